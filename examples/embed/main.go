@@ -0,0 +1,66 @@
+// Embed shows how other services can authorize topics in-process using the soteria authorizer.
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/snapp-incubator/soteria/pkg/authorizer"
+)
+
+func main() {
+	// nolint: exhaustruct
+	vendor := authorizer.Vendor{
+		Company:            "snapp",
+		Type:               "manual",
+		AllowedAccessTypes: []string{"pub", "sub"},
+		Topics: []authorizer.Topic{
+			{
+				Type:     "driver_location",
+				Template: "^{{.company}}/driver/{{.sub}}/location$",
+				Accesses: map[string]acl.AccessType{"0": acl.Pub},
+			},
+		},
+		Keys: map[string]string{
+			"0": base64.StdEncoding.EncodeToString([]byte(os.Getenv("SOTERIA_DRIVER_KEY"))),
+		},
+		IssEntityMap: map[string]string{"0": "driver", "default": ""},
+		IssPeerMap:   map[string]string{"0": "passenger", "default": ""},
+		JWT: authorizer.JWT{
+			IssName:       "iss",
+			SubName:       "sub",
+			SigningMethod: "HS512",
+		},
+	}
+
+	a, err := authorizer.New(vendor)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(os.Args) != 3 { // nolint: mnd
+		log.Fatalf("usage: %s <token> <topic>", os.Args[0])
+	}
+
+	token, topic := os.Args[1], os.Args[2]
+
+	ok, err := a.ACL(context.Background(), acl.Pub, token, topic)
+	if err != nil {
+		log.Printf("publish on %s is denied: %s", topic, err)
+	}
+
+	fmt.Printf("publish on %s: %t\n", topic, ok)
+
+	permissions, err := a.AllowedTopics(context.Background(), token)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, permission := range permissions {
+		fmt.Printf("%s %s%s %v\n", permission.Type, permission.Topic, permission.Pattern, permission.Accesses)
+	}
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/snapp-incubator/soteria/internal/flags"
+	"github.com/snapp-incubator/soteria/internal/version"
+)
+
+// AboutResponse is the build information of binary with the enabled feature flags of each vendor,
+// so the version skew of fleet and the features of its vendors can be compared.
+type AboutResponse struct {
+	version.Info
+	Features map[string][]string `json:"features"`
+}
+
+// About returns the build information and the enabled feature flags of vendors.
+func (a API) About(c *fiber.Ctx) error {
+	features := make(map[string][]string, len(a.Authenticators))
+
+	for company := range a.Authenticators {
+		features[company] = make([]string, 0)
+
+		for _, name := range flags.Names() {
+			if a.Flags.Enabled(company, name) {
+				features[company] = append(features[company], name)
+			}
+		}
+	}
+
+	return c.Status(http.StatusOK).JSON(AboutResponse{
+		Info:     version.Get(),
+		Features: features,
+	})
+}
@@ -1,19 +1,73 @@
 package api
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/snapp-incubator/soteria/internal/audit"
 	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/budget"
+	"github.com/snapp-incubator/soteria/internal/topics"
 	"github.com/snapp-incubator/soteria/pkg/acl"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
+// malformedTopicLogLength is the length of malformed topics prefix which is logged.
+const malformedTopicLogLength = 64
+
 type ACLResponse struct {
 	Result string `json:"result,omitempty"`
+	// Reason is the machine-readable reason of denials which are caused by topic accesses.
+	Reason string `json:"reason,omitempty"`
+	// GrantedAccesses are the accesses which client actually has on the denied topic.
+	GrantedAccesses []string `json:"granted_accesses,omitempty"`
+	// TopicAttrs are the matched topic type and entity of allowed requests, they are set only for vendors which emit them.
+	*authenticator.TopicAttrs
+}
+
+// SessionDecision is the memoized ACL decision of a session, Err is the denial error which is counted
+// on each use.
+type SessionDecision struct {
+	Response ACLResponse
+	Err      error
+}
+
+// topicNotAllowed returns the deny response which describes the granted accesses of client.
+func topicNotAllowed(err authenticator.TopicNotAllowedError) ACLResponse {
+	granted := make([]string, 0, len(err.GrantedAccesses()))
+
+	for _, access := range err.GrantedAccesses() {
+		granted = append(granted, access.String())
+	}
+
+	return ACLResponse{
+		Result:          "deny",
+		Reason:          err.Reason(),
+		GrantedAccesses: granted,
+		TopicAttrs:      nil,
+	}
+}
+
+// topicAccess returns the access type of EMQ action, unknown actions have no access type.
+func topicAccess(action string) acl.AccessType {
+	var access acl.AccessType
+
+	switch action {
+	case "publish":
+		access = acl.Pub
+	case "subscribe":
+		access = acl.Sub
+	}
+
+	return access
 }
 
 // ACLRequest is the body payload structure of the ACL endpoint.
@@ -23,15 +77,32 @@ type ACLRequest struct {
 	Password string `json:"password"`
 	Topic    string `json:"topic"`
 	Action   string `json:"action"`
+	ClientID string `json:"clientid"`
+	// PayloadSize is the size of the published message in bytes which is provided by broker on publish.
+	PayloadSize int `json:"payload_size"`
+	// IPAddress and PeerHost are the address of MQTT client which EMQ includes in its requests.
+	IPAddress string `json:"ipaddress"`
+	PeerHost  string `json:"peerhost"`
+	// Mountpoint is the listener mountpoint which EMQ prepends to the topic.
+	Mountpoint string `json:"mountpoint"`
+	// Listener is the EMQ listener of client, it scopes the anonymous policies.
+	Listener string `json:"listener"`
+	// Payload is the base64 payload of publish which EMQ forwards for the topics with payload checks.
+	Payload string `json:"payload,omitempty"`
 }
 
 // ACLv2 is the handler responsible for ACL requests coming from EMQv5.
 // https://www.emqx.io/docs/en/latest/access-control/authz/http.html
 // nolint: funlen
 func (a API) ACLv2(c *fiber.Ctx) error {
-	ctx, span := a.Tracer.Start(c.Context(), "api.v2.acl")
+	start := time.Now()
+
+	traceCtx, span := a.Tracer.Start(traceContext(c), "api.v2.acl", trace.WithSpanKind(trace.SpanKindServer))
 	defer span.End()
 
+	// a is a copy, so the handler and its helpers use the request-scoped logger.
+	a = a.correlate(c, span)
+
 	request := new(ACLRequest)
 	if err := c.BodyParser(request); err != nil {
 		a.Logger.
@@ -46,14 +117,109 @@ func (a API) ACLv2(c *fiber.Ctx) error {
 		a.Metrics.ACLFailed("unknown_company_before_parse_body", err)
 
 		return c.Status(http.StatusOK).JSON(ACLResponse{
-			Result: "deny",
+			Result:          "deny",
+			Reason:          "",
+			GrantedAccesses: nil,
+			TopicAttrs:      nil,
 		})
 	}
 
-	vendor, token := ExtractVendorToken(request.Token, request.Username, request.Password)
+	auth, token, resolution, resolveErr := a.credentials("acl", request.Token, request.Username, request.Password)
 
-	topic := request.Topic
-	auth := a.Authenticator(vendor)
+	if token == "" {
+		return a.anonymousACL(c, auth.GetCompany(), request)
+	}
+
+	c.Locals(vendorLocal, auth.GetCompany())
+	c.Locals(topicLocal, request.Topic)
+
+	token = a.normalizeCredential(auth, "acl", request.Token, request.Username, token)
+
+	if err := a.checkCredential(auth, "acl", request.Token, request.Username, token); err != nil {
+		a.Metrics.ACLFailed(auth.GetCompany(), err)
+
+		return c.Status(http.StatusOK).JSON(ACLResponse{
+			Result:          "deny",
+			Reason:          authenticator.ReasonMalformedCredential,
+			GrantedAccesses: nil,
+			TopicAttrs:      nil,
+		})
+	}
+
+	if resolveErr != nil {
+		a.Metrics.ACLFailed(auth.GetCompany(), resolveErr)
+
+		a.Logger.
+			Warn("acl request issuer is not known",
+				zap.Error(resolveErr),
+				zap.Strings("resolution", a.VendorResolution),
+				zap.String("topic", request.Topic),
+			)
+
+		return c.Status(http.StatusOK).JSON(ACLResponse{
+			Result:          "deny",
+			Reason:          authenticator.ReasonUnknownIssuer,
+			GrantedAccesses: nil,
+			TopicAttrs:      nil,
+		})
+	}
+
+	if state := a.States.Get(auth.GetCompany()); state.State == VendorStateDisabled {
+		a.Metrics.VendorDisabled(auth.GetCompany(), "acl", state.Policy)
+
+		return c.Status(http.StatusOK).JSON(ACLResponse{
+			Result:          state.Policy,
+			Reason:          "",
+			GrantedAccesses: nil,
+			TopicAttrs:      nil,
+		})
+	}
+
+	// a is a copy, so the maintenance only changes the handler of this request.
+	a, traceCtx = a.maintain(traceCtx, span, auth.GetCompany(), "acl")
+
+	topic, err := a.stripMountpoint(auth.GetCompany(), request.Mountpoint, request.Topic)
+	if err != nil {
+		a.Metrics.ACLFailed(auth.GetCompany(), err)
+
+		a.Logger.
+			Warn("acl request mountpoint is not allowed",
+				zap.Error(err),
+				zap.String("authenticator", auth.GetCompany()),
+				zap.String("topic", request.Topic),
+			)
+
+		return c.Status(http.StatusOK).JSON(ACLResponse{
+			Result:          "deny",
+			Reason:          authenticator.ReasonUnexpectedMountpoint,
+			GrantedAccesses: nil,
+			TopicAttrs:      nil,
+		})
+	}
+
+	if request.Mountpoint != "" {
+		a.Logger.
+			Debug("acl request mountpoint is stripped",
+				zap.String("mountpoint", request.Mountpoint),
+				zap.String("topic", request.Topic),
+				zap.String("normalized-topic", topic),
+			)
+	}
+
+	if err := topics.Sanitize(topic, a.MaxTopicLength); err != nil {
+		a.malformedTopic(auth.GetCompany(), "acl", topic, err)
+		a.Metrics.ACLFailed(auth.GetCompany(), err)
+
+		return c.Status(http.StatusOK).JSON(ACLResponse{
+			Result:          "deny",
+			Reason:          "",
+			GrantedAccesses: nil,
+			TopicAttrs:      nil,
+		})
+	}
+
+	addr := ClientIP(c, request.IPAddress, request.PeerHost)
+	clientIP := formatIP(addr)
 
 	logger := a.Logger.With(
 		zap.String("access", request.Action),
@@ -62,6 +228,7 @@ func (a API) ACLv2(c *fiber.Ctx) error {
 		zap.String("username", request.Username),
 		zap.String("password", request.Password),
 		zap.String("authenticator", auth.GetCompany()),
+		zap.String("client-ip", clientIP),
 	)
 
 	span.SetAttributes(
@@ -70,39 +237,259 @@ func (a API) ACLv2(c *fiber.Ctx) error {
 		attribute.String("username", request.Username),
 		attribute.String("password", request.Password),
 		attribute.String("authenticator", auth.GetCompany()),
+		attribute.Int("payload-size", request.PayloadSize),
+		attribute.String("client-ip", clientIP),
 	)
 
-	var access acl.AccessType
+	access := topicAccess(request.Action)
 
-	switch request.Action {
-	case "publish":
-		access = acl.Pub
-	case "subscribe":
-		access = acl.Sub
+	if client, ok := staticClient(auth, request.Token, request.Username); ok {
+		return a.staticACL(c, auth, client, request, topic, access)
+	}
+
+	// decisions of publishes with payload or its size depend on them, so they are never memoized.
+	if request.Payload != "" || request.PayloadSize > 0 {
+		a.Sessions = nil
+	}
+
+	// request context is reused by fiber after handler returns, so calls which may
+	// be abandoned because of budget use a detached context.
+	// broker secret is copied because fiber reuses the header buffers.
+	brokerSecret := strings.Clone(c.Get(authenticator.BrokerSecretHeader))
+
+	ctx, cancel := budget.Start(authenticator.WithSubjectRecord(authenticator.WithBrokerSecret(
+		authenticator.WithPayload(traceCtx, request.Payload),
+		brokerSecret,
+	)), a.Budget.ACL.Deadline)
+	defer cancel()
+
+	// the vendor which answers the parallel resolution of auth request authorizes its acl requests too.
+	if answered := a.aclVendor(ctx, auth, resolution, token, addr); answered.GetCompany() != auth.GetCompany() {
+		auth = answered
+		logger = logger.With(zap.String("answered-authenticator", auth.GetCompany()))
+
+		c.Locals(vendorLocal, auth.GetCompany())
+	}
+
+	credential := sessionCredential(token, brokerSecret)
+
+	if decision, ok := a.Sessions.Get(auth.GetCompany(), request.ClientID, credential, topic, access); ok {
+		if decision.Err != nil {
+			a.Metrics.ACLFailed(auth.GetCompany(), decision.Err)
+		} else {
+			a.Metrics.ACLSuccess(auth.GetCompany())
+		}
+
+		logger.Debug("acl request is answered by session decision", zap.String("result", decision.Response.Result))
+
+		return c.Status(http.StatusOK).JSON(decision.Response)
+	}
+
+	var (
+		ok    bool
+		attrs *authenticator.TopicAttrs
+	)
+
+	err = budget.Run(ctx, func(ctx context.Context) error {
+		var err error
+
+		ok, attrs, err = authorize(ctx, auth, access, token, topic, request.PayloadSize)
+
+		return err
+	})
+	if exceeded := new(budget.ExceededError); errors.As(err, exceeded) {
+		decision := a.budgetExceeded(logger, auth.GetCompany(), "acl", a.Budget.ACL, *exceeded)
+
+		a.audit(ctx, auth, access, exceeded.TopicType, decision, ReasonBudgetExceeded, start, err)
+
+		return c.Status(http.StatusOK).JSON(ACLResponse{
+			Result:          decision,
+			Reason:          "",
+			GrantedAccesses: nil,
+			TopicAttrs:      nil,
+		})
+	}
+
+	if err != nil || ok {
+		a.capture(auth, token, topic, access, err)
+	}
+
+	if err == nil && ok {
+		a.audit(ctx, auth, access, budget.TopicType(ctx), audit.ResultAllow, "", start, nil)
+	} else {
+		a.audit(ctx, auth, access, budget.TopicType(ctx), audit.ResultDeny, "", start, err)
 	}
 
-	ok, err := auth.ACL(ctx, access, token, topic)
 	if err != nil || !ok {
 		if err != nil {
 			span.RecordError(err)
 		}
 
 		a.Metrics.ACLFailed(auth.GetCompany(), err)
+		a.InvalidTopics.Record(auth.GetCompany(), topic, err)
 
-		var tnaErr authenticator.TopicNotAllowedError
+		var (
+			tnaErr authenticator.TopicNotAllowedError
+			ptlErr authenticator.PayloadTooLargeError
+			treErr authenticator.TemplateRenderError
+			sleErr authenticator.SubscriptionLimitExceededError
+			rmErr  authenticator.RideMismatchError
+			itfErr authenticator.InvalidTopicFieldError
+			pmErr  authenticator.PayloadMismatchError
+			pdErr  authenticator.PolicyDeniedError
+		)
 
 		if errors.As(err, &tnaErr) {
 			logger.
 				Warn("acl request is not authorized",
-					zap.Error(tnaErr))
-		} else if !errors.Is(err, jwt.ErrTokenExpired) {
+					zap.Error(tnaErr),
+					zap.String("reason", tnaErr.Reason()),
+				)
+
+			response := topicNotAllowed(tnaErr)
+			a.Sessions.Set(auth.GetCompany(), request.ClientID, credential, topic, access, SessionDecision{
+				Response: response,
+				Err:      tnaErr,
+			}, sessionExpiry(auth, token))
+
+			return c.Status(http.StatusOK).JSON(response)
+		}
+
+		if errors.As(err, &treErr) {
+			logger.
+				Warn("acl request topic template cannot be rendered",
+					zap.Error(treErr),
+					zap.String("topic-type", treErr.TopicType),
+					zap.String("field", treErr.Field),
+				)
+
+			return c.Status(http.StatusOK).JSON(ACLResponse{
+				Result:          "deny",
+				Reason:          treErr.Reason(),
+				GrantedAccesses: nil,
+				TopicAttrs:      nil,
+			})
+		}
+
+		if errors.As(err, &rmErr) {
+			logger.
+				Warn("acl request topic doesn't belong to the ride of client",
+					zap.Error(rmErr),
+					zap.String("topic-type", rmErr.TopicType),
+				)
+
+			response := ACLResponse{
+				Result:          "deny",
+				Reason:          rmErr.Reason(),
+				GrantedAccesses: nil,
+				TopicAttrs:      nil,
+			}
+			a.Sessions.Set(auth.GetCompany(), request.ClientID, credential, topic, access, SessionDecision{
+				Response: response,
+				Err:      rmErr,
+			}, sessionExpiry(auth, token))
+
+			return c.Status(http.StatusOK).JSON(response)
+		}
+
+		if errors.As(err, &pdErr) {
+			logger.
+				Warn("acl request is denied by policy",
+					zap.Error(pdErr),
+					zap.String("topic-type", pdErr.TopicType),
+					zap.String("reason", pdErr.Reason()),
+				)
+
+			response := ACLResponse{
+				Result:          "deny",
+				Reason:          pdErr.Reason(),
+				GrantedAccesses: nil,
+				TopicAttrs:      nil,
+			}
+			a.Sessions.Set(auth.GetCompany(), request.ClientID, credential, topic, access, SessionDecision{
+				Response: response,
+				Err:      pdErr,
+			}, sessionExpiry(auth, token))
+
+			return c.Status(http.StatusOK).JSON(response)
+		}
+
+		if errors.As(err, &itfErr) {
+			logger.
+				Warn("acl request topic has a field which is not allowed",
+					zap.Error(itfErr),
+					zap.String("topic-type", itfErr.TopicType),
+					zap.String("field", itfErr.Field),
+				)
+
+			response := ACLResponse{
+				Result:          "deny",
+				Reason:          itfErr.Reason(),
+				GrantedAccesses: nil,
+				TopicAttrs:      nil,
+			}
+			a.Sessions.Set(auth.GetCompany(), request.ClientID, credential, topic, access, SessionDecision{
+				Response: response,
+				Err:      itfErr,
+			}, sessionExpiry(auth, token))
+
+			return c.Status(http.StatusOK).JSON(response)
+		}
+
+		if errors.As(err, &pmErr) {
+			logger.
+				Warn("acl request payload doesn't match its topic",
+					zap.Error(pmErr),
+					zap.String("topic-type", pmErr.TopicType),
+					zap.String("path", pmErr.Path),
+					zap.String("client-id", request.ClientID),
+				)
+
+			return c.Status(http.StatusOK).JSON(ACLResponse{
+				Result:          "deny",
+				Reason:          pmErr.Reason(),
+				GrantedAccesses: nil,
+				TopicAttrs:      nil,
+			})
+		}
+
+		if errors.As(err, &sleErr) {
+			return c.Status(http.StatusOK).JSON(ACLResponse{
+				Result:          "deny",
+				Reason:          sleErr.Reason(),
+				GrantedAccesses: nil,
+				TopicAttrs:      nil,
+			})
+		}
+
+		if errors.As(err, &ptlErr) {
+			a.Metrics.PayloadTooLarge(auth.GetCompany(), ptlErr.TopicType, a.Parser.Parse(request.ClientID))
+
+			logger.
+				Warn("acl request payload is too large",
+					zap.Error(ptlErr),
+					zap.String("client-id", request.ClientID),
+				)
+
+			return c.Status(http.StatusOK).JSON(ACLResponse{
+				Result:          "deny",
+				Reason:          ptlErr.Reason(),
+				GrantedAccesses: nil,
+				TopicAttrs:      nil,
+			})
+		}
+
+		if !errors.Is(err, jwt.ErrTokenExpired) {
 			logger.
 				Error("acl request is not authorized",
 					zap.Error(err))
 		}
 
 		return c.Status(http.StatusOK).JSON(ACLResponse{
-			Result: "deny",
+			Result:          "deny",
+			Reason:          tokenReason(err),
+			GrantedAccesses: nil,
+			TopicAttrs:      nil,
 		})
 	}
 
@@ -110,7 +497,80 @@ func (a API) ACLv2(c *fiber.Ctx) error {
 		Info("acl ok")
 	a.Metrics.ACLSuccess(auth.GetCompany())
 
-	return c.Status(http.StatusOK).JSON(ACLResponse{
-		Result: "allow",
-	})
+	response := ACLResponse{
+		Result:          "allow",
+		Reason:          "",
+		GrantedAccesses: nil,
+		TopicAttrs:      attrs,
+	}
+	a.Sessions.Set(auth.GetCompany(), request.ClientID, credential, topic, access, SessionDecision{
+		Response: response,
+		Err:      nil,
+	}, sessionExpiry(auth, token))
+
+	return c.Status(http.StatusOK).JSON(response)
+}
+
+// sessionCredential returns the credential which session decisions of the token are keyed by,
+// it has the broker secret because auto vendors accept their unverified tokens by it.
+func sessionCredential(token, brokerSecret string) string {
+	return token + "\n" + brokerSecret
+}
+
+// sessionExpiry returns the expiry of token which its session decisions expire at,
+// it is zero for the tokens without exp claim.
+func sessionExpiry(auth authenticator.Authenticator, token string) time.Time {
+	expiry, ok := auth.(authenticator.ExpiryAuthenticator)
+	if !ok {
+		return time.Time{}
+	}
+
+	exp, ok := expiry.TokenExpiry(token)
+	if !ok {
+		return time.Time{}
+	}
+
+	return exp
+}
+
+// malformedTopic reports the topic which is rejected by sanitation, only a quoted prefix
+// of topic is logged because it can be large or contain unprintable characters.
+func (a API) malformedTopic(company, endpoint, topic string, err error) {
+	var mErr topics.MalformedTopicError
+
+	if !errors.As(err, &mErr) {
+		return
+	}
+
+	a.Metrics.MalformedTopic(company, endpoint, mErr.Reason)
+
+	if len(topic) > malformedTopicLogLength {
+		topic = topic[:malformedTopicLogLength]
+	}
+
+	a.Logger.Warn("topic is malformed",
+		zap.Error(err),
+		zap.String("authenticator", company),
+		zap.String("endpoint", endpoint),
+		zap.String("topic-prefix", strconv.QuoteToASCII(topic)),
+	)
+}
+
+// authorize checks the access and returns topic attributes when authenticator supports them.
+func authorize(
+	ctx context.Context,
+	auth authenticator.Authenticator,
+	access acl.AccessType,
+	token, topic string,
+	payloadSize int,
+) (bool, *authenticator.TopicAttrs, error) {
+	if topicAuth, ok := auth.(authenticator.TopicAuthenticator); ok {
+		attrs, err := topicAuth.ACLWithTopic(ctx, access, token, topic, payloadSize)
+
+		return err == nil, attrs, err //nolint: wrapcheck
+	}
+
+	ok, err := auth.ACL(ctx, access, token, topic, payloadSize)
+
+	return ok, nil, err //nolint: wrapcheck
 }
@@ -0,0 +1,358 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/snapp-incubator/soteria/internal/audit"
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"go.uber.org/zap"
+)
+
+const bearerPrefix = "bearer "
+
+type AdminErrorResponse struct {
+	Error string `json:"error"`
+}
+
+type AdminVendorResponse struct {
+	Company     string `json:"company"`
+	IsSuperuser bool   `json:"is_superuser"`
+	VendorState
+	// Maintenance is the maintenance window of vendor, it is nil when vendor is not in maintenance.
+	Maintenance *Maintenance `json:"maintenance,omitempty"`
+}
+
+// AdminVendorCachesResponse is the result of flushing the caches of vendor.
+type AdminVendorCachesResponse struct {
+	Company string `json:"company"`
+	// Sessions is the number of the flushed session decisions.
+	Sessions int `json:"sessions"`
+}
+
+// AdminVendorConfigResponse is the redacted configuration of vendor, its features are the effective
+// feature flags which are reloaded without the rest of configuration.
+type AdminVendorConfigResponse struct {
+	ConfigGeneration uint64          `json:"config_generation"`
+	Features         map[string]bool `json:"features"`
+	config.Vendor
+}
+
+// AdminAuth only allows requests with a bearer token which is accepted by one of the superuser authenticators.
+func (a API) AdminAuth(c *fiber.Ctx) error {
+	header := c.Get(fiber.HeaderAuthorization)
+
+	if len(header) <= len(bearerPrefix) || !strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+		return c.Status(http.StatusUnauthorized).JSON(AdminErrorResponse{
+			Error: "bearer token is required",
+		})
+	}
+
+	_, token := ExtractVendorToken(header[len(bearerPrefix):], "", "")
+
+	for _, auth := range a.Authenticators {
+		if !auth.IsSuperuser() {
+			continue
+		}
+
+		if err := auth.Auth(c.Context(), token); err == nil {
+			return c.Next()
+		}
+	}
+
+	a.Logger.Warn("admin request is not authorized", zap.String("path", c.Path()))
+
+	return c.Status(http.StatusUnauthorized).JSON(AdminErrorResponse{
+		Error: "admin token is invalid",
+	})
+}
+
+// AdminKeys lists fingerprints of the loaded keys, it never returns the key material.
+func (a API) AdminKeys(c *fiber.Ctx) error {
+	keys := make([]authenticator.KeyInfo, 0)
+
+	if a.Keys != nil {
+		keys = a.Keys.List()
+	}
+
+	return c.Status(http.StatusOK).JSON(keys)
+}
+
+// AdminVendors lists vendors with their runtime state.
+func (a API) AdminVendors(c *fiber.Ctx) error {
+	vendors := make([]AdminVendorResponse, 0, len(a.Authenticators))
+
+	for company, auth := range a.Authenticators {
+		vendors = append(vendors, AdminVendorResponse{
+			Company:     company,
+			IsSuperuser: auth.IsSuperuser(),
+			VendorState: a.States.Get(company),
+			Maintenance: a.maintenance(company),
+		})
+	}
+
+	slices.SortFunc(vendors, func(a, b AdminVendorResponse) int {
+		return strings.Compare(a.Company, b.Company)
+	})
+
+	return c.Status(http.StatusOK).JSON(vendors)
+}
+
+// AdminVendorState enables or disables a vendor at runtime.
+func (a API) AdminVendorState(c *fiber.Ctx) error {
+	company := c.Params("name")
+
+	if _, ok := a.Authenticators[company]; !ok || a.States == nil {
+		return c.Status(http.StatusNotFound).JSON(AdminErrorResponse{
+			Error: "vendor not found",
+		})
+	}
+
+	state := new(VendorState)
+
+	if err := c.BodyParser(state); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(AdminErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	if err := state.Validate(); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(AdminErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	a.States.Set(company, *state)
+
+	a.Logger.Warn("vendor state changed",
+		zap.String("company", company),
+		zap.String("state", state.State),
+		zap.String("policy", state.Policy),
+	)
+
+	return c.Status(http.StatusOK).JSON(AdminVendorResponse{
+		Company:     company,
+		IsSuperuser: a.Authenticators[company].IsSuperuser(),
+		VendorState: *state,
+		Maintenance: a.maintenance(company),
+	})
+}
+
+// AdminVendorMaintenance starts the maintenance of vendor until its mandatory expiry, tokens of vendor
+// are not verified by its keys or validator during the maintenance. The window is only started on this pod.
+func (a API) AdminVendorMaintenance(c *fiber.Ctx) error {
+	company := c.Params("name")
+
+	if _, ok := a.Authenticators[company]; !ok || a.Maintenances == nil {
+		return c.Status(http.StatusNotFound).JSON(AdminErrorResponse{
+			Error: "vendor not found",
+		})
+	}
+
+	window := new(Maintenance)
+
+	if err := c.BodyParser(window); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(AdminErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	if err := window.Validate(time.Now()); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(AdminErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	a.Maintenances.Set(company, *window)
+
+	a.Logger.Warn("vendor maintenance started",
+		zap.String("company", company),
+		zap.Time("expires-at", window.ExpiresAt),
+		zap.String("reason", window.Reason),
+	)
+
+	return c.Status(http.StatusOK).JSON(AdminVendorResponse{
+		Company:     company,
+		IsSuperuser: a.Authenticators[company].IsSuperuser(),
+		VendorState: a.States.Get(company),
+		Maintenance: window,
+	})
+}
+
+// AdminVendorMaintenanceEnd ends the maintenance of vendor before its expiry.
+func (a API) AdminVendorMaintenanceEnd(c *fiber.Ctx) error {
+	company := c.Params("name")
+
+	if _, ok := a.Authenticators[company]; !ok || !a.Maintenances.End(company) {
+		return c.Status(http.StatusNotFound).JSON(AdminErrorResponse{
+			Error: "vendor is not in maintenance",
+		})
+	}
+
+	a.Logger.Warn("vendor maintenance ended", zap.String("company", company))
+
+	return c.Status(http.StatusOK).JSON(AdminVendorResponse{
+		Company:     company,
+		IsSuperuser: a.Authenticators[company].IsSuperuser(),
+		VendorState: a.States.Get(company),
+		Maintenance: nil,
+	})
+}
+
+// maintenance returns the maintenance window of vendor, it is nil when vendor is not in maintenance.
+func (a API) maintenance(company string) *Maintenance {
+	window, ok := a.Maintenances.Get(company)
+	if !ok {
+		return nil
+	}
+
+	return &window
+}
+
+// AdminVendorCaches flushes the session decisions and the authenticator caches of vendor,
+// caches of the other vendors are kept.
+func (a API) AdminVendorCaches(c *fiber.Ctx) error {
+	company := c.Params("name")
+
+	auth, ok := a.Authenticators[company]
+	if !ok {
+		return c.Status(http.StatusNotFound).JSON(AdminErrorResponse{
+			Error: "vendor not found",
+		})
+	}
+
+	if flusher, ok := auth.(authenticator.FlushAuthenticator); ok {
+		flusher.Flush()
+	}
+
+	sessions := a.Sessions.Flush(company)
+
+	a.Logger.Warn("vendor caches flushed", zap.String("company", company), zap.Int("sessions", sessions))
+
+	return c.Status(http.StatusOK).JSON(AdminVendorCachesResponse{
+		Company:  company,
+		Sessions: sessions,
+	})
+}
+
+// AdminVendorConfig returns the active configuration of vendor with its secrets redacted.
+func (a API) AdminVendorConfig(c *fiber.Ctx) error {
+	vendor, ok := a.Configs.Get(c.Params("name"))
+	if !ok {
+		return c.Status(http.StatusNotFound).JSON(AdminErrorResponse{
+			Error: "vendor not found",
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(AdminVendorConfigResponse{
+		ConfigGeneration: a.Configs.Generation(),
+		Features:         a.Flags.List()[vendor.Company],
+		Vendor:           vendor,
+	})
+}
+
+// AdminFlags lists the effective feature flags of vendors.
+func (a API) AdminFlags(c *fiber.Ctx) error {
+	return c.Status(http.StatusOK).JSON(a.Flags.List())
+}
+
+// AdminRecentDecisions lists the newest ACL decisions which match the vendor, result and topic_type queries,
+// newest first. Their number is capped by the limit query which is audit.DefaultLimit by default.
+func (a API) AdminRecentDecisions(c *fiber.Ctx) error {
+	if a.Decisions == nil {
+		return c.Status(http.StatusNotFound).JSON(AdminErrorResponse{
+			Error: "recent decisions are disabled",
+		})
+	}
+
+	result := c.Query("result")
+	if result != "" && result != audit.ResultAllow && result != audit.ResultDeny {
+		return c.Status(http.StatusBadRequest).JSON(AdminErrorResponse{
+			Error: "result should be allow or deny",
+		})
+	}
+
+	limit := audit.DefaultLimit
+
+	if query := c.Query("limit"); query != "" {
+		var err error
+
+		limit, err = strconv.Atoi(query)
+		if err != nil || limit <= 0 {
+			return c.Status(http.StatusBadRequest).JSON(AdminErrorResponse{
+				Error: "limit should be a positive number",
+			})
+		}
+	}
+
+	return c.Status(http.StatusOK).JSON(a.Decisions.List(audit.Filter{
+		Vendor:    c.Query("vendor"),
+		Result:    result,
+		TopicType: c.Query("topic_type"),
+		Sub:       c.Query("sub"),
+		Limit:     limit,
+	}))
+}
+
+// DebugPermissionsRequest has the token in the body, so it is not kept in the access logs of proxies
+// like the query strings.
+type DebugPermissionsRequest struct {
+	// Token is the token of client with its vendor prefix, e.g. vendor:token.
+	Token string `json:"token"`
+}
+
+type DebugPermissionsResponse struct {
+	Vendor      string              `json:"vendor"`
+	Permissions []topics.Permission `json:"permissions"`
+}
+
+// DebugPermissions lists the allowed topics of the given token using the same rendering as ACL.
+func (a API) DebugPermissions(c *fiber.Ctx) error {
+	request := new(DebugPermissionsRequest)
+
+	if err := c.BodyParser(request); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(AdminErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	if request.Token == "" {
+		return c.Status(http.StatusBadRequest).JSON(AdminErrorResponse{
+			Error: "token is required",
+		})
+	}
+
+	vendor, token := ExtractVendorToken(request.Token, "", "")
+
+	auth, err := a.Authenticator(vendor, token)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(AdminErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	permAuth, ok := auth.(authenticator.PermissionsAuthenticator)
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(AdminErrorResponse{
+			Error: "vendor " + auth.GetCompany() + " cannot list permissions",
+		})
+	}
+
+	permissions, err := permAuth.Permissions(c.Context(), token)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(AdminErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(DebugPermissionsResponse{
+		Vendor:      auth.GetCompany(),
+		Permissions: permissions,
+	})
+}
@@ -0,0 +1,554 @@
+package api_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/api"
+	"github.com/snapp-incubator/soteria/internal/audit"
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/flags"
+	"github.com/snapp-incubator/soteria/internal/metric"
+	"github.com/snapp-incubator/soteria/internal/session"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/snapp-incubator/soteria/pkg/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// nolint: funlen
+func TestVendorState(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	key := "secret"
+
+	features := flags.New(zap.NewNop())
+	features.Load(map[string]bool{flags.EmitClientAttrs: true}, map[string]map[string]bool{
+		"snapp-admin": {flags.EmitClientAttrs: false},
+	})
+
+	a := newTestAPI(authenticator.AdminAuthenticator{
+		Key:     []byte(key),
+		Company: "snapp-admin",
+		JwtConfig: config.JWT{
+			IssName:       "iss",
+			SubName:       "sub",
+			SigningMethod: "HS512",
+		},
+		Parser: jwt.NewParser(),
+	})
+	a.WillTopicPolicy = api.WillTopicPolicyDeny
+	a.States = api.NewVendorStates()
+	a.Flags = features
+
+	app := a.ReSTServer()
+
+	token, err := getSampleToken(key)
+	require.NoError(err)
+
+	auth := func() string {
+		// nolint: exhaustruct
+		return decode[api.AuthResponse](t, postJSON(t, app, "/v2/auth", api.AuthRequest{Username: token})).Result
+	}
+
+	state := func(authorization string, state api.VendorState) int {
+		req := jsonRequest(t, http.MethodPut, "/v2/admin/vendors/snapp-admin/state", state)
+		req.Header.Add("Authorization", authorization)
+
+		return send(t, app, req).StatusCode
+	}
+
+	require.Equal("allow", auth())
+
+	require.Equal(http.StatusUnauthorized, state("", api.VendorState{State: api.VendorStateDisabled, Policy: ""}))
+	require.Equal(http.StatusUnauthorized, state("Bearer invalid", api.VendorState{State: api.VendorStateDisabled, Policy: ""}))
+	require.Equal(http.StatusBadRequest, state("Bearer "+token, api.VendorState{State: "paused", Policy: ""}))
+	require.Equal(http.StatusBadRequest, state("Bearer "+token, api.VendorState{State: api.VendorStateDisabled, Policy: "x"}))
+
+	require.Equal(http.StatusOK, state("Bearer "+token, api.VendorState{State: api.VendorStateDisabled, Policy: ""}))
+	require.Equal("deny", auth())
+
+	require.Equal(http.StatusOK, state("Bearer "+token, api.VendorState{
+		State:  api.VendorStateDisabled,
+		Policy: api.VendorPolicyIgnore,
+	}))
+	require.Equal("ignore", auth())
+
+	req := httptest.NewRequest(http.MethodGet, "/v2/admin/vendors", nil)
+	req.Header.Add("Authorization", "bearer snapp-admin:"+token)
+
+	vendors := decode[[]api.AdminVendorResponse](t, send(t, app, req))
+	require.Equal([]api.AdminVendorResponse{
+		{
+			Company:     "snapp-admin",
+			IsSuperuser: true,
+			VendorState: api.VendorState{State: api.VendorStateDisabled, Policy: api.VendorPolicyIgnore},
+		},
+	}, vendors)
+
+	require.Equal(http.StatusOK, state("Bearer "+token, api.VendorState{State: api.VendorStateEnabled, Policy: ""}))
+	require.Equal("allow", auth())
+
+	req = httptest.NewRequest(http.MethodGet, "/v2/admin/flags", nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+
+	effective := decode[map[string]map[string]bool](t, send(t, app, req))
+	require.Equal(map[string]map[string]bool{
+		"snapp-admin": {
+			flags.EmitClientAttrs:     false,
+			flags.EmitTopicType:       false,
+			flags.EmitExpireAt:        false,
+			flags.LenientTokenParsing: false,
+			flags.AuthFailureStatus:   false,
+			flags.NormalizeTopics:     false,
+		},
+	}, effective)
+}
+
+// nolint: funlen
+func TestDebugPermissions(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	key := "secret"
+	cfg := config.SnappVendor()
+
+	a := newTestAPI(manualAuthenticator(t, []byte(key), cfg), authenticator.AdminAuthenticator{
+		Key:     []byte(key),
+		Company: "snapp-admin",
+		JwtConfig: config.JWT{
+			IssName:       "iss",
+			SubName:       "sub",
+			SigningMethod: "HS512",
+		},
+		Parser: jwt.NewParser(),
+	})
+	a.VendorResolution = []string{"snapp"}
+	a.WillTopicPolicy = api.WillTopicPolicyDeny
+	a.States = api.NewVendorStates()
+
+	app := fiber.New()
+	app.Post("/v2/debug/permissions", a.AdminAuth, a.DebugPermissions)
+
+	admin, err := getSampleToken(key)
+	require.NoError(err)
+
+	driver, err := testutil.DriverToken(jwt.SigningMethodHS512, []byte(key))
+	require.NoError(err)
+
+	permissions := func(token string) (int, api.DebugPermissionsResponse) {
+		req := jsonRequest(t, http.MethodPost, "/v2/debug/permissions", api.DebugPermissionsRequest{Token: token})
+		req.Header.Add("Authorization", "Bearer "+admin)
+
+		resp := send(t, app, req)
+
+		var response api.DebugPermissionsResponse
+
+		_ = json.NewDecoder(resp.Body).Decode(&response)
+
+		return resp.StatusCode, response
+	}
+
+	status, response := permissions("snapp:" + driver)
+	require.Equal(http.StatusOK, status)
+	require.Equal("snapp", response.Vendor)
+	require.Contains(response.Permissions, topics.Permission{
+		Type:     topics.DriverLocation,
+		Topic:    "snapp/driver/" + testutil.DefaultSubject + "/location",
+		Pattern:  "",
+		Accesses: []string{"publish"},
+	})
+
+	status, _ = permissions("invalid")
+	require.Equal(http.StatusBadRequest, status)
+
+	status, _ = permissions("")
+	require.Equal(http.StatusBadRequest, status)
+}
+
+// nolint: funlen
+func TestAdminVendorConfig(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	key := "secret"
+
+	cfg := config.SnappVendor()
+	cfg.StaticClients = []config.StaticClient{{Username: "bridge", Password: "$2a$10$hash", Topics: nil}}
+	cfg.SigningSecret = "response-secret"
+
+	features := flags.New(zap.NewNop())
+	features.Load(map[string]bool{flags.EmitClientAttrs: true}, map[string]map[string]bool{"snapp": nil})
+
+	// nolint: exhaustruct
+	a := api.API{
+		Authenticators: map[string]authenticator.Authenticator{
+			"snapp-admin": authenticator.AdminAuthenticator{
+				Key:     []byte(key),
+				Company: "snapp-admin",
+				JwtConfig: config.JWT{
+					IssName:       "iss",
+					SubName:       "sub",
+					SigningMethod: "HS512",
+				},
+				Parser: jwt.NewParser(),
+			},
+		},
+		Logger:  zap.NewNop(),
+		Metrics: metric.NewAPIMetrics(prometheus.DefaultRegisterer),
+		Flags:   features,
+		Configs: api.NewVendorConfigs([]config.Vendor{cfg}),
+	}
+
+	app := fiber.New()
+	app.Get("/v2/admin/vendors/:name", a.AdminAuth, a.AdminVendorConfig)
+
+	admin, err := getSampleToken(key)
+	require.NoError(err)
+
+	vendor := func(name string) (int, string) {
+		req := httptest.NewRequest(http.MethodGet, "/v2/admin/vendors/"+name, nil)
+		req.Header.Add("Authorization", "Bearer "+admin)
+
+		resp := send(t, app, req)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(err)
+
+		return resp.StatusCode, string(body)
+	}
+
+	status, body := vendor("snapp")
+	require.Equal(http.StatusOK, status)
+
+	var response api.AdminVendorConfigResponse
+
+	require.NoError(json.Unmarshal([]byte(body), &response))
+	require.Equal(uint64(0), response.ConfigGeneration)
+	require.Equal(map[string]bool{
+		flags.EmitClientAttrs:     true,
+		flags.EmitTopicType:       false,
+		flags.EmitExpireAt:        false,
+		flags.LenientTokenParsing: false,
+		flags.AuthFailureStatus:   false,
+		flags.NormalizeTopics:     false,
+	}, response.Features)
+	require.Equal("snapp", response.Company)
+	require.Len(response.Topics, len(cfg.Topics))
+	require.Equal(cfg.Topics[0].Template, response.Topics[0].Template)
+	require.Equal(cfg.IssEntityMap, response.IssEntityMap)
+
+	for iss, value := range response.Keys {
+		require.Equal(config.RedactedValue, value, iss)
+	}
+
+	for iss, data := range response.HashIDMap {
+		require.Equal(config.RedactedValue, data.Salt, iss)
+	}
+
+	require.Equal(config.RedactedValue, response.StaticClients[0].Password)
+
+	for _, secret := range []string{cfg.Keys[topics.DriverIss], cfg.HashIDMap[topics.DriverIss].Salt, "$2a$10$hash", "response-secret"} {
+		require.NotContains(body, secret)
+	}
+
+	// swaps replace the served configuration as a whole.
+	swapped := cfg
+	swapped.AllowedAccessTypes = []string{"sub"}
+
+	a.Configs.Swap([]config.Vendor{swapped})
+
+	_, body = vendor("snapp")
+	require.NoError(json.Unmarshal([]byte(body), &response))
+	require.Equal(uint64(1), response.ConfigGeneration)
+	require.Equal([]string{"sub"}, response.AllowedAccessTypes)
+
+	status, _ = vendor("unknown")
+	require.Equal(http.StatusNotFound, status)
+}
+
+// nolint: funlen
+func TestAdminVendorCaches(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	cfg := config.SnappVendor()
+
+	// vendors have the same topics, so the same client id and topic can be checked by both of them.
+	topicList := []topics.Topic{{ // nolint: exhaustruct
+		Type:     "shared",
+		Template: "^shared/{{.sub}}$",
+		Accesses: map[string]acl.AccessType{topics.DriverIss: acl.Pub},
+	}}
+
+	keys := map[string][]byte{"snapp": []byte("snapp-secret"), "tapsi": []byte("tapsi-secret")}
+	auths := make([]authenticator.Authenticator, 0, len(keys))
+
+	for company, key := range keys {
+		vendor := cfg
+		vendor.Company = company
+		vendor.Topics = topicList
+
+		auths = append(auths, manualAuthenticator(t, key, vendor))
+	}
+
+	a := newTestAPI(auths...)
+	a.VendorResolution = []string{"snapp"}
+	a.Sessions = session.New[api.SessionDecision](session.Config{TTL: time.Minute, MaxEntries: 0})
+
+	app := fiber.New()
+	app.Post("/v2/acl", a.ACLv2)
+	app.Delete("/v2/admin/vendors/:name/caches", a.AdminVendorCaches)
+
+	check := func(company string, key []byte) string {
+		token, err := testutil.DriverToken(jwt.SigningMethodHS512, key)
+		require.NoError(err)
+
+		// nolint: exhaustruct
+		resp := postJSON(t, app, "/v2/acl", api.ACLRequest{
+			Username: company + api.VendorTokenSeparator + token,
+			Topic:    "shared/" + testutil.DefaultSubject,
+			Action:   "publish",
+			ClientID: "client",
+		})
+
+		return decode[api.ACLResponse](t, resp).Result
+	}
+
+	flush := func(company string) (int, api.AdminVendorCachesResponse) {
+		resp := send(t, app, httptest.NewRequest(http.MethodDelete, "/v2/admin/vendors/"+company+"/caches", nil))
+
+		var result api.AdminVendorCachesResponse
+
+		if resp.StatusCode == http.StatusOK {
+			result = decode[api.AdminVendorCachesResponse](t, resp)
+		}
+
+		return resp.StatusCode, result
+	}
+
+	invalid := []byte("invalid")
+
+	require.Equal("allow", check("snapp", keys["snapp"]))
+
+	// decision of the same client id and topic in snapp is not used for tapsi.
+	require.Equal("deny", check("tapsi", invalid))
+	require.Equal("allow", check("tapsi", keys["tapsi"]))
+
+	status, result := flush("tapsi")
+	require.Equal(http.StatusOK, status)
+	require.Equal(api.AdminVendorCachesResponse{Company: "tapsi", Sessions: 1}, result)
+
+	require.Equal("deny", check("tapsi", invalid))
+
+	// decision of snapp is kept.
+	status, result = flush("snapp")
+	require.Equal(http.StatusOK, status)
+	require.Equal(api.AdminVendorCachesResponse{Company: "snapp", Sessions: 1}, result)
+
+	status, _ = flush("unknown")
+	require.Equal(http.StatusNotFound, status)
+}
+
+// nolint: funlen
+func TestAdminVendorMaintenance(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	cfg := config.SnappVendor()
+
+	a := newTestAPI(manualAuthenticator(t, []byte("secret"), cfg))
+	a.Sessions = session.New[api.SessionDecision](session.Config{TTL: time.Minute, MaxEntries: 0})
+	a.Maintenances = api.NewMaintenances()
+
+	app := fiber.New()
+	app.Post("/v2/auth", a.Authv2)
+	app.Post("/v2/acl", a.ACLv2)
+	app.Put("/v2/admin/vendors/:name/maintenance", a.AdminVendorMaintenance)
+	app.Delete("/v2/admin/vendors/:name/maintenance", a.AdminVendorMaintenanceEnd)
+
+	// identity provider rotated its key, so the tokens are not verified by the configured key.
+	token, err := testutil.DriverToken(jwt.SigningMethodHS512, []byte("rotated"))
+	require.NoError(err)
+
+	expired, err := testutil.Token(jwt.SigningMethodHS512, []byte("rotated"), testutil.Claims{
+		Issuer:       topics.DriverIss,
+		Subject:      testutil.DefaultSubject,
+		ExpiresIn:    -time.Minute,
+		NoExpiration: false,
+		Extra:        nil,
+		Kid:          "",
+	})
+	require.NoError(err)
+
+	auth := func(token string) string {
+		// nolint: exhaustruct
+		resp := postJSON(t, app, "/v2/auth", api.AuthRequest{Username: token, ClientID: "client"})
+
+		return decode[api.AuthResponse](t, resp).Result
+	}
+
+	check := func() string {
+		// nolint: exhaustruct
+		resp := postJSON(t, app, "/v2/acl", api.ACLRequest{
+			Username: token,
+			Topic:    "snapp/driver/" + testutil.DefaultSubject + "/location",
+			Action:   "publish",
+			ClientID: "client",
+		})
+
+		return decode[api.ACLResponse](t, resp).Result
+	}
+
+	maintain := func(company string, window api.Maintenance) int {
+		req := jsonRequest(t, http.MethodPut, "/v2/admin/vendors/"+company+"/maintenance", window)
+
+		return send(t, app, req).StatusCode
+	}
+
+	end := func() int {
+		return send(t, app, httptest.NewRequest(http.MethodDelete, "/v2/admin/vendors/snapp/maintenance", nil)).StatusCode
+	}
+
+	require.Equal("deny", auth(token))
+	require.Equal("deny", check())
+
+	// maintenance cannot be started without an expiry in the future.
+	require.Equal(http.StatusBadRequest, maintain("snapp", api.Maintenance{ExpiresAt: time.Time{}, Reason: ""}))
+	require.Equal(http.StatusBadRequest, maintain("snapp", api.Maintenance{
+		ExpiresAt: time.Now().Add(-time.Minute),
+		Reason:    "",
+	}))
+	require.Equal(http.StatusNotFound, maintain("unknown", api.Maintenance{
+		ExpiresAt: time.Now().Add(time.Hour),
+		Reason:    "",
+	}))
+
+	require.Equal(http.StatusOK, maintain("snapp", api.Maintenance{
+		ExpiresAt: time.Now().Add(time.Hour),
+		Reason:    "identity provider upgrade",
+	}))
+	require.Equal("allow", auth(token))
+	require.Equal("allow", check())
+
+	// expired tokens are rejected in maintenance too.
+	require.Equal("deny", auth(expired))
+
+	// decisions of maintenance are not memoized, so they end with the maintenance.
+	require.Equal(http.StatusOK, end())
+	require.Equal(http.StatusNotFound, end())
+	require.Equal("deny", check())
+
+	// maintenance ends by itself on its expiry.
+	require.Equal(http.StatusOK, maintain("snapp", api.Maintenance{
+		ExpiresAt: time.Now().Add(100 * time.Millisecond),
+		Reason:    "",
+	}))
+	require.Equal("allow", auth(token))
+	require.Eventually(func() bool { return auth(token) == "deny" }, time.Second, 10*time.Millisecond)
+}
+
+// nolint: funlen
+func TestAdminRecentDecisions(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	cfg := config.SnappVendor()
+
+	cfg.Topics = []topics.Topic{{ // nolint: exhaustruct
+		Type:     "shared",
+		Template: "^shared/{{.sub}}$",
+		Accesses: map[string]acl.AccessType{topics.DriverIss: acl.Pub},
+	}}
+
+	key := []byte("snapp-secret")
+
+	a := newTestAPI(manualAuthenticator(t, key, cfg))
+	a.Decisions = audit.New(audit.Config{Size: 10})
+
+	app := fiber.New()
+	app.Post("/v2/acl", a.ACLv2)
+	app.Get("/v2/admin/recent-decisions", a.AdminRecentDecisions)
+
+	token, err := testutil.DriverToken(jwt.SigningMethodHS512, key)
+	require.NoError(err)
+
+	check := func(action string) {
+		// nolint: exhaustruct
+		postJSON(t, app, "/v2/acl", api.ACLRequest{
+			Token:  token,
+			Topic:  "shared/" + testutil.DefaultSubject,
+			Action: action,
+		})
+	}
+
+	list := func(query string) (int, []byte) {
+		resp := send(t, app, httptest.NewRequest(http.MethodGet, "/v2/admin/recent-decisions?"+query, nil))
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(err)
+
+		return resp.StatusCode, body
+	}
+
+	check("publish")
+	check("subscribe")
+
+	status, body := list("result=deny&topic_type=shared&limit=100")
+	require.Equal(http.StatusOK, status)
+
+	// entries never have the token or subject of request.
+	require.NotContains(string(body), token)
+	require.NotContains(string(body), testutil.DefaultSubject)
+
+	var entries []audit.Entry
+
+	require.NoError(json.Unmarshal(body, &entries))
+	require.Len(entries, 1)
+	require.Equal("snapp", entries[0].Vendor)
+	require.Equal("subscribe", entries[0].Access)
+	require.Equal("shared", entries[0].TopicType)
+	require.Equal(audit.ResultDeny, entries[0].Result)
+	require.Equal(authenticator.ReasonPublishOnly, entries[0].Reason)
+	require.NotEmpty(entries[0].SubHash)
+
+	// decisions of a subject are found by its hash.
+	status, body = list("sub=" + testutil.DefaultSubject)
+	require.Equal(http.StatusOK, status)
+	require.NoError(json.Unmarshal(body, &entries))
+	require.Len(entries, 2)
+
+	status, body = list("sub=DXKgaNQa7N5Y7bp")
+	require.Equal(http.StatusOK, status)
+	require.NoError(json.Unmarshal(body, &entries))
+	require.Empty(entries)
+
+	status, body = list("")
+	require.Equal(http.StatusOK, status)
+	require.NoError(json.Unmarshal(body, &entries))
+	require.Len(entries, 2)
+	require.Equal(audit.ResultAllow, entries[1].Result)
+	require.Empty(entries[1].Reason)
+
+	status, _ = list("result=maybe")
+	require.Equal(http.StatusBadRequest, status)
+
+	status, _ = list("limit=-1")
+	require.Equal(http.StatusBadRequest, status)
+}
@@ -0,0 +1,252 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"go.uber.org/zap"
+)
+
+var ErrInvalidAnonymousPolicy = errors.New("anonymous policy should have an entity")
+
+// AnonymousPolicy allows the clients of a vendor with empty credentials on its listeners or mountpoints.
+type AnonymousPolicy struct {
+	Company     string
+	Listeners   []string
+	Mountpoints []string
+	// Client authorizes the topics of anonymous clients, its username is the pseudo entity.
+	Client authenticator.StaticClient
+}
+
+// NewAnonymousPolicies returns the anonymous policies of vendors which allow anonymous clients
+// in the order of vendors.
+func NewAnonymousPolicies(vendors []config.Vendor) ([]AnonymousPolicy, error) {
+	policies := make([]AnonymousPolicy, 0)
+
+	for _, vendor := range vendors {
+		anonymous := vendor.AllowAnonymous
+		if anonymous == nil {
+			continue
+		}
+
+		if anonymous.Entity == "" {
+			return nil, fmt.Errorf("vendor %s: %w", vendor.Company, ErrInvalidAnonymousPolicy)
+		}
+
+		list := make([]authenticator.StaticTopic, 0, len(anonymous.Topics))
+
+		for _, topic := range anonymous.Topics {
+			if !topic.Access.IsValid() {
+				return nil, authenticator.InvalidTopicAccessError{
+					TopicType: topic.Pattern,
+					Issuer:    anonymous.Entity,
+					Access:    topic.Access,
+				}
+			}
+
+			list = append(list, authenticator.StaticTopic{Pattern: topic.Pattern, Access: topic.Access})
+		}
+
+		policies = append(policies, AnonymousPolicy{
+			Company:     vendor.Company,
+			Listeners:   anonymous.Listeners,
+			Mountpoints: anonymous.Mountpoints,
+			Client: authenticator.StaticClient{
+				Username:     anonymous.Entity,
+				PasswordHash: nil,
+				Topics:       list,
+			},
+		})
+	}
+
+	return policies, nil
+}
+
+// Allows checks the policy applies to the listener or mountpoint of request.
+func (p AnonymousPolicy) Allows(listener, mountpoint string) bool {
+	if len(p.Listeners) == 0 && len(p.Mountpoints) == 0 {
+		return true
+	}
+
+	return (listener != "" && slices.Contains(p.Listeners, listener)) ||
+		(mountpoint != "" && slices.Contains(p.Mountpoints, mountpoint))
+}
+
+// anonymousPolicy returns the first anonymous policy which applies to the listener or mountpoint of request.
+func (a API) anonymousPolicy(listener, mountpoint string) (AnonymousPolicy, bool) {
+	for _, policy := range a.AnonymousPolicies {
+		if policy.Allows(listener, mountpoint) {
+			return policy, true
+		}
+	}
+
+	return AnonymousPolicy{}, false
+}
+
+// anonymousAuth authenticates the client with empty credentials using the anonymous policies,
+// company is the vendor of request which is used when none of the policies applies.
+func (a API) anonymousAuth(c *fiber.Ctx, company string, request *AuthRequest, source string) error {
+	clientIP := ClientIP(c, request.IPAddress, request.PeerHost)
+
+	logger := a.Logger.With(
+		zap.String("client-id", request.ClientID),
+		zap.String("source", source),
+		zap.String("listener", request.Listener),
+		zap.String("mountpoint", request.Mountpoint),
+		zap.String("auth-method", "anonymous"),
+		zap.String("client-ip", formatIP(clientIP)),
+	)
+
+	policy, ok := a.anonymousPolicy(request.Listener, request.Mountpoint)
+	if !ok {
+		a.Metrics.AnonymousAuth(company, source, authenticator.ErrEmptyCredentials)
+		c.Locals(vendorLocal, company)
+
+		logger.Warn("anonymous auth request is not allowed",
+			zap.Error(authenticator.ErrEmptyCredentials),
+			zap.String("authenticator", company),
+		)
+
+		return a.authDenied(c, company, CodeEmptyCredentials)
+	}
+
+	c.Locals(vendorLocal, policy.Company)
+
+	logger = logger.With(
+		zap.String("authenticator", policy.Company),
+		zap.String("entity", policy.Client.Username),
+	)
+
+	var (
+		err   error
+		rules []ACLRule
+	)
+
+	if !a.IPFilters[policy.Company].Allowed(clientIP) {
+		err = fmt.Errorf("client address %q is not allowed: %w", formatIP(clientIP), authenticator.ErrInvalidIP)
+	}
+
+	if err == nil && request.WillTopic != "" {
+		if _, aclErr := policy.Client.ACL(acl.Pub, request.WillTopic); aclErr != nil {
+			aclErr = willError(request.WillTopic, aclErr)
+
+			if a.willDenies() {
+				err = aclErr
+			} else {
+				rules = a.willRules(request.WillTopic)
+
+				logger.Warn("anonymous auth request will topic is not authorized but connection is accepted",
+					zap.Error(aclErr),
+					zap.Bool("will-stripped", rules != nil),
+				)
+			}
+		}
+	}
+
+	a.Metrics.AnonymousAuth(policy.Company, source, err)
+
+	if err != nil {
+		logger.Warn("anonymous auth request is not authorized", zap.Error(err))
+
+		return a.authDenied(c, policy.Company, CodeAccessDenied)
+	}
+
+	logger.Info("anonymous auth ok")
+
+	return c.Status(http.StatusOK).JSON(AuthResponse{
+		Result:      "allow",
+		IsSuperuser: false,
+		ExpireAt:    0,
+		ClientAttrs: &authenticator.ClientAttrs{
+			Entity: policy.Client.Username,
+			HashID: "",
+			Vendor: policy.Company,
+		},
+		Code: "",
+		ACL:  rules,
+	})
+}
+
+// anonymousACL authorizes the client with empty credentials using the topic patterns of anonymous policy,
+// company is the vendor of request which is used when none of the policies applies.
+func (a API) anonymousACL(c *fiber.Ctx, company string, request *ACLRequest) error {
+	logger := a.Logger.With(
+		zap.String("access", request.Action),
+		zap.String("topic", request.Topic),
+		zap.String("listener", request.Listener),
+		zap.String("mountpoint", request.Mountpoint),
+		zap.String("auth-method", "anonymous"),
+		zap.String("client-ip", formatIP(ClientIP(c, request.IPAddress, request.PeerHost))),
+	)
+
+	policy, ok := a.anonymousPolicy(request.Listener, request.Mountpoint)
+	if !ok {
+		a.Metrics.AnonymousACL(company, authenticator.ErrEmptyCredentials)
+		c.Locals(vendorLocal, company)
+
+		logger.Warn("anonymous acl request is not allowed",
+			zap.Error(authenticator.ErrEmptyCredentials),
+			zap.String("authenticator", company),
+		)
+
+		return c.Status(http.StatusOK).JSON(ACLResponse{
+			Result:          "deny",
+			Reason:          authenticator.ReasonEmptyCredentials,
+			GrantedAccesses: nil,
+			TopicAttrs:      nil,
+		})
+	}
+
+	c.Locals(vendorLocal, policy.Company)
+
+	logger = logger.With(
+		zap.String("authenticator", policy.Company),
+		zap.String("entity", policy.Client.Username),
+	)
+
+	topic, err := a.stripMountpoint(policy.Company, request.Mountpoint, request.Topic)
+	if err == nil {
+		err = topics.Sanitize(topic, a.MaxTopicLength)
+	}
+
+	if err == nil {
+		_, err = policy.Client.ACL(topicAccess(request.Action), topic)
+	}
+
+	a.Metrics.AnonymousACL(policy.Company, err)
+
+	if err != nil {
+		logger.Warn("anonymous acl request is not authorized", zap.Error(err))
+
+		var tnaErr authenticator.TopicNotAllowedError
+		if errors.As(err, &tnaErr) {
+			return c.Status(http.StatusOK).JSON(topicNotAllowed(tnaErr))
+		}
+
+		reason := ""
+		if errors.Is(err, authenticator.ErrUnexpectedMountpoint) {
+			reason = authenticator.ReasonUnexpectedMountpoint
+		}
+
+		return c.Status(http.StatusOK).JSON(ACLResponse{
+			Result:          "deny",
+			Reason:          reason,
+			GrantedAccesses: nil,
+			TopicAttrs:      nil,
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(ACLResponse{
+		Result:          "allow",
+		Reason:          "",
+		GrantedAccesses: nil,
+		TopicAttrs:      nil,
+	})
+}
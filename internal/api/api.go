@@ -2,14 +2,26 @@ package api
 
 import (
 	"strings"
+	"time"
 
 	"github.com/ansrivas/fiberprometheus/v2"
 	"github.com/gofiber/contrib/fiberzap"
 	"github.com/gofiber/fiber/v2"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/audit"
 	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/budget"
 	"github.com/snapp-incubator/soteria/internal/clientid"
+	"github.com/snapp-incubator/soteria/internal/flags"
+	"github.com/snapp-incubator/soteria/internal/invalidtopic"
+	"github.com/snapp-incubator/soteria/internal/ipfilter"
+	"github.com/snapp-incubator/soteria/internal/limiter"
 	"github.com/snapp-incubator/soteria/internal/metric"
+	"github.com/snapp-incubator/soteria/internal/replay"
+	"github.com/snapp-incubator/soteria/internal/reuse"
+	"github.com/snapp-incubator/soteria/internal/server"
+	"github.com/snapp-incubator/soteria/internal/session"
+	"github.com/snapp-incubator/soteria/pkg/signature"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
@@ -18,11 +30,69 @@ const VendorTokenSeparator = ":"
 
 type API struct {
 	Authenticators map[string]authenticator.Authenticator
-	DefaultVendor  string
-	Tracer         trace.Tracer
-	Parser         *clientid.Parser
-	Logger         *zap.Logger
-	Metrics        *metric.APIMetrics
+	// VendorResolution is the ordered vendors which handle the tokens without vendor,
+	// the first vendor which knows the token issuer handles it.
+	VendorResolution []string
+	// ParallelResolution authenticates the tokens by all of the vendors of resolution which know their issuer
+	// in parallel and the first definitive answer wins.
+	ParallelResolution bool
+	// Winners keep the vendors which allowed the tokens in parallel resolution for their acl requests.
+	Winners *Winners
+	Tracer  trace.Tracer
+	Parser  *clientid.Parser
+	Logger  *zap.Logger
+	Metrics *metric.APIMetrics
+	// WillTopicPolicy is the behaviour on disallowed will topics, it is deny by default.
+	WillTopicPolicy string
+	Keys            *authenticator.KeyRegistry
+	States          *VendorStates
+	// Budget answers requests with default decisions when they exceed their latency budget.
+	Budget budget.Config
+	Flags  *flags.Flags
+	// MaxTopicLength is the maximum length of topics in bytes, zero disables the length check.
+	MaxTopicLength int
+	// MaxCredentialLength is the maximum length of tokens in bytes, zero disables the length check.
+	MaxCredentialLength int
+	// Signers sign the responses of vendors, vendors without signer have unsigned responses.
+	Signers map[string]*signature.Signer
+	// IPFilters filter the client addresses of vendors on authentication, vendors without filter allow every address.
+	IPFilters map[string]*ipfilter.Filter
+	// Limiters cap the in-flight auth and acl requests, nil limiters don't limit.
+	Limiters limiter.Limiters
+	// Mountpoints are the allowed mountpoints of vendors, requests with other mountpoints are denied.
+	Mountpoints map[string][]string
+	// TokenSources are the MQTT fields which have the tokens of vendors, vendors without token source
+	// take the username and then the password.
+	TokenSources map[string]string
+	// AnonymousPolicies allow the clients with empty credentials, the first policy which applies
+	// to the listener or mountpoint of request is used and clients are denied without them.
+	AnonymousPolicies []AnonymousPolicy
+	// Sessions memoize the ACL decisions by client id, nil sessions don't memoize anything.
+	Sessions *session.Cache[SessionDecision]
+	// Configs are the redacted configuration of vendors which is returned by admin API.
+	Configs *VendorConfigs
+	// Recorder captures the sampled topic decisions for replay, nil recorder doesn't capture anything.
+	Recorder *replay.Recorder
+	// Decisions keep the recent ACL decisions for admin API, nil decisions don't keep anything.
+	Decisions *audit.Buffer
+	// Maintenances are the maintenance windows of vendors, tokens of vendors in maintenance are not verified.
+	Maintenances *Maintenances
+	// TokenReuse detects the tokens of vendors which are authenticated from many client addresses,
+	// vendors without detector don't detect anything.
+	TokenReuse map[string]*reuse.Detector
+	// CacheHints are the ttl of EMQ acl cache for the decisions of vendors, vendors without hint use acl_cache_ttl.
+	CacheHints map[string]CacheHint
+	// MaxSessions are the maximum session durations of vendors which clamp their expire_at,
+	// vendors without maximum session use the expiry of tokens.
+	MaxSessions map[string]time.Duration
+	// LegacyRoutes mounts the v1 routes of the old EMQ clusters.
+	LegacyRoutes bool
+	// Readiness has the failures of startup self-checks, nil readiness is always ready.
+	Readiness *Readiness
+	// InvalidTopics tracks the rate of invalid topic denials, it is optional.
+	InvalidTopics *invalidtopic.Tracker
+	// Server tunes the REST server, its zero value keeps the defaults of fiber.
+	Server server.Config
 }
 
 // MetricLogSkipper check if route is equal "metric" disable log.
@@ -34,46 +104,60 @@ func MetricLogSkipper(ctx *fiber.Ctx) bool {
 
 // ReSTServer will return fiber app.
 func (a API) ReSTServer() *fiber.App {
-	app := fiber.New()
+	app := fiber.New(FiberConfig(a.Server))
+
+	app.Use(a.RequestID)
+	app.Use(Compress(a.Server.Compression))
 
 	//nolint: exhaustruct
 	app.Use(fiberzap.New(fiberzap.Config{
 		Next:   MetricLogSkipper,
 		Logger: a.Logger.Named("fiber"),
+		Fields: []string{"latency", "status", "method", "url", "requestId"},
 	}))
 
 	prometheus := fiberprometheus.NewWithRegistry(prometheus.DefaultRegisterer, "http", "platform", "soteria", nil)
 	prometheus.RegisterAt(app, "/metrics")
 	app.Use(prometheus.Middleware)
 
-	app.Post("/v2/auth", a.Authv2)
-	app.Post("/v2/acl", a.ACLv2)
+	broker := BodyLimit(a.Server.BodyLimits.Broker)
 
-	return app
-}
+	app.Post("/v2/auth", broker, a.Limit(a.Limiters.Auth), a.SignResponse, a.Authv2)
+	app.Post("/v2/acl", broker, a.Limit(a.Limiters.ACL), a.SignResponse, a.CacheHint, a.ACLv2)
+	app.Get("/v2/about", a.About)
+	app.Get("/v2/ready", a.Ready)
+	a.Legacy(app)
 
-func (a API) Authenticator(vendor string) authenticator.Authenticator {
-	auth, ok := a.Authenticators[vendor]
+	bodyLimit := BodyLimit(a.Server.BodyLimits.Admin)
 
-	if ok {
-		return auth
-	}
+	admin := app.Group("/v2/admin", bodyLimit, a.AdminAuth)
+	admin.Get("/keys", a.AdminKeys)
+	admin.Get("/vendors", a.AdminVendors)
+	admin.Get("/vendors/:name", a.AdminVendorConfig)
+	admin.Put("/vendors/:name/state", a.AdminVendorState)
+	admin.Delete("/vendors/:name/caches", a.AdminVendorCaches)
+	admin.Put("/vendors/:name/maintenance", a.AdminVendorMaintenance)
+	admin.Delete("/vendors/:name/maintenance", a.AdminVendorMaintenanceEnd)
+	admin.Get("/flags", a.AdminFlags)
+	admin.Get("/recent-decisions", a.AdminRecentDecisions)
+
+	debug := app.Group("/v2/debug", bodyLimit, a.AdminAuth)
+	debug.Post("/permissions", a.DebugPermissions)
 
-	return a.Authenticators[a.DefaultVendor]
+	return app
 }
 
+// ExtractVendorToken returns the vendor and token of request, the token is taken from the token field,
+// then the username and then the password.
 func ExtractVendorToken(rawToken, username, password string) (string, string) {
-	tokenString := rawToken
-
-	if len(tokenString) == 0 {
-		tokenString = username
-	}
+	raw, _ := SelectToken("", rawToken, username, password)
 
-	if len(tokenString) == 0 {
-		tokenString = password
-	}
+	return splitVendorToken(raw)
+}
 
-	split := strings.Split(tokenString, VendorTokenSeparator)
+// splitVendorToken splits the vendor from the token when token is prefixed by its vendor.
+func splitVendorToken(raw string) (string, string) {
+	split := strings.Split(raw, VendorTokenSeparator)
 
 	var vendor, token string
 
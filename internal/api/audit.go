@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/snapp-incubator/soteria/internal/audit"
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/metric"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+)
+
+// ReasonBudgetExceeded is the audit reason of decisions which are answered by the default decision of budget.
+const ReasonBudgetExceeded = "budget_exceeded"
+
+// audit keeps the ACL decision of authenticator in the recent decisions, the subject which ACL recorded on
+// the context is only kept as its hash. Reason of denials is the reason of their response when it is not given,
+// and their metric status when their response has no reason.
+func (a API) audit(
+	ctx context.Context,
+	auth authenticator.Authenticator,
+	access acl.AccessType,
+	topicType, result, reason string,
+	start time.Time,
+	err error,
+) {
+	if a.Decisions == nil {
+		return
+	}
+
+	if result == audit.ResultDeny && reason == "" && err != nil {
+		var reasoned interface{ Reason() string }
+
+		if errors.As(err, &reasoned) {
+			reason = reasoned.Reason()
+		} else {
+			reason = metric.Status(err)
+		}
+	}
+
+	var subHash string
+
+	if subject, ok := authenticator.RecordedSubject(ctx); ok {
+		subHash = a.Decisions.SubHash(subject.Sub)
+	}
+
+	a.Decisions.Add(audit.Entry{
+		Time:      start,
+		Vendor:    auth.GetCompany(),
+		Access:    access.String(),
+		TopicType: topicType,
+		Result:    result,
+		Reason:    reason,
+		SubHash:   subHash,
+		LatencyMS: float64(time.Since(start)) / float64(time.Millisecond),
+	})
+}
@@ -1,27 +1,92 @@
 package api
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/budget"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/acl"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
+const (
+	WillTopicPolicyDeny  = config.WillTopicPolicyDeny
+	WillTopicPolicyLog   = config.WillTopicPolicyLog
+	WillTopicPolicyStrip = config.WillTopicPolicyStrip
+)
+
+// ErrWillTopicNotAllowed is the error of connections which their will topic is not allowed.
+var ErrWillTopicNotAllowed = errors.New("will topic is not allowed")
+
 // AuthRequest is the body payload structure of the auth endpoint.
 type AuthRequest struct {
 	Token    string `json:"token,omitempty"`
 	Username string `json:"username,omitempty"`
 	Password string `json:"password,omitempty"`
 	ClientID string `json:"client_id,omitempty"`
+	// WillTopic is the last will topic which client sets on connect, it is checked same as a publish.
+	WillTopic string `json:"will_topic,omitempty"`
+	WillQoS   int    `json:"will_qos,omitempty"`
+	// IPAddress and PeerHost are the address of MQTT client which EMQ includes in its requests.
+	IPAddress string `json:"ipaddress,omitempty"`
+	PeerHost  string `json:"peerhost,omitempty"`
+	// Listener and Mountpoint are the EMQ listener of client and its mountpoint, they scope the anonymous policies.
+	Listener   string `json:"listener,omitempty"`
+	Mountpoint string `json:"mountpoint,omitempty"`
 }
 
 type AuthResponse struct {
 	Result      string `json:"result,omitempty"`
 	IsSuperuser bool   `json:"is_superuser,omitempty"`
 	ExpireAt    int64  `json:"expire_at,omitempty"`
+	// ClientAttrs are attached to the client session by EMQ, they are set only for vendors which emit them.
+	ClientAttrs *authenticator.ClientAttrs `json:"client_attrs,omitempty"`
+	// Code is the code of failed authentications, e.g. TOKEN_EXPIRED, INVALID_TOKEN or ACCESS_DENIED.
+	Code string `json:"code,omitempty"`
+	// ACL are the ACL rules of client which EMQ checks before the ACL requests, they only strip the
+	// disallowed will topics.
+	ACL []ACLRule `json:"acl,omitempty"`
+}
+
+// ACLRule is an ACL rule of the auth response of EMQ.
+type ACLRule struct {
+	Permission string `json:"permission"`
+	Action     string `json:"action"`
+	Topic      string `json:"topic"`
+}
+
+// willDenies reports whether a disallowed will topic denies its connection, which is the default policy.
+func (a API) willDenies() bool {
+	return a.WillTopicPolicy != WillTopicPolicyLog && a.WillTopicPolicy != WillTopicPolicyStrip
+}
+
+// willRules returns the ACL rules of auth response which deny the publish of the disallowed will topic
+// when will topics are stripped. Topic is matched exactly, so its placeholders are not replaced by EMQ.
+func (a API) willRules(topic string) []ACLRule {
+	if a.WillTopicPolicy != WillTopicPolicyStrip {
+		return nil
+	}
+
+	return []ACLRule{{Permission: "deny", Action: "publish", Topic: "eq " + topic}}
+}
+
+// willError returns the error of disallowed will topic, err is the error of its ACL when it has one.
+func willError(topic string, err error) error {
+	if err == nil {
+		return fmt.Errorf("%w: %s", ErrWillTopicNotAllowed, topic)
+	}
+
+	return fmt.Errorf("%w: %s: %w", ErrWillTopicNotAllowed, topic, err)
 }
 
 // Auth is the handler responsible for authentication.
@@ -29,9 +94,12 @@ type AuthResponse struct {
 // https://www.emqx.io/docs/en/latest/access-control/authn/http.html
 // nolint: funlen
 func (a API) Authv2(c *fiber.Ctx) error {
-	ctx, span := a.Tracer.Start(c.Context(), "api.v2.auth")
+	traceCtx, span := a.Tracer.Start(traceContext(c), "api.v2.auth", trace.WithSpanKind(trace.SpanKindServer))
 	defer span.End()
 
+	// a is a copy, so the handler and its helpers use the request-scoped logger.
+	a = a.correlate(c, span)
+
 	request := new(AuthRequest)
 
 	if err := c.BodyParser(request); err != nil {
@@ -47,15 +115,88 @@ func (a API) Authv2(c *fiber.Ctx) error {
 			Result:      "deny",
 			IsSuperuser: false,
 			ExpireAt:    0,
+			ClientAttrs: nil,
+			Code:        "",
+			ACL:         nil,
 		})
 	}
 
-	vendor, token := ExtractVendorToken(request.Token, request.Username, request.Password)
+	auth, token, resolution, resolveErr := a.credentials("auth", request.Token, request.Username, request.Password)
+	c.Locals(vendorLocal, auth.GetCompany())
 
-	auth := a.Authenticator(vendor)
+	// a new connection can have a new token, so decisions of the previous session are not used.
+	a.Sessions.Invalidate(auth.GetCompany(), request.ClientID)
 
 	source := a.Parser.Parse(request.ClientID)
 
+	// empty credentials never reach the token parsing, they are allowed only by the anonymous policies.
+	if token == "" {
+		return a.anonymousAuth(c, auth.GetCompany(), request, source)
+	}
+
+	token = a.normalizeCredential(auth, "auth", request.Token, request.Username, token)
+
+	if err := a.checkCredential(auth, "auth", request.Token, request.Username, token); err != nil {
+		span.RecordError(err)
+		a.Metrics.AuthFailed(auth.GetCompany(), source, err)
+
+		return a.authDenied(c, auth.GetCompany(), CodeMalformedCredential)
+	}
+
+	clientIP := ClientIP(c, request.IPAddress, request.PeerHost)
+
+	if resolveErr != nil {
+		span.RecordError(resolveErr)
+		a.Metrics.AuthFailed(auth.GetCompany(), source, resolveErr)
+
+		a.Logger.
+			Warn("auth request issuer is not known",
+				zap.Error(resolveErr),
+				zap.Strings("resolution", a.VendorResolution),
+				zap.String("client-id", request.ClientID),
+				zap.String("client-ip", formatIP(clientIP)),
+			)
+
+		return a.authDenied(c, auth.GetCompany(), CodeUnknownIssuer)
+	}
+
+	if state := a.States.Get(auth.GetCompany()); state.State == VendorStateDisabled {
+		a.Metrics.VendorDisabled(auth.GetCompany(), "auth", state.Policy)
+
+		return c.Status(http.StatusOK).JSON(AuthResponse{
+			Result:      state.Policy,
+			IsSuperuser: false,
+			ExpireAt:    0,
+			ClientAttrs: nil,
+			Code:        "",
+			ACL:         nil,
+		})
+	}
+
+	// a is a copy, so the maintenance only changes the handler of this request.
+	a, traceCtx = a.maintain(traceCtx, span, auth.GetCompany(), "auth")
+
+	if !a.IPFilters[auth.GetCompany()].Allowed(clientIP) {
+		err := fmt.Errorf("client address %q is not allowed: %w", formatIP(clientIP), authenticator.ErrInvalidIP)
+
+		span.RecordError(err)
+		a.Metrics.AuthFailed(auth.GetCompany(), source, err)
+
+		a.Logger.
+			Warn("auth request client address is not allowed",
+				zap.Error(err),
+				zap.String("authenticator", auth.GetCompany()),
+				zap.String("client-id", request.ClientID),
+				zap.String("client-ip", formatIP(clientIP)),
+			)
+
+		return a.authDenied(c, auth.GetCompany(), CodeAccessDenied)
+	}
+
+	if client, ok := staticClient(auth, request.Token, request.Username); ok {
+		return a.staticAuth(c, auth, client, request, source)
+	}
+
 	logger := a.Logger.With(
 		zap.String("token", request.Token),
 		zap.String("username", request.Username),
@@ -63,17 +204,58 @@ func (a API) Authv2(c *fiber.Ctx) error {
 		zap.String("authenticator", auth.GetCompany()),
 		zap.String("client-id", request.ClientID),
 		zap.String("source", source),
+		zap.String("will-topic", request.WillTopic),
+		zap.String("client-ip", formatIP(clientIP)),
 	)
 
 	span.SetAttributes(
 		attribute.String("authenticator", auth.GetCompany()),
+		attribute.String("client-ip", formatIP(clientIP)),
 		attribute.String("cliend-id", request.ClientID),
 		attribute.String("source", source),
 		attribute.String("username", request.Username),
 		attribute.String("password", request.Password),
 	)
 
-	if err := auth.Auth(ctx, token); err != nil {
+	// request context is reused by fiber after handler returns, so calls which may
+	// be abandoned because of budget use a detached context.
+	ctx, cancel := budget.Start(traceCtx, a.Budget.Auth.Deadline)
+	defer cancel()
+
+	var attrs *authenticator.ClientAttrs
+
+	candidates := a.candidates(auth, resolution, token, clientIP)
+	answered := auth
+
+	err := budget.Run(ctx, func(ctx context.Context) error {
+		var err error
+
+		answered, attrs, err = a.race(ctx, candidates, token)
+
+		return err
+	})
+	if exceeded := new(budget.ExceededError); errors.As(err, exceeded) {
+		decision := a.budgetExceeded(logger, auth.GetCompany(), "auth", a.Budget.Auth, *exceeded)
+
+		return c.Status(http.StatusOK).JSON(AuthResponse{
+			Result:      decision,
+			IsSuperuser: false,
+			ExpireAt:    0,
+			ClientAttrs: nil,
+			Code:        "",
+			ACL:         nil,
+		})
+	}
+
+	// the vendor which answered in the parallel resolution handles the rest of request.
+	if answered.GetCompany() != auth.GetCompany() {
+		auth = answered
+		logger = logger.With(zap.String("answered-authenticator", auth.GetCompany()))
+
+		c.Locals(vendorLocal, auth.GetCompany())
+	}
+
+	if err != nil {
 		span.RecordError(err)
 		a.Metrics.AuthFailed(auth.GetCompany(), source, err)
 
@@ -84,11 +266,72 @@ func (a API) Authv2(c *fiber.Ctx) error {
 				)
 		}
 
-		return c.Status(http.StatusOK).JSON(AuthResponse{
-			Result:      "deny",
-			IsSuperuser: false,
-			ExpireAt:    0,
-		})
+		return a.authDenied(c, auth.GetCompany(), tokenFailure(err))
+	}
+
+	if len(candidates) > 1 {
+		a.Winners.Set(token, auth.GetCompany(), sessionExpiry(auth, token))
+	}
+
+	a.observeReuse(auth, token, clientIP)
+
+	var rules []ACLRule
+
+	if request.WillTopic != "" {
+		var ok bool
+
+		err := topics.Sanitize(request.WillTopic, a.MaxTopicLength)
+		if err != nil {
+			a.malformedTopic(auth.GetCompany(), "auth", request.WillTopic, err)
+		} else {
+			err = budget.Run(ctx, func(ctx context.Context) error {
+				var err error
+
+				ok, err = auth.ACL(ctx, acl.Pub, token, request.WillTopic, 0)
+
+				return err
+			})
+		}
+
+		if exceeded := new(budget.ExceededError); errors.As(err, exceeded) {
+			decision := a.budgetExceeded(logger, auth.GetCompany(), "auth", a.Budget.Auth, *exceeded)
+
+			return c.Status(http.StatusOK).JSON(AuthResponse{
+				Result:      decision,
+				IsSuperuser: false,
+				ExpireAt:    0,
+				ClientAttrs: nil,
+				Code:        "",
+				ACL:         nil,
+			})
+		}
+
+		if err != nil || !ok {
+			err = willError(request.WillTopic, err)
+
+			span.RecordError(err)
+
+			if a.willDenies() {
+				a.Metrics.AuthFailed(auth.GetCompany(), source, err)
+
+				logger.
+					Warn("auth request will topic is not authorized",
+						zap.Error(err),
+						zap.Int("will-qos", request.WillQoS),
+					)
+
+				return a.authDenied(c, auth.GetCompany(), CodeAccessDenied)
+			}
+
+			rules = a.willRules(request.WillTopic)
+
+			logger.
+				Warn("auth request will topic is not authorized but connection is accepted",
+					zap.Error(err),
+					zap.Int("will-qos", request.WillQoS),
+					zap.Bool("will-stripped", rules != nil),
+				)
+		}
 	}
 
 	logger.
@@ -98,6 +341,44 @@ func (a API) Authv2(c *fiber.Ctx) error {
 	return c.Status(http.StatusOK).JSON(AuthResponse{
 		Result:      "allow",
 		IsSuperuser: auth.IsSuperuser(),
-		ExpireAt:    0,
+		ExpireAt:    a.expireAt(auth, token, time.Now()),
+		ClientAttrs: attrs,
+		Code:        "",
+		ACL:         rules,
 	})
 }
+
+// budgetExceeded reports the exceeded request and returns its default decision.
+func (a API) budgetExceeded(
+	logger *zap.Logger,
+	company, endpoint string,
+	cfg budget.Endpoint,
+	err budget.ExceededError,
+) string {
+	decision := cfg.Decision(err.TopicType)
+
+	a.Metrics.BudgetExceeded(company, endpoint, err.Stage, decision)
+
+	logger.
+		Warn("request exceeded its budget and is answered by default decision",
+			zap.Error(err),
+			zap.String("stage", err.Stage),
+			zap.String("topic-type", err.TopicType),
+			zap.String("decision", decision),
+		)
+
+	return decision
+}
+
+// authenticate authenticates the token and returns client attributes when authenticator supports them.
+func authenticate(
+	ctx context.Context,
+	auth authenticator.Authenticator,
+	token string,
+) (*authenticator.ClientAttrs, error) {
+	if attrsAuth, ok := auth.(authenticator.AttrsAuthenticator); ok {
+		return attrsAuth.AuthWithAttrs(ctx, token) //nolint: wrapcheck
+	}
+
+	return nil, auth.Auth(ctx, token) //nolint: wrapcheck
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/snapp-incubator/soteria/internal/config"
+)
+
+// CacheHint is the ttl of EMQ acl cache for the allow and deny decisions of a vendor,
+// zero ttl doesn't hint the decision.
+type CacheHint struct {
+	Allow time.Duration
+	Deny  time.Duration
+}
+
+// cacheHint is the cache field of ACL responses which EMQ uses instead of its acl_cache_ttl.
+type cacheHint struct {
+	TTL string `json:"ttl"`
+}
+
+// NewCacheHints creates the acl cache hints of vendors which have them.
+func NewCacheHints(vendors []config.Vendor) map[string]CacheHint {
+	hints := make(map[string]CacheHint)
+
+	for _, vendor := range vendors {
+		if vendor.ACLCacheAllowTTL <= 0 && vendor.ACLCacheDenyTTL <= 0 {
+			continue
+		}
+
+		hints[vendor.Company] = CacheHint{
+			Allow: vendor.ACLCacheAllowTTL,
+			Deny:  vendor.ACLCacheDenyTTL,
+		}
+	}
+
+	return hints
+}
+
+// ttl returns the hinted ttl of result, other results like ignore are never hinted.
+func (h CacheHint) ttl(result string) time.Duration {
+	switch result {
+	case "allow":
+		return h.Allow
+	case "deny":
+		return h.Deny
+	default:
+		return 0
+	}
+}
+
+// CacheHint adds the ttl of vendor for the decision of acl handler, so EMQ keeps allows longer
+// than denials, e.g. {"cache": {"ttl": "30s"}, "result": "allow"}.
+func (a API) CacheHint(c *fiber.Ctx) error {
+	if err := c.Next(); err != nil {
+		return err
+	}
+
+	company, _ := c.Locals(vendorLocal).(string)
+
+	hint, ok := a.CacheHints[company]
+	if !ok {
+		return nil
+	}
+
+	var response map[string]json.RawMessage
+
+	if err := json.Unmarshal(c.Response().Body(), &response); err != nil {
+		return nil //nolint: nilerr
+	}
+
+	var result string
+
+	if err := json.Unmarshal(response["result"], &result); err != nil {
+		return nil //nolint: nilerr
+	}
+
+	ttl := hint.ttl(result)
+	if ttl <= 0 {
+		return nil
+	}
+
+	cache, err := json.Marshal(cacheHint{TTL: emqDuration(ttl)})
+	if err != nil {
+		return nil //nolint: nilerr
+	}
+
+	response["cache"] = cache
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		return nil //nolint: nilerr
+	}
+
+	c.Response().SetBody(body)
+
+	return nil
+}
+
+// emqDuration formats the duration in seconds, or milliseconds when it has a fraction of second,
+// because EMQ doesn't parse the compound durations of Go like 1m30s.
+func emqDuration(d time.Duration) string {
+	if d%time.Second == 0 {
+		return strconv.FormatInt(int64(d/time.Second), 10) + "s"
+	}
+
+	return strconv.FormatInt(d.Milliseconds(), 10) + "ms"
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/ipfilter"
+)
+
+// NewIPFilters parses the client address ranges of vendors which have them.
+func NewIPFilters(vendors []config.Vendor) (map[string]*ipfilter.Filter, error) {
+	filters := make(map[string]*ipfilter.Filter)
+
+	for _, vendor := range vendors {
+		if len(vendor.AllowedCIDRs) == 0 && len(vendor.DeniedCIDRs) == 0 {
+			continue
+		}
+
+		filter, err := ipfilter.New(vendor.AllowedCIDRs, vendor.DeniedCIDRs)
+		if err != nil {
+			return nil, fmt.Errorf("vendor %s has invalid client address ranges %w", vendor.Company, err)
+		}
+
+		filters[vendor.Company] = filter
+	}
+
+	return filters, nil
+}
+
+// ClientIP returns the address of MQTT client from the ipaddress or peerhost fields of EMQ requests
+// and falls back to the first address of X-Forwarded-For header.
+// It returns an invalid address when none of them has an address.
+func ClientIP(c *fiber.Ctx, ipAddress, peerHost string) netip.Addr {
+	for _, raw := range []string{ipAddress, peerHost} {
+		if addr, ok := parseAddr(raw); ok {
+			return addr
+		}
+	}
+
+	forwarded, _, _ := strings.Cut(c.Get(fiber.HeaderXForwardedFor), ",")
+
+	addr, _ := parseAddr(forwarded)
+
+	return addr
+}
+
+// parseAddr parses an address which may have a port.
+func parseAddr(raw string) (netip.Addr, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return netip.Addr{}, false
+	}
+
+	if addr, err := netip.ParseAddr(raw); err == nil {
+		return addr.Unmap(), true
+	}
+
+	if addrPort, err := netip.ParseAddrPort(raw); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+
+	return netip.Addr{}, false
+}
+
+// formatIP returns the address for logs and spans, unknown addresses are empty.
+func formatIP(addr netip.Addr) string {
+	if !addr.IsValid() {
+		return ""
+	}
+
+	return addr.String()
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/metric"
+)
+
+// VendorConfigs holds the redacted configuration of vendors which the running authenticators are built from,
+// its generation is increased each time the served configuration is swapped.
+type VendorConfigs struct {
+	vendors    atomic.Pointer[map[string]config.Vendor]
+	generation atomic.Uint64
+	metrics    *metric.ConfigMetrics
+}
+
+func NewVendorConfigs(vendors []config.Vendor) *VendorConfigs {
+	// nolint: exhaustruct
+	v := &VendorConfigs{
+		metrics: metric.NewConfigMetrics(prometheus.DefaultRegisterer),
+	}
+
+	v.vendors.Store(redact(vendors))
+
+	return v
+}
+
+func redact(vendors []config.Vendor) *map[string]config.Vendor {
+	redacted := make(map[string]config.Vendor, len(vendors))
+
+	for _, vendor := range vendors {
+		redacted[vendor.Company] = vendor.Redacted()
+	}
+
+	return &redacted
+}
+
+// Get returns the redacted configuration of vendor.
+func (v *VendorConfigs) Get(company string) (config.Vendor, bool) {
+	if v == nil {
+		return config.Vendor{}, false //nolint: exhaustruct
+	}
+
+	vendor, ok := (*v.vendors.Load())[company]
+
+	return vendor, ok
+}
+
+// Generation returns the number of swaps of the served configuration.
+func (v *VendorConfigs) Generation() uint64 {
+	if v == nil {
+		return 0
+	}
+
+	return v.generation.Load()
+}
+
+// Swap replaces the configuration of vendors with the configuration which the authenticators are rebuilt from
+// and increases the generation. Reloads which don't rebuild the authenticators, e.g. the feature flag reloads,
+// shouldn't swap it.
+func (v *VendorConfigs) Swap(vendors []config.Vendor) {
+	v.vendors.Store(redact(vendors))
+	v.generation.Add(1)
+	v.metrics.Swapped()
+}
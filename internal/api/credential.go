@@ -0,0 +1,43 @@
+package api
+
+import (
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/credential"
+)
+
+// normalizeCredential normalizes the token of vendors with lenient token parsing, like the padded tokens of embedded
+// clients, before it is checked and parsed by their authenticator. Static clients are skipped like the checks.
+func (a API) normalizeCredential(auth authenticator.Authenticator, endpoint, rawToken, username, token string) string {
+	if !a.Flags.LenientTokenParsing(auth.GetCompany()) {
+		return token
+	}
+
+	if _, ok := staticClient(auth, rawToken, username); ok {
+		return token
+	}
+
+	normalized, ok := credential.Normalize(token)
+	if ok {
+		a.Metrics.TokenNormalized(auth.GetCompany(), endpoint)
+	}
+
+	return normalized
+}
+
+// checkCredential rejects the tokens which are not shaped like a JWT before they are parsed and counts them,
+// static clients are skipped because their username is not a token. Rejections are not logged because
+// they are mostly coming from scanners.
+func (a API) checkCredential(auth authenticator.Authenticator, endpoint, rawToken, username, token string) error {
+	if _, ok := staticClient(auth, rawToken, username); ok {
+		return nil
+	}
+
+	reason := credential.Check(token, a.MaxCredentialLength)
+	if reason == "" {
+		return nil
+	}
+
+	a.Metrics.MalformedCredential(auth.GetCompany(), endpoint, reason)
+
+	return credential.MalformedCredentialError{Reason: reason, Length: len(token), Err: nil}
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"time"
+
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/config"
+)
+
+// NewMaxSessions creates the maximum session durations of vendors which have them.
+func NewMaxSessions(vendors []config.Vendor) map[string]time.Duration {
+	sessions := make(map[string]time.Duration)
+
+	for _, vendor := range vendors {
+		if vendor.MaxSessionDuration <= 0 {
+			continue
+		}
+
+		sessions[vendor.Company] = vendor.MaxSessionDuration
+	}
+
+	return sessions
+}
+
+// expireAt returns the epoch seconds which EMQ disconnects the client at, so expired tokens cannot publish until
+// their next ACL check. It is the expiry of token clamped to the maximum session duration of vendor,
+// and zero omits it for vendors without emit_expire_at and tokens without exp claim, like the tokens of services.
+func (a API) expireAt(auth authenticator.Authenticator, token string, now time.Time) int64 {
+	if !a.Flags.EmitExpireAt(auth.GetCompany()) {
+		return 0
+	}
+
+	expiry, ok := auth.(authenticator.ExpiryAuthenticator)
+	if !ok {
+		return 0
+	}
+
+	exp, ok := expiry.TokenExpiry(token)
+	if !ok {
+		return 0
+	}
+
+	if limit := a.MaxSessions[auth.GetCompany()]; limit > 0 && exp.After(now.Add(limit)) {
+		exp = now.Add(limit)
+	}
+
+	return exp.Unix()
+}
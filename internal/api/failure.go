@@ -0,0 +1,80 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+)
+
+// Codes of failed authentications, so clients can refresh their expired tokens instead of treating
+// every failure the same.
+const (
+	CodeTokenExpired = "TOKEN_EXPIRED"
+	CodeInvalidToken = "INVALID_TOKEN"
+	CodeAccessDenied = "ACCESS_DENIED"
+	// CodeMalformedCredential is the code of credentials which are not a compact JWT, so they are not parsed.
+	CodeMalformedCredential = "MALFORMED_CREDENTIAL"
+	// CodeUnknownIssuer is the code of tokens which issuer is not known by any vendor of the resolution.
+	CodeUnknownIssuer = "UNKNOWN_ISSUER"
+	// CodeEmptyCredentials is the code of clients without credentials which no anonymous policy allows.
+	CodeEmptyCredentials = "EMPTY_CREDENTIALS"
+)
+
+// failureStatus is the status code of each failure code for vendors with the auth_failure_status flag.
+// nolint: gochecknoglobals
+var failureStatus = map[string]int{
+	CodeTokenExpired:        http.StatusUnauthorized,
+	CodeInvalidToken:        http.StatusUnauthorized,
+	CodeAccessDenied:        http.StatusForbidden,
+	CodeMalformedCredential: http.StatusBadRequest,
+	CodeUnknownIssuer:       http.StatusUnauthorized,
+	CodeEmptyCredentials:    http.StatusUnauthorized,
+}
+
+// tokenFailure returns the code of tokens which are not authenticated, expiry is reported by
+// the parser of manual authenticators and by the validator of auto authenticators.
+func tokenFailure(err error) string {
+	var mcErr authenticator.MalformedCredentialError
+
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return CodeTokenExpired
+	case errors.As(err, &mcErr):
+		return CodeMalformedCredential
+	default:
+		return CodeInvalidToken
+	}
+}
+
+// tokenReason returns the reason of ACL requests which are denied because of their token, it is empty
+// for the tokens which are not malformed.
+func tokenReason(err error) string {
+	var mcErr authenticator.MalformedCredentialError
+
+	if errors.As(err, &mcErr) {
+		return authenticator.ReasonMalformedCredential
+	}
+
+	return ""
+}
+
+// authDenied responds the denied authentication with its code. Status is 200, which EMQ expects
+// for its decisions, unless vendor has the auth_failure_status flag.
+func (a API) authDenied(c *fiber.Ctx, company, code string) error {
+	status := http.StatusOK
+	if a.Flags.AuthFailureStatus(company) {
+		status = failureStatus[code]
+	}
+
+	return c.Status(status).JSON(AuthResponse{
+		Result:      "deny",
+		IsSuperuser: false,
+		ExpireAt:    0,
+		ClientAttrs: nil,
+		Code:        code,
+		ACL:         nil,
+	})
+}
@@ -0,0 +1,171 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/api"
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/clientid"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/credential"
+	"github.com/snapp-incubator/soteria/internal/flags"
+	"github.com/snapp-incubator/soteria/internal/metric"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/snapp-incubator/soteria/pkg/testutil"
+	"github.com/snapp-incubator/soteria/pkg/validator"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+)
+
+// TestAuthFailureCodes pins each class of failed authentications to its code and status,
+// brokers and SDKs depend on them.
+// nolint: funlen
+func TestAuthFailureCodes(t *testing.T) {
+	t.Parallel()
+
+	key := []byte("secret")
+	cfg := config.SnappVendor()
+
+	hid, err := topics.NewHashIDManager(cfg.HashIDMap)
+	require.NoError(t, err)
+
+	token := func(key []byte, expiresIn time.Duration) string {
+		token, err := testutil.Token(jwt.SigningMethodHS512, key, testutil.Claims{
+			Issuer:       topics.DriverIss,
+			Subject:      testutil.DefaultSubject,
+			ExpiresIn:    expiresIn,
+			NoExpiration: false,
+			Extra:        nil,
+			Kid:          "",
+		})
+		require.NoError(t, err)
+
+		return token
+	}
+
+	valid := token(key, time.Hour)
+	expired := token(key, -time.Minute)
+	forged := token([]byte("forged"), time.Hour)
+
+	// validator rejects the expired token with its code and the others with a reason which mentions expiry
+	// but has no code, so they are not expired.
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		res.WriteHeader(http.StatusUnauthorized)
+
+		if req.Header.Get("Authorization") == "bearer "+expired {
+			_, _ = res.Write([]byte(`{"code": "token_expired", "message": "token is expired"}`))
+
+			return
+		}
+
+		_, _ = res.Write([]byte(`{"message": "signing key is expired"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	manager := func(company string) *topics.Manager {
+		return topics.NewTopicManager(cfg.Topics, hid, company, cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop())
+	}
+
+	manual := func(company string) authenticator.ManualAuthenticator {
+		// nolint: exhaustruct
+		return authenticator.ManualAuthenticator{
+			Keys:               map[string]any{topics.DriverIss: key},
+			AllowedAccessTypes: []acl.AccessType{acl.Pub, acl.Sub},
+			Company:            company,
+			TopicManager:       manager(company),
+			JWTConfig:          cfg.Jwt,
+			Parser:             jwt.NewParser(),
+		}
+	}
+
+	features := flags.New(zap.NewNop())
+	features.Load(map[string]bool{}, map[string]map[string]bool{
+		"snapp": {flags.AuthFailureStatus: true},
+		"auto":  {flags.AuthFailureStatus: true},
+	})
+
+	// nolint: exhaustruct
+	a := api.API{
+		Authenticators: map[string]authenticator.Authenticator{
+			"snapp":  manual("snapp"),
+			"compat": manual("compat"),
+			"auto": &authenticator.AutoAuthenticator{
+				AllowedAccessTypes: []acl.AccessType{acl.Pub, acl.Sub},
+				Company:            "auto",
+				TopicManager:       manager("auto"),
+				JWTConfig:          cfg.Jwt,
+				Validator:          validator.New(server.URL, time.Second),
+				Parser:             jwt.NewParser(),
+				Tracer:             noop.NewTracerProvider().Tracer(""),
+				Metrics:            metric.NewAutoAuthenticatorMetrics(prometheus.DefaultRegisterer),
+			},
+		},
+		VendorResolution: []string{"snapp"},
+		Tracer:           noop.NewTracerProvider().Tracer(""),
+		Logger:           zap.NewNop(),
+		Metrics:          metric.NewAPIMetrics(prometheus.DefaultRegisterer),
+		Flags:            features,
+		Parser: clientid.NewParser(clientid.Config{
+			Patterns: map[string]string{},
+		}),
+		MaxTopicLength:      topics.DefaultMaxTopicLength,
+		MaxCredentialLength: credential.DefaultMaxLength,
+	}
+
+	app := fiber.New()
+	app.Post("/v2/auth", a.Authv2)
+
+	tests := []struct {
+		name      string
+		token     string
+		willTopic string
+		status    int
+		result    string
+		code      string
+	}{
+		{name: "allowed", token: "snapp:" + valid, status: http.StatusOK, result: "allow", code: ""},
+		{name: "expired", token: "snapp:" + expired, status: http.StatusUnauthorized, result: "deny", code: api.CodeTokenExpired},
+		{name: "forged", token: "snapp:" + forged, status: http.StatusUnauthorized, result: "deny", code: api.CodeInvalidToken},
+		{
+			name: "malformed", token: "snapp:token", status: http.StatusBadRequest, result: "deny",
+			code: api.CodeMalformedCredential,
+		},
+		{
+			name:      "will topic",
+			token:     "snapp:" + valid,
+			willTopic: "snapp/unknown",
+			status:    http.StatusForbidden,
+			result:    "deny",
+			code:      api.CodeAccessDenied,
+		},
+		{name: "expired by validator", token: "auto:" + expired, status: http.StatusUnauthorized, result: "deny", code: api.CodeTokenExpired},
+		{name: "invalid by validator", token: "auto:" + valid, status: http.StatusUnauthorized, result: "deny", code: api.CodeInvalidToken},
+		// brokers expect 200 for their decisions, so vendors without the flag only have the code.
+		{name: "expired without flag", token: "compat:" + expired, status: http.StatusOK, result: "deny", code: api.CodeTokenExpired},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require := require.New(t)
+
+			// nolint: exhaustruct
+			resp := postJSON(t, app, "/v2/auth", api.AuthRequest{Token: tc.token, WillTopic: tc.willTopic})
+			response := decode[api.AuthResponse](t, resp)
+
+			require.Equal(tc.status, resp.StatusCode)
+			require.Equal(tc.result, response.Result)
+			require.Equal(tc.code, response.Code)
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+)
+
+const (
+	// LegacyAllow is the v1 response body of allowed requests.
+	LegacyAllow = "ok"
+	// LegacyIgnore is the v1 response body of ignored requests, broker tries its next authenticator.
+	LegacyIgnore = "ignore"
+	// LegacyDeny is the v1 response body of denied requests.
+	LegacyDeny = "request is not authorized"
+
+	// LegacyAuthPath and LegacyACLPath are the v1 routes, they label the deprecated endpoint metric
+	// instead of the request path which is only valid during the request.
+	LegacyAuthPath = "/v1/auth"
+	LegacyACLPath  = "/v1/acl"
+)
+
+// LegacyAuthRequest is the form body of v1 auth requests which the old EMQ clusters send.
+type LegacyAuthRequest struct {
+	Token     string `form:"token"`
+	Username  string `form:"username"`
+	Password  string `form:"password"`
+	ClientID  string `form:"clientid"`
+	IPAddress string `form:"ipaddress"`
+}
+
+// LegacyACLRequest is the form body of v1 acl requests which the old EMQ clusters send,
+// access is 1 for subscribe and 2 for publish.
+type LegacyACLRequest struct {
+	Access     acl.AccessType `form:"access"`
+	Token      string         `form:"token"`
+	Username   string         `form:"username"`
+	Password   string         `form:"password"`
+	Topic      string         `form:"topic"`
+	ClientID   string         `form:"clientid"`
+	IPAddress  string         `form:"ipaddr"`
+	Mountpoint string         `form:"mountpoint"`
+}
+
+// Legacy mounts the v1 routes of the old EMQ clusters when they are enabled. They are translated
+// onto the v2 handlers and their responses are not signed.
+func (a API) Legacy(router fiber.Router) {
+	if !a.LegacyRoutes {
+		return
+	}
+
+	broker := BodyLimit(a.Server.BodyLimits.Broker)
+
+	router.Post(LegacyAuthPath, broker, a.Limit(a.Limiters.Auth), a.LegacyAuth, a.Authv2)
+	router.Post(LegacyACLPath, broker, a.Limit(a.Limiters.ACL), a.LegacyACL, a.ACLv2)
+}
+
+// LegacyAuth translates the v1 auth request into the v2 request of next handler and
+// its v2 response into the v1 response.
+func (a API) LegacyAuth(c *fiber.Ctx) error {
+	a.Metrics.DeprecatedEndpoint(LegacyAuthPath)
+
+	request := new(LegacyAuthRequest)
+	if err := c.BodyParser(request); err != nil {
+		return c.Status(http.StatusBadRequest).SendString(err.Error())
+	}
+
+	// nolint: exhaustruct
+	if err := legacyRequest(c, AuthRequest{
+		Token:     request.Token,
+		Username:  request.Username,
+		Password:  request.Password,
+		ClientID:  request.ClientID,
+		IPAddress: request.IPAddress,
+	}); err != nil {
+		return err
+	}
+
+	return legacyResponse(c)
+}
+
+// LegacyACL translates the v1 acl request into the v2 request of next handler and
+// its v2 response into the v1 response.
+func (a API) LegacyACL(c *fiber.Ctx) error {
+	a.Metrics.DeprecatedEndpoint(LegacyACLPath)
+
+	request := new(LegacyACLRequest)
+	if err := c.BodyParser(request); err != nil {
+		return c.Status(http.StatusBadRequest).SendString(err.Error())
+	}
+
+	// nolint: exhaustruct
+	if err := legacyRequest(c, ACLRequest{
+		Token:      request.Token,
+		Username:   request.Username,
+		Password:   request.Password,
+		Topic:      request.Topic,
+		Action:     legacyAction(request.Access),
+		ClientID:   request.ClientID,
+		IPAddress:  request.IPAddress,
+		Mountpoint: request.Mountpoint,
+	}); err != nil {
+		return err
+	}
+
+	return legacyResponse(c)
+}
+
+// legacyRequest replaces the body of request with the v2 request and calls the next handler.
+func legacyRequest(c *fiber.Ctx, request any) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).SendString(err.Error())
+	}
+
+	c.Request().SetBody(body)
+	c.Request().Header.SetContentType(fiber.MIMEApplicationJSON)
+
+	return c.Next()
+}
+
+// legacyResponse replaces the v2 response with the v1 response, which has the decision in its status code.
+func legacyResponse(c *fiber.Ctx) error {
+	var decision struct {
+		Result string `json:"result"`
+	}
+
+	// responses of middlewares, e.g. the limiter, are not decisions.
+	if err := json.Unmarshal(c.Response().Body(), &decision); err != nil {
+		return nil //nolint: nilerr
+	}
+
+	c.Response().Header.SetContentType(fiber.MIMETextPlainCharsetUTF8)
+
+	switch decision.Result {
+	case "allow":
+		return c.Status(http.StatusOK).SendString(LegacyAllow)
+	case "ignore":
+		return c.Status(http.StatusOK).SendString(LegacyIgnore)
+	default:
+		return c.Status(http.StatusUnauthorized).SendString(LegacyDeny)
+	}
+}
+
+// legacyAction returns the v2 action of v1 access.
+func legacyAction(access acl.AccessType) string {
+	switch access { //nolint: exhaustive
+	case acl.Sub:
+		return "subscribe"
+	case acl.Pub:
+		return "publish"
+	}
+
+	return string(access)
+}
@@ -0,0 +1,115 @@
+package api_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/snapp-incubator/soteria/internal/api"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/snapp-incubator/soteria/pkg/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// payloads of the oldest EMQ 4 clusters with their default http auth parameters,
+// the token is in the username and %s is replaced by it.
+const (
+	legacyAuthPayload = "clientid=snapp-driver-1&username=%s&password="
+	legacyACLPayload  = "access=%s&username=%s&clientid=snapp-driver-1&ipaddr=10.0.0.1&topic=%s&mountpoint="
+)
+
+// nolint: funlen
+func TestLegacyRoutes(t *testing.T) {
+	t.Parallel()
+
+	key := []byte("secret")
+	cfg := config.SnappVendor()
+
+	token, err := testutil.DriverToken(jwt.SigningMethodHS512, key)
+	require.NoError(t, err)
+
+	a := newTestAPI(manualAuthenticator(t, key, cfg))
+	a.MaxTopicLength = topics.DefaultMaxTopicLength
+	a.LegacyRoutes = true
+
+	location := "snapp/driver/" + testutil.DefaultSubject + "/location"
+
+	cases := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		result string
+	}{
+		{
+			name:   "auth",
+			path:   "/v1/auth",
+			body:   fmt.Sprintf(legacyAuthPayload, token),
+			status: http.StatusOK,
+			result: api.LegacyAllow,
+		},
+		{
+			name:   "auth with invalid token",
+			path:   "/v1/auth",
+			body:   fmt.Sprintf(legacyAuthPayload, "header.payload.signature"),
+			status: http.StatusUnauthorized,
+			result: api.LegacyDeny,
+		},
+		{
+			name:   "publish",
+			path:   "/v1/acl",
+			body:   fmt.Sprintf(legacyACLPayload, acl.Pub, token, location),
+			status: http.StatusOK,
+			result: api.LegacyAllow,
+		},
+		{
+			name:   "subscribe on publish only topic",
+			path:   "/v1/acl",
+			body:   fmt.Sprintf(legacyACLPayload, acl.Sub, token, location),
+			status: http.StatusUnauthorized,
+			result: api.LegacyDeny,
+		},
+	}
+
+	post := func(a api.API, path, body string) (int, string) {
+		app := fiber.New()
+		a.Legacy(app)
+
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Add("Content-Type", fiber.MIMEApplicationForm)
+
+		resp := send(t, app, req)
+
+		result, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		return resp.StatusCode, string(result)
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			status, result := post(a, c.path, c.body)
+			require.Equal(t, c.status, status)
+			require.Equal(t, c.result, result)
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Parallel()
+
+		b := a
+		b.LegacyRoutes = false
+
+		status, _ := post(b, "/v1/auth", fmt.Sprintf(legacyAuthPayload, token))
+		require.Equal(t, http.StatusNotFound, status)
+	})
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/snapp-incubator/soteria/internal/limiter"
+)
+
+// Limit caps the in-flight requests of an endpoint, requests which are shed by the limiter
+// are answered with 503 and a retry hint, so broker retries them after the storm.
+func (a API) Limit(l *limiter.Limiter) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		release, err := l.Acquire(c.UserContext())
+		if err != nil {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(l.RetryAfter()))
+
+			return c.SendStatus(http.StatusServiceUnavailable)
+		}
+
+		defer release()
+
+		return c.Next()
+	}
+}
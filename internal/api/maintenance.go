@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+var ErrMaintenanceExpiry = errors.New("maintenance should have an expires_at in the future")
+
+// Maintenance is a window of vendor in which tokens are not verified, so clients keep their topics
+// while the identity provider of vendor is down. It always has an expiry and ends by itself.
+type Maintenance struct {
+	ExpiresAt time.Time `json:"expires_at"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+// Validate checks the maintenance ends in the future.
+func (m Maintenance) Validate(now time.Time) error {
+	if !m.ExpiresAt.After(now) {
+		return ErrMaintenanceExpiry
+	}
+
+	return nil
+}
+
+// Maintenances holds the maintenance windows of vendors by their company name. Windows are kept in memory,
+// so they are not shared between pods and each pod needs its own admin request.
+type Maintenances struct {
+	lock    sync.Mutex
+	windows map[string]Maintenance
+}
+
+func NewMaintenances() *Maintenances {
+	return &Maintenances{
+		lock:    sync.Mutex{},
+		windows: make(map[string]Maintenance),
+	}
+}
+
+// Get returns the maintenance of vendor when it is not expired, nil maintenances have no vendor in maintenance.
+func (m *Maintenances) Get(company string) (Maintenance, bool) {
+	if m == nil {
+		return Maintenance{ExpiresAt: time.Time{}, Reason: ""}, false
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	window, ok := m.windows[company]
+	if ok && !window.ExpiresAt.After(time.Now()) {
+		delete(m.windows, company)
+
+		ok = false
+	}
+
+	return window, ok
+}
+
+// Set starts the maintenance of vendor or changes its window.
+func (m *Maintenances) Set(company string, window Maintenance) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.windows[company] = window
+}
+
+// End ends the maintenance of vendor before its expiry and reports whether vendor was in maintenance.
+func (m *Maintenances) End(company string) bool {
+	if _, ok := m.Get(company); !ok {
+		return false
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	_, ok := m.windows[company]
+	delete(m.windows, company)
+
+	return ok
+}
+
+// maintain tags the request of vendor in maintenance in its logs, span and metrics and returns a copy
+// of API without session decisions, so the unverified decisions are not memoized after the maintenance.
+func (a API) maintain(
+	ctx context.Context,
+	span trace.Span,
+	company, endpoint string,
+) (API, context.Context) {
+	window, ok := a.Maintenances.Get(company)
+	if !ok {
+		return a, ctx
+	}
+
+	a.Metrics.Maintenance(company, endpoint)
+	span.SetAttributes(attribute.Bool("maintenance", true))
+
+	a.Logger = a.Logger.With(zap.Bool("maintenance", true), zap.Time("maintenance-expires-at", window.ExpiresAt))
+	a.Sessions = nil
+
+	return a, authenticator.WithMaintenance(ctx)
+}
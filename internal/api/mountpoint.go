@@ -0,0 +1,39 @@
+package api
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/config"
+)
+
+// NewMountpoints returns the allowed mountpoints of vendors which have them.
+func NewMountpoints(vendors []config.Vendor) map[string][]string {
+	mountpoints := make(map[string][]string)
+
+	for _, vendor := range vendors {
+		if len(vendor.Mountpoints) == 0 {
+			continue
+		}
+
+		mountpoints[vendor.Company] = vendor.Mountpoints
+	}
+
+	return mountpoints
+}
+
+// stripMountpoint removes the listener mountpoint which EMQ prepends to topics, so topic can be matched
+// by the vendor templates. mountpoint must be one of the allowed mountpoints of vendor.
+func (a API) stripMountpoint(company, mountpoint, topic string) (string, error) {
+	if mountpoint == "" {
+		return topic, nil
+	}
+
+	if !slices.Contains(a.Mountpoints[company], mountpoint) {
+		return "", fmt.Errorf("mountpoint %q is not allowed: %w", mountpoint, authenticator.ErrUnexpectedMountpoint)
+	}
+
+	return strings.TrimPrefix(topic, mountpoint), nil
+}
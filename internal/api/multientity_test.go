@@ -0,0 +1,221 @@
+package api_test
+
+import (
+	"crypto/md5" //nolint: gosec
+	"encoding/hex"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/api"
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/clientid"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/metric"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+)
+
+// sampleMultiEntity is the reference configuration of a vendor with driver, passenger and courier entities.
+const sampleMultiEntity = "../../configs/sample-multi-entity.yml"
+
+// nolint: funlen
+func TestSampleMultiEntity(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	cfg, err := config.Load(sampleMultiEntity)
+	require.NoError(err)
+	require.Len(cfg.Vendors, 1)
+
+	vendor := cfg.Vendors[0]
+
+	auths, err := authenticator.Builder{
+		Vendors:              cfg.Vendors,
+		Logger:               zap.NewNop(),
+		ValidatorConfig:      cfg.Validator,
+		Tracer:               noop.NewTracerProvider().Tracer(""),
+		KeyRegistry:          nil,
+		Flags:                nil,
+		FailureRatio:         nil,
+		StrictTopicShadowing: true,
+		Background:           nil,
+	}.Authenticators()
+	require.NoError(err)
+	require.Empty(api.NewReadiness(auths, zap.NewNop()).Failures())
+
+	// nolint: exhaustruct
+	a := api.API{
+		Authenticators:   auths,
+		VendorResolution: cfg.Resolution(),
+		Tracer:           noop.NewTracerProvider().Tracer(""),
+		Logger:           zap.NewNop(),
+		Metrics:          metric.NewAPIMetrics(prometheus.DefaultRegisterer),
+		Parser: clientid.NewParser(clientid.Config{
+			Patterns: map[string]string{},
+		}),
+	}
+
+	app := fiber.New()
+	app.Post("/v2/auth", a.Authv2)
+	app.Post("/v2/acl", a.ACLv2)
+
+	hid, err := topics.NewHashIDManager(vendor.HashIDMap)
+	require.NoError(err)
+
+	secrets := map[string]string{
+		"driver":    "driver-secret",
+		"passenger": "passenger-secret",
+		"courier":   "courier-secret",
+	}
+	issuers := map[string]string{"driver": "0", "passenger": "1", "courier": "2"}
+	subjects := make(map[string]string)
+	tokens := make(map[string]string)
+
+	for entity, iss := range issuers {
+		// each entity has the hash-id of 1 with its own salt.
+		subjects[entity], err = hid[iss].Encode([]int{1})
+		require.NoError(err)
+
+		// nolint: exhaustruct
+		tokens[entity], err = testutil.Token(jwt.SigningMethodHS512, []byte(secrets[entity]), testutil.Claims{
+			Issuer:  iss,
+			Subject: subjects[entity],
+		})
+		require.NoError(err)
+	}
+
+	require.NotEqual(subjects["driver"], subjects["courier"])
+
+	post := func(path string, request any) string {
+		return decode[api.AuthResponse](t, postJSON(t, app, path, request)).Result
+	}
+
+	for entity, token := range tokens {
+		// nolint: exhaustruct
+		require.Equal("allow", post("/v2/auth", api.AuthRequest{Token: token}), entity)
+	}
+
+	event := md5.Sum([]byte(topics.EmqCabHashPrefix + "-1")) //nolint: gosec
+
+	cases := []struct {
+		name   string
+		entity string
+		topic  string
+		action string
+		result string
+	}{
+		{
+			name:   "courier publishes its box event",
+			entity: "courier",
+			topic:  "snapp-box/courier/" + subjects["courier"] + "/box",
+			action: "publish",
+			result: "allow",
+		},
+		{
+			name:   "courier cannot subscribe to its box event",
+			entity: "courier",
+			topic:  "snapp-box/courier/" + subjects["courier"] + "/box",
+			action: "subscribe",
+			result: "deny",
+		},
+		{
+			name:   "driver cannot publish its box event",
+			entity: "driver",
+			topic:  "snapp-box/driver/" + subjects["driver"] + "/box",
+			action: "publish",
+			result: "deny",
+		},
+		{
+			name:   "driver cannot publish box event of courier",
+			entity: "driver",
+			topic:  "snapp-box/courier/" + subjects["courier"] + "/box",
+			action: "publish",
+			result: "deny",
+		},
+		{
+			name:   "passenger cannot publish its box event",
+			entity: "passenger",
+			topic:  "snapp-box/passenger/" + subjects["passenger"] + "/box",
+			action: "publish",
+			result: "deny",
+		},
+		{
+			name:   "courier subscribes to its chat",
+			entity: "courier",
+			topic:  "snapp-box/courier/" + subjects["courier"] + "/chat",
+			action: "subscribe",
+			result: "allow",
+		},
+		{
+			name:   "driver subscribes to its chat",
+			entity: "driver",
+			topic:  "snapp-box/driver/" + subjects["driver"] + "/chat",
+			action: "subscribe",
+			result: "allow",
+		},
+		{
+			name:   "courier cannot subscribe to chat of driver",
+			entity: "courier",
+			topic:  "snapp-box/driver/" + subjects["driver"] + "/chat",
+			action: "subscribe",
+			result: "deny",
+		},
+		{
+			name:   "driver publishes to delivery chat",
+			entity: "driver",
+			topic:  "snapp-box/delivery/d42/chat",
+			action: "publish",
+			result: "allow",
+		},
+		{
+			name:   "passenger subscribes to delivery chat",
+			entity: "passenger",
+			topic:  "snapp-box/delivery/d42/chat",
+			action: "subscribe",
+			result: "allow",
+		},
+		{
+			name:   "courier publishes to delivery chat",
+			entity: "courier",
+			topic:  "snapp-box/delivery/d42/chat",
+			action: "publish",
+			result: "allow",
+		},
+		{
+			name:   "courier subscribes to delivery chat",
+			entity: "courier",
+			topic:  "snapp-box/delivery/d42/chat",
+			action: "subscribe",
+			result: "allow",
+		},
+		{
+			name:   "courier subscribes to its events by its own salt",
+			entity: "courier",
+			topic:  "courier-event-" + hex.EncodeToString(event[:]),
+			action: "subscribe",
+			result: "allow",
+		},
+		{
+			name:   "driver cannot subscribe to events of courier",
+			entity: "driver",
+			topic:  "courier-event-" + hex.EncodeToString(event[:]),
+			action: "subscribe",
+			result: "deny",
+		},
+	}
+
+	for _, tc := range cases {
+		// nolint: exhaustruct
+		require.Equal(tc.result, post("/v2/acl", api.ACLRequest{
+			Token:  tokens[tc.entity],
+			Topic:  tc.topic,
+			Action: tc.action,
+		}), tc.name)
+	}
+}
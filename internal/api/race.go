@@ -0,0 +1,292 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"go.uber.org/zap"
+)
+
+const (
+	// winnerTTL is the lifetime of the winners of tokens without expiry.
+	winnerTTL = time.Hour
+	// winnerSweepInterval is the interval of removing the expired winners.
+	winnerSweepInterval = time.Minute
+	// maxWinners bounds the winners, winners of new tokens are not kept when it is reached.
+	maxWinners = 100_000
+)
+
+// raceResult is the authentication of a candidate vendor, index is its order in resolution.
+type raceResult struct {
+	index int
+	attrs *authenticator.ClientAttrs
+	err   error
+}
+
+// candidates returns the vendors which authenticate the token in parallel, it is only the resolved vendor
+// unless parallel resolution is enabled and the vendor is resolved by its issuer. The other vendors of
+// resolution which know the token issuer are added in their order and the disabled vendors, vendors in
+// maintenance and vendors which don't allow the client address are skipped.
+func (a API) candidates(
+	resolved authenticator.Authenticator,
+	resolution, token string,
+	clientIP netip.Addr,
+) []authenticator.Authenticator {
+	if !a.ParallelResolution || resolution != ResolutionIssuer || len(a.VendorResolution) < 2 { //nolint: mnd
+		return []authenticator.Authenticator{resolved}
+	}
+
+	candidates := make([]authenticator.Authenticator, 0, len(a.VendorResolution))
+
+	for _, name := range a.VendorResolution {
+		auth := a.Authenticators[name]
+		if auth == nil {
+			continue
+		}
+
+		if name == resolved.GetCompany() {
+			candidates = append(candidates, auth)
+
+			continue
+		}
+
+		if _, ok := a.Maintenances.Get(name); ok {
+			continue
+		}
+
+		if a.States.Get(name).State == VendorStateDisabled || !a.IPFilters[name].Allowed(clientIP) {
+			continue
+		}
+
+		if knows(auth, token) {
+			candidates = append(candidates, auth)
+		}
+	}
+
+	return candidates
+}
+
+// race authenticates the token by the candidates in parallel with a shared context and returns the first
+// definitive answer, which is an allow or a terminal deny, and cancels the others. Answers which are
+// finished together are resolved by the vendor order and allows of more than one vendor are logged as
+// ambiguous. The answer of the first candidate is returned when none of them is definitive.
+func (a API) race(
+	ctx context.Context,
+	candidates []authenticator.Authenticator,
+	token string,
+) (authenticator.Authenticator, *authenticator.ClientAttrs, error) {
+	if len(candidates) == 1 {
+		attrs, err := authenticate(ctx, candidates[0], token)
+
+		return candidates[0], attrs, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// results is buffered, so canceled candidates finish without a receiver.
+	results := make(chan raceResult, len(candidates))
+
+	for i, auth := range candidates {
+		go func() {
+			attrs, err := authenticate(ctx, auth, token)
+
+			results <- raceResult{index: i, attrs: attrs, err: err}
+		}()
+	}
+
+	answers := make([]*raceResult, len(candidates))
+
+	for range candidates {
+		result := <-results
+		answers[result.index] = &result
+
+		if definitive(result.err) {
+			break
+		}
+	}
+
+	cancel()
+
+	// answers which are already finished are collected without waiting for the canceled candidates.
+	for collected := false; !collected; {
+		select {
+		case result := <-results:
+			answers[result.index] = &result
+		default:
+			collected = true
+		}
+	}
+
+	winner := -1
+	allows := make([]string, 0)
+
+	for i, answer := range answers {
+		if answer == nil || !definitive(answer.err) {
+			continue
+		}
+
+		if winner == -1 {
+			winner = i
+		}
+
+		if answer.err == nil {
+			allows = append(allows, candidates[i].GetCompany())
+		}
+	}
+
+	if len(allows) > 1 {
+		a.Logger.Warn("token is allowed by more than one vendor of resolution",
+			zap.Strings("vendors", allows),
+			zap.String("winner", candidates[winner].GetCompany()),
+		)
+	}
+
+	// all of the candidates are answered when none of them is definitive.
+	if winner == -1 {
+		winner = 0
+	}
+
+	return candidates[winner], answers[winner].attrs, answers[winner].err
+}
+
+// aclVendor returns the vendor which authorizes the token of acl request. Tokens which are authenticated by
+// the parallel resolution are authorized by the vendor which allowed them on authentication instead of the first
+// vendor of resolution. Their candidates are only raced again when the pod has no winner of token, e.g. the
+// authentication is handled by another pod, and the winner is kept for the next acl requests of token.
+func (a API) aclVendor(
+	ctx context.Context,
+	resolved authenticator.Authenticator,
+	resolution, token string,
+	clientIP netip.Addr,
+) authenticator.Authenticator {
+	candidates := a.candidates(resolved, resolution, token, clientIP)
+	if len(candidates) == 1 {
+		return resolved
+	}
+
+	if company, ok := a.Winners.Get(token); ok {
+		for _, candidate := range candidates {
+			if candidate.GetCompany() == company {
+				return candidate
+			}
+		}
+	}
+
+	answered, _, err := a.race(ctx, candidates, token)
+	if err == nil {
+		a.Winners.Set(token, answered.GetCompany(), sessionExpiry(answered, token))
+	}
+
+	return answered
+}
+
+type winner struct {
+	company string
+	expires time.Time
+}
+
+// Winners keep the vendor which allowed each token in the parallel resolution by the digest of token, so
+// acl requests don't authenticate their tokens again. Winners are kept in memory until their token expires
+// and nil winners don't keep anything.
+type Winners struct {
+	lock    sync.Mutex
+	winners map[[sha256.Size]byte]winner
+}
+
+func NewWinners() *Winners {
+	return &Winners{
+		lock:    sync.Mutex{},
+		winners: make(map[[sha256.Size]byte]winner),
+	}
+}
+
+// Get returns the vendor which allowed the token when it is not expired.
+func (w *Winners) Get(token string) (string, bool) {
+	if w == nil {
+		return "", false
+	}
+
+	key := sha256.Sum256([]byte(token))
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	won, ok := w.winners[key]
+	if ok && !won.expires.After(time.Now()) {
+		delete(w.winners, key)
+
+		return "", false
+	}
+
+	return won.company, ok
+}
+
+// Set keeps the vendor which allowed the token until the token expiry, zero expiry is for the tokens
+// which don't expire and they are kept for winner ttl.
+func (w *Winners) Set(token, company string, expiry time.Time) {
+	if w == nil {
+		return
+	}
+
+	expires := time.Now().Add(winnerTTL)
+	if !expiry.IsZero() && expiry.Before(expires) {
+		expires = expiry
+	}
+
+	key := sha256.Sum256([]byte(token))
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if _, ok := w.winners[key]; !ok && len(w.winners) >= maxWinners {
+		return
+	}
+
+	w.winners[key] = winner{company: company, expires: expires}
+}
+
+// Start removes the expired winners periodically until the context is done.
+func (w *Winners) Start(ctx context.Context) {
+	if w == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(winnerSweepInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				w.expire(time.Now())
+			}
+		}
+	}()
+}
+
+func (w *Winners) expire(now time.Time) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for key, won := range w.winners {
+		if !won.expires.After(now) {
+			delete(w.winners, key)
+		}
+	}
+}
+
+// definitive checks the authentication answer is final for the token: it is allowed, or it is verified
+// by the vendor but denied, e.g. it is expired. Unverified tokens and failures of the vendor are not final
+// because the token may belong to another vendor.
+func definitive(err error) bool {
+	return err == nil || errors.Is(err, jwt.ErrTokenInvalidClaims) || errors.Is(err, authenticator.ErrMissingExpiry)
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"go.uber.org/zap"
+)
+
+// ReadyResponse is the readiness of instance, failures of its self-checks are only logged
+// because they have the configuration of vendors.
+type ReadyResponse struct {
+	Ready bool `json:"ready"`
+}
+
+// Readiness holds the failures of the latest self-checks, nil readiness is always ready.
+type Readiness struct {
+	failures atomic.Pointer[[]string]
+	logger   *zap.Logger
+}
+
+// NewReadiness runs the self-checks of authenticators on startup.
+func NewReadiness(auths map[string]authenticator.Authenticator, logger *zap.Logger) *Readiness {
+	r := &Readiness{
+		failures: atomic.Pointer[[]string]{},
+		logger:   logger,
+	}
+
+	r.Check(auths)
+
+	return r
+}
+
+// Check runs the self-checks of authenticators again, e.g. after a reload, and logs their failures
+// which are prefixed by their vendor.
+func (r *Readiness) Check(auths map[string]authenticator.Authenticator) {
+	failures := make([]string, 0)
+
+	for company, auth := range auths {
+		checker, ok := auth.(authenticator.SelfCheckAuthenticator)
+		if !ok {
+			continue
+		}
+
+		for _, err := range checker.SelfCheck() {
+			failures = append(failures, company+": "+err.Error())
+		}
+	}
+
+	slices.Sort(failures)
+
+	for _, failure := range failures {
+		r.logger.Error("self-check failed, instance is not ready", zap.String("failure", failure))
+	}
+
+	r.failures.Store(&failures)
+}
+
+// Failures returns the failures of the latest self-checks.
+func (r *Readiness) Failures() []string {
+	if r == nil {
+		return nil
+	}
+
+	if failures := r.failures.Load(); failures != nil {
+		return *failures
+	}
+
+	return nil
+}
+
+// Ready answers the readiness probe, instances with failed self-checks are not ready, so a bad
+// configuration never takes traffic.
+func (a API) Ready(c *fiber.Ctx) error {
+	if len(a.Readiness.Failures()) > 0 {
+		return c.Status(http.StatusServiceUnavailable).JSON(ReadyResponse{
+			Ready: false,
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(ReadyResponse{
+		Ready: true,
+	})
+}
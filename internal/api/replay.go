@@ -0,0 +1,47 @@
+package api
+
+import (
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/replay"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"go.uber.org/zap"
+)
+
+// capture records the sampled topic decision of token without the token itself,
+// decisions which don't depend on topic configuration are not captured.
+func (a API) capture(auth authenticator.Authenticator, token, topic string, access acl.AccessType, err error) {
+	if !a.Recorder.Sample() {
+		return
+	}
+
+	decision, reason, ok := replay.Outcome(err)
+	if !ok {
+		return
+	}
+
+	replayAuth, ok := auth.(authenticator.ReplayAuthenticator)
+	if !ok {
+		return
+	}
+
+	subject, subErr := replayAuth.Subject(token)
+	if subErr != nil {
+		return
+	}
+
+	// subject is not captured, decisions are replayed with its hash.
+	subHash, topic := a.Recorder.Pseudonymize(subject.Sub, topic)
+
+	if err := a.Recorder.Write(replay.Record{
+		Company:   auth.GetCompany(),
+		Issuer:    subject.Issuer,
+		SubHash:   subHash,
+		Qualifier: subject.Qualifier,
+		Topic:     topic,
+		Access:    access,
+		Decision:  decision,
+		Reason:    reason,
+	}); err != nil {
+		a.Logger.Warn("acl decision capture failed", zap.Error(err), zap.String("authenticator", auth.GetCompany()))
+	}
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/snapp-incubator/soteria/pkg/correlation"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// requestIDLocal is the local which the request id middleware stores the request id in.
+const requestIDLocal = "soteria-request-id"
+
+// RequestID honors the request id of request or generates one, and returns it in the response headers,
+// so error responses can be correlated with the logs too.
+func (a API) RequestID(c *fiber.Ctx) error {
+	// the header is a view of the request buffer which fiber reuses, but the id outlives the request
+	// in the logger, span and validator calls, so it is copied.
+	id := utils.CopyString(c.Get(correlation.Header))
+	if !correlation.ValidRequestID(id) {
+		id = correlation.NewRequestID()
+	}
+
+	c.Locals(requestIDLocal, id)
+	c.Set(correlation.Header, id)
+
+	return c.Next()
+}
+
+// requestID returns the request id of request, it is empty when the middleware is not used.
+func requestID(c *fiber.Ctx) string {
+	id, _ := c.Locals(requestIDLocal).(string)
+
+	return id
+}
+
+// correlate returns a copy of api which logs the request id of request, and adds the request id to the span.
+func (a API) correlate(c *fiber.Ctx, span trace.Span) API {
+	id := requestID(c)
+
+	span.SetAttributes(attribute.String("request-id", id))
+	a.Logger = a.Logger.With(zap.String("request-id", id))
+
+	return a
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/credential"
+)
+
+const (
+	// ResolutionVendor means the vendor is given in the token.
+	ResolutionVendor = "vendor"
+	// ResolutionIssuer means the vendor is resolved by walking the vendor resolution.
+	ResolutionIssuer = "issuer"
+	// ResolutionUnknownIssuer means none of the vendors in resolution knows the token issuer.
+	ResolutionUnknownIssuer = "unknown_issuer"
+)
+
+// Authenticator returns the authenticator of vendor, tokens without a known vendor are resolved
+// using the vendor resolution. It returns the first vendor of resolution with ErrUnknownIssuer
+// when none of the vendors knows the token issuer.
+func (a API) Authenticator(vendor, token string) (authenticator.Authenticator, error) {
+	auth, _, err := a.resolve(vendor, token)
+
+	return auth, err
+}
+
+// resolve walks the vendor resolution in order and returns the first vendor which knows the token issuer.
+// vendors which cannot tell their issuers accept every token, and a single vendor resolution
+// is used without checking the issuer, same as the default vendor.
+func (a API) resolve(vendor, token string) (authenticator.Authenticator, string, error) {
+	if auth, ok := a.Authenticators[vendor]; ok {
+		return auth, ResolutionVendor, nil
+	}
+
+	if len(a.VendorResolution) == 0 {
+		return nil, ResolutionUnknownIssuer, authenticator.ErrInvalidAuthenticator
+	}
+
+	if len(a.VendorResolution) == 1 {
+		return a.Authenticators[a.VendorResolution[0]], ResolutionIssuer, nil
+	}
+
+	for _, name := range a.VendorResolution {
+		auth := a.Authenticators[name]
+
+		if knows(auth, token) {
+			return auth, ResolutionIssuer, nil
+		}
+	}
+
+	return a.Authenticators[a.VendorResolution[0]], ResolutionUnknownIssuer, authenticator.ErrUnknownIssuer
+}
+
+// knows checks the authenticator accepts the token, static clients are known by their username.
+func knows(auth authenticator.Authenticator, token string) bool {
+	if staticAuth, ok := auth.(authenticator.StaticAuthenticator); ok {
+		if _, ok := staticAuth.StaticClient(token); ok {
+			return true
+		}
+	}
+
+	issuerAuth, ok := auth.(authenticator.IssuerAuthenticator)
+	if !ok {
+		return true
+	}
+
+	// malformed tokens are not parsed for their issuer, they are rejected by the handlers.
+	if credential.Check(token, 0) != "" {
+		return false
+	}
+
+	return issuerAuth.KnowsIssuer(token)
+}
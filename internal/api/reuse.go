@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/netip"
+
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/reuse"
+	"go.uber.org/zap"
+)
+
+// NewTokenReuse creates the token reuse detectors of vendors which have them.
+func NewTokenReuse(vendors []config.Vendor, logger *zap.Logger) map[string]*reuse.Detector {
+	detectors := make(map[string]*reuse.Detector)
+
+	for _, vendor := range vendors {
+		if vendor.TokenReuse == nil {
+			continue
+		}
+
+		if detector := reuse.New(*vendor.TokenReuse, vendor.Company, logger); detector != nil {
+			detectors[vendor.Company] = detector
+		}
+	}
+
+	return detectors
+}
+
+// observeReuse records the client address of an authenticated token. It only reports the suspected tokens,
+// so their authentication is not changed.
+func (a API) observeReuse(auth authenticator.Authenticator, token string, clientIP netip.Addr) {
+	detector := a.TokenReuse[auth.GetCompany()]
+	if detector == nil {
+		return
+	}
+
+	replayAuth, ok := auth.(authenticator.ReplayAuthenticator)
+	if !ok {
+		return
+	}
+
+	subject, err := replayAuth.Subject(token)
+	if err != nil {
+		return
+	}
+
+	detector.Observe(subject.Issuer, subject.Sub, subject.ID, clientIP)
+}
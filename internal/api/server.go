@@ -0,0 +1,126 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/snapp-incubator/soteria/internal/server"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	encodingGzip     = "gzip"
+	encodingDeflate  = "deflate"
+	encodingIdentity = "identity"
+)
+
+// FiberConfig returns the fiber configuration of server, the body limit of fiber is the largest limit
+// of route groups, so each group is limited by its own middleware.
+func FiberConfig(c server.Config) fiber.Config {
+	// nolint: exhaustruct
+	return fiber.Config{
+		ReadTimeout:    c.ReadTimeout,
+		WriteTimeout:   c.WriteTimeout,
+		IdleTimeout:    c.IdleTimeout,
+		ReadBufferSize: c.MaxHeaderBytes,
+		BodyLimit:      max(server.Limit(c.BodyLimits.Broker), server.Limit(c.BodyLimits.Admin)),
+	}
+}
+
+// BodyLimit rejects the requests which have a larger body than the limit with 413,
+// zero limit uses the fiber default. Compressed bodies are limited by their decompressed size too,
+// they are decompressed at most up to the limit and replaced by their decompressed body,
+// so handlers don't decompress them again.
+func BodyLimit(size int) fiber.Handler {
+	size = server.Limit(size)
+
+	return func(c *fiber.Ctx) error {
+		if len(c.Request().Body()) > size || c.Request().Header.ContentLength() > size {
+			return fiber.ErrRequestEntityTooLarge
+		}
+
+		encoding := string(c.Request().Header.Peek(fiber.HeaderContentEncoding))
+		if encoding == "" || encoding == encodingIdentity {
+			return c.Next()
+		}
+
+		body, err := decompress(encoding, c.Request().Body(), size)
+		if err != nil {
+			return err
+		}
+
+		c.Request().SetBodyRaw(body)
+		c.Request().Header.Del(fiber.HeaderContentEncoding)
+
+		return c.Next()
+	}
+}
+
+// decompress reads the compressed body up to one byte more than the limit, so larger bodies
+// are rejected without decompressing them completely.
+func decompress(encoding string, body []byte, size int) ([]byte, error) {
+	var (
+		r   io.Reader
+		err error
+	)
+
+	switch encoding {
+	case encodingGzip:
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	case encodingDeflate:
+		r, err = zlib.NewReader(bytes.NewReader(body))
+	default:
+		return nil, fiber.ErrUnsupportedMediaType
+	}
+
+	if err != nil {
+		return nil, fiber.ErrBadRequest
+	}
+
+	decompressed, err := io.ReadAll(io.LimitReader(r, int64(size)+1))
+	if err != nil {
+		return nil, fiber.ErrBadRequest
+	}
+
+	if len(decompressed) > size {
+		return nil, fiber.ErrRequestEntityTooLarge
+	}
+
+	return decompressed, nil
+}
+
+// Compress compresses the responses which are larger than the minimum size by gzip when the client
+// accepts it. Request bodies are decompressed by BodyLimit based on their Content-Encoding.
+func Compress(cfg server.Compression) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		if !cfg.Enabled {
+			return nil
+		}
+
+		c.Vary(fiber.HeaderAcceptEncoding)
+
+		body := c.Response().Body()
+
+		if len(body) < cfg.MinSize || len(c.Response().Header.ContentEncoding()) > 0 ||
+			!c.Request().Header.HasAcceptEncoding(encodingGzip) {
+			return nil
+		}
+
+		compressed := fasthttp.AppendGzipBytesLevel(nil, body, fasthttp.CompressBestSpeed)
+		if len(compressed) >= len(body) {
+			return nil
+		}
+
+		c.Response().SetBodyRaw(compressed)
+		c.Set(fiber.HeaderContentEncoding, encodingGzip)
+
+		return nil
+	}
+}
@@ -0,0 +1,175 @@
+package api_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/snapp-incubator/soteria/internal/api"
+	"github.com/snapp-incubator/soteria/internal/server"
+	"github.com/stretchr/testify/require"
+)
+
+type echo struct {
+	Topics []string `json:"topics"`
+}
+
+func echoApp(cfg server.Config) *fiber.App {
+	app := fiber.New(api.FiberConfig(cfg))
+
+	app.Use(api.Compress(cfg.Compression))
+
+	app.Post("/echo", api.BodyLimit(cfg.BodyLimits.Broker), func(c *fiber.Ctx) error {
+		var request echo
+
+		if err := c.BodyParser(&request); err != nil {
+			return fiber.ErrBadRequest
+		}
+
+		return c.JSON(request)
+	})
+
+	return app
+}
+
+func gzipBody(t *testing.T, body []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	w := gzip.NewWriter(&buf)
+
+	_, err := w.Write(body)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	return buf.Bytes()
+}
+
+// nolint: funlen
+func TestCompressedRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	// nolint: exhaustruct
+	app := echoApp(server.Config{
+		Compression: server.Compression{Enabled: true, MinSize: server.DefaultMinCompressSize},
+	})
+
+	topics := make([]string, 0)
+	for range 100 {
+		topics = append(topics, "snapp/driver/DXKgaNQa7N5Y7bo/location")
+	}
+
+	body, err := json.Marshal(echo{Topics: topics})
+	require.NoError(err)
+
+	req := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader(gzipBody(t, body)))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	req.Header.Set(fiber.HeaderContentEncoding, "gzip")
+	req.Header.Set(fiber.HeaderAcceptEncoding, "gzip")
+
+	resp, err := app.Test(req)
+	require.NoError(err)
+
+	defer resp.Body.Close()
+
+	require.Equal(http.StatusOK, resp.StatusCode)
+	require.Equal("gzip", resp.Header.Get(fiber.HeaderContentEncoding))
+	require.Contains(resp.Header.Get(fiber.HeaderVary), fiber.HeaderAcceptEncoding)
+
+	r, err := gzip.NewReader(resp.Body)
+	require.NoError(err)
+
+	decompressed, err := io.ReadAll(r)
+	require.NoError(err)
+	require.JSONEq(string(body), string(decompressed))
+
+	// responses under the minimum size and responses of clients which don't accept gzip are not compressed.
+	for _, tc := range []struct {
+		body   string
+		accept string
+	}{
+		{body: `{"topics":["chat"]}`, accept: "gzip"},
+		{body: string(body), accept: ""},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(tc.body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		req.Header.Set(fiber.HeaderAcceptEncoding, tc.accept)
+
+		resp, err := app.Test(req)
+		require.NoError(err)
+
+		response, err := io.ReadAll(resp.Body)
+		require.NoError(err)
+		require.NoError(resp.Body.Close())
+
+		require.Empty(resp.Header.Get(fiber.HeaderContentEncoding))
+		require.JSONEq(tc.body, string(response))
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	// nolint: exhaustruct
+	app := echoApp(server.Config{
+		BodyLimits: server.BodyLimits{Broker: 64, Admin: 0},
+	})
+
+	small := []byte(`{"topics":["chat"]}`)
+	large := []byte(`{"topics":["` + strings.Repeat("a", 128) + `"]}`)
+
+	for _, tc := range []struct {
+		name     string
+		body     []byte
+		encoding string
+		status   int
+	}{
+		{name: "small", body: small, encoding: "", status: http.StatusOK},
+		{name: "oversized", body: large, encoding: "", status: http.StatusRequestEntityTooLarge},
+		{name: "compressed small", body: gzipBody(t, small), encoding: "gzip", status: http.StatusOK},
+		// compressed bodies are limited by their decompressed size.
+		{name: "compressed oversized", body: gzipBody(t, large), encoding: "gzip", status: http.StatusRequestEntityTooLarge},
+		{name: "corrupted", body: small, encoding: "gzip", status: http.StatusBadRequest},
+		{name: "unsupported encoding", body: small, encoding: "br", status: http.StatusUnsupportedMediaType},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader(tc.body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		if tc.encoding != "" {
+			req.Header.Set(fiber.HeaderContentEncoding, tc.encoding)
+		}
+
+		resp, err := app.Test(req)
+		require.NoError(err, tc.name)
+		require.NoError(resp.Body.Close())
+
+		require.Equal(tc.status, resp.StatusCode, tc.name)
+	}
+}
+
+func TestFiberConfig(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	// nolint: exhaustruct
+	cfg := api.FiberConfig(server.Config{})
+	require.Equal(server.DefaultBodyLimit, cfg.BodyLimit)
+	require.Equal(fiber.DefaultBodyLimit, server.DefaultBodyLimit)
+	require.Zero(cfg.ReadTimeout)
+
+	// nolint: exhaustruct
+	cfg = api.FiberConfig(server.Config{BodyLimits: server.BodyLimits{Broker: 1024, Admin: 8 * 1024 * 1024}})
+	require.Equal(8*1024*1024, cfg.BodyLimit)
+}
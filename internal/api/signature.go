@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/pkg/signature"
+)
+
+const (
+	// vendorLocal is the local which handlers store the company of request authenticator in.
+	vendorLocal = "soteria-vendor"
+	// topicLocal is the local which acl handler stores the requested topic in.
+	topicLocal = "soteria-topic"
+)
+
+// NewSigners creates the response signers of vendors which have signing secret.
+func NewSigners(vendors []config.Vendor) map[string]*signature.Signer {
+	signers := make(map[string]*signature.Signer)
+
+	for _, vendor := range vendors {
+		if vendor.SigningSecret == "" {
+			continue
+		}
+
+		signers[vendor.Company] = signature.NewSigner([]byte(vendor.SigningSecret))
+	}
+
+	return signers
+}
+
+// SignResponse signs the decision of auth and acl handlers for the vendors which have signer.
+func (a API) SignResponse(c *fiber.Ctx) error {
+	if err := c.Next(); err != nil {
+		return err
+	}
+
+	company, _ := c.Locals(vendorLocal).(string)
+
+	signer, ok := a.Signers[company]
+	if !ok {
+		return nil
+	}
+
+	var decision struct {
+		Result string `json:"result"`
+	}
+
+	if err := json.Unmarshal(c.Response().Body(), &decision); err != nil {
+		return nil //nolint: nilerr
+	}
+
+	topic, _ := c.Locals(topicLocal).(string)
+	timestamp := time.Now().Unix()
+
+	c.Set(signature.TimestampHeader, strconv.FormatInt(timestamp, 10))
+	c.Set(signature.Header, signer.Sign(timestamp, c.Path(), decision.Result, topic))
+
+	return nil
+}
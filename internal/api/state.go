@@ -0,0 +1,78 @@
+package api
+
+import (
+	"errors"
+	"sync"
+)
+
+const (
+	VendorStateEnabled  = "enabled"
+	VendorStateDisabled = "disabled"
+
+	// VendorPolicyDeny denies every request of a disabled vendor.
+	VendorPolicyDeny = "deny"
+	// VendorPolicyIgnore ignores every request of a disabled vendor, so EMQ moves to the next authenticator.
+	VendorPolicyIgnore = "ignore"
+)
+
+var (
+	ErrInvalidVendorState  = errors.New("vendor state should be enabled or disabled")
+	ErrInvalidVendorPolicy = errors.New("vendor policy should be deny or ignore")
+)
+
+// VendorState is the runtime state of a vendor which is changed using admin API.
+type VendorState struct {
+	State  string `json:"state"`
+	Policy string `json:"policy,omitempty"`
+}
+
+// Validate checks the state and sets the default policy for disabled vendors.
+func (s *VendorState) Validate() error {
+	switch s.State {
+	case VendorStateEnabled:
+		s.Policy = ""
+	case VendorStateDisabled:
+		if s.Policy == "" {
+			s.Policy = VendorPolicyDeny
+		}
+
+		if s.Policy != VendorPolicyDeny && s.Policy != VendorPolicyIgnore {
+			return ErrInvalidVendorPolicy
+		}
+	default:
+		return ErrInvalidVendorState
+	}
+
+	return nil
+}
+
+// VendorStates holds runtime states of vendors. States are kept by company name
+// and are independent of authenticators, so rebuilding authenticators doesn't reset them.
+type VendorStates struct {
+	states sync.Map
+}
+
+func NewVendorStates() *VendorStates {
+	return &VendorStates{
+		states: sync.Map{},
+	}
+}
+
+// Get returns the vendor state, vendors are enabled by default.
+func (v *VendorStates) Get(company string) VendorState {
+	if v == nil {
+		return VendorState{State: VendorStateEnabled, Policy: ""}
+	}
+
+	state, ok := v.states.Load(company)
+	if !ok {
+		return VendorState{State: VendorStateEnabled, Policy: ""}
+	}
+
+	return state.(VendorState) //nolint: forcetypeassert
+}
+
+// Set changes the vendor state atomically.
+func (v *VendorStates) Set(company string, state VendorState) {
+	v.states.Store(company, state)
+}
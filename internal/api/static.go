@@ -0,0 +1,134 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"go.uber.org/zap"
+)
+
+// staticClient returns the static client of authenticator when request has username without token.
+// static clients are checked before any token parsing or validator calls.
+func staticClient(auth authenticator.Authenticator, rawToken, username string) (authenticator.StaticClient, bool) {
+	if rawToken != "" || username == "" {
+		return authenticator.StaticClient{}, false
+	}
+
+	_, name := splitVendorToken(username)
+
+	if staticAuth, ok := auth.(authenticator.StaticAuthenticator); ok {
+		return staticAuth.StaticClient(name)
+	}
+
+	return authenticator.StaticClient{}, false
+}
+
+// staticAuth authenticates the static client using its password, password is never logged.
+func (a API) staticAuth(
+	c *fiber.Ctx,
+	auth authenticator.Authenticator,
+	client authenticator.StaticClient,
+	request *AuthRequest,
+	source string,
+) error {
+	logger := a.Logger.With(
+		zap.String("username", request.Username),
+		zap.String("authenticator", auth.GetCompany()),
+		zap.String("client-id", request.ClientID),
+		zap.String("source", source),
+		zap.String("will-topic", request.WillTopic),
+		zap.String("auth-method", "static"),
+		zap.String("client-ip", formatIP(ClientIP(c, request.IPAddress, request.PeerHost))),
+	)
+
+	var rules []ACLRule
+
+	err := client.Auth(request.Password)
+	if err == nil && request.WillTopic != "" {
+		if _, aclErr := client.ACL(acl.Pub, request.WillTopic); aclErr != nil {
+			aclErr = willError(request.WillTopic, aclErr)
+
+			if a.willDenies() {
+				err = aclErr
+			} else {
+				rules = a.willRules(request.WillTopic)
+
+				logger.Warn("auth request will topic is not authorized but connection is accepted",
+					zap.Error(aclErr),
+					zap.Bool("will-stripped", rules != nil),
+				)
+			}
+		}
+	}
+
+	a.Metrics.StaticAuth(auth.GetCompany(), source, err)
+
+	if err != nil {
+		logger.Warn("static client auth request is not authorized", zap.Error(err))
+
+		code := CodeInvalidToken
+		if !errors.Is(err, authenticator.ErrIncorrectPassword) {
+			code = CodeAccessDenied
+		}
+
+		return a.authDenied(c, auth.GetCompany(), code)
+	}
+
+	logger.Info("static client auth ok")
+
+	return c.Status(http.StatusOK).JSON(AuthResponse{
+		Result:      "allow",
+		IsSuperuser: false,
+		ExpireAt:    0,
+		ClientAttrs: nil,
+		Code:        "",
+		ACL:         rules,
+	})
+}
+
+// staticACL authorizes the static client using its topic patterns, topic is without mountpoint.
+func (a API) staticACL(
+	c *fiber.Ctx,
+	auth authenticator.Authenticator,
+	client authenticator.StaticClient,
+	request *ACLRequest,
+	topic string,
+	access acl.AccessType,
+) error {
+	_, err := client.ACL(access, topic)
+
+	a.Metrics.StaticACL(auth.GetCompany(), err)
+
+	if err != nil {
+		a.Logger.Warn("static client acl request is not authorized",
+			zap.Error(err),
+			zap.String("access", request.Action),
+			zap.String("topic", request.Topic),
+			zap.String("username", request.Username),
+			zap.String("authenticator", auth.GetCompany()),
+			zap.String("client-ip", formatIP(ClientIP(c, request.IPAddress, request.PeerHost))),
+		)
+
+		var tnaErr authenticator.TopicNotAllowedError
+		if errors.As(err, &tnaErr) {
+			return c.Status(http.StatusOK).JSON(topicNotAllowed(tnaErr))
+		}
+
+		return c.Status(http.StatusOK).JSON(ACLResponse{
+			Result:          "deny",
+			Reason:          "",
+			GrantedAccesses: nil,
+			TopicAttrs:      nil,
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(ACLResponse{
+		Result:          "allow",
+		Reason:          "",
+		GrantedAccesses: nil,
+		TopicAttrs:      nil,
+	})
+}
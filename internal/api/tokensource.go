@@ -0,0 +1,101 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/credential"
+)
+
+const (
+	// TokenSourceToken means the token is taken from the token field of HTTP requests.
+	TokenSourceToken = "token"
+	// TokenSourceUsername means the token is taken from the MQTT username.
+	TokenSourceUsername = "username"
+	// TokenSourcePassword means the token is taken from the MQTT password.
+	TokenSourcePassword = "password"
+	// TokenSourceEither means the token is taken from the MQTT username or password which looks like a JWT.
+	TokenSourceEither = "either"
+)
+
+var ErrInvalidTokenSource = errors.New("token source must be password, username or either")
+
+// NewTokenSources returns the token sources of vendors which have them.
+func NewTokenSources(vendors []config.Vendor) (map[string]string, error) {
+	sources := make(map[string]string)
+
+	for _, vendor := range vendors {
+		switch vendor.TokenSource {
+		case "":
+			continue
+		case TokenSourceUsername, TokenSourcePassword, TokenSourceEither:
+			sources[vendor.Company] = vendor.TokenSource
+		default:
+			return nil, fmt.Errorf("vendor %s has token source %q: %w", vendor.Company, vendor.TokenSource, ErrInvalidTokenSource)
+		}
+	}
+
+	return sources, nil
+}
+
+// SelectToken returns the request field which has the token based on the token source with its name.
+// The token field of HTTP requests is always used when it is set, and empty token source takes
+// the username and then the password when username is empty. Either token source prefers
+// the field which looks like a JWT and falls back to the empty token source.
+func SelectToken(source, rawToken, username, password string) (string, string) {
+	if rawToken != "" {
+		return rawToken, TokenSourceToken
+	}
+
+	switch source {
+	case TokenSourceUsername:
+		return username, TokenSourceUsername
+	case TokenSourcePassword:
+		return password, TokenSourcePassword
+	case TokenSourceEither:
+		for _, field := range []struct{ value, name string }{
+			{value: username, name: TokenSourceUsername},
+			{value: password, name: TokenSourcePassword},
+		} {
+			if _, token := splitVendorToken(field.value); credential.Check(token, 0) == "" {
+				return field.value, field.name
+			}
+		}
+	}
+
+	if username != "" {
+		return username, TokenSourceUsername
+	}
+
+	return password, TokenSourcePassword
+}
+
+// credentials resolves the authenticator of request and returns it with the token of request and its resolution.
+// The request is resolved by the field which looks like a JWT, and then the token is taken from
+// the token source of resolved vendor, which is resolved again when it is a different field.
+func (a API) credentials(
+	endpoint, rawToken, username, password string,
+) (authenticator.Authenticator, string, string, error) {
+	raw, source := SelectToken(TokenSourceEither, rawToken, username, password)
+	vendor, token := splitVendorToken(raw)
+
+	auth, resolution, err := a.resolve(vendor, token)
+
+	if auth != nil {
+		if sourced, field := SelectToken(a.TokenSources[auth.GetCompany()], rawToken, username, password); sourced != raw {
+			source = field
+			vendor, token = splitVendorToken(sourced)
+
+			auth, resolution, err = a.resolve(vendor, token)
+		}
+	}
+
+	if auth != nil {
+		a.Metrics.VendorResolved(auth.GetCompany(), endpoint, resolution)
+		a.Metrics.TokenSource(auth.GetCompany(), endpoint, source)
+	}
+
+	return auth, token, resolution, err
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/snapp-incubator/soteria/pkg/correlation"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// requestCarrier carries the trace context of the request headers.
+type requestCarrier struct {
+	c *fiber.Ctx
+}
+
+var _ propagation.TextMapCarrier = requestCarrier{c: nil}
+
+func (r requestCarrier) Get(key string) string {
+	return r.c.Get(key)
+}
+
+func (r requestCarrier) Set(key, value string) {
+	r.c.Request().Header.Set(key, value)
+}
+
+func (r requestCarrier) Keys() []string {
+	keys := make([]string, 0)
+
+	r.c.Request().Header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+
+	return keys
+}
+
+// traceContext returns a detached context with the trace context which the broker injects into
+// the request headers using the configured propagator, so the spans of request join the broker trace.
+// The context carries the request id too, so it is sent to the validator.
+func traceContext(c *fiber.Ctx) context.Context {
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), requestCarrier{c: c})
+
+	if id := requestID(c); id != "" {
+		ctx = correlation.WithRequestID(ctx, id)
+	}
+
+	return ctx
+}
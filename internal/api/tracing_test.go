@@ -0,0 +1,167 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/api"
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/metric"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/snapp-incubator/soteria/pkg/correlation"
+	"github.com/snapp-incubator/soteria/pkg/validator"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+)
+
+const (
+	brokerTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	brokerSpanID  = "00f067aa0ba902b7"
+	// validatorToken is shaped like a JWT, so it passes the credential checks and reaches the validator.
+	validatorToken = "header.payload.signature"
+)
+
+// nolint: funlen
+func TestTraceContext(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("")
+
+	traceparent := make(chan string, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		traceparent <- req.Header.Get("traceparent")
+
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := config.SnappVendor()
+
+	hid, err := topics.NewHashIDManager(cfg.HashIDMap)
+	require.NoError(err)
+
+	// nolint: exhaustruct
+	a := newTestAPI(authenticator.AutoAuthenticator{
+		Validator:          validator.New(server.URL, time.Second),
+		AllowedAccessTypes: []acl.AccessType{acl.Pub, acl.Sub},
+		Tracer:             tracer,
+		Company:            "snapp",
+		Metrics:            metric.NewAutoAuthenticatorMetrics(prometheus.DefaultRegisterer),
+		TopicManager: topics.NewTopicManager(
+			cfg.Topics, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop(),
+		),
+		JWTConfig: cfg.Jwt,
+	})
+	a.Tracer = tracer
+
+	app := fiber.New()
+	app.Post("/v2/auth", a.Authv2)
+
+	// nolint: exhaustruct
+	req := jsonRequest(t, http.MethodPost, "/v2/auth", api.AuthRequest{
+		Token: validatorToken,
+	})
+	req.Header.Add("traceparent", "00-"+brokerTraceID+"-"+brokerSpanID+"-01")
+
+	send(t, app, req)
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	handler, ok := spans["api.v2.auth"]
+	require.True(ok)
+	require.Equal(brokerTraceID, handler.SpanContext().TraceID().String())
+	require.Equal(brokerSpanID, handler.Parent().SpanID().String())
+	require.True(handler.Parent().IsRemote())
+	require.Equal(trace.SpanKindServer, handler.SpanKind())
+
+	auth, ok := spans["auto-authenticator.auth"]
+	require.True(ok)
+	require.Equal(brokerTraceID, auth.SpanContext().TraceID().String())
+	require.Equal(handler.SpanContext().SpanID(), auth.Parent().SpanID())
+
+	require.Equal("00-"+brokerTraceID+"-"+auth.SpanContext().SpanID().String()+"-01", <-traceparent)
+}
+
+// nolint: funlen
+func TestRequestID(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	requestID := make(chan string, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		requestID <- req.Header.Get(correlation.Header)
+
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := config.SnappVendor()
+
+	hid, err := topics.NewHashIDManager(cfg.HashIDMap)
+	require.NoError(err)
+
+	// nolint: exhaustruct
+	a := newTestAPI(authenticator.AutoAuthenticator{
+		Validator:          validator.New(server.URL, time.Second),
+		AllowedAccessTypes: []acl.AccessType{acl.Pub, acl.Sub},
+		Tracer:             noop.NewTracerProvider().Tracer(""),
+		Company:            "snapp",
+		Metrics:            metric.NewAutoAuthenticatorMetrics(prometheus.DefaultRegisterer),
+		TopicManager: topics.NewTopicManager(
+			cfg.Topics, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop(),
+		),
+		JWTConfig: cfg.Jwt,
+	})
+
+	app := fiber.New()
+	app.Use(a.RequestID)
+	app.Post("/v2/auth", a.Authv2)
+
+	// nolint: exhaustruct
+	request := api.AuthRequest{
+		Token: validatorToken,
+	}
+
+	// incoming request id is honored and sent to the validator.
+	req := jsonRequest(t, http.MethodPost, "/v2/auth", request)
+	req.Header.Add(correlation.Header, "emq-1")
+
+	resp := send(t, app, req)
+
+	require.Equal("emq-1", resp.Header.Get(correlation.Header))
+	require.Equal("emq-1", <-requestID)
+
+	// invalid request ids are replaced.
+	req = jsonRequest(t, http.MethodPost, "/v2/auth", request)
+	req.Header.Add(correlation.Header, "emq 1")
+
+	resp = send(t, app, req)
+
+	generated := resp.Header.Get(correlation.Header)
+	require.NotEqual("emq 1", generated)
+	require.True(correlation.ValidRequestID(generated))
+	require.Equal(generated, <-requestID)
+}
@@ -0,0 +1,196 @@
+// Package audit keeps the recent decisions in memory, so they can be listed by admin API without
+// searching the logs of every instance. Entries never have the token or subject of their request,
+// subjects are only kept as a keyed hash, its key is random for each process, so the hashes cannot be
+// reversed by hashing the known subjects outside of the process.
+package audit
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	ResultAllow = "allow"
+	ResultDeny  = "deny"
+
+	// DefaultLimit is the number of listed entries when the limit is not given.
+	DefaultLimit = 100
+
+	// shards is the number of independently locked rings, entries are spread over them
+	// in round robin so concurrent requests rarely wait for each other.
+	shards = 16
+	// subHashLength is the length of subject hashes in hex.
+	subHashLength = 16
+)
+
+type Config struct {
+	// Size is the number of the kept decisions, zero disables the buffer.
+	Size int `json:"size,omitempty" koanf:"size"`
+}
+
+// Entry is a decision without the secrets of its request.
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Vendor    string    `json:"vendor"`
+	Access    string    `json:"access"`
+	TopicType string    `json:"topic_type,omitempty"`
+	Result    string    `json:"result"`
+	// Reason is the metric status of denials.
+	Reason string `json:"reason,omitempty"`
+	// SubHash is the truncated keyed hash of token subject, it is empty when subject is not known.
+	SubHash   string  `json:"sub_hash,omitempty"`
+	LatencyMS float64 `json:"latency_ms"`
+}
+
+// Filter selects the listed entries, empty fields match every entry.
+type Filter struct {
+	Vendor    string
+	Result    string
+	TopicType string
+	// Sub is the subject of entries, it is compared by its hash.
+	Sub   string
+	Limit int
+}
+
+func (f Filter) matches(e Entry, subHash string) bool {
+	return (f.Vendor == "" || f.Vendor == e.Vendor) &&
+		(f.Result == "" || f.Result == e.Result) &&
+		(f.TopicType == "" || f.TopicType == e.TopicType) &&
+		(subHash == "" || subHash == e.SubHash)
+}
+
+type shard struct {
+	lock    sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+}
+
+// Buffer is safe for concurrent use and nil buffer doesn't keep anything.
+type Buffer struct {
+	shards []*shard
+	next   atomic.Uint64
+	size   int
+	// key is the random key of subject hashes.
+	key []byte
+}
+
+// New returns the buffer, it is nil when the buffer is disabled.
+func New(cfg Config) *Buffer {
+	if cfg.Size <= 0 {
+		return nil
+	}
+
+	count := min(shards, cfg.Size)
+
+	b := &Buffer{
+		shards: make([]*shard, count),
+		next:   atomic.Uint64{},
+		size:   cfg.Size,
+		key:    make([]byte, sha256.Size),
+	}
+
+	// rand.Read never returns an error.
+	_, _ = rand.Read(b.key)
+
+	for i := range b.shards {
+		// the first shards keep the remainder, so their sum is the size.
+		capacity := cfg.Size / count
+		if i < cfg.Size%count {
+			capacity++
+		}
+
+		b.shards[i] = &shard{
+			lock:    sync.Mutex{},
+			entries: make([]Entry, capacity),
+			next:    0,
+			full:    false,
+		}
+	}
+
+	return b
+}
+
+// Add keeps the entry instead of the oldest entry of its shard.
+func (b *Buffer) Add(e Entry) {
+	if b == nil {
+		return
+	}
+
+	s := b.shards[(b.next.Add(1)-1)%uint64(len(b.shards))]
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.entries[s.next] = e
+	s.next++
+
+	if s.next == len(s.entries) {
+		s.next = 0
+		s.full = true
+	}
+}
+
+// List returns the newest entries which match the filter, newest first. Limit is DefaultLimit
+// when it is not positive and it is capped by the size of buffer.
+func (b *Buffer) List(f Filter) []Entry {
+	if b == nil {
+		return []Entry{}
+	}
+
+	limit := f.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	limit = min(limit, b.size)
+
+	entries := make([]Entry, 0, b.size)
+	subHash := b.SubHash(f.Sub)
+
+	for _, s := range b.shards {
+		s.lock.Lock()
+
+		count := s.next
+		if s.full {
+			count = len(s.entries)
+		}
+
+		for _, e := range s.entries[:count] {
+			if f.matches(e, subHash) {
+				entries = append(entries, e)
+			}
+		}
+
+		s.lock.Unlock()
+	}
+
+	slices.SortStableFunc(entries, func(a, b Entry) int {
+		return b.Time.Compare(a.Time)
+	})
+
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	return entries
+}
+
+// SubHash returns the truncated HMAC-SHA256 of subject by the key of buffer, so entries of a subject can be
+// found without keeping it. It is empty for empty subjects and nil buffer.
+func (b *Buffer) SubHash(sub string) string {
+	if b == nil || sub == "" {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, b.key)
+	_, _ = mac.Write([]byte(sub))
+
+	return hex.EncodeToString(mac.Sum(nil))[:subHashLength]
+}
@@ -0,0 +1,118 @@
+package audit_test
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/snapp-incubator/soteria/internal/audit"
+	"github.com/stretchr/testify/require"
+)
+
+func entry(b *audit.Buffer, i int, result, topicType string) audit.Entry {
+	return audit.Entry{
+		Time:      time.Unix(int64(i), 0),
+		Vendor:    "snapp",
+		Access:    "subscribe",
+		TopicType: topicType,
+		Result:    result,
+		Reason:    "",
+		SubHash:   b.SubHash(strconv.Itoa(i)),
+		LatencyMS: 1,
+	}
+}
+
+func TestBuffer(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	b := audit.New(audit.Config{Size: 32})
+
+	for i := range 64 {
+		result := audit.ResultAllow
+		if i%2 == 0 {
+			result = audit.ResultDeny
+		}
+
+		b.Add(entry(b, i, result, "driver_location"))
+	}
+
+	// nolint: exhaustruct
+	entries := b.List(audit.Filter{Limit: 100})
+	require.Len(entries, 32)
+	require.Equal(time.Unix(63, 0), entries[0].Time)
+	require.Equal(time.Unix(32, 0), entries[31].Time)
+
+	// nolint: exhaustruct
+	entries = b.List(audit.Filter{Result: audit.ResultDeny, TopicType: "driver_location", Limit: 3})
+	require.Len(entries, 3)
+	require.Equal(time.Unix(62, 0), entries[0].Time)
+	require.Equal(time.Unix(58, 0), entries[2].Time)
+
+	// nolint: exhaustruct
+	require.Empty(b.List(audit.Filter{TopicType: "chat"}))
+
+	// nolint: exhaustruct
+	entries = b.List(audit.Filter{Sub: "42"})
+	require.Len(entries, 1)
+	require.Equal(time.Unix(42, 0), entries[0].Time)
+}
+
+func TestBufferConcurrent(t *testing.T) {
+	t.Parallel()
+
+	b := audit.New(audit.Config{Size: 10})
+
+	var wg sync.WaitGroup
+
+	for i := range 8 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := range 100 {
+				b.Add(entry(b, i*100+j, audit.ResultAllow, ""))
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// nolint: exhaustruct
+	require.Len(t, b.List(audit.Filter{}), 10)
+}
+
+func TestDisabled(t *testing.T) {
+	t.Parallel()
+
+	b := audit.New(audit.Config{Size: 0})
+	require.Nil(t, b)
+
+	b.Add(entry(b, 1, audit.ResultAllow, ""))
+
+	// nolint: exhaustruct
+	require.Empty(t, b.List(audit.Filter{}))
+}
+
+func TestSubHash(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	b := audit.New(audit.Config{Size: 1})
+
+	require.Len(b.SubHash("DXKgaNQa7N5Y7bo"), 16)
+	require.Equal(b.SubHash("DXKgaNQa7N5Y7bo"), b.SubHash("DXKgaNQa7N5Y7bo"))
+	require.NotEqual(b.SubHash("DXKgaNQa7N5Y7bo"), b.SubHash("DXKgaNQa7N5Y7bp"))
+	require.Empty(b.SubHash(""))
+
+	// hashes are keyed by a random key of each buffer, so they cannot be computed outside of it.
+	require.NotEqual(b.SubHash("DXKgaNQa7N5Y7bo"), audit.New(audit.Config{Size: 1}).SubHash("DXKgaNQa7N5Y7bo"))
+
+	var disabled *audit.Buffer
+
+	require.Empty(disabled.SubHash("DXKgaNQa7N5Y7bo"))
+}
@@ -0,0 +1,134 @@
+package authenticator
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/metric"
+	"github.com/snapp-incubator/soteria/pkg/strconv"
+)
+
+const (
+	// BrokerSecretHeader has the broker secret of acl requests which are sent by the broker.
+	BrokerSecretHeader = "X-Soteria-Broker-Secret"
+
+	// ACLVerifiedByKey means acl token is verified by the keys of vendor.
+	ACLVerifiedByKey = "key"
+	// ACLVerifiedByAuth means acl token is validated recently on authentication.
+	ACLVerifiedByAuth = "auth"
+	// ACLVerifiedByBroker means acl token is not verified but the request has the broker secret.
+	ACLVerifiedByBroker = "broker"
+	// ACLUnverified means acl token is not verified and it is allowed because verification is not enforced.
+	ACLUnverified = "unverified"
+	// ACLRejected means acl token is not verified and it is denied because verification is enforced.
+	ACLRejected = "rejected"
+)
+
+type brokerSecretKey struct{}
+
+// WithBrokerSecret attaches the broker secret of request to the context.
+func WithBrokerSecret(ctx context.Context, secret string) context.Context {
+	return context.WithValue(ctx, brokerSecretKey{}, secret)
+}
+
+// brokerSecret returns the broker secret of request, it is empty when request has no broker secret.
+func brokerSecret(ctx context.Context) string {
+	secret, _ := ctx.Value(brokerSecretKey{}).(string)
+
+	return secret
+}
+
+// ACLVerifier verifies the tokens of acl requests of auto vendors, which are parsed unverified otherwise.
+// Tokens are verified by the keys of vendor, then by their recent validation on authentication and
+// at the end by the broker secret of request.
+type ACLVerifier struct {
+	Company          string
+	JWTConfig        config.JWT
+	Keys             map[string]any
+	VerificationKeys map[string][]VerificationKey
+	Parser           *jwt.Parser
+	// Validations have the tokens which are validated on authentication, tokens are only found
+	// when the validator cache is enabled.
+	Validations  *Validations
+	BrokerSecret string
+	// Enforce denies the unverified tokens, otherwise they are only counted.
+	Enforce bool
+	Metrics *metric.AutoAuthenticatorMetrics
+}
+
+// Verify checks the token of acl request is verified and counts it by its verification,
+// it returns ErrUnverifiedToken for unverified tokens when verification is enforced and
+// MalformedCredentialError for the tokens which cannot be decoded, like their signature.
+// Nil verifier doesn't verify the tokens.
+func (v *ACLVerifier) Verify(ctx context.Context, tokenString string, claims jwt.MapClaims) error {
+	if v == nil {
+		return nil
+	}
+
+	result, err := v.verify(ctx, tokenString, claims)
+
+	if result == ACLUnverified && v.Enforce {
+		result = ACLRejected
+	}
+
+	v.Metrics.ACLVerification(v.Company, result)
+
+	if err != nil {
+		return err
+	}
+
+	if result == ACLRejected {
+		return ErrUnverifiedToken
+	}
+
+	return nil
+}
+
+func (v *ACLVerifier) verify(ctx context.Context, tokenString string, claims jwt.MapClaims) (string, error) {
+	verified, err := v.verifiedByKey(tokenString, strconv.ToString(claims[v.JWTConfig.IssName]))
+	if err != nil {
+		return ACLRejected, err
+	}
+
+	if verified {
+		return ACLVerifiedByKey, nil
+	}
+
+	if v.Validations.Validated(tokenString) {
+		return ACLVerifiedByAuth, nil
+	}
+
+	if v.BrokerSecret != "" &&
+		subtle.ConstantTimeCompare([]byte(brokerSecret(ctx)), []byte(v.BrokerSecret)) == 1 {
+		return ACLVerifiedByBroker, nil
+	}
+
+	return ACLUnverified, nil
+}
+
+// verifiedByKey verifies the token by the key of its issuer, tokens of issuers without keys are not verified.
+// It returns MalformedCredentialError for the tokens which cannot be decoded.
+func (v *ACLVerifier) verifiedByKey(tokenString, issuer string) (bool, error) {
+	if len(v.Keys) == 0 && len(v.VerificationKeys) == 0 {
+		return false, nil
+	}
+
+	_, err := v.Parser.Parse(tokenString, func(token *jwt.Token) (any, error) {
+		if method := v.JWTConfig.IssuerSigningMethod(issuer); token.Method.Alg() != method {
+			return nil, fmt.Errorf("%w: issuer %s requires %s", ErrInvalidSigningMethod, issuer, method)
+		}
+
+		key, _, err := verificationKey(token, issuer, v.Keys, v.VerificationKeys)
+
+		return key, err
+	})
+	if errors.Is(err, jwt.ErrTokenMalformed) {
+		return false, malformed(tokenString, err)
+	}
+
+	return err == nil, nil
+}
@@ -0,0 +1,94 @@
+package authenticator_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/metric"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// nolint: funlen
+func TestACLVerifier(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	key := []byte("secret")
+	cfg := config.SnappVendor()
+	cfg.Jwt.SigningMethod = jwt.SigningMethodHS512.Alg()
+	metrics := metric.NewAutoAuthenticatorMetrics(prometheus.DefaultRegisterer)
+
+	// nolint: exhaustruct
+	validations := authenticator.NewValidations("snapp", config.Validator{CacheTTL: time.Minute}, metrics)
+
+	verifier := &authenticator.ACLVerifier{
+		Company:          "snapp",
+		JWTConfig:        cfg.Jwt,
+		Keys:             map[string]any{topics.DriverIss: key},
+		VerificationKeys: map[string][]authenticator.VerificationKey{},
+		Parser:           jwt.NewParser(jwt.WithValidMethods(cfg.Jwt.SigningMethods())),
+		Validations:      validations,
+		BrokerSecret:     "broker",
+		Enforce:          true,
+		Metrics:          metrics,
+	}
+
+	claims := func(token string) jwt.MapClaims {
+		var claims jwt.MapClaims
+
+		_, _, err := jwt.NewParser().ParseUnverified(token, &claims)
+		require.NoError(err)
+
+		return claims
+	}
+
+	ctx := context.Background()
+
+	signed, err := testutil.DriverToken(jwt.SigningMethodHS512, key)
+	require.NoError(err)
+	require.NoError(verifier.Verify(ctx, signed, claims(signed)))
+
+	forged, err := testutil.DriverToken(jwt.SigningMethodHS512, []byte("forged"))
+	require.NoError(err)
+	require.ErrorIs(verifier.Verify(ctx, forged, claims(forged)), authenticator.ErrUnverifiedToken)
+
+	// requests of broker have its secret.
+	require.NoError(verifier.Verify(authenticator.WithBrokerSecret(ctx, "broker"), forged, claims(forged)))
+	require.ErrorIs(
+		verifier.Verify(authenticator.WithBrokerSecret(ctx, "guess"), forged, claims(forged)),
+		authenticator.ErrUnverifiedToken,
+	)
+
+	// tokens of issuers without keys are verified by their validation on auth.
+	passenger, err := testutil.PassengerToken(jwt.SigningMethodHS512, []byte("validator"))
+	require.NoError(err)
+	require.ErrorIs(verifier.Verify(ctx, passenger, claims(passenger)), authenticator.ErrUnverifiedToken)
+
+	require.NoError(validations.Validate(ctx, passenger, func(context.Context) error { return nil }))
+	require.NoError(verifier.Verify(ctx, passenger, claims(passenger)))
+
+	// unverified tokens are allowed when verification is not enforced.
+	verifier.Enforce = false
+	require.NoError(verifier.Verify(ctx, forged, claims(forged)))
+
+	// tokens whose signature cannot be decoded are malformed even when verification is not enforced.
+	malformed := signed[:strings.LastIndex(signed, ".")+1] + "!!!"
+
+	var mcErr authenticator.MalformedCredentialError
+
+	require.ErrorAs(verifier.Verify(ctx, malformed, claims(malformed)), &mcErr)
+	require.Equal(len(malformed), mcErr.Length)
+
+	var none *authenticator.ACLVerifier
+
+	require.NoError(none.Verify(ctx, forged, claims(forged)))
+}
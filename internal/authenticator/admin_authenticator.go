@@ -50,6 +50,7 @@ func (a AdminAuthenticator) ACL(
 	_ acl.AccessType,
 	_ string,
 	_ string,
+	_ int,
 ) (bool, error) {
 	return true, nil
 }
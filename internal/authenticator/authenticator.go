@@ -2,10 +2,20 @@ package authenticator
 
 import (
 	"context"
+	"slices"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/snapp-incubator/soteria/internal/claimguard"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/topics"
 	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/snapp-incubator/soteria/pkg/strconv"
 )
 
+// Authenticator is the interface of every vendor type, handlers and the builder only depend on it and
+// new types are added by Register. ACL keeps its boolean and the payload size, which the acl handler
+// and payload limits use, and IsSuperuser is a property of the vendor which doesn't depend on the claims.
 type Authenticator interface {
 	// Auth check user authentication by checking the user's token.
 	// it returns error in case of any issue with the user token.
@@ -15,11 +25,14 @@ type Authenticator interface {
 	) error
 
 	// ACL check a user access to a topic.
+	// payloadSize is the size of the published message in bytes and
+	// it is zero when broker doesn't provide it.
 	ACL(
 		ctx context.Context,
 		accessType acl.AccessType,
 		tokenString string,
 		topic string,
+		payloadSize int,
 	) (bool, error)
 
 	// ValidateAccessType checks access type for specific topic
@@ -32,3 +45,115 @@ type Authenticator interface {
 	// and shows user as superuser which disables the ACL.
 	IsSuperuser() bool
 }
+
+// ClientAttrs are the attributes of an authenticated client which EMQ attaches to its session.
+type ClientAttrs struct {
+	Entity string `json:"entity"`
+	HashID string `json:"hash_id"`
+	Vendor string `json:"vendor"`
+}
+
+// AttrsAuthenticator is implemented by authenticators which can return client attributes
+// of their users on authentication.
+type AttrsAuthenticator interface {
+	// AuthWithAttrs is the same as Auth but it also returns the client attributes from the verified token.
+	// attributes are nil when the vendor doesn't emit them.
+	AuthWithAttrs(
+		ctx context.Context,
+		tokenString string,
+	) (*ClientAttrs, error)
+}
+
+// ExpiryAuthenticator is implemented by authenticators which can tell the expiry of their tokens,
+// so EMQ can disconnect the clients when their tokens expire.
+type ExpiryAuthenticator interface {
+	// TokenExpiry returns the exp claim of the authenticated token, it is false for tokens without exp claim.
+	TokenExpiry(tokenString string) (time.Time, bool)
+}
+
+// TopicAttrs are the matched topic type and entity of an allowed ACL request which EMQ can use in its rules.
+type TopicAttrs struct {
+	TopicType string `json:"topic_type"`
+	Entity    string `json:"entity"`
+}
+
+// TopicAuthenticator is implemented by authenticators which can return the matched topic of allowed ACL requests.
+type TopicAuthenticator interface {
+	// ACLWithTopic is the same as ACL but it also returns the topic attributes of the allowed access.
+	// attributes are nil when the vendor doesn't emit them.
+	ACLWithTopic(
+		ctx context.Context,
+		accessType acl.AccessType,
+		tokenString string,
+		topic string,
+		payloadSize int,
+	) (*TopicAttrs, error)
+}
+
+// IssuerAuthenticator is implemented by authenticators which can tell whether a token is issued
+// by one of their issuers, so tokens without vendor can be routed to a vendor by their issuer.
+type IssuerAuthenticator interface {
+	// KnowsIssuer checks the issuer claim of the unverified token is one of the vendor issuers.
+	KnowsIssuer(tokenString string) bool
+}
+
+// checkAccessType checks the access type using the allowed access types of topic when it has them
+// and the allowed access types of vendor otherwise, the error names the level which rejected it.
+func checkAccessType(vendor []acl.AccessType, topicTemplate *topics.Template, accessType acl.AccessType) error {
+	allowed, level := vendor, AccessTypeLevelVendor
+
+	if topicTemplate.AllowedAccessTypes != nil {
+		allowed, level = topicTemplate.AllowedAccessTypes, AccessTypeLevelTopic
+	}
+
+	if slices.Contains(allowed, accessType) {
+		return nil
+	}
+
+	return InvalidAccessTypeError{
+		Level:      level,
+		AccessType: accessType,
+		TopicType:  topicTemplate.Type,
+	}
+}
+
+// guarded returns the claim names of vendor which claim guard looks for.
+func guarded(cfg config.JWT) claimguard.Claims {
+	return claimguard.Claims{IssName: cfg.IssName, SubName: cfg.SubName}
+}
+
+// clientAttrs creates client attributes from the verified claims, entity is resolved from the issuer
+// and its entity rules and hash-id is the subject as it is in the token.
+func clientAttrs(claims jwt.MapClaims, cfg config.JWT, manager *topics.Manager, company string) *ClientAttrs {
+	return &ClientAttrs{
+		Entity: manager.Entity(strconv.ToString(claims[cfg.IssName]), claims),
+		HashID: strconv.ToString(claims[cfg.SubName]),
+		Vendor: company,
+	}
+}
+
+// tokenExpiry returns the exp claim of the unverified token, tokens are verified before their expiry is used.
+func tokenExpiry(parser *jwt.Parser, tokenString string) (time.Time, bool) {
+	var claims jwt.MapClaims
+
+	if _, _, err := parser.ParseUnverified(tokenString, &claims); err != nil {
+		return time.Time{}, false
+	}
+
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return time.Time{}, false
+	}
+
+	return exp.Time, true
+}
+
+// qualifier returns the access qualifier from claims, it is empty when qualifier claim is not configured
+// or token doesn't have it.
+func qualifier(claims jwt.MapClaims, name string) string {
+	if name == "" || claims[name] == nil {
+		return ""
+	}
+
+	return strconv.ToString(claims[name])
+}
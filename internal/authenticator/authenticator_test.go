@@ -5,9 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"time"
+	"sync"
+	"testing"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/snapp-incubator/soteria/pkg/testutil"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -56,76 +59,87 @@ var (
 	ErrPublicKeyNotFound  = errors.New("invalid user, public key not found")
 )
 
-func getPublicKey(u string) (*rsa.PublicKey, error) {
-	var fileName string
-
-	switch u {
-	case "1":
-		fileName = "../../test/snapp-1.pem"
-	case "0":
-		fileName = "../../test/snapp-0.pem"
-	case "admin":
-		fileName = "../../test/snapp-admin.pem"
-	default:
-		return nil, ErrPublicKeyNotFound
+// keys are generated once per user in memory, so public and private keys of a user
+// are always a pair, and tests don't depend on the committed fixtures.
+// nolint: gochecknoglobals
+var keys = sync.OnceValues(func() (map[string]*rsa.PrivateKey, error) {
+	keys := make(map[string]*rsa.PrivateKey)
+
+	for _, u := range []string{"0", "1", "admin"} {
+		key, err := testutil.RSAKey()
+		if err != nil {
+			return nil, fmt.Errorf("generating key for %s failed %w", u, err)
+		}
+
+		keys[u] = key
 	}
 
-	pem, err := os.ReadFile(fileName)
+	return keys, nil
+})
+
+func getPublicKey(u string) (*rsa.PublicKey, error) {
+	keys, err := keys()
 	if err != nil {
-		return nil, fmt.Errorf("reading public key failed %w", err)
+		return nil, err
 	}
 
-	publicKey, err := jwt.ParseRSAPublicKeyFromPEM(pem)
-	if err != nil {
-		return nil, fmt.Errorf("paring public key failed %w", err)
+	key, ok := keys[u]
+	if !ok {
+		return nil, ErrPublicKeyNotFound
 	}
 
-	return publicKey, nil
+	return &key.PublicKey, nil
 }
 
 func getPrivateKey(u string) (*rsa.PrivateKey, error) {
-	var fileName string
-
-	switch u {
-	case "0":
-		fileName = "../../test/snapp-0.private.pem"
-	case "1":
-		fileName = "../../test/snapp-1.private.pem"
-	case "admin":
-		fileName = "../../test/snapp-admin.private.pem"
-	default:
-		return nil, ErrPrivateKeyNotFound
-	}
-
-	pem, err := os.ReadFile(fileName)
+	keys, err := keys()
 	if err != nil {
-		return nil, fmt.Errorf("reading private key failed %w", err)
+		return nil, err
 	}
 
-	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
-	if err != nil {
-		return nil, fmt.Errorf("paring private key failed %w", err)
+	key, ok := keys[u]
+	if !ok {
+		return nil, ErrPrivateKeyNotFound
 	}
 
-	return privateKey, nil
+	return key, nil
 }
 
 func getSampleToken(issuer string, key *rsa.PrivateKey) (string, error) {
-	exp := time.Now().Add(time.Hour * 24 * 365 * 10)
-	sub := "DXKgaNQa7N5Y7bo"
-
 	// nolint: exhaustruct
-	claims := jwt.RegisteredClaims{
-		ExpiresAt: jwt.NewNumericDate(exp),
-		Issuer:    issuer,
-		Subject:   sub,
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	return testutil.Token(jwt.SigningMethodRS256, key, testutil.Claims{
+		Issuer:  issuer,
+		Subject: testutil.DefaultSubject,
+	})
+}
 
-	tokenString, err := token.SignedString(key)
-	if err != nil {
-		return "", fmt.Errorf("cannot generate a signed string %w", err)
-	}
+// TestFixtureKeys guards parsing of the PEM keys which are committed into the repository.
+func TestFixtureKeys(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	for _, u := range []string{"snapp-0", "snapp-1", "snapp-admin"} {
+		public, err := os.ReadFile("../../test/" + u + ".pem")
+		require.NoError(err)
 
-	return tokenString, nil
+		publicKey, err := jwt.ParseRSAPublicKeyFromPEM(public)
+		require.NoError(err)
+
+		private, err := os.ReadFile("../../test/" + u + ".private.pem")
+		require.NoError(err)
+
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(private)
+		require.NoError(err)
+
+		require.True(publicKey.Equal(privateKey.Public()))
+
+		token, err := getSampleToken("0", privateKey)
+		require.NoError(err)
+
+		_, err = jwt.NewParser().Parse(token, func(*jwt.Token) (any, error) {
+			return publicKey, nil
+		})
+		require.NoError(err)
+	}
 }
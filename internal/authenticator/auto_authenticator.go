@@ -2,15 +2,22 @@ package authenticator
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/snapp-incubator/soteria/internal/budget"
+	"github.com/snapp-incubator/soteria/internal/claimguard"
 	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/failratio"
+	"github.com/snapp-incubator/soteria/internal/flags"
+	"github.com/snapp-incubator/soteria/internal/hashring"
 	"github.com/snapp-incubator/soteria/internal/metric"
 	"github.com/snapp-incubator/soteria/internal/topics"
 	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/snapp-incubator/soteria/pkg/correlation"
 	"github.com/snapp-incubator/soteria/pkg/strconv"
 	"github.com/snapp-incubator/soteria/pkg/validator"
 	"go.opentelemetry.io/otel"
@@ -18,6 +25,9 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
+// IATSkewWindow is the age of tokens which are considered freshly minted, only their skew rejections are retried.
+const IATSkewWindow = 10 * time.Second
+
 // AutoAuthenticator is responsible for Acl/Auth/Token of users.
 type AutoAuthenticator struct {
 	AllowedAccessTypes []acl.AccessType
@@ -28,13 +38,45 @@ type AutoAuthenticator struct {
 	Parser             *jwt.Parser
 	Tracer             trace.Tracer
 	Metrics            *metric.AutoAuthenticatorMetrics
+	// Flags are the runtime feature flags, nil flags use the default values.
+	Flags *flags.Flags
+	// AccessQualifierClaim is the claim which qualifies the issuer in topic accesses.
+	AccessQualifierClaim string
+	// StaticClients use username and password instead of token.
+	StaticClients StaticClients
+	// IATSkewRetryDelay is the delay before retrying freshly minted tokens which are rejected
+	// by validator, zero disables the retry.
+	IATSkewRetryDelay time.Duration
+	// FailureRatio tracks the authentication failures of issuers, it is optional.
+	FailureRatio *failratio.Tracker
+	// ClaimGuard samples the claim names of parsed tokens, it is optional.
+	ClaimGuard *claimguard.Guard
+	// Validations coalesce and cache the validator calls, nil validations call the validator for every token.
+	Validations *Validations
+	// NoExpiry accepts the tokens without exp claim of its subjects, nil rejects all of them.
+	NoExpiry *NoExpiry
+	// SubjectFormats reject the subjects which don't have the format of their issuer, nil accepts all of them.
+	SubjectFormats *SubjectFormats
+	// ACLVerifier verifies the tokens of acl requests, nil verifier parses them unverified.
+	ACLVerifier *ACLVerifier
+	// Ring chooses the validator endpoint of tokens when validator has multiple endpoints,
+	// nil ring calls the Validator.
+	Ring *hashring.Ring
 }
 
 // Auth check user authentication by checking the user's token
 // isSuperuser is a flag that authenticator set it true when credentials is related to a superuser.
 func (a AutoAuthenticator) Auth(ctx context.Context, tokenString string) error {
+	_, err := a.AuthWithAttrs(ctx, tokenString)
+
+	return err
+}
+
+// AuthWithAttrs check user authentication by calling the validator and returns client attributes
+// from the claims of the validated token when they are enabled for the vendor.
+func (a AutoAuthenticator) AuthWithAttrs(ctx context.Context, tokenString string) (*ClientAttrs, error) {
 	ctx, span := a.Tracer.Start(ctx, "auto-authenticator.auth")
-	span.End()
+	defer span.End()
 
 	headers := http.Header{
 		validator.ServiceNameHeader: []string{"soteria"},
@@ -48,65 +90,357 @@ func (a AutoAuthenticator) Auth(ctx context.Context, tokenString string) error {
 
 	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(headers))
 
+	if id := correlation.RequestIDFromContext(ctx); id != "" {
+		headers.Set(correlation.Header, id)
+	}
+
+	budget.SetStage(ctx, budget.StageValidator)
+
 	start := time.Now()
 
-	if err := a.Validator.Validate(ctx, headers, "bearer "+tokenString); err != nil {
+	var err error
+
+	if InMaintenance(ctx) {
+		// tokens of vendors in maintenance are not sent to the validator, which is expected to reject them.
+		_, err = parse(ctx, a.Parser, tokenString, nil)
+	} else {
+		err = a.Validations.Validate(ctx, tokenString, func(ctx context.Context) error {
+			return a.validate(ctx, headers, tokenString)
+		})
+	}
+
+	if a.FailureRatio != nil {
+		a.FailureRatio.Record(a.Company, a.trackedIssuer(tokenString), err)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("token is invalid: %w (validator response time %g)", expired(err), time.Since(start).Seconds())
+	}
+
+	// token is verified by the validator, so its claims can be used without verification.
+	var claims jwt.MapClaims
+
+	if _, _, err := a.Parser.ParseUnverified(tokenString, &claims); err != nil {
+		return nil, ErrInvalidClaims
+	}
+
+	a.ClaimGuard.Record(a.Company, guarded(a.JWTConfig), claims)
+
+	if err := a.SubjectFormats.Check(
+		ctx, strconv.ToString(claims[a.JWTConfig.IssName]), strconv.ToString(claims[a.JWTConfig.SubName]),
+	); err != nil {
+		return nil, err
+	}
+
+	if _, err := a.NoExpiry.Check(claims, strconv.ToString(claims[a.JWTConfig.SubName])); err != nil {
+		return nil, err
+	}
+
+	if !a.Flags.EmitClientAttrs(a.Company) {
+		return nil, nil //nolint: nilnil
+	}
+
+	return clientAttrs(claims, a.JWTConfig, a.TopicManager, a.Company), nil
+}
+
+// expired adds jwt.ErrTokenExpired to the validator rejections of expired tokens, so the expiry of tokens
+// is detected the same for tokens of manual authenticators, which are rejected by the parser.
+func expired(err error) error {
+	if errors.Is(err, validator.ErrTokenExpired) && !errors.Is(err, jwt.ErrTokenExpired) {
+		return fmt.Errorf("%w: %w", jwt.ErrTokenExpired, err)
+	}
+
+	return err
+}
+
+// call calls the validator endpoint of token when there is a ring, otherwise it calls the validator.
+func (a AutoAuthenticator) call(ctx context.Context, headers http.Header, bearerToken string) error {
+	if a.Ring != nil {
+		return a.Ring.Validate(ctx, headers, bearerToken) //nolint: wrapcheck
+	}
+
+	return a.Validator.Validate(ctx, headers, bearerToken) //nolint: wrapcheck
+}
+
+// validate calls the validator, freshly minted tokens which validator rejects because their iat or nbf
+// is in the future of its clock are retried once after the skew delay.
+func (a AutoAuthenticator) validate(ctx context.Context, headers http.Header, tokenString string) error {
+	start := time.Now()
+
+	err := a.call(ctx, headers, "bearer "+tokenString)
+
+	a.Metrics.Latency(time.Since(start).Seconds(), a.Company, err)
+
+	if err == nil {
+		return nil
+	}
+
+	// only the rejections which validator reports as skew are retried, the others are returned as is.
+	if !errors.Is(err, validator.ErrTokenSkewed) {
+		return err //nolint: wrapcheck
+	}
+
+	issuer, iat, ok := a.freshlyMinted(tokenString)
+	if !ok {
+		return err //nolint: wrapcheck
+	}
+
+	if a.IATSkewRetryDelay > 0 && wait(ctx, a.IATSkewRetryDelay) {
+		start = time.Now()
+
+		err = a.call(ctx, headers, "bearer "+tokenString)
+
 		a.Metrics.Latency(time.Since(start).Seconds(), a.Company, err)
 
-		return fmt.Errorf("token is invalid: %w (validator response time %g)", err, time.Since(start).Seconds())
+		if err == nil {
+			a.Metrics.IATSkew(a.Company, issuer, "recovered")
+
+			return nil
+		}
+	}
+
+	a.Metrics.IATSkew(a.Company, issuer, "rejected")
+
+	return IATSkewError{
+		Issuer:   issuer,
+		IssuedAt: iat,
+		Err:      err,
+	}
+}
+
+// freshlyMinted returns the issuer and iat of tokens which are issued in the skew window or in the future.
+func (a AutoAuthenticator) freshlyMinted(tokenString string) (string, time.Time, bool) {
+	var claims jwt.MapClaims
+
+	if _, _, err := a.Parser.ParseUnverified(tokenString, &claims); err != nil {
+		return "", time.Time{}, false
+	}
+
+	iat, err := claims.GetIssuedAt()
+	if err != nil || iat == nil || time.Since(iat.Time) > IATSkewWindow {
+		return "", time.Time{}, false
+	}
+
+	return strconv.ToString(claims[a.JWTConfig.IssName]), iat.Time, true
+}
+
+// KnowsIssuer checks the issuer of the unverified token is in the vendor issuer entity map.
+func (a AutoAuthenticator) KnowsIssuer(tokenString string) bool {
+	return a.trackedIssuer(tokenString) != failratio.UnknownIssuer
+}
+
+// TokenExpiry returns the exp claim of the authenticated token, tokens of auto vendors are parsed
+// because their validator doesn't return the claims.
+func (a AutoAuthenticator) TokenExpiry(tokenString string) (time.Time, bool) {
+	return tokenExpiry(a.Parser, tokenString)
+}
+
+// trackedIssuer returns the issuer of token for tracking its failures, issuers which are not
+// in the iss-entity map are unknown, so unverified tokens cannot add issuers.
+func (a AutoAuthenticator) trackedIssuer(tokenString string) string {
+	var claims jwt.MapClaims
+
+	if _, _, err := a.Parser.ParseUnverified(tokenString, &claims); err != nil || a.TopicManager == nil {
+		return failratio.UnknownIssuer
+	}
+
+	issuer := strconv.ToString(claims[a.JWTConfig.IssName])
+
+	if _, ok := a.TopicManager.IssEntityMap[issuer]; !ok || issuer == topics.Default {
+		return failratio.UnknownIssuer
+	}
+
+	return issuer
+}
+
+// wait waits for the delay when it fits in the context deadline.
+func wait(ctx context.Context, delay time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+		return false
 	}
 
-	a.Metrics.Latency(time.Since(start).Seconds(), a.Company, nil)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
 
-	return nil
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
 
 // ACL check a user access to a topic.
-// nolint: cyclop, dupl
 func (a AutoAuthenticator) ACL(
-	_ context.Context,
+	ctx context.Context,
 	accessType acl.AccessType,
 	tokenString string,
 	topic string,
+	payloadSize int,
 ) (bool, error) {
-	if !a.ValidateAccessType(accessType) {
-		return false, ErrInvalidAccessType
+	if _, err := a.ACLWithTopic(ctx, accessType, tokenString, topic, payloadSize); err != nil {
+		return false, err
 	}
 
+	return true, nil
+}
+
+// ACLWithTopic checks the access of user to a topic and returns the matched topic type and entity of user
+// when access is allowed.
+// nolint: cyclop, dupl
+func (a AutoAuthenticator) ACLWithTopic(
+	ctx context.Context,
+	accessType acl.AccessType,
+	tokenString string,
+	topic string,
+	payloadSize int,
+) (*TopicAttrs, error) {
+	// access types are checked against the allowed access types after topic is matched,
+	// because topics can override them.
+	if !accessType.IsValid() {
+		return nil, ErrInvalidAccessType
+	}
+
+	budget.SetStage(ctx, budget.StageParseToken)
+
 	var claims jwt.MapClaims
 
+	// tokens which cannot be decoded are malformed like the tokens of manual vendors.
 	if _, _, err := a.Parser.ParseUnverified(tokenString, &claims); err != nil {
-		return false, ErrInvalidClaims
+		if errors.Is(err, jwt.ErrTokenMalformed) {
+			return nil, malformed(tokenString, err)
+		}
+
+		return nil, ErrInvalidClaims
+	}
+
+	if err := a.ACLVerifier.Verify(ctx, tokenString, claims); err != nil {
+		return nil, err
 	}
 
 	if claims[a.JWTConfig.IssName] == nil {
-		return false, ErrIssNotFound
+		return nil, ErrIssNotFound
 	}
 
 	issuer := strconv.ToString(claims[a.JWTConfig.IssName])
 
 	if claims[a.JWTConfig.SubName] == nil {
-		return false, ErrSubNotFound
+		return nil, ErrSubNotFound
 	}
 
 	sub := strconv.ToString(claims[a.JWTConfig.SubName])
 
-	topicTemplate := a.TopicManager.ParseTopic(topic, issuer, sub, map[string]any(claims))
+	recordSubject(ctx, Subject{
+		Issuer:    issuer,
+		Sub:       sub,
+		Qualifier: qualifier(claims, a.AccessQualifierClaim),
+		ID:        strconv.ToString(claims["jti"]),
+	})
+
+	// the decoded subject is reused by the templates of request.
+	ctx = topics.WithDecodedSubjects(ctx)
+
+	if err := a.SubjectFormats.Check(ctx, issuer, sub); err != nil {
+		return nil, err
+	}
+
+	restricted, err := a.NoExpiry.Check(claims, sub)
+	if err != nil {
+		return nil, err
+	}
+
+	budget.SetStage(ctx, budget.StageParseTopic)
+
+	// the checks after matching use the normalized topic too.
+	topic = a.TopicManager.Normalize(topic)
+
+	topicTemplate, err := a.TopicManager.ParseTopic(ctx, topic, issuer, sub, map[string]any(claims))
+	if err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
 	if topicTemplate == nil {
-		return false, InvalidTopicError{Topic: topic}
+		return nil, InvalidTopicError{Topic: topic}
 	}
 
-	if !topicTemplate.HasAccess(issuer, accessType) {
-		return false, TopicNotAllowedError{
+	budget.SetTopicType(ctx, topicTemplate.Type)
+
+	if err := checkAccessType(a.AllowedAccessTypes, topicTemplate, accessType); err != nil {
+		return nil, err
+	}
+
+	// tokens without exp claim can only access the topic types of no expiry.
+	if restricted && !a.NoExpiry.Allows(topicTemplate.Type) {
+		return nil, TopicNotAllowedError{
 			Issuer:     issuer,
 			Sub:        sub,
 			AccessType: accessType,
 			Topic:      topic,
 			TopicType:  topicTemplate.Type,
+			Granted:    acl.None,
 		}
 	}
 
-	return true, nil
+	granted := a.TopicManager.Access(ctx, topicTemplate, issuer, qualifier(claims, a.AccessQualifierClaim), claims)
+	if !granted.Allows(accessType) {
+		return nil, TopicNotAllowedError{
+			Issuer:     issuer,
+			Sub:        sub,
+			AccessType: accessType,
+			Topic:      topic,
+			TopicType:  topicTemplate.Type,
+			Granted:    granted,
+		}
+	}
+
+	if err := topicTemplate.CheckRide(topic, issuer, sub, map[string]any(claims)); err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	if err := topicTemplate.CheckFields(topic, issuer, sub); err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	if err := topicTemplate.CheckPayload(
+		topic, issuer, sub, map[string]any(claims), accessType, PayloadFromContext(ctx),
+	); err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	if !topicTemplate.AllowsPayload(accessType, payloadSize) {
+		return nil, PayloadTooLargeError{
+			Topic:     topic,
+			TopicType: topicTemplate.Type,
+			Size:      payloadSize,
+			MaxSize:   topicTemplate.MaxPayloadBytes,
+		}
+	}
+
+	budget.SetStage(ctx, budget.StageStateCheck)
+
+	if err := a.TopicManager.CheckState(ctx, topicTemplate, topic, issuer, sub, map[string]any(claims)); err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	budget.SetStage(ctx, budget.StagePostAuthorize)
+
+	if err := topicTemplate.PostAuthorize(ctx, issuer, sub, topic, accessType); err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	// subscriptions are counted only when they are allowed.
+	if err := topicTemplate.LimitSubscription(issuer, sub, topic, accessType); err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	if !a.Flags.EmitTopicType(a.Company) {
+		return nil, nil //nolint: nilnil
+	}
+
+	return &TopicAttrs{
+		TopicType: topicTemplate.Type,
+		Entity:    a.TopicManager.Entity(issuer, claims),
+	}, nil
 }
 
 func (a AutoAuthenticator) ValidateAccessType(accessType acl.AccessType) bool {
@@ -119,6 +453,11 @@ func (a AutoAuthenticator) ValidateAccessType(accessType acl.AccessType) bool {
 	return false
 }
 
+// StaticClient returns the static client with the given username.
+func (a AutoAuthenticator) StaticClient(username string) (StaticClient, bool) {
+	return a.StaticClients.StaticClient(username)
+}
+
 func (a AutoAuthenticator) GetCompany() string {
 	return a.Company
 }
@@ -4,18 +4,22 @@ import (
 	"context"
 	"crypto/rsa"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/snapp-incubator/soteria/internal/authenticator"
 	"github.com/snapp-incubator/soteria/internal/config"
 	"github.com/snapp-incubator/soteria/internal/metric"
 	"github.com/snapp-incubator/soteria/internal/topics"
 	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/snapp-incubator/soteria/pkg/testutil"
 	"github.com/snapp-incubator/soteria/pkg/validator"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -96,7 +100,7 @@ func (suite *AutoAuthenticatorTestSuite) SetupSuite() {
 		Tracer:             noop.NewTracerProvider().Tracer(""),
 		Company:            "snapp",
 		Parser:             jwt.NewParser(),
-		Metrics:            metric.NewAutoAuthenticatorMetrics(),
+		Metrics:            metric.NewAutoAuthenticatorMetrics(prometheus.DefaultRegisterer),
 		TopicManager:       topics.NewTopicManager(cfg.Topics, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop()),
 		JWTConfig: config.JWT{
 			IssName:       "iss",
@@ -118,6 +122,18 @@ func (suite *AutoAuthenticatorTestSuite) TestAuth() {
 	})
 }
 
+func (suite *AutoAuthenticatorTestSuite) TestACLMalformed() {
+	require := suite.Require()
+
+	// tokens which cannot be decoded are malformed, same as manual vendors.
+	_, err := suite.Authenticator.ACL(context.Background(), acl.Sub, "header.!!!.signature", "topic", 0)
+
+	var mcErr authenticator.MalformedCredentialError
+
+	require.ErrorAs(err, &mcErr)
+	require.Equal(len("header.!!!.signature"), mcErr.Length)
+}
+
 func (suite *AutoAuthenticatorTestSuite) TearDownSuite() {
 	suite.Server.Close()
 }
@@ -140,12 +156,108 @@ func TestAutoAuthenticator_ValidateTopicBySender(t *testing.T) {
 	t.Run("testing valid driver cab event", func(t *testing.T) {
 		t.Parallel()
 
-		topicTemplate := authenticator.TopicManager.ParseTopic(
-			validDriverCabEventTopic,
+		topicTemplate, err := authenticator.TopicManager.ParseTopic(
+			context.Background(), validDriverCabEventTopic,
 			topics.DriverIss,
 			"DXKgaNQa7N5Y7bo",
 			nil,
 		)
+		require.NoError(t, err)
 		require.NotNil(t, topicTemplate)
 	})
 }
+
+// nolint: funlen
+func TestAutoAuthenticator_IATSkew(t *testing.T) {
+	t.Parallel()
+
+	key := []byte("secret")
+
+	skewed := `{"code": "` + validator.CodeTokenUsedBeforeIssued + `", "message": "token used before issued"}`
+
+	tests := []struct {
+		name     string
+		iat      time.Time
+		body     string
+		accepted int
+		calls    int32
+		skew     bool
+	}{
+		{name: "recovered by retry", iat: time.Now().Add(2 * time.Second), body: skewed, accepted: 2, calls: 2, skew: false},
+		{name: "rejected after retry", iat: time.Now().Add(2 * time.Second), body: skewed, accepted: 0, calls: 2, skew: true},
+		{name: "old token is not retried", iat: time.Now().Add(-time.Hour), body: skewed, accepted: 0, calls: 1, skew: false},
+		// rejections which validator doesn't report as skew are not retried.
+		{
+			name:     "other rejection is not retried",
+			iat:      time.Now().Add(2 * time.Second),
+			body:     `{"message": "invalid signature"}`,
+			accepted: 0,
+			calls:    1,
+			skew:     false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require := require.New(t)
+
+			var calls atomic.Int32
+
+			server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+				if int(calls.Add(1)) != tc.accepted {
+					res.WriteHeader(http.StatusUnauthorized)
+					_, _ = res.Write([]byte(tc.body))
+
+					return
+				}
+
+				res.Header().Add("X-User-Data", "{}")
+				res.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			// nolint: exhaustruct
+			a := authenticator.AutoAuthenticator{
+				Validator:         validator.New(server.URL, time.Second),
+				Tracer:            noop.NewTracerProvider().Tracer(""),
+				Company:           "snapp",
+				Parser:            jwt.NewParser(),
+				Metrics:           metric.NewAutoAuthenticatorMetrics(prometheus.DefaultRegisterer),
+				JWTConfig:         config.SnappVendor().Jwt,
+				IATSkewRetryDelay: 10 * time.Millisecond,
+			}
+
+			token, err := testutil.Token(jwt.SigningMethodHS512, key, testutil.Claims{
+				Issuer:       topics.DriverIss,
+				Subject:      testutil.DefaultSubject,
+				ExpiresIn:    0,
+				NoExpiration: false,
+				Extra:        map[string]any{"iat": jwt.NewNumericDate(tc.iat)},
+				Kid:          "",
+			})
+			require.NoError(err)
+
+			err = a.Auth(context.Background(), token)
+			require.Equal(tc.calls, calls.Load())
+
+			if tc.accepted > 0 {
+				require.NoError(err)
+
+				return
+			}
+
+			require.Error(err)
+
+			var skewErr authenticator.IATSkewError
+
+			require.Equal(tc.skew, errors.As(err, &skewErr))
+
+			if tc.skew {
+				require.Equal(topics.DriverIss, skewErr.Issuer)
+				require.ErrorIs(err, validator.ErrRequestFailed)
+			}
+		})
+	}
+}
@@ -5,11 +5,18 @@ import (
 	"fmt"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/claimguard"
 	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/failratio"
+	"github.com/snapp-incubator/soteria/internal/flags"
+	"github.com/snapp-incubator/soteria/internal/hashring"
 	"github.com/snapp-incubator/soteria/internal/metric"
 	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/internal/worker"
 	"github.com/snapp-incubator/soteria/pkg/acl"
 	"github.com/snapp-incubator/soteria/pkg/validator"
+	"github.com/speps/go-hashids/v2"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
@@ -20,6 +27,8 @@ var (
 	ErrNoDefaultCaseIssEntity      = errors.New("default case for iss-entity map is required")
 	ErrNoDefaultCaseIssPeer        = errors.New("default case for iss-peer map is required")
 	ErrInvalidAuthenticator        = errors.New("there is no authenticator to support your request")
+	ErrTopicShadowed               = errors.New("topic template is shadowed by an earlier template")
+	ErrInvalidRideMembership       = errors.New("ride membership segment is out of the topic levels")
 )
 
 type Builder struct {
@@ -27,40 +36,69 @@ type Builder struct {
 	Logger          *zap.Logger
 	ValidatorConfig config.Validator
 	Tracer          trace.Tracer
+	// KeyRegistry records the loaded keys fingerprints, it is optional.
+	KeyRegistry *KeyRegistry
+	// Flags are the runtime feature flags of vendors, they are optional.
+	Flags *flags.Flags
+	// FailureRatio tracks the authentication failures of issuers, it is optional.
+	FailureRatio *failratio.Tracker
+	// ClaimGuard samples the claim names of parsed tokens, it is optional.
+	ClaimGuard *claimguard.Guard
+	// StrictTopicShadowing fails the building of vendors which have shadowed topic templates,
+	// otherwise they are logged as warning.
+	StrictTopicShadowing bool
+	// ValidatorRing chooses the validator endpoints of tokens when validator has multiple urls, it is optional.
+	ValidatorRing *hashring.Ring
+	// Background runs the background tasks of authenticators, they run in their own goroutines when it is nil.
+	Background *worker.Pool
+	// Registerer registers the metrics of authenticators, they are registered on the default registerer
+	// when it is nil.
+	Registerer prometheus.Registerer
 }
 
+// registerer returns the registerer of authenticators metrics.
+func (b Builder) registerer() prometheus.Registerer {
+	if b.Registerer == nil {
+		return prometheus.DefaultRegisterer
+	}
+
+	return b.Registerer
+}
+
+// noExpiry returns the subjects of vendor which can omit the exp claim, it is nil when vendor has no subjects.
+func (b Builder) noExpiry(vendor config.Vendor) *NoExpiry {
+	return NewNoExpiry(vendor.Company, vendor.NoExpirySubjects, vendor.NoExpiryTopicTypes, b.registerer())
+}
+
+// Authenticators validates the vendors, logs their warnings and builds their authenticators using the factory of their type.
+// Errors of all vendors are joined, each of them is a ConfigError with the path of vendor or its field.
 func (b Builder) Authenticators() (map[string]Authenticator, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+
+	for _, warning := range b.Warnings() {
+		b.Logger.Warn("vendor configuration is probably wrong", zap.Error(warning))
+	}
+
 	all := make(map[string]Authenticator)
+	errs := make([]error, 0)
 
-	for _, vendor := range b.Vendors {
-		var (
-			auth Authenticator
-			err  error
-		)
+	for i, vendor := range b.Vendors {
+		auth, err := b.Authenticator(vendor)
+		if err != nil {
+			errs = append(errs, ConfigError{Path: fmt.Sprintf("vendors[%d]", i), Err: err})
 
-		switch vendor.Type {
-		case "auto", "validator", "validator-based", "using-validator":
-			auth, err = b.autoAuthenticator(vendor)
-			if err != nil {
-				return nil, fmt.Errorf("cannot build auto authenticator %w", err)
-			}
-		case "admin", "internal":
-			auth, err = b.adminAuthenticator(vendor)
-			if err != nil {
-				return nil, fmt.Errorf("cannot build admin authenticator %w", err)
-			}
-		case "manual":
-			auth, err = b.manualAuthenticator(vendor)
-			if err != nil {
-				return nil, fmt.Errorf("cannot build manual authenticator %w", err)
-			}
-		default:
-			return nil, ErrInvalidAuthenticator
+			continue
 		}
 
 		all[vendor.Company] = auth
 	}
 
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
 	if len(all) == 0 {
 		return nil, ErrNoAuthenticator
 	}
@@ -68,6 +106,21 @@ func (b Builder) Authenticators() (map[string]Authenticator, error) {
 	return all, nil
 }
 
+// Authenticator builds the authenticator of a single vendor using the factory of its type.
+func (b Builder) Authenticator(vendor config.Vendor) (Authenticator, error) {
+	build, ok := factory(vendor.Type)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", vendor.Type, ErrInvalidAuthenticator)
+	}
+
+	auth, err := build(b, vendor)
+	if err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	return auth, nil
+}
+
 func (b Builder) adminAuthenticator(vendor config.Vendor) (*AdminAuthenticator, error) {
 	if _, ok := vendor.Keys["system"]; !ok || len(vendor.Keys) != 1 {
 		return nil, ErrAdminAuthenticatorSystemKey
@@ -78,6 +131,10 @@ func (b Builder) adminAuthenticator(vendor config.Vendor) (*AdminAuthenticator,
 		return nil, fmt.Errorf("loading keys failed %w", err)
 	}
 
+	if b.KeyRegistry != nil {
+		b.KeyRegistry.Set(vendor.Company, vendor.Keys, keys)
+	}
+
 	return &AdminAuthenticator{
 		Key:       keys["system"],
 		Company:   vendor.Company,
@@ -91,6 +148,10 @@ func (b Builder) manualAuthenticator(vendor config.Vendor) (*ManualAuthenticator
 		return nil, fmt.Errorf("failed to validate mappers %w", err)
 	}
 
+	if err := b.ValidateTopics(vendor.Topics); err != nil {
+		return nil, fmt.Errorf("failed to validate topics %w", err)
+	}
+
 	allowedAccessTypes, err := b.GetAllowedAccessTypes(vendor.AllowedAccessTypes)
 	if err != nil {
 		return nil, fmt.Errorf("cannot parse allowed access types %w", err)
@@ -101,29 +162,67 @@ func (b Builder) manualAuthenticator(vendor config.Vendor) (*ManualAuthenticator
 		return nil, fmt.Errorf("cannot create hash-id manager %w", err)
 	}
 
-	keys, err := b.GenerateKeys(vendor.Jwt.SigningMethod, vendor.Keys)
+	keys := make(map[string]any)
+
+	// vendors can have only verification keys.
+	if len(vendor.Keys) > 0 || len(vendor.VerificationKeys) == 0 {
+		keys, err = b.GenerateIssuerKeys(vendor.Jwt, vendor.Keys)
+		if err != nil {
+			return nil, fmt.Errorf("loading keys failed %w", err)
+		}
+	}
+
+	staticClients, err := b.GenerateStaticClients(vendor.StaticClients)
 	if err != nil {
-		return nil, fmt.Errorf("loading keys failed %w", err)
+		return nil, fmt.Errorf("loading static clients failed %w", err)
+	}
+
+	verificationKeys, err := b.GenerateVerificationKeys(vendor.Jwt, vendor.VerificationKeys, keys)
+	if err != nil {
+		return nil, fmt.Errorf("loading verification keys failed %w", err)
+	}
+
+	if b.KeyRegistry != nil {
+		b.KeyRegistry.Set(vendor.Company, vendor.Keys, keys)
+		b.KeyRegistry.SetVerificationKeys(vendor.Company, vendor.VerificationKeys, verificationKeys)
+	}
+
+	manager, err := b.topicManager(vendor, hid, allowedAccessTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	formats, err := NewSubjectFormats(vendor.SubjectFormats, manager)
+	if err != nil {
+		return nil, err
 	}
 
 	return &ManualAuthenticator{
-		Keys:               keys,
-		AllowedAccessTypes: allowedAccessTypes,
-		Company:            vendor.Company,
-		TopicManager: topics.NewTopicManager(
-			vendor.Topics,
-			hid,
-			vendor.Company,
-			vendor.IssEntityMap,
-			vendor.IssPeerMap,
-			b.Logger.Named("topic-manager"),
-		),
-		JWTConfig: vendor.Jwt,
-		Parser:    jwt.NewParser(jwt.WithValidMethods([]string{vendor.Jwt.SigningMethod})),
+		Keys:                 keys,
+		AllowedAccessTypes:   allowedAccessTypes,
+		Company:              vendor.Company,
+		TopicManager:         manager,
+		JWTConfig:            vendor.Jwt,
+		Parser:               jwt.NewParser(jwt.WithValidMethods(vendor.Jwt.SigningMethods())),
+		Flags:                b.Flags,
+		AccessQualifierClaim: vendor.AccessQualifierClaim,
+		VerificationKeys:     verificationKeys,
+		KeyMetrics:           metric.NewKeyMetrics(b.registerer()),
+		StaticClients:        staticClients,
+		FailureRatio:         b.FailureRatio,
+		ClaimGuard:           b.ClaimGuard,
+		NoExpiry:             b.noExpiry(vendor),
+		SubjectFormats:       formats,
+		Reloader:             b.keyReloader(vendor, keys),
+		Policy:               b.policy(vendor),
 	}, nil
 }
 
 func (b Builder) autoAuthenticator(vendor config.Vendor) (*AutoAuthenticator, error) {
+	if err := b.ValidateTopics(vendor.Topics); err != nil {
+		return nil, fmt.Errorf("failed to validate topics %w", err)
+	}
+
 	allowedAccessTypes, err := b.GetAllowedAccessTypes(vendor.AllowedAccessTypes)
 	if err != nil {
 		return nil, fmt.Errorf("cannot parse allowed access types %w", err)
@@ -134,27 +233,143 @@ func (b Builder) autoAuthenticator(vendor config.Vendor) (*AutoAuthenticator, er
 		return nil, fmt.Errorf("cannot create hash-id manager %w", err)
 	}
 
+	staticClients, err := b.GenerateStaticClients(vendor.StaticClients)
+	if err != nil {
+		return nil, fmt.Errorf("loading static clients failed %w", err)
+	}
+
+	manager, err := b.topicManager(vendor, hid, allowedAccessTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	formats, err := NewSubjectFormats(vendor.SubjectFormats, manager)
+	if err != nil {
+		return nil, err
+	}
+
 	client := validator.New(b.ValidatorConfig.URL, b.ValidatorConfig.Timeout)
+	metrics := metric.NewAutoAuthenticatorMetrics(b.registerer())
+	validations := NewValidations(vendor.Company, b.ValidatorConfig, metrics).WithBackground(b.Background)
+
+	verifier, err := b.aclVerifier(vendor, validations, metrics)
+	if err != nil {
+		return nil, err
+	}
 
 	return &AutoAuthenticator{
-		AllowedAccessTypes: allowedAccessTypes,
-		Company:            vendor.Company,
-		Metrics:            metric.NewAutoAuthenticatorMetrics(),
-		TopicManager: topics.NewTopicManager(
-			vendor.Topics,
-			hid,
-			vendor.Company,
-			vendor.IssEntityMap,
-			vendor.IssPeerMap,
-			b.Logger.Named("topic-manager"),
-		),
-		Tracer:    b.Tracer,
-		JWTConfig: vendor.Jwt,
-		Validator: client,
-		Parser:    jwt.NewParser(),
+		AllowedAccessTypes:   allowedAccessTypes,
+		Company:              vendor.Company,
+		Metrics:              metrics,
+		TopicManager:         manager,
+		Tracer:               b.Tracer,
+		JWTConfig:            vendor.Jwt,
+		Validator:            client,
+		Parser:               jwt.NewParser(),
+		Flags:                b.Flags,
+		AccessQualifierClaim: vendor.AccessQualifierClaim,
+		StaticClients:        staticClients,
+		IATSkewRetryDelay:    b.ValidatorConfig.IATSkewRetryDelay,
+		FailureRatio:         b.FailureRatio,
+		ClaimGuard:           b.ClaimGuard,
+		Validations:          validations,
+		NoExpiry:             b.noExpiry(vendor),
+		SubjectFormats:       formats,
+		ACLVerifier:          verifier,
+		Ring:                 b.ValidatorRing,
+	}, nil
+}
+
+// aclVerifier creates the acl verifier of auto vendor, it is nil when vendor has no acl verification.
+// Keys of vendor are optional and tokens of issuers without keys are verified by their validations.
+func (b Builder) aclVerifier(
+	vendor config.Vendor,
+	validations *Validations,
+	metrics *metric.AutoAuthenticatorMetrics,
+) (*ACLVerifier, error) {
+	if vendor.ACLVerification == nil {
+		return nil, nil //nolint: nilnil
+	}
+
+	keys := make(map[string]any)
+
+	if len(vendor.Keys) > 0 {
+		generated, err := b.GenerateIssuerKeys(vendor.Jwt, vendor.Keys)
+		if err != nil {
+			return nil, fmt.Errorf("loading acl verification keys failed %w", err)
+		}
+
+		keys = generated
+	}
+
+	verificationKeys, err := b.GenerateVerificationKeys(vendor.Jwt, vendor.VerificationKeys, keys)
+	if err != nil {
+		return nil, fmt.Errorf("loading acl verification keys failed %w", err)
+	}
+
+	return &ACLVerifier{
+		Company:          vendor.Company,
+		JWTConfig:        vendor.Jwt,
+		Keys:             keys,
+		VerificationKeys: verificationKeys,
+		Parser:           jwt.NewParser(jwt.WithValidMethods(vendor.Jwt.SigningMethods())),
+		Validations:      validations,
+		BrokerSecret:     vendor.ACLVerification.BrokerSecret,
+		Enforce:          vendor.ACLVerification.Enforce,
+		Metrics:          metrics,
 	}, nil
 }
 
+func (b Builder) topicManager(
+	vendor config.Vendor,
+	hid map[string]*hashids.HashID,
+	allowedAccessTypes []acl.AccessType,
+) (*topics.Manager, error) {
+	manager := topics.NewTopicManager(
+		vendor.Topics,
+		hid,
+		vendor.Company,
+		vendor.IssEntityMap,
+		vendor.IssPeerMap,
+		b.Logger.Named("topic-manager"),
+	).WithRegisterer(b.registerer()).
+		WithPostAuthorizers(vendor.Topics, b.Tracer).
+		WithStateCheckers(vendor.Topics, b.Tracer).
+		WithRemoteAccesses(vendor.Topics, b.Tracer).
+		WithSubscriptionLimits(vendor.Topics).
+		WithFlags(b.Flags).
+		WithStaticTopics(vendor.StaticTopics).
+		WithEntityRules(vendor.IssEntityRules).
+		WithAllowedAccessTypes(allowedAccessTypes)
+
+	// topics without allowed access types use the vendor allowed access types.
+	for i, topic := range topics.Ordered(vendor.Topics) {
+		if topic.AllowedAccessTypes == nil || i >= len(manager.TopicTemplates) {
+			continue
+		}
+
+		allowed, err := b.GetAllowedAccessTypes(topic.AllowedAccessTypes)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse allowed access types of topic %s %w", topic.Type, err)
+		}
+
+		manager.TopicTemplates[i].AllowedAccessTypes = allowed
+	}
+
+	for _, shadow := range manager.Shadows() {
+		if b.StrictTopicShadowing {
+			return nil, fmt.Errorf("%w: %s: %s", ErrTopicShadowed, vendor.Company, shadow)
+		}
+
+		b.Logger.Warn("topic template is shadowed",
+			zap.String("vendor", vendor.Company),
+			zap.Stringer("shadow", shadow),
+		)
+	}
+
+	return manager, nil
+}
+
 // GetAllowedAccessTypes will return all allowed access types in Soteria.
 func (b Builder) GetAllowedAccessTypes(accessTypes []string) ([]acl.AccessType, error) {
 	allowedAccessTypes := make([]acl.AccessType, 0, len(accessTypes))
@@ -162,7 +377,7 @@ func (b Builder) GetAllowedAccessTypes(accessTypes []string) ([]acl.AccessType,
 	for _, a := range accessTypes {
 		at, err := toUserAccessType(a)
 		if err != nil {
-			return nil, fmt.Errorf("could not convert %s: %w", at, err)
+			return nil, fmt.Errorf("could not convert %q: %w", a, err)
 		}
 
 		allowedAccessTypes = append(allowedAccessTypes, at)
@@ -171,18 +386,15 @@ func (b Builder) GetAllowedAccessTypes(accessTypes []string) ([]acl.AccessType,
 	return allowedAccessTypes, nil
 }
 
-// toUserAccessType will convert string access type to it's own type.
+// toUserAccessType will convert string access type to it's own type, only the accesses which
+// clients can request are allowed.
 func toUserAccessType(access string) (acl.AccessType, error) {
-	switch access {
-	case "pub", "publish":
-		return acl.Pub, nil
-	case "sub", "subscribe":
-		return acl.Sub, nil
-	case "pubsub", "subpub":
-		return acl.PubSub, nil
+	at, err := acl.ParseAccessType(access)
+	if err != nil || at == acl.Deny || at == acl.None {
+		return "", ErrInvalidAccessType
 	}
 
-	return "", ErrInvalidAccessType
+	return at, nil
 }
 
 func (b Builder) ValidateMappers(issEntityMap, issPeerMap map[string]string) error {
@@ -196,3 +408,15 @@ func (b Builder) ValidateMappers(issEntityMap, issPeerMap map[string]string) err
 
 	return nil
 }
+
+// ValidateTopics checks the topics same as Validate, errors are joined and each of them is a ConfigError
+// with the index of its topic.
+func (b Builder) ValidateTopics(topicList []topics.Topic) error {
+	errs := make([]error, 0)
+
+	for i, topic := range topicList {
+		errs = append(errs, validateTopic(fmt.Sprintf("topics[%d]", i), topic)...)
+	}
+
+	return errors.Join(errs...)
+}
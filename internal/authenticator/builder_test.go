@@ -1,12 +1,22 @@
 package authenticator_test
 
 import (
+	"context"
+	"crypto/md5" //nolint: gosec
+	"encoding/hex"
+	"slices"
+	"strings"
 	"testing"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/snapp-incubator/soteria/internal/authenticator"
 	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/keygen"
+	"github.com/snapp-incubator/soteria/internal/policy"
+	"github.com/snapp-incubator/soteria/internal/remoteaccess"
 	"github.com/snapp-incubator/soteria/internal/topics"
 	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/snapp-incubator/soteria/pkg/testutil"
 	"github.com/snapp-incubator/soteria/pkg/validator"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/otel/trace/noop"
@@ -116,6 +126,140 @@ func TestBuilderAutoAuthenticator(t *testing.T) {
 	require.Equal(validator.New(b.ValidatorConfig.URL, b.ValidatorConfig.Timeout), aa.Validator)
 }
 
+func TestBuilderAutoAuthenticatorPolicy(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	// nolint: exhaustruct
+	b := authenticator.Builder{
+		Tracer: noop.NewTracerProvider().Tracer(""),
+		Vendors: []config.Vendor{
+			{
+				Company:            "auto",
+				Type:               "auto",
+				AllowedAccessTypes: []string{"pub"},
+				// nolint: exhaustruct
+				Policy: &policy.Config{Path: "soteria/authz", Modules: []string{"authz.rego"}},
+			},
+		},
+		Logger: zap.NewNop(),
+		ValidatorConfig: config.Validator{
+			URL:     "https://httpbin.org",
+			Timeout: 0,
+		},
+	}
+
+	_, err := b.Authenticators()
+	require.ErrorIs(err, authenticator.ErrPolicyNotSupported)
+}
+
+func TestBuilderTopicAllowedAccessTypes(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	vendor := func(allowed []string) config.Vendor {
+		// nolint: exhaustruct
+		return config.Vendor{
+			Company:            "auto",
+			Type:               "auto",
+			AllowedAccessTypes: []string{"sub"},
+			Topics: []topics.Topic{
+				{
+					Type:               topics.BoxEvent,
+					Template:           "^bucks$",
+					Accesses:           map[string]acl.AccessType{topics.DriverIss: acl.PubSub},
+					AllowedAccessTypes: allowed,
+				},
+				{
+					Type:     topics.Chat,
+					Template: "^chat$",
+					Accesses: map[string]acl.AccessType{topics.DriverIss: acl.Sub},
+				},
+			},
+		}
+	}
+
+	// nolint: exhaustruct
+	b := authenticator.Builder{
+		Tracer:  noop.NewTracerProvider().Tracer(""),
+		Vendors: []config.Vendor{vendor([]string{"pub", "sub"})},
+		Logger:  zap.NewNop(),
+	}
+
+	vendors, err := b.Authenticators()
+	require.NoError(err)
+
+	aa, ok := vendors["auto"].(*authenticator.AutoAuthenticator)
+	require.True(ok)
+	require.Equal([]acl.AccessType{acl.Pub, acl.Sub}, aa.TopicManager.TopicTemplates[0].AllowedAccessTypes)
+	require.Nil(aa.TopicManager.TopicTemplates[1].AllowedAccessTypes)
+
+	b.Vendors = []config.Vendor{vendor([]string{"everything"})}
+
+	_, err = b.Authenticators()
+	require.ErrorIs(err, authenticator.ErrInvalidAccessType)
+}
+
+func TestBuilderTopicShadowing(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	// nolint: exhaustruct
+	vendor := config.Vendor{
+		Company:            "auto",
+		Type:               "auto",
+		AllowedAccessTypes: []string{"pub", "sub"},
+		Topics: []topics.Topic{
+			{
+				Type:     topics.BoxEvent,
+				Template: "^.+$",
+				Accesses: map[string]acl.AccessType{topics.DriverIss: acl.Sub},
+			},
+			{
+				Type:     topics.Chat,
+				Template: "^chat$",
+				Accesses: map[string]acl.AccessType{topics.DriverIss: acl.PubSub},
+			},
+		},
+	}
+
+	// nolint: exhaustruct
+	b := authenticator.Builder{
+		Tracer:  noop.NewTracerProvider().Tracer(""),
+		Vendors: []config.Vendor{vendor},
+		Logger:  zap.NewNop(),
+	}
+
+	_, err := b.Authenticators()
+	require.NoError(err)
+
+	b.StrictTopicShadowing = true
+
+	_, err = b.Authenticators()
+	require.ErrorIs(err, authenticator.ErrTopicShadowed)
+
+	// priority makes the specific template to be matched first.
+	vendor.Topics[1].Priority = 1
+	b.Vendors = []config.Vendor{vendor}
+
+	_, err = b.Authenticators()
+	require.NoError(err)
+
+	// static topics are matched before all templates, so their wide patterns shadow templates too.
+	vendor.StaticTopics = []topics.StaticTopic{{
+		Pattern:  "#",
+		Accesses: map[string]acl.AccessType{topics.DriverIss: acl.Sub},
+	}}
+	b.Vendors = []config.Vendor{vendor}
+
+	_, err = b.Authenticators()
+	require.ErrorIs(err, authenticator.ErrTopicShadowed)
+	require.ErrorContains(err, "is shadowed by static topic 0 (#)")
+}
+
 func TestBuilderInternalAuthenticator(t *testing.T) {
 	t.Parallel()
 
@@ -260,7 +404,7 @@ func TestBuilderManualAuthenticator(t *testing.T) {
 				Jwt: config.JWT{
 					IssName:       "iss",
 					SubName:       "sub",
-					SigningMethod: "RSA512",
+					SigningMethod: "RS512",
 				},
 				Type:               "manual",
 				AllowedAccessTypes: []string{"pub", "sub"},
@@ -337,7 +481,7 @@ func TestBuilderManualAuthenticatorInvalidMapping_1(t *testing.T) {
 				Jwt: config.JWT{
 					IssName:       "iss",
 					SubName:       "sub",
-					SigningMethod: "RSA512",
+					SigningMethod: "RS512",
 				},
 				Type:               "manual",
 				AllowedAccessTypes: []string{"pub", "sub"},
@@ -408,7 +552,7 @@ func TestBuilderManualAuthenticatorInvalidMapping_2(t *testing.T) {
 				Jwt: config.JWT{
 					IssName:       "iss",
 					SubName:       "sub",
-					SigningMethod: "RSA512",
+					SigningMethod: "RS512",
 				},
 				Type:               "manual",
 				AllowedAccessTypes: []string{"pub", "sub"},
@@ -482,7 +626,7 @@ func TestBuilderManualAuthenticatorInvalidAccess(t *testing.T) {
 				Jwt: config.JWT{
 					IssName:       "iss",
 					SubName:       "sub",
-					SigningMethod: "RSA512",
+					SigningMethod: "RS512",
 				},
 				Type:               "manual",
 				AllowedAccessTypes: []string{"pub", "superuser"},
@@ -542,3 +686,274 @@ func TestBuilderManualAuthenticatorInvalidAccess(t *testing.T) {
 	_, err := b.Authenticators()
 	require.ErrorIs(err, authenticator.ErrInvalidAccessType)
 }
+
+func TestBuilderValidateTopics(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	// nolint: exhaustruct
+	b := authenticator.Builder{}
+
+	require.NoError(b.ValidateTopics(config.SnappVendor().Topics))
+
+	require.ErrorIs(b.ValidateTopics([]topics.Topic{
+		{
+			Type:     topics.Chat,
+			Template: "^chat$",
+			Accesses: map[string]acl.AccessType{
+				topics.DriverIss:    acl.Deny,
+				topics.PassengerIss: "publish",
+			},
+			MaxPayloadBytes: 0,
+		},
+	}), authenticator.InvalidTopicAccessError{
+		TopicType: topics.Chat,
+		Issuer:    topics.PassengerIss,
+		Access:    "publish",
+	})
+
+	// nolint: exhaustruct
+	require.ErrorIs(b.ValidateTopics([]topics.Topic{
+		{Type: topics.Chat, Template: "^chat$", AccessesSource: "panel"},
+	}), authenticator.ErrUnknownAccessSource)
+
+	// nolint: exhaustruct
+	require.ErrorIs(b.ValidateTopics([]topics.Topic{
+		{Type: topics.Chat, Template: "^chat$", AccessesSource: topics.AccessesSourceRemote},
+	}), remoteaccess.ErrMissingURL)
+
+	// nolint: exhaustruct
+	require.NoError(b.ValidateTopics([]topics.Topic{
+		{
+			Type:           topics.Chat,
+			Template:       "^chat$",
+			AccessesSource: topics.AccessesSourceRemote,
+			RemoteAccesses: &remoteaccess.Config{URL: "http://localhost", Fallback: remoteaccess.FallbackStatic},
+		},
+	}))
+}
+
+func TestBuilderEdDSAKeys(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	pair, err := keygen.Generate(keygen.Options{Algorithm: keygen.Ed25519, Bits: 0, Kid: ""})
+	require.NoError(err)
+
+	// nolint: exhaustruct
+	b := authenticator.Builder{
+		Logger: zap.NewNop(),
+	}
+
+	keys, err := b.GenerateKeys(pair.SigningMethod, map[string]string{"0": string(pair.PublicKey)})
+	require.NoError(err)
+	require.Equal(pair.Public, keys["0"])
+}
+
+// nolint: funlen
+func TestBuilderAggregatedErrors(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	broken := config.SnappVendor()
+	broken.Jwt.SigningMethod = "RSA-512"
+	broken.Keys = map[string]string{topics.DriverIss: "key"}
+	broken.AllowedAccessTypes = []string{"pub", "write"}
+	broken.Topics[3].Accesses = map[string]acl.AccessType{topics.DriverIss: "publish"}
+
+	// nolint: exhaustruct
+	b := authenticator.Builder{
+		Tracer: noop.NewTracerProvider().Tracer(""),
+		Vendors: []config.Vendor{
+			{
+				Company: "admin",
+				Jwt: config.JWT{
+					IssName:       "iss",
+					SubName:       "sub",
+					SigningMethod: "HS512",
+				},
+				Type: "internal",
+				Keys: map[string]string{"system": "c2VjcmV0"},
+			},
+			broken,
+			{
+				Company: "unknown",
+				Type:    "unknown",
+			},
+		},
+		Logger: zap.NewNop(),
+	}
+
+	_, err := b.Authenticators()
+	require.Error(err)
+
+	require.ErrorIs(err, authenticator.ErrUnknownSigningMethod)
+	require.ErrorIs(err, authenticator.ErrMissingIssuerKey)
+	require.ErrorIs(err, authenticator.ErrInvalidAccessType)
+	require.ErrorIs(err, authenticator.ErrInvalidAuthenticator)
+
+	var topicErr authenticator.InvalidTopicAccessError
+
+	require.ErrorAs(err, &topicErr)
+	require.Equal(topics.DriverIss, topicErr.Issuer)
+
+	require.Equal([]string{
+		`vendors[1].allowed_access_types[1]: unknown access type "write": requested access type is invalid`,
+		`vendors[1].topics[3].accesses.0: topic ` + broken.Topics[3].Type + ` has unknown access type "publish" for 0`,
+		`vendors[1].jwt.signing_method: unknown signing method "RSA-512"`,
+		`vendors[1].keys.1: issuer of iss_entity_map has no key`,
+		`vendors[2].type: "unknown": there is no authenticator to support your request`,
+	}, strings.Split(err.Error(), "\n"))
+}
+
+func TestBuilderAccessIssuers(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	vendor := config.SnappVendor()
+	vendor.Jwt.SigningMethod = "HS512"
+	vendor.Keys = map[string]string{topics.DriverIss: "c2VjcmV0", topics.PassengerIss: "c2VjcmV0", "2": "c2VjcmV0"}
+	// issuer 2 has no access on any topic and access key O is a typo of issuer 0.
+	vendor.IssEntityMap["2"] = "box"
+	vendor.Topics[1].Accesses = map[string]acl.AccessType{"O": acl.Pub, topics.Default: acl.None}
+
+	// nolint: exhaustruct
+	b := authenticator.Builder{
+		Vendors: []config.Vendor{vendor},
+		Logger:  zap.NewNop(),
+		Tracer:  noop.NewTracerProvider().Tracer(""),
+	}
+
+	err := b.Validate()
+	require.ErrorIs(err, authenticator.ErrUnknownAccessIssuer)
+	require.EqualError(err,
+		`vendors[0].topics[1].accesses.O: access key is not an issuer of iss_entity_map or extra_issuers "O"`,
+	)
+
+	warnings := b.Warnings()
+	require.Len(warnings, 1)
+	require.ErrorIs(warnings[0], authenticator.ErrIssuerWithoutAccess)
+	require.EqualError(warnings[0], "vendors[0].iss_entity_map.2: issuer has no access on any topic: box")
+
+	// topics with remote accesses may give access to every issuer.
+	remote := slices.Clone(vendor.Topics)
+	remote[0].AccessesSource = topics.AccessesSourceRemote
+	b.Vendors[0].Topics = remote
+	require.Empty(b.Warnings())
+
+	b.Vendors[0].Topics = vendor.Topics
+
+	b.Vendors[0].ExtraIssuers = []string{"O"}
+	require.NoError(b.Validate())
+
+	// static topics give access to issuers and their access keys are checked too.
+	b.Vendors[0].StaticTopics = []topics.StaticTopic{{
+		Pattern:  "snapp/ops/#",
+		Accesses: map[string]acl.AccessType{"2": acl.Sub, "3": acl.Sub},
+	}}
+
+	require.EqualError(b.Validate(),
+		`vendors[0].static_topics[0].accesses.3: access key is not an issuer of iss_entity_map or extra_issuers "3"`,
+	)
+	require.Empty(b.Warnings())
+}
+
+func TestBuilderEntityRules(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	vendor := config.SnappVendor()
+	vendor.Jwt.SigningMethod = "HS512"
+	vendor.Keys = map[string]string{topics.DriverIss: "c2VjcmV0", topics.PassengerIss: "c2VjcmV0"}
+	vendor.IssEntityRules = []topics.EntityRule{
+		{Iss: topics.PassengerIss, Claim: "", Value: "", Entity: topics.Passenger},
+		{Iss: topics.PassengerIss, Claim: "user_kind", Value: "guest", Entity: "guest"},
+	}
+
+	// nolint: exhaustruct
+	b := authenticator.Builder{
+		Vendors: []config.Vendor{vendor},
+		Logger:  zap.NewNop(),
+		Tracer:  noop.NewTracerProvider().Tracer(""),
+	}
+
+	err := b.Validate()
+	require.ErrorIs(err, topics.ErrUnreachableEntityRule)
+	require.ErrorContains(err, "vendors[0].iss_entity_rules[1]: ")
+
+	b.Vendors[0].IssEntityRules = []topics.EntityRule{
+		{Iss: topics.PassengerIss, Claim: "user_kind", Value: "guest", Entity: "guest"},
+		{Iss: topics.PassengerIss, Claim: "", Value: "", Entity: topics.Passenger},
+	}
+	require.NoError(b.Validate())
+}
+
+// nolint: funlen
+func TestBuilderHashData(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	// vendor doesn't hash its subjects, so it has no hashid config.
+	vendor := config.SnappVendor()
+	vendor.Jwt.SigningMethod = "HS512"
+	vendor.Keys = map[string]string{topics.DriverIss: "c2VjcmV0", topics.PassengerIss: "c2VjcmV0"}
+	vendor.HashIDMap = nil
+
+	// nolint: exhaustruct
+	b := authenticator.Builder{
+		Vendors: []config.Vendor{vendor},
+		Logger:  zap.NewNop(),
+		Tracer:  noop.NewTracerProvider().Tracer(""),
+	}
+
+	// cab event topic decodes the subject of driver and passenger.
+	err := b.Validate()
+	require.ErrorIs(err, authenticator.ErrMissingHashData)
+	require.ErrorContains(err, "vendors[0].topics[0].accesses.0:")
+	require.ErrorContains(err, "vendors[0].topics[0].accesses.1:")
+
+	b.Vendors[0].HashIDMap = map[string]topics.HashData{
+		topics.DriverIss:    {Length: 0, Salt: "", Alphabet: "", Type: "sha1"},
+		topics.PassengerIss: {Length: 0, Salt: "", Alphabet: "", Type: topics.HashTypeNone},
+	}
+
+	err = b.Validate()
+	require.ErrorIs(err, topics.ErrUnknownHashType)
+	require.ErrorContains(err, "vendors[0].hashid_map.0.type:")
+
+	b.Vendors[0].HashIDMap[topics.DriverIss] = topics.HashData{
+		Length:   0,
+		Salt:     "",
+		Alphabet: "",
+		Type:     topics.HashTypeNone,
+	}
+
+	vendors, err := b.Authenticators()
+	require.NoError(err)
+
+	token, err := testutil.DriverToken(jwt.SigningMethodHS512, []byte("secret"))
+	require.NoError(err)
+
+	// subjects are used verbatim instead of their decoded hash-id.
+	event := md5.Sum([]byte(topics.EmqCabHashPrefix + "-" + testutil.DefaultSubject)) //nolint: gosec
+
+	for _, topic := range []string{
+		"driver-event-" + hex.EncodeToString(event[:]),
+		"snapp/driver/" + testutil.DefaultSubject + "/location",
+	} {
+		access := acl.Sub
+		if strings.HasSuffix(topic, "location") {
+			access = acl.Pub
+		}
+
+		ok, err := vendors["snapp"].ACL(context.Background(), access, token, topic, 0)
+		require.NoError(err, topic)
+		require.True(ok, topic)
+	}
+}
@@ -0,0 +1,123 @@
+package authenticator
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+)
+
+// Decider decides the topic accesses of a vendor in the topic layer same as the replay of authenticators,
+// so it is built without keys and validator of vendor and never calls the webhooks of topics.
+type Decider struct {
+	Company              string
+	TopicManager         *topics.Manager
+	AllowedAccessTypes   []acl.AccessType
+	JWTConfig            config.JWT
+	AccessQualifierClaim string
+}
+
+// Decision is the matched topic type of a subject and its decision of each access type.
+type Decision struct {
+	// Type is empty when no topic matches.
+	Type     string
+	Accesses map[acl.AccessType]error
+}
+
+// Decide checks the access of subject to the topic in the topic layer.
+func (d Decider) Decide(accessType acl.AccessType, topic string, s Subject) error {
+	return decide(d.TopicManager, d.AllowedAccessTypes, d.JWTConfig, d.AccessQualifierClaim, accessType, topic, s)
+}
+
+// Match matches the topic once and decides the publish and subscribe accesses of subject on it,
+// claims are the other claims of subject which templates can use and the qualifier of subject is
+// taken from them when it is empty. It returns InvalidTopicError when no topic matches.
+func (d Decider) Match(topic string, s Subject, claims map[string]any) (Decision, error) {
+	if s.Qualifier == "" {
+		s.Qualifier = qualifier(claims, d.AccessQualifierClaim)
+	}
+
+	topicTemplate, topic, err := match(d.TopicManager, d.JWTConfig, d.AccessQualifierClaim, topic, s, claims)
+	if err != nil {
+		return Decision{Type: "", Accesses: nil}, err
+	}
+
+	return Decision{
+		Type: topicTemplate.Type,
+		Accesses: map[acl.AccessType]error{
+			acl.Pub: check(d.AllowedAccessTypes, topicTemplate, acl.Pub, topic, s),
+			acl.Sub: check(d.AllowedAccessTypes, topicTemplate, acl.Sub, topic, s),
+		},
+	}, nil
+}
+
+// Deciders validates the vendors and builds the deciders of vendors which have topics or static topics.
+// Errors of all vendors are joined same as Authenticators.
+func (b Builder) Deciders() (map[string]Decider, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+
+	all := make(map[string]Decider)
+	errs := make([]error, 0)
+
+	for i, vendor := range b.Vendors {
+		if len(vendor.Topics) == 0 && len(vendor.StaticTopics) == 0 {
+			continue
+		}
+
+		decider, err := b.decider(vendor)
+		if err != nil {
+			errs = append(errs, ConfigError{Path: fmt.Sprintf("vendors[%d]", i), Err: err})
+
+			continue
+		}
+
+		all[vendor.Company] = decider
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	if len(all) == 0 {
+		return nil, ErrNoAuthenticator
+	}
+
+	return all, nil
+}
+
+func (b Builder) decider(vendor config.Vendor) (Decider, error) {
+	if err := b.ValidateMappers(vendor.IssEntityMap, vendor.IssPeerMap); err != nil {
+		return Decider{}, fmt.Errorf("failed to validate mappers %w", err)
+	}
+
+	if err := b.ValidateTopics(vendor.Topics); err != nil {
+		return Decider{}, fmt.Errorf("failed to validate topics %w", err)
+	}
+
+	allowedAccessTypes, err := b.GetAllowedAccessTypes(vendor.AllowedAccessTypes)
+	if err != nil {
+		return Decider{}, fmt.Errorf("cannot parse allowed access types %w", err)
+	}
+
+	hid, err := topics.NewHashIDManager(vendor.HashIDMap)
+	if err != nil {
+		return Decider{}, fmt.Errorf("cannot create hash-id manager %w", err)
+	}
+
+	manager, err := b.topicManager(vendor, hid, allowedAccessTypes)
+	if err != nil {
+		return Decider{}, err
+	}
+
+	return Decider{
+		Company:              vendor.Company,
+		TopicManager:         manager,
+		AllowedAccessTypes:   allowedAccessTypes,
+		JWTConfig:            vendor.Jwt,
+		AccessQualifierClaim: vendor.AccessQualifierClaim,
+	}, nil
+}
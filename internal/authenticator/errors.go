@@ -12,10 +12,71 @@ var (
 	ErrDecodeHashID         = errors.ErrDecodeHashID
 	ErrInvalidSecret        = errors.ErrInvalidSecret
 	ErrIncorrectPassword    = errors.ErrIncorrectPassword
+	ErrPostAuthorizeDenied  = errors.ErrPostAuthorizeDenied
+	ErrPostAuthorizeFailed  = errors.ErrPostAuthorizeFailed
+	ErrInvalidStaticClient  = errors.ErrInvalidStaticClient
+	ErrStateCheckDenied     = errors.ErrStateCheckDenied
+	ErrStateCheckFailed     = errors.ErrStateCheckFailed
+	ErrUnknownIssuer        = errors.ErrUnknownIssuer
+	ErrUnexpectedMountpoint = errors.ErrUnexpectedMountpoint
+	ErrEmptyCredentials     = errors.ErrEmptyCredentials
+	ErrMissingExpiry        = errors.ErrMissingExpiry
+	ErrUnverifiedToken      = errors.ErrUnverifiedToken
+	ErrInvalidSubjectFormat = errors.ErrInvalidSubjectFormat
+
+	ErrInvalidVerificationKey = errors.ErrInvalidVerificationKey
 )
 
 type TopicNotAllowedError = errors.TopicNotAllowedError
 
+const (
+	ReasonNoAccess             = errors.ReasonNoAccess
+	ReasonSubscribeOnly        = errors.ReasonSubscribeOnly
+	ReasonPublishOnly          = errors.ReasonPublishOnly
+	ReasonMissingField         = errors.ReasonMissingField
+	ReasonTemplateFailed       = errors.ReasonTemplateFailed
+	ReasonUnknownIssuer        = errors.ReasonUnknownIssuer
+	ReasonUnexpectedMountpoint = errors.ReasonUnexpectedMountpoint
+
+	ReasonSubscriptionLimitExceeded = errors.ReasonSubscriptionLimitExceeded
+	ReasonEmptyCredentials          = errors.ReasonEmptyCredentials
+	ReasonRideMismatch              = errors.ReasonRideMismatch
+	ReasonInvalidTopicField         = errors.ReasonInvalidTopicField
+	ReasonPayloadMismatch           = errors.ReasonPayloadMismatch
+	ReasonPolicyDenied              = errors.ReasonPolicyDenied
+	ReasonMalformedCredential       = errors.ReasonMalformedCredential
+	ReasonPayloadTooLarge           = errors.ReasonPayloadTooLarge
+)
+
 type KeyNotFoundError = errors.KeyNotFoundError
 
 type InvalidTopicError = errors.InvalidTopicError
+
+type PayloadTooLargeError = errors.PayloadTooLargeError
+
+type SubscriptionLimitExceededError = errors.SubscriptionLimitExceededError
+
+type RideMismatchError = errors.RideMismatchError
+
+type InvalidTopicFieldError = errors.InvalidTopicFieldError
+
+type PayloadMismatchError = errors.PayloadMismatchError
+
+type PolicyDeniedError = errors.PolicyDeniedError
+
+type InvalidTopicAccessError = errors.InvalidTopicAccessError
+
+type MalformedTopicError = errors.MalformedTopicError
+
+type MalformedCredentialError = errors.MalformedCredentialError
+
+type IATSkewError = errors.IATSkewError
+
+type TemplateRenderError = errors.TemplateRenderError
+
+type InvalidAccessTypeError = errors.InvalidAccessTypeError
+
+const (
+	AccessTypeLevelVendor = errors.AccessTypeLevelVendor
+	AccessTypeLevelTopic  = errors.AccessTypeLevelTopic
+)
@@ -0,0 +1,104 @@
+package authenticator
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/snapp-incubator/soteria/internal/config"
+)
+
+var ErrDuplicateAuthenticatorType = errors.New("authenticator type is already registered")
+
+// Factory builds the authenticator of a vendor, builder provides the shared dependencies
+// like logger, tracer and validator configuration.
+type Factory func(b Builder, vendor config.Vendor) (Authenticator, error)
+
+// factories maps the vendor types to their authenticator factories.
+// nolint: gochecknoglobals
+var factories = struct {
+	lock sync.RWMutex
+	all  map[string]Factory
+}{
+	lock: sync.RWMutex{},
+	all:  builtinFactories(),
+}
+
+func builtinFactories() map[string]Factory {
+	auto := func(b Builder, vendor config.Vendor) (Authenticator, error) {
+		auth, err := b.autoAuthenticator(vendor)
+		if err != nil {
+			return nil, fmt.Errorf("cannot build auto authenticator %w", err)
+		}
+
+		return auth, nil
+	}
+
+	admin := func(b Builder, vendor config.Vendor) (Authenticator, error) {
+		auth, err := b.adminAuthenticator(vendor)
+		if err != nil {
+			return nil, fmt.Errorf("cannot build admin authenticator %w", err)
+		}
+
+		return auth, nil
+	}
+
+	manual := func(b Builder, vendor config.Vendor) (Authenticator, error) {
+		auth, err := b.manualAuthenticator(vendor)
+		if err != nil {
+			return nil, fmt.Errorf("cannot build manual authenticator %w", err)
+		}
+
+		return auth, nil
+	}
+
+	return map[string]Factory{
+		"auto":            auto,
+		"validator":       auto,
+		"validator-based": auto,
+		"using-validator": auto,
+		"admin":           admin,
+		"internal":        admin,
+		"manual":          manual,
+	}
+}
+
+// Register adds a new authenticator type, so vendors which have it as their type
+// are built by the given factory. Types cannot be registered twice.
+func Register(kind string, factory Factory) error {
+	factories.lock.Lock()
+	defer factories.lock.Unlock()
+
+	if _, ok := factories.all[kind]; ok {
+		return fmt.Errorf("%s: %w", kind, ErrDuplicateAuthenticatorType)
+	}
+
+	factories.all[kind] = factory
+
+	return nil
+}
+
+// Types returns the sorted list of registered authenticator types.
+func Types() []string {
+	factories.lock.RLock()
+	defer factories.lock.RUnlock()
+
+	kinds := make([]string, 0, len(factories.all))
+	for kind := range factories.all {
+		kinds = append(kinds, kind)
+	}
+
+	slices.Sort(kinds)
+
+	return kinds
+}
+
+func factory(kind string) (Factory, bool) {
+	factories.lock.RLock()
+	defer factories.lock.RUnlock()
+
+	f, ok := factories.all[kind]
+
+	return f, ok
+}
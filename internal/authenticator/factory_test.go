@@ -0,0 +1,70 @@
+package authenticator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+)
+
+// allowAuthenticator allows everything and shows how custom authenticators are registered.
+type allowAuthenticator struct {
+	company string
+}
+
+func (a allowAuthenticator) Auth(_ context.Context, _ string) error {
+	return nil
+}
+
+func (a allowAuthenticator) ACL(_ context.Context, _ acl.AccessType, _ string, _ string, _ int) (bool, error) {
+	return true, nil
+}
+
+func (a allowAuthenticator) ValidateAccessType(_ acl.AccessType) bool {
+	return true
+}
+
+func (a allowAuthenticator) GetCompany() string {
+	return a.company
+}
+
+func (a allowAuthenticator) IsSuperuser() bool {
+	return false
+}
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	require.NoError(authenticator.Register("allow", func(_ authenticator.Builder, vendor config.Vendor) (authenticator.Authenticator, error) {
+		return allowAuthenticator{company: vendor.Company}, nil
+	}))
+
+	require.ErrorIs(authenticator.Register("manual", nil), authenticator.ErrDuplicateAuthenticatorType)
+	require.Contains(authenticator.Types(), "allow")
+	require.Contains(authenticator.Types(), "manual")
+
+	// nolint: exhaustruct
+	b := authenticator.Builder{
+		Vendors: []config.Vendor{
+			{
+				Company: "allow",
+				Type:    "allow",
+			},
+		},
+		Logger: zap.NewNop(),
+		Tracer: noop.NewTracerProvider().Tracer(""),
+	}
+
+	auths, err := b.Authenticators()
+	require.NoError(err)
+	require.Len(auths, 1)
+	require.Equal("allow", auths["allow"].GetCompany())
+	require.NoError(auths["allow"].Auth(context.Background(), ""))
+}
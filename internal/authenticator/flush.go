@@ -0,0 +1,19 @@
+package authenticator
+
+// FlushAuthenticator is implemented by authenticators which cache the results of their services,
+// caches of each vendor are flushed without the caches of the other vendors.
+type FlushAuthenticator interface {
+	// Flush removes the cached validations, webhook decisions and states of vendor.
+	Flush()
+}
+
+// Flush removes the cached webhook decisions and states of vendor.
+func (a ManualAuthenticator) Flush() {
+	a.TopicManager.Flush()
+}
+
+// Flush removes the cached validations, webhook decisions and states of vendor.
+func (a AutoAuthenticator) Flush() {
+	a.Validations.Flush()
+	a.TopicManager.Flush()
+}
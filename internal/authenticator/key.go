@@ -4,10 +4,11 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"maps"
 	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
-	"go.uber.org/zap"
+	"github.com/snapp-incubator/soteria/internal/config"
 )
 
 var (
@@ -23,12 +24,14 @@ func (b Builder) GenerateKeys(method string, keys map[string]string) (map[string
 
 	// https://jwt.io/
 	switch {
-	case strings.HasPrefix(method, "RS"):
+	case strings.HasPrefix(method, "RS"), strings.HasPrefix(method, "PS"):
 		keyList, err = b.GenerateRSAKeys(keys)
 	case strings.HasPrefix(method, "HS"):
 		keyList, err = b.GenerateHMACKeys(keys)
 	case strings.HasPrefix(method, "ES"):
 		keyList, err = b.GenerateECDSAKeys(keys)
+	case method == jwt.SigningMethodEdDSA.Alg():
+		keyList, err = b.GenerateEdDSAKeys(keys)
 	default:
 		return nil, ErrInvalidKeyType
 	}
@@ -44,13 +47,46 @@ func (b Builder) GenerateKeys(method string, keys map[string]string) (map[string
 	return keyList, nil
 }
 
+// GenerateIssuerKeys reads the keys of issuers using their signing methods,
+// issuers without their own signing method use the signing method of vendor.
+func (b Builder) GenerateIssuerKeys(cfg config.JWT, raw map[string]string) (map[string]any, error) {
+	if len(raw) == 0 {
+		return b.GenerateKeys(cfg.SigningMethod, raw)
+	}
+
+	byMethod := make(map[string]map[string]string)
+
+	for iss, key := range raw {
+		method := cfg.IssuerSigningMethod(iss)
+
+		if byMethod[method] == nil {
+			byMethod[method] = make(map[string]string)
+		}
+
+		byMethod[method][iss] = key
+	}
+
+	keys := make(map[string]any, len(raw))
+
+	for method, list := range byMethod {
+		generated, err := b.GenerateKeys(method, list)
+		if err != nil {
+			return nil, err
+		}
+
+		maps.Copy(keys, generated)
+	}
+
+	return keys, nil
+}
+
 func (b Builder) GenerateRSAKeys(raw map[string]string) (map[string]any, error) {
 	keys := make(map[string]any)
 
 	for iss, publicKey := range raw {
 		bytes, err := jwt.ParseRSAPublicKeyFromPEM([]byte(publicKey))
 		if err != nil {
-			b.Logger.Fatal("could not read public key", zap.String("issuer", iss), zap.Error(err))
+			return nil, fmt.Errorf("could not read public key of issuer %s %w", iss, err)
 		}
 
 		keys[iss] = bytes
@@ -65,7 +101,22 @@ func (b Builder) GenerateECDSAKeys(raw map[string]string) (map[string]any, error
 	for iss, publicKey := range raw {
 		bytes, err := jwt.ParseECPublicKeyFromPEM([]byte(publicKey))
 		if err != nil {
-			b.Logger.Fatal("could not read public key", zap.String("issuer", iss), zap.Error(err))
+			return nil, fmt.Errorf("could not read public key of issuer %s %w", iss, err)
+		}
+
+		keys[iss] = bytes
+	}
+
+	return keys, nil
+}
+
+func (b Builder) GenerateEdDSAKeys(raw map[string]string) (map[string]any, error) {
+	keys := make(map[string]any)
+
+	for iss, publicKey := range raw {
+		bytes, err := jwt.ParseEdPublicKeyFromPEM([]byte(publicKey))
+		if err != nil {
+			return nil, fmt.Errorf("could not read public key of issuer %s %w", iss, err)
 		}
 
 		keys[iss] = bytes
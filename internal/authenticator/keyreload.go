@@ -0,0 +1,212 @@
+package authenticator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/metric"
+	"go.uber.org/zap"
+)
+
+const (
+	KeyReloadChanged   = "changed"
+	KeyReloadUnchanged = "unchanged"
+	KeyReloadFailed    = "failed"
+)
+
+var ErrMissingReloadedKey = errors.New("reloaded keys miss a key of issuer")
+
+// KeyReloadAuthenticator is implemented by authenticators which can reload their keys from the keys
+// directory of vendor, the reloader is nil for vendors without keys directory.
+type KeyReloadAuthenticator interface {
+	KeyReloader() *KeyReloader
+}
+
+// KeyReloader reloads the keys of a vendor from its keys directory without reloading the configuration.
+// Keys are parsed before they are swapped, so requests use either the old or the new keys and
+// the old keys are kept when the new ones cannot be parsed. Removing a file keeps the last key of its issuer.
+type KeyReloader struct {
+	vendor   string
+	dir      string
+	jwt      config.JWT
+	generate func(config.JWT, map[string]string) (map[string]any, error)
+
+	// lock serializes the reloads, keys are read without it.
+	lock     sync.Mutex
+	raw      map[string]string
+	keys     atomic.Pointer[map[string]any]
+	registry *KeyRegistry
+	metrics  *metric.KeyMetrics
+	logger   *zap.Logger
+}
+
+func (b Builder) keyReloader(vendor config.Vendor, keys map[string]any) *KeyReloader {
+	if vendor.KeysDir == "" {
+		return nil
+	}
+
+	r := &KeyReloader{
+		vendor:   vendor.Company,
+		dir:      vendor.KeysDir,
+		jwt:      vendor.Jwt,
+		generate: b.GenerateIssuerKeys,
+		lock:     sync.Mutex{},
+		raw:      maps.Clone(vendor.Keys),
+		keys:     atomic.Pointer[map[string]any]{},
+		registry: b.KeyRegistry,
+		metrics:  metric.NewKeyMetrics(b.registerer()),
+		logger:   b.Logger.Named("keys").With(zap.String("vendor", vendor.Company), zap.String("dir", vendor.KeysDir)),
+	}
+
+	r.keys.Store(&keys)
+
+	return r
+}
+
+// Keys returns the current keys of issuers.
+func (r *KeyReloader) Keys() map[string]any {
+	return *r.keys.Load()
+}
+
+// Dir returns the keys directory of vendor.
+func (r *KeyReloader) Dir() string {
+	return r.dir
+}
+
+// Reload reads the keys directory and swaps the keys when all of them are parsed,
+// otherwise the old keys are kept and the error is returned.
+func (r *KeyReloader) Reload() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	raw, keys, err := r.read()
+	if err != nil {
+		r.metrics.Reloaded(r.vendor, KeyReloadFailed)
+		r.logger.Error("keys reload failed, old keys are kept", zap.Error(err))
+
+		return err
+	}
+
+	old := r.Keys()
+	changed := false
+
+	for _, iss := range slices.Sorted(maps.Keys(keys)) {
+		oldFingerprint, newFingerprint := Fingerprint(old[iss]), Fingerprint(keys[iss])
+		if oldFingerprint == newFingerprint {
+			continue
+		}
+
+		changed = true
+
+		r.logger.Info("key changed",
+			zap.String("issuer", iss),
+			zap.String("old-fingerprint", oldFingerprint),
+			zap.String("new-fingerprint", newFingerprint),
+		)
+	}
+
+	if !changed {
+		r.metrics.Reloaded(r.vendor, KeyReloadUnchanged)
+
+		return nil
+	}
+
+	r.raw = raw
+	r.keys.Store(&keys)
+
+	if r.registry != nil {
+		r.registry.Set(r.vendor, raw, keys)
+	}
+
+	r.metrics.Reloaded(r.vendor, KeyReloadChanged)
+
+	return nil
+}
+
+func (r *KeyReloader) read() (map[string]string, map[string]any, error) {
+	files, err := config.ReadKeysDir(r.dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading keys failed %w", err)
+	}
+
+	raw := maps.Clone(r.raw)
+	maps.Copy(raw, files)
+
+	keys, err := r.generate(r.jwt, raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading keys failed %w", err)
+	}
+
+	for iss := range r.Keys() {
+		if _, ok := keys[iss]; !ok {
+			return nil, nil, fmt.Errorf("%w %s", ErrMissingReloadedKey, iss)
+		}
+	}
+
+	return raw, keys, nil
+}
+
+// Watch reloads the keys on each change of the keys directory until the context is done. The directory
+// itself is watched, so the atomic swap of ..data symlink of Kubernetes secret volumes is seen as a change.
+func (r *KeyReloader) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("cannot create keys watcher %w", err)
+	}
+
+	if err := watcher.Add(r.dir); err != nil {
+		_ = watcher.Close()
+
+		return fmt.Errorf("cannot watch keys directory %s %w", r.dir, err)
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+					continue
+				}
+
+				_ = r.Reload()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				r.logger.Error("keys watcher failed", zap.Error(err))
+			}
+		}
+	}()
+
+	return nil
+}
+
+// KeyReloader returns the reloader of keys directory of vendor, it is nil for vendors without keys directory.
+func (a ManualAuthenticator) KeyReloader() *KeyReloader {
+	return a.Reloader
+}
+
+// keys returns the reloaded keys of issuers when vendor has a keys directory.
+func (a ManualAuthenticator) keys() map[string]any {
+	if a.Reloader != nil {
+		return a.Reloader.Keys()
+	}
+
+	return a.Keys
+}
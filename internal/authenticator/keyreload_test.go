@@ -0,0 +1,111 @@
+package authenticator_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/keygen"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+)
+
+// nolint: funlen
+func TestKeyReloader(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	dir := t.TempDir()
+
+	// the public key of driver issuer is written into the keys directory.
+	write := func(pair keygen.KeyPair) {
+		require.NoError(os.WriteFile(filepath.Join(dir, topics.DriverIss+".pem"), pair.PublicKey, 0o600))
+	}
+
+	sign := func(pair keygen.KeyPair) string {
+		key, err := jwt.ParseEdPrivateKeyFromPEM(pair.PrivateKey)
+		require.NoError(err)
+
+		// nolint: exhaustruct
+		token, err := testutil.Token(jwt.SigningMethodEdDSA, key, testutil.Claims{
+			Issuer:  topics.DriverIss,
+			Subject: testutil.DefaultSubject,
+		})
+		require.NoError(err)
+
+		return token
+	}
+
+	generate := func() keygen.KeyPair {
+		pair, err := keygen.Generate(keygen.Options{Algorithm: keygen.Ed25519, Bits: 0, Kid: ""})
+		require.NoError(err)
+
+		return pair
+	}
+
+	first := generate()
+	write(first)
+
+	vendor := config.SnappVendor()
+	vendor.Jwt.SigningMethod = jwt.SigningMethodEdDSA.Alg()
+	vendor.Jwt.IssuerSigningMethods = map[string]string{topics.PassengerIss: "RS512"}
+	vendor.KeysDir = dir
+	delete(vendor.Keys, topics.DriverIss)
+
+	cfg := config.Config{Vendors: []config.Vendor{vendor}} // nolint: exhaustruct
+	require.NoError(cfg.ReadKeysDirs())
+
+	// nolint: exhaustruct
+	auths, err := authenticator.Builder{
+		Vendors: cfg.Vendors,
+		Logger:  zap.NewNop(),
+		Tracer:  noop.NewTracerProvider().Tracer(""),
+	}.Authenticators()
+	require.NoError(err)
+
+	auth := auths[vendor.Company]
+
+	reloadable, ok := auth.(authenticator.KeyReloadAuthenticator)
+	require.True(ok)
+
+	reloader := reloadable.KeyReloader()
+	require.NotNil(reloader)
+
+	ctx := context.Background()
+
+	require.NoError(auth.Auth(ctx, sign(first)))
+	require.NoError(reloader.Reload())
+
+	second := generate()
+	write(second)
+
+	require.NoError(reloader.Reload())
+	require.NoError(auth.Auth(ctx, sign(second)))
+	require.Error(auth.Auth(ctx, sign(first)))
+
+	// keys which cannot be parsed are not swapped, so the old key is kept.
+	require.NoError(os.WriteFile(filepath.Join(dir, topics.DriverIss+".pem"), []byte("not a key"), 0o600))
+	require.Error(reloader.Reload())
+	require.NoError(auth.Auth(ctx, sign(second)))
+
+	watch, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	require.NoError(reloader.Watch(watch))
+
+	third := generate()
+	write(third)
+
+	require.Eventually(func() bool {
+		return auth.Auth(ctx, sign(third)) == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
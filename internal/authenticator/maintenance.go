@@ -0,0 +1,55 @@
+package authenticator
+
+import (
+	"context"
+	"errors"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/snapp-incubator/soteria/internal/credential"
+)
+
+type maintenanceKey struct{}
+
+// WithMaintenance marks the requests of vendors in maintenance, their tokens are not verified by the keys
+// or the validator because the identity provider of vendor is expected to fail them.
+func WithMaintenance(ctx context.Context) context.Context {
+	return context.WithValue(ctx, maintenanceKey{}, true)
+}
+
+// InMaintenance reports whether the request belongs to a vendor in maintenance.
+func InMaintenance(ctx context.Context) bool {
+	maintenance, _ := ctx.Value(maintenanceKey{}).(bool)
+
+	return maintenance
+}
+
+// parse verifies the token using the key function, tokens of vendors in maintenance are parsed
+// without verification but their time based claims like exp are still validated.
+// Tokens which cannot be decoded are MalformedCredentialError without the decoding error message.
+func parse(ctx context.Context, parser *jwt.Parser, tokenString string, keyFunc jwt.Keyfunc) (*jwt.Token, error) {
+	if !InMaintenance(ctx) {
+		token, err := parser.Parse(tokenString, keyFunc)
+
+		return token, malformed(tokenString, err)
+	}
+
+	token, _, err := parser.ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return nil, malformed(tokenString, err)
+	}
+
+	if err := jwt.NewValidator().Validate(token.Claims); err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	return token, nil
+}
+
+// malformed replaces the decoding errors of token, like illegal base64 data, with MalformedCredentialError.
+func malformed(tokenString string, err error) error {
+	if !errors.Is(err, jwt.ErrTokenMalformed) {
+		return err
+	}
+
+	return MalformedCredentialError{Reason: credential.MalformedEncoding, Length: len(tokenString), Err: err}
+}
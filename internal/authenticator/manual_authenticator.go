@@ -3,9 +3,16 @@ package authenticator
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/snapp-incubator/soteria/internal/budget"
+	"github.com/snapp-incubator/soteria/internal/claimguard"
 	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/failratio"
+	"github.com/snapp-incubator/soteria/internal/flags"
+	"github.com/snapp-incubator/soteria/internal/metric"
+	"github.com/snapp-incubator/soteria/internal/policy"
 	"github.com/snapp-incubator/soteria/internal/topics"
 	"github.com/snapp-incubator/soteria/pkg/acl"
 	"github.com/snapp-incubator/soteria/pkg/strconv"
@@ -20,11 +27,46 @@ type ManualAuthenticator struct {
 	Company            string
 	JWTConfig          config.JWT
 	Parser             *jwt.Parser
+	// Flags are the runtime feature flags, nil flags use the default values.
+	Flags *flags.Flags
+	// AccessQualifierClaim is the claim which qualifies the issuer in topic accesses.
+	AccessQualifierClaim string
+	// VerificationKeys are used instead of Keys for issuers which have them.
+	VerificationKeys map[string][]VerificationKey
+	// KeyMetrics counts the verified tokens by their key, it is optional.
+	KeyMetrics *metric.KeyMetrics
+	// StaticClients use username and password instead of token.
+	StaticClients StaticClients
+	// FailureRatio tracks the authentication failures of issuers, it is optional.
+	FailureRatio *failratio.Tracker
+	// ClaimGuard samples the claim names of parsed tokens, it is optional.
+	ClaimGuard *claimguard.Guard
+	// NoExpiry accepts the tokens without exp claim of its subjects, nil rejects all of them.
+	NoExpiry *NoExpiry
+	// SubjectFormats reject the subjects which don't have the format of their issuer, nil accepts all of them.
+	SubjectFormats *SubjectFormats
+	// Reloader reloads Keys from the keys directory of vendor and is used instead of them, it is optional.
+	Reloader *KeyReloader
+	// Policy evaluates the accesses of topics after or instead of their static accesses, it is optional.
+	Policy *policy.Policy
 }
 
 // Auth check user authentication by checking the user's token.
-func (a ManualAuthenticator) Auth(_ context.Context, tokenString string) error {
-	_, err := a.Parser.Parse(tokenString, func(
+func (a ManualAuthenticator) Auth(ctx context.Context, tokenString string) error {
+	_, err := a.AuthWithAttrs(ctx, tokenString)
+
+	return err
+}
+
+// AuthWithAttrs check user authentication by checking the user's token and
+// returns client attributes from its claims when they are enabled for the vendor.
+func (a ManualAuthenticator) AuthWithAttrs(ctx context.Context, tokenString string) (*ClientAttrs, error) {
+	budget.SetStage(ctx, budget.StageParseToken)
+
+	verified := func() {}
+	tracked := failratio.UnknownIssuer
+
+	token, err := parse(ctx, a.Parser, tokenString, func(
 		token *jwt.Token,
 	) (interface{}, error) {
 		claims, ok := token.Claims.(jwt.MapClaims)
@@ -32,39 +74,101 @@ func (a ManualAuthenticator) Auth(_ context.Context, tokenString string) error {
 			return nil, ErrInvalidClaims
 		}
 
+		a.ClaimGuard.Record(a.Company, guarded(a.JWTConfig), claims)
+
 		if claims[a.JWTConfig.IssName] == nil {
 			return nil, ErrIssNotFound
 		}
 
 		issuer := fmt.Sprintf("%v", claims[a.JWTConfig.IssName])
 
-		key := a.Keys[issuer]
-		if key == nil {
-			return nil, KeyNotFoundError{Issuer: issuer}
+		// only issuers which have keys are tracked, so unverified tokens cannot add issuers.
+		if a.knownIssuer(issuer) {
+			tracked = issuer
+		}
+
+		if err := a.checkSigningMethod(token, issuer); err != nil {
+			return nil, err
+		}
+
+		key, kid, err := verificationKey(token, issuer, a.keys(), a.VerificationKeys)
+		if err != nil {
+			return nil, err
+		}
+
+		verified = func() {
+			a.verified(issuer, kid)
 		}
 
 		return key, nil
 	})
+
+	a.FailureRatio.Record(a.Company, tracked, err)
+
 	if err != nil {
-		return fmt.Errorf("token is invalid: %w", err)
+		return nil, fmt.Errorf("token is invalid: %w", err)
 	}
 
-	return nil
+	verified()
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidClaims
+	}
+
+	if err := a.SubjectFormats.Check(
+		ctx, strconv.ToString(claims[a.JWTConfig.IssName]), strconv.ToString(claims[a.JWTConfig.SubName]),
+	); err != nil {
+		return nil, err
+	}
+
+	if _, err := a.NoExpiry.Check(claims, strconv.ToString(claims[a.JWTConfig.SubName])); err != nil {
+		return nil, err
+	}
+
+	if !a.Flags.EmitClientAttrs(a.Company) {
+		return nil, nil //nolint: nilnil
+	}
+
+	return clientAttrs(claims, a.JWTConfig, a.TopicManager, a.Company), nil
 }
 
 // ACL check a user access to a topic.
-// nolint: funlen, cyclop
 func (a ManualAuthenticator) ACL(
-	_ context.Context,
+	ctx context.Context,
 	accessType acl.AccessType,
 	tokenString string,
 	topic string,
+	payloadSize int,
 ) (bool, error) {
-	if !a.ValidateAccessType(accessType) {
-		return false, ErrInvalidAccessType
+	if _, err := a.ACLWithTopic(ctx, accessType, tokenString, topic, payloadSize); err != nil {
+		return false, err
 	}
 
-	token, err := a.Parser.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+	return true, nil
+}
+
+// ACLWithTopic checks the access of user to a topic and returns the matched topic type and entity of user
+// when access is allowed.
+// nolint: funlen, cyclop
+func (a ManualAuthenticator) ACLWithTopic(
+	ctx context.Context,
+	accessType acl.AccessType,
+	tokenString string,
+	topic string,
+	payloadSize int,
+) (*TopicAttrs, error) {
+	// access types are checked against the allowed access types after topic is matched,
+	// because topics can override them.
+	if !accessType.IsValid() {
+		return nil, ErrInvalidAccessType
+	}
+
+	budget.SetStage(ctx, budget.StageParseToken)
+
+	verified := func() {}
+
+	token, err := parse(ctx, a.Parser, tokenString, func(token *jwt.Token) (interface{}, error) {
 		claims, ok := token.Claims.(jwt.MapClaims)
 		if !ok {
 			return nil, ErrInvalidClaims
@@ -80,50 +184,227 @@ func (a ManualAuthenticator) ACL(
 
 		issuer := fmt.Sprintf("%v", claims[a.JWTConfig.IssName])
 
-		key := a.Keys[issuer]
-		if key == nil {
-			return nil, KeyNotFoundError{Issuer: issuer}
+		if err := a.checkSigningMethod(token, issuer); err != nil {
+			return nil, err
+		}
+
+		key, kid, err := verificationKey(token, issuer, a.keys(), a.VerificationKeys)
+		if err != nil {
+			return nil, err
+		}
+
+		verified = func() {
+			a.verified(issuer, kid)
 		}
 
 		return key, nil
 	})
 	if err != nil {
-		return false, fmt.Errorf("token is invalid: %w", err)
+		return nil, fmt.Errorf("token is invalid: %w", err)
 	}
 
+	verified()
+
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return false, ErrInvalidClaims
+		return nil, ErrInvalidClaims
 	}
 
 	if claims[a.JWTConfig.IssName] == nil {
-		return false, ErrIssNotFound
+		return nil, ErrIssNotFound
 	}
 
 	issuer := strconv.ToString(claims[a.JWTConfig.IssName])
 
 	if claims[a.JWTConfig.SubName] == nil {
-		return false, ErrSubNotFound
+		return nil, ErrSubNotFound
 	}
 
 	sub := strconv.ToString(claims[a.JWTConfig.SubName])
 
-	topicTemplate := a.TopicManager.ParseTopic(topic, issuer, sub, map[string]any(claims))
+	recordSubject(ctx, Subject{
+		Issuer:    issuer,
+		Sub:       sub,
+		Qualifier: qualifier(claims, a.AccessQualifierClaim),
+		ID:        strconv.ToString(claims["jti"]),
+	})
+
+	// the decoded subject is reused by the templates of request.
+	ctx = topics.WithDecodedSubjects(ctx)
+
+	if err := a.SubjectFormats.Check(ctx, issuer, sub); err != nil {
+		return nil, err
+	}
+
+	restricted, err := a.NoExpiry.Check(claims, sub)
+	if err != nil {
+		return nil, err
+	}
+
+	budget.SetStage(ctx, budget.StageParseTopic)
+
+	// the checks after matching use the normalized topic too.
+	topic = a.TopicManager.Normalize(topic)
+
+	topicTemplate, err := a.TopicManager.ParseTopic(ctx, topic, issuer, sub, map[string]any(claims))
+	if err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
 	if topicTemplate == nil {
-		return false, InvalidTopicError{Topic: topic}
+		return nil, InvalidTopicError{Topic: topic}
+	}
+
+	budget.SetTopicType(ctx, topicTemplate.Type)
+
+	if err := checkAccessType(a.AllowedAccessTypes, topicTemplate, accessType); err != nil {
+		return nil, err
 	}
 
-	if !topicTemplate.HasAccess(issuer, accessType) {
-		return false, TopicNotAllowedError{
+	// tokens without exp claim can only access the topic types of no expiry.
+	if restricted && !a.NoExpiry.Allows(topicTemplate.Type) {
+		return nil, TopicNotAllowedError{
 			Issuer:     issuer,
 			Sub:        sub,
 			AccessType: accessType,
 			Topic:      topic,
 			TopicType:  topicTemplate.Type,
+			Granted:    acl.None,
 		}
 	}
 
-	return true, nil
+	granted := a.TopicManager.Access(ctx, topicTemplate, issuer, qualifier(claims, a.AccessQualifierClaim), claims)
+
+	allowed := granted.Allows(accessType)
+
+	if a.Policy != nil {
+		allowed, err = a.Policy.Allows(ctx, allowed, policy.Input{
+			Company:     a.Company,
+			Claims:      map[string]any(claims),
+			Issuer:      issuer,
+			Sub:         sub,
+			Entity:      a.TopicManager.Entity(issuer, claims),
+			Topic:       topic,
+			TopicType:   topicTemplate.Type,
+			Access:      accessType.String(),
+			ClientAttrs: clientAttrs(claims, a.JWTConfig, a.TopicManager, a.Company),
+			Static:      allowed,
+		})
+		if err != nil {
+			return nil, err //nolint: wrapcheck
+		}
+	}
+
+	if !allowed {
+		return nil, TopicNotAllowedError{
+			Issuer:     issuer,
+			Sub:        sub,
+			AccessType: accessType,
+			Topic:      topic,
+			TopicType:  topicTemplate.Type,
+			Granted:    granted,
+		}
+	}
+
+	if err := topicTemplate.CheckRide(topic, issuer, sub, map[string]any(claims)); err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	if err := topicTemplate.CheckFields(topic, issuer, sub); err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	if err := topicTemplate.CheckPayload(
+		topic, issuer, sub, map[string]any(claims), accessType, PayloadFromContext(ctx),
+	); err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	if !topicTemplate.AllowsPayload(accessType, payloadSize) {
+		return nil, PayloadTooLargeError{
+			Topic:     topic,
+			TopicType: topicTemplate.Type,
+			Size:      payloadSize,
+			MaxSize:   topicTemplate.MaxPayloadBytes,
+		}
+	}
+
+	budget.SetStage(ctx, budget.StageStateCheck)
+
+	if err := a.TopicManager.CheckState(ctx, topicTemplate, topic, issuer, sub, map[string]any(claims)); err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	budget.SetStage(ctx, budget.StagePostAuthorize)
+
+	if err := topicTemplate.PostAuthorize(ctx, issuer, sub, topic, accessType); err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	// subscriptions are counted only when they are allowed.
+	if err := topicTemplate.LimitSubscription(issuer, sub, topic, accessType); err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	if !a.Flags.EmitTopicType(a.Company) {
+		return nil, nil //nolint: nilnil
+	}
+
+	return &TopicAttrs{
+		TopicType: topicTemplate.Type,
+		Entity:    a.TopicManager.Entity(issuer, claims),
+	}, nil
+}
+
+// checkSigningMethod rejects tokens which are not signed by the signing method of their issuer,
+// so an RS256 token cannot be verified by the key of a PS256 issuer. The parser checks the signing method
+// of vendors without issuer signing methods.
+func (a ManualAuthenticator) checkSigningMethod(token *jwt.Token, issuer string) error {
+	if len(a.JWTConfig.IssuerSigningMethods) == 0 {
+		return nil
+	}
+
+	method := a.JWTConfig.IssuerSigningMethod(issuer)
+	if token.Method.Alg() == method {
+		return nil
+	}
+
+	return fmt.Errorf("%w: issuer %s requires %s but token is signed by %s",
+		ErrInvalidSigningMethod, issuer, method, token.Method.Alg())
+}
+
+// verified counts the tokens which are verified by the issuer key with the given kid.
+func (a ManualAuthenticator) verified(issuer, kid string) {
+	if a.KeyMetrics != nil {
+		a.KeyMetrics.Verified(a.Company, issuer, kid)
+	}
+}
+
+// KnowsIssuer checks the vendor has keys for the issuer of the unverified token.
+func (a ManualAuthenticator) KnowsIssuer(tokenString string) bool {
+	var claims jwt.MapClaims
+
+	if _, _, err := a.Parser.ParseUnverified(tokenString, &claims); err != nil || claims[a.JWTConfig.IssName] == nil {
+		return false
+	}
+
+	return a.knownIssuer(strconv.ToString(claims[a.JWTConfig.IssName]))
+}
+
+// TokenExpiry returns the exp claim of the authenticated token.
+func (a ManualAuthenticator) TokenExpiry(tokenString string) (time.Time, bool) {
+	return tokenExpiry(a.Parser, tokenString)
+}
+
+// knownIssuer returns true when issuer has a key or verification keys.
+func (a ManualAuthenticator) knownIssuer(issuer string) bool {
+	if _, ok := a.keys()[issuer]; ok {
+		return true
+	}
+
+	_, ok := a.VerificationKeys[issuer]
+
+	return ok
 }
 
 func (a ManualAuthenticator) ValidateAccessType(accessType acl.AccessType) bool {
@@ -136,6 +417,11 @@ func (a ManualAuthenticator) ValidateAccessType(accessType acl.AccessType) bool
 	return false
 }
 
+// StaticClient returns the static client with the given username.
+func (a ManualAuthenticator) StaticClient(username string) (StaticClient, bool) {
+	return a.StaticClients.StaticClient(username)
+}
+
 func (a ManualAuthenticator) GetCompany() string {
 	return a.Company
 }
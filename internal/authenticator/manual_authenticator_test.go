@@ -3,15 +3,26 @@ package authenticator_test
 import (
 	"context"
 	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/snapp-incubator/soteria/internal/authenticator"
 	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/credential"
+	"github.com/snapp-incubator/soteria/internal/failratio"
+	"github.com/snapp-incubator/soteria/internal/statecheck"
 	"github.com/snapp-incubator/soteria/internal/topics"
 	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/snapp-incubator/soteria/pkg/testutil"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel/trace/noop"
 	"go.uber.org/zap"
 )
 
@@ -126,32 +137,32 @@ func (suite *ManualAuthenticatorSnappTestSuite) TestACLBasics() {
 	require := suite.Require()
 
 	suite.Run("testing acl with invalid access type", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), "invalid-access", suite.Tokens.Passenger, "test")
+		ok, err := suite.Authenticator.ACL(context.Background(), "invalid-access", suite.Tokens.Passenger, "test", 0)
 		require.False(ok)
 		require.ErrorIs(err, authenticator.ErrInvalidAccessType)
 	})
 
 	suite.Run("testing acl with invalid token", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Pub, invalidToken, validDriverCabEventTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Pub, invalidToken, validDriverCabEventTopic, 0)
 		require.False(ok)
 		require.ErrorIs(err, jwt.ErrTokenMalformed)
 	})
 
 	suite.Run("testing acl with valid inputs", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, suite.Tokens.Passenger, validPassengerCabEventTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, suite.Tokens.Passenger, validPassengerCabEventTopic, 0)
 		require.NoError(err)
 		require.True(ok)
 	})
 
 	suite.Run("testing acl with invalid topic", func() {
 		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub,
-			suite.Tokens.Passenger, invalidPassengerCabEventTopic)
+			suite.Tokens.Passenger, invalidPassengerCabEventTopic, 0)
 		require.Error(err)
 		require.False(ok)
 	})
 
 	suite.Run("testing acl with invalid access type", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Pub, suite.Tokens.Passenger, validPassengerCabEventTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Pub, suite.Tokens.Passenger, validPassengerCabEventTopic, 0)
 		require.Error(err)
 		require.False(ok)
 	})
@@ -163,49 +174,49 @@ func (suite *ManualAuthenticatorSnappTestSuite) TestACLPassenger() {
 	token := suite.Tokens.Passenger
 
 	suite.Run("testing passenger subscribe on valid superapp event topic", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, validPassengerSuperappEventTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, validPassengerSuperappEventTopic, 0)
 		require.NoError(err)
 		require.True(ok)
 	})
 
 	suite.Run("testing passenger subscribe on invalid superapp event topic", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, invalidPassengerSuperappEventTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, invalidPassengerSuperappEventTopic, 0)
 		require.Error(err)
 		require.False(ok)
 	})
 
 	suite.Run("testing passenger subscribe on valid shared location topic", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, validPassengerSharedTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, validPassengerSharedTopic, 0)
 		require.NoError(err)
 		require.True(ok)
 	})
 
 	suite.Run("testing passenger subscribe on invalid shared location topic", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, invalidPassengerSharedTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, invalidPassengerSharedTopic, 0)
 		require.Error(err)
 		require.False(ok)
 	})
 
 	suite.Run("testing passenger subscribe on valid chat topic", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, validPassengerChatTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, validPassengerChatTopic, 0)
 		require.NoError(err)
 		require.True(ok)
 	})
 
 	suite.Run("testing passenger subscribe on invalid chat topic", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, invalidPassengerChatTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, invalidPassengerChatTopic, 0)
 		require.Error(err)
 		require.False(ok)
 	})
 
 	suite.Run("testing passenger subscribe on valid entry call topic", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Pub, token, validPassengerCallEntryTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Pub, token, validPassengerCallEntryTopic, 0)
 		require.NoError(err)
 		require.True(ok)
 	})
 
 	suite.Run("testing passenger subscribe on invalid call entry topic", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Pub, token, invalidPassengerCallEntryTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Pub, token, invalidPassengerCallEntryTopic, 0)
 		require.ErrorIs(err, authenticator.InvalidTopicError{
 			Topic: invalidPassengerCallEntryTopic,
 		})
@@ -213,19 +224,19 @@ func (suite *ManualAuthenticatorSnappTestSuite) TestACLPassenger() {
 	})
 
 	suite.Run("testing passenger subscribe on valid outgoing call topic", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, validPassengerCallOutgoingTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, validPassengerCallOutgoingTopic, 0)
 		require.NoError(err)
 		require.True(ok)
 	})
 
 	suite.Run("testing passenger subscribe on valid outgoing call node topic", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Pub, token, validPassengerNodeCallEntryTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Pub, token, validPassengerNodeCallEntryTopic, 0)
 		require.NoError(err)
 		require.True(ok)
 	})
 
 	suite.Run("testing passenger subscribe on invalid call outgoing topic", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, invalidPassengerCallOutgoingTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, invalidPassengerCallOutgoingTopic, 0)
 		require.Error(err)
 		require.False(ok)
 	})
@@ -237,85 +248,85 @@ func (suite *ManualAuthenticatorSnappTestSuite) TestACLDriver() {
 	token := suite.Tokens.Driver
 
 	suite.Run("testing driver publish on its location topic", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Pub, token, validDriverLocationTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Pub, token, validDriverLocationTopic, 0)
 		require.NoError(err)
 		require.True(ok)
 	})
 
 	suite.Run("testing driver publish on invalid location topic", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Pub, token, invalidDriverLocationTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Pub, token, invalidDriverLocationTopic, 0)
 		require.Error(err)
 		require.False(ok)
 	})
 
 	suite.Run("testing driver subscribe on invalid cab event topic", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, invalidDriverCabEventTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, invalidDriverCabEventTopic, 0)
 		require.Error(err)
 		require.False(ok)
 	})
 
 	suite.Run("testing driver subscribe on valid superapp event topic", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, validDriverSuperappEventTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, validDriverSuperappEventTopic, 0)
 		require.NoError(err)
 		require.True(ok)
 	})
 
 	suite.Run("testing driver subscribe on invalid superapp event topic", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, invalidDriverSuperappEventTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, invalidDriverSuperappEventTopic, 0)
 		require.Error(err)
 		require.False(ok)
 	})
 
 	suite.Run("testing driver subscribe on valid shared location topic", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, validDriverSharedTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, validDriverSharedTopic, 0)
 		require.NoError(err)
 		require.True(ok)
 	})
 
 	suite.Run("testing driver subscribe on invalid shared location topic", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, invalidDriverSharedTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, invalidDriverSharedTopic, 0)
 		require.Error(err)
 		require.False(ok)
 	})
 
 	suite.Run("testing driver subscribe on valid chat topic", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, validDriverChatTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, validDriverChatTopic, 0)
 		require.NoError(err)
 		require.True(ok)
 	})
 
 	suite.Run("testing driver subscribe on invalid chat topic", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, invalidDriverChatTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, invalidDriverChatTopic, 0)
 		require.Error(err)
 		require.False(ok)
 	})
 
 	suite.Run("testing driver subscribe on valid call entry topic", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Pub, token, validDriverCallEntryTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Pub, token, validDriverCallEntryTopic, 0)
 		require.NoError(err)
 		require.True(ok)
 	})
 
 	suite.Run("testing driver subscribe on invalid call entry topic", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Pub, token, invalidDriverCallEntryTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Pub, token, invalidDriverCallEntryTopic, 0)
 		require.Error(err)
 		require.False(ok)
 	})
 
 	suite.Run("testing driver subscribe on valid call outgoing topic", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, validDriverCallOutgoingTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, validDriverCallOutgoingTopic, 0)
 		require.NoError(err)
 		require.True(ok)
 	})
 
 	suite.Run("testing driver subscribe on valid call outgoing node topic", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Pub, token, validDriverNodeCallEntryTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Pub, token, validDriverNodeCallEntryTopic, 0)
 		require.NoError(err)
 		require.True(ok)
 	})
 
 	suite.Run("testing driver subscribe on invalid call outgoing topic", func() {
-		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, invalidDriverCallOutgoingTopic)
+		ok, err := suite.Authenticator.ACL(context.Background(), acl.Sub, token, invalidDriverCallOutgoingTopic, 0)
 		require.Error(err)
 		require.False(ok)
 	})
@@ -339,12 +350,13 @@ func TestManualAuthenticator_ValidateTopicBySender(t *testing.T) {
 	t.Run("testing valid driver cab event", func(t *testing.T) {
 		t.Parallel()
 
-		topicTemplate := authenticator.TopicManager.ParseTopic(
-			validDriverCabEventTopic,
+		topicTemplate, err := authenticator.TopicManager.ParseTopic(
+			context.Background(), validDriverCabEventTopic,
 			topics.DriverIss,
 			"DXKgaNQa7N5Y7bo",
 			nil,
 		)
+		require.NoError(t, err)
 		require.NotNil(t, topicTemplate)
 	})
 }
@@ -379,19 +391,209 @@ func TestManualAuthenticator_ValidateTopicBySenderAndClaims(t *testing.T) {
 	t.Run("testing valid driver cab event", func(t *testing.T) {
 		t.Parallel()
 
-		topicTemplate := authenticator.TopicManager.ParseTopic(
-			"snapp/driver/123/456/123",
+		topicTemplate, err := authenticator.TopicManager.ParseTopic(
+			context.Background(), "snapp/driver/123/456/123",
 			topics.DriverIss,
 			"123",
 			map[string]any{
 				"uid": "456",
 			},
 		)
+		require.NoError(t, err)
 		require.NotNil(t, topicTemplate)
 	})
 }
 
+func TestManualAuthenticator_PayloadSize(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	cfg := config.SnappVendor()
+
+	for i := range cfg.Topics {
+		if cfg.Topics[i].Type == topics.DriverLocation {
+			cfg.Topics[i].MaxPayloadBytes = 1024
+		}
+	}
+
+	hid, err := topics.NewHashIDManager(cfg.HashIDMap)
+	require.NoError(err)
+
+	pkey0, err := getPublicKey("0")
+	require.NoError(err)
+
+	key0, err := getPrivateKey("0")
+	require.NoError(err)
+
+	token, err := getSampleToken("0", key0)
+	require.NoError(err)
+
+	// nolint: exhaustruct
+	a := authenticator.ManualAuthenticator{
+		Keys:               map[string]any{topics.DriverIss: pkey0},
+		AllowedAccessTypes: []acl.AccessType{acl.Pub, acl.Sub},
+		Company:            "snapp",
+		Parser:             jwt.NewParser(),
+		TopicManager:       topics.NewTopicManager(cfg.Topics, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop()),
+		JWTConfig:          cfg.Jwt,
+	}
+
+	ok, err := a.ACL(context.Background(), acl.Pub, token, validDriverLocationTopic, 512)
+	require.NoError(err)
+	require.True(ok)
+
+	ok, err = a.ACL(context.Background(), acl.Pub, token, validDriverLocationTopic, 0)
+	require.NoError(err)
+	require.True(ok)
+
+	ok, err = a.ACL(context.Background(), acl.Pub, token, validDriverLocationTopic, 200*1024)
+	require.ErrorIs(err, authenticator.PayloadTooLargeError{
+		Topic:     validDriverLocationTopic,
+		TopicType: topics.DriverLocation,
+		Size:      200 * 1024,
+		MaxSize:   1024,
+	})
+	require.False(ok)
+}
+
 // nolint: funlen
+func TestManualAuthenticator_AccessQualifier(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	cfg := config.SnappVendor()
+
+	for i := range cfg.Topics {
+		if cfg.Topics[i].Type == topics.CallOutgoing {
+			cfg.Topics[i].Accesses[topics.QualifiedKey(topics.DriverIss, "caller")] = acl.Sub
+			cfg.Topics[i].Accesses[topics.QualifiedKey(topics.DriverIss, "callee")] = acl.Deny
+		}
+	}
+
+	hid, err := topics.NewHashIDManager(cfg.HashIDMap)
+	require.NoError(err)
+
+	key := []byte("secret")
+
+	// nolint: exhaustruct
+	a := authenticator.ManualAuthenticator{
+		Keys:                 map[string]any{topics.DriverIss: key},
+		AllowedAccessTypes:   []acl.AccessType{acl.Pub, acl.Sub},
+		Company:              "snapp",
+		Parser:               jwt.NewParser(),
+		TopicManager:         topics.NewTopicManager(cfg.Topics, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop()),
+		JWTConfig:            cfg.Jwt,
+		AccessQualifierClaim: "call_role",
+	}
+
+	topic := "snapp/driver/" + testutil.DefaultSubject + "/call/receive"
+
+	for role, allowed := range map[string]bool{"caller": true, "callee": false, "": true} {
+		extra := map[string]any{}
+		if role != "" {
+			extra["call_role"] = role
+		}
+
+		token, err := testutil.Token(jwt.SigningMethodHS512, key, testutil.Claims{
+			Issuer:       topics.DriverIss,
+			Subject:      testutil.DefaultSubject,
+			ExpiresIn:    0,
+			NoExpiration: false,
+			Extra:        extra,
+			Kid:          "",
+		})
+		require.NoError(err)
+
+		ok, err := a.ACL(context.Background(), acl.Sub, token, topic, 0)
+		require.Equal(allowed, ok, role)
+
+		if !allowed {
+			require.ErrorAs(err, new(authenticator.TopicNotAllowedError))
+		}
+	}
+}
+
+func TestManualAuthenticator_TopicNotAllowed(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.SnappVendor()
+
+	hid, err := topics.NewHashIDManager(cfg.HashIDMap)
+	require.NoError(t, err)
+
+	key := []byte("secret")
+
+	// nolint: exhaustruct
+	a := authenticator.ManualAuthenticator{
+		Keys:               map[string]any{topics.DriverIss: key, topics.PassengerIss: key},
+		AllowedAccessTypes: []acl.AccessType{acl.Pub, acl.Sub},
+		Company:            "snapp",
+		Parser:             jwt.NewParser(),
+		TopicManager:       topics.NewTopicManager(cfg.Topics, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop()),
+		JWTConfig:          cfg.Jwt,
+	}
+
+	driver, err := testutil.DriverToken(jwt.SigningMethodHS512, key)
+	require.NoError(t, err)
+
+	passenger, err := testutil.PassengerToken(jwt.SigningMethodHS512, key)
+	require.NoError(t, err)
+
+	location := "snapp/driver/" + testutil.DefaultSubject + "/location"
+
+	tests := []struct {
+		name    string
+		token   string
+		access  acl.AccessType
+		topic   string
+		reason  string
+		granted []acl.AccessType
+	}{
+		{
+			name:    "publish on subscribe only topic",
+			token:   driver,
+			access:  acl.Pub,
+			topic:   "snapp/driver/" + testutil.DefaultSubject + "/superapp",
+			reason:  authenticator.ReasonSubscribeOnly,
+			granted: []acl.AccessType{acl.Sub},
+		},
+		{
+			name:    "subscribe on publish only topic",
+			token:   driver,
+			access:  acl.Sub,
+			topic:   location,
+			reason:  authenticator.ReasonPublishOnly,
+			granted: []acl.AccessType{acl.Pub},
+		},
+		{
+			name:    "no access",
+			token:   passenger,
+			access:  acl.Pub,
+			topic:   location,
+			reason:  authenticator.ReasonNoAccess,
+			granted: nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ok, err := a.ACL(context.Background(), tc.access, tc.token, tc.topic, 0)
+			require.False(t, ok)
+
+			var tnaErr authenticator.TopicNotAllowedError
+
+			require.ErrorAs(t, err, &tnaErr)
+			require.Equal(t, tc.reason, tnaErr.Reason())
+			require.Equal(t, tc.granted, tnaErr.GrantedAccesses())
+			require.Equal(t, tc.granted != nil, tnaErr.HasAnyAccess())
+		})
+	}
+}
+
 func TestManualAuthenticator_validateAccessType(t *testing.T) {
 	t.Parallel()
 
@@ -479,3 +681,330 @@ func TestManualAuthenticator_validateAccessType(t *testing.T) {
 		})
 	}
 }
+
+// nolint: funlen
+func TestManualAuthenticator_StateCheck(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	var (
+		calls  atomic.Int64
+		active atomic.Bool
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		calls.Add(1)
+
+		var request statecheck.Request
+
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil || request.PassengerHash != testutil.DefaultSubject {
+			res.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		_ = json.NewEncoder(res).Encode(statecheck.Response{Active: active.Load()})
+	}))
+	defer server.Close()
+
+	cfg := config.SnappVendor()
+
+	for i := range cfg.Topics {
+		if cfg.Topics[i].Type == topics.Chat {
+			// nolint: exhaustruct
+			cfg.Topics[i].StateCheck = &statecheck.Config{
+				URL:           server.URL,
+				DriverID:      "{{ .sub }}",
+				PassengerHash: "{{ .segment2 }}",
+			}
+		}
+	}
+
+	hid, err := topics.NewHashIDManager(cfg.HashIDMap)
+	require.NoError(err)
+
+	pkey0, err := getPublicKey("0")
+	require.NoError(err)
+
+	key0, err := getPrivateKey("0")
+	require.NoError(err)
+
+	token, err := getSampleToken("0", key0)
+	require.NoError(err)
+
+	newAuthenticator := func() authenticator.ManualAuthenticator {
+		// nolint: exhaustruct
+		return authenticator.ManualAuthenticator{
+			Keys:               map[string]any{topics.DriverIss: pkey0},
+			AllowedAccessTypes: []acl.AccessType{acl.Pub, acl.Sub},
+			Company:            "snapp",
+			Parser:             jwt.NewParser(),
+			TopicManager: topics.NewTopicManager(
+				cfg.Topics, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop(),
+			).WithStateCheckers(cfg.Topics, noop.NewTracerProvider().Tracer("")),
+			JWTConfig: cfg.Jwt,
+		}
+	}
+
+	// topics without state check never call the state service.
+	a := newAuthenticator()
+
+	ok, err := a.ACL(context.Background(), acl.Pub, token, validDriverLocationTopic, 0)
+	require.NoError(err)
+	require.True(ok)
+	require.Zero(calls.Load())
+
+	active.Store(true)
+
+	ok, err = a.ACL(context.Background(), acl.Sub, token, validDriverChatTopic, 0)
+	require.NoError(err)
+	require.True(ok)
+	require.Equal(int64(1), calls.Load())
+
+	active.Store(false)
+
+	// the result is cached per driver and passenger pair.
+	ok, err = a.ACL(context.Background(), acl.Sub, token, validDriverChatTopic, 0)
+	require.NoError(err)
+	require.True(ok)
+	require.Equal(int64(1), calls.Load())
+
+	a = newAuthenticator()
+
+	ok, err = a.ACL(context.Background(), acl.Sub, token, validDriverChatTopic, 0)
+	require.ErrorIs(err, authenticator.ErrStateCheckDenied)
+	require.False(ok)
+	require.Equal(int64(2), calls.Load())
+}
+
+func TestManualAuthenticator_FailureRatio(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	cfg := config.SnappVendor()
+
+	hid, err := topics.NewHashIDManager(cfg.HashIDMap)
+	require.NoError(err)
+
+	pkey0, err := getPublicKey("0")
+	require.NoError(err)
+
+	key0, err := getPrivateKey("0")
+	require.NoError(err)
+
+	key1, err := getPrivateKey("1")
+	require.NoError(err)
+
+	// nolint: exhaustruct
+	tracker := failratio.New(failratio.Config{Window: time.Minute}, zap.NewNop())
+
+	// nolint: exhaustruct
+	a := authenticator.ManualAuthenticator{
+		Keys:               map[string]any{topics.DriverIss: pkey0},
+		AllowedAccessTypes: []acl.AccessType{acl.Pub, acl.Sub},
+		Company:            "snapp",
+		Parser:             jwt.NewParser(),
+		TopicManager:       topics.NewTopicManager(cfg.Topics, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop()),
+		JWTConfig:          cfg.Jwt,
+		FailureRatio:       tracker,
+	}
+
+	valid, err := getSampleToken(topics.DriverIss, key0)
+	require.NoError(err)
+
+	// driver token which is signed by the passenger key.
+	invalid, err := getSampleToken(topics.DriverIss, key1)
+	require.NoError(err)
+
+	unknown, err := getSampleToken("9", key0)
+	require.NoError(err)
+
+	require.NoError(a.Auth(context.Background(), valid))
+	require.Error(a.Auth(context.Background(), invalid))
+	require.Error(a.Auth(context.Background(), unknown))
+	require.Error(a.Auth(context.Background(), "not a token"))
+
+	require.InDelta(0.5, tracker.Ratio("snapp", topics.DriverIss), 0.0001)
+	require.InDelta(1.0, tracker.Ratio("snapp", failratio.UnknownIssuer), 0.0001)
+	require.Zero(tracker.Ratio("snapp", "9"))
+}
+
+func TestManualAuthenticator_TopicAllowedAccessTypes(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	cfg := config.SnappVendor()
+
+	hid, err := topics.NewHashIDManager(cfg.HashIDMap)
+	require.NoError(err)
+
+	pkey0, err := getPublicKey("0")
+	require.NoError(err)
+
+	key0, err := getPrivateKey("0")
+	require.NoError(err)
+
+	manager := topics.NewTopicManager([]topics.Topic{
+		{
+			Type:     topics.BoxEvent,
+			Template: "^bucks$",
+			Accesses: map[string]acl.AccessType{topics.DriverIss: acl.PubSub},
+		},
+		{
+			Type:     topics.Chat,
+			Template: "^{{.company}}/chat/{{.sub}}$",
+			Accesses: map[string]acl.AccessType{topics.DriverIss: acl.PubSub},
+		},
+	}, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop())
+
+	manager.TopicTemplates[0].AllowedAccessTypes = []acl.AccessType{acl.Pub, acl.Sub}
+
+	// nolint: exhaustruct
+	a := authenticator.ManualAuthenticator{
+		Keys:               map[string]any{topics.DriverIss: pkey0},
+		AllowedAccessTypes: []acl.AccessType{acl.Sub},
+		Company:            "snapp",
+		Parser:             jwt.NewParser(),
+		TopicManager:       manager,
+		JWTConfig:          cfg.Jwt,
+	}
+
+	token, err := getSampleToken(topics.DriverIss, key0)
+	require.NoError(err)
+
+	ok, err := a.ACL(context.Background(), acl.Pub, token, "bucks", 0)
+	require.NoError(err)
+	require.True(ok)
+
+	ok, err = a.ACL(context.Background(), acl.Sub, token, "snapp/chat/DXKgaNQa7N5Y7bo", 0)
+	require.NoError(err)
+	require.True(ok)
+
+	_, err = a.ACL(context.Background(), acl.Pub, token, "snapp/chat/DXKgaNQa7N5Y7bo", 0)
+	require.ErrorIs(err, authenticator.ErrInvalidAccessType)
+	require.Equal(authenticator.InvalidAccessTypeError{
+		Level:      authenticator.AccessTypeLevelVendor,
+		AccessType: acl.Pub,
+		TopicType:  topics.Chat,
+	}, err)
+
+	manager.TopicTemplates[0].AllowedAccessTypes = []acl.AccessType{acl.Sub}
+
+	_, err = a.ACL(context.Background(), acl.Pub, token, "bucks", 0)
+	require.ErrorIs(err, authenticator.ErrInvalidAccessType)
+	require.Equal(authenticator.InvalidAccessTypeError{
+		Level:      authenticator.AccessTypeLevelTopic,
+		AccessType: acl.Pub,
+		TopicType:  topics.BoxEvent,
+	}, err)
+}
+
+// nolint: funlen
+func TestManualAuthenticator_IssuerSigningMethods(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	driverKey, err := testutil.RSAKey()
+	require.NoError(err)
+
+	passengerKey, err := testutil.RSAKey()
+	require.NoError(err)
+
+	driverPEM, err := testutil.PublicKeyPEM(&driverKey.PublicKey)
+	require.NoError(err)
+
+	passengerPEM, err := testutil.PublicKeyPEM(&passengerKey.PublicKey)
+	require.NoError(err)
+
+	vendor := config.SnappVendor()
+	vendor.Company = "snapp-pss"
+	vendor.Jwt.SigningMethod = "RS256"
+	vendor.Jwt.IssuerSigningMethods = map[string]string{
+		topics.PassengerIss: "PS256",
+	}
+	vendor.Keys = map[string]string{
+		topics.DriverIss:    driverPEM,
+		topics.PassengerIss: passengerPEM,
+	}
+
+	// nolint: exhaustruct
+	auths, err := authenticator.Builder{
+		Vendors: []config.Vendor{vendor},
+		Logger:  zap.NewNop(),
+		Tracer:  noop.NewTracerProvider().Tracer(""),
+	}.Authenticators()
+	require.NoError(err)
+
+	auth := auths[vendor.Company]
+
+	passengerPSS, err := testutil.PassengerToken(jwt.SigningMethodPS256, passengerKey)
+	require.NoError(err)
+
+	driverRSA, err := testutil.DriverToken(jwt.SigningMethodRS256, driverKey)
+	require.NoError(err)
+
+	require.NoError(auth.Auth(context.Background(), passengerPSS))
+	require.NoError(auth.Auth(context.Background(), driverRSA))
+
+	// RS256 token of the PS256 issuer is rejected even though it is signed by the issuer key.
+	passengerRSA, err := testutil.PassengerToken(jwt.SigningMethodRS256, passengerKey)
+	require.NoError(err)
+	require.ErrorIs(auth.Auth(context.Background(), passengerRSA), authenticator.ErrInvalidSigningMethod)
+
+	// issuers without their own signing method still use the signing method of vendor.
+	driverPSS, err := testutil.DriverToken(jwt.SigningMethodPS256, driverKey)
+	require.NoError(err)
+	require.ErrorIs(auth.Auth(context.Background(), driverPSS), authenticator.ErrInvalidSigningMethod)
+
+	driverHMAC, err := testutil.DriverToken(jwt.SigningMethodHS256, []byte("secret"))
+	require.NoError(err)
+	require.ErrorIs(auth.Auth(context.Background(), driverHMAC), jwt.ErrTokenSignatureInvalid)
+
+	passengerPS512, err := testutil.PassengerToken(jwt.SigningMethodPS512, passengerKey)
+	require.NoError(err)
+	require.ErrorIs(auth.Auth(context.Background(), passengerPS512), jwt.ErrTokenSignatureInvalid)
+}
+
+func TestManualAuthenticator_MalformedToken(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	cfg := config.SnappVendor()
+
+	hid, err := topics.NewHashIDManager(cfg.HashIDMap)
+	require.NoError(err)
+
+	key := []byte("secret")
+
+	// nolint: exhaustruct
+	a := authenticator.ManualAuthenticator{
+		Keys:               map[string]any{topics.DriverIss: key},
+		AllowedAccessTypes: []acl.AccessType{acl.Pub, acl.Sub},
+		Company:            "snapp",
+		Parser:             jwt.NewParser(),
+		TopicManager:       topics.NewTopicManager(cfg.Topics, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop()),
+		JWTConfig:          cfg.Jwt,
+	}
+
+	token, err := testutil.DriverToken(jwt.SigningMethodHS512, key)
+	require.NoError(err)
+
+	// the header has the JWT alphabet but its length cannot be decoded.
+	header, rest, _ := strings.Cut(token, ".")
+	for len(header)%4 != 1 {
+		header += "a"
+	}
+
+	err = a.Auth(context.Background(), header+"."+rest)
+
+	var mcErr authenticator.MalformedCredentialError
+	require.ErrorAs(err, &mcErr)
+	require.Equal(credential.MalformedEncoding, mcErr.Reason)
+	require.ErrorIs(err, jwt.ErrTokenMalformed)
+	require.NotContains(err.Error(), "illegal base64")
+}
@@ -0,0 +1,75 @@
+package authenticator
+
+import (
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/metric"
+)
+
+// NoExpiry accepts the tokens without exp claim of its subjects, like the tokens of internal services,
+// and restricts them to its topic types. Tokens without exp claim of other subjects are rejected,
+// nil NoExpiry rejects all of them.
+type NoExpiry struct {
+	Company    string
+	Subjects   map[string]struct{}
+	TopicTypes map[string]struct{}
+	Metrics    *metric.NoExpiryMetrics
+}
+
+// NewNoExpiry returns nil when vendor has no subjects which can omit the exp claim.
+func NewNoExpiry(company string, subjects, topicTypes []string, reg prometheus.Registerer) *NoExpiry {
+	if len(subjects) == 0 {
+		return nil
+	}
+
+	n := &NoExpiry{
+		Company:    company,
+		Subjects:   make(map[string]struct{}, len(subjects)),
+		TopicTypes: make(map[string]struct{}, len(topicTypes)),
+		Metrics:    metric.NewNoExpiryMetrics(reg),
+	}
+
+	for _, sub := range subjects {
+		n.Subjects[sub] = struct{}{}
+	}
+
+	for _, topicType := range topicTypes {
+		n.TopicTypes[topicType] = struct{}{}
+	}
+
+	return n
+}
+
+// Check returns true when token has no exp claim and its subject can omit it, so the token is
+// restricted to the topic types.
+func (n *NoExpiry) Check(claims jwt.MapClaims, sub string) (bool, error) {
+	// malformed exp claims are rejected by the parser or validator.
+	if exp, err := claims.GetExpirationTime(); err != nil || exp != nil {
+		return false, nil
+	}
+
+	if n == nil {
+		return false, ErrMissingExpiry
+	}
+
+	if _, ok := n.Subjects[sub]; !ok {
+		return false, fmt.Errorf("%w: %s", ErrMissingExpiry, sub)
+	}
+
+	n.Metrics.Used(n.Company, sub)
+
+	return true, nil
+}
+
+// Allows checks the tokens without exp claim can access the topic type.
+func (n *NoExpiry) Allows(topicType string) bool {
+	if n == nil {
+		return false
+	}
+
+	_, ok := n.TopicTypes[topicType]
+
+	return ok
+}
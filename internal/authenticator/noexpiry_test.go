@@ -0,0 +1,109 @@
+package authenticator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/snapp-incubator/soteria/pkg/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// nolint: funlen
+func TestManualAuthenticator_NoExpiry(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.SnappVendor()
+
+	hid, err := topics.NewHashIDManager(cfg.HashIDMap)
+	require.NoError(t, err)
+
+	key := []byte("secret")
+
+	// nolint: exhaustruct
+	a := authenticator.ManualAuthenticator{
+		Keys:               map[string]any{topics.DriverIss: key},
+		AllowedAccessTypes: []acl.AccessType{acl.Pub, acl.Sub},
+		Company:            "snapp",
+		Parser:             jwt.NewParser(),
+		TopicManager:       topics.NewTopicManager(cfg.Topics, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop()),
+		JWTConfig:          cfg.Jwt,
+		NoExpiry: authenticator.NewNoExpiry(
+			"snapp", []string{testutil.DefaultSubject}, []string{topics.DriverLocation}, prometheus.DefaultRegisterer,
+		),
+	}
+
+	token := func(sub string, noExpiration bool) string {
+		t.Helper()
+
+		token, err := testutil.Token(jwt.SigningMethodHS512, key, testutil.Claims{
+			Issuer:       topics.DriverIss,
+			Subject:      sub,
+			ExpiresIn:    0,
+			NoExpiration: noExpiration,
+			Extra:        nil,
+			Kid:          "",
+		})
+		require.NoError(t, err)
+
+		return token
+	}
+
+	ctx := context.Background()
+	location := "snapp/driver/" + testutil.DefaultSubject + "/location"
+	superapp := "snapp/driver/" + testutil.DefaultSubject + "/superapp"
+
+	t.Run("subject without exp is restricted to its topic types", func(t *testing.T) {
+		t.Parallel()
+
+		require := require.New(t)
+
+		tk := token(testutil.DefaultSubject, true)
+
+		require.NoError(a.Auth(ctx, tk))
+
+		_, err := a.ACL(ctx, acl.Pub, tk, location, 0)
+		require.NoError(err)
+
+		var tnaErr authenticator.TopicNotAllowedError
+
+		_, err = a.ACL(ctx, acl.Sub, tk, superapp, 0)
+		require.ErrorAs(err, &tnaErr)
+		require.Equal(authenticator.ReasonNoAccess, tnaErr.Reason())
+	})
+
+	t.Run("subject with exp is not restricted", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := a.ACL(ctx, acl.Sub, token(testutil.DefaultSubject, false), superapp, 0)
+		require.NoError(t, err)
+	})
+
+	t.Run("other subjects without exp are rejected", func(t *testing.T) {
+		t.Parallel()
+
+		require := require.New(t)
+
+		tk := token("other", true)
+
+		require.ErrorIs(a.Auth(ctx, tk), authenticator.ErrMissingExpiry)
+
+		_, err := a.ACL(ctx, acl.Pub, tk, "snapp/driver/other/location", 0)
+		require.ErrorIs(err, authenticator.ErrMissingExpiry)
+	})
+
+	t.Run("vendors without no expiry subjects reject tokens without exp", func(t *testing.T) {
+		t.Parallel()
+
+		b := a
+		b.NoExpiry = nil
+
+		require.ErrorIs(t, b.Auth(ctx, token(testutil.DefaultSubject, true)), authenticator.ErrMissingExpiry)
+	})
+}
@@ -0,0 +1,17 @@
+package authenticator
+
+import "context"
+
+type payloadKey struct{}
+
+// WithPayload adds the base64 payload of publish which broker forwards for the payload checks of topics.
+func WithPayload(ctx context.Context, payload string) context.Context {
+	return context.WithValue(ctx, payloadKey{}, payload)
+}
+
+// PayloadFromContext returns the base64 payload of publish, it is empty when broker doesn't forward it.
+func PayloadFromContext(ctx context.Context) string {
+	payload, _ := ctx.Value(payloadKey{}).(string)
+
+	return payload
+}
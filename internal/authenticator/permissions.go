@@ -0,0 +1,59 @@
+package authenticator
+
+import (
+	"context"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/strconv"
+)
+
+// PermissionsAuthenticator is implemented by authenticators which can enumerate the allowed topics of a token.
+type PermissionsAuthenticator interface {
+	// Permissions returns the allowed topics of token, token signature is not verified
+	// because it is used for debugging, so it must not be used for authorization.
+	// Remote accesses of templates are asked from their service like ACL.
+	Permissions(ctx context.Context, tokenString string) ([]topics.Permission, error)
+}
+
+// Permissions returns the allowed topics of token.
+func (a ManualAuthenticator) Permissions(ctx context.Context, tokenString string) ([]topics.Permission, error) {
+	return permissions(ctx, a.Parser, tokenString, a.JWTConfig, a.TopicManager, a.AccessQualifierClaim)
+}
+
+// Permissions returns the allowed topics of token.
+func (a AutoAuthenticator) Permissions(ctx context.Context, tokenString string) ([]topics.Permission, error) {
+	return permissions(ctx, a.Parser, tokenString, a.JWTConfig, a.TopicManager, a.AccessQualifierClaim)
+}
+
+func permissions(
+	ctx context.Context,
+	parser *jwt.Parser,
+	tokenString string,
+	cfg config.JWT,
+	manager *topics.Manager,
+	qualifierClaim string,
+) ([]topics.Permission, error) {
+	var claims jwt.MapClaims
+
+	if _, _, err := parser.ParseUnverified(tokenString, &claims); err != nil {
+		return nil, ErrInvalidClaims
+	}
+
+	if claims[cfg.IssName] == nil {
+		return nil, ErrIssNotFound
+	}
+
+	if claims[cfg.SubName] == nil {
+		return nil, ErrSubNotFound
+	}
+
+	return manager.AllowedTopics(
+		ctx,
+		strconv.ToString(claims[cfg.IssName]),
+		strconv.ToString(claims[cfg.SubName]),
+		qualifier(claims, qualifierClaim),
+		map[string]any(claims),
+	), nil
+}
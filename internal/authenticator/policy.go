@@ -0,0 +1,29 @@
+package authenticator
+
+import (
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/policy"
+	"go.uber.org/zap"
+)
+
+// PolicyAuthenticator is implemented by authenticators which evaluate the accesses by a policy,
+// the policy is nil for vendors without policy.
+type PolicyAuthenticator interface {
+	AccessPolicy() *policy.Policy
+}
+
+// AccessPolicy returns the policy of vendor, it is nil for vendors without policy.
+func (a ManualAuthenticator) AccessPolicy() *policy.Policy {
+	return a.Policy
+}
+
+// policy creates the policy of vendor, it is nil for vendors without policy.
+func (b Builder) policy(vendor config.Vendor) *policy.Policy {
+	if vendor.Policy == nil {
+		return nil
+	}
+
+	return policy.New(*vendor.Policy, vendor.Company, b.Tracer, b.registerer(), b.Logger.Named("policy").With(
+		zap.String("vendor", vendor.Company),
+	))
+}
@@ -0,0 +1,123 @@
+package authenticator_test
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/policy"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/snapp-incubator/soteria/pkg/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+)
+
+// nolint: funlen
+func TestManualAuthenticator_Policy(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	// policy allows the drivers with the new app version and denies the others with a reason,
+	// subscriptions are only allowed when they are denied statically.
+	path := filepath.Join(t.TempDir(), "authz.rego")
+
+	require.NoError(os.WriteFile(path, []byte(`package soteria.authz
+
+allow if {
+	input.claims.app_version == "new"
+	input.topic_type == "driver_location"
+	input.issuer == "0"
+	input.access == "publish"
+	input.static
+}
+
+allow if {
+	input.claims.app_version == "new"
+	input.access == "subscribe"
+	not input.static
+}
+
+reason := "app_version_too_old" if input.claims.app_version != "new"
+`), 0o600))
+
+	key := []byte("secret")
+
+	vendor := config.SnappVendor()
+	vendor.Jwt.SigningMethod = jwt.SigningMethodHS512.Alg()
+
+	encoded := base64.StdEncoding.EncodeToString(key)
+	vendor.Keys = map[string]string{topics.DriverIss: encoded, topics.PassengerIss: encoded}
+
+	token := func(version string) string {
+		token, err := testutil.Token(jwt.SigningMethodHS512, key, testutil.Claims{
+			Issuer:       topics.DriverIss,
+			Subject:      testutil.DefaultSubject,
+			ExpiresIn:    0,
+			NoExpiration: false,
+			Extra:        map[string]any{"app_version": version},
+			Kid:          "",
+		})
+		require.NoError(err)
+
+		return token
+	}
+
+	topic := "snapp/driver/" + testutil.DefaultSubject + "/location"
+
+	for _, mode := range []string{policy.ModeGate, policy.ModeOverride} {
+		vendor.Policy = &policy.Config{
+			Path:     "soteria/authz",
+			Modules:  []string{path},
+			Mode:     mode,
+			Timeout:  0,
+			FailOpen: false,
+		}
+
+		// nolint: exhaustruct
+		auths, err := authenticator.Builder{
+			Vendors: []config.Vendor{vendor},
+			Logger:  zap.NewNop(),
+			Tracer:  noop.NewTracerProvider().Tracer(""),
+		}.Authenticators()
+		require.NoError(err, mode)
+
+		auth := auths[vendor.Company]
+
+		evaluated, ok := auth.(authenticator.PolicyAuthenticator)
+		require.True(ok, mode)
+		require.NotNil(evaluated.AccessPolicy(), mode)
+		require.NoError(evaluated.AccessPolicy().Load(context.Background()), mode)
+
+		ok, err = auth.ACL(context.Background(), acl.Pub, token("new"), topic, 0)
+		require.NoError(err, mode)
+		require.True(ok, mode)
+
+		ok, err = auth.ACL(context.Background(), acl.Pub, token("old"), topic, 0)
+		require.False(ok, mode)
+
+		var pdErr authenticator.PolicyDeniedError
+		require.ErrorAs(err, &pdErr, mode)
+		require.Equal("app_version_too_old", pdErr.Reason(), mode)
+
+		// drivers cannot subscribe their location statically, override policies allow it.
+		ok, err = auth.ACL(context.Background(), acl.Sub, token("new"), topic, 0)
+
+		if mode == policy.ModeGate {
+			require.ErrorAs(err, new(authenticator.TopicNotAllowedError), mode)
+			require.False(ok, mode)
+
+			continue
+		}
+
+		require.NoError(err, mode)
+		require.True(ok, mode)
+	}
+}
@@ -0,0 +1,226 @@
+package authenticator
+
+import (
+	"cmp"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/metric"
+	"go.uber.org/zap"
+)
+
+// fingerprintSecret keys the fingerprints of symmetric keys, so their fingerprints on metrics, logs and
+// the admin API cannot be brute-forced offline. It is generated per process, so they are only comparable
+// within a process.
+// nolint: gochecknoglobals
+var fingerprintSecret = func() []byte {
+	secret := make([]byte, sha256.Size)
+	_, _ = rand.Read(secret)
+
+	return secret
+}()
+
+// KeyInfo describes a loaded key without its material.
+type KeyInfo struct {
+	Vendor      string     `json:"vendor"`
+	Issuer      string     `json:"issuer"`
+	Kid         string     `json:"kid,omitempty"`
+	Fingerprint string     `json:"fingerprint"`
+	NotBefore   *time.Time `json:"not_before,omitempty"`
+	NotAfter    *time.Time `json:"not_after,omitempty"`
+}
+
+// KeyRegistry keeps track of the loaded keys of vendors, it is safe for concurrent use
+// so key refreshes can update it while it is being served.
+type KeyRegistry struct {
+	lock    sync.RWMutex
+	keys    map[string]map[keyID]KeyInfo
+	metrics *metric.KeyMetrics
+	logger  *zap.Logger
+}
+
+func NewKeyRegistry(reg prometheus.Registerer, logger *zap.Logger) *KeyRegistry {
+	return &KeyRegistry{
+		lock:    sync.RWMutex{},
+		keys:    make(map[string]map[keyID]KeyInfo),
+		metrics: metric.NewKeyMetrics(reg),
+		logger:  logger,
+	}
+}
+
+// keyID identifies a key of vendor, kid is empty for the single key of issuer.
+type keyID struct {
+	issuer string
+	kid    string
+}
+
+// Set records the loaded keys of a vendor, raw keys are used for reading certificates validity.
+// Keys of issuers which are not in keys anymore are removed.
+func (r *KeyRegistry) Set(vendor string, raw map[string]string, keys map[string]any) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for iss, key := range keys {
+		r.set(vendor, iss, "", raw[iss], key)
+	}
+
+	r.prune(vendor, func(id keyID) bool {
+		_, ok := keys[id.issuer]
+
+		return id.kid != "" || ok
+	})
+}
+
+// SetVerificationKeys records the verification keys of a vendor which are identified by their kid,
+// keys are generated by GenerateVerificationKeys from raw so they have the same order.
+func (r *KeyRegistry) SetVerificationKeys(
+	vendor string,
+	raw map[string][]config.VerificationKey,
+	keys map[string][]VerificationKey,
+) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for iss, list := range keys {
+		// every configured key has a kid, the single key of issuer is the last key and it is recorded by Set.
+		for i, key := range list[:min(len(list), len(raw[iss]))] {
+			r.set(vendor, iss, key.Kid, raw[iss][i].Key, key.Key)
+		}
+	}
+
+	r.prune(vendor, func(id keyID) bool {
+		return id.kid == "" || slices.ContainsFunc(keys[id.issuer], func(key VerificationKey) bool {
+			return key.Kid == id.kid
+		})
+	})
+}
+
+// prune removes the keys of vendor which are not kept anymore.
+func (r *KeyRegistry) prune(vendor string, keep func(keyID) bool) {
+	for id, info := range r.keys[vendor] {
+		if keep(id) {
+			continue
+		}
+
+		delete(r.keys[vendor], id)
+		r.metrics.Unloaded(vendor, id.issuer, info.Fingerprint)
+
+		r.logger.Info("key unloaded",
+			zap.String("vendor", vendor),
+			zap.String("issuer", id.issuer),
+			zap.String("kid", id.kid),
+		)
+	}
+}
+
+func (r *KeyRegistry) set(vendor, iss, kid, raw string, key any) {
+	if _, ok := r.keys[vendor]; !ok {
+		r.keys[vendor] = make(map[keyID]KeyInfo)
+	}
+
+	id := keyID{issuer: iss, kid: kid}
+
+	info := KeyInfo{
+		Vendor:      vendor,
+		Issuer:      iss,
+		Kid:         kid,
+		Fingerprint: Fingerprint(key),
+		NotBefore:   nil,
+		NotAfter:    nil,
+	}
+
+	if cert := certificate(raw); cert != nil {
+		info.NotBefore = &cert.NotBefore
+		info.NotAfter = &cert.NotAfter
+	}
+
+	if old, ok := r.keys[vendor][id]; ok {
+		if old.Fingerprint == info.Fingerprint {
+			return
+		}
+
+		r.metrics.Unloaded(vendor, iss, old.Fingerprint)
+	}
+
+	r.keys[vendor][id] = info
+	r.metrics.Loaded(vendor, iss, info.Fingerprint)
+
+	r.logger.Info("key loaded",
+		zap.String("vendor", vendor),
+		zap.String("issuer", iss),
+		zap.String("kid", kid),
+		zap.String("fingerprint", info.Fingerprint),
+		zap.Timep("not-before", info.NotBefore),
+		zap.Timep("not-after", info.NotAfter),
+	)
+}
+
+// List returns the loaded keys sorted by vendor, issuer and kid.
+func (r *KeyRegistry) List() []KeyInfo {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	list := make([]KeyInfo, 0)
+
+	for _, keys := range r.keys {
+		for _, info := range keys {
+			list = append(list, info)
+		}
+	}
+
+	slices.SortFunc(list, func(a, b KeyInfo) int {
+		return cmp.Or(cmp.Compare(a.Vendor, b.Vendor), cmp.Compare(a.Issuer, b.Issuer), cmp.Compare(a.Kid, b.Kid))
+	})
+
+	return list
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the key. Public keys are fingerprinted
+// using their PKIX form, so it is the same as `openssl pkey -pubin -outform der | sha256sum`.
+// Symmetric keys are secrets, so their fingerprint is an HMAC-SHA256 by the process secret with the hmac: prefix.
+func Fingerprint(key any) string {
+	var data []byte
+
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, fingerprintSecret)
+		_, _ = mac.Write(k)
+
+		return "hmac:" + hex.EncodeToString(mac.Sum(nil))
+	default:
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			data = []byte(fmt.Sprintf("%v", key))
+		} else {
+			data = der
+		}
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+func certificate(raw string) *x509.Certificate {
+	block, _ := pem.Decode([]byte(raw))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil
+	}
+
+	return cert
+}
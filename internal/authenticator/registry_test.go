@@ -0,0 +1,100 @@
+package authenticator_test
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/pkg/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+)
+
+// nolint: funlen
+func TestKeyRegistry(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	key, err := testutil.RSAKey()
+	require.NoError(err)
+
+	public, err := testutil.PublicKeyPEM(&key.PublicKey)
+	require.NoError(err)
+
+	notBefore := time.Now().Truncate(time.Second).UTC()
+	notAfter := notBefore.Add(time.Hour)
+
+	// nolint: exhaustruct
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "snapp"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}, &x509.Certificate{SerialNumber: big.NewInt(1)}, &key.PublicKey, key)
+	require.NoError(err)
+
+	// nolint: exhaustruct
+	cert := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+
+	registry := authenticator.NewKeyRegistry(prometheus.NewRegistry(), zap.NewNop())
+
+	b := authenticator.Builder{
+		Vendors:         nil,
+		Logger:          zap.NewNop(),
+		ValidatorConfig: config.Validator{URL: "", Timeout: 0},
+		Tracer:          noop.NewTracerProvider().Tracer(""),
+		KeyRegistry:     registry,
+	}
+
+	raw := map[string]string{"0": public, "1": cert}
+
+	keys, err := b.GenerateKeys("RS512", raw)
+	require.NoError(err)
+
+	registry.Set("snapp", raw, keys)
+
+	list := registry.List()
+	require.Len(list, 2)
+
+	require.Equal("snapp", list[0].Vendor)
+	require.Equal("0", list[0].Issuer)
+	require.Equal(authenticator.Fingerprint(&key.PublicKey), list[0].Fingerprint)
+	require.Nil(list[0].NotBefore)
+	require.Nil(list[0].NotAfter)
+
+	require.Equal("1", list[1].Issuer)
+	require.Equal(list[0].Fingerprint, list[1].Fingerprint)
+	require.NotNil(list[1].NotBefore)
+	require.True(notBefore.Equal(*list[1].NotBefore))
+	require.NotNil(list[1].NotAfter)
+	require.True(notAfter.Equal(*list[1].NotAfter))
+
+	hmac := map[string]string{"0": "c2VjcmV0"}
+
+	keys, err = b.GenerateKeys("HS512", hmac)
+	require.NoError(err)
+
+	registry.Set("snapp", hmac, keys)
+
+	// issuer 1 is not configured anymore, so its key is removed.
+	list = registry.List()
+	require.Len(list, 1)
+	require.Equal(authenticator.Fingerprint([]byte("secret")), list[0].Fingerprint)
+	require.True(strings.HasPrefix(list[0].Fingerprint, "hmac:"))
+
+	// symmetric keys are not fingerprinted by their plain hash, which could be brute-forced.
+	plain := sha256.Sum256([]byte("secret"))
+	require.NotContains(list[0].Fingerprint, hex.EncodeToString(plain[:]))
+}
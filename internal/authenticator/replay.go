@@ -0,0 +1,152 @@
+package authenticator
+
+import (
+	"context"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/snapp-incubator/soteria/pkg/strconv"
+)
+
+// Subject is the client of a token without its secrets which topic decisions depend on.
+type Subject struct {
+	Issuer string
+	Sub    string
+	// Qualifier is the access qualifier claim of token, it is empty when vendor doesn't have it.
+	Qualifier string
+	// ID is the jti claim of token, it is empty when token doesn't have it.
+	ID string
+}
+
+// ReplayAuthenticator is implemented by authenticators which can replay the topic decisions of their tokens.
+type ReplayAuthenticator interface {
+	// Subject returns the subject of the unverified token, so it must not be used for authorization.
+	Subject(tokenString string) (Subject, error)
+	// Decide checks the access of subject to the topic in the topic layer. Token validation, state checks,
+	// ride membership, webhooks, subscription limits and payload sizes are not checked.
+	Decide(accessType acl.AccessType, topic string, subject Subject) error
+}
+
+// Subject returns the subject of the unverified token.
+func (a ManualAuthenticator) Subject(tokenString string) (Subject, error) {
+	return subject(a.Parser, tokenString, a.JWTConfig, a.AccessQualifierClaim)
+}
+
+// Subject returns the subject of the unverified token.
+func (a AutoAuthenticator) Subject(tokenString string) (Subject, error) {
+	return subject(a.Parser, tokenString, a.JWTConfig, a.AccessQualifierClaim)
+}
+
+// Decide checks the access of subject to the topic in the topic layer.
+func (a ManualAuthenticator) Decide(accessType acl.AccessType, topic string, s Subject) error {
+	return decide(a.TopicManager, a.AllowedAccessTypes, a.JWTConfig, a.AccessQualifierClaim, accessType, topic, s)
+}
+
+// Decide checks the access of subject to the topic in the topic layer.
+func (a AutoAuthenticator) Decide(accessType acl.AccessType, topic string, s Subject) error {
+	return decide(a.TopicManager, a.AllowedAccessTypes, a.JWTConfig, a.AccessQualifierClaim, accessType, topic, s)
+}
+
+func subject(parser *jwt.Parser, tokenString string, cfg config.JWT, qualifierClaim string) (Subject, error) {
+	var claims jwt.MapClaims
+
+	if _, _, err := parser.ParseUnverified(tokenString, &claims); err != nil {
+		return Subject{}, ErrInvalidClaims
+	}
+
+	if claims[cfg.IssName] == nil {
+		return Subject{}, ErrIssNotFound
+	}
+
+	if claims[cfg.SubName] == nil {
+		return Subject{}, ErrSubNotFound
+	}
+
+	return Subject{
+		Issuer:    strconv.ToString(claims[cfg.IssName]),
+		Sub:       strconv.ToString(claims[cfg.SubName]),
+		Qualifier: qualifier(claims, qualifierClaim),
+		ID:        strconv.ToString(claims["jti"]),
+	}, nil
+}
+
+// decide runs the topic checks of ACL with the claims of subject.
+func decide(
+	manager *topics.Manager,
+	allowed []acl.AccessType,
+	cfg config.JWT,
+	qualifierClaim string,
+	accessType acl.AccessType,
+	topic string,
+	s Subject,
+) error {
+	if !accessType.IsValid() {
+		return ErrInvalidAccessType
+	}
+
+	topicTemplate, topic, err := match(manager, cfg, qualifierClaim, topic, s, nil)
+	if err != nil {
+		return err
+	}
+
+	return check(allowed, topicTemplate, accessType, topic, s)
+}
+
+// match matches the normalized topic with the claims of subject, extra claims are used for the fields
+// of templates which are not the claims of subject. It returns the matched template and the normalized topic.
+func match(
+	manager *topics.Manager,
+	cfg config.JWT,
+	qualifierClaim string,
+	topic string,
+	s Subject,
+	extra map[string]any,
+) (*topics.Template, string, error) {
+	claims := make(map[string]any, len(extra)+3) //nolint: mnd
+
+	for k, v := range extra {
+		claims[k] = v
+	}
+
+	claims[cfg.IssName] = s.Issuer
+	claims[cfg.SubName] = s.Sub
+
+	if qualifierClaim != "" && s.Qualifier != "" {
+		claims[qualifierClaim] = s.Qualifier
+	}
+
+	topic = manager.Normalize(topic)
+
+	topicTemplate, err := manager.ParseTopic(context.Background(), topic, s.Issuer, s.Sub, claims)
+	if err != nil {
+		return nil, topic, err //nolint: wrapcheck
+	}
+
+	if topicTemplate == nil {
+		return nil, topic, InvalidTopicError{Topic: topic}
+	}
+
+	return topicTemplate, topic, nil
+}
+
+// check checks the access of subject to the normalized topic which is matched by the template.
+func check(allowed []acl.AccessType, topicTemplate *topics.Template, accessType acl.AccessType, topic string, s Subject) error {
+	if err := checkAccessType(allowed, topicTemplate, accessType); err != nil {
+		return err
+	}
+
+	if granted := topicTemplate.Access(s.Issuer, s.Qualifier); !granted.Allows(accessType) {
+		return TopicNotAllowedError{
+			Issuer:     s.Issuer,
+			Sub:        s.Sub,
+			AccessType: accessType,
+			Topic:      topic,
+			TopicType:  topicTemplate.Type,
+			Granted:    granted,
+		}
+	}
+
+	return topicTemplate.CheckFields(topic, s.Issuer, s.Sub) //nolint: wrapcheck
+}
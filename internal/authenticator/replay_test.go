@@ -0,0 +1,107 @@
+package authenticator_test
+
+import (
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/snapp-incubator/soteria/pkg/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestManualAuthenticator_Replay(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	cfg := config.SnappVendor()
+
+	for i := range cfg.Topics {
+		if cfg.Topics[i].Type == topics.CallOutgoing {
+			cfg.Topics[i].Accesses[topics.QualifiedKey(topics.DriverIss, "callee")] = acl.Deny
+		}
+	}
+
+	hid, err := topics.NewHashIDManager(cfg.HashIDMap)
+	require.NoError(err)
+
+	key := []byte("secret")
+
+	// nolint: exhaustruct
+	a := authenticator.ManualAuthenticator{
+		Keys:                 map[string]any{topics.DriverIss: key},
+		AllowedAccessTypes:   []acl.AccessType{acl.Pub, acl.Sub},
+		Company:              "snapp",
+		Parser:               jwt.NewParser(),
+		TopicManager:         topics.NewTopicManager(cfg.Topics, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop()),
+		JWTConfig:            cfg.Jwt,
+		AccessQualifierClaim: "call_role",
+	}
+
+	token, err := testutil.Token(jwt.SigningMethodHS512, key, testutil.Claims{
+		Issuer:       topics.DriverIss,
+		Subject:      testutil.DefaultSubject,
+		ExpiresIn:    0,
+		NoExpiration: false,
+		Extra:        map[string]any{"call_role": "callee"},
+		Kid:          "",
+	})
+	require.NoError(err)
+
+	subject, err := a.Subject(token)
+	require.NoError(err)
+	require.Equal(authenticator.Subject{
+		Issuer:    topics.DriverIss,
+		Sub:       testutil.DefaultSubject,
+		Qualifier: "callee",
+		ID:        "",
+	}, subject)
+
+	require.NoError(a.Decide(acl.Pub, "snapp/driver/"+testutil.DefaultSubject+"/location", subject))
+
+	// qualifier of subject is used in the replay.
+	err = a.Decide(acl.Sub, "snapp/driver/"+testutil.DefaultSubject+"/call/receive", subject)
+	require.ErrorAs(err, new(authenticator.TopicNotAllowedError))
+
+	err = a.Decide(acl.Sub, "snapp/driver/"+testutil.DefaultSubject+"/unknown", subject)
+	require.ErrorAs(err, new(authenticator.InvalidTopicError))
+}
+
+func TestStaticTopicAccessTypes(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	cfg := config.SnappVendor()
+
+	hid, err := topics.NewHashIDManager(cfg.HashIDMap)
+	require.NoError(err)
+
+	// nolint: exhaustruct
+	a := authenticator.ManualAuthenticator{
+		AllowedAccessTypes: []acl.AccessType{acl.Sub},
+		Company:            "snapp",
+		Parser:             jwt.NewParser(),
+		TopicManager: topics.NewTopicManager(cfg.Topics, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop()).
+			WithStaticTopics([]topics.StaticTopic{{
+				Pattern:  "snapp/ops/#",
+				Accesses: map[string]acl.AccessType{topics.DriverIss: acl.PubSub},
+			}}),
+		JWTConfig: cfg.Jwt,
+	}
+
+	subject := authenticator.Subject{Issuer: topics.DriverIss, Sub: testutil.DefaultSubject, Qualifier: "", ID: ""}
+
+	require.NoError(a.Decide(acl.Sub, "snapp/ops/broadcast", subject))
+
+	// static topics have no allowed access types, so the allowed access types of vendor are used.
+	var accessTypeErr authenticator.InvalidAccessTypeError
+
+	require.ErrorAs(a.Decide(acl.Pub, "snapp/ops/broadcast", subject), &accessTypeErr)
+	require.Equal(authenticator.AccessTypeLevelVendor, accessTypeErr.Level)
+	require.Equal(topics.StaticTopicType, accessTypeErr.TopicType)
+}
@@ -0,0 +1,18 @@
+package authenticator
+
+// SelfCheckAuthenticator is implemented by authenticators which can check their hash-ids and topic
+// templates at startup, so a bad configuration is found before it takes traffic.
+type SelfCheckAuthenticator interface {
+	// SelfCheck returns the failures of hash-id round trips and topic template renderings of vendor.
+	SelfCheck() []error
+}
+
+// SelfCheck returns the failures of hash-id round trips and topic template renderings of vendor.
+func (a ManualAuthenticator) SelfCheck() []error {
+	return a.TopicManager.SelfCheck()
+}
+
+// SelfCheck returns the failures of hash-id round trips and topic template renderings of vendor.
+func (a AutoAuthenticator) SelfCheck() []error {
+	return a.TopicManager.SelfCheck()
+}
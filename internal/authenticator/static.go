@@ -0,0 +1,164 @@
+package authenticator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// SingleLevelWildcard matches exactly one level of topic.
+	SingleLevelWildcard = topics.SingleLevelWildcard
+	// MultiLevelWildcard matches any number of levels at the end of topic.
+	MultiLevelWildcard = topics.MultiLevelWildcard
+
+	// MaxUsernameLength is the longest canonical username of static clients.
+	MaxUsernameLength = 64
+)
+
+// StaticAuthenticator is implemented by authenticators which have static clients. These clients
+// are authenticated using username and password and never use tokens.
+type StaticAuthenticator interface {
+	// StaticClient returns the static client with the given username.
+	StaticClient(username string) (StaticClient, bool)
+}
+
+type StaticTopic struct {
+	Pattern string
+	Access  acl.AccessType
+}
+
+// StaticClient is a client like an internal bridge which cannot use JWTs.
+type StaticClient struct {
+	Username     string
+	PasswordHash []byte
+	Topics       []StaticTopic
+}
+
+// StaticClients are static clients of a vendor by their username.
+type StaticClients map[string]StaticClient
+
+// Auth checks the password of static client.
+func (c StaticClient) Auth(password string) error {
+	if err := bcrypt.CompareHashAndPassword(c.PasswordHash, []byte(password)); err != nil {
+		return ErrIncorrectPassword
+	}
+
+	return nil
+}
+
+// ACL checks the topic against the patterns of static client.
+func (c StaticClient) ACL(accessType acl.AccessType, topic string) (bool, error) {
+	granted := acl.None
+
+	for _, t := range c.Topics {
+		if !MatchPattern(t.Pattern, topic) {
+			continue
+		}
+
+		if t.Access.Allows(accessType) {
+			return true, nil
+		}
+
+		granted = merge(granted, t.Access)
+	}
+
+	return false, TopicNotAllowedError{
+		Issuer:     c.Username,
+		Sub:        c.Username,
+		AccessType: accessType,
+		Topic:      topic,
+		TopicType:  "static",
+		Granted:    granted,
+	}
+}
+
+// merge returns the access type which grants both of the given access types.
+func merge(a, b acl.AccessType) acl.AccessType {
+	switch {
+	case len(a.Grants()) == 0:
+		return b
+	case len(b.Grants()) == 0, a == b:
+		return a
+	default:
+		return acl.PubSub
+	}
+}
+
+// MatchPattern checks the topic matches the pattern which can have MQTT wildcards.
+// wildcards in topic are matched only by the same or wider wildcards in pattern.
+func MatchPattern(pattern, topic string) bool {
+	return topics.MatchPattern(pattern, topic)
+}
+
+// CanonicalUsername returns the canonical form of username which is used by every lookup of static clients.
+func CanonicalUsername(username string) string {
+	return strings.ToLower(username)
+}
+
+// ValidUsername checks the username is canonical, canonical usernames are lowercase and only have
+// letters, digits, '.', '_' and '-' up to MaxUsernameLength characters.
+func ValidUsername(username string) bool {
+	if username == "" || len(username) > MaxUsernameLength {
+		return false
+	}
+
+	for _, r := range username {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// StaticClient returns the static client with the given username, usernames are matched by their canonical form.
+func (s StaticClients) StaticClient(username string) (StaticClient, bool) {
+	client, ok := s[CanonicalUsername(username)]
+
+	return client, ok
+}
+
+// GenerateStaticClients validates and creates static clients of a vendor.
+func (b Builder) GenerateStaticClients(clients []config.StaticClient) (StaticClients, error) {
+	result := make(StaticClients, len(clients))
+
+	for _, client := range clients {
+		if _, ok := result[client.Username]; ok || client.Username == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidStaticClient, client.Username)
+		}
+
+		// usernames which differ by case are the same client, so only the canonical ones are accepted.
+		if !ValidUsername(client.Username) {
+			return nil, fmt.Errorf("%w: %q is not a canonical username", ErrInvalidStaticClient, client.Username)
+		}
+
+		if _, err := bcrypt.Cost([]byte(client.Password)); err != nil {
+			return nil, fmt.Errorf("%w: %s password should be a bcrypt hash %w", ErrInvalidStaticClient, client.Username, err)
+		}
+
+		list := make([]StaticTopic, 0, len(client.Topics))
+
+		for _, topic := range client.Topics {
+			if !topic.Access.IsValid() {
+				return nil, InvalidTopicAccessError{TopicType: topic.Pattern, Issuer: client.Username, Access: topic.Access}
+			}
+
+			list = append(list, StaticTopic{Pattern: topic.Pattern, Access: topic.Access})
+		}
+
+		result[client.Username] = StaticClient{
+			Username:     client.Username,
+			PasswordHash: []byte(client.Password),
+			Topics:       list,
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,126 @@
+package authenticator_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestMatchPattern(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern string
+		topic   string
+		want    bool
+	}{
+		{pattern: "bridge/location", topic: "bridge/location", want: true},
+		{pattern: "bridge/location", topic: "bridge/location/1", want: false},
+		{pattern: "bridge/+/location", topic: "bridge/driver/location", want: true},
+		{pattern: "bridge/+/location", topic: "bridge/driver/1/location", want: false},
+		{pattern: "bridge/+", topic: "bridge", want: false},
+		{pattern: "bridge/#", topic: "bridge/driver/1/location", want: true},
+		{pattern: "bridge/#", topic: "bridge", want: true},
+		{pattern: "#", topic: "snapp/driver/1/location", want: true},
+		{pattern: "bridge/#/location", topic: "bridge/driver/location", want: false},
+		{pattern: "bridge/+", topic: "bridge/+", want: true},
+		{pattern: "bridge/+", topic: "bridge/#", want: false},
+		{pattern: "bridge/driver", topic: "bridge/+", want: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.pattern+" "+tc.topic, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.want, authenticator.MatchPattern(tc.pattern, tc.topic))
+		})
+	}
+}
+
+func TestStaticClient(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(err)
+
+	// nolint: exhaustruct
+	b := authenticator.Builder{Logger: zap.NewNop()}
+
+	clients, err := b.GenerateStaticClients([]config.StaticClient{
+		{
+			Username: "ingest",
+			Password: string(hash),
+			Topics: []config.StaticTopic{
+				{Pattern: "snapp/+/location", Access: acl.Sub},
+				{Pattern: "bridge/#", Access: acl.PubSub},
+			},
+		},
+	})
+	require.NoError(err)
+
+	client, ok := clients.StaticClient("ingest")
+	require.True(ok)
+
+	// lookups use the canonical username.
+	_, ok = clients.StaticClient("Ingest")
+	require.True(ok)
+
+	_, ok = clients.StaticClient("unknown")
+	require.False(ok)
+
+	require.NoError(client.Auth("secret"))
+	require.ErrorIs(client.Auth("wrong"), authenticator.ErrIncorrectPassword)
+
+	ok, err = client.ACL(acl.Sub, "snapp/driver/location")
+	require.NoError(err)
+	require.True(ok)
+
+	ok, err = client.ACL(acl.Pub, "bridge/events/1")
+	require.NoError(err)
+	require.True(ok)
+
+	ok, err = client.ACL(acl.Pub, "snapp/driver/location")
+	require.ErrorAs(err, new(authenticator.TopicNotAllowedError))
+	require.False(ok)
+}
+
+func TestGenerateStaticClientsInvalid(t *testing.T) {
+	t.Parallel()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	// nolint: exhaustruct
+	b := authenticator.Builder{Logger: zap.NewNop()}
+
+	_, err = b.GenerateStaticClients([]config.StaticClient{
+		{Username: "ingest", Password: "secret", Topics: nil},
+	})
+	require.ErrorIs(t, err, authenticator.ErrInvalidStaticClient)
+
+	_, err = b.GenerateStaticClients([]config.StaticClient{
+		{Username: "ingest", Password: string(hash), Topics: nil},
+		{Username: "ingest", Password: string(hash), Topics: nil},
+	})
+	require.ErrorIs(t, err, authenticator.ErrInvalidStaticClient)
+
+	_, err = b.GenerateStaticClients([]config.StaticClient{
+		{Username: "ingest", Password: string(hash), Topics: []config.StaticTopic{{Pattern: "#", Access: "x"}}},
+	})
+	require.ErrorAs(t, err, new(authenticator.InvalidTopicAccessError))
+
+	for _, username := range []string{"SnappBox", "snapp box", "snapp:box", strings.Repeat("a", 65)} {
+		_, err = b.GenerateStaticClients([]config.StaticClient{
+			{Username: username, Password: string(hash), Topics: nil},
+		})
+		require.ErrorIs(t, err, authenticator.ErrInvalidStaticClient, username)
+	}
+}
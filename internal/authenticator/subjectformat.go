@@ -0,0 +1,70 @@
+package authenticator
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/topics"
+)
+
+// SubjectFormats reject the subjects which don't have the format of their issuer, e.g. raw numeric ids
+// instead of hash-ids, which can match the topics of another subject. Subjects of issuers without format
+// are not checked and nil SubjectFormats doesn't check any subject.
+type SubjectFormats struct {
+	Patterns map[string]*regexp.Regexp
+	HashIDs  map[string]bool
+	// TopicManager decodes the hash-id subjects, decoded subjects are reused by the templates of request.
+	TopicManager *topics.Manager
+}
+
+// NewSubjectFormats returns nil when vendor has no subject formats.
+func NewSubjectFormats(formats map[string]config.SubjectFormat, manager *topics.Manager) (*SubjectFormats, error) {
+	if len(formats) == 0 {
+		return nil, nil //nolint: nilnil
+	}
+
+	f := &SubjectFormats{
+		Patterns:     make(map[string]*regexp.Regexp),
+		HashIDs:      make(map[string]bool),
+		TopicManager: manager,
+	}
+
+	for iss, format := range formats {
+		if format.Pattern != "" {
+			pattern, err := regexp.Compile(format.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid subject pattern of issuer %s %w", iss, err)
+			}
+
+			f.Patterns[iss] = pattern
+		}
+
+		if format.HashID {
+			f.HashIDs[iss] = true
+		}
+	}
+
+	return f, nil
+}
+
+// Check returns ErrInvalidSubjectFormat when the subject doesn't match the pattern of its issuer
+// or it doesn't decode as a hash-id of its issuer.
+func (f *SubjectFormats) Check(ctx context.Context, iss, sub string) error {
+	if f == nil {
+		return nil
+	}
+
+	if pattern, ok := f.Patterns[iss]; ok && !pattern.MatchString(sub) {
+		return fmt.Errorf("%w: subject of issuer %s doesn't match %s", ErrInvalidSubjectFormat, iss, pattern)
+	}
+
+	if f.HashIDs[iss] {
+		if _, err := f.TopicManager.DecodeSubject(ctx, sub, iss); err != nil {
+			return fmt.Errorf("%w: subject of issuer %s is not a hash-id: %w", ErrInvalidSubjectFormat, iss, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package authenticator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/snapp-incubator/soteria/pkg/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+)
+
+// nolint: funlen
+func TestManualAuthenticator_SubjectFormats(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.SnappVendor()
+
+	hid, err := topics.NewHashIDManager(cfg.HashIDMap)
+	require.NoError(t, err)
+
+	key := []byte("secret")
+	manager := topics.NewTopicManager(cfg.Topics, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop())
+
+	formats, err := authenticator.NewSubjectFormats(map[string]config.SubjectFormat{
+		topics.DriverIss:    {Pattern: "", HashID: true},
+		topics.PassengerIss: {Pattern: "^[A-Za-z0-9]{15}$", HashID: false},
+	}, manager)
+	require.NoError(t, err)
+
+	// nolint: exhaustruct
+	a := authenticator.ManualAuthenticator{
+		Keys:               map[string]any{topics.DriverIss: key, topics.PassengerIss: key},
+		AllowedAccessTypes: []acl.AccessType{acl.Pub, acl.Sub},
+		Company:            "snapp",
+		Parser:             jwt.NewParser(),
+		TopicManager:       manager,
+		JWTConfig:          cfg.Jwt,
+		SubjectFormats:     formats,
+	}
+
+	token := func(iss, sub string) string {
+		t.Helper()
+
+		token, err := testutil.Token(jwt.SigningMethodHS512, key, testutil.Claims{
+			Issuer:       iss,
+			Subject:      sub,
+			ExpiresIn:    0,
+			NoExpiration: false,
+			Extra:        nil,
+			Kid:          "",
+		})
+		require.NoError(t, err)
+
+		return token
+	}
+
+	ctx := context.Background()
+
+	t.Run("hash-id subjects are accepted", func(t *testing.T) {
+		t.Parallel()
+
+		require := require.New(t)
+
+		tk := token(topics.DriverIss, testutil.DefaultSubject)
+
+		require.NoError(a.Auth(ctx, tk))
+
+		_, err := a.ACL(ctx, acl.Pub, tk, "snapp/driver/"+testutil.DefaultSubject+"/location", 0)
+		require.NoError(err)
+	})
+
+	t.Run("raw numeric subjects are rejected", func(t *testing.T) {
+		t.Parallel()
+
+		require := require.New(t)
+
+		tk := token(topics.DriverIss, "1")
+
+		require.ErrorIs(a.Auth(ctx, tk), authenticator.ErrInvalidSubjectFormat)
+
+		_, err := a.ACL(ctx, acl.Pub, tk, "snapp/driver/1/location", 0)
+		require.ErrorIs(err, authenticator.ErrInvalidSubjectFormat)
+	})
+
+	t.Run("subjects are matched by the pattern of their issuer", func(t *testing.T) {
+		t.Parallel()
+
+		require := require.New(t)
+
+		require.NoError(a.Auth(ctx, token(topics.PassengerIss, testutil.DefaultSubject)))
+		require.ErrorIs(a.Auth(ctx, token(topics.PassengerIss, "1")), authenticator.ErrInvalidSubjectFormat)
+	})
+}
+
+func TestBuilderSubjectFormats(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	vendor := config.SnappVendor()
+	vendor.SubjectFormats = map[string]config.SubjectFormat{
+		topics.DriverIss: {Pattern: "", HashID: true},
+		// issuer 2 has no hash-id.
+		"2": {Pattern: "", HashID: true},
+	}
+
+	// nolint: exhaustruct
+	b := authenticator.Builder{
+		Vendors: []config.Vendor{vendor},
+		Logger:  zap.NewNop(),
+		Tracer:  noop.NewTracerProvider().Tracer(""),
+	}
+
+	err := b.Validate()
+	require.ErrorIs(err, authenticator.ErrInvalidSubjectRule)
+	require.EqualError(err, "vendors[0].subject_formats.2.hashid: "+
+		"subject format should have a valid pattern or be a hash-id of the issuer: issuer 2 has no hashid_map")
+
+	delete(b.Vendors[0].SubjectFormats, "2")
+	require.NoError(b.Validate())
+
+	// strict subject formats require a format for every issuer of iss_entity_map.
+	b.Vendors[0].StrictSubjectFormats = true
+
+	err = b.Validate()
+	require.ErrorIs(err, authenticator.ErrMissingSubjectFormat)
+	require.EqualError(err, "vendors[0].subject_formats: issuer of iss_entity_map has no subject format: 1")
+}
@@ -0,0 +1,49 @@
+package authenticator
+
+import (
+	"context"
+	"sync"
+)
+
+type subjectRecordKey struct{}
+
+// subjectRecord has the subject of the token of ACL request. ACL can be abandoned by its budget while it
+// runs, so the record has a lock.
+type subjectRecord struct {
+	lock    sync.Mutex
+	subject Subject
+	ok      bool
+}
+
+// WithSubjectRecord lets the ACL of request record the subject of its token, so the callers of ACL, like the
+// audit of decisions, don't parse the token again.
+func WithSubjectRecord(ctx context.Context) context.Context {
+	return context.WithValue(ctx, subjectRecordKey{}, new(subjectRecord))
+}
+
+// RecordedSubject returns the subject which the ACL of request recorded, it is false when the context has
+// no record or ACL failed before it found the subject of token.
+func RecordedSubject(ctx context.Context) (Subject, bool) {
+	r, ok := ctx.Value(subjectRecordKey{}).(*subjectRecord)
+	if !ok {
+		return Subject{}, false
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.subject, r.ok
+}
+
+func recordSubject(ctx context.Context, s Subject) {
+	r, ok := ctx.Value(subjectRecordKey{}).(*subjectRecord)
+	if !ok {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.subject = s
+	r.ok = true
+}
@@ -0,0 +1,530 @@
+package authenticator
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/remoteaccess"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+)
+
+var (
+	ErrUnknownSigningMethod = errors.New("unknown signing method")
+	ErrMissingIssuerKey     = errors.New("issuer of iss_entity_map has no key")
+	ErrUnknownTopicType     = errors.New("unknown topic type")
+	ErrUnknownAccessIssuer  = errors.New("access key is not an issuer of iss_entity_map or extra_issuers")
+	ErrIssuerWithoutAccess  = errors.New("issuer has no access on any topic")
+	ErrUnknownAccessSource  = errors.New("unknown accesses source")
+	ErrMissingSubjectFormat = errors.New("issuer of iss_entity_map has no subject format")
+	ErrInvalidSubjectRule   = errors.New("subject format should have a valid pattern or be a hash-id of the issuer")
+	ErrMissingHashData      = errors.New("topic uses hash-id of issuer which has no hashid_map, use type none for raw subjects")
+	ErrPolicyNotSupported   = errors.New("policy is only supported by manual vendors")
+)
+
+// ConfigError is an error of configuration with the path of its field,
+// e.g. vendors[1].topics[3].accesses.0.
+type ConfigError struct {
+	Path string
+	Err  error
+}
+
+func (err ConfigError) Error() string {
+	return fmt.Sprintf("%s: %s", err.Path, err.Err)
+}
+
+func (err ConfigError) Unwrap() error {
+	return err.Err
+}
+
+// Validate checks the vendors without building them and joins all of their errors,
+// so operators can fix every error of configuration in one deploy.
+func (b Builder) Validate() error {
+	errs := make([]error, 0)
+
+	for i, vendor := range b.Vendors {
+		errs = append(errs, b.validateVendor(fmt.Sprintf("vendors[%d]", i), vendor)...)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (b Builder) validateVendor(path string, vendor config.Vendor) []error {
+	if _, ok := factory(vendor.Type); !ok {
+		return []error{ConfigError{
+			Path: path + ".type",
+			Err:  fmt.Errorf("%q: %w", vendor.Type, ErrInvalidAuthenticator),
+		}}
+	}
+
+	errs := validateAccessTypes(path+".allowed_access_types", vendor.AllowedAccessTypes)
+
+	for i, topic := range vendor.Topics {
+		errs = append(errs, validateTopic(fmt.Sprintf("%s.topics[%d]", path, i), topic)...)
+	}
+
+	for i, static := range vendor.StaticTopics {
+		errs = append(errs, validateStaticTopic(fmt.Sprintf("%s.static_topics[%d]", path, i), static)...)
+	}
+
+	errs = append(errs, validateNoExpiryTopicTypes(path+".no_expiry_topic_types", vendor)...)
+	errs = append(errs, validateAccessIssuers(path, vendor)...)
+	errs = append(errs, validateSubjectFormats(path, vendor)...)
+	errs = append(errs, validateHashData(path, vendor)...)
+	errs = append(errs, validateEntityRules(path, vendor)...)
+
+	switch vendor.Type {
+	case "admin", "internal":
+		if _, ok := vendor.Keys["system"]; !ok || len(vendor.Keys) != 1 {
+			errs = append(errs, ConfigError{Path: path + ".keys", Err: ErrAdminAuthenticatorSystemKey})
+		}
+
+		errs = append(errs, b.validateKeys(path, vendor)...)
+	case "manual":
+		if _, ok := vendor.IssEntityMap[topics.Default]; !ok {
+			errs = append(errs, ConfigError{Path: path + ".iss_entity_map", Err: ErrNoDefaultCaseIssEntity})
+		}
+
+		if _, ok := vendor.IssPeerMap[topics.Default]; !ok {
+			errs = append(errs, ConfigError{Path: path + ".iss_peer_map", Err: ErrNoDefaultCaseIssPeer})
+		}
+
+		errs = append(errs, b.validateKeys(path, vendor)...)
+		errs = append(errs, validateIssuerKeys(path, vendor)...)
+
+		if vendor.Policy != nil {
+			if err := vendor.Policy.Validate(); err != nil {
+				errs = append(errs, ConfigError{Path: path + ".policy", Err: err})
+			}
+		}
+	case "auto":
+		// keys of auto vendors are only used for verifying their acl tokens.
+		if vendor.ACLVerification != nil && (len(vendor.Keys) > 0 || len(vendor.VerificationKeys) > 0) {
+			errs = append(errs, b.validateKeys(path, vendor)...)
+		}
+	}
+
+	// policies are only evaluated by manual authenticators, so they would be ignored silently on other vendors.
+	if vendor.Policy != nil && vendor.Type != "manual" {
+		errs = append(errs, ConfigError{Path: path + ".policy", Err: ErrPolicyNotSupported})
+	}
+
+	return errs
+}
+
+func validateAccessTypes(path string, accessTypes []string) []error {
+	errs := make([]error, 0)
+
+	for i, access := range accessTypes {
+		if _, err := toUserAccessType(access); err != nil {
+			errs = append(errs, ConfigError{
+				Path: fmt.Sprintf("%s[%d]", path, i),
+				Err:  fmt.Errorf("unknown access type %q: %w", access, err),
+			})
+		}
+	}
+
+	return errs
+}
+
+func validateTopic(path string, topic topics.Topic) []error {
+	errs := make([]error, 0)
+
+	if err := topic.Validate(); err != nil {
+		errs = append(errs, ConfigError{Path: path, Err: err})
+	}
+
+	for _, iss := range slices.Sorted(maps.Keys(topic.Accesses)) {
+		if access := topic.Accesses[iss]; !access.IsValid() {
+			errs = append(errs, ConfigError{
+				Path: path + ".accesses." + iss,
+				Err:  InvalidTopicAccessError{TopicType: topic.Type, Issuer: iss, Access: access},
+			})
+		}
+	}
+
+	if topic.AllowedAccessTypes != nil {
+		errs = append(errs, validateAccessTypes(path+".allowed_access_types", topic.AllowedAccessTypes)...)
+	}
+
+	if ride := topic.RideMembership; ride != nil && (ride.Segment < 0 || ride.Segment >= topics.MaxSegments) {
+		errs = append(errs, ConfigError{
+			Path: path + ".ride_membership.segment",
+			Err:  fmt.Errorf("%w: %s has segment %d", ErrInvalidRideMembership, topic.Type, ride.Segment),
+		})
+	}
+
+	for _, field := range slices.Sorted(maps.Keys(topic.AllowedValues)) {
+		if _, err := topic.AllowedValues[field].Matcher(field); err != nil {
+			errs = append(errs, ConfigError{Path: path + ".allowed_values." + field, Err: err})
+		}
+	}
+
+	for i, check := range topic.PayloadChecks {
+		if err := check.Validate(); err != nil {
+			errs = append(errs, ConfigError{Path: fmt.Sprintf("%s.payload_checks[%d]", path, i), Err: err})
+		}
+	}
+
+	return append(errs, validateAccessesSource(path, topic)...)
+}
+
+// validateAccessesSource checks the accesses source of topic is known and remote topics have remote accesses.
+func validateAccessesSource(path string, topic topics.Topic) []error {
+	switch topic.AccessesSource {
+	case "", topics.AccessesSourceStatic:
+		return nil
+	case topics.AccessesSourceRemote:
+	default:
+		return []error{ConfigError{
+			Path: path + ".accesses_source",
+			Err:  fmt.Errorf("%w %q", ErrUnknownAccessSource, topic.AccessesSource),
+		}}
+	}
+
+	if topic.RemoteAccesses == nil {
+		return []error{ConfigError{Path: path + ".remote_accesses", Err: remoteaccess.ErrMissingURL}}
+	}
+
+	if err := topic.RemoteAccesses.Validate(); err != nil {
+		return []error{ConfigError{Path: path + ".remote_accesses", Err: err}}
+	}
+
+	return nil
+}
+
+func validateStaticTopic(path string, static topics.StaticTopic) []error {
+	errs := make([]error, 0)
+
+	if err := static.Validate(); err != nil {
+		errs = append(errs, ConfigError{Path: path + ".pattern", Err: err})
+	}
+
+	for _, iss := range slices.Sorted(maps.Keys(static.Accesses)) {
+		if access := static.Accesses[iss]; !access.IsValid() {
+			errs = append(errs, ConfigError{
+				Path: path + ".accesses." + iss,
+				Err:  InvalidTopicAccessError{TopicType: topics.StaticTopicType, Issuer: iss, Access: access},
+			})
+		}
+	}
+
+	return errs
+}
+
+// Warnings checks the vendors for configuration which is valid but probably wrong, like issuers of
+// iss_entity_map which have no access on any topic or static topic. Each warning is a ConfigError.
+func (b Builder) Warnings() []error {
+	warnings := make([]error, 0)
+
+	for i, vendor := range b.Vendors {
+		accessible := slices.Clone(vendor.Topics)
+
+		for _, static := range vendor.StaticTopics {
+			accessible = append(accessible, topics.Topic{Type: topics.StaticTopicType, Accesses: static.Accesses}) // nolint: exhaustruct
+		}
+
+		for _, iss := range slices.Sorted(maps.Keys(vendor.IssEntityMap)) {
+			if iss == topics.Default || hasAccess(accessible, iss) {
+				continue
+			}
+
+			warnings = append(warnings, ConfigError{
+				Path: fmt.Sprintf("vendors[%d].iss_entity_map.%s", i, iss),
+				Err:  fmt.Errorf("%w: %s", ErrIssuerWithoutAccess, vendor.IssEntityMap[iss]),
+			})
+		}
+	}
+
+	return warnings
+}
+
+// hasAccess checks the issuer has access on at least one of topics, with or without qualifier.
+// Topics with remote accesses may grant every issuer, so they are accessible.
+func hasAccess(topicList []topics.Topic, iss string) bool {
+	for _, topic := range topicList {
+		if topic.AccessesSource == topics.AccessesSourceRemote {
+			return true
+		}
+
+		// nolint: exhaustruct
+		if len(topics.Template{Accesses: topic.Accesses}.Access(iss, "").Grants()) > 0 {
+			return true
+		}
+
+		for key, access := range topic.Accesses {
+			if strings.HasPrefix(key, iss+topics.QualifierSeparator) && len(access.Grants()) > 0 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// validateAccessIssuers checks the keys of topic accesses are issuers of iss_entity_map or extra_issuers,
+// or issuers and entities of iss_entity_rules, so a typo in a key doesn't silently deny its issuer.
+// Vendors without issuers only use the default access.
+func validateAccessIssuers(path string, vendor config.Vendor) []error {
+	issuers := make(map[string]struct{})
+
+	for iss := range vendor.IssEntityMap {
+		if iss != topics.Default {
+			issuers[iss] = struct{}{}
+		}
+	}
+
+	for _, iss := range vendor.ExtraIssuers {
+		issuers[iss] = struct{}{}
+	}
+
+	for _, rule := range vendor.IssEntityRules {
+		issuers[rule.Iss] = struct{}{}
+		issuers[rule.Entity] = struct{}{}
+	}
+
+	errs := make([]error, 0)
+
+	if len(issuers) == 0 {
+		return errs
+	}
+
+	unknown := func(path string, accesses map[string]acl.AccessType) {
+		for _, key := range slices.Sorted(maps.Keys(accesses)) {
+			iss, _, _ := strings.Cut(key, topics.QualifierSeparator)
+
+			if _, ok := issuers[iss]; ok || key == topics.Default {
+				continue
+			}
+
+			errs = append(errs, ConfigError{
+				Path: path + ".accesses." + key,
+				Err:  fmt.Errorf("%w %q", ErrUnknownAccessIssuer, key),
+			})
+		}
+	}
+
+	for i, topic := range vendor.Topics {
+		unknown(fmt.Sprintf("%s.topics[%d]", path, i), topic.Accesses)
+	}
+
+	for i, static := range vendor.StaticTopics {
+		unknown(fmt.Sprintf("%s.static_topics[%d]", path, i), static.Accesses)
+	}
+
+	return errs
+}
+
+// validateNoExpiryTopicTypes checks the topic types of no expiry are topic types of vendor,
+// the static topic type is known when vendor has static topics.
+func validateNoExpiryTopicTypes(path string, vendor config.Vendor) []error {
+	errs := make([]error, 0)
+
+	for i, topicType := range vendor.NoExpiryTopicTypes {
+		if topicType == topics.StaticTopicType && len(vendor.StaticTopics) > 0 {
+			continue
+		}
+
+		if !slices.ContainsFunc(vendor.Topics, func(topic topics.Topic) bool { return topic.Type == topicType }) {
+			errs = append(errs, ConfigError{
+				Path: fmt.Sprintf("%s[%d]", path, i),
+				Err:  fmt.Errorf("%w %q", ErrUnknownTopicType, topicType),
+			})
+		}
+	}
+
+	return errs
+}
+
+// validateSubjectFormats checks the subject formats have a valid pattern or are hash-ids of issuers which
+// have hash-id, and every issuer of iss_entity_map has a subject format when they are strict.
+func validateSubjectFormats(path string, vendor config.Vendor) []error {
+	errs := make([]error, 0)
+
+	for _, iss := range slices.Sorted(maps.Keys(vendor.SubjectFormats)) {
+		format := vendor.SubjectFormats[iss]
+
+		if format.Pattern == "" && !format.HashID {
+			errs = append(errs, ConfigError{
+				Path: fmt.Sprintf("%s.subject_formats.%s", path, iss),
+				Err:  ErrInvalidSubjectRule,
+			})
+		}
+
+		if _, err := regexp.Compile(format.Pattern); err != nil {
+			errs = append(errs, ConfigError{
+				Path: fmt.Sprintf("%s.subject_formats.%s.pattern", path, iss),
+				Err:  fmt.Errorf("%w: %w", ErrInvalidSubjectRule, err),
+			})
+		}
+
+		if _, ok := vendor.HashIDMap[iss]; format.HashID && !ok {
+			errs = append(errs, ConfigError{
+				Path: fmt.Sprintf("%s.subject_formats.%s.hashid", path, iss),
+				Err:  fmt.Errorf("%w: issuer %s has no hashid_map", ErrInvalidSubjectRule, iss),
+			})
+		}
+	}
+
+	if !vendor.StrictSubjectFormats {
+		return errs
+	}
+
+	for _, iss := range slices.Sorted(maps.Keys(vendor.IssEntityMap)) {
+		if _, ok := vendor.SubjectFormats[iss]; !ok && iss != topics.Default {
+			errs = append(errs, ConfigError{
+				Path: path + ".subject_formats",
+				Err:  fmt.Errorf("%w: %s", ErrMissingSubjectFormat, iss),
+			})
+		}
+	}
+
+	return errs
+}
+
+// validateKeys checks the signing method and reads the keys of vendor one by one.
+func (b Builder) validateKeys(path string, vendor config.Vendor) []error {
+	if method := vendor.Jwt.SigningMethod; jwt.GetSigningMethod(method) == nil {
+		return []error{ConfigError{
+			Path: path + ".jwt.signing_method",
+			Err:  fmt.Errorf("%w %q", ErrUnknownSigningMethod, method),
+		}}
+	}
+
+	errs := make([]error, 0)
+
+	for _, iss := range slices.Sorted(maps.Keys(vendor.Jwt.IssuerSigningMethods)) {
+		if method := vendor.Jwt.IssuerSigningMethods[iss]; jwt.GetSigningMethod(method) == nil {
+			errs = append(errs, ConfigError{
+				Path: path + ".jwt.issuer_signing_methods." + iss,
+				Err:  fmt.Errorf("%w %q", ErrUnknownSigningMethod, method),
+			})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	for _, iss := range slices.Sorted(maps.Keys(vendor.Keys)) {
+		method := vendor.Jwt.IssuerSigningMethod(iss)
+
+		if _, err := b.GenerateKeys(method, map[string]string{iss: vendor.Keys[iss]}); err != nil {
+			errs = append(errs, ConfigError{Path: path + ".keys." + iss, Err: err})
+		}
+	}
+
+	for _, iss := range slices.Sorted(maps.Keys(vendor.VerificationKeys)) {
+		for i, key := range vendor.VerificationKeys[iss] {
+			method := vendor.Jwt.IssuerSigningMethod(iss)
+
+			if _, err := b.GenerateKeys(method, map[string]string{iss: key.Key}); err != nil {
+				errs = append(errs, ConfigError{
+					Path: fmt.Sprintf("%s.verification_keys.%s[%d]", path, iss, i),
+					Err:  err,
+				})
+			}
+		}
+	}
+
+	return errs
+}
+
+// validateIssuerKeys checks the issuers of iss_entity_map and iss_entity_rules have keys.
+func validateIssuerKeys(path string, vendor config.Vendor) []error {
+	if len(vendor.Keys) == 0 && len(vendor.VerificationKeys) == 0 {
+		return []error{ConfigError{Path: path + ".keys", Err: ErrNoKeys}}
+	}
+
+	errs := make([]error, 0)
+
+	issuers := slices.Collect(maps.Keys(vendor.IssEntityMap))
+	for _, rule := range vendor.IssEntityRules {
+		issuers = append(issuers, rule.Iss)
+	}
+
+	slices.Sort(issuers)
+
+	for _, iss := range slices.Compact(issuers) {
+		if iss == topics.Default {
+			continue
+		}
+
+		_, ok := vendor.Keys[iss]
+		if _, verification := vendor.VerificationKeys[iss]; !ok && !verification {
+			errs = append(errs, ConfigError{Path: path + ".keys." + iss, Err: ErrMissingIssuerKey})
+		}
+	}
+
+	return errs
+}
+
+// validateHashData checks the hash types of issuers are known and the issuers of topics which use
+// hash-id in their templates have hash data, accesses of the default issuer are not checked.
+// Entities of iss_entity_rules use the hash data of their issuer when they don't have their own.
+func validateHashData(path string, vendor config.Vendor) []error {
+	errs := make([]error, 0)
+
+	entities := make(map[string]string)
+	for _, rule := range vendor.IssEntityRules {
+		entities[rule.Entity] = rule.Iss
+	}
+
+	for _, iss := range slices.Sorted(maps.Keys(vendor.HashIDMap)) {
+		if err := vendor.HashIDMap[iss].Validate(); err != nil {
+			errs = append(errs, ConfigError{Path: fmt.Sprintf("%s.hashid_map.%s.type", path, iss), Err: err})
+		}
+	}
+
+	for i, topic := range vendor.Topics {
+		if !strings.Contains(topic.Template, "DecodeHashID") && !strings.Contains(topic.Template, "EncodeHashID") {
+			continue
+		}
+
+		for _, key := range slices.Sorted(maps.Keys(topic.Accesses)) {
+			iss, _, _ := strings.Cut(key, topics.QualifierSeparator)
+
+			if _, ok := vendor.HashIDMap[iss]; ok || key == topics.Default {
+				continue
+			}
+
+			if _, ok := vendor.HashIDMap[entities[iss]]; ok {
+				continue
+			}
+
+			errs = append(errs, ConfigError{
+				Path: fmt.Sprintf("%s.topics[%d].accesses.%s", path, i, key),
+				Err:  fmt.Errorf("%w: %s", ErrMissingHashData, iss),
+			})
+		}
+	}
+
+	return errs
+}
+
+// validateEntityRules checks the entity rules are valid and reachable, and their issuers have a default entry.
+func validateEntityRules(path string, vendor config.Vendor) []error {
+	errs := make([]error, 0)
+
+	for _, err := range topics.ValidateEntityRules(vendor.IssEntityRules, vendor.IssEntityMap) {
+		var ruleErr topics.EntityRuleError
+		if !errors.As(err, &ruleErr) {
+			errs = append(errs, ConfigError{Path: path + ".iss_entity_rules", Err: err})
+
+			continue
+		}
+
+		errs = append(errs, ConfigError{
+			Path: fmt.Sprintf("%s.iss_entity_rules[%d]", path, ruleErr.Index),
+			Err:  ruleErr.Err,
+		})
+	}
+
+	return errs
+}
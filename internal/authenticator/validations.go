@@ -0,0 +1,266 @@
+package authenticator
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/metric"
+	"github.com/snapp-incubator/soteria/internal/singleflight"
+	"github.com/snapp-incubator/soteria/internal/worker"
+	"github.com/snapp-incubator/soteria/pkg/validator"
+)
+
+const (
+	// ValidationCacheHit means token is validated by its fresh cache entry.
+	ValidationCacheHit = "hit"
+	// ValidationCacheStale means token is validated by its stale cache entry while it is validated again.
+	ValidationCacheStale = "stale"
+	// ValidationCacheMiss means token is validated by calling the validator.
+	ValidationCacheMiss = "miss"
+
+	// maxValidations bounds the cache, expired entries are removed when cache reaches it.
+	maxValidations = 100_000
+
+	// revalidationComponent is the component of revalidation tasks in the background pool.
+	revalidationComponent = "revalidation"
+)
+
+type validation struct {
+	fresh      time.Time
+	expires    time.Time
+	refreshing bool
+}
+
+// Validations coalesces the concurrent validator calls of a token and caches the valid tokens
+// with stale-while-revalidate. Nil validations call the validator for every token.
+type Validations struct {
+	company  string
+	cacheTTL time.Duration
+	staleTTL time.Duration
+	parser   *jwt.Parser
+	metrics  *metric.AutoAuthenticatorMetrics
+	// background runs the revalidations, they run in their own goroutines when it is nil.
+	background *worker.Pool
+
+	flight singleflight.Group[struct{}]
+
+	lock  sync.Mutex
+	cache map[[sha256.Size]byte]*validation
+}
+
+// NewValidations creates the validations of a vendor, tokens are cached when cache ttl of validator is set.
+func NewValidations(company string, cfg config.Validator, metrics *metric.AutoAuthenticatorMetrics) *Validations {
+	return &Validations{
+		company:    company,
+		cacheTTL:   cfg.CacheTTL,
+		staleTTL:   cfg.StaleTTL,
+		parser:     jwt.NewParser(),
+		metrics:    metrics,
+		background: nil,
+		flight:     singleflight.Group[struct{}]{},
+		lock:       sync.Mutex{},
+		cache:      make(map[[sha256.Size]byte]*validation),
+	}
+}
+
+// WithBackground runs the revalidation of stale tokens on the pool.
+func (v *Validations) WithBackground(pool *worker.Pool) *Validations {
+	v.background = pool
+
+	return v
+}
+
+// Validate validates the token using the cache and calls validate on cache misses, concurrent calls
+// of the same token are coalesced. Stale tokens are valid while they are validated again in background.
+func (v *Validations) Validate(ctx context.Context, token string, validate func(context.Context) error) error {
+	if v == nil {
+		return validate(ctx)
+	}
+
+	key := sha256.Sum256([]byte(token))
+
+	if v.cacheTTL > 0 {
+		result, refresh := v.lookup(key)
+
+		v.metrics.ValidationCache(v.company, result)
+
+		switch result {
+		case ValidationCacheHit:
+			return nil
+		case ValidationCacheStale:
+			if refresh {
+				// revalidation is detached from the request context which is canceled after the response.
+				if !v.background.Submit(ctx, revalidationComponent, func(ctx context.Context) {
+					_ = v.call(ctx, key, token, validate)
+				}) {
+					v.release(key)
+				}
+			}
+
+			return nil
+		}
+	}
+
+	return v.call(ctx, key, token, validate)
+}
+
+func (v *Validations) call(
+	ctx context.Context,
+	key [sha256.Size]byte,
+	token string,
+	validate func(context.Context) error,
+) error {
+	// the call is shared by the waiting requests, so it doesn't fail when the request which runs it
+	// is canceled, validator calls have their own timeout.
+	_, err, shared := v.flight.Do(string(key[:]), func() (struct{}, error) {
+		return struct{}{}, validate(context.WithoutCancel(ctx))
+	})
+	if shared {
+		v.metrics.Coalesced(v.company, "validator")
+	}
+
+	if v.cacheTTL > 0 {
+		v.store(key, token, err)
+	}
+
+	return err
+}
+
+// Flush removes the cached tokens, running validations are not canceled.
+func (v *Validations) Flush() {
+	if v == nil {
+		return
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	v.cache = make(map[[sha256.Size]byte]*validation)
+}
+
+// Validated reports whether the token is cached as valid, fresh or stale, without validating it.
+// Nil validations and validations without cache have no valid tokens.
+func (v *Validations) Validated(token string) bool {
+	if v == nil || v.cacheTTL <= 0 {
+		return false
+	}
+
+	key := sha256.Sum256([]byte(token))
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	e, ok := v.cache[key]
+
+	return ok && time.Now().Before(e.expires)
+}
+
+// lookup returns the cache result of token, refresh is true for the one caller which revalidates the stale token.
+func (v *Validations) lookup(key [sha256.Size]byte) (string, bool) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	now := time.Now()
+
+	e, ok := v.cache[key]
+	if !ok || now.After(e.expires) {
+		return ValidationCacheMiss, false
+	}
+
+	if now.Before(e.fresh) {
+		return ValidationCacheHit, false
+	}
+
+	refresh := !e.refreshing
+	e.refreshing = true
+
+	return ValidationCacheStale, refresh
+}
+
+// release lets the next caller revalidate the stale token when its revalidation is dropped.
+func (v *Validations) release(key [sha256.Size]byte) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if e, ok := v.cache[key]; ok {
+		e.refreshing = false
+	}
+}
+
+// store caches the valid tokens and removes the rejected ones, tokens are kept on the other errors
+// like validator timeouts, so their stale entries are served until they are expired.
+func (v *Validations) store(key [sha256.Size]byte, token string, err error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if err != nil {
+		if rejected(err) {
+			delete(v.cache, key)
+		} else if e, ok := v.cache[key]; ok {
+			e.refreshing = false
+		}
+
+		return
+	}
+
+	now := time.Now()
+
+	if len(v.cache) >= maxValidations {
+		for k, e := range v.cache {
+			if now.After(e.expires) {
+				delete(v.cache, k)
+			}
+		}
+
+		if len(v.cache) >= maxValidations {
+			v.cache = make(map[[sha256.Size]byte]*validation)
+		}
+	}
+
+	e := &validation{
+		fresh:      now.Add(v.cacheTTL),
+		expires:    now.Add(v.cacheTTL + v.staleTTL),
+		refreshing: false,
+	}
+
+	// tokens are never cached after their expiration.
+	if exp := v.expiration(token); !exp.IsZero() {
+		if exp.Before(e.fresh) {
+			e.fresh = exp
+		}
+
+		if exp.Before(e.expires) {
+			e.expires = exp
+		}
+	}
+
+	v.cache[key] = e
+}
+
+// expiration returns the exp claim of the validated token, it is zero when token doesn't have it.
+func (v *Validations) expiration(token string) time.Time {
+	var claims jwt.MapClaims
+
+	if _, _, err := v.parser.ParseUnverified(token, &claims); err != nil {
+		return time.Time{}
+	}
+
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return time.Time{}
+	}
+
+	return exp.Time
+}
+
+// rejected checks the validator rejected the token instead of failing to validate it.
+func rejected(err error) bool {
+	return errors.Is(err, validator.ErrRequestFailed) ||
+		errors.Is(err, validator.ErrInvalidJWT) ||
+		errors.Is(err, validator.ErrInvalidUserDataHeader)
+}
@@ -0,0 +1,172 @@
+package authenticator_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/metric"
+	"github.com/snapp-incubator/soteria/internal/worker"
+	"github.com/snapp-incubator/soteria/pkg/testutil"
+	"github.com/snapp-incubator/soteria/pkg/validator"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// nolint: exhaustruct
+func TestValidationsCoalesce(t *testing.T) {
+	t.Parallel()
+
+	metrics := metric.NewAutoAuthenticatorMetrics(prometheus.DefaultRegisterer)
+	v := authenticator.NewValidations("snapp", config.Validator{}, metrics)
+
+	var (
+		calls atomic.Int32
+		wg    sync.WaitGroup
+	)
+
+	release := make(chan struct{})
+
+	validate := func(context.Context) error {
+		calls.Add(1)
+		<-release
+
+		return nil
+	}
+
+	const callers = 20
+
+	for range callers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			require.NoError(t, v.Validate(context.Background(), "token", validate))
+		}()
+	}
+
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	// waits for the other callers to join the running call.
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	require.Equal(t, int32(1), calls.Load())
+}
+
+// nolint: exhaustruct
+func TestValidationsStaleWhileRevalidate(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	v := authenticator.NewValidations("snapp", config.Validator{
+		CacheTTL: 50 * time.Millisecond,
+		StaleTTL: time.Hour,
+	}, metric.NewAutoAuthenticatorMetrics(prometheus.DefaultRegisterer))
+
+	token, err := testutil.DriverToken(jwt.SigningMethodHS512, []byte("secret"))
+	require.NoError(err)
+
+	var (
+		calls  atomic.Int32
+		reject atomic.Bool
+	)
+
+	validate := func(context.Context) error {
+		calls.Add(1)
+
+		if reject.Load() {
+			return validator.ErrRequestFailed
+		}
+
+		return nil
+	}
+
+	require.NoError(v.Validate(context.Background(), token, validate))
+	require.NoError(v.Validate(context.Background(), token, validate))
+	require.Equal(int32(1), calls.Load())
+
+	time.Sleep(60 * time.Millisecond)
+
+	// stale token is valid and it is validated again in background.
+	reject.Store(true)
+	require.NoError(v.Validate(context.Background(), token, validate))
+	require.Eventually(func() bool { return calls.Load() == 2 }, time.Second, time.Millisecond)
+
+	// rejected token is removed from the cache.
+	require.Eventually(func() bool {
+		return v.Validate(context.Background(), token, validate) != nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+// nolint: exhaustruct
+func TestValidationsExpiredToken(t *testing.T) {
+	t.Parallel()
+
+	v := authenticator.NewValidations("snapp", config.Validator{
+		CacheTTL: time.Hour,
+		StaleTTL: time.Hour,
+	}, metric.NewAutoAuthenticatorMetrics(prometheus.DefaultRegisterer))
+
+	token, err := testutil.Token(jwt.SigningMethodHS512, []byte("secret"), testutil.Claims{
+		Issuer:    "0",
+		Subject:   testutil.DefaultSubject,
+		ExpiresIn: -time.Minute,
+	})
+	require.NoError(t, err)
+
+	var calls atomic.Int32
+
+	validate := func(context.Context) error {
+		calls.Add(1)
+
+		return nil
+	}
+
+	require.NoError(t, v.Validate(context.Background(), token, validate))
+	require.NoError(t, v.Validate(context.Background(), token, validate))
+	require.Equal(t, int32(2), calls.Load())
+}
+
+// nolint: exhaustruct
+func TestValidationsBackground(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	pool := worker.New(worker.Config{Workers: 1, QueueSize: 1}, zap.NewNop())
+
+	v := authenticator.NewValidations("snapp", config.Validator{
+		CacheTTL: 50 * time.Millisecond,
+		StaleTTL: time.Hour,
+	}, metric.NewAutoAuthenticatorMetrics(prometheus.DefaultRegisterer)).WithBackground(pool)
+
+	token, err := testutil.DriverToken(jwt.SigningMethodHS512, []byte("secret"))
+	require.NoError(err)
+
+	var calls atomic.Int32
+
+	validate := func(context.Context) error {
+		calls.Add(1)
+
+		return nil
+	}
+
+	require.NoError(v.Validate(context.Background(), token, validate))
+
+	time.Sleep(60 * time.Millisecond)
+
+	// stale token is validated again on the pool and its queued revalidation is drained on stop.
+	require.NoError(v.Validate(context.Background(), token, validate))
+	require.NoError(pool.Stop())
+	require.Equal(int32(2), calls.Load())
+}
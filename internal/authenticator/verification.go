@@ -0,0 +1,91 @@
+package authenticator
+
+import (
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/snapp-incubator/soteria/internal/config"
+)
+
+// KidHeader is the token header which identifies the signing key.
+const KidHeader = "kid"
+
+// VerificationKey is one of the keys of an issuer which can verify its tokens,
+// issuers have more than one key during the key rotation.
+type VerificationKey struct {
+	Kid string
+	Key any
+}
+
+// verificationKey returns the key of token issuer with its kid. The key with the same kid as token header
+// is used, otherwise the parser tries the keys in order and stops at the first key which verifies the signature,
+// so the token is verified once. The verifying key of these tokens is not known, so their kid is empty.
+// issuers without verification keys use their single key.
+func verificationKey(
+	token *jwt.Token,
+	issuer string,
+	keys map[string]any,
+	verificationKeys map[string][]VerificationKey,
+) (any, string, error) {
+	list := verificationKeys[issuer]
+
+	if len(list) == 0 {
+		key := keys[issuer]
+		if key == nil {
+			return nil, "", KeyNotFoundError{Issuer: issuer}
+		}
+
+		return key, "", nil
+	}
+
+	if kid, ok := token.Header[KidHeader].(string); ok && kid != "" {
+		for _, key := range list {
+			if key.Kid == kid {
+				return key.Key, key.Kid, nil
+			}
+		}
+	}
+
+	set := jwt.VerificationKeySet{Keys: make([]jwt.VerificationKey, 0, len(list))}
+
+	for _, key := range list {
+		set.Keys = append(set.Keys, key.Key)
+	}
+
+	return set, "", nil
+}
+
+// GenerateVerificationKeys reads the verification keys of issuers, which are identified by their unique kid.
+// Single keys of issuers are added at the end of their verification keys without kid.
+func (b Builder) GenerateVerificationKeys(
+	cfg config.JWT,
+	raw map[string][]config.VerificationKey,
+	keys map[string]any,
+) (map[string][]VerificationKey, error) {
+	result := make(map[string][]VerificationKey)
+
+	for iss, list := range raw {
+		kids := make(map[string]bool, len(list))
+
+		for i, k := range list {
+			if k.Kid == "" || kids[k.Kid] {
+				return nil, fmt.Errorf("%w: verification_keys[%d] of issuer %s", ErrInvalidVerificationKey, i, iss)
+			}
+
+			kids[k.Kid] = true
+
+			generated, err := b.GenerateKeys(cfg.IssuerSigningMethod(iss), map[string]string{iss: k.Key})
+			if err != nil {
+				return nil, fmt.Errorf("reading verification key %s of issuer %s failed %w", k.Kid, iss, err)
+			}
+
+			result[iss] = append(result[iss], VerificationKey{Kid: k.Kid, Key: generated[iss]})
+		}
+
+		if key, ok := keys[iss]; ok {
+			result[iss] = append(result[iss], VerificationKey{Kid: "", Key: key})
+		}
+	}
+
+	return result, nil
+}
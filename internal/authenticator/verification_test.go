@@ -0,0 +1,130 @@
+package authenticator_test
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+)
+
+// nolint: funlen
+func TestVerificationKeys(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	oldKey := []byte("old-secret")
+	newKey := []byte("new-secret")
+	legacyKey := []byte("legacy-secret")
+
+	vendor := config.SnappVendor()
+	vendor.Company = "snapp-rotation"
+	vendor.Jwt.SigningMethod = "HS512"
+	vendor.Keys = map[string]string{
+		topics.DriverIss: base64.StdEncoding.EncodeToString(legacyKey),
+	}
+	// the vendor only verifies the drivers, but its topics still have the passenger accesses.
+	delete(vendor.IssEntityMap, topics.PassengerIss)
+	vendor.ExtraIssuers = []string{topics.PassengerIss}
+	vendor.VerificationKeys = map[string][]config.VerificationKey{
+		topics.DriverIss: {
+			{Kid: "new", Key: base64.StdEncoding.EncodeToString(newKey)},
+			{Kid: "old", Key: base64.StdEncoding.EncodeToString(oldKey)},
+		},
+	}
+
+	registry := authenticator.NewKeyRegistry(prometheus.NewRegistry(), zap.NewNop())
+
+	// nolint: exhaustruct
+	auths, err := authenticator.Builder{
+		Vendors:     []config.Vendor{vendor},
+		Logger:      zap.NewNop(),
+		Tracer:      noop.NewTracerProvider().Tracer(""),
+		KeyRegistry: registry,
+	}.Authenticators()
+	require.NoError(err)
+
+	auth := auths[vendor.Company]
+
+	token := func(key []byte, kid string) string {
+		token, err := testutil.Token(jwt.SigningMethodHS512, key, testutil.Claims{
+			Issuer:       topics.DriverIss,
+			Subject:      testutil.DefaultSubject,
+			ExpiresIn:    0,
+			NoExpiration: false,
+			Extra:        nil,
+			Kid:          kid,
+		})
+		require.NoError(err)
+
+		return token
+	}
+
+	require.NoError(auth.Auth(context.Background(), token(newKey, "new")))
+	require.NoError(auth.Auth(context.Background(), token(oldKey, "old")))
+
+	// tokens without kid or with unknown kid fall back through the keys in order.
+	require.NoError(auth.Auth(context.Background(), token(oldKey, "")))
+	require.NoError(auth.Auth(context.Background(), token(newKey, "unknown")))
+	require.NoError(auth.Auth(context.Background(), token(legacyKey, "")))
+
+	// kid which doesn't match the signing key is rejected.
+	require.ErrorIs(auth.Auth(context.Background(), token(oldKey, "new")), jwt.ErrTokenSignatureInvalid)
+	require.ErrorIs(auth.Auth(context.Background(), token([]byte("other"), "")), jwt.ErrTokenSignatureInvalid)
+
+	kids := make([]string, 0)
+
+	for _, info := range registry.List() {
+		kids = append(kids, info.Kid)
+	}
+
+	require.Equal([]string{"", "new", "old"}, kids)
+}
+
+func TestInvalidVerificationKeys(t *testing.T) {
+	t.Parallel()
+
+	key := base64.StdEncoding.EncodeToString([]byte("secret"))
+
+	cases := []struct {
+		name string
+		keys []config.VerificationKey
+	}{
+		{
+			name: "missing kid",
+			keys: []config.VerificationKey{{Kid: "new", Key: key}, {Kid: "", Key: key}},
+		},
+		{
+			name: "duplicate kid",
+			keys: []config.VerificationKey{{Kid: "new", Key: key}, {Kid: "new", Key: key}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			vendor := config.SnappVendor()
+			vendor.Jwt.SigningMethod = "HS512"
+			vendor.Keys = map[string]string{topics.DriverIss: key, topics.PassengerIss: key}
+			vendor.VerificationKeys = map[string][]config.VerificationKey{topics.DriverIss: c.keys}
+
+			// nolint: exhaustruct
+			_, err := authenticator.Builder{
+				Vendors: []config.Vendor{vendor},
+				Logger:  zap.NewNop(),
+				Tracer:  noop.NewTracerProvider().Tracer(""),
+			}.Authenticators()
+			require.ErrorIs(t, err, authenticator.ErrInvalidVerificationKey)
+		})
+	}
+}
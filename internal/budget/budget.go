@@ -0,0 +1,147 @@
+// Package budget answers the broker with a default decision when a request
+// cannot be answered in its latency budget, so broker doesn't time out and retry.
+package budget
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	serrors "github.com/snapp-incubator/soteria/internal/errors"
+)
+
+const (
+	DecisionAllow = "allow"
+	DecisionDeny  = "deny"
+)
+
+const (
+	StageStart         = "start"
+	StageParseToken    = "parse_token"
+	StageValidator     = "validator"
+	StageParseTopic    = "parse_topic"
+	StageRemoteAccess  = "remote_access"
+	StageStateCheck    = "state_check"
+	StagePostAuthorize = "post_authorize"
+)
+
+type ExceededError = serrors.BudgetExceededError
+
+type Config struct {
+	Auth Endpoint `json:"auth,omitempty" koanf:"auth"`
+	ACL  Endpoint `json:"acl,omitempty"  koanf:"acl"`
+}
+
+type Endpoint struct {
+	// Deadline is the latency budget of the endpoint, zero disables the budget.
+	Deadline time.Duration `json:"deadline,omitempty" koanf:"deadline"`
+	// Default is the decision when budget is exceeded, it is deny when it is not allow.
+	Default string `json:"default,omitempty" koanf:"default"`
+	// AllowedTopicTypes are the low-risk topic types which are allowed when budget is exceeded.
+	AllowedTopicTypes []string `json:"allowed_topic_types,omitempty" koanf:"allowed_topic_types"`
+}
+
+// Decision returns the decision of an exceeded request, topic type is empty when it is not known.
+func (e Endpoint) Decision(topicType string) string {
+	if topicType != "" && slices.Contains(e.AllowedTopicTypes, topicType) {
+		return DecisionAllow
+	}
+
+	if e.Default == DecisionAllow {
+		return DecisionAllow
+	}
+
+	return DecisionDeny
+}
+
+type tracker struct {
+	lock      sync.Mutex
+	stage     string
+	topicType string
+	deadline  time.Duration
+}
+
+type trackerKey struct{}
+
+// Start starts the budget of a request, the calls which are run using the returned context
+// share the deadline. Zero deadline disables the budget, but the stage and topic type of request
+// are still tracked.
+func Start(ctx context.Context, deadline time.Duration) (context.Context, context.CancelFunc) {
+	t := &tracker{
+		lock:      sync.Mutex{},
+		stage:     StageStart,
+		topicType: "",
+		deadline:  deadline,
+	}
+
+	ctx = context.WithValue(ctx, trackerKey{}, t)
+
+	if deadline <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, deadline)
+}
+
+// Run calls fn and returns ExceededError when the budget is exceeded before fn returns.
+// fn must stop its work when the context is canceled.
+func Run(ctx context.Context, fn func(context.Context) error) error {
+	t, ok := ctx.Value(trackerKey{}).(*tracker)
+	if !ok || t.deadline <= 0 {
+		return fn(ctx)
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- fn(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		t.lock.Lock()
+		defer t.lock.Unlock()
+
+		return ExceededError{
+			Stage:     t.stage,
+			TopicType: t.topicType,
+			Deadline:  t.deadline,
+		}
+	}
+}
+
+// SetStage records the current stage of the request, it does nothing when request has no budget.
+func SetStage(ctx context.Context, stage string) {
+	if t, ok := ctx.Value(trackerKey{}).(*tracker); ok {
+		t.lock.Lock()
+		defer t.lock.Unlock()
+
+		t.stage = stage
+	}
+}
+
+// SetTopicType records the topic type of the request for choosing the default decision.
+func SetTopicType(ctx context.Context, topicType string) {
+	if t, ok := ctx.Value(trackerKey{}).(*tracker); ok {
+		t.lock.Lock()
+		defer t.lock.Unlock()
+
+		t.topicType = topicType
+	}
+}
+
+// TopicType returns the topic type of the request, it is empty when topic is not matched yet.
+func TopicType(ctx context.Context) string {
+	t, ok := ctx.Value(trackerKey{}).(*tracker)
+	if !ok {
+		return ""
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.topicType
+}
@@ -0,0 +1,117 @@
+package budget_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/snapp-incubator/soteria/internal/budget"
+	"github.com/stretchr/testify/require"
+)
+
+var errSample = errors.New("sample error")
+
+func TestRunWithoutBudget(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := budget.Start(context.Background(), 0)
+	defer cancel()
+
+	require.ErrorIs(t, budget.Run(ctx, func(context.Context) error {
+		return errSample
+	}), errSample)
+}
+
+func TestRunInBudget(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := budget.Start(context.Background(), time.Second)
+	defer cancel()
+
+	require.NoError(t, budget.Run(ctx, func(context.Context) error {
+		return nil
+	}))
+
+	require.ErrorIs(t, budget.Run(ctx, func(context.Context) error {
+		return errSample
+	}), errSample)
+}
+
+func TestRunExceeded(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	ctx, cancel := budget.Start(context.Background(), 10*time.Millisecond)
+
+	canceled := make(chan struct{})
+
+	err := budget.Run(ctx, func(ctx context.Context) error {
+		budget.SetStage(ctx, budget.StagePostAuthorize)
+		budget.SetTopicType(ctx, "chat")
+
+		<-ctx.Done()
+		close(canceled)
+
+		return ctx.Err()
+	})
+
+	cancel()
+
+	var exceeded budget.ExceededError
+
+	require.ErrorAs(err, &exceeded)
+	require.Equal(budget.StagePostAuthorize, exceeded.Stage)
+	require.Equal("chat", exceeded.TopicType)
+	require.Equal(10*time.Millisecond, exceeded.Deadline)
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		require.Fail("abandoned work is not canceled")
+	}
+}
+
+func TestTopicTypeWithoutBudget(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	ctx, cancel := budget.Start(context.Background(), 0)
+	defer cancel()
+
+	require.Empty(budget.TopicType(ctx))
+
+	require.NoError(budget.Run(ctx, func(ctx context.Context) error {
+		budget.SetTopicType(ctx, "chat")
+
+		return nil
+	}))
+
+	require.Equal("chat", budget.TopicType(ctx))
+	require.Empty(budget.TopicType(context.Background()))
+}
+
+func TestDecision(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	// nolint: exhaustruct
+	require.Equal(budget.DecisionDeny, budget.Endpoint{}.Decision(""))
+
+	endpoint := budget.Endpoint{
+		Deadline:          time.Second,
+		Default:           budget.DecisionDeny,
+		AllowedTopicTypes: []string{"driver_location"},
+	}
+
+	require.Equal(budget.DecisionAllow, endpoint.Decision("driver_location"))
+	require.Equal(budget.DecisionDeny, endpoint.Decision("chat"))
+	require.Equal(budget.DecisionDeny, endpoint.Decision(""))
+
+	endpoint.Default = budget.DecisionAllow
+
+	require.Equal(budget.DecisionAllow, endpoint.Decision(""))
+}
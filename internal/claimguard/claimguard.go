@@ -0,0 +1,201 @@
+// Package claimguard samples the claim names of parsed tokens for each vendor, so a vendor which renames
+// its issuer or subject claim is detected by an error which names the configured and the observed claims,
+// instead of every token being denied without a hint. It is only observational and never changes decisions.
+package claimguard
+
+import (
+	"cmp"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/metric"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultSampleRate = 0.01
+	DefaultWindow     = 5 * time.Minute
+	DefaultMaxClaims  = 32
+
+	// maxNameLength bounds the claim names, claims of unverified tokens are sampled too.
+	maxNameLength = 64
+)
+
+type Config struct {
+	// SampleRate is the fraction of parsed tokens which are sampled.
+	SampleRate float64 `json:"sample_rate,omitempty" koanf:"sample_rate"`
+	// Threshold is the ratio of sampled tokens without the configured claim which logs an error,
+	// zero disables the errors.
+	Threshold float64       `json:"threshold,omitempty" koanf:"threshold"`
+	Window    time.Duration `json:"window,omitempty"    koanf:"window"`
+	// MinSamples is the number of sampled tokens in the window which is required before logging.
+	MinSamples int64 `json:"min_samples,omitempty" koanf:"min_samples"`
+	// WarnInterval is the minimum interval between the errors of each vendor.
+	WarnInterval time.Duration `json:"warn_interval,omitempty" koanf:"warn_interval"`
+	// MaxClaims bounds the distinct claim names which are counted in each window of vendor.
+	MaxClaims int `json:"max_claims,omitempty" koanf:"max_claims"`
+}
+
+// Claims are the configured issuer and subject claim names of a vendor.
+type Claims struct {
+	IssName string
+	SubName string
+}
+
+// window counts the sampled tokens of a vendor in a tumbling window, it is reset when the window ends.
+type window struct {
+	lock       sync.Mutex
+	epoch      int64
+	samples    int64
+	missingIss int64
+	missingSub int64
+	observed   map[string]int64
+	lastWarn   atomic.Int64
+}
+
+// Guard keeps the windows of vendors, it is safe for concurrent use and nil guard doesn't sample anything.
+type Guard struct {
+	cfg     Config
+	windows sync.Map
+	metrics *metric.ClaimGuardMetrics
+	logger  *zap.Logger
+}
+
+func New(cfg Config, logger *zap.Logger) *Guard {
+	if cfg.SampleRate <= 0 || cfg.SampleRate > 1 {
+		cfg.SampleRate = DefaultSampleRate
+	}
+
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultWindow
+	}
+
+	if cfg.MaxClaims <= 0 {
+		cfg.MaxClaims = DefaultMaxClaims
+	}
+
+	return &Guard{
+		cfg:     cfg,
+		windows: sync.Map{},
+		metrics: metric.NewClaimGuardMetrics(prometheus.DefaultRegisterer),
+		logger:  logger,
+	}
+}
+
+// Record samples the claim names of a parsed token of vendor, configured are its claim names.
+func (g *Guard) Record(vendor string, configured Claims, claims map[string]any) {
+	if g == nil || rand.Float64() >= g.cfg.SampleRate { // nolint: gosec
+		return
+	}
+
+	now := time.Now().UnixNano()
+
+	w := g.window(vendor)
+
+	w.lock.Lock()
+
+	if epoch := now / int64(g.cfg.Window); epoch != w.epoch {
+		w.epoch = epoch
+		w.samples, w.missingIss, w.missingSub = 0, 0, 0
+		clear(w.observed)
+	}
+
+	w.samples++
+
+	if _, ok := claims[configured.IssName]; !ok {
+		w.missingIss++
+	}
+
+	if _, ok := claims[configured.SubName]; !ok {
+		w.missingSub++
+	}
+
+	for name := range claims {
+		name = name[:min(len(name), maxNameLength)]
+
+		if _, ok := w.observed[name]; ok || len(w.observed) < g.cfg.MaxClaims {
+			w.observed[name]++
+		}
+	}
+
+	samples, missingIss, missingSub := w.samples, w.missingIss, w.missingSub
+
+	w.lock.Unlock()
+
+	issRatio := float64(missingIss) / float64(samples)
+	subRatio := float64(missingSub) / float64(samples)
+
+	g.metrics.Missing(vendor, "iss", issRatio)
+	g.metrics.Missing(vendor, "sub", subRatio)
+
+	if g.cfg.Threshold <= 0 || samples < g.cfg.MinSamples ||
+		(issRatio < g.cfg.Threshold && subRatio < g.cfg.Threshold) {
+		return
+	}
+
+	if last := w.lastWarn.Load(); time.Since(time.Unix(0, last)) < g.cfg.WarnInterval ||
+		!w.lastWarn.CompareAndSwap(last, now) {
+		return
+	}
+
+	g.logger.Error("configured claims are absent from the sampled tokens, iss_name or sub_name of vendor may be stale",
+		zap.String("vendor", vendor),
+		zap.String("iss-name", configured.IssName),
+		zap.String("sub-name", configured.SubName),
+		zap.Float64("missing-iss-ratio", issRatio),
+		zap.Float64("missing-sub-ratio", subRatio),
+		zap.Float64("threshold", g.cfg.Threshold),
+		zap.Int64("samples", samples),
+		zap.Strings("observed-claims", g.Observed(vendor)),
+	)
+}
+
+// Observed returns the claim names of the sampled tokens of vendor in the current window,
+// the most frequent claim names are first.
+func (g *Guard) Observed(vendor string) []string {
+	if g == nil {
+		return nil
+	}
+
+	v, ok := g.windows.Load(vendor)
+	if !ok {
+		return nil
+	}
+
+	w := v.(*window) //nolint: forcetypeassert
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	names := slices.Collect(maps.Keys(w.observed))
+
+	slices.SortFunc(names, func(a, b string) int {
+		if c := cmp.Compare(w.observed[b], w.observed[a]); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a, b)
+	})
+
+	return names
+}
+
+func (g *Guard) window(vendor string) *window {
+	if v, ok := g.windows.Load(vendor); ok {
+		return v.(*window) //nolint: forcetypeassert
+	}
+
+	// nolint: exhaustruct
+	w := &window{
+		observed: make(map[string]int64),
+	}
+
+	v, _ := g.windows.LoadOrStore(vendor, w)
+
+	return v.(*window) //nolint: forcetypeassert
+}
@@ -0,0 +1,85 @@
+package claimguard_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snapp-incubator/soteria/internal/claimguard"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// nolint: funlen
+func TestRecord(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	core, logs := observer.New(zapcore.ErrorLevel)
+
+	guard := claimguard.New(claimguard.Config{
+		SampleRate:   1,
+		Threshold:    0.9,
+		Window:       time.Minute,
+		MinSamples:   10,
+		WarnInterval: time.Minute,
+		MaxClaims:    4,
+	}, zap.New(core))
+
+	configured := claimguard.Claims{IssName: "iss", SubName: "sub"}
+
+	for range 20 {
+		guard.Record("snapp", configured, map[string]any{"iss": 0, "sub": "DXKgaNQa7N5Y7bo", "exp": 0})
+	}
+
+	require.Zero(logs.Len())
+
+	// vendor renamed its sub claim to subject.
+	for range 200 {
+		guard.Record("snapp", configured, map[string]any{"iss": 0, "subject": "DXKgaNQa7N5Y7bo", "exp": 0})
+	}
+
+	require.Equal(1, logs.Len(), "errors are rate limited")
+
+	entry := logs.All()[0].ContextMap()
+	require.Equal("snapp", entry["vendor"])
+	require.Equal("sub", entry["sub-name"])
+	require.Equal([]any{"exp", "iss", "subject", "sub"}, entry["observed-claims"])
+
+	// claim names are bounded.
+	for i := range 100 {
+		guard.Record("snapp", configured, map[string]any{"claim-" + strconv.Itoa(i): true})
+	}
+
+	require.Len(guard.Observed("snapp"), 4)
+
+	guard.Record("other", configured, map[string]any{strings.Repeat("c", 1024): true})
+	require.Len(guard.Observed("other")[0], 64)
+
+	var nilGuard *claimguard.Guard
+
+	nilGuard.Record("snapp", configured, nil)
+	require.Nil(nilGuard.Observed("snapp"))
+}
+
+func TestRecordDisabled(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	core, logs := observer.New(zapcore.ErrorLevel)
+
+	// nolint: exhaustruct
+	guard := claimguard.New(claimguard.Config{SampleRate: 1}, zap.New(core))
+
+	for range 100 {
+		guard.Record("snapp", claimguard.Claims{IssName: "iss", SubName: "sub"}, map[string]any{"subject": ""})
+	}
+
+	require.Zero(logs.Len())
+	require.Equal([]string{"subject"}, guard.Observed("snapp"))
+}
@@ -0,0 +1,126 @@
+package keys
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/keygen"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+const (
+	// PrivateKeyPerm is readable only by the owner because private keys sign the tokens of vendor.
+	PrivateKeyPerm = 0o600
+	PublicKeyPerm  = 0o644
+)
+
+type Keys struct {
+	Logger *zap.Logger
+}
+
+type generateOptions struct {
+	keygen.Options
+
+	out     string
+	name    string
+	issuer  string
+	snippet bool
+}
+
+// generate writes a new key pair and prints its fingerprint, existing files are never overwritten.
+func (k Keys) generate(cmd *cobra.Command, opts generateOptions) error {
+	pair, err := keygen.Generate(opts.Options)
+	if err != nil {
+		return fmt.Errorf("cannot generate keys %w", err)
+	}
+
+	name := opts.name
+	if name == "" {
+		name = "soteria"
+		if opts.Kid != "" {
+			name = opts.Kid
+		}
+	}
+
+	private := filepath.Join(opts.out, name+".pem")
+	public := filepath.Join(opts.out, name+".pub.pem")
+
+	if err := write(private, pair.PrivateKey, PrivateKeyPerm); err != nil {
+		return err
+	}
+
+	if err := write(public, pair.PublicKey, PublicKeyPerm); err != nil {
+		return err
+	}
+
+	k.Logger.Info("keys generated", zap.String("private", private), zap.String("public", public))
+
+	out := cmd.OutOrStdout()
+
+	_, _ = fmt.Fprintf(out, "private key: %s\npublic key: %s\nsigning method: %s\nfingerprint: %s\n",
+		private, public, pair.SigningMethod, authenticator.Fingerprint(pair.Public))
+
+	if opts.snippet {
+		_, _ = fmt.Fprintf(out, "\n%s", keygen.Snippet(pair, opts.issuer, opts.Kid))
+	}
+
+	return nil
+}
+
+func write(path string, data []byte, perm os.FileMode) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return fmt.Errorf("cannot create %s %w", path, err)
+	}
+
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+
+		return fmt.Errorf("cannot write %s %w", path, err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("cannot write %s %w", path, err)
+	}
+
+	return nil
+}
+
+// Register keys generate command.
+func (k Keys) Register(root *cobra.Command) {
+	//nolint: exhaustruct
+	keys := &cobra.Command{
+		Use:   "keys",
+		Short: "keys manages the keys of vendors",
+	}
+
+	var opts generateOptions
+
+	//nolint: exhaustruct
+	generate := &cobra.Command{
+		Use:   "generate",
+		Short: "generate creates a key pair for a vendor",
+		Long: `generate writes a private key (<name>.pem) and its public key (<name>.pub.pem) in the formats which
+are read by soteria, prints the public key fingerprint and optionally the vendor configuration snippet.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return k.generate(cmd, opts)
+		},
+	}
+
+	generate.Flags().StringVarP(&opts.Algorithm, "algorithm", "a", keygen.RSA, "key algorithm (rsa, ec or ed25519)")
+	generate.Flags().IntVar(&opts.Bits, "bits", 0,
+		fmt.Sprintf("rsa key size (default %d) or ec curve size (256, 384 or 521)", keygen.DefaultRSABits))
+	generate.Flags().StringVar(&opts.Kid, "kid", "", "key id which is added to the keys for rollover")
+	generate.Flags().StringVarP(&opts.out, "out", "o", ".", "directory of the key files")
+	generate.Flags().StringVar(&opts.name, "name", "", "name of the key files (default kid or soteria)")
+	generate.Flags().StringVar(&opts.issuer, "issuer", "0", "issuer of the key in the configuration snippet")
+	generate.Flags().BoolVar(&opts.snippet, "snippet", false, "print the vendor configuration snippet")
+
+	keys.AddCommand(generate)
+
+	root.AddCommand(keys)
+}
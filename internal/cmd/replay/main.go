@@ -0,0 +1,126 @@
+package replay
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/flags"
+	"github.com/snapp-incubator/soteria/internal/replay"
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// DefaultMismatches is the number of printed mismatches.
+const DefaultMismatches = 10
+
+type Replay struct {
+	Logger *zap.Logger
+	Tracer trace.Tracer
+}
+
+type options struct {
+	input      string
+	config     string
+	mismatches int
+}
+
+// main replays the captured decisions against the candidate configuration and prints
+// the summary with the first mismatches.
+func (r Replay) main(cmd *cobra.Command, opts options) error {
+	cfg, err := config.Load(opts.config)
+	if err != nil {
+		return fmt.Errorf("candidate configuration cannot be loaded %w", err)
+	}
+
+	// flags of the candidate configuration change the decisions too, e.g. the normalization of topics.
+	features := flags.New(r.Logger.Named("flags"))
+	features.Load(cfg.Features, cfg.VendorFeatures())
+
+	auths, err := authenticator.Builder{
+		Vendors:              cfg.Vendors,
+		Logger:               r.Logger,
+		ValidatorConfig:      cfg.Validator,
+		Tracer:               r.Tracer,
+		KeyRegistry:          nil,
+		Flags:                features,
+		FailureRatio:         nil,
+		ClaimGuard:           nil,
+		ValidatorRing:        nil,
+		StrictTopicShadowing: false,
+		Background:           nil,
+	}.Authenticators()
+	if err != nil {
+		return fmt.Errorf("authenticator building failed %w", err)
+	}
+
+	input, err := os.Open(opts.input)
+	if err != nil {
+		return fmt.Errorf("cannot open captured decisions %w", err)
+	}
+	defer input.Close()
+
+	summary, err := replay.Replay(input, func(record replay.Record) error {
+		auth, ok := auths[record.Company].(authenticator.ReplayAuthenticator)
+		if !ok {
+			return fmt.Errorf("%w: vendor %s", replay.ErrNotReplayable, record.Company)
+		}
+
+		return auth.Decide(record.Access, record.Topic, authenticator.Subject{
+			Issuer:    record.Issuer,
+			Sub:       record.SubHash,
+			Qualifier: record.Qualifier,
+			ID:        "",
+		})
+	}, opts.mismatches)
+	if err != nil {
+		return fmt.Errorf("replay failed %w", err)
+	}
+
+	out := cmd.OutOrStdout()
+
+	_, _ = fmt.Fprintf(out, "total: %d, matched: %d, mismatched: %d, skipped: %d\n",
+		summary.Total, summary.Matched, summary.Mismatched, summary.Skipped)
+
+	for _, m := range summary.Mismatches {
+		_, _ = fmt.Fprintf(out, "%s %s %s of %s issuer %s: %s -> %s\n",
+			m.Record.Company, m.Record.Access, m.Record.Topic, m.Record.SubHash, m.Record.Issuer,
+			outcome(m.Record.Decision, m.Record.Reason), outcome(m.Decision, m.Reason))
+	}
+
+	return nil
+}
+
+// outcome formats the decision with its reason.
+func outcome(decision, reason string) string {
+	if reason == "" {
+		return decision
+	}
+
+	return decision + " (" + reason + ")"
+}
+
+// Register replay command.
+func (r Replay) Register(root *cobra.Command) {
+	var opts options
+
+	//nolint: exhaustruct
+	cmd := &cobra.Command{
+		Use:   "replay",
+		Short: "replay checks the captured decisions against a configuration",
+		Long: `replay re-evaluates the decisions which are captured by the capture of serve with the candidate
+configuration in the topic layer and prints the summary and the first mismatches.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return r.main(cmd, opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.input, "input", "i", "decisions.jsonl", "captured decisions in JSONL")
+	cmd.Flags().StringVarP(&opts.config, "config", "c", "config.yml", "candidate configuration")
+	cmd.Flags().IntVarP(&opts.mismatches, "mismatches", "n", DefaultMismatches, "number of printed mismatches")
+
+	root.AddCommand(cmd)
+}
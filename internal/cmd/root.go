@@ -1,9 +1,16 @@
 package cmd
 
 import (
+	"context"
 	"os"
 
+	"github.com/snapp-incubator/soteria/internal/cmd/keys"
+	"github.com/snapp-incubator/soteria/internal/cmd/replay"
 	"github.com/snapp-incubator/soteria/internal/cmd/serve"
+	"github.com/snapp-incubator/soteria/internal/cmd/token"
+	"github.com/snapp-incubator/soteria/internal/cmd/topics"
+	"github.com/snapp-incubator/soteria/internal/cmd/validate"
+	"github.com/snapp-incubator/soteria/internal/cmd/version"
 	"github.com/snapp-incubator/soteria/internal/config"
 	"github.com/snapp-incubator/soteria/internal/logger"
 	"github.com/snapp-incubator/soteria/internal/profiler"
@@ -18,13 +25,18 @@ const ExitFailure = 1
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	cfg := config.New()
+	// configuration is loaded using the default logger because the configured one is not available yet.
+	cfg := config.New(logger.New(config.Default().Logger).Named("config"))
 
 	logger := logger.New(cfg.Logger).Named("root")
 
-	tracer := tracing.New(cfg.Tracer, logger.Named("tracer"))
+	// secrets of configuration are redacted, because logs are shipped to shared storage.
+	logger.Info("loaded configuration", zap.Any("config", cfg.Redacted()))
 
-	profiler.Start(cfg.Profiler)
+	telemetry := tracing.New(cfg.Tracer, logger.Named("tracer"))
+	tracer := telemetry.Tracer
+
+	profiler.Start(cfg.Profiler, logger.Named("profiler"))
 
 	//nolint: exhaustruct
 	root := &cobra.Command{
@@ -42,7 +54,43 @@ func Execute() {
 		Tracer: tracer,
 	}.Register(root)
 
-	if err := root.Execute(); err != nil {
+	validate.Validate{
+		Cfg:    cfg,
+		Logger: logger.Named("validate"),
+		Tracer: tracer,
+	}.Register(root)
+
+	token.Token{
+		Cfg:    cfg,
+		Logger: logger.Named("token"),
+		Tracer: tracer,
+	}.Register(root)
+
+	keys.Keys{
+		Logger: logger.Named("keys"),
+	}.Register(root)
+
+	replay.Replay{
+		Logger: logger.Named("replay"),
+		Tracer: tracer,
+	}.Register(root)
+
+	topics.TopicsServer{
+		Cfg:    cfg,
+		Logger: logger.Named("topics-server"),
+		Tracer: tracer,
+	}.Register(root)
+
+	version.Version{}.Register(root)
+
+	err := root.Execute()
+
+	// flush the spans and metrics before exit.
+	if err := telemetry.Shutdown(context.Background()); err != nil {
+		logger.Error("failed to shutdown telemetry", zap.Error(err))
+	}
+
+	if err != nil {
 		logger.Error("failed to execute root command", zap.Error(err))
 
 		os.Exit(ExitFailure)
@@ -1,17 +1,32 @@
 package serve
 
 import (
+	"context"
 	"errors"
-	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/snapp-incubator/soteria/internal/api"
+	"github.com/snapp-incubator/soteria/internal/audit"
 	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/claimguard"
 	"github.com/snapp-incubator/soteria/internal/clientid"
 	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/failratio"
+	"github.com/snapp-incubator/soteria/internal/flags"
+	"github.com/snapp-incubator/soteria/internal/hashring"
+	"github.com/snapp-incubator/soteria/internal/invalidtopic"
+	"github.com/snapp-incubator/soteria/internal/limiter"
+	"github.com/snapp-incubator/soteria/internal/listener"
 	"github.com/snapp-incubator/soteria/internal/metric"
+	"github.com/snapp-incubator/soteria/internal/replay"
+	"github.com/snapp-incubator/soteria/internal/session"
+	"github.com/snapp-incubator/soteria/internal/version"
+	"github.com/snapp-incubator/soteria/internal/worker"
+	"github.com/snapp-incubator/soteria/pkg/authorizer"
 	"github.com/spf13/cobra"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
@@ -24,44 +39,257 @@ type Serve struct {
 }
 
 func (s Serve) main() {
-	auth, err := authenticator.Builder{
-		Vendors:         s.Cfg.Vendors,
-		Logger:          s.Logger,
-		ValidatorConfig: s.Cfg.Validator,
-		Tracer:          s.Tracer,
-	}.Authenticators()
+	info := version.Get()
+	metric.NewBuildMetrics(prometheus.DefaultRegisterer).Info(info.Version, info.Commit)
+
+	s.Logger.Info("starting soteria",
+		zap.String("version", info.Version),
+		zap.String("commit", info.Commit),
+		zap.String("build-date", info.BuildDate),
+	)
+
+	keys := authenticator.NewKeyRegistry(prometheus.DefaultRegisterer, s.Logger.Named("keys"))
+
+	features := flags.New(s.Logger.Named("flags"))
+	loadFlags(features, s.Cfg)
+
+	background := worker.New(s.Cfg.Background, s.Logger.Named("background"))
+
+	ring := validatorRing(s.Cfg.Validator, s.Logger.Named("validator-ring"))
+
+	authorizers, err := authorizer.Vendors(authenticator.Builder{
+		Vendors:              s.Cfg.Vendors,
+		Logger:               s.Logger,
+		ValidatorConfig:      s.Cfg.Validator,
+		Tracer:               s.Tracer,
+		KeyRegistry:          keys,
+		Flags:                features,
+		FailureRatio:         failratio.New(s.Cfg.FailureRatio, s.Logger.Named("failure-ratio")),
+		ClaimGuard:           claimguard.New(s.Cfg.ClaimGuard, s.Logger.Named("claim-guard")),
+		StrictTopicShadowing: s.Cfg.StrictTopicShadowing,
+		ValidatorRing:        ring,
+		Background:           background,
+		Registerer:           prometheus.DefaultRegisterer,
+	})
+	if err != nil {
+		for _, err := range errorList(err) {
+			s.Logger.Error("invalid configuration", zap.Error(err))
+		}
+
+		s.Logger.Fatal("authenticator building failed", zap.Int("errors", len(errorList(err))))
+	}
+
+	auth := make(map[string]authenticator.Authenticator, len(authorizers))
+	for company, a := range authorizers {
+		auth[company] = a.Authenticator()
+	}
+
+	filters, err := api.NewIPFilters(s.Cfg.Vendors)
+	if err != nil {
+		s.Logger.Fatal("client address filters building failed", zap.Error(err))
+	}
+
+	sources, err := api.NewTokenSources(s.Cfg.Vendors)
+	if err != nil {
+		s.Logger.Fatal("token sources building failed", zap.Error(err))
+	}
+
+	anonymous, err := api.NewAnonymousPolicies(s.Cfg.Vendors)
 	if err != nil {
-		s.Logger.Fatal("authenticator building failed", zap.Error(err))
+		s.Logger.Fatal("anonymous policies building failed", zap.Error(err))
+	}
+
+	recorder, err := replay.New(s.Cfg.Capture)
+	if err != nil {
+		s.Logger.Fatal("decision capture failed", zap.Error(err))
+	}
+
+	watch, stopWatch := context.WithCancel(context.Background())
+	watchKeys(watch, auth, s.Logger)
+	watchPolicies(watch, auth, s.Logger)
+
+	if ring != nil {
+		ring.Start(watch)
 	}
 
 	api := api.API{
-		DefaultVendor:  s.Cfg.DefaultVendor,
-		Authenticators: auth,
-		Tracer:         s.Tracer,
-		Logger:         s.Logger.Named("api"),
-		Parser:         clientid.NewParser(s.Cfg.Parser),
-		Metrics:        metric.NewAPIMetrics(),
+		VendorResolution:    s.Cfg.Resolution(),
+		ParallelResolution:  s.Cfg.ParallelResolution,
+		Winners:             api.NewWinners(),
+		Authenticators:      auth,
+		Tracer:              s.Tracer,
+		Logger:              s.Logger.Named("api"),
+		Parser:              clientid.NewParser(s.Cfg.Parser),
+		Metrics:             metric.NewAPIMetrics(prometheus.DefaultRegisterer),
+		WillTopicPolicy:     s.Cfg.WillTopicPolicy,
+		Keys:                keys,
+		States:              api.NewVendorStates(),
+		Budget:              s.Cfg.Budget,
+		Flags:               features,
+		MaxTopicLength:      s.Cfg.MaxTopicLength,
+		MaxCredentialLength: s.Cfg.MaxCredentialLength,
+		Signers:             api.NewSigners(s.Cfg.Vendors),
+		IPFilters:           filters,
+		Limiters:            limiter.NewLimiters(s.Cfg.Limiter),
+		Mountpoints:         api.NewMountpoints(s.Cfg.Vendors),
+		TokenSources:        sources,
+		AnonymousPolicies:   anonymous,
+		Configs:             api.NewVendorConfigs(s.Cfg.Vendors),
+		Sessions:            session.New[api.SessionDecision](s.Cfg.SessionCache),
+		Recorder:            recorder,
+		Decisions:           audit.New(s.Cfg.RecentDecisions),
+		Maintenances:        api.NewMaintenances(),
+		TokenReuse:          api.NewTokenReuse(s.Cfg.Vendors, s.Logger.Named("reuse")),
+		CacheHints:          api.NewCacheHints(s.Cfg.Vendors),
+		MaxSessions:         api.NewMaxSessions(s.Cfg.Vendors),
+		LegacyRoutes:        !s.Cfg.DisableLegacyRoutes,
+		Readiness:           api.NewReadiness(auth, s.Logger.Named("readiness")),
+		InvalidTopics:       invalidtopic.New(s.Cfg.InvalidTopics, s.Logger.Named("invalid-topics")),
+		Server:              s.Cfg.Server,
 	}
 
-	if _, ok := api.Authenticators[s.Cfg.DefaultVendor]; !ok {
-		s.Logger.Fatal("default vendor shouldn't be nil, please set it")
+	if len(api.VendorResolution) == 0 {
+		s.Logger.Fatal("vendor resolution shouldn't be empty, please set it")
 	}
 
+	for _, vendor := range api.VendorResolution {
+		if _, ok := api.Authenticators[vendor]; !ok {
+			s.Logger.Fatal("vendor of resolution shouldn't be nil, please set it", zap.String("vendor", vendor))
+		}
+	}
+
+	api.Winners.Start(watch)
+	api.Sessions.Start(watch)
+
 	rest := api.ReSTServer()
 
+	ln, err := listener.Listen(context.Background(), listener.Config{
+		Host:      s.Cfg.HTTPHost,
+		Port:      s.Cfg.HTTPPort,
+		ReusePort: s.Cfg.ReusePort,
+	})
+	if err != nil {
+		s.Logger.Fatal("failed to listen for REST HTTP server", zap.Error(err))
+	}
+
+	s.Logger.Info("REST HTTP server is listening",
+		zap.String("address", ln.Addr().String()),
+		zap.Bool("reuse-port", s.Cfg.ReusePort),
+	)
+
 	go func() {
-		if err := rest.Listen(fmt.Sprintf(":%d", s.Cfg.HTTPPort)); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		if err := rest.Listener(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			s.Logger.Fatal("failed to run REST HTTP server", zap.Error(err))
 		}
 	}()
 
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+
+	go func() {
+		for range reload {
+			s.Logger.Info("reloading feature flags")
+
+			// invalid configurations keep the current flags instead of stopping the server.
+			cfg, err := config.LoadWithEnv(config.File)
+			if err != nil {
+				s.Logger.Error("feature flags are not reloaded", zap.Error(err))
+
+				continue
+			}
+
+			// only the flags are reloaded, so the served vendor configuration and its generation are kept.
+			loadFlags(features, cfg)
+			api.Readiness.Check(api.Authenticators)
+		}
+	}()
+
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt)
 	<-c
 
+	stopWatch()
+
 	if err := rest.Shutdown(); err != nil {
 		s.Logger.Error("error happened during REST API shutdown", zap.Error(err))
 	}
+
+	// background tasks of the served requests are drained after the server is stopped.
+	if err := background.Stop(); err != nil {
+		s.Logger.Error("error happened during background tasks shutdown", zap.Error(err))
+	}
+
+	if err := recorder.Close(); err != nil {
+		s.Logger.Error("error happened during decision capture shutdown", zap.Error(err))
+	}
+}
+
+// errorList returns the joined errors one by one.
+func errorList(err error) []error {
+	var joined interface{ Unwrap() []error }
+	if errors.As(err, &joined) {
+		return joined.Unwrap()
+	}
+
+	return []error{err}
+}
+
+// watchKeys reloads the keys of vendors which have a keys directory on the changes of its files,
+// independently of the configuration reloads.
+func watchKeys(ctx context.Context, auths map[string]authenticator.Authenticator, logger *zap.Logger) {
+	for company, auth := range auths {
+		reloadable, ok := auth.(authenticator.KeyReloadAuthenticator)
+		if !ok || reloadable.KeyReloader() == nil {
+			continue
+		}
+
+		reloader := reloadable.KeyReloader()
+
+		if err := reloader.Watch(ctx); err != nil {
+			logger.Fatal("keys directory watch failed", zap.String("vendor", company), zap.Error(err))
+		}
+
+		logger.Info("watching keys directory", zap.String("vendor", company), zap.String("dir", reloader.Dir()))
+	}
+}
+
+// watchPolicies compiles the policies of vendors, which should compile on startup,
+// and compiles them again on the changes of their modules.
+func watchPolicies(ctx context.Context, auths map[string]authenticator.Authenticator, logger *zap.Logger) {
+	for company, auth := range auths {
+		evaluated, ok := auth.(authenticator.PolicyAuthenticator)
+		if !ok || evaluated.AccessPolicy() == nil {
+			continue
+		}
+
+		p := evaluated.AccessPolicy()
+
+		if err := p.Load(ctx); err != nil {
+			logger.Fatal("policy loading failed", zap.String("vendor", company), zap.Error(err))
+		}
+
+		if err := p.Watch(ctx); err != nil {
+			logger.Fatal("policy watch failed", zap.String("vendor", company), zap.Error(err))
+		}
+
+		logger.Info("watching policy modules", zap.String("vendor", company), zap.Strings("modules", p.Modules()))
+	}
+}
+
+// validatorRing creates the ring of validator endpoints, it is nil when validator has no urls.
+func validatorRing(cfg config.Validator, logger *zap.Logger) *hashring.Ring {
+	if len(cfg.URLs) == 0 {
+		return nil
+	}
+
+	logger.Info("validator endpoints are chosen by consistent hashing", zap.Strings("urls", cfg.URLs))
+
+	return hashring.New(cfg.URLs, cfg.Timeout, cfg.HealthCheck, logger)
+}
+
+// loadFlags loads the global and vendors feature flags from configuration.
+func loadFlags(features *flags.Flags, cfg config.Config) {
+	features.Load(cfg.Features, cfg.VendorFeatures())
 }
 
 // Register serve command.
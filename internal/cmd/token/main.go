@@ -0,0 +1,97 @@
+package token
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/snapp-incubator/soteria/internal/api"
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type Token struct {
+	Cfg    config.Config
+	Logger *zap.Logger
+	Tracer trace.Tracer
+}
+
+// permissions prints the allowed topics of the given token which can be prefixed by its vendor.
+func (t Token) permissions(cmd *cobra.Command, raw string) error {
+	auths, err := authenticator.Builder{
+		Vendors:         t.Cfg.Vendors,
+		Logger:          t.Logger,
+		ValidatorConfig: t.Cfg.Validator,
+		Tracer:          t.Tracer,
+		KeyRegistry:     nil,
+		Flags:           nil,
+	}.Authenticators()
+	if err != nil {
+		return fmt.Errorf("authenticator building failed %w", err)
+	}
+
+	// nolint: exhaustruct
+	a := api.API{
+		Authenticators:   auths,
+		VendorResolution: t.Cfg.Resolution(),
+	}
+
+	vendor, token := api.ExtractVendorToken(raw, "", "")
+
+	auth, err := a.Authenticator(vendor, token)
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, vendor)
+	}
+
+	if auth == nil {
+		return fmt.Errorf("%w: %s", authenticator.ErrInvalidAuthenticator, vendor)
+	}
+
+	permAuth, ok := auth.(authenticator.PermissionsAuthenticator)
+	if !ok {
+		return fmt.Errorf("%w: vendor %s cannot list permissions", authenticator.ErrInvalidAuthenticator, auth.GetCompany())
+	}
+
+	permissions, err := permAuth.Permissions(cmd.Context(), token)
+	if err != nil {
+		return fmt.Errorf("cannot list permissions %w", err)
+	}
+
+	out, err := json.MarshalIndent(api.DebugPermissionsResponse{
+		Vendor:      auth.GetCompany(),
+		Permissions: permissions,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot marshal permissions %w", err)
+	}
+
+	_, _ = fmt.Fprintln(cmd.OutOrStdout(), string(out))
+
+	return nil
+}
+
+// Register token permissions command.
+func (t Token) Register(root *cobra.Command) {
+	//nolint: exhaustruct
+	token := &cobra.Command{
+		Use:   "token",
+		Short: "token inspects the tokens",
+	}
+
+	token.AddCommand(
+		//nolint: exhaustruct
+		&cobra.Command{
+			Use:   "permissions <token>",
+			Short: "permissions lists the allowed topics of token",
+			Long:  `permissions renders the topics of token vendor same as ACL and prints the allowed ones with their accesses.`,
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return t.permissions(cmd, args[0])
+			},
+		},
+	)
+
+	root.AddCommand(token)
+}
@@ -0,0 +1,121 @@
+package topics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/flags"
+	"github.com/snapp-incubator/soteria/internal/listener"
+	"github.com/snapp-incubator/soteria/internal/topicserver"
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// DefaultPort is the port of topics server, it is different from the port of serve
+// so both of them can run on the same host.
+const DefaultPort = 9998
+
+type TopicsServer struct {
+	Cfg    config.Config
+	Logger *zap.Logger
+	Tracer trace.Tracer
+}
+
+type options struct {
+	host     string
+	port     int
+	maxBulks int
+}
+
+// main serves the topic matching of vendors until user disrupts, vendors are built without
+// their keys and validators so only their topics are required.
+func (t TopicsServer) main(opts options) error {
+	features := flags.New(t.Logger.Named("flags"))
+	features.Load(t.Cfg.Features, t.Cfg.VendorFeatures())
+
+	deciders, err := authenticator.Builder{
+		Vendors:              t.Cfg.Vendors,
+		Logger:               t.Logger,
+		ValidatorConfig:      t.Cfg.Validator,
+		Tracer:               t.Tracer,
+		KeyRegistry:          nil,
+		Flags:                features,
+		FailureRatio:         nil,
+		ClaimGuard:           nil,
+		ValidatorRing:        nil,
+		StrictTopicShadowing: t.Cfg.StrictTopicShadowing,
+		Background:           nil,
+	}.Deciders()
+	if err != nil {
+		return fmt.Errorf("decider building failed %w", err)
+	}
+
+	defaultVendor := ""
+	if resolution := t.Cfg.Resolution(); len(resolution) > 0 {
+		defaultVendor = resolution[0]
+	}
+
+	// nolint: exhaustruct
+	server := topicserver.New(topicserver.Config{MaxBulks: opts.maxBulks}, deciders, defaultVendor, t.Logger.Named("server"))
+	app := server.App()
+
+	ln, err := listener.Listen(context.Background(), listener.Config{
+		Host:      opts.host,
+		Port:      opts.port,
+		ReusePort: false,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to listen for topics server %w", err)
+	}
+
+	t.Logger.Info("topics server is listening",
+		zap.String("address", ln.Addr().String()),
+		zap.Int("vendors", len(deciders)),
+	)
+
+	go func() {
+		if err := app.Listener(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			t.Logger.Fatal("failed to run topics server", zap.Error(err))
+		}
+	}()
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	<-c
+
+	if err := app.Shutdown(); err != nil {
+		return fmt.Errorf("error happened during topics server shutdown %w", err)
+	}
+
+	return nil
+}
+
+// Register topics-server command.
+func (t TopicsServer) Register(root *cobra.Command) {
+	var opts options
+
+	//nolint: exhaustruct
+	cmd := &cobra.Command{
+		Use:   "topics-server",
+		Short: "topics-server serves the topic matching of vendors",
+		Long: `topics-server serves the topic matching of vendors over HTTP without authentication, so topics
+can be validated offline in the topic layer. Requests are matched one by one on /match and as NDJSON on /bulk.`,
+		Args: cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return t.main(opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.host, "host", "", "interface which topics server binds to, empty means all interfaces")
+	cmd.Flags().IntVarP(&opts.port, "port", "p", DefaultPort, "port of topics server")
+	cmd.Flags().IntVar(&opts.maxBulks, "max-bulks", topicserver.DefaultMaxBulks, "number of concurrent bulk requests")
+
+	root.AddCommand(cmd)
+}
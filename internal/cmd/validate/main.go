@@ -0,0 +1,194 @@
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type Validate struct {
+	Cfg    config.Config
+	Logger *zap.Logger
+	Tracer trace.Tracer
+}
+
+// main builds the authenticators to validate the configuration and prints the warnings of configuration,
+// the topics of each vendor after expanding their topic sets and their access matrix.
+func (v Validate) main(cmd *cobra.Command) error {
+	if err := v.Cfg.ValidateTopics(); err != nil {
+		return fmt.Errorf("topics are not valid %w", err)
+	}
+
+	if err := v.Cfg.ValidateWillTopicPolicy(); err != nil {
+		return fmt.Errorf("configuration is not valid %w", err)
+	}
+
+	builder := authenticator.Builder{
+		Vendors:              v.Cfg.Vendors,
+		Logger:               v.Logger,
+		ValidatorConfig:      v.Cfg.Validator,
+		Tracer:               v.Tracer,
+		KeyRegistry:          nil,
+		StrictTopicShadowing: v.Cfg.StrictTopicShadowing,
+	}
+
+	if _, err := builder.Authenticators(); err != nil {
+		return fmt.Errorf("configuration is not valid %w", err)
+	}
+
+	out := cmd.OutOrStdout()
+
+	for _, warning := range builder.Warnings() {
+		_, _ = fmt.Fprintf(out, "warning: %s\n", warning)
+	}
+
+	for _, vendor := range v.Cfg.Vendors {
+		topics, err := json.MarshalIndent(vendor.Topics, "", "  ")
+		if err != nil {
+			return fmt.Errorf("cannot marshal topics of %s %w", vendor.Company, err)
+		}
+
+		_, _ = fmt.Fprintf(out, "%s (topic sets: %v):\n%s\n", vendor.Company, vendor.TopicSets, topics)
+
+		if err := accessMatrix(out, vendor); err != nil {
+			return fmt.Errorf("cannot print access matrix of %s %w", vendor.Company, err)
+		}
+	}
+
+	_, _ = fmt.Fprintln(out, "configuration is valid")
+
+	return nil
+}
+
+// accessMatrix prints the effective access of each issuer and qualified issuer of vendor on its topic types,
+// default accesses are applied, so the matrix is what ACL decides. Topics with remote accesses are marked remote.
+func accessMatrix(out io.Writer, vendor config.Vendor) error {
+	if len(vendor.Topics) == 0 {
+		return nil
+	}
+
+	type row struct {
+		iss       string
+		qualifier string
+	}
+
+	issuers := make(map[string]struct{})
+
+	for iss := range vendor.IssEntityMap {
+		if iss != topics.Default {
+			issuers[iss] = struct{}{}
+		}
+	}
+
+	for _, iss := range vendor.ExtraIssuers {
+		issuers[iss] = struct{}{}
+	}
+
+	rows := make([]row, 0, len(issuers))
+
+	for _, iss := range slices.Sorted(maps.Keys(issuers)) {
+		rows = append(rows, row{iss: iss, qualifier: ""})
+
+		qualifiers := make(map[string]struct{})
+
+		for _, topic := range vendor.Topics {
+			for key := range topic.Accesses {
+				if qualifier, ok := strings.CutPrefix(key, iss+topics.QualifierSeparator); ok {
+					qualifiers[qualifier] = struct{}{}
+				}
+			}
+		}
+
+		for _, qualifier := range slices.Sorted(maps.Keys(qualifiers)) {
+			rows = append(rows, row{iss: iss, qualifier: qualifier})
+		}
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0) //nolint: mnd
+
+	_, _ = fmt.Fprint(w, "ENTITY (ISS)")
+
+	for _, topic := range vendor.Topics {
+		_, _ = fmt.Fprintf(w, "\t%s", topic.Type)
+	}
+
+	_, _ = fmt.Fprintln(w)
+
+	for _, r := range rows {
+		key := r.iss
+		if r.qualifier != "" {
+			key = topics.QualifiedKey(r.iss, r.qualifier)
+		}
+
+		_, _ = fmt.Fprintf(w, "%s (%s)", vendor.IssEntityMap[r.iss], key)
+
+		for _, topic := range vendor.Topics {
+			// accesses of remote topics are decided by their service.
+			if topic.AccessesSource == topics.AccessesSourceRemote {
+				_, _ = fmt.Fprint(w, "\tremote")
+
+				continue
+			}
+
+			// nolint: exhaustruct
+			access := topics.Template{Accesses: topic.Accesses}.Access(r.iss, r.qualifier)
+
+			_, _ = fmt.Fprintf(w, "\t%s", accessName(access))
+		}
+
+		_, _ = fmt.Fprintln(w)
+	}
+
+	return w.Flush() //nolint: wrapcheck
+}
+
+func accessName(access acl.AccessType) string {
+	switch access { //nolint: exhaustive
+	case acl.Sub:
+		return "sub"
+	case acl.Pub:
+		return "pub"
+	case acl.PubSub:
+		return "pubsub"
+	case acl.Deny:
+		return "deny"
+	}
+
+	return "-"
+}
+
+// Register config validate command.
+func (v Validate) Register(root *cobra.Command) {
+	//nolint: exhaustruct
+	cfg := &cobra.Command{
+		Use:   "config",
+		Short: "config inspects the configuration",
+	}
+
+	cfg.AddCommand(
+		//nolint: exhaustruct
+		&cobra.Command{
+			Use:   "validate",
+			Short: "validate checks the configuration",
+			Long: `validate builds the authenticators from configuration, prints its warnings and prints topics of vendors
+after expanding their topic sets with their matrix of issuer accesses.`,
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				return v.main(cmd)
+			},
+		},
+	)
+
+	root.AddCommand(cfg)
+}
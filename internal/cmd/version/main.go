@@ -0,0 +1,34 @@
+package version
+
+import (
+	"fmt"
+
+	"github.com/snapp-incubator/soteria/internal/version"
+	"github.com/spf13/cobra"
+)
+
+type Version struct{}
+
+// main prints the build information of binary.
+func (Version) main(cmd *cobra.Command) {
+	info := version.Get()
+
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "version: %s\ncommit: %s\nbuild date: %s\ngo: %s\n",
+		info.Version, info.Commit, info.BuildDate, info.GoVersion,
+	)
+}
+
+// Register version command.
+func (v Version) Register(root *cobra.Command) {
+	root.AddCommand(
+		//nolint: exhaustruct
+		&cobra.Command{
+			Use:   "version",
+			Short: "version prints the build information",
+			Args:  cobra.NoArgs,
+			Run: func(cmd *cobra.Command, _ []string) {
+				v.main(cmd)
+			},
+		},
+	)
+}
@@ -1,8 +1,9 @@
 package config
 
 import (
-	"encoding/json"
-	"log"
+	"fmt"
+	"os"
+	"slices"
 	"strings"
 	"time"
 
@@ -11,17 +12,33 @@ import (
 	"github.com/knadh/koanf/providers/file"
 	"github.com/knadh/koanf/providers/structs"
 	"github.com/knadh/koanf/v2"
+	"github.com/snapp-incubator/soteria/internal/audit"
+	"github.com/snapp-incubator/soteria/internal/budget"
+	"github.com/snapp-incubator/soteria/internal/claimguard"
 	"github.com/snapp-incubator/soteria/internal/clientid"
+	"github.com/snapp-incubator/soteria/internal/failratio"
+	"github.com/snapp-incubator/soteria/internal/hashring"
+	"github.com/snapp-incubator/soteria/internal/invalidtopic"
+	"github.com/snapp-incubator/soteria/internal/limiter"
 	"github.com/snapp-incubator/soteria/internal/logger"
+	"github.com/snapp-incubator/soteria/internal/policy"
 	"github.com/snapp-incubator/soteria/internal/profiler"
+	"github.com/snapp-incubator/soteria/internal/replay"
+	"github.com/snapp-incubator/soteria/internal/reuse"
+	"github.com/snapp-incubator/soteria/internal/server"
+	"github.com/snapp-incubator/soteria/internal/session"
 	"github.com/snapp-incubator/soteria/internal/topics"
 	"github.com/snapp-incubator/soteria/internal/tracing"
-	"github.com/tidwall/pretty"
+	"github.com/snapp-incubator/soteria/internal/worker"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"go.uber.org/zap"
 )
 
 const (
 	// Prefix indicates environment variables prefix.
 	Prefix = "soteria_"
+	// File is the configuration file which is read by New and reloaded on SIGHUP.
+	File = "config.yml"
 )
 
 type (
@@ -35,6 +52,54 @@ type (
 		Validator     Validator       `json:"validator,omitempty"      koanf:"validator"`
 		Parser        clientid.Config `json:"parser,omitempty"         koanf:"parser"`
 		Profiler      profiler.Config `json:"profiler,omitempty"       koanf:"profiler"`
+		// WillTopicPolicy is deny, log or strip and controls the disallowed will topics on connect.
+		WillTopicPolicy string `json:"will_topic_policy,omitempty" koanf:"will_topic_policy"`
+		// Budget is the latency budget of endpoints, requests are answered by default decision when they exceed it.
+		Budget budget.Config `json:"budget,omitempty" koanf:"budget"`
+		// TopicSets are named lists of topics which vendors reference using their topic_sets.
+		TopicSets map[string][]topics.Topic `json:"topic_sets,omitempty" koanf:"topic_sets"`
+		// Features are the default feature flags of vendors.
+		Features map[string]bool `json:"features,omitempty" koanf:"features"`
+		// MaxTopicLength is the maximum length of topics in bytes, longer topics are rejected before matching.
+		MaxTopicLength int `json:"max_topic_length,omitempty" koanf:"max_topic_length"`
+		// MaxCredentialLength is the maximum length of tokens in bytes, longer tokens are rejected before parsing.
+		MaxCredentialLength int `json:"max_credential_length,omitempty" koanf:"max_credential_length"`
+		// HTTPHost is the interface which HTTP server binds to, empty means all interfaces.
+		HTTPHost string `json:"http_host,omitempty" koanf:"http_host"`
+		// ReusePort sets SO_REUSEPORT on listeners on linux, so two processes can share the port during deploys.
+		ReusePort bool `json:"reuse_port,omitempty" koanf:"reuse_port"`
+		// FailureRatio tracks the authentication failure ratio of issuers and warns when it crosses the threshold.
+		FailureRatio failratio.Config `json:"failure_ratio,omitempty" koanf:"failure_ratio"`
+		// ClaimGuard samples the claim names of tokens and logs an error when the configured issuer or subject
+		// claim of a vendor is absent from most of them.
+		ClaimGuard claimguard.Config `json:"claim_guard,omitempty" koanf:"claim_guard"`
+		// Limiter caps the in-flight requests of endpoints and sheds the requests over its queue.
+		Limiter limiter.Config `json:"limiter,omitempty" koanf:"limiter"`
+		// VendorResolution is the ordered vendors which handle the tokens without vendor by their issuer,
+		// it replaces the default vendor which is used when resolution is empty.
+		VendorResolution []string `json:"vendor_resolution,omitempty" koanf:"vendor_resolution"`
+		// ParallelResolution authenticates the tokens by all of the vendors of resolution which know
+		// their issuer in parallel, so a slow vendor doesn't prevent the others in the request budget.
+		ParallelResolution bool `json:"parallel_resolution,omitempty" koanf:"parallel_resolution"`
+		// StrictTopicShadowing fails the startup when a topic template is shadowed by an earlier one,
+		// otherwise shadowed templates are logged as warning.
+		StrictTopicShadowing bool `json:"strict_topic_shadowing,omitempty" koanf:"strict_topic_shadowing"`
+		// SessionCache memoizes the ACL decisions by client id, it is disabled when its ttl is zero.
+		SessionCache session.Config `json:"session_cache,omitempty" koanf:"session_cache"`
+		// Background bounds the goroutines of background tasks, queued tasks are drained on shutdown.
+		Background worker.Config `json:"background,omitempty" koanf:"background"`
+		// Capture samples the topic decisions of ACL requests into a file, so they can be replayed
+		// against another configuration.
+		Capture replay.Config `json:"capture,omitempty" koanf:"capture"`
+		// RecentDecisions keeps the recent ACL decisions in memory for admin API, it is disabled when its size is zero.
+		RecentDecisions audit.Config `json:"recent_decisions,omitempty" koanf:"recent_decisions"`
+		// DisableLegacyRoutes removes the v1 routes of the old EMQ clusters once they are migrated to v2.
+		DisableLegacyRoutes bool `json:"disable_legacy_routes,omitempty" koanf:"disable_legacy_routes"`
+		// InvalidTopics tracks the rate of invalid topic denials of vendors and topic types and warns when it
+		// crosses the threshold with the most frequent shapes of the denied topics.
+		InvalidTopics invalidtopic.Config `json:"invalid_topics,omitempty" koanf:"invalid_topics"`
+		// Server tunes the timeouts, body limits and compression of REST server.
+		Server server.Config `json:"server,omitempty" koanf:"server"`
 	}
 
 	Vendor struct {
@@ -47,60 +112,258 @@ type (
 		Jwt                JWT                        `json:"jwt,omitempty"                  koanf:"jwt"`
 		Type               string                     `json:"type,omitempty"                 koanf:"type"`
 		HashIDMap          map[string]topics.HashData `json:"hash_id_map,omitempty"          koanf:"hashid_map"`
+		// Features override the global feature flags for the vendor.
+		Features map[string]bool `json:"features,omitempty" koanf:"features"`
+		// TopicSets are names of the shared topic sets, vendor topics override their topics by type.
+		TopicSets []string `json:"topic_sets,omitempty" koanf:"topic_sets"`
+		// AccessQualifierClaim is the claim which is appended to the issuer for looking up topic accesses.
+		AccessQualifierClaim string `json:"access_qualifier_claim,omitempty" koanf:"access_qualifier_claim"`
+		// VerificationKeys are the ordered keys of issuers which are used during key rotation.
+		VerificationKeys map[string][]VerificationKey `json:"verification_keys,omitempty" koanf:"verification_keys"`
+		// StaticClients are clients like bridges which use username and password instead of JWT.
+		StaticClients []StaticClient `json:"static_clients,omitempty" koanf:"static_clients"`
+		// SigningSecret signs the auth and acl responses of vendor when it is set, it is never logged.
+		SigningSecret string `json:"-" koanf:"signing_secret"`
+		// AllowedCIDRs and DeniedCIDRs filter the client addresses on authentication, denied ranges have precedence.
+		AllowedCIDRs []string `json:"allowed_cidrs,omitempty" koanf:"allowed_cidrs"`
+		DeniedCIDRs  []string `json:"denied_cidrs,omitempty"  koanf:"denied_cidrs"`
+		// Mountpoints are the allowed EMQ listener mountpoints, they are stripped from topics before matching.
+		Mountpoints []string `json:"mountpoints,omitempty" koanf:"mountpoints"`
+		// TokenSource is the MQTT field which has the token, it is password, username or either.
+		// Empty token source takes the username and then the password when username is empty.
+		TokenSource string `json:"token_source,omitempty" koanf:"token_source"`
+		// AllowAnonymous allows the clients with empty credentials, they are denied when it is nil.
+		AllowAnonymous *Anonymous `json:"allow_anonymous,omitempty" koanf:"allow_anonymous"`
+		// NoExpirySubjects can use tokens without exp claim, like internal services, tokens without exp claim
+		// of other subjects are rejected.
+		NoExpirySubjects []string `json:"no_expiry_subjects,omitempty" koanf:"no_expiry_subjects"`
+		// NoExpiryTopicTypes are the only topic types which tokens without exp claim can access.
+		NoExpiryTopicTypes []string `json:"no_expiry_topic_types,omitempty" koanf:"no_expiry_topic_types"`
+		// IssEntityRules resolve the entity of tokens by a claim in their order before IssEntityMap,
+		// e.g. guests which share their issuer with passengers.
+		IssEntityRules []topics.EntityRule `json:"iss_entity_rules,omitempty" koanf:"iss_entity_rules"`
+		// ExtraIssuers are the issuers which topic accesses can reference without being in IssEntityMap.
+		ExtraIssuers []string `json:"extra_issuers,omitempty" koanf:"extra_issuers"`
+		// TokenReuse detects the tokens which are authenticated from many client addresses, it is disabled when nil.
+		TokenReuse *reuse.Config `json:"token_reuse,omitempty" koanf:"token_reuse"`
+		// StaticTopics are the exact topics or MQTT patterns which are matched before the topic templates.
+		StaticTopics []topics.StaticTopic `json:"static_topics,omitempty" koanf:"static_topics"`
+		// ACLCacheAllowTTL and ACLCacheDenyTTL are hinted to EMQ as the acl cache ttl of allow and deny decisions,
+		// decisions are not hinted when they are zero.
+		ACLCacheAllowTTL time.Duration `json:"acl_cache_allow_ttl,omitempty" koanf:"acl_cache_allow_ttl"`
+		ACLCacheDenyTTL  time.Duration `json:"acl_cache_deny_ttl,omitempty"  koanf:"acl_cache_deny_ttl"`
+		// ACLVerification verifies the tokens of acl requests of auto vendors, which are parsed unverified
+		// when it is nil.
+		ACLVerification *ACLVerification `json:"acl_verification,omitempty" koanf:"acl_verification"`
+		// SubjectFormats are the formats of subjects of issuers, subjects of issuers without format are not checked.
+		SubjectFormats map[string]SubjectFormat `json:"subject_formats,omitempty" koanf:"subject_formats"`
+		// StrictSubjectFormats requires a subject format for every issuer of iss_entity_map.
+		StrictSubjectFormats bool `json:"strict_subject_formats,omitempty" koanf:"strict_subject_formats"`
+		// KeysDir is the directory of the key files of issuers, which override their keys. Keys of manual vendors
+		// are reloaded when its files change, without reloading the configuration.
+		KeysDir string `json:"keys_dir,omitempty" koanf:"keys_dir"`
+		// Policy evaluates the accesses of manual vendors by an OPA policy as an override or a second gate
+		// of the static accesses of topics, accesses are only static when it is nil.
+		Policy *policy.Config `json:"policy,omitempty" koanf:"policy"`
+		// MaxSessionDuration clamps the expire_at of authenticated clients when emit_expire_at is enabled,
+		// zero doesn't clamp it.
+		MaxSessionDuration time.Duration `json:"max_session_duration,omitempty" koanf:"max_session_duration"`
+	}
+
+	// SubjectFormat rejects the subjects of an issuer which don't match its pattern or don't decode
+	// as a hash-id of the issuer, e.g. raw numeric ids instead of hash-ids.
+	SubjectFormat struct {
+		Pattern string `json:"pattern,omitempty" koanf:"pattern"`
+		HashID  bool   `json:"hashid,omitempty"  koanf:"hashid"`
+	}
+
+	// ACLVerification verifies the acl tokens by the keys of vendor or by their recent validation on auth,
+	// and tokens of the requests which have the broker secret can be parsed unverified.
+	// Unverified tokens are only denied when Enforce is set, so their decisions can be tracked during migration.
+	ACLVerification struct {
+		Enforce bool `json:"enforce,omitempty" koanf:"enforce"`
+		// BrokerSecret is the secret of broker requests, it is never logged.
+		BrokerSecret string `json:"-" koanf:"broker_secret"`
+	}
+
+	// Anonymous maps the clients with empty credentials to a pseudo entity with its own topic accesses.
+	// Listeners and Mountpoints scope it, and it applies to every listener when both of them are empty.
+	Anonymous struct {
+		Entity      string        `json:"entity,omitempty"      koanf:"entity"`
+		Listeners   []string      `json:"listeners,omitempty"   koanf:"listeners"`
+		Mountpoints []string      `json:"mountpoints,omitempty" koanf:"mountpoints"`
+		Topics      []StaticTopic `json:"topics,omitempty"      koanf:"topics"`
 	}
 
 	JWT struct {
 		IssName       string `json:"iss_name,omitempty"       koanf:"iss_name"`
 		SubName       string `json:"sub_name,omitempty"       koanf:"sub_name"`
 		SigningMethod string `json:"signing_method,omitempty" koanf:"signing_method"`
+		// IssuerSigningMethods override the signing method of issuers, e.g. PS256 for the issuer of a partner.
+		IssuerSigningMethods map[string]string `json:"issuer_signing_methods,omitempty" koanf:"issuer_signing_methods"`
+	}
+
+	VerificationKey struct {
+		Kid string `json:"kid,omitempty" koanf:"kid"`
+		Key string `json:"key,omitempty" koanf:"key"`
+	}
+
+	// StaticClient has a bcrypt hash as its password.
+	StaticClient struct {
+		Username string        `json:"username,omitempty" koanf:"username"`
+		Password string        `json:"password,omitempty" koanf:"password"`
+		Topics   []StaticTopic `json:"topics,omitempty"   koanf:"topics"`
+	}
+
+	// StaticTopic is a topic pattern which can have MQTT wildcards.
+	StaticTopic struct {
+		Pattern string         `json:"pattern,omitempty" koanf:"pattern"`
+		Access  acl.AccessType `json:"access,omitempty"  koanf:"access"`
 	}
 
 	Validator struct {
-		URL     string        `json:"url,omitempty"     koanf:"url"`
-		Timeout time.Duration `json:"timeout,omitempty" koanf:"timeout"`
+		URL string `json:"url,omitempty" koanf:"url"`
+		// URLs are the endpoints of validator replicas, e.g. in multiple datacenters, which are chosen
+		// by consistent hashing of tokens instead of URL. Unhealthy endpoints are ejected by HealthCheck.
+		URLs        []string             `json:"urls,omitempty"         koanf:"urls"`
+		HealthCheck hashring.HealthCheck `json:"health_check,omitempty" koanf:"health_check"`
+		Timeout     time.Duration        `json:"timeout,omitempty"      koanf:"timeout"`
+		// IATSkewRetryDelay is the delay before retrying freshly minted tokens which are rejected
+		// by validator because of clock skew, zero disables the retry.
+		IATSkewRetryDelay time.Duration `json:"iat_skew_retry_delay,omitempty" koanf:"iat_skew_retry_delay"`
+		// CacheTTL caches the valid tokens and zero disables the cache, tokens which are cached longer
+		// are served during StaleTTL while they are validated again in background.
+		CacheTTL time.Duration `json:"cache_ttl,omitempty" koanf:"cache_ttl"`
+		StaleTTL time.Duration `json:"stale_ttl,omitempty" koanf:"stale_ttl"`
 	}
 )
 
-// New reads configuration with koanf.
-func New() Config {
+// New reads configuration with koanf, logger is used for reporting the loading errors.
+// Configuration file is optional and only the default configuration and environment variables are read without it.
+func New(logger *zap.Logger) Config {
+	path := File
+
+	if _, err := os.Stat(File); err != nil {
+		logger.Warn("error loading config.yml", zap.Error(err))
+
+		path = ""
+	}
+
+	instance, err := load(path, true)
+	if err != nil {
+		logger.Fatal("error loading configuration", zap.Error(err))
+	}
+
+	return instance
+}
+
+// Load reads the configuration of the given file on top of the default configuration,
+// environment variables are not loaded, so it can read candidate configurations.
+func Load(path string) (Config, error) {
+	return load(path, false)
+}
+
+// LoadWithEnv reads the configuration of the given file and environment variables on top of the default
+// configuration like New, but it returns the errors instead of exiting, so the running configuration
+// can be kept when a reloaded one is invalid.
+func LoadWithEnv(path string) (Config, error) {
+	return load(path, true)
+}
+
+func load(path string, environment bool) (Config, error) {
 	var instance Config
 
 	k := koanf.New(".")
 
-	// load default configuration from file
 	if err := k.Load(structs.Provider(Default(), "koanf"), nil); err != nil {
-		log.Fatalf("error loading default: %s", err)
+		return instance, fmt.Errorf("error loading default %w", err)
 	}
 
-	// load configuration from file
-	if err := k.Load(file.Provider("config.yml"), yaml.Parser()); err != nil {
-		log.Printf("error loading config.yml: %s", err)
+	if path != "" {
+		if err := k.Load(file.Provider(path), yaml.Parser()); err != nil {
+			return instance, fmt.Errorf("error loading %s %w", path, err)
+		}
 	}
 
-	// load environment variables
-	if err := k.Load(env.Provider(Prefix, ".", func(s string) string {
-		return strings.ReplaceAll(strings.ToLower(
-			strings.TrimPrefix(s, Prefix)), "__", ".")
-	}), nil); err != nil {
-		log.Printf("error loading environment variables: %s", err)
+	if environment {
+		if err := k.Load(env.Provider(Prefix, ".", func(s string) string {
+			return strings.ReplaceAll(strings.ToLower(
+				strings.TrimPrefix(s, Prefix)), "__", ".")
+		}), nil); err != nil {
+			return instance, fmt.Errorf("error loading environment variables %w", err)
+		}
 	}
 
 	if err := k.Unmarshal("", &instance); err != nil {
-		log.Fatalf("error unmarshalling config: %s", err)
+		return instance, fmt.Errorf("error unmarshalling config %w", err)
 	}
 
-	indent, err := json.MarshalIndent(instance, "", "\t")
-	if err != nil {
-		log.Fatalf("error marshaling configuration to json: %s", err)
+	if err := instance.ExpandTopicSets(); err != nil {
+		return instance, fmt.Errorf("error expanding topic sets %w", err)
 	}
 
-	indent = pretty.Color(indent, nil)
-	tmpl := `
-	================ Loaded Configuration ================
-	%s
-	======================================================
-	`
-	log.Printf(tmpl, string(indent))
+	if err := instance.ValidateTopics(); err != nil {
+		return instance, fmt.Errorf("invalid topics %w", err)
+	}
 
-	return instance
+	if err := instance.ValidateWillTopicPolicy(); err != nil {
+		return instance, err
+	}
+
+	instance.ApplyTopicDefaults()
+
+	if err := instance.ReadKeysDirs(); err != nil {
+		return instance, fmt.Errorf("error reading keys directories %w", err)
+	}
+
+	return instance, nil
+}
+
+// VendorFeatures returns the feature flags of vendors by their company.
+func (c Config) VendorFeatures() map[string]map[string]bool {
+	vendors := make(map[string]map[string]bool, len(c.Vendors))
+
+	for _, vendor := range c.Vendors {
+		vendors[vendor.Company] = vendor.Features
+	}
+
+	return vendors
+}
+
+// Resolution returns the vendor resolution, configurations without it use the default vendor.
+func (c Config) Resolution() []string {
+	if len(c.VendorResolution) > 0 {
+		return c.VendorResolution
+	}
+
+	if c.DefaultVendor == "" {
+		return nil
+	}
+
+	return []string{c.DefaultVendor}
+}
+
+// IssuerSigningMethod returns the signing method of issuer, issuers without their own signing method
+// use the signing method of vendor.
+func (j JWT) IssuerSigningMethod(iss string) string {
+	if method, ok := j.IssuerSigningMethods[iss]; ok {
+		return method
+	}
+
+	return j.SigningMethod
+}
+
+// SigningMethods returns the sorted signing methods of vendor and its issuers.
+func (j JWT) SigningMethods() []string {
+	methods := []string{j.SigningMethod}
+
+	for _, method := range j.IssuerSigningMethods {
+		if !slices.Contains(methods, method) {
+			methods = append(methods, method)
+		}
+	}
+
+	slices.Sort(methods)
+
+	return methods
 }
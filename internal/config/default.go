@@ -3,11 +3,23 @@ package config
 import (
 	"time"
 
+	"github.com/snapp-incubator/soteria/internal/audit"
+	"github.com/snapp-incubator/soteria/internal/budget"
+	"github.com/snapp-incubator/soteria/internal/claimguard"
 	"github.com/snapp-incubator/soteria/internal/clientid"
+	"github.com/snapp-incubator/soteria/internal/credential"
+	"github.com/snapp-incubator/soteria/internal/failratio"
+	"github.com/snapp-incubator/soteria/internal/hashring"
+	"github.com/snapp-incubator/soteria/internal/invalidtopic"
+	"github.com/snapp-incubator/soteria/internal/limiter"
 	"github.com/snapp-incubator/soteria/internal/logger"
 	"github.com/snapp-incubator/soteria/internal/profiler"
+	"github.com/snapp-incubator/soteria/internal/replay"
+	"github.com/snapp-incubator/soteria/internal/server"
+	"github.com/snapp-incubator/soteria/internal/session"
 	"github.com/snapp-incubator/soteria/internal/topics"
 	"github.com/snapp-incubator/soteria/internal/tracing"
+	"github.com/snapp-incubator/soteria/internal/worker"
 	"github.com/snapp-incubator/soteria/pkg/acl"
 )
 
@@ -28,6 +40,12 @@ func Default() Config {
 		Logger: logger.Config{
 			Level:      "debug",
 			Stacktrace: true,
+			Format:     logger.FormatConsole,
+			File: logger.File{
+				Path:       "",
+				MaxSize:    100,
+				MaxBackups: 3,
+			},
 		},
 		Parser: clientid.Config{
 			Patterns: map[string]string{},
@@ -37,15 +55,116 @@ func Default() Config {
 			Enabled:  false,
 			Ratio:    0.1,
 			Endpoint: "127.0.0.1:4317",
+			// metrics are exported with prometheus and sent to the endpoint only when enabled.
+			Metrics:         false,
+			MetricsInterval: time.Minute,
+			Attributes:      nil,
 		},
 		Validator: Validator{
-			URL:     "http://validator-lb",
-			Timeout: 5 * time.Second,
+			URL:  "http://validator-lb",
+			URLs: nil,
+			HealthCheck: hashring.HealthCheck{
+				Path:             hashring.DefaultHealthPath,
+				Interval:         hashring.DefaultHealthInterval,
+				Timeout:          hashring.DefaultHealthTimeout,
+				FailureThreshold: hashring.DefaultFailureThreshold,
+			},
+			Timeout:           5 * time.Second,
+			IATSkewRetryDelay: time.Second,
+			CacheTTL:          0,
+			StaleTTL:          0,
 		},
 		Profiler: profiler.Config{
 			Enabled: false,
 			URL:     "",
 		},
+		WillTopicPolicy: WillTopicPolicyDeny,
+		Budget: budget.Config{
+			Auth: budget.Endpoint{
+				Deadline:          0,
+				Default:           budget.DecisionDeny,
+				AllowedTopicTypes: nil,
+			},
+			ACL: budget.Endpoint{
+				Deadline:          0,
+				Default:           budget.DecisionDeny,
+				AllowedTopicTypes: nil,
+			},
+		},
+		TopicSets:           nil,
+		Features:            map[string]bool{},
+		MaxTopicLength:      topics.DefaultMaxTopicLength,
+		MaxCredentialLength: credential.DefaultMaxLength,
+		HTTPHost:            "",
+		ReusePort:           false,
+		FailureRatio: failratio.Config{
+			Threshold:    0.5,
+			Window:       time.Minute,
+			MinRequests:  20,
+			WarnInterval: time.Minute,
+		},
+		ClaimGuard: claimguard.Config{
+			SampleRate:   claimguard.DefaultSampleRate,
+			Threshold:    0.9,
+			Window:       claimguard.DefaultWindow,
+			MinSamples:   20,
+			WarnInterval: claimguard.DefaultWindow,
+			MaxClaims:    claimguard.DefaultMaxClaims,
+		},
+		Limiter: limiter.Config{
+			Auth: limiter.Endpoint{
+				MaxInFlight: 0,
+				QueueSize:   256,
+				MaxWait:     limiter.DefaultMaxWait,
+				RetryAfter:  limiter.DefaultRetryAfter,
+			},
+			ACL: limiter.Endpoint{
+				MaxInFlight: 0,
+				QueueSize:   256,
+				MaxWait:     limiter.DefaultMaxWait,
+				RetryAfter:  limiter.DefaultRetryAfter,
+			},
+		},
+		// default vendor is used when resolution is empty.
+		VendorResolution:     nil,
+		StrictTopicShadowing: false,
+		SessionCache: session.Config{
+			TTL:        0,
+			MaxEntries: 100_000,
+		},
+		Background: worker.Config{
+			Workers:         worker.DefaultWorkers,
+			QueueSize:       worker.DefaultQueueSize,
+			ShutdownTimeout: worker.DefaultShutdownTimeout,
+		},
+		Capture: replay.Config{
+			Path:  "",
+			Ratio: 0.01,
+		},
+		RecentDecisions: audit.Config{
+			Size: 1000,
+		},
+		InvalidTopics: invalidtopic.Config{
+			Threshold:    1,
+			Window:       invalidtopic.DefaultWindow,
+			WarnInterval: invalidtopic.DefaultWindow,
+			TopK:         invalidtopic.DefaultTopK,
+			Epsilon:      invalidtopic.DefaultEpsilon,
+		},
+		Server: server.Config{
+			ReadTimeout:    0,
+			WriteTimeout:   0,
+			IdleTimeout:    0,
+			MaxHeaderBytes: server.DefaultMaxHeaderBytes,
+			BodyLimits: server.BodyLimits{
+				Broker: server.DefaultBodyLimit,
+				Admin:  server.DefaultBodyLimit,
+			},
+			Compression: server.Compression{
+				Enabled: false,
+				MinSize: server.DefaultMinCompressSize,
+			},
+		},
 	}
 }
 
@@ -184,9 +303,24 @@ func SnappVendor() Vendor {
 			"default": "",
 		},
 		Jwt: JWT{
-			IssName:       "iss",
-			SubName:       "sub",
-			SigningMethod: "RS512",
+			IssName:              "iss",
+			SubName:              "sub",
+			SigningMethod:        "RS512",
+			IssuerSigningMethods: nil,
 		},
+		Features:             map[string]bool{},
+		TopicSets:            nil,
+		AccessQualifierClaim: "",
+		VerificationKeys:     nil,
+		StaticClients:        nil,
+		SigningSecret:        "",
+		AllowedCIDRs:         nil,
+		DeniedCIDRs:          nil,
+		TokenSource:          "",
+		AllowAnonymous:       nil,
+		NoExpirySubjects:     nil,
+		NoExpiryTopicTypes:   nil,
+		IssEntityRules:       nil,
+		ExtraIssuers:         nil,
 	}
 }
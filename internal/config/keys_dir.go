@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReadKeysDir reads the keys of issuers from the files of directory, the file name without its extension
+// is the issuer. Hidden files are skipped, so the ..data symlinks of Kubernetes secret volumes are not read
+// and the symlinks of their keys are followed.
+func ReadKeysDir(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read keys directory %s %w", dir, err)
+	}
+
+	keys := make(map[string]string)
+
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot stat key file %s %w", path, err)
+		}
+
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read key file %s %w", path, err)
+		}
+
+		keys[strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))] = strings.TrimSpace(string(data))
+	}
+
+	return keys, nil
+}
+
+// ReadKeysDirs adds the keys of the keys directory of vendors to their keys, keys of files
+// override the keys of configuration for the same issuer.
+func (c *Config) ReadKeysDirs() error {
+	for i, vendor := range c.Vendors {
+		if vendor.KeysDir == "" {
+			continue
+		}
+
+		keys, err := ReadKeysDir(vendor.KeysDir)
+		if err != nil {
+			return fmt.Errorf("vendor %s %w", vendor.Company, err)
+		}
+
+		merged := make(map[string]string, len(vendor.Keys)+len(keys))
+
+		maps.Copy(merged, vendor.Keys)
+		maps.Copy(merged, keys)
+
+		c.Vendors[i].Keys = merged
+	}
+
+	return nil
+}
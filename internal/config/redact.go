@@ -0,0 +1,123 @@
+package config
+
+import (
+	"maps"
+	"net/url"
+
+	"github.com/snapp-incubator/soteria/internal/topics"
+)
+
+// RedactedValue replaces the secrets of redacted configuration.
+const RedactedValue = "REDACTED"
+
+// Redacted returns a copy of vendor without its secrets. Names of keys are kept, so the issuers
+// which have keys are still visible, and passwords of webhook, state service and remote accesses URLs are redacted.
+func (v Vendor) Redacted() Vendor {
+	r := v
+
+	r.SigningSecret = ""
+
+	if v.ACLVerification != nil {
+		verification := *v.ACLVerification
+		verification.BrokerSecret = ""
+		r.ACLVerification = &verification
+	}
+
+	r.Keys = make(map[string]string, len(v.Keys))
+	for iss := range v.Keys {
+		r.Keys[iss] = RedactedValue
+	}
+
+	r.VerificationKeys = make(map[string][]VerificationKey, len(v.VerificationKeys))
+	for iss, keys := range v.VerificationKeys {
+		for _, key := range keys {
+			r.VerificationKeys[iss] = append(r.VerificationKeys[iss], VerificationKey{
+				Kid: key.Kid,
+				Key: RedactedValue,
+			})
+		}
+	}
+
+	r.HashIDMap = make(map[string]topics.HashData, len(v.HashIDMap))
+	for iss, data := range v.HashIDMap {
+		data.Salt = RedactedValue
+		r.HashIDMap[iss] = data
+	}
+
+	r.StaticClients = make([]StaticClient, 0, len(v.StaticClients))
+	for _, client := range v.StaticClients {
+		client.Password = RedactedValue
+		r.StaticClients = append(r.StaticClients, client)
+	}
+
+	r.Topics = redactTopics(v.Topics)
+
+	return r
+}
+
+// Redacted returns a copy of configuration without the secrets of its vendors, topic sets and validator,
+// so it can be logged.
+func (c Config) Redacted() Config {
+	r := c
+
+	r.Vendors = make([]Vendor, 0, len(c.Vendors))
+	for _, vendor := range c.Vendors {
+		r.Vendors = append(r.Vendors, vendor.Redacted())
+	}
+
+	r.TopicSets = make(map[string][]topics.Topic, len(c.TopicSets))
+	for name, set := range c.TopicSets {
+		r.TopicSets[name] = redactTopics(set)
+	}
+
+	r.Validator.URL = redactURL(c.Validator.URL)
+
+	r.Validator.URLs = make([]string, 0, len(c.Validator.URLs))
+	for _, u := range c.Validator.URLs {
+		r.Validator.URLs = append(r.Validator.URLs, redactURL(u))
+	}
+
+	return r
+}
+
+// redactTopics returns a copy of topics without the passwords of their webhook, state service and
+// remote accesses URLs.
+func redactTopics(list []topics.Topic) []topics.Topic {
+	redacted := make([]topics.Topic, 0, len(list))
+
+	for _, topic := range list {
+		topic.Accesses = maps.Clone(topic.Accesses)
+
+		if topic.PostAuthorizeWebhook != nil {
+			webhook := *topic.PostAuthorizeWebhook
+			webhook.URL = redactURL(webhook.URL)
+			topic.PostAuthorizeWebhook = &webhook
+		}
+
+		if topic.StateCheck != nil {
+			check := *topic.StateCheck
+			check.URL = redactURL(check.URL)
+			topic.StateCheck = &check
+		}
+
+		if topic.RemoteAccesses != nil {
+			remote := *topic.RemoteAccesses
+			remote.URL = redactURL(remote.URL)
+			topic.RemoteAccesses = &remote
+		}
+
+		redacted = append(redacted, topic)
+	}
+
+	return redacted
+}
+
+// redactURL redacts the password of URL, invalid URLs are redacted completely.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return RedactedValue
+	}
+
+	return u.Redacted()
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/snapp-incubator/soteria/internal/topics"
+)
+
+var ErrDuplicateTopicSet = errors.New("topic set is referenced more than once")
+
+type UnknownTopicSetError struct {
+	Vendor string
+	Set    string
+}
+
+func (err UnknownTopicSetError) Error() string {
+	return fmt.Sprintf("vendor %s references unknown topic set %s", err.Vendor, err.Set)
+}
+
+type ConflictingTopicTypeError struct {
+	Vendor string
+	Type   string
+	Sets   []string
+}
+
+func (err ConflictingTopicTypeError) Error() string {
+	return fmt.Sprintf("vendor %s has topic type %s in more than one topic set %v", err.Vendor, err.Type, err.Sets)
+}
+
+// ExpandTopicSets replaces the topic sets references of vendors with their topics.
+// topics of sets are added in the order of references and then vendor topics override them
+// by type or are added at the end. A type can have more than one template in its set, and vendor
+// topics of the type replace all of them.
+func (c *Config) ExpandTopicSets() error {
+	for i, vendor := range c.Vendors {
+		expanded, err := c.expandTopicSets(vendor)
+		if err != nil {
+			return err
+		}
+
+		c.Vendors[i].Topics = expanded
+	}
+
+	return nil
+}
+
+func (c *Config) expandTopicSets(vendor Vendor) ([]topics.Topic, error) {
+	if len(vendor.TopicSets) == 0 {
+		return vendor.Topics, nil
+	}
+
+	expanded := make([]topics.Topic, 0)
+	// sources keep the set of each topic type for reporting conflicts.
+	sources := make(map[string]string)
+
+	for i, name := range vendor.TopicSets {
+		if slices.Contains(vendor.TopicSets[:i], name) {
+			return nil, fmt.Errorf("%w: %s in vendor %s", ErrDuplicateTopicSet, name, vendor.Company)
+		}
+
+		set, ok := c.TopicSets[name]
+		if !ok {
+			return nil, UnknownTopicSetError{Vendor: vendor.Company, Set: name}
+		}
+
+		for _, topic := range set {
+			// templates of a type can be repeated in their set, e.g. during topic migrations.
+			if source, ok := sources[topic.Type]; ok && source != name {
+				return nil, ConflictingTopicTypeError{
+					Vendor: vendor.Company,
+					Type:   topic.Type,
+					Sets:   []string{source, name},
+				}
+			}
+
+			sources[topic.Type] = name
+
+			expanded = append(expanded, topic)
+		}
+	}
+
+	for _, topic := range vendor.Topics {
+		index := slices.IndexFunc(expanded, func(t topics.Topic) bool {
+			return t.Type == topic.Type
+		})
+
+		if _, ok := sources[topic.Type]; ok && index >= 0 {
+			expanded[index] = topic
+
+			// the other set templates of the type are removed, so vendor templates replace all of them.
+			expanded = slices.Concat(expanded[:index+1], slices.DeleteFunc(slices.Clone(expanded[index+1:]), func(t topics.Topic) bool {
+				return t.Type == topic.Type
+			}))
+
+			// only the first local topic overrides the set topics.
+			delete(sources, topic.Type)
+
+			continue
+		}
+
+		expanded = append(expanded, topic)
+	}
+
+	return expanded, nil
+}
@@ -0,0 +1,138 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/stretchr/testify/require"
+)
+
+func topic(t string, access acl.AccessType) topics.Topic {
+	// nolint: exhaustruct
+	return topics.Topic{
+		Type:     t,
+		Template: "^" + t + "$",
+		Accesses: map[string]acl.AccessType{topics.DriverIss: access},
+	}
+}
+
+func deprecated(topic topics.Topic) topics.Topic {
+	topic.Template = "^old/" + topic.Type + "$"
+	topic.Deprecated = true
+
+	return topic
+}
+
+// nolint: funlen
+func TestExpandTopicSets(t *testing.T) {
+	t.Parallel()
+
+	sets := map[string][]topics.Topic{
+		"location": {topic(topics.DriverLocation, acl.Pub), topic(topics.PassengerLocation, acl.Pub)},
+		"chat":     {topic(topics.Chat, acl.Sub)},
+		"driver":   {topic(topics.DriverLocation, acl.Sub)},
+		"chat_v2":  {deprecated(topic(topics.Chat, acl.Sub)), topic(topics.Chat, acl.PubSub)},
+	}
+
+	cases := []struct {
+		name   string
+		sets   []string
+		topics []topics.Topic
+		result []topics.Topic
+		err    error
+	}{
+		{
+			name:   "without topic sets",
+			sets:   nil,
+			topics: []topics.Topic{topic(topics.Chat, acl.Pub)},
+			result: []topics.Topic{topic(topics.Chat, acl.Pub)},
+			err:    nil,
+		},
+		{
+			name:   "with overrides and additions",
+			sets:   []string{"location", "chat"},
+			topics: []topics.Topic{topic(topics.DriverLocation, acl.None), topic(topics.CabEvent, acl.Sub)},
+			result: []topics.Topic{
+				topic(topics.DriverLocation, acl.None),
+				topic(topics.PassengerLocation, acl.Pub),
+				topic(topics.Chat, acl.Sub),
+				topic(topics.CabEvent, acl.Sub),
+			},
+			err: nil,
+		},
+		{
+			name:   "shared topic type",
+			sets:   []string{"chat_v2"},
+			topics: nil,
+			result: []topics.Topic{deprecated(topic(topics.Chat, acl.Sub)), topic(topics.Chat, acl.PubSub)},
+			err:    nil,
+		},
+		{
+			name:   "override of shared topic type",
+			sets:   []string{"chat_v2", "location"},
+			topics: []topics.Topic{topic(topics.Chat, acl.None)},
+			result: []topics.Topic{
+				topic(topics.Chat, acl.None),
+				topic(topics.DriverLocation, acl.Pub),
+				topic(topics.PassengerLocation, acl.Pub),
+			},
+			err: nil,
+		},
+		{
+			name:   "unknown topic set",
+			sets:   []string{"location", "call"},
+			topics: nil,
+			result: nil,
+			err:    config.UnknownTopicSetError{Vendor: "snapp", Set: "call"},
+		},
+		{
+			name:   "conflicting topic types",
+			sets:   []string{"location", "driver"},
+			topics: nil,
+			result: nil,
+			err: config.ConflictingTopicTypeError{
+				Vendor: "snapp",
+				Type:   topics.DriverLocation,
+				Sets:   []string{"location", "driver"},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			require := require.New(t)
+
+			vendor := config.SnappVendor()
+			vendor.TopicSets = c.sets
+			vendor.Topics = c.topics
+
+			// nolint: exhaustruct
+			cfg := config.Config{
+				Vendors:   []config.Vendor{vendor},
+				TopicSets: sets,
+			}
+
+			err := cfg.ExpandTopicSets()
+			if c.err != nil {
+				require.Equal(c.err, err)
+
+				return
+			}
+
+			require.NoError(err)
+			require.Equal(c.result, cfg.Vendors[0].Topics)
+		})
+	}
+
+	// nolint: exhaustruct
+	cfg := config.Config{
+		Vendors:   []config.Vendor{{Company: "snapp", TopicSets: []string{"chat", "chat"}}},
+		TopicSets: sets,
+	}
+
+	require.ErrorIs(t, cfg.ExpandTopicSets(), config.ErrDuplicateTopicSet)
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/snapp-incubator/soteria/internal/topics"
+)
+
+// TopicError is an error of a topic of vendor with its index after expanding the topic sets.
+type TopicError struct {
+	Vendor string
+	Index  int
+	Type   string
+	Err    error
+}
+
+func (err TopicError) Error() string {
+	return fmt.Sprintf("vendor %s topics[%d] (%s): %s", err.Vendor, err.Index, err.Type, err.Err)
+}
+
+func (err TopicError) Unwrap() error {
+	return err.Err
+}
+
+// HashTypeError is an unknown hash type of an issuer of vendor.
+type HashTypeError struct {
+	Vendor string
+	Issuer string
+	Type   string
+}
+
+func (err HashTypeError) Error() string {
+	return fmt.Sprintf("vendor %s hashid_map.%s: %s %q", err.Vendor, err.Issuer, topics.ErrUnknownHashType, err.Type)
+}
+
+func (err HashTypeError) Unwrap() error {
+	return topics.ErrUnknownHashType
+}
+
+// ApplyTopicDefaults sets the default hash type of issuers, so the effective hash types are explicit
+// in the printed configuration.
+func (c *Config) ApplyTopicDefaults() {
+	for _, vendor := range c.Vendors {
+		for iss, data := range vendor.HashIDMap {
+			if data.Type == "" {
+				data.Type = topics.HashTypeHashID
+				vendor.HashIDMap[iss] = data
+			}
+		}
+	}
+}
+
+// ValidateTopics checks the topics and hash types of vendors after their topic sets are expanded
+// and joins all of their errors, which are TopicError or HashTypeError.
+func (c Config) ValidateTopics() error {
+	errs := make([]error, 0)
+
+	for _, vendor := range c.Vendors {
+		for i, topic := range vendor.Topics {
+			if err := topic.Validate(); err != nil {
+				errs = append(errs, TopicError{Vendor: vendor.Company, Index: i, Type: topic.Type, Err: err})
+			}
+		}
+
+		for _, iss := range slices.Sorted(maps.Keys(vendor.HashIDMap)) {
+			if err := vendor.HashIDMap[iss].Validate(); err != nil {
+				errs = append(errs, HashTypeError{Vendor: vendor.Company, Issuer: iss, Type: vendor.HashIDMap[iss].Type})
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,148 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/stretchr/testify/require"
+)
+
+// nolint: funlen
+func TestValidateTopics(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		raw  string
+		err  error
+		path string
+	}{
+		{
+			name: "missing template",
+			raw: `
+vendors:
+  - company: snapp
+    topics:
+      - type: chat
+        accesses:
+          0: pub
+`,
+			err:  topics.ErrMissingTemplate,
+			path: "vendor snapp topics[0] (chat)",
+		},
+		{
+			name: "missing type",
+			raw: `
+vendors:
+  - company: snapp
+    topics:
+      - template: ^chat$
+        accesses:
+          0: pub
+`,
+			err:  topics.ErrMissingTopicType,
+			path: "vendor snapp topics[0] ()",
+		},
+		{
+			name: "unclosed action",
+			raw: `
+vendors:
+  - company: snapp
+    topics:
+      - type: chat
+        template: ^chat$
+        accesses:
+          0: pub
+      - type: location
+        template: ^{{.company}/location$
+        accesses:
+          0: pub
+`,
+			err:  topics.ErrInvalidTemplate,
+			path: "vendor snapp topics[1] (location)",
+		},
+		{
+			name: "unknown function",
+			raw: `
+vendors:
+  - company: snapp
+    topics:
+      - type: location
+        template: ^{{.company}}/{{DecodeHash .iss .sub}}/location$
+        accesses:
+          0: pub
+`,
+			err:  topics.ErrInvalidTemplate,
+			path: "vendor snapp topics[0] (location)",
+		},
+		{
+			name: "missing accesses",
+			raw: `
+vendors:
+  - company: snapp
+    topics:
+      - type: chat
+        template: ^chat$
+`,
+			err:  topics.ErrMissingAccesses,
+			path: "vendor snapp topics[0] (chat)",
+		},
+		{
+			name: "unknown hash type",
+			raw: `
+vendors:
+  - company: snapp
+    hashid_map:
+      0:
+        type: md5
+`,
+			err:  topics.ErrUnknownHashType,
+			path: "vendor snapp hashid_map.0",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			require := require.New(t)
+
+			path := filepath.Join(t.TempDir(), "config.yml")
+			require.NoError(os.WriteFile(path, []byte(c.raw), 0o600))
+
+			_, err := config.Load(path)
+			require.ErrorIs(err, c.err)
+			require.ErrorContains(err, c.path)
+		})
+	}
+}
+
+func TestApplyTopicDefaults(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(os.WriteFile(path, []byte(`
+vendors:
+  - company: snapp
+    topics:
+      - type: location
+        template: ^{{.company}}/{{IssToEntity .iss}}/{{EncodeHashID .hashId .iss}}/location$
+        accesses:
+          0: pub
+    hashid_map:
+      0:
+        salt: secret
+      1:
+        type: none
+`), 0o600))
+
+	cfg, err := config.Load(path)
+	require.NoError(err)
+	require.Equal(topics.HashTypeHashID, cfg.Vendors[0].HashIDMap[topics.DriverIss].Type)
+	require.Equal(topics.HashTypeNone, cfg.Vendors[0].HashIDMap[topics.PassengerIss].Type)
+}
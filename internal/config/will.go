@@ -0,0 +1,28 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// WillTopicPolicyDeny denies the connection when its will topic is not allowed.
+	WillTopicPolicyDeny = "deny"
+	// WillTopicPolicyLog only logs the disallowed will topic and accepts the connection.
+	WillTopicPolicyLog = "log"
+	// WillTopicPolicyStrip accepts the connection and denies the publish of its will topic by the ACL rules
+	// of auth response, so EMQ drops the will.
+	WillTopicPolicyStrip = "strip"
+)
+
+var ErrInvalidWillTopicPolicy = errors.New("will topic policy should be deny, log or strip")
+
+// ValidateWillTopicPolicy checks the will topic policy is one of the policies, empty policy is deny.
+func (c Config) ValidateWillTopicPolicy() error {
+	switch c.WillTopicPolicy {
+	case "", WillTopicPolicyDeny, WillTopicPolicyLog, WillTopicPolicyStrip:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidWillTopicPolicy, c.WillTopicPolicy)
+	}
+}
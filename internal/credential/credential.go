@@ -0,0 +1,107 @@
+// Package credential checks the shape of tokens before they are parsed, so random strings which
+// are sent by scanners are dropped without base64 decoding or the error allocations of JWT parsing.
+package credential
+
+import (
+	"strings"
+
+	serrors "github.com/snapp-incubator/soteria/internal/errors"
+)
+
+// DefaultMaxLength is the default maximum length of tokens in bytes.
+const DefaultMaxLength = 8 << 10
+
+// Reasons of malformed credentials which are used in errors and metrics.
+const (
+	MalformedTooLong  = "too_long"
+	MalformedSegments = "segments"
+	MalformedAlphabet = "alphabet"
+	// MalformedEncoding is the reason of tokens which have the JWT shape but their segments cannot be decoded.
+	MalformedEncoding = "encoding"
+)
+
+// segments is the number of segments of a compact JWT, header, payload and signature.
+const segments = 3
+
+// alphabet is the unpadded base64url alphabet which is indexed by byte.
+// nolint: gochecknoglobals
+var alphabet = func() [256]bool {
+	var table [256]bool
+
+	for _, c := range []byte("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_") {
+		table[c] = true
+	}
+
+	return table
+}()
+
+type MalformedCredentialError = serrors.MalformedCredentialError
+
+// Check returns the reason of a token which is not a compact JWT and is empty for well-formed tokens.
+// Well-formed tokens have exactly two dots, non-empty header and payload, and only the unpadded
+// base64url alphabet in their segments. zero max length disables the length check. It doesn't allocate.
+func Check(token string, maxLength int) string {
+	if maxLength > 0 && len(token) > maxLength {
+		return MalformedTooLong
+	}
+
+	count, length := 1, 0
+
+	for i := range len(token) {
+		switch c := token[i]; {
+		case alphabet[c]:
+			length++
+		case c != '.':
+			return MalformedAlphabet
+		case length == 0, count == segments:
+			return MalformedSegments
+		default:
+			count++
+			length = 0
+		}
+	}
+
+	if count != segments {
+		return MalformedSegments
+	}
+
+	return ""
+}
+
+// Normalize converts the tokens of embedded clients which have whitespaces, padded segments or the standard
+// base64 alphabet to compact JWT with unpadded base64url segments, which is the same as re-encoding them,
+// and returns false when token doesn't need normalization. Padding is only removed from the end of segments.
+func Normalize(token string) (string, bool) {
+	if !strings.ContainsAny(token, " \t\r\n=+/") {
+		return token, false
+	}
+
+	var b strings.Builder
+
+	b.Grow(len(token))
+
+	for i, segment := range strings.Split(token, ".") {
+		if i > 0 {
+			b.WriteByte('.')
+		}
+
+		segment = strings.TrimRight(strings.Map(func(r rune) rune {
+			switch r {
+			case ' ', '\t', '\r', '\n':
+				return -1
+			case '+':
+				return '-'
+			case '/':
+				return '_'
+			default:
+				return r
+			}
+		}, segment), "=")
+
+		b.WriteString(segment)
+	}
+
+	normalized := b.String()
+
+	return normalized, normalized != token
+}
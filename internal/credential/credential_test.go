@@ -0,0 +1,110 @@
+package credential_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/snapp-incubator/soteria/internal/credential"
+	"github.com/snapp-incubator/soteria/pkg/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	token, err := testutil.DriverToken(jwt.SigningMethodHS512, []byte("secret"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		token     string
+		maxLength int
+		reason    string
+	}{
+		{name: "token", token: token, maxLength: credential.DefaultMaxLength, reason: ""},
+		{name: "no length check", token: token, maxLength: 0, reason: ""},
+		{name: "unsigned", token: "eyJh.eyJz.", maxLength: 0, reason: ""},
+		{name: "too long", token: token, maxLength: 16, reason: credential.MalformedTooLong},
+		{name: "empty", token: "", maxLength: 0, reason: credential.MalformedSegments},
+		{name: "one dot", token: "eyJh.eyJz", maxLength: 0, reason: credential.MalformedSegments},
+		{name: "three dots", token: "eyJh.eyJz.c2ln.c2ln", maxLength: 0, reason: credential.MalformedSegments},
+		{name: "empty header", token: ".eyJz.c2ln", maxLength: 0, reason: credential.MalformedSegments},
+		{name: "empty payload", token: "eyJh..c2ln", maxLength: 0, reason: credential.MalformedSegments},
+		{name: "padding", token: "eyJh.eyJz.c2ln==", maxLength: 0, reason: credential.MalformedAlphabet},
+		{name: "standard alphabet", token: "eyJh.ey+z.c2/n", maxLength: 0, reason: credential.MalformedAlphabet},
+		{name: "space", token: "Bearer eyJh.eyJz.c2ln", maxLength: 0, reason: credential.MalformedAlphabet},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.reason, credential.Check(tc.token, tc.maxLength))
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	t.Parallel()
+
+	token, err := testutil.DriverToken(jwt.SigningMethodHS512, []byte("secret"))
+	require.NoError(t, err)
+
+	segments := strings.Split(token, ".")
+
+	tests := []struct {
+		name       string
+		token      string
+		normalized string
+		changed    bool
+	}{
+		{name: "token", token: token, normalized: token, changed: false},
+		{name: "newlines", token: " " + segments[0] + ".\n" + segments[1] + "\r\n." + segments[2] + "\n", normalized: token, changed: true},
+		{name: "padding", token: "eyJh.eyJz.c2ln==", normalized: "eyJh.eyJz.c2ln", changed: true},
+		{name: "padded segments", token: "eyJhb===.eyJz.c2lu=", normalized: "eyJhb.eyJz.c2lu", changed: true},
+		{name: "standard alphabet", token: "eyJh.ey+z.c2/n", normalized: "eyJh.ey-z.c2_n", changed: true},
+		// padding in the middle of segments is not removed, so the token stays malformed.
+		{name: "inner padding", token: "eyJh.ey=z.c2ln", normalized: "eyJh.ey=z.c2ln", changed: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			normalized, changed := credential.Normalize(tc.token)
+			require.Equal(t, tc.normalized, normalized)
+			require.Equal(t, tc.changed, changed)
+		})
+	}
+}
+
+func TestCheckAllocations(t *testing.T) {
+	garbage := strings.Repeat("x%", 64)
+
+	require.Zero(t, testing.AllocsPerRun(100, func() {
+		credential.Check(garbage, credential.DefaultMaxLength)
+	}))
+}
+
+func BenchmarkCheckGarbage(b *testing.B) {
+	// scanners send random strings which are shorter than tokens.
+	garbage := "aGVsbG8gd29ybGQgaGVsbG8gd29ybGQgaGVsbG8gd29ybGQgaGVsbG8gd29ybGQ"
+
+	b.ReportAllocs()
+
+	for range b.N {
+		credential.Check(garbage, credential.DefaultMaxLength)
+	}
+}
+
+func BenchmarkCheckToken(b *testing.B) {
+	token, err := testutil.DriverToken(jwt.SigningMethodHS512, []byte("secret"))
+	require.NoError(b, err)
+
+	b.ReportAllocs()
+
+	for range b.N {
+		credential.Check(token, credential.DefaultMaxLength)
+	}
+}
@@ -3,6 +3,7 @@ package errors
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/snapp-incubator/soteria/pkg/acl"
 )
@@ -17,6 +18,53 @@ var (
 	ErrDecodeHashID         = errors.New("could not decode hash id")
 	ErrInvalidSecret        = errors.New("invalid secret")
 	ErrIncorrectPassword    = errors.New("username or password is wrong")
+	ErrPostAuthorizeDenied  = errors.New("post authorize webhook denied the access")
+	ErrPostAuthorizeFailed  = errors.New("post authorize webhook failed")
+	ErrInvalidStaticClient  = errors.New("invalid static client")
+	ErrStateCheckDenied     = errors.New("state check denied the access")
+	ErrStateCheckFailed     = errors.New("state check failed")
+	ErrUnknownIssuer        = errors.New("token issuer is not known by any vendor of resolution")
+	ErrUnexpectedMountpoint = errors.New("mountpoint is not allowed for the vendor")
+	ErrEmptyCredentials     = errors.New("credentials are empty and anonymous clients are not allowed")
+	ErrMissingExpiry        = errors.New("token has no exp claim and its subject is not allowed to omit it")
+	ErrUnverifiedToken      = errors.New("acl token is not verified by keys, auth or broker secret")
+	ErrInvalidSubjectFormat = errors.New("subject doesn't have the subject format of its issuer")
+	ErrPolicyFailed         = errors.New("policy cannot be evaluated")
+
+	ErrInvalidVerificationKey = errors.New("verification key should have a unique kid")
+)
+
+const (
+	// ReasonNoAccess means client has no access on the topic at all.
+	ReasonNoAccess = "no_access"
+	// ReasonSubscribeOnly means client published on a topic which it can only subscribe.
+	ReasonSubscribeOnly = "subscribe_only"
+	// ReasonPublishOnly means client subscribed on a topic which it can only publish.
+	ReasonPublishOnly = "publish_only"
+	// ReasonMissingField means topic template references a field which client doesn't have.
+	ReasonMissingField = "missing_field"
+	// ReasonTemplateFailed means topic template execution failed, e.g. one of its functions failed.
+	ReasonTemplateFailed = "template_failed"
+	// ReasonUnknownIssuer means none of the vendors in resolution knows the token issuer.
+	ReasonUnknownIssuer = "unknown_issuer"
+	// ReasonUnexpectedMountpoint means topic has a mountpoint which is not allowed for the vendor.
+	ReasonUnexpectedMountpoint = "unexpected_mountpoint"
+	// ReasonSubscriptionLimitExceeded means client subscribed to more topics of the type than its limit.
+	ReasonSubscriptionLimitExceeded = "subscription_limit_exceeded"
+	// ReasonEmptyCredentials means client has empty credentials and anonymous clients are not allowed.
+	ReasonEmptyCredentials = "empty_credentials"
+	// ReasonRideMismatch means topic belongs to a ride which is not the ride of client.
+	ReasonRideMismatch = "ride_mismatch"
+	// ReasonInvalidTopicField means a field of topic doesn't have one of its allowed values.
+	ReasonInvalidTopicField = "invalid_topic_field"
+	// ReasonPayloadMismatch means a field of published payload doesn't match its topic or client.
+	ReasonPayloadMismatch = "payload_mismatch"
+	// ReasonPolicyDenied means the policy of vendor denied the access without its own reason.
+	ReasonPolicyDenied = "policy_denied"
+	// ReasonMalformedCredential means the credential of client is not a compact JWT, so it is not parsed.
+	ReasonMalformedCredential = "malformed_credential"
+	// ReasonPayloadTooLarge means the published payload exceeds the max_payload_bytes of its topic.
+	ReasonPayloadTooLarge = "payload_too_large"
 )
 
 type TopicNotAllowedError struct {
@@ -25,11 +73,42 @@ type TopicNotAllowedError struct {
 	AccessType acl.AccessType
 	Topic      string
 	TopicType  string
+	// Granted is the effective access of client on the topic.
+	Granted acl.AccessType
+}
+
+// GrantedAccesses returns the accesses which client has on the topic.
+func (err TopicNotAllowedError) GrantedAccesses() []acl.AccessType {
+	return err.Granted.Grants()
+}
+
+// HasAnyAccess checks client has any access on the topic.
+func (err TopicNotAllowedError) HasAnyAccess() bool {
+	return len(err.GrantedAccesses()) > 0
+}
+
+// Reason returns a machine-readable reason which differentiates
+// publishing on subscribe-only topics from subscribing on publish-only topics.
+func (err TopicNotAllowedError) Reason() string {
+	switch err.Granted { //nolint:exhaustive
+	case acl.Sub:
+		return ReasonSubscribeOnly
+	case acl.Pub:
+		return ReasonPublishOnly
+	}
+
+	return ReasonNoAccess
 }
 
 func (err TopicNotAllowedError) Error() string {
-	return fmt.Sprintf("issuer %s with sub %s is not allowed to %s on topic %s (%s)",
-		err.Issuer, err.Sub, err.AccessType, err.Topic, err.TopicType,
+	if !err.HasAnyAccess() {
+		return fmt.Sprintf("issuer %s with sub %s is not allowed to %s on topic %s (%s), it has no access on topic",
+			err.Issuer, err.Sub, err.AccessType, err.Topic, err.TopicType,
+		)
+	}
+
+	return fmt.Sprintf("issuer %s with sub %s is not allowed to %s on topic %s (%s), it can only %s",
+		err.Issuer, err.Sub, err.AccessType, err.Topic, err.TopicType, err.Granted,
 	)
 }
 
@@ -48,3 +127,256 @@ type InvalidTopicError struct {
 func (err InvalidTopicError) Error() string {
 	return fmt.Sprintf("provided topic %s is not valid", err.Topic)
 }
+
+type PayloadTooLargeError struct {
+	Topic     string
+	TopicType string
+	Size      int
+	MaxSize   int
+}
+
+func (err PayloadTooLargeError) Error() string {
+	return fmt.Sprintf("payload of %d bytes on topic %s (%s) exceeds the %d bytes limit",
+		err.Size, err.Topic, err.TopicType, err.MaxSize,
+	)
+}
+
+// Reason returns the machine-readable reason of the denial.
+func (err PayloadTooLargeError) Reason() string {
+	return ReasonPayloadTooLarge
+}
+
+type InvalidTopicAccessError struct {
+	TopicType string
+	Issuer    string
+	Access    acl.AccessType
+}
+
+func (err InvalidTopicAccessError) Error() string {
+	return fmt.Sprintf("topic %s has unknown access type %q for %s", err.TopicType, string(err.Access), err.Issuer)
+}
+
+// TemplateRenderError means topic template of the given type cannot be rendered for the client,
+// Field is the missing field of template when the failure is because of it.
+type TemplateRenderError struct {
+	TopicType string
+	Field     string
+	Err       error
+}
+
+func (err TemplateRenderError) Error() string {
+	if err.Field != "" {
+		return fmt.Sprintf("template %s cannot be rendered because of missing field %s", err.TopicType, err.Field)
+	}
+
+	return fmt.Sprintf("template %s cannot be rendered: %s", err.TopicType, err.Err)
+}
+
+// Reason returns the machine-readable reason of the render failure.
+func (err TemplateRenderError) Reason() string {
+	if err.Field != "" {
+		return ReasonMissingField
+	}
+
+	return ReasonTemplateFailed
+}
+
+func (err TemplateRenderError) Unwrap() error {
+	return err.Err
+}
+
+const (
+	// AccessTypeLevelVendor means the access type is rejected by the allowed access types of vendor.
+	AccessTypeLevelVendor = "vendor"
+	// AccessTypeLevelTopic means the access type is rejected by the allowed access types of topic.
+	AccessTypeLevelTopic = "topic"
+)
+
+// InvalidAccessTypeError is an ErrInvalidAccessType which names the level that rejected the access type.
+type InvalidAccessTypeError struct {
+	Level      string
+	AccessType acl.AccessType
+	TopicType  string
+}
+
+func (err InvalidAccessTypeError) Error() string {
+	if err.Level == AccessTypeLevelTopic {
+		return fmt.Sprintf("%s: %q is not allowed on topic %s", ErrInvalidAccessType, err.AccessType, err.TopicType)
+	}
+
+	return fmt.Sprintf("%s: %q is not allowed for vendor", ErrInvalidAccessType, err.AccessType)
+}
+
+func (err InvalidAccessTypeError) Is(target error) bool {
+	return target == ErrInvalidAccessType //nolint: errorlint, err113
+}
+
+type BudgetExceededError struct {
+	Stage     string
+	TopicType string
+	Deadline  time.Duration
+}
+
+func (err BudgetExceededError) Error() string {
+	return fmt.Sprintf("request exceeded its %s budget on %s stage", err.Deadline, err.Stage)
+}
+
+type MalformedTopicError struct {
+	Reason string
+	Length int
+}
+
+func (err MalformedTopicError) Error() string {
+	return fmt.Sprintf("topic with %d bytes is malformed: %s", err.Length, err.Reason)
+}
+
+type MalformedCredentialError struct {
+	Reason string
+	Length int
+	// Err is the decoding error of token, its message is not in the error because it can have token bytes.
+	Err error
+}
+
+func (err MalformedCredentialError) Error() string {
+	return fmt.Sprintf("credential with %d bytes is malformed: %s", err.Length, err.Reason)
+}
+
+func (err MalformedCredentialError) Unwrap() error {
+	return err.Err
+}
+
+// IATSkewCode is the code of tokens which are rejected because of their issued at time
+// is in the future of validator clock.
+const IATSkewCode = "IATSkew"
+
+type IATSkewError struct {
+	Issuer   string
+	IssuedAt time.Time
+	Err      error
+}
+
+func (err IATSkewError) Error() string {
+	return fmt.Sprintf("%s: freshly minted token of issuer %s (iat %s) is rejected by validator: %s",
+		IATSkewCode, err.Issuer, err.IssuedAt.Format(time.RFC3339), err.Err,
+	)
+}
+
+func (err IATSkewError) Unwrap() error {
+	return err.Err
+}
+
+// RideMismatchError means the ride segment of topic is not the ride claim of client
+// or client doesn't have the ride claim.
+type RideMismatchError struct {
+	TopicType string
+	Issuer    string
+	Sub       string
+	Claim     string
+	// Missing is true when token doesn't have the ride claim.
+	Missing bool
+}
+
+func (err RideMismatchError) Error() string {
+	if err.Missing {
+		return fmt.Sprintf("%s of issuer %s has no %s claim for the topic of %s", err.Sub, err.Issuer, err.Claim, err.TopicType)
+	}
+
+	return fmt.Sprintf("topic of %s doesn't belong to the %s of %s of issuer %s",
+		err.TopicType, err.Claim, err.Sub, err.Issuer,
+	)
+}
+
+// Reason returns the machine-readable reason of the denial.
+func (err RideMismatchError) Reason() string {
+	return ReasonRideMismatch
+}
+
+// InvalidTopicFieldError means a field of topic, like the node of call entry topics,
+// doesn't have one of its allowed values.
+type InvalidTopicFieldError struct {
+	TopicType string
+	Issuer    string
+	Sub       string
+	Field     string
+	Value     string
+}
+
+func (err InvalidTopicFieldError) Error() string {
+	return fmt.Sprintf("%q is not an allowed %s of topic of %s for %s of issuer %s",
+		err.Value, err.Field, err.TopicType, err.Sub, err.Issuer,
+	)
+}
+
+// Reason returns the machine-readable reason of the denial.
+func (err InvalidTopicFieldError) Reason() string {
+	return ReasonInvalidTopicField
+}
+
+// PayloadMismatchError means a field of the JSON payload of publish, like its sender, is not the value
+// of its claim or topic segment, or the payload cannot be checked because it is malformed.
+type PayloadMismatchError struct {
+	TopicType string
+	Issuer    string
+	Sub       string
+	Path      string
+	// Malformed is true when payload is not a JSON object within the checked size and depth.
+	Malformed bool
+}
+
+func (err PayloadMismatchError) Error() string {
+	if err.Malformed {
+		return fmt.Sprintf("payload of %s of issuer %s on topic of %s cannot be checked", err.Sub, err.Issuer, err.TopicType)
+	}
+
+	return fmt.Sprintf("%s of payload of %s of issuer %s doesn't match the topic of %s",
+		err.Path, err.Sub, err.Issuer, err.TopicType,
+	)
+}
+
+// Reason returns the machine-readable reason of the denial.
+func (err PayloadMismatchError) Reason() string {
+	return ReasonPayloadMismatch
+}
+
+// PolicyDeniedError means the policy of vendor denied the access, its reason is given by the policy.
+type PolicyDeniedError struct {
+	TopicType string
+	Issuer    string
+	Sub       string
+	Cause     string
+}
+
+func (err PolicyDeniedError) Error() string {
+	return fmt.Sprintf("policy denied the topic of %s for %s of issuer %s: %s",
+		err.TopicType, err.Sub, err.Issuer, err.Reason(),
+	)
+}
+
+// Reason returns the reason of policy, or policy_denied when policy has no reason.
+func (err PolicyDeniedError) Reason() string {
+	if err.Cause == "" {
+		return ReasonPolicyDenied
+	}
+
+	return err.Cause
+}
+
+// SubscriptionLimitExceededError means client subscribed to the maximum number of distinct topics
+// of the topic type and the subscription on a new topic is denied.
+type SubscriptionLimitExceededError struct {
+	TopicType string
+	Issuer    string
+	Sub       string
+	Max       int
+}
+
+func (err SubscriptionLimitExceededError) Error() string {
+	return fmt.Sprintf("%s of issuer %s has subscribed to %d topics of %s which is its limit",
+		err.Sub, err.Issuer, err.Max, err.TopicType,
+	)
+}
+
+// Reason returns the machine-readable reason of the denial.
+func (err SubscriptionLimitExceededError) Reason() string {
+	return ReasonSubscriptionLimitExceeded
+}
@@ -0,0 +1,246 @@
+// Package failratio tracks the authentication failure ratio of each vendor and issuer
+// in a sliding window, so failures of a single issuer (e.g. after an unannounced key rotation)
+// are not lost in the aggregated metrics.
+package failratio
+
+import (
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/metric"
+	"go.uber.org/zap"
+)
+
+const (
+	// UnknownIssuer groups the failures of tokens without a known issuer, so arbitrary issuers
+	// of unverified tokens cannot grow the tracked issuers.
+	UnknownIssuer = "-"
+
+	DefaultWindow = time.Minute
+
+	// buckets is the number of buckets in the window, the window slides bucket by bucket.
+	buckets = 6
+	// shards spread the counters of each bucket, so concurrent requests of an issuer
+	// don't contend on the same counter.
+	shards = 8
+	// cacheLine is used to pad the shards.
+	cacheLine = 64
+)
+
+type Config struct {
+	// Threshold is the failure ratio which logs a warning, zero disables the warnings.
+	Threshold float64       `json:"threshold,omitempty"     koanf:"threshold"`
+	Window    time.Duration `json:"window,omitempty"        koanf:"window"`
+	// MinRequests is the number of requests in the window which is required before warning,
+	// so a few failures of a quiet issuer don't warn.
+	MinRequests int64 `json:"min_requests,omitempty" koanf:"min_requests"`
+	// WarnInterval is the minimum interval between the warnings of each issuer.
+	WarnInterval time.Duration `json:"warn_interval,omitempty" koanf:"warn_interval"`
+}
+
+type key struct {
+	vendor string
+	issuer string
+}
+
+type shard struct {
+	total  atomic.Int64
+	failed atomic.Int64
+	_      [cacheLine - 16]byte
+}
+
+type bucket struct {
+	epoch  atomic.Int64
+	shards [shards]shard
+
+	// reasons are only updated on failures.
+	lock    sync.Mutex
+	reasons map[string]int64
+}
+
+type window struct {
+	buckets  [buckets]bucket
+	lastWarn atomic.Int64
+}
+
+// Tracker keeps the sliding windows of issuers, it is safe for concurrent use and nil tracker
+// doesn't track anything.
+type Tracker struct {
+	cfg     Config
+	width   int64
+	windows sync.Map
+	metrics *metric.FailureRatioMetrics
+	logger  *zap.Logger
+}
+
+func New(cfg Config, logger *zap.Logger) *Tracker {
+	// window is divided into buckets, so it cannot be shorter than them.
+	if cfg.Window < buckets {
+		cfg.Window = DefaultWindow
+	}
+
+	return &Tracker{
+		cfg:     cfg,
+		width:   int64(cfg.Window) / buckets,
+		windows: sync.Map{},
+		metrics: metric.NewFailureRatioMetrics(prometheus.DefaultRegisterer),
+		logger:  logger,
+	}
+}
+
+// Record adds the result of an authentication to the window of issuer.
+func (t *Tracker) Record(vendor, issuer string, err error) {
+	if t == nil {
+		return
+	}
+
+	now := time.Now().UnixNano() / t.width
+
+	w := t.window(key{vendor: vendor, issuer: issuer})
+	b := w.bucket(now)
+	s := &b.shards[rand.IntN(shards)] // nolint: gosec
+
+	s.total.Add(1)
+
+	if err != nil {
+		s.failed.Add(1)
+
+		reason := metric.Status(err)
+
+		b.lock.Lock()
+		b.reasons[reason]++
+		b.lock.Unlock()
+	}
+
+	total, failed := w.counts(now)
+
+	// a concurrent bucket reset can clear the recorded request.
+	if total == 0 {
+		return
+	}
+
+	ratio := float64(failed) / float64(total)
+
+	t.metrics.Ratio(vendor, issuer, ratio)
+
+	if t.cfg.Threshold <= 0 || total < t.cfg.MinRequests || ratio < t.cfg.Threshold {
+		return
+	}
+
+	if last := w.lastWarn.Load(); time.Since(time.Unix(0, last)) < t.cfg.WarnInterval ||
+		!w.lastWarn.CompareAndSwap(last, time.Now().UnixNano()) {
+		return
+	}
+
+	t.logger.Warn("authentication failure ratio crossed the threshold",
+		zap.String("vendor", vendor),
+		zap.String("issuer", issuer),
+		zap.Float64("ratio", ratio),
+		zap.Float64("threshold", t.cfg.Threshold),
+		zap.Int64("total", total),
+		zap.Int64("failed", failed),
+		zap.Any("reasons", w.reasons(now)),
+	)
+}
+
+// Ratio returns the failure ratio of issuer in the current window, it is zero without requests.
+func (t *Tracker) Ratio(vendor, issuer string) float64 {
+	if t == nil {
+		return 0
+	}
+
+	v, ok := t.windows.Load(key{vendor: vendor, issuer: issuer})
+	if !ok {
+		return 0
+	}
+
+	total, failed := v.(*window).counts(time.Now().UnixNano() / t.width) //nolint: forcetypeassert
+
+	if total == 0 {
+		return 0
+	}
+
+	return float64(failed) / float64(total)
+}
+
+func (t *Tracker) window(k key) *window {
+	if v, ok := t.windows.Load(k); ok {
+		return v.(*window) //nolint: forcetypeassert
+	}
+
+	// nolint: exhaustruct
+	w := new(window)
+
+	for i := range w.buckets {
+		w.buckets[i].reasons = make(map[string]int64)
+	}
+
+	v, _ := t.windows.LoadOrStore(k, w)
+
+	return v.(*window) //nolint: forcetypeassert
+}
+
+// bucket returns the bucket of the given epoch, buckets of the previous windows are reset
+// before being reused.
+func (w *window) bucket(epoch int64) *bucket {
+	b := &w.buckets[epoch%buckets]
+
+	if old := b.epoch.Load(); old != epoch && b.epoch.CompareAndSwap(old, epoch) {
+		for i := range b.shards {
+			b.shards[i].total.Store(0)
+			b.shards[i].failed.Store(0)
+		}
+
+		b.lock.Lock()
+		clear(b.reasons)
+		b.lock.Unlock()
+	}
+
+	return b
+}
+
+// counts sums the counters of the buckets which are in the window ending at the given epoch.
+func (w *window) counts(epoch int64) (int64, int64) {
+	var total, failed int64
+
+	for i := range w.buckets {
+		b := &w.buckets[i]
+
+		if epoch-b.epoch.Load() >= buckets {
+			continue
+		}
+
+		for j := range b.shards {
+			total += b.shards[j].total.Load()
+			failed += b.shards[j].failed.Load()
+		}
+	}
+
+	return total, failed
+}
+
+// reasons merges the failure reasons of the buckets in the window ending at the given epoch.
+func (w *window) reasons(epoch int64) map[string]int64 {
+	reasons := make(map[string]int64)
+
+	for i := range w.buckets {
+		b := &w.buckets[i]
+
+		if epoch-b.epoch.Load() >= buckets {
+			continue
+		}
+
+		b.lock.Lock()
+
+		for reason, count := range b.reasons {
+			reasons[reason] += count
+		}
+
+		b.lock.Unlock()
+	}
+
+	return reasons
+}
@@ -0,0 +1,115 @@
+package failratio_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	serrors "github.com/snapp-incubator/soteria/internal/errors"
+	"github.com/snapp-incubator/soteria/internal/failratio"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRatio(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	// nolint: exhaustruct
+	tracker := failratio.New(failratio.Config{Window: time.Minute}, zap.NewNop())
+
+	require.Zero(tracker.Ratio("snapp", "0"))
+
+	var wg sync.WaitGroup
+
+	for i := range 100 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if i%4 == 0 {
+				tracker.Record("snapp", "0", serrors.ErrInvalidSigningMethod)
+
+				return
+			}
+
+			tracker.Record("snapp", "0", nil)
+		}()
+	}
+
+	wg.Wait()
+
+	require.InDelta(0.25, tracker.Ratio("snapp", "0"), 0.0001)
+	require.Zero(tracker.Ratio("snapp", "1"))
+	require.Zero(tracker.Ratio("other", "0"))
+
+	var nilTracker *failratio.Tracker
+
+	nilTracker.Record("snapp", "0", nil)
+	require.Zero(nilTracker.Ratio("snapp", "0"))
+}
+
+func TestRatioWindow(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	// nolint: exhaustruct
+	tracker := failratio.New(failratio.Config{Window: 60 * time.Millisecond}, zap.NewNop())
+
+	tracker.Record("snapp", "0", errors.ErrUnsupported)
+	require.InDelta(1.0, tracker.Ratio("snapp", "0"), 0.0001)
+
+	time.Sleep(100 * time.Millisecond)
+
+	require.Zero(tracker.Ratio("snapp", "0"))
+
+	tracker.Record("snapp", "0", nil)
+	require.Zero(tracker.Ratio("snapp", "0"))
+}
+
+func TestThresholdWarning(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	core, logs := observer.New(zapcore.WarnLevel)
+
+	tracker := failratio.New(failratio.Config{
+		Threshold:    0.5,
+		Window:       time.Minute,
+		MinRequests:  10,
+		WarnInterval: time.Minute,
+	}, zap.New(core))
+
+	// below minimum requests.
+	for range 5 {
+		tracker.Record("snapp", "0", serrors.ErrInvalidSigningMethod)
+	}
+
+	require.Zero(logs.Len())
+
+	for range 5 {
+		tracker.Record("snapp", "0", &serrors.KeyNotFoundError{Issuer: "0"})
+	}
+
+	// warnings are rate-limited.
+	for range 10 {
+		tracker.Record("snapp", "0", nil)
+	}
+
+	require.Equal(1, logs.Len())
+
+	entry := logs.All()[0]
+
+	require.Equal("0", entry.ContextMap()["issuer"])
+	require.Equal(map[string]int64{
+		"err_invalid_signing_method": 5,
+		"key_not_found_error":        5,
+	}, entry.ContextMap()["reasons"])
+}
@@ -0,0 +1,173 @@
+// Package flags provides runtime feature flags of vendors. Values are read from the global
+// features configuration and are overridden by the features of each vendor.
+package flags
+
+import (
+	"maps"
+	"slices"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// EmitClientAttrs returns entity, hash-id and vendor of the authenticated clients to EMQ.
+	EmitClientAttrs = "emit_client_attrs"
+	// EmitTopicType returns the matched topic type and entity of the allowed ACL requests to EMQ.
+	EmitTopicType = "emit_topic_type"
+	// EmitExpireAt returns the expiry of token of the authenticated clients to EMQ, which disconnects them at it.
+	EmitExpireAt = "emit_expire_at"
+	// LenientTokenParsing normalizes the tokens with whitespaces, padded segments or standard base64 alphabet
+	// before they are checked and parsed.
+	LenientTokenParsing = "lenient_token_parsing"
+	// AuthFailureStatus responds the failed authentications with 401 or 403 instead of 200, which EMQ expects.
+	AuthFailureStatus = "auth_failure_status"
+	// NormalizeTopics collapses the duplicate slashes and strips the trailing slash of topics before matching.
+	NormalizeTopics = "normalize_topics"
+)
+
+// defaults are the safe values of flags which are used when they are not configured.
+// nolint: gochecknoglobals
+var defaults = map[string]bool{
+	EmitClientAttrs: false,
+	EmitTopicType:   false,
+	EmitExpireAt:    false,
+	// tokens are strict by default, because normalization hides the bugs of clients.
+	LenientTokenParsing: false,
+	// brokers treat the non-200 responses as ignore, so only the vendors of REST clients enable it.
+	AuthFailureStatus: false,
+	// topics are matched as they are sent by default, so only the vendors of devices with broken firmware enable it.
+	NormalizeTopics: false,
+}
+
+// Names returns the valid flag names.
+func Names() []string {
+	return slices.Sorted(maps.Keys(defaults))
+}
+
+type values struct {
+	global  map[string]bool
+	vendors map[string]map[string]bool
+}
+
+// Flags holds the flag values, values can be reloaded at runtime while they are being read.
+type Flags struct {
+	values atomic.Pointer[values]
+	logger *zap.Logger
+}
+
+func New(logger *zap.Logger) *Flags {
+	f := &Flags{
+		values: atomic.Pointer[values]{},
+		logger: logger,
+	}
+
+	f.values.Store(&values{
+		global:  map[string]bool{},
+		vendors: map[string]map[string]bool{},
+	})
+
+	return f
+}
+
+// Load replaces the flag values, unknown flag names are reported and ignored.
+func (f *Flags) Load(global map[string]bool, vendors map[string]map[string]bool) {
+	v := &values{
+		global:  f.known("", global),
+		vendors: make(map[string]map[string]bool, len(vendors)),
+	}
+
+	for vendor, features := range vendors {
+		v.vendors[vendor] = f.known(vendor, features)
+	}
+
+	f.values.Store(v)
+}
+
+func (f *Flags) known(vendor string, features map[string]bool) map[string]bool {
+	result := make(map[string]bool, len(features))
+
+	for name, value := range features {
+		if _, ok := defaults[name]; !ok {
+			f.logger.Warn("unknown feature flag is ignored",
+				zap.String("name", name),
+				zap.String("vendor", vendor),
+				zap.Strings("valid-names", Names()),
+			)
+
+			continue
+		}
+
+		result[name] = value
+	}
+
+	return result
+}
+
+// Enabled returns the flag value of vendor, nil flags return the default values.
+func (f *Flags) Enabled(vendor, name string) bool {
+	if f == nil {
+		return defaults[name]
+	}
+
+	v := f.values.Load()
+
+	if value, ok := v.vendors[vendor][name]; ok {
+		return value
+	}
+
+	if value, ok := v.global[name]; ok {
+		return value
+	}
+
+	return defaults[name]
+}
+
+// EmitClientAttrs returns client attributes on successful authentication.
+func (f *Flags) EmitClientAttrs(vendor string) bool {
+	return f.Enabled(vendor, EmitClientAttrs)
+}
+
+// EmitTopicType returns topic type and entity on allowed ACL requests.
+func (f *Flags) EmitTopicType(vendor string) bool {
+	return f.Enabled(vendor, EmitTopicType)
+}
+
+// EmitExpireAt returns the expiry of token on successful authentication.
+func (f *Flags) EmitExpireAt(vendor string) bool {
+	return f.Enabled(vendor, EmitExpireAt)
+}
+
+// LenientTokenParsing normalizes the tokens of embedded clients before they are parsed.
+func (f *Flags) LenientTokenParsing(vendor string) bool {
+	return f.Enabled(vendor, LenientTokenParsing)
+}
+
+// AuthFailureStatus responds the failed authentications with their status code.
+func (f *Flags) AuthFailureStatus(vendor string) bool {
+	return f.Enabled(vendor, AuthFailureStatus)
+}
+
+// NormalizeTopics normalizes the topics of vendor before they are matched.
+func (f *Flags) NormalizeTopics(vendor string) bool {
+	return f.Enabled(vendor, NormalizeTopics)
+}
+
+// List returns the effective values of all flags per vendor.
+func (f *Flags) List() map[string]map[string]bool {
+	result := make(map[string]map[string]bool)
+
+	if f == nil {
+		return result
+	}
+
+	for vendor := range f.values.Load().vendors {
+		result[vendor] = make(map[string]bool, len(defaults))
+
+		for _, name := range Names() {
+			result[vendor][name] = f.Enabled(vendor, name)
+		}
+	}
+
+	return result
+}
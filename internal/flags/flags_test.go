@@ -0,0 +1,73 @@
+package flags_test
+
+import (
+	"testing"
+
+	"github.com/snapp-incubator/soteria/internal/flags"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestFlags(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	core, logs := observer.New(zap.WarnLevel)
+
+	f := flags.New(zap.New(core))
+
+	require.False(f.EmitClientAttrs("snapp"))
+	require.False(f.EmitTopicType("snapp"))
+
+	f.Load(map[string]bool{flags.EmitClientAttrs: true, "wildcard": true}, map[string]map[string]bool{
+		"snapp": {flags.EmitClientAttrs: false},
+		"tapsi": {},
+	})
+
+	require.False(f.EmitClientAttrs("snapp"))
+	require.True(f.EmitClientAttrs("tapsi"))
+	require.True(f.EmitClientAttrs("unknown"))
+
+	require.Equal(1, logs.FilterMessage("unknown feature flag is ignored").Len())
+
+	require.Equal(map[string]map[string]bool{
+		"snapp": {
+			flags.EmitClientAttrs:     false,
+			flags.EmitTopicType:       false,
+			flags.EmitExpireAt:        false,
+			flags.LenientTokenParsing: false,
+			flags.AuthFailureStatus:   false,
+			flags.NormalizeTopics:     false,
+		},
+		"tapsi": {
+			flags.EmitClientAttrs:     true,
+			flags.EmitTopicType:       false,
+			flags.EmitExpireAt:        false,
+			flags.LenientTokenParsing: false,
+			flags.AuthFailureStatus:   false,
+			flags.NormalizeTopics:     false,
+		},
+	}, f.List())
+
+	// reload replaces all values.
+	f.Load(map[string]bool{}, map[string]map[string]bool{})
+
+	require.False(f.EmitClientAttrs("tapsi"))
+}
+
+func TestNilFlags(t *testing.T) {
+	t.Parallel()
+
+	var f *flags.Flags
+
+	require.False(t, f.EmitClientAttrs("snapp"))
+	require.Empty(t, f.List())
+	require.Contains(t, flags.Names(), flags.EmitClientAttrs)
+	require.Contains(t, flags.Names(), flags.EmitTopicType)
+	require.Contains(t, flags.Names(), flags.EmitExpireAt)
+	require.Contains(t, flags.Names(), flags.LenientTokenParsing)
+	require.Contains(t, flags.Names(), flags.AuthFailureStatus)
+	require.Contains(t, flags.Names(), flags.NormalizeTopics)
+}
@@ -0,0 +1,299 @@
+// Package hashring selects the validator replica of tokens by consistent hashing, so the same token keeps
+// hitting the same replica and its warm cache, e.g. when replicas run in multiple datacenters.
+// Endpoints are health-checked and unhealthy endpoints are ejected from the ring, so their tokens fail over
+// to the next healthy endpoint of the ring and the tokens of the other endpoints don't move. Tokens which cannot
+// connect to their endpoint are retried on the next endpoints before it is ejected.
+package hashring
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/metric"
+	"github.com/snapp-incubator/soteria/pkg/validator"
+	"go.uber.org/zap"
+)
+
+const (
+	// Replicas is the number of virtual nodes of each endpoint, which spread the tokens evenly.
+	Replicas = 128
+
+	DefaultHealthPath       = "/healthz"
+	DefaultHealthInterval   = 5 * time.Second
+	DefaultHealthTimeout    = time.Second
+	DefaultFailureThreshold = 2
+)
+
+var ErrNoEndpoints = errors.New("validator ring has no endpoints")
+
+type HealthCheck struct {
+	// Path is requested on each endpoint and endpoints are healthy when it returns 2xx.
+	Path     string        `json:"path,omitempty"     koanf:"path"`
+	Interval time.Duration `json:"interval,omitempty" koanf:"interval"`
+	Timeout  time.Duration `json:"timeout,omitempty"  koanf:"timeout"`
+	// FailureThreshold is the number of consecutive failed checks which eject an endpoint,
+	// ejected endpoints are added back on their first successful check.
+	FailureThreshold int `json:"failure_threshold,omitempty" koanf:"failure_threshold"`
+}
+
+// Endpoint is a validator replica.
+type Endpoint struct {
+	URL     string
+	client  validator.Client
+	healthy atomic.Bool
+	// failures is the number of consecutive failed checks, it is only used by the health checks.
+	failures int
+}
+
+// Healthy reports whether the endpoint is in the ring.
+func (e *Endpoint) Healthy() bool {
+	return e.healthy.Load()
+}
+
+type point struct {
+	hash     uint64
+	endpoint int
+}
+
+// Ring is safe for concurrent use, its health checks run in their own goroutine.
+type Ring struct {
+	endpoints []*Endpoint
+	points    []point
+	cfg       HealthCheck
+	client    *http.Client
+	metrics   *metric.ValidatorEndpointMetrics
+	logger    *zap.Logger
+}
+
+// New creates the ring of validator endpoints, all endpoints are healthy until they are checked.
+func New(urls []string, timeout time.Duration, cfg HealthCheck, logger *zap.Logger) *Ring {
+	if cfg.Path == "" {
+		cfg.Path = DefaultHealthPath
+	}
+
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultHealthInterval
+	}
+
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultHealthTimeout
+	}
+
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = DefaultFailureThreshold
+	}
+
+	r := &Ring{
+		endpoints: make([]*Endpoint, 0, len(urls)),
+		points:    make([]point, 0, len(urls)*Replicas),
+		cfg:       cfg,
+		client:    new(http.Client),
+		metrics:   metric.NewValidatorEndpointMetrics(prometheus.DefaultRegisterer),
+		logger:    logger,
+	}
+
+	for i, url := range urls {
+		// nolint: exhaustruct
+		endpoint := &Endpoint{
+			URL:    url,
+			client: validator.New(url, timeout),
+		}
+		endpoint.healthy.Store(true)
+
+		r.endpoints = append(r.endpoints, endpoint)
+		r.metrics.Healthy(url, true)
+
+		for replica := range Replicas {
+			r.points = append(r.points, point{hash: hash(fmt.Sprintf("%s#%d", url, replica)), endpoint: i})
+		}
+	}
+
+	slices.SortFunc(r.points, func(a, b point) int {
+		switch {
+		case a.hash < b.hash:
+			return -1
+		case a.hash > b.hash:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	return r
+}
+
+// Endpoints returns the endpoints of ring.
+func (r *Ring) Endpoints() []*Endpoint {
+	return r.endpoints
+}
+
+// Pick returns the endpoint of token, which is the first healthy endpoint of ring after the token hash.
+// Token fails over to the next endpoint of ring when its endpoint is ejected, and it is the preferred
+// endpoint of token when all endpoints are ejected.
+func (r *Ring) Pick(token string) *Endpoint {
+	candidates := r.candidates(token)
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	return candidates[0]
+}
+
+// candidates returns the endpoints of token in the order of ring after the token hash,
+// healthy endpoints are before the ejected ones.
+func (r *Ring) candidates(token string) []*Endpoint {
+	if len(r.points) == 0 {
+		return nil
+	}
+
+	h := hash(token)
+
+	start, _ := slices.BinarySearchFunc(r.points, h, func(p point, h uint64) int {
+		switch {
+		case p.hash < h:
+			return -1
+		case p.hash > h:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	seen := make([]bool, len(r.endpoints))
+	healthy := make([]*Endpoint, 0, len(r.endpoints))
+	ejected := make([]*Endpoint, 0)
+
+	for i := 0; i < len(r.points) && len(healthy)+len(ejected) < len(r.endpoints); i++ {
+		index := r.points[(start+i)%len(r.points)].endpoint
+		if seen[index] {
+			continue
+		}
+
+		seen[index] = true
+
+		if e := r.endpoints[index]; e.Healthy() {
+			healthy = append(healthy, e)
+		} else {
+			ejected = append(ejected, e)
+		}
+	}
+
+	return append(healthy, ejected...)
+}
+
+// Validate validates the token by its endpoint, bearer token has the bearer keyword.
+// Connection errors are retried on the next endpoints of ring, so tokens fail over
+// before the health checks eject their endpoint.
+func (r *Ring) Validate(ctx context.Context, headers http.Header, bearerToken string) error {
+	err := ErrNoEndpoints
+
+	for _, endpoint := range r.candidates(bearerToken) {
+		start := time.Now()
+		err = endpoint.client.Validate(ctx, headers, bearerToken)
+		r.metrics.Latency(endpoint.URL, time.Since(start).Seconds(), err)
+
+		if !connectionError(err) || ctx.Err() != nil {
+			return err //nolint: wrapcheck
+		}
+
+		r.logger.Warn("validator endpoint is not reachable, trying the next endpoint of ring",
+			zap.String("endpoint", endpoint.URL),
+			zap.Error(err),
+		)
+	}
+
+	return err //nolint: wrapcheck
+}
+
+// connectionError reports whether the request is failed before it reaches the endpoint, e.g. connection is refused,
+// so it can be retried on another endpoint without sending the token twice to a replica.
+func connectionError(err error) bool {
+	var opErr *net.OpError
+
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// Start checks the health of endpoints every interval until context is done.
+func (r *Ring) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(r.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.Check(ctx)
+			}
+		}
+	}()
+}
+
+// Check checks the health of endpoints once, endpoints are ejected after failure threshold consecutive
+// failed checks and they are added back on their first successful check.
+func (r *Ring) Check(ctx context.Context) {
+	for _, endpoint := range r.endpoints {
+		err := r.check(ctx, endpoint)
+		if err == nil {
+			endpoint.failures = 0
+
+			if !endpoint.healthy.Swap(true) {
+				r.logger.Info("validator endpoint is healthy and added back to the ring", zap.String("endpoint", endpoint.URL))
+				r.metrics.Healthy(endpoint.URL, true)
+			}
+
+			continue
+		}
+
+		endpoint.failures++
+
+		if endpoint.failures >= r.cfg.FailureThreshold && endpoint.healthy.Swap(false) {
+			r.logger.Warn("validator endpoint is unhealthy and ejected from the ring",
+				zap.String("endpoint", endpoint.URL),
+				zap.Int("failures", endpoint.failures),
+				zap.Error(err),
+			)
+			r.metrics.Healthy(endpoint.URL, false)
+		}
+	}
+}
+
+func (r *Ring) check(ctx context.Context, endpoint *Endpoint) error {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.URL+r.cfg.Path, nil)
+	if err != nil {
+		return fmt.Errorf("cannot create request %w", err)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending request failed %w", err)
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode) // nolint: err113
+	}
+
+	return nil
+}
+
+// hash uses sha256 because fnv doesn't spread the similar names of virtual nodes on the ring.
+func hash(s string) uint64 {
+	sum := sha256.Sum256([]byte(s))
+
+	return binary.BigEndian.Uint64(sum[:8])
+}
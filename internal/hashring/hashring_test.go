@@ -0,0 +1,199 @@
+package hashring_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/snapp-incubator/soteria/internal/hashring"
+	"github.com/snapp-incubator/soteria/pkg/validator"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// replica fakes a validator replica which accepts all tokens while it is healthy.
+type replica struct {
+	healthy atomic.Bool
+	calls   atomic.Int64
+}
+
+func (r *replica) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == hashring.DefaultHealthPath {
+		if !r.healthy.Load() {
+			res.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		return
+	}
+
+	r.calls.Add(1)
+
+	res.Header().Set("X-User-Data", `{"iss": 0}`)
+}
+
+func replicas(t *testing.T, n int) ([]*replica, []string) {
+	t.Helper()
+
+	rs := make([]*replica, 0, n)
+	urls := make([]string, 0, n)
+
+	for range n {
+		r := new(replica)
+		r.healthy.Store(true)
+
+		server := httptest.NewServer(r)
+		t.Cleanup(server.Close)
+
+		rs = append(rs, r)
+		urls = append(urls, server.URL)
+	}
+
+	return rs, urls
+}
+
+func health() hashring.HealthCheck {
+	return hashring.HealthCheck{
+		Path:             hashring.DefaultHealthPath,
+		Interval:         time.Second,
+		Timeout:          time.Second,
+		FailureThreshold: 2,
+	}
+}
+
+func TestPick(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	_, urls := replicas(t, 3)
+
+	ring := hashring.New(urls, time.Second, health(), zap.NewNop())
+
+	picked := make(map[string]int)
+
+	for i := range 300 {
+		token := "bearer token-" + strconv.Itoa(i)
+
+		endpoint := ring.Pick(token)
+
+		// the same token keeps hitting the same endpoint.
+		require.Same(endpoint, ring.Pick(token))
+
+		picked[endpoint.URL]++
+	}
+
+	require.Len(picked, 3)
+
+	for _, url := range urls {
+		require.Greater(picked[url], 30, url)
+	}
+}
+
+// nolint: funlen
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	rs, urls := replicas(t, 3)
+
+	ring := hashring.New(urls, time.Second, health(), zap.NewNop())
+
+	ctx := context.Background()
+	headers := http.Header{validator.ServiceNameHeader: []string{"soteria"}}
+
+	tokens := make([]string, 0, 100)
+	preferred := make(map[string]string)
+
+	for i := range 100 {
+		token := "bearer token-" + strconv.Itoa(i)
+
+		tokens = append(tokens, token)
+		preferred[token] = ring.Pick(token).URL
+	}
+
+	ejected := ring.Endpoints()[0]
+	rs[0].healthy.Store(false)
+
+	// endpoints are ejected after the failure threshold.
+	ring.Check(ctx)
+	require.True(ejected.Healthy())
+
+	ring.Check(ctx)
+	require.False(ejected.Healthy())
+
+	for _, token := range tokens {
+		require.NoError(ring.Validate(ctx, headers, token))
+
+		endpoint := ring.Pick(token)
+		require.NotEqual(ejected.URL, endpoint.URL)
+
+		// tokens of the other endpoints don't move.
+		if preferred[token] != ejected.URL {
+			require.Equal(preferred[token], endpoint.URL)
+		}
+	}
+
+	require.Zero(rs[0].calls.Load())
+	require.Equal(int64(len(tokens)), rs[1].calls.Load()+rs[2].calls.Load())
+
+	// ejected endpoints are added back on their first successful check.
+	rs[0].healthy.Store(true)
+
+	ring.Check(ctx)
+	require.True(ejected.Healthy())
+
+	for _, token := range tokens {
+		require.Equal(preferred[token], ring.Pick(token).URL)
+	}
+
+	// tokens use their preferred endpoint when all endpoints are ejected.
+	for _, r := range rs {
+		r.healthy.Store(false)
+	}
+
+	ring.Check(ctx)
+	ring.Check(ctx)
+
+	for _, token := range tokens {
+		require.Equal(preferred[token], ring.Pick(token).URL)
+	}
+}
+
+func TestConnectionFailover(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	rs, urls := replicas(t, 2)
+
+	// the closed server refuses the connections, but it is in the ring until it is checked.
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	urls = append(urls, closed.URL)
+
+	ring := hashring.New(urls, time.Second, health(), zap.NewNop())
+
+	ctx := context.Background()
+	headers := http.Header{validator.ServiceNameHeader: []string{"soteria"}}
+
+	failed := 0
+
+	for i := range 100 {
+		token := "bearer token-" + strconv.Itoa(i)
+
+		if ring.Pick(token).URL == closed.URL {
+			failed++
+		}
+
+		require.NoError(ring.Validate(ctx, headers, token))
+	}
+
+	require.Positive(failed)
+	require.Equal(int64(100), rs[0].calls.Load()+rs[1].calls.Load())
+}
@@ -0,0 +1,262 @@
+// Package invalidtopic tracks the rate of acl denials because of invalid topics for each vendor and
+// topic type in a sliding window, so client releases which publish or subscribe malformed topics are
+// detected by their warning instead of support tickets. Warnings have the most frequent shapes of
+// the denied topics, where digits and hex runs are collapsed.
+package invalidtopic
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	serrors "github.com/snapp-incubator/soteria/internal/errors"
+	"github.com/snapp-incubator/soteria/internal/metric"
+	"go.uber.org/zap"
+)
+
+const (
+	// Unmatched is the topic type of the denied topics which match no template.
+	Unmatched = "unmatched"
+
+	DefaultWindow  = 5 * time.Minute
+	DefaultTopK    = 5
+	DefaultEpsilon = 0.001
+
+	// buckets is the number of buckets in the window, the window slides bucket by bucket.
+	buckets = 6
+)
+
+type Config struct {
+	// Threshold is the rate of denials per second which logs a warning, zero disables the warnings.
+	Threshold float64       `json:"threshold,omitempty" koanf:"threshold"`
+	Window    time.Duration `json:"window,omitempty"    koanf:"window"`
+	// WarnInterval is the minimum interval between the warnings of each vendor and topic type.
+	WarnInterval time.Duration `json:"warn_interval,omitempty" koanf:"warn_interval"`
+	// TopK is the number of the most frequent topic shapes in warnings.
+	TopK int `json:"top_k,omitempty" koanf:"top_k"`
+	// Epsilon is the error of shape counts relative to the denials of window, shapes are tracked
+	// in about 1/epsilon entries.
+	Epsilon float64 `json:"epsilon,omitempty" koanf:"epsilon"`
+}
+
+type key struct {
+	vendor    string
+	topicType string
+}
+
+type bucket struct {
+	epoch atomic.Int64
+	count atomic.Int64
+}
+
+type window struct {
+	buckets  [buckets]bucket
+	lastWarn atomic.Int64
+
+	// shapes are counted in a tumbling window, they are reset when their window ends.
+	lock        sync.Mutex
+	shapes      *lossyCounter
+	shapesEpoch int64
+}
+
+// Tracker keeps the sliding windows of vendors and topic types, it is safe for concurrent use
+// and nil tracker doesn't track anything.
+type Tracker struct {
+	cfg     Config
+	width   int64
+	windows sync.Map
+	metrics *metric.InvalidTopicMetrics
+	logger  *zap.Logger
+}
+
+func New(cfg Config, logger *zap.Logger) *Tracker {
+	// window is divided into buckets, so it cannot be shorter than them.
+	if cfg.Window < buckets {
+		cfg.Window = DefaultWindow
+	}
+
+	if cfg.TopK <= 0 {
+		cfg.TopK = DefaultTopK
+	}
+
+	if cfg.Epsilon <= 0 || cfg.Epsilon >= 1 {
+		cfg.Epsilon = DefaultEpsilon
+	}
+
+	t := &Tracker{
+		cfg:     cfg,
+		width:   int64(cfg.Window) / buckets,
+		windows: sync.Map{},
+		metrics: nil,
+		logger:  logger,
+	}
+
+	t.metrics = metric.NewInvalidTopicMetrics(prometheus.DefaultRegisterer, t.rates)
+
+	return t
+}
+
+// TopicType returns the topic type of invalid topic denials, which is unmatched for the topics
+// without template, and false for the other errors.
+func TopicType(err error) (string, bool) {
+	var fieldErr serrors.InvalidTopicFieldError
+	if errors.As(err, &fieldErr) {
+		return fieldErr.TopicType, true
+	}
+
+	var topicErr serrors.InvalidTopicError
+	if errors.As(err, &topicErr) {
+		return Unmatched, true
+	}
+
+	return "", false
+}
+
+// Record adds the denial of topic to the window of its vendor and topic type,
+// errors which are not invalid topic errors are ignored.
+func (t *Tracker) Record(vendor, topic string, err error) {
+	if t == nil {
+		return
+	}
+
+	topicType, ok := TopicType(err)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UnixNano()
+	epoch := now / t.width
+
+	w := t.window(key{vendor: vendor, topicType: topicType})
+	w.bucket(epoch).count.Add(1)
+
+	w.lock.Lock()
+
+	if shapesEpoch := now / int64(t.cfg.Window); shapesEpoch != w.shapesEpoch {
+		w.shapes.reset()
+		w.shapesEpoch = shapesEpoch
+	}
+
+	w.shapes.add(Shape(topic))
+
+	w.lock.Unlock()
+
+	denials := w.count(epoch)
+	rate := float64(denials) / t.cfg.Window.Seconds()
+
+	t.metrics.Denied(vendor, topicType)
+
+	if t.cfg.Threshold <= 0 || rate < t.cfg.Threshold {
+		return
+	}
+
+	if last := w.lastWarn.Load(); time.Since(time.Unix(0, last)) < t.cfg.WarnInterval ||
+		!w.lastWarn.CompareAndSwap(last, now) {
+		return
+	}
+
+	t.logger.Warn("invalid topic denials crossed the threshold, a client release may have broken its topics",
+		zap.String("vendor", vendor),
+		zap.String("topic-type", topicType),
+		zap.Float64("rate", rate),
+		zap.Float64("threshold", t.cfg.Threshold),
+		zap.Int64("denials", denials),
+		zap.Duration("window", t.cfg.Window),
+		zap.Any("shapes", t.Shapes(vendor, topicType)),
+	)
+}
+
+// Rate returns the denials per second of vendor and topic type in the current window.
+func (t *Tracker) Rate(vendor, topicType string) float64 {
+	if t == nil {
+		return 0
+	}
+
+	v, ok := t.windows.Load(key{vendor: vendor, topicType: topicType})
+	if !ok {
+		return 0
+	}
+
+	denials := v.(*window).count(time.Now().UnixNano() / t.width) //nolint: forcetypeassert
+
+	return float64(denials) / t.cfg.Window.Seconds()
+}
+
+// rates yields the rate of each vendor and topic type in the current window, they are collected when the
+// metrics are scraped.
+func (t *Tracker) rates(yield func(vendor, topicType string, rate float64)) {
+	epoch := time.Now().UnixNano() / t.width
+
+	t.windows.Range(func(k, v any) bool {
+		denials := v.(*window).count(epoch) //nolint: forcetypeassert
+
+		yield(k.(key).vendor, k.(key).topicType, float64(denials)/t.cfg.Window.Seconds()) //nolint: forcetypeassert
+
+		return true
+	})
+}
+
+// Shapes returns the most frequent shapes of the denied topics of vendor and topic type.
+func (t *Tracker) Shapes(vendor, topicType string) []ShapeCount {
+	if t == nil {
+		return nil
+	}
+
+	v, ok := t.windows.Load(key{vendor: vendor, topicType: topicType})
+	if !ok {
+		return nil
+	}
+
+	w := v.(*window) //nolint: forcetypeassert
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.shapes.top(t.cfg.TopK)
+}
+
+func (t *Tracker) window(k key) *window {
+	if v, ok := t.windows.Load(k); ok {
+		return v.(*window) //nolint: forcetypeassert
+	}
+
+	// nolint: exhaustruct
+	w := &window{
+		shapes: newLossyCounter(t.cfg.Epsilon),
+	}
+
+	v, _ := t.windows.LoadOrStore(k, w)
+
+	return v.(*window) //nolint: forcetypeassert
+}
+
+// bucket returns the bucket of the given epoch, buckets of the previous windows are reset
+// before being reused.
+func (w *window) bucket(epoch int64) *bucket {
+	b := &w.buckets[epoch%buckets]
+
+	if old := b.epoch.Load(); old != epoch && b.epoch.CompareAndSwap(old, epoch) {
+		b.count.Store(0)
+	}
+
+	return b
+}
+
+// count sums the denials of the buckets which are in the window ending at the given epoch.
+func (w *window) count(epoch int64) int64 {
+	var count int64
+
+	for i := range w.buckets {
+		b := &w.buckets[i]
+
+		if epoch-b.epoch.Load() >= buckets {
+			continue
+		}
+
+		count += b.count.Load()
+	}
+
+	return count
+}
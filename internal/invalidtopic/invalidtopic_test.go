@@ -0,0 +1,134 @@
+package invalidtopic_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	serrors "github.com/snapp-incubator/soteria/internal/errors"
+	"github.com/snapp-incubator/soteria/internal/invalidtopic"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestShape(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"snapp/driver/42/location":                   "snapp/driver/{n}/location",
+		"passenger-event-9f86d081884c7d65":           "passenger-event-{hex}",
+		"snapp/passenger/DXKgaNQa7N5Y7bo/chat":       "snapp/passenger/DXKgaNQa{n}N{n}Y{n}bo/chat",
+		"snapp/ride12/cafe":                          "snapp/ride{n}/cafe",
+		"shared/snapp/driver/1/call/node-2b/send":    "shared/snapp/driver/{n}/call/node-{n}b/send",
+		"snapp/driver/0123456789abcdef0123/location": "snapp/driver/{hex}/location",
+	}
+
+	for topic, shape := range cases {
+		require.Equal(t, shape, invalidtopic.Shape(topic), topic)
+	}
+}
+
+// nolint: funlen
+func TestTracker(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	core, logs := observer.New(zap.WarnLevel)
+
+	tracker := invalidtopic.New(invalidtopic.Config{
+		Threshold:    0.1,
+		Window:       10 * time.Second,
+		WarnInterval: time.Minute,
+		TopK:         2,
+		Epsilon:      0.01,
+	}, zap.New(core))
+
+	// other denials are not tracked.
+	tracker.Record("snapp", "snapp/driver/1/location", serrors.ErrInvalidAccessType)
+	require.Zero(tracker.Rate("snapp", invalidtopic.Unmatched))
+
+	for i := range 10 {
+		tracker.Record("snapp", fmt.Sprintf("snapp/driver/%d/locations", i), serrors.InvalidTopicError{
+			Topic: "",
+		})
+	}
+
+	for i := range 5 {
+		tracker.Record("snapp", fmt.Sprintf("snapp/driver/%d/chats", i), serrors.InvalidTopicError{Topic: ""})
+	}
+
+	tracker.Record("snapp", "snapp/driver/events", serrors.InvalidTopicError{Topic: ""})
+
+	tracker.Record("snapp", "snapp/driver/1/call/node/send", serrors.InvalidTopicFieldError{
+		TopicType: "node_call_entry",
+		Issuer:    "0",
+		Sub:       "sub",
+		Field:     "node",
+		Value:     "node",
+	})
+
+	require.InDelta(1.6, tracker.Rate("snapp", invalidtopic.Unmatched), 0.001)
+	require.InDelta(0.1, tracker.Rate("snapp", "node_call_entry"), 0.001)
+	require.Zero(tracker.Rate("snappbox", invalidtopic.Unmatched))
+
+	require.Equal([]invalidtopic.ShapeCount{
+		{Shape: "snapp/driver/{n}/locations", Count: 10},
+		{Shape: "snapp/driver/{n}/chats", Count: 5},
+	}, tracker.Shapes("snapp", invalidtopic.Unmatched))
+
+	// each vendor and topic type warns once in the warn interval.
+	warnings := logs.FilterMessageSnippet("invalid topic denials").All()
+	require.Len(warnings, 2)
+	require.Equal(invalidtopic.Unmatched, warnings[0].ContextMap()["topic-type"])
+	require.Equal("node_call_entry", warnings[1].ContextMap()["topic-type"])
+
+	var nilTracker *invalidtopic.Tracker
+
+	nilTracker.Record("snapp", "topic", serrors.InvalidTopicError{Topic: ""})
+	require.Zero(nilTracker.Rate("snapp", invalidtopic.Unmatched))
+	require.Nil(nilTracker.Shapes("snapp", invalidtopic.Unmatched))
+}
+
+// the rate collector has the rates of the last tracker, so this test doesn't run in parallel with the others.
+// nolint: paralleltest
+func TestTrackerRateMetric(t *testing.T) {
+	require := require.New(t)
+
+	tracker := invalidtopic.New(invalidtopic.Config{
+		Threshold:    0,
+		Window:       60 * time.Millisecond,
+		WarnInterval: 0,
+		TopK:         0,
+		Epsilon:      0,
+	}, zap.NewNop())
+
+	rate := func() float64 {
+		families, err := prometheus.DefaultGatherer.Gather()
+		require.NoError(err)
+
+		for _, family := range families {
+			if family.GetName() != "platform_soteria_invalid_topic_rate" {
+				continue
+			}
+
+			for _, m := range family.GetMetric() {
+				if m.GetLabel()[0].GetValue() == "decay" {
+					return m.GetGauge().GetValue()
+				}
+			}
+		}
+
+		return -1
+	}
+
+	tracker.Record("decay", "snapp/driver/1/locations", serrors.InvalidTopicError{Topic: ""})
+	require.Positive(rate())
+
+	// rates are computed when they are scraped, so they decay without new denials.
+	require.Eventually(func() bool {
+		return rate() == 0
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,79 @@
+package invalidtopic
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// ShapeCount is a topic shape with its estimated count, counts are underestimated
+// by at most epsilon times the number of recorded topics.
+type ShapeCount struct {
+	Shape string `json:"shape"`
+	Count int64  `json:"count"`
+}
+
+type lossyEntry struct {
+	count int64
+	// delta is the maximum count of shape before it is tracked.
+	delta int64
+}
+
+// lossyCounter finds the frequent shapes of a stream in bounded memory using lossy counting.
+// The stream is divided into buckets of 1/epsilon items and rare shapes are pruned at the end
+// of each bucket, so it tracks at most 1/epsilon * log(epsilon * n) shapes.
+type lossyCounter struct {
+	width   int64
+	n       int64
+	entries map[string]*lossyEntry
+}
+
+func newLossyCounter(epsilon float64) *lossyCounter {
+	return &lossyCounter{
+		width:   int64(math.Ceil(1 / epsilon)),
+		n:       0,
+		entries: make(map[string]*lossyEntry),
+	}
+}
+
+func (l *lossyCounter) add(shape string) {
+	l.n++
+
+	bucket := (l.n + l.width - 1) / l.width
+
+	if entry, ok := l.entries[shape]; ok {
+		entry.count++
+	} else {
+		l.entries[shape] = &lossyEntry{count: 1, delta: bucket - 1}
+	}
+
+	if l.n%l.width != 0 {
+		return
+	}
+
+	for shape, entry := range l.entries {
+		if entry.count+entry.delta <= bucket {
+			delete(l.entries, shape)
+		}
+	}
+}
+
+// top returns the k most frequent shapes, ties are sorted by shape.
+func (l *lossyCounter) top(k int) []ShapeCount {
+	list := make([]ShapeCount, 0, len(l.entries))
+
+	for shape, entry := range l.entries {
+		list = append(list, ShapeCount{Shape: shape, Count: entry.count})
+	}
+
+	slices.SortFunc(list, func(a, b ShapeCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Shape, b.Shape))
+	})
+
+	return list[:min(k, len(list))]
+}
+
+func (l *lossyCounter) reset() {
+	l.n = 0
+	clear(l.entries)
+}
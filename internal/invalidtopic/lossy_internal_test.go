@@ -0,0 +1,36 @@
+package invalidtopic
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLossyCounter(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	l := newLossyCounter(0.01)
+
+	// distinct shapes are pruned at the end of their bucket, so memory is bounded by the bucket width.
+	for i := range 100_000 {
+		l.add(strconv.Itoa(i))
+
+		if i%7 == 0 {
+			l.add("frequent")
+		}
+	}
+
+	require.LessOrEqual(len(l.entries), int(2*l.width))
+
+	top := l.top(1)
+	require.Len(top, 1)
+	require.Equal("frequent", top[0].Shape)
+	// counts are underestimated by at most epsilon times the number of items.
+	require.InDelta(100_000/7+1, top[0].Count, 0.01*float64(l.n))
+
+	l.reset()
+	require.Empty(l.top(1))
+}
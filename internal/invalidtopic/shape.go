@@ -0,0 +1,84 @@
+package invalidtopic
+
+import (
+	"strings"
+)
+
+const (
+	// NumberPlaceholder replaces the runs of digits in topic shapes.
+	NumberPlaceholder = "{n}"
+	// HexPlaceholder replaces the runs of hex characters, like hashes, in topic shapes.
+	HexPlaceholder = "{hex}"
+
+	// minHexRun is the minimum length of hex runs which are collapsed, so short words like cafe are kept.
+	minHexRun = 8
+	// maxShapeLength bounds the memory of the tracked shapes.
+	maxShapeLength = 256
+)
+
+// Shape templatizes topic by collapsing its digit runs and its hex runs which have a digit, e.g.
+// driver-event-9f86d081884c7d65 is driver-event-{hex} and snapp/driver/42/location is snapp/driver/{n}/location.
+func Shape(topic string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(topic) && b.Len() < maxShapeLength; {
+		if !isHex(topic[i]) {
+			b.WriteByte(topic[i])
+			i++
+
+			continue
+		}
+
+		j := i
+		digits := 0
+
+		for j < len(topic) && isHex(topic[j]) {
+			if isDigit(topic[j]) {
+				digits++
+			}
+
+			j++
+		}
+
+		run := topic[i:j]
+		i = j
+
+		switch {
+		case digits == len(run):
+			b.WriteString(NumberPlaceholder)
+		case len(run) >= minHexRun && digits > 0:
+			b.WriteString(HexPlaceholder)
+		default:
+			writeDigitRuns(&b, run)
+		}
+	}
+
+	shape := b.String()
+
+	return shape[:min(len(shape), maxShapeLength)]
+}
+
+// writeDigitRuns writes the run with its digit runs collapsed.
+func writeDigitRuns(b *strings.Builder, run string) {
+	for i := 0; i < len(run); i++ {
+		if !isDigit(run[i]) {
+			b.WriteByte(run[i])
+
+			continue
+		}
+
+		b.WriteString(NumberPlaceholder)
+
+		for i+1 < len(run) && isDigit(run[i+1]) {
+			i++
+		}
+	}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHex(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
@@ -0,0 +1,91 @@
+// Package ipfilter allows or denies client addresses using lists of CIDR ranges,
+// ranges are parsed once and addresses are checked against them in order.
+package ipfilter
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// Filter has the allowed and denied ranges, nil filter allows every address.
+type Filter struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// New parses the allowed and denied ranges, ranges can be a CIDR or a single address.
+func New(allow, deny []string) (*Filter, error) {
+	allowed, err := parse(allow)
+	if err != nil {
+		return nil, err
+	}
+
+	denied, err := parse(deny)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Filter{
+		allow: allowed,
+		deny:  denied,
+	}, nil
+}
+
+func parse(ranges []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(ranges))
+
+	for _, r := range ranges {
+		if !strings.Contains(r, "/") {
+			addr, err := netip.ParseAddr(r)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %s %w", r, err)
+			}
+
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cidr %s %w", r, err)
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// Allowed checks the address is not in the denied ranges and it is in the allowed ranges
+// when there are any. Unknown addresses are only allowed when there is no allowed range.
+func (f *Filter) Allowed(addr netip.Addr) bool {
+	if f == nil {
+		return true
+	}
+
+	if !addr.IsValid() {
+		return len(f.allow) == 0
+	}
+
+	addr = addr.Unmap()
+
+	for _, prefix := range f.deny {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	if len(f.allow) == 0 {
+		return true
+	}
+
+	for _, prefix := range f.allow {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
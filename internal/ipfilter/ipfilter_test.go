@@ -0,0 +1,75 @@
+package ipfilter_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/snapp-incubator/soteria/internal/ipfilter"
+	"github.com/stretchr/testify/require"
+)
+
+// nolint: funlen
+func TestAllowed(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		allow []string
+		deny  []string
+		addr  string
+		want  bool
+	}{
+		{name: "without ranges", allow: nil, deny: nil, addr: "192.168.1.1", want: true},
+		{name: "in allowed range", allow: []string{"10.0.0.0/8"}, deny: nil, addr: "10.1.2.3", want: true},
+		{name: "out of allowed range", allow: []string{"10.0.0.0/8"}, deny: nil, addr: "192.168.1.1", want: false},
+		{name: "single address", allow: []string{"192.168.1.1"}, deny: nil, addr: "192.168.1.1", want: true},
+		{name: "mapped address", allow: []string{"10.0.0.0/8"}, deny: nil, addr: "::ffff:10.1.2.3", want: true},
+		{name: "ipv6", allow: []string{"2001:db8::/32"}, deny: nil, addr: "2001:db8::1", want: true},
+		{name: "in denied range", allow: nil, deny: []string{"10.0.0.0/8"}, addr: "10.1.2.3", want: false},
+		{name: "out of denied range", allow: nil, deny: []string{"10.0.0.0/8"}, addr: "192.168.1.1", want: true},
+		{
+			name:  "deny has precedence",
+			allow: []string{"10.0.0.0/8"},
+			deny:  []string{"10.1.0.0/16"},
+			addr:  "10.1.2.3",
+			want:  false,
+		},
+		{name: "unknown address without allowed ranges", allow: nil, deny: []string{"10.0.0.0/8"}, addr: "", want: true},
+		{name: "unknown address with allowed ranges", allow: []string{"10.0.0.0/8"}, deny: nil, addr: "", want: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require := require.New(t)
+
+			f, err := ipfilter.New(tc.allow, tc.deny)
+			require.NoError(err)
+
+			var addr netip.Addr
+
+			if tc.addr != "" {
+				addr = netip.MustParseAddr(tc.addr)
+			}
+
+			require.Equal(tc.want, f.Allowed(addr))
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	_, err := ipfilter.New([]string{"10.0.0.0/33"}, nil)
+	require.Error(err)
+
+	_, err = ipfilter.New(nil, []string{"not-an-ip"})
+	require.Error(err)
+
+	var f *ipfilter.Filter
+
+	require.True(f.Allowed(netip.MustParseAddr("10.1.2.3")))
+}
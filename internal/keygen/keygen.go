@@ -0,0 +1,210 @@
+// Package keygen generates the key pairs of vendors in the PEM formats which are read by soteria.
+package keygen
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	RSA     = "rsa"
+	EC      = "ec"
+	Ed25519 = "ed25519"
+
+	// DefaultRSABits is the size of RSA keys when bits are not given.
+	DefaultRSABits = 4096
+	// MinRSABits is the minimum size of RSA keys.
+	MinRSABits = 2048
+	// DefaultECBits is the size of EC keys when bits are not given which is P-256 curve.
+	DefaultECBits = 256
+
+	// KidHeader is the PEM header which has the key id of key pair.
+	KidHeader = "Kid"
+)
+
+var (
+	ErrInvalidAlgorithm = errors.New("algorithm must be one of rsa, ec and ed25519")
+	ErrInvalidBits      = errors.New("invalid key size")
+)
+
+type Options struct {
+	Algorithm string
+	// Bits is the size of RSA keys or the curve size of EC keys (256, 384 or 521), zero uses the defaults
+	// and it is ignored for ed25519 keys.
+	Bits int
+	// Kid is added as the kid header of PEM blocks when it is not empty.
+	Kid string
+}
+
+// KeyPair has the PEM encoded keys and the JWT signing method which uses them.
+type KeyPair struct {
+	PrivateKey    []byte
+	PublicKey     []byte
+	SigningMethod string
+	// Public is the parsed public key which can be used for fingerprints.
+	Public crypto.PublicKey
+}
+
+// Generate creates a new key pair, private keys are encoded as PKCS #1 for RSA, SEC 1 for EC and PKCS #8
+// for ed25519 and public keys are encoded as PKIX, which are the formats of jwt PEM parsers.
+func Generate(opts Options) (KeyPair, error) {
+	var (
+		public        crypto.PublicKey
+		privateBlock  *pem.Block
+		signingMethod string
+		err           error
+	)
+
+	switch strings.ToLower(opts.Algorithm) {
+	case RSA:
+		public, privateBlock, signingMethod, err = rsaKey(opts.Bits)
+	case EC:
+		public, privateBlock, signingMethod, err = ecKey(opts.Bits)
+	case Ed25519:
+		public, privateBlock, signingMethod, err = ed25519Key()
+	default:
+		return KeyPair{}, fmt.Errorf("%w: %s", ErrInvalidAlgorithm, opts.Algorithm)
+	}
+
+	if err != nil {
+		return KeyPair{}, err
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("cannot marshal public key %w", err)
+	}
+
+	publicBlock := &pem.Block{
+		Type:    "PUBLIC KEY",
+		Headers: nil,
+		Bytes:   der,
+	}
+
+	if opts.Kid != "" {
+		privateBlock.Headers = map[string]string{KidHeader: opts.Kid}
+		publicBlock.Headers = map[string]string{KidHeader: opts.Kid}
+	}
+
+	return KeyPair{
+		PrivateKey:    pem.EncodeToMemory(privateBlock),
+		PublicKey:     pem.EncodeToMemory(publicBlock),
+		SigningMethod: signingMethod,
+		Public:        public,
+	}, nil
+}
+
+func rsaKey(bits int) (crypto.PublicKey, *pem.Block, string, error) {
+	if bits == 0 {
+		bits = DefaultRSABits
+	}
+
+	if bits < MinRSABits {
+		return nil, nil, "", fmt.Errorf("%w: rsa keys must have at least %d bits", ErrInvalidBits, MinRSABits)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("cannot generate rsa key %w", err)
+	}
+
+	return &key.PublicKey, &pem.Block{
+		Type:    "RSA PRIVATE KEY",
+		Headers: nil,
+		Bytes:   x509.MarshalPKCS1PrivateKey(key),
+	}, "RS512", nil
+}
+
+func ecKey(bits int) (crypto.PublicKey, *pem.Block, string, error) {
+	var (
+		curve  elliptic.Curve
+		method string
+	)
+
+	switch bits {
+	case 0, DefaultECBits:
+		curve, method = elliptic.P256(), "ES256"
+	case 384: // nolint: mnd
+		curve, method = elliptic.P384(), "ES384"
+	case 521: // nolint: mnd
+		curve, method = elliptic.P521(), "ES512"
+	default:
+		return nil, nil, "", fmt.Errorf("%w: ec keys must have 256, 384 or 521 bits", ErrInvalidBits)
+	}
+
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("cannot generate ec key %w", err)
+	}
+
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("cannot marshal ec key %w", err)
+	}
+
+	return &key.PublicKey, &pem.Block{
+		Type:    "EC PRIVATE KEY",
+		Headers: nil,
+		Bytes:   der,
+	}, method, nil
+}
+
+func ed25519Key() (crypto.PublicKey, *pem.Block, string, error) {
+	public, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("cannot generate ed25519 key %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("cannot marshal ed25519 key %w", err)
+	}
+
+	return public, &pem.Block{
+		Type:    "PRIVATE KEY",
+		Headers: nil,
+		Bytes:   der,
+	}, "EdDSA", nil
+}
+
+// Snippet returns the vendor configuration of the public key for the given issuer, keys with kid are
+// added as verification keys so they can be rolled over.
+func Snippet(pair KeyPair, issuer, kid string) string {
+	var snippet strings.Builder
+
+	_, _ = fmt.Fprintf(&snippet, "jwt:\n  signing_method: %q\n", pair.SigningMethod)
+
+	if kid == "" {
+		_, _ = fmt.Fprintf(&snippet, "keys:\n  %q: |\n%s", issuer, indent(pair.PublicKey, "    "))
+	} else {
+		_, _ = fmt.Fprintf(&snippet, "verification_keys:\n  %q:\n    - kid: %q\n      key: |\n%s",
+			issuer, kid, indent(pair.PublicKey, "        "))
+	}
+
+	return snippet.String()
+}
+
+func indent(block []byte, prefix string) string {
+	var indented strings.Builder
+
+	for _, line := range strings.SplitAfter(string(block), "\n") {
+		switch line {
+		case "":
+		case "\n":
+			indented.WriteString(line)
+		default:
+			indented.WriteString(prefix + line)
+		}
+	}
+
+	return indented.String()
+}
@@ -0,0 +1,108 @@
+package keygen_test
+
+import (
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/snapp-incubator/soteria/internal/keygen"
+	"github.com/stretchr/testify/require"
+)
+
+// nolint: funlen
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		opts          keygen.Options
+		signingMethod string
+		parse         func(private, public []byte) error
+	}{
+		{
+			name:          "rsa",
+			opts:          keygen.Options{Algorithm: keygen.RSA, Bits: keygen.MinRSABits, Kid: "2024-10"},
+			signingMethod: "RS512",
+			parse: func(private, public []byte) error {
+				if _, err := jwt.ParseRSAPrivateKeyFromPEM(private); err != nil {
+					return err
+				}
+
+				_, err := jwt.ParseRSAPublicKeyFromPEM(public)
+
+				return err
+			},
+		},
+		{
+			name:          "ec",
+			opts:          keygen.Options{Algorithm: keygen.EC, Bits: 384, Kid: ""},
+			signingMethod: "ES384",
+			parse: func(private, public []byte) error {
+				if _, err := jwt.ParseECPrivateKeyFromPEM(private); err != nil {
+					return err
+				}
+
+				_, err := jwt.ParseECPublicKeyFromPEM(public)
+
+				return err
+			},
+		},
+		{
+			name:          "ed25519",
+			opts:          keygen.Options{Algorithm: keygen.Ed25519, Bits: 0, Kid: "2024-10"},
+			signingMethod: "EdDSA",
+			parse: func(private, public []byte) error {
+				if _, err := jwt.ParseEdPrivateKeyFromPEM(private); err != nil {
+					return err
+				}
+
+				_, err := jwt.ParseEdPublicKeyFromPEM(public)
+
+				return err
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			pair, err := keygen.Generate(tc.opts)
+			require.NoError(t, err)
+			require.Equal(t, tc.signingMethod, pair.SigningMethod)
+			require.NoError(t, tc.parse(pair.PrivateKey, pair.PublicKey))
+
+			block, _ := pem.Decode(pair.PublicKey)
+			require.NotNil(t, block)
+			require.Equal(t, "PUBLIC KEY", block.Type)
+			require.Equal(t, tc.opts.Kid, block.Headers[keygen.KidHeader])
+		})
+	}
+}
+
+func TestGenerateInvalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := keygen.Generate(keygen.Options{Algorithm: "dsa", Bits: 0, Kid: ""})
+	require.ErrorIs(t, err, keygen.ErrInvalidAlgorithm)
+
+	_, err = keygen.Generate(keygen.Options{Algorithm: keygen.RSA, Bits: 1024, Kid: ""})
+	require.ErrorIs(t, err, keygen.ErrInvalidBits)
+
+	_, err = keygen.Generate(keygen.Options{Algorithm: keygen.EC, Bits: 128, Kid: ""})
+	require.ErrorIs(t, err, keygen.ErrInvalidBits)
+}
+
+func TestSnippet(t *testing.T) {
+	t.Parallel()
+
+	pair, err := keygen.Generate(keygen.Options{Algorithm: keygen.Ed25519, Bits: 0, Kid: ""})
+	require.NoError(t, err)
+
+	snippet := keygen.Snippet(pair, "0", "")
+	require.True(t, strings.HasPrefix(snippet, "jwt:\n  signing_method: \"EdDSA\"\nkeys:\n  \"0\": |\n    -----BEGIN PUBLIC KEY-----\n"))
+
+	snippet = keygen.Snippet(pair, "0", "2024-10")
+	require.Contains(t, snippet, "verification_keys:\n  \"0\":\n    - kid: \"2024-10\"\n      key: |\n        -----BEGIN PUBLIC KEY-----\n")
+}
@@ -0,0 +1,152 @@
+// Package limiter caps the in-flight requests of an endpoint, so reconnect storms after broker
+// restarts don't grow goroutines without a bound. Requests over the cap wait in a small queue
+// and are shed when the queue is full or they wait too long.
+package limiter
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/metric"
+)
+
+const (
+	DefaultMaxWait    = 100 * time.Millisecond
+	DefaultRetryAfter = time.Second
+
+	ReasonQueueFull = "queue_full"
+	ReasonTimeout   = "timeout"
+)
+
+var (
+	ErrQueueFull = errors.New("limiter queue is full")
+	ErrTimeout   = errors.New("limiter queue wait timed out")
+)
+
+type Config struct {
+	Auth Endpoint `json:"auth,omitempty" koanf:"auth"`
+	ACL  Endpoint `json:"acl,omitempty"  koanf:"acl"`
+}
+
+type Endpoint struct {
+	// MaxInFlight is the maximum number of concurrent requests, zero disables the limiter.
+	MaxInFlight int `json:"max_in_flight,omitempty" koanf:"max_in_flight"`
+	// QueueSize is the number of requests which wait for a slot, zero sheds every request over the cap.
+	QueueSize int           `json:"queue_size,omitempty" koanf:"queue_size"`
+	MaxWait   time.Duration `json:"max_wait,omitempty"   koanf:"max_wait"`
+	// RetryAfter is the hint which is sent with the shed requests.
+	RetryAfter time.Duration `json:"retry_after,omitempty" koanf:"retry_after"`
+}
+
+// Limiters are the limiters of endpoints, they are created once and shared by every
+// server which answers the endpoints.
+type Limiters struct {
+	Auth *Limiter
+	ACL  *Limiter
+}
+
+func NewLimiters(cfg Config) Limiters {
+	metrics := metric.NewLimiterMetrics(prometheus.DefaultRegisterer)
+
+	return Limiters{
+		Auth: New("auth", cfg.Auth, metrics),
+		ACL:  New("acl", cfg.ACL, metrics),
+	}
+}
+
+// Limiter is safe for concurrent use and nil limiter doesn't limit anything.
+type Limiter struct {
+	endpoint string
+	cfg      Endpoint
+	slots    chan struct{}
+	queued   atomic.Int64
+	metrics  *metric.LimiterMetrics
+}
+
+// New creates the limiter of endpoint, it returns nil when the endpoint has no cap.
+func New(endpoint string, cfg Endpoint, metrics *metric.LimiterMetrics) *Limiter {
+	if cfg.MaxInFlight <= 0 {
+		return nil
+	}
+
+	if cfg.MaxWait <= 0 {
+		cfg.MaxWait = DefaultMaxWait
+	}
+
+	if cfg.RetryAfter <= 0 {
+		cfg.RetryAfter = DefaultRetryAfter
+	}
+
+	// nolint: exhaustruct
+	return &Limiter{
+		endpoint: endpoint,
+		cfg:      cfg,
+		slots:    make(chan struct{}, cfg.MaxInFlight),
+		metrics:  metrics,
+	}
+}
+
+// Acquire takes a slot for the request and returns its release function, which must be
+// called when the request is answered. It returns ErrQueueFull or ErrTimeout when the request is shed.
+func (l *Limiter) Acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return l.acquired(), nil
+	default:
+	}
+
+	depth := l.queued.Add(1)
+	if depth > int64(l.cfg.QueueSize) {
+		l.queued.Add(-1)
+		l.metrics.Shed(l.endpoint, ReasonQueueFull)
+
+		return nil, ErrQueueFull
+	}
+
+	l.metrics.QueueDepth(l.endpoint, depth)
+
+	defer func() {
+		l.metrics.QueueDepth(l.endpoint, l.queued.Add(-1))
+	}()
+
+	timer := time.NewTimer(l.cfg.MaxWait)
+	defer timer.Stop()
+
+	select {
+	case l.slots <- struct{}{}:
+		return l.acquired(), nil
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	l.metrics.Shed(l.endpoint, ReasonTimeout)
+
+	return nil, ErrTimeout
+}
+
+func (l *Limiter) acquired() func() {
+	l.metrics.InFlight(l.endpoint, len(l.slots))
+
+	return func() {
+		<-l.slots
+
+		l.metrics.InFlight(l.endpoint, len(l.slots))
+	}
+}
+
+// RetryAfter returns the retry hint of shed requests in seconds, it is at least one second.
+func (l *Limiter) RetryAfter() int {
+	if l == nil {
+		return int(DefaultRetryAfter.Seconds())
+	}
+
+	return max(1, int(math.Ceil(l.cfg.RetryAfter.Seconds())))
+}
@@ -0,0 +1,117 @@
+package limiter_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/limiter"
+	"github.com/snapp-incubator/soteria/internal/metric"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisabled(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	// nolint: exhaustruct
+	l := limiter.New("auth", limiter.Endpoint{}, metric.NewLimiterMetrics(prometheus.DefaultRegisterer))
+	require.Nil(l)
+
+	for range 10 {
+		release, err := l.Acquire(context.Background())
+		require.NoError(err)
+
+		defer release()
+	}
+
+	require.Equal(1, l.RetryAfter())
+}
+
+func TestQueueFull(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	l := limiter.New("auth", limiter.Endpoint{
+		MaxInFlight: 1,
+		QueueSize:   0,
+		MaxWait:     time.Second,
+		RetryAfter:  1500 * time.Millisecond,
+	}, metric.NewLimiterMetrics(prometheus.DefaultRegisterer))
+
+	release, err := l.Acquire(context.Background())
+	require.NoError(err)
+
+	_, err = l.Acquire(context.Background())
+	require.ErrorIs(err, limiter.ErrQueueFull)
+	require.Equal(2, l.RetryAfter())
+
+	release()
+
+	release, err = l.Acquire(context.Background())
+	require.NoError(err)
+
+	release()
+}
+
+func TestQueueTimeout(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	l := limiter.New("acl", limiter.Endpoint{
+		MaxInFlight: 1,
+		QueueSize:   1,
+		MaxWait:     10 * time.Millisecond,
+		RetryAfter:  0,
+	}, metric.NewLimiterMetrics(prometheus.DefaultRegisterer))
+
+	release, err := l.Acquire(context.Background())
+	require.NoError(err)
+
+	defer release()
+
+	_, err = l.Acquire(context.Background())
+	require.ErrorIs(err, limiter.ErrTimeout)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = l.Acquire(ctx)
+	require.ErrorIs(err, limiter.ErrTimeout)
+}
+
+func TestQueueWaits(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	l := limiter.New("acl", limiter.Endpoint{
+		MaxInFlight: 1,
+		QueueSize:   1,
+		MaxWait:     time.Second,
+		RetryAfter:  time.Second,
+	}, metric.NewLimiterMetrics(prometheus.DefaultRegisterer))
+
+	release, err := l.Acquire(context.Background())
+	require.NoError(err)
+
+	done := make(chan error, 1)
+
+	go func() {
+		release, err := l.Acquire(context.Background())
+		if err == nil {
+			release()
+		}
+
+		done <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	release()
+
+	require.NoError(<-done)
+}
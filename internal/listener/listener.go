@@ -0,0 +1,38 @@
+package listener
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+type Config struct {
+	Host string
+	Port int
+	// ReusePort sets SO_REUSEPORT on the listener, so multiple processes can bind the same address.
+	// it is only supported on linux and is ignored elsewhere.
+	ReusePort bool
+}
+
+// Address returns the host and port in the listen format.
+func (c Config) Address() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// Listen creates a TCP listener on the configured address.
+func Listen(ctx context.Context, cfg Config) (net.Listener, error) {
+	// nolint: exhaustruct
+	lc := net.ListenConfig{}
+
+	if cfg.ReusePort {
+		lc.Control = reusePort
+	}
+
+	ln, err := lc.Listen(ctx, "tcp", cfg.Address())
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen on %s %w", cfg.Address(), err)
+	}
+
+	return ln, nil
+}
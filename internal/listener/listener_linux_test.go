@@ -0,0 +1,40 @@
+//go:build linux
+
+package listener_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/snapp-incubator/soteria/internal/listener"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReusePort(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	first, err := listener.Listen(context.Background(), listener.Config{Host: "127.0.0.1", Port: 0, ReusePort: true})
+	require.NoError(err)
+
+	defer first.Close()
+
+	addr, ok := first.Addr().(*net.TCPAddr)
+	require.True(ok)
+
+	cfg := listener.Config{Host: "127.0.0.1", Port: addr.Port, ReusePort: true}
+
+	second, err := listener.Listen(context.Background(), cfg)
+	require.NoError(err)
+
+	defer second.Close()
+
+	require.Equal(first.Addr().String(), second.Addr().String())
+
+	cfg.ReusePort = false
+
+	_, err = listener.Listen(context.Background(), cfg)
+	require.Error(err)
+}
@@ -0,0 +1,26 @@
+//go:build linux
+
+package listener
+
+import (
+	"fmt"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func reusePort(_, _ string, conn syscall.RawConn) error {
+	var opErr error
+
+	if err := conn.Control(func(fd uintptr) {
+		opErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	}); err != nil {
+		return fmt.Errorf("cannot access raw connection %w", err)
+	}
+
+	if opErr != nil {
+		return fmt.Errorf("cannot set SO_REUSEPORT %w", opErr)
+	}
+
+	return nil
+}
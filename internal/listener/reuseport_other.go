@@ -0,0 +1,10 @@
+//go:build !linux
+
+package listener
+
+import "syscall"
+
+// reusePort is a no-op because SO_REUSEPORT is only supported on linux.
+func reusePort(_, _ string, _ syscall.RawConn) error {
+	return nil
+}
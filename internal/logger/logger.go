@@ -1,33 +1,63 @@
 package logger
 
 import (
-	"log"
 	"os"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	// FormatConsole is the human readable format which is the default.
+	FormatConsole = "console"
+	// FormatJSON is the structured format for log pipelines.
+	FormatJSON = "json"
 )
 
 type Config struct {
 	Level      string `json:"level,omitempty"      koanf:"level"`
 	Stacktrace bool   `json:"stacktrace,omitempty" koanf:"stacktrace"`
+	// Format is console or json, unknown formats are console.
+	Format string `json:"format,omitempty" koanf:"format"`
+	// File writes the logs into a rotating file in addition to stderr when its path is set.
+	File File `json:"file,omitempty" koanf:"file"`
+}
+
+type File struct {
+	Path string `json:"path,omitempty" koanf:"path"`
+	// MaxSize is the size of file in megabytes before it gets rotated.
+	MaxSize int `json:"max_size,omitempty" koanf:"max_size"`
+	// MaxBackups is the number of rotated files which are kept.
+	MaxBackups int `json:"max_backups,omitempty" koanf:"max_backups"`
 }
 
-// New creates a zap logger for console.
+// New creates a zap logger for console and file.
 func New(cfg Config) *zap.Logger {
 	var lvl zapcore.Level
-	if err := lvl.Set(cfg.Level); err != nil {
-		log.Printf("cannot parse log level %s: %s", cfg.Level, err)
 
+	lvlErr := lvl.Set(cfg.Level)
+	if lvlErr != nil {
 		lvl = zapcore.WarnLevel
 	}
 
-	encoder := zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
+	encoder := newEncoder(cfg.Format)
 	defaultCore := zapcore.NewCore(encoder, zapcore.Lock(zapcore.AddSync(os.Stderr)), lvl)
 	cores := []zapcore.Core{
 		defaultCore,
 	}
 
+	if cfg.File.Path != "" {
+		// nolint: exhaustruct
+		writer := &lumberjack.Logger{
+			Filename:   cfg.File.Path,
+			MaxSize:    cfg.File.MaxSize,
+			MaxBackups: cfg.File.MaxBackups,
+		}
+
+		cores = append(cores, zapcore.NewCore(encoder, zapcore.AddSync(writer), lvl))
+	}
+
 	core := zapcore.NewTee(cores...)
 	zapOpts := []zap.Option{
 		zap.AddCaller(),
@@ -39,5 +69,17 @@ func New(cfg Config) *zap.Logger {
 
 	logger := zap.New(core, zapOpts...)
 
+	if lvlErr != nil {
+		logger.Warn("cannot parse log level", zap.String("level", cfg.Level), zap.Error(lvlErr))
+	}
+
 	return logger
 }
+
+func newEncoder(format string) zapcore.Encoder {
+	if format == FormatJSON {
+		return zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	}
+
+	return zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
+}
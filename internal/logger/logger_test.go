@@ -0,0 +1,42 @@
+package logger_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/snapp-incubator/soteria/internal/logger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONFile(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "soteria.log")
+
+	l := logger.New(logger.Config{
+		Level:      "info",
+		Stacktrace: false,
+		Format:     logger.FormatJSON,
+		File: logger.File{
+			Path:       path,
+			MaxSize:    1,
+			MaxBackups: 1,
+		},
+	})
+
+	l.Info("hello")
+	l.Debug("hidden")
+
+	content, err := os.ReadFile(path)
+	require.NoError(err)
+
+	var entry map[string]any
+
+	require.NoError(json.Unmarshal(content, &entry))
+	require.Equal("hello", entry["msg"])
+	require.Equal("info", entry["level"])
+}
@@ -1,167 +1,1179 @@
 package metric
 
 import (
+	"context"
 	"errors"
+	"sync/atomic"
 
 	"github.com/prometheus/client_golang/prometheus"
 	serrors "github.com/snapp-incubator/soteria/internal/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+)
+
+const (
+	// AuthMethodJWT is the auth method of clients which use tokens.
+	AuthMethodJWT = "jwt"
+	// AuthMethodStatic is the auth method of static clients which use username and password.
+	AuthMethodStatic = "static"
+	// AuthMethodAnonymous is the auth method of clients which have empty credentials.
+	AuthMethodAnonymous = "anonymous"
+
+	// meterName is the instrumentation scope of the OpenTelemetry instruments.
+	meterName = "github.com/snapp-incubator/soteria/internal/metric"
 )
 
 type AutoAuthenticatorMetrics struct {
-	latency *prometheus.HistogramVec
+	latency   otelmetric.Float64Histogram
+	iatSkew   *prometheus.CounterVec
+	coalesced *prometheus.CounterVec
+	cache     *prometheus.CounterVec
+	// verification counts the acl tokens by the way they are verified.
+	verification *prometheus.CounterVec
 }
 
 type APIMetrics struct {
-	auth *prometheus.CounterVec
-	acl  *prometheus.CounterVec
+	auth     otelmetric.Int64Counter
+	acl      otelmetric.Int64Counter
+	payload  *prometheus.CounterVec
+	disabled *prometheus.CounterVec
+	budget   *prometheus.CounterVec
+	topic    *prometheus.CounterVec
+	resolved *prometheus.CounterVec
+	token    *prometheus.CounterVec
+	// maintenance counts the requests of vendors in maintenance.
+	maintenance *prometheus.CounterVec
+	credential  *prometheus.CounterVec
+	// deprecated counts the requests of deprecated endpoints, e.g. the v1 routes of old brokers.
+	deprecated *prometheus.CounterVec
+	// normalized counts the tokens which are normalized by lenient token parsing.
+	normalized *prometheus.CounterVec
+	// method counts the auth and acl attempts by their auth method, auth and acl totals keep their labels.
+	method *prometheus.CounterVec
 }
 
-func NewAutoAuthenticatorMetrics() *AutoAuthenticatorMetrics {
+func NewAutoAuthenticatorMetrics(reg prometheus.Registerer) *AutoAuthenticatorMetrics {
 	m := &AutoAuthenticatorMetrics{
-		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
-			Namespace:                       "platform",
-			Subsystem:                       "soteria",
-			Name:                            "auto_auth_latency_seconds",
-			Help:                            "Automatic authentication latency in seconds",
-			ConstLabels:                     prometheus.Labels{},
-			Buckets:                         prometheus.DefBuckets,
-			NativeHistogramBucketFactor:     0,
-			NativeHistogramZeroThreshold:    0,
-			NativeHistogramMaxBucketNumber:  0,
-			NativeHistogramMinResetDuration: 0,
-			NativeHistogramMaxZeroThreshold: 0,
-			NativeHistogramMaxExemplars:     0,
-			NativeHistogramExemplarTTL:      0,
-		}, []string{"company", "status"}),
+		latency: must(otel.Meter(meterName).Float64Histogram(
+			"platform_soteria_auto_auth_latency_seconds",
+			otelmetric.WithDescription("Automatic authentication latency in seconds"),
+			otelmetric.WithUnit("s"),
+			otelmetric.WithExplicitBucketBoundaries(prometheus.DefBuckets...),
+		)),
+		iatSkew: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "auto_auth_iat_skew_total",
+			Help:        "Total number of freshly minted tokens which are rejected by validator because of clock skew",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company", "issuer", "result"}),
+		coalesced: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "coalesced_calls_total",
+			Help:        "Total number of outbound calls which waited for the same call of another request",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company", "call"}),
+		cache: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "validation_cache_total",
+			Help:        "Total number of validations by their cache result",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company", "result"}),
+		verification: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "acl_verification_total",
+			Help:        "Total number of acl tokens by the way they are verified",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company", "result"}),
 	}
 
-	m.register()
+	m.register(reg)
 
 	return m
 }
 
-// Latency measures latency in seconds.
+// IATSkew counts the freshly minted tokens which validator rejects because of clock skew,
+// result shows the retry was successful or not.
+func (m *AutoAuthenticatorMetrics) IATSkew(company, issuer, result string) {
+	m.iatSkew.WithLabelValues(company, issuer, result).Inc()
+}
+
+// Latency measures latency in seconds, status is the metric status of error
+// because error messages have tokens and addresses in them.
 func (m *AutoAuthenticatorMetrics) Latency(latency float64, company string, err error) {
-	message := ""
-	if err != nil {
-		message = err.Error()
-	}
+	m.latency.Record(context.Background(), latency, otelmetric.WithAttributes(
+		attribute.String("company", company),
+		attribute.String("status", Status(err)),
+	))
+}
+
+// Coalesced counts the calls which received the result of the same running call instead of calling again.
+func (m *AutoAuthenticatorMetrics) Coalesced(company, call string) {
+	m.coalesced.WithLabelValues(company, call).Inc()
+}
+
+// ValidationCache counts validations by their cache result which is hit, stale or miss.
+func (m *AutoAuthenticatorMetrics) ValidationCache(company, result string) {
+	m.cache.WithLabelValues(company, result).Inc()
+}
 
-	m.latency.WithLabelValues(company, message).Observe(latency)
+// ACLVerification counts acl tokens by their verification, which is key, auth, broker, unverified or rejected.
+func (m *AutoAuthenticatorMetrics) ACLVerification(company, result string) {
+	m.verification.WithLabelValues(company, result).Inc()
 }
 
-func (m *AutoAuthenticatorMetrics) register() {
-	register(m.latency)
+func (m *AutoAuthenticatorMetrics) register(reg prometheus.Registerer) {
+	m.iatSkew = register(reg, m.iatSkew)
+	m.coalesced = register(reg, m.coalesced)
+	m.cache = register(reg, m.cache)
+	m.verification = register(reg, m.verification)
 }
 
-func NewAPIMetrics() *APIMetrics {
+func NewAPIMetrics(reg prometheus.Registerer) *APIMetrics {
+	meter := otel.Meter(meterName)
+
 	m := &APIMetrics{
-		auth: prometheus.NewCounterVec(prometheus.CounterOpts{
+		auth: must(meter.Int64Counter(
+			"platform_soteria_auth_total",
+			otelmetric.WithDescription("Total number of authentication attempts"),
+		)),
+		acl: must(meter.Int64Counter(
+			"platform_soteria_acl_total",
+			otelmetric.WithDescription("Total number of authorization attempts"),
+		)),
+		payload: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Namespace:   "platform",
 			Subsystem:   "soteria",
-			Name:        "auth_total",
-			Help:        "Total number of authentication attempts",
+			Name:        "acl_payload_too_large_total",
+			Help:        "Total number of publishes denied because of their payload size",
 			ConstLabels: prometheus.Labels{},
-		}, []string{"company", "status", "source"}),
-		acl: prometheus.NewCounterVec(prometheus.CounterOpts{
+		}, []string{"company", "topic_type", "source"}),
+		disabled: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Namespace:   "platform",
 			Subsystem:   "soteria",
-			Name:        "acl_total",
-			Help:        "Total number of authorization attempts",
+			Name:        "vendor_disabled_total",
+			Help:        "Total number of requests which are answered by the disabled vendor policy",
 			ConstLabels: prometheus.Labels{},
-		}, []string{"company", "status"}),
+		}, []string{"company", "endpoint", "policy"}),
+		budget: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "budget_exceeded_total",
+			Help:        "Total number of requests answered by the default decision after exceeding their budget",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company", "endpoint", "stage", "decision"}),
+		topic: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "malformed_topic_total",
+			Help:        "Total number of requests which are rejected because of their malformed topic",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company", "endpoint", "reason"}),
+		resolved: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "vendor_resolution_total",
+			Help:        "Total number of requests by the vendor which handled them and how it is resolved",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company", "endpoint", "resolution"}),
+		token: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "token_source_total",
+			Help:        "Total number of requests by the request field which their token is taken from",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company", "endpoint", "source"}),
+		maintenance: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "maintenance_requests_total",
+			Help:        "Total number of requests of vendors in maintenance which are answered without token verification",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company", "endpoint"}),
+		credential: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "malformed_credential_total",
+			Help:        "Total number of requests which are rejected because their token is not shaped like a JWT",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company", "endpoint", "reason"}),
+		deprecated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "deprecated_endpoint_total",
+			Help:        "Total number of requests of deprecated endpoints by their path",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"path"}),
+		normalized: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "token_normalized_total",
+			Help:        "Total number of tokens which needed normalization by lenient token parsing",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company", "endpoint"}),
+		method: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "auth_method_total",
+			Help:        "Total number of authentication and authorization attempts by their auth method",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company", "endpoint", "auth_method", "status"}),
 	}
 
-	m.register()
+	m.register(reg)
 
 	return m
 }
 
-func (m *APIMetrics) register() {
-	register(m.acl)
-	register(m.auth)
+func (m *APIMetrics) register(reg prometheus.Registerer) {
+	register(reg, m.payload)
+	register(reg, m.disabled)
+	register(reg, m.budget)
+	register(reg, m.topic)
+	register(reg, m.resolved)
+	register(reg, m.token)
+	register(reg, m.maintenance)
+	register(reg, m.credential)
+	register(reg, m.deprecated)
+	register(reg, m.normalized)
+	register(reg, m.method)
 }
 
 func (m *APIMetrics) AuthSuccess(company, source string) {
-	m.auth.WithLabelValues(company, "success", source).Inc()
+	m.authAttempt(company, "success", source, AuthMethodJWT)
 }
 
-// nolint:cyclop
 func (m *APIMetrics) AuthFailed(company, source string, err error) {
-	var (
-		status                     string
-		topicNotAllowedErrorTarget *serrors.TopicNotAllowedError
-		keyNotFoundErrorTarget     *serrors.KeyNotFoundError
-	)
+	m.authAttempt(company, Status(err), source, AuthMethodJWT)
+}
 
-	switch {
-	case errors.Is(err, serrors.ErrInvalidSigningMethod):
-		status = "err_invalid_signing_method"
-	case errors.Is(err, serrors.ErrIssNotFound):
-		status = "err_iss_not_found"
-	case errors.Is(err, serrors.ErrSubNotFound):
-		status = "err_sub_not_found"
-	case errors.Is(err, serrors.ErrInvalidClaims):
-		status = "err_invalid_claims"
-	case errors.Is(err, serrors.ErrInvalidIP):
-		status = "err_invalid_ip"
-	case errors.Is(err, serrors.ErrInvalidAccessType):
-		status = "err_invalid_access_type"
-	case errors.Is(err, serrors.ErrDecodeHashID):
-		status = "err_decode_hash_id"
-	case errors.Is(err, serrors.ErrInvalidSecret):
-		status = "err_invalid_secret"
-	case errors.Is(err, serrors.ErrIncorrectPassword):
-		status = "err_incorrect_password"
-	case errors.As(err, &topicNotAllowedErrorTarget):
-		status = "topic_not_allowed_error"
-	case errors.As(err, &keyNotFoundErrorTarget):
-		status = "key_not_found_error"
-	default:
-		status = "unknown_error"
-	}
+// StaticAuth counts authentication attempts of static clients, nil error means success.
+func (m *APIMetrics) StaticAuth(company, source string, err error) {
+	m.authAttempt(company, Status(err), source, AuthMethodStatic)
+}
+
+// AnonymousAuth counts authentication attempts of clients with empty credentials, nil error means success.
+func (m *APIMetrics) AnonymousAuth(company, source string, err error) {
+	m.authAttempt(company, Status(err), source, AuthMethodAnonymous)
+}
+
+func (m *APIMetrics) authAttempt(company, status, source, method string) {
+	m.auth.Add(context.Background(), 1, otelmetric.WithAttributes(
+		attribute.String("company", company),
+		attribute.String("status", status),
+		attribute.String("source", source),
+	))
+	m.method.WithLabelValues(company, "auth", method, status).Inc()
+}
+
+// PayloadTooLarge counts publishes that are denied because of their payload size,
+// source is the client-id source which helps to find the offending application version.
+func (m *APIMetrics) PayloadTooLarge(company, topicType, source string) {
+	m.payload.WithLabelValues(company, topicType, source).Inc()
+}
+
+// VendorDisabled counts requests of a disabled vendor on auth or acl endpoint.
+func (m *APIMetrics) VendorDisabled(company, endpoint, policy string) {
+	m.disabled.WithLabelValues(company, endpoint, policy).Inc()
+}
+
+// Maintenance counts requests of a vendor in maintenance on auth or acl endpoint.
+func (m *APIMetrics) Maintenance(company, endpoint string) {
+	m.maintenance.WithLabelValues(company, endpoint).Inc()
+}
+
+// BudgetExceeded counts requests which exceeded their latency budget on the given stage.
+func (m *APIMetrics) BudgetExceeded(company, endpoint, stage, decision string) {
+	m.budget.WithLabelValues(company, endpoint, stage, decision).Inc()
+}
+
+// VendorResolved counts requests by the vendor which handled them, resolution is vendor, issuer or unknown_issuer.
+func (m *APIMetrics) VendorResolved(company, endpoint, resolution string) {
+	m.resolved.WithLabelValues(company, endpoint, resolution).Inc()
+}
 
-	m.auth.WithLabelValues(company, status, source).Inc()
+// TokenSource counts requests by the field which their token is taken from, source is token, username or password.
+func (m *APIMetrics) TokenSource(company, endpoint, source string) {
+	m.token.WithLabelValues(company, endpoint, source).Inc()
+}
+
+// MalformedTopic counts requests which are rejected by topic sanitation with the rejection reason.
+func (m *APIMetrics) MalformedTopic(company, endpoint, reason string) {
+	m.topic.WithLabelValues(company, endpoint, reason).Inc()
+}
+
+// MalformedCredential counts requests which are rejected by the token shape check with the rejection reason.
+func (m *APIMetrics) MalformedCredential(company, endpoint, reason string) {
+	m.credential.WithLabelValues(company, endpoint, reason).Inc()
+}
+
+// TokenNormalized counts a token which is normalized by lenient token parsing.
+func (m *APIMetrics) TokenNormalized(company, endpoint string) {
+	m.normalized.WithLabelValues(company, endpoint).Inc()
+}
+
+// DeprecatedEndpoint counts a request of the deprecated endpoint.
+func (m *APIMetrics) DeprecatedEndpoint(path string) {
+	m.deprecated.WithLabelValues(path).Inc()
 }
 
 func (m *APIMetrics) ACLSuccess(company string) {
-	m.acl.WithLabelValues(company, "success").Inc()
+	m.aclAttempt(company, "success", AuthMethodJWT)
 }
 
-// nolint:cyclop
 func (m *APIMetrics) ACLFailed(company string, err error) {
+	m.aclAttempt(company, aclStatus(err), AuthMethodJWT)
+}
+
+// StaticACL counts authorization attempts of static clients, nil error means success.
+func (m *APIMetrics) StaticACL(company string, err error) {
+	m.aclAttempt(company, Status(err), AuthMethodStatic)
+}
+
+// AnonymousACL counts authorization attempts of clients with empty credentials, nil error means success.
+func (m *APIMetrics) AnonymousACL(company string, err error) {
+	m.aclAttempt(company, Status(err), AuthMethodAnonymous)
+}
+
+func (m *APIMetrics) aclAttempt(company, status, method string) {
+	m.acl.Add(context.Background(), 1, otelmetric.WithAttributes(
+		attribute.String("company", company),
+		attribute.String("status", status),
+	))
+	m.method.WithLabelValues(company, "acl", method, status).Inc()
+}
+
+// aclStatus returns the metric status of the given acl error, payload size is only checked on acl requests.
+func aclStatus(err error) string {
+	var payloadTooLargeErrorTarget serrors.PayloadTooLargeError
+
+	if errors.As(err, &payloadTooLargeErrorTarget) {
+		return "payload_too_large_error"
+	}
+
+	return Status(err)
+}
+
+// Status returns the metric status of the given error, nil error is a success.
+// nolint:cyclop
+func Status(err error) string {
 	var (
-		status                     string
 		topicNotAllowedErrorTarget *serrors.TopicNotAllowedError
 		keyNotFoundErrorTarget     *serrors.KeyNotFoundError
+		malformedTopicErrorTarget  serrors.MalformedTopicError
+		malformedCredentialTarget  serrors.MalformedCredentialError
+		iatSkewErrorTarget         serrors.IATSkewError
+		templateRenderErrorTarget  serrors.TemplateRenderError
+		subscriptionLimitTarget    serrors.SubscriptionLimitExceededError
+		rideMismatchTarget         serrors.RideMismatchError
+		invalidTopicFieldTarget    serrors.InvalidTopicFieldError
+		payloadMismatchTarget      serrors.PayloadMismatchError
+		policyDeniedTarget         serrors.PolicyDeniedError
 	)
 
 	switch {
+	case err == nil:
+		return "success"
 	case errors.Is(err, serrors.ErrInvalidSigningMethod):
-		status = "err_invalid_signing_method"
+		return "err_invalid_signing_method"
 	case errors.Is(err, serrors.ErrIssNotFound):
-		status = "err_iss_not_found"
+		return "err_iss_not_found"
 	case errors.Is(err, serrors.ErrSubNotFound):
-		status = "err_sub_not_found"
+		return "err_sub_not_found"
 	case errors.Is(err, serrors.ErrInvalidClaims):
-		status = "err_invalid_claims"
+		return "err_invalid_claims"
 	case errors.Is(err, serrors.ErrInvalidIP):
-		status = "err_invalid_ip"
+		return "err_invalid_ip"
 	case errors.Is(err, serrors.ErrInvalidAccessType):
-		status = "err_invalid_access_type"
+		return "err_invalid_access_type"
 	case errors.Is(err, serrors.ErrDecodeHashID):
-		status = "err_decode_hash_id"
+		return "err_decode_hash_id"
 	case errors.Is(err, serrors.ErrInvalidSecret):
-		status = "err_invalid_secret"
+		return "err_invalid_secret"
 	case errors.Is(err, serrors.ErrIncorrectPassword):
-		status = "err_incorrect_password"
+		return "err_incorrect_password"
+	case errors.Is(err, serrors.ErrPostAuthorizeDenied):
+		return "err_post_authorize_denied"
+	case errors.Is(err, serrors.ErrPostAuthorizeFailed):
+		return "err_post_authorize_failed"
+	case errors.Is(err, serrors.ErrStateCheckDenied):
+		return "err_state_check_denied"
+	case errors.Is(err, serrors.ErrStateCheckFailed):
+		return "err_state_check_failed"
+	case errors.Is(err, serrors.ErrUnknownIssuer):
+		return "err_unknown_issuer"
+	case errors.Is(err, serrors.ErrUnexpectedMountpoint):
+		return "err_unexpected_mountpoint"
+	case errors.Is(err, serrors.ErrEmptyCredentials):
+		return "err_empty_credentials"
+	case errors.Is(err, serrors.ErrMissingExpiry):
+		return "err_missing_expiry"
+	case errors.Is(err, serrors.ErrUnverifiedToken):
+		return "err_unverified_token"
+	case errors.Is(err, serrors.ErrInvalidSubjectFormat):
+		return "err_invalid_subject_format"
+	case errors.Is(err, serrors.ErrPolicyFailed):
+		return "err_policy_failed"
 	case errors.As(err, &topicNotAllowedErrorTarget):
-		status = "topic_not_allowed_error"
+		return "topic_not_allowed_error"
 	case errors.As(err, &keyNotFoundErrorTarget):
-		status = "key_not_found_error"
+		return "key_not_found_error"
+	case errors.As(err, &malformedTopicErrorTarget):
+		return "malformed_topic_error"
+	case errors.As(err, &malformedCredentialTarget):
+		return "malformed_credential_error"
+	case errors.As(err, &iatSkewErrorTarget):
+		return "iat_skew_error"
+	case errors.As(err, &templateRenderErrorTarget):
+		return "template_render_error"
+	case errors.As(err, &subscriptionLimitTarget):
+		return "subscription_limit_exceeded_error"
+	case errors.As(err, &rideMismatchTarget):
+		return "ride_mismatch_error"
+	case errors.As(err, &invalidTopicFieldTarget):
+		return "invalid_topic_field_error"
+	case errors.As(err, &payloadMismatchTarget):
+		return "payload_mismatch_error"
+	case errors.As(err, &policyDeniedTarget):
+		return "policy_denied_error"
 	default:
-		status = "unknown_error"
+		return "unknown_error"
+	}
+}
+
+type KeyMetrics struct {
+	loaded   *prometheus.GaugeVec
+	verified *prometheus.CounterVec
+	// reloaded counts the reloads of keys directories of vendors by their result.
+	reloaded *prometheus.CounterVec
+}
+
+func NewKeyMetrics(reg prometheus.Registerer) *KeyMetrics {
+	m := &KeyMetrics{
+		loaded: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "key_loaded",
+			Help:        "Loaded keys of vendors by their fingerprint",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"vendor", "issuer", "fingerprint"}),
+		verified: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "key_verified_total",
+			Help:        "Total number of verified tokens by the kid of their key",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"vendor", "issuer", "kid"}),
+		reloaded: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "key_reload_total",
+			Help:        "Total number of reloads of the keys directories of vendors by their result",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"vendor", "result"}),
+	}
+
+	m.register(reg)
+
+	return m
+}
+
+func (m *KeyMetrics) register(reg prometheus.Registerer) {
+	m.loaded = register(reg, m.loaded)
+	m.verified = register(reg, m.verified)
+	m.reloaded = register(reg, m.reloaded)
+}
+
+// Reloaded counts the reloads of keys directory of vendor, result is changed, unchanged or failed.
+func (m *KeyMetrics) Reloaded(vendor, result string) {
+	m.reloaded.WithLabelValues(vendor, result).Inc()
+}
+
+// Verified counts the verified tokens, kid is empty for the issuer keys without kid.
+func (m *KeyMetrics) Verified(vendor, issuer, kid string) {
+	m.verified.WithLabelValues(vendor, issuer, kid).Inc()
+}
+
+func (m *KeyMetrics) Loaded(vendor, issuer, fingerprint string) {
+	m.loaded.WithLabelValues(vendor, issuer, fingerprint).Set(1)
+}
+
+// Unloaded removes the replaced key of an issuer.
+func (m *KeyMetrics) Unloaded(vendor, issuer, fingerprint string) {
+	m.loaded.DeleteLabelValues(vendor, issuer, fingerprint)
+}
+
+type PostAuthorizeMetrics struct {
+	result *prometheus.CounterVec
+}
+
+func NewPostAuthorizeMetrics(reg prometheus.Registerer) *PostAuthorizeMetrics {
+	m := &PostAuthorizeMetrics{
+		result: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "post_authorize_total",
+			Help:        "Total number of post authorize webhook decisions",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company", "topic_type", "result"}),
+	}
+
+	m.register(reg)
+
+	return m
+}
+
+func (m *PostAuthorizeMetrics) register(reg prometheus.Registerer) {
+	m.result = register(reg, m.result)
+}
+
+// Result counts webhook decisions, result is allow, deny, cache or error.
+func (m *PostAuthorizeMetrics) Result(company, topicType, result string) {
+	m.result.WithLabelValues(company, topicType, result).Inc()
+}
+
+type SubscriptionLimitMetrics struct {
+	exceeded *prometheus.CounterVec
+}
+
+func NewSubscriptionLimitMetrics(reg prometheus.Registerer) *SubscriptionLimitMetrics {
+	m := &SubscriptionLimitMetrics{
+		exceeded: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "subscription_limit_exceeded_total",
+			Help:        "Total number of subscriptions which are denied because of subscription limit",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company", "topic_type", "issuer"}),
+	}
+
+	m.register(reg)
+
+	return m
+}
+
+func (m *SubscriptionLimitMetrics) register(reg prometheus.Registerer) {
+	m.exceeded = register(reg, m.exceeded)
+}
+
+func (m *SubscriptionLimitMetrics) Exceeded(company, topicType, issuer string) {
+	m.exceeded.WithLabelValues(company, topicType, issuer).Inc()
+}
+
+type TokenReuseMetrics struct {
+	suspected *prometheus.CounterVec
+}
+
+func NewTokenReuseMetrics(reg prometheus.Registerer) *TokenReuseMetrics {
+	m := &TokenReuseMetrics{
+		suspected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "token_reuse_suspected_total",
+			Help:        "Total number of tokens which are used from more client addresses than their maximum",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company", "issuer"}),
+	}
+
+	m.register(reg)
+
+	return m
+}
+
+func (m *TokenReuseMetrics) register(reg prometheus.Registerer) {
+	m.suspected = register(reg, m.suspected)
+}
+
+func (m *TokenReuseMetrics) Suspected(company, issuer string) {
+	m.suspected.WithLabelValues(company, issuer).Inc()
+}
+
+type StateCheckMetrics struct {
+	result *prometheus.CounterVec
+}
+
+func NewStateCheckMetrics(reg prometheus.Registerer) *StateCheckMetrics {
+	m := &StateCheckMetrics{
+		result: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "state_check_total",
+			Help:        "Total number of state check results",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company", "topic_type", "result"}),
+	}
+
+	m.register(reg)
+
+	return m
+}
+
+func (m *StateCheckMetrics) register(reg prometheus.Registerer) {
+	m.result = register(reg, m.result)
+}
+
+// Result counts state check results, result is active, inactive, cache or error.
+func (m *StateCheckMetrics) Result(company, topicType, result string) {
+	m.result.WithLabelValues(company, topicType, result).Inc()
+}
+
+type RemoteAccessMetrics struct {
+	result *prometheus.CounterVec
+}
+
+func NewRemoteAccessMetrics(reg prometheus.Registerer) *RemoteAccessMetrics {
+	m := &RemoteAccessMetrics{
+		result: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "remote_access_total",
+			Help:        "Total number of remote topic access results",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company", "topic_type", "result"}),
+	}
+
+	m.register(reg)
+
+	return m
+}
+
+func (m *RemoteAccessMetrics) register(reg prometheus.Registerer) {
+	m.result = register(reg, m.result)
+}
+
+// Result counts remote access results, result is remote, coalesced, cache, stale or error.
+func (m *RemoteAccessMetrics) Result(company, topicType, result string) {
+	m.result.WithLabelValues(company, topicType, result).Inc()
+}
+
+type TopicServerMetrics struct {
+	matches *prometheus.CounterVec
+}
+
+func NewTopicServerMetrics(reg prometheus.Registerer) *TopicServerMetrics {
+	m := &TopicServerMetrics{
+		matches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "topics_server_matches_total",
+			Help:        "Total number of topics which are matched by the topics server",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company", "endpoint", "result"}),
+	}
+
+	m.register(reg)
+
+	return m
+}
+
+func (m *TopicServerMetrics) register(reg prometheus.Registerer) {
+	m.matches = register(reg, m.matches)
+}
+
+// Match counts the matched topics of endpoint, result is matched, unmatched or error.
+func (m *TopicServerMetrics) Match(company, endpoint, result string) {
+	m.matches.WithLabelValues(company, endpoint, result).Inc()
+}
+
+type FailureRatioMetrics struct {
+	ratio *prometheus.GaugeVec
+}
+
+func NewFailureRatioMetrics(reg prometheus.Registerer) *FailureRatioMetrics {
+	m := &FailureRatioMetrics{
+		ratio: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "auth_failure_ratio",
+			Help:        "Ratio of failed authentications in the sliding window",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company", "issuer"}),
+	}
+
+	m.register(reg)
+
+	return m
+}
+
+func (m *FailureRatioMetrics) register(reg prometheus.Registerer) {
+	m.ratio = register(reg, m.ratio)
+}
+
+func (m *FailureRatioMetrics) Ratio(company, issuer string, ratio float64) {
+	m.ratio.WithLabelValues(company, issuer).Set(ratio)
+}
+
+type PolicyMetrics struct {
+	decision *prometheus.CounterVec
+	reload   *prometheus.CounterVec
+}
+
+func NewPolicyMetrics(reg prometheus.Registerer) *PolicyMetrics {
+	m := &PolicyMetrics{
+		decision: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "policy_decision_total",
+			Help:        "Total number of policy evaluations of vendors by their result",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company", "result"}),
+		reload: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "policy_reload_total",
+			Help:        "Total number of policy module loads of vendors by their result",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company", "result"}),
+	}
+
+	m.register(reg)
+
+	return m
+}
+
+func (m *PolicyMetrics) register(reg prometheus.Registerer) {
+	m.decision = register(reg, m.decision)
+	m.reload = register(reg, m.reload)
+}
+
+// Decision counts the policy evaluations, result is allow, deny or error.
+func (m *PolicyMetrics) Decision(company, result string) {
+	m.decision.WithLabelValues(company, result).Inc()
+}
+
+// Reloaded counts the loads of policy modules, result is loaded or failed.
+func (m *PolicyMetrics) Reloaded(company, result string) {
+	m.reload.WithLabelValues(company, result).Inc()
+}
+
+type InvalidTopicMetrics struct {
+	denied *prometheus.CounterVec
+	rate   *invalidTopicRates
+}
+
+// InvalidTopicRates calls yield with the current rate of invalid topic denials of each company and topic type.
+type InvalidTopicRates func(yield func(company, topicType string, rate float64))
+
+// invalidTopicRates collects the rates of invalid topic denials when they are scraped, so the rates decay with
+// their sliding window when denials stop. Trackers share the collector and the rates of the last one are collected.
+type invalidTopicRates struct {
+	desc  *prometheus.Desc
+	rates atomic.Pointer[InvalidTopicRates]
+}
+
+func (c *invalidTopicRates) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *invalidTopicRates) Collect(ch chan<- prometheus.Metric) {
+	rates := c.rates.Load()
+	if rates == nil {
+		return
+	}
+
+	(*rates)(func(company, topicType string, rate float64) {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, rate, company, topicType)
+	})
+}
+
+func NewInvalidTopicMetrics(reg prometheus.Registerer, rates InvalidTopicRates) *InvalidTopicMetrics {
+	m := &InvalidTopicMetrics{
+		denied: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "invalid_topic_denied_total",
+			Help:        "Total number of acl requests which are denied because of their invalid topic",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company", "topic_type"}),
+		rate: &invalidTopicRates{
+			desc: prometheus.NewDesc(
+				prometheus.BuildFQName("platform", "soteria", "invalid_topic_rate"),
+				"Rate of invalid topic denials per second in the sliding window",
+				[]string{"company", "topic_type"},
+				prometheus.Labels{},
+			),
+			rates: atomic.Pointer[InvalidTopicRates]{},
+		},
+	}
+
+	m.register(reg)
+
+	m.rate.rates.Store(&rates)
+
+	return m
+}
+
+func (m *InvalidTopicMetrics) register(reg prometheus.Registerer) {
+	m.denied = register(reg, m.denied)
+	m.rate = register(reg, m.rate)
+}
+
+// Denied counts an invalid topic denial, topic type is unmatched for the topics which match no template.
+func (m *InvalidTopicMetrics) Denied(company, topicType string) {
+	m.denied.WithLabelValues(company, topicType).Inc()
+}
+
+type TopicMetrics struct {
+	deprecated *prometheus.CounterVec
+	normalized *prometheus.CounterVec
+	matched    *prometheus.CounterVec
+}
+
+func NewTopicMetrics(reg prometheus.Registerer) *TopicMetrics {
+	m := &TopicMetrics{
+		deprecated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "deprecated_topic_total",
+			Help:        "Total number of topics which are matched by deprecated templates",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company", "type"}),
+		normalized: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "normalized_topic_total",
+			Help:        "Total number of topics which are changed by normalization",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company"}),
+		matched: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "topic_match_total",
+			Help:        "Total number of matched topics by their match, static or template",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company", "match"}),
 	}
 
-	m.acl.WithLabelValues(company, status).Inc()
+	m.register(reg)
+
+	return m
+}
+
+func (m *TopicMetrics) register(reg prometheus.Registerer) {
+	m.deprecated = register(reg, m.deprecated)
+	m.normalized = register(reg, m.normalized)
+	m.matched = register(reg, m.matched)
+}
+
+func (m *TopicMetrics) Deprecated(company, topicType string) {
+	m.deprecated.WithLabelValues(company, topicType).Inc()
+}
+
+// Normalized counts the topics of vendor which are changed by normalization.
+func (m *TopicMetrics) Normalized(company string) {
+	m.normalized.WithLabelValues(company).Inc()
+}
+
+// Matched counts the matched topics of vendor, match is static or template.
+func (m *TopicMetrics) Matched(company, match string) {
+	m.matched.WithLabelValues(company, match).Inc()
+}
+
+type LimiterMetrics struct {
+	inFlight *prometheus.GaugeVec
+	queue    *prometheus.GaugeVec
+	shed     *prometheus.CounterVec
+}
+
+func NewLimiterMetrics(reg prometheus.Registerer) *LimiterMetrics {
+	m := &LimiterMetrics{
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "limiter_in_flight",
+			Help:        "Number of in-flight requests of the limited endpoints",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"endpoint"}),
+		queue: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "limiter_queue_depth",
+			Help:        "Number of requests which are waiting for an in-flight slot",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"endpoint"}),
+		shed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "limiter_shed_total",
+			Help:        "Total number of requests which are shed by the limiter",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"endpoint", "reason"}),
+	}
+
+	m.register(reg)
+
+	return m
+}
+
+func (m *LimiterMetrics) register(reg prometheus.Registerer) {
+	m.inFlight = register(reg, m.inFlight)
+	m.queue = register(reg, m.queue)
+	m.shed = register(reg, m.shed)
+}
+
+func (m *LimiterMetrics) InFlight(endpoint string, count int) {
+	m.inFlight.WithLabelValues(endpoint).Set(float64(count))
+}
+
+func (m *LimiterMetrics) QueueDepth(endpoint string, depth int64) {
+	m.queue.WithLabelValues(endpoint).Set(float64(depth))
+}
+
+// Shed counts shed requests, reason is queue_full or timeout.
+func (m *LimiterMetrics) Shed(endpoint, reason string) {
+	m.shed.WithLabelValues(endpoint, reason).Inc()
+}
+
+type ConfigMetrics struct {
+	generation prometheus.Counter
+}
+
+func NewConfigMetrics(reg prometheus.Registerer) *ConfigMetrics {
+	m := &ConfigMetrics{
+		generation: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "config_generation",
+			Help:        "Number of swaps of the served vendor configuration since the process is started",
+			ConstLabels: prometheus.Labels{},
+		}),
+	}
+
+	m.register(reg)
+
+	return m
+}
+
+func (m *ConfigMetrics) register(reg prometheus.Registerer) {
+	m.generation = register(reg, m.generation)
+}
+
+func (m *ConfigMetrics) Swapped() {
+	m.generation.Inc()
+}
+
+type SessionCacheMetrics struct {
+	size    *prometheus.GaugeVec
+	lookups *prometheus.CounterVec
+}
+
+func NewSessionCacheMetrics(reg prometheus.Registerer) *SessionCacheMetrics {
+	m := &SessionCacheMetrics{
+		size: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "session_cache_size",
+			Help:        "Number of the cached session decisions of vendors",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company"}),
+		lookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "session_cache_total",
+			Help:        "Total number of session cache lookups of vendors by their result",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company", "result"}),
+	}
+
+	m.register(reg)
+
+	return m
+}
+
+func (m *SessionCacheMetrics) register(reg prometheus.Registerer) {
+	m.size = register(reg, m.size)
+	m.lookups = register(reg, m.lookups)
+}
+
+// Add changes the number of the cached session decisions of vendor by delta.
+func (m *SessionCacheMetrics) Add(company string, delta int) {
+	m.size.WithLabelValues(company).Add(float64(delta))
+}
+
+// Lookup counts the session cache lookups of vendor, result is hit or miss.
+func (m *SessionCacheMetrics) Lookup(company, result string) {
+	m.lookups.WithLabelValues(company, result).Inc()
+}
+
+type BackgroundMetrics struct {
+	tasks   *prometheus.GaugeVec
+	results *prometheus.CounterVec
+}
+
+func NewBackgroundMetrics(reg prometheus.Registerer) *BackgroundMetrics {
+	m := &BackgroundMetrics{
+		tasks: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "background_tasks",
+			Help:        "Number of the queued and running background tasks of components",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"component", "state"}),
+		results: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "background_tasks_total",
+			Help:        "Total number of the background tasks of components by their result",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"component", "result"}),
+	}
+
+	m.register(reg)
+
+	return m
+}
+
+func (m *BackgroundMetrics) register(reg prometheus.Registerer) {
+	m.tasks = register(reg, m.tasks)
+	m.results = register(reg, m.results)
+}
+
+// State changes the number of tasks of component in state, state is queued or running.
+func (m *BackgroundMetrics) State(component, state string, delta float64) {
+	m.tasks.WithLabelValues(component, state).Add(delta)
+}
+
+// Result counts the tasks of component, result is done, panic or dropped.
+func (m *BackgroundMetrics) Result(component, result string) {
+	m.results.WithLabelValues(component, result).Inc()
+}
+
+type NoExpiryMetrics struct {
+	tokens *prometheus.CounterVec
+}
+
+func NewNoExpiryMetrics(reg prometheus.Registerer) *NoExpiryMetrics {
+	m := &NoExpiryMetrics{
+		tokens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "no_expiry_token_total",
+			Help:        "Total number of the accepted tokens without exp claim of vendors by their subject",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company", "sub"}),
+	}
+
+	m.register(reg)
+
+	return m
+}
+
+func (m *NoExpiryMetrics) register(reg prometheus.Registerer) {
+	m.tokens = register(reg, m.tokens)
+}
+
+// Used counts the accepted tokens without exp claim, subjects are bounded by the vendor configuration.
+func (m *NoExpiryMetrics) Used(company, sub string) {
+	m.tokens.WithLabelValues(company, sub).Inc()
+}
+
+type BuildMetrics struct {
+	info *prometheus.GaugeVec
+}
+
+func NewBuildMetrics(reg prometheus.Registerer) *BuildMetrics {
+	m := &BuildMetrics{
+		info: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "build_info",
+			Help:        "Build information of the running binary, it is always one",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"version", "sha"}),
+	}
+
+	m.register(reg)
+
+	return m
+}
+
+func (m *BuildMetrics) register(reg prometheus.Registerer) {
+	m.info = register(reg, m.info)
+}
+
+// Info exports the version and commit of the running binary.
+func (m *BuildMetrics) Info(version, sha string) {
+	m.info.WithLabelValues(version, sha).Set(1)
+}
+
+type ValidatorEndpointMetrics struct {
+	latency otelmetric.Float64Histogram
+	healthy *prometheus.GaugeVec
+}
+
+func NewValidatorEndpointMetrics(reg prometheus.Registerer) *ValidatorEndpointMetrics {
+	m := &ValidatorEndpointMetrics{
+		latency: must(otel.Meter(meterName).Float64Histogram(
+			"platform_soteria_validator_endpoint_latency_seconds",
+			otelmetric.WithDescription("Latency of the validator calls in seconds by their endpoint"),
+			otelmetric.WithUnit("s"),
+			otelmetric.WithExplicitBucketBoundaries(prometheus.DefBuckets...),
+		)),
+		healthy: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "validator_endpoint_healthy",
+			Help:        "Health of the validator endpoints, unhealthy endpoints are ejected from the ring",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"endpoint"}),
+	}
+
+	m.register(reg)
+
+	return m
+}
+
+func (m *ValidatorEndpointMetrics) register(reg prometheus.Registerer) {
+	m.healthy = register(reg, m.healthy)
+}
+
+// Latency records the latency of a validator call of endpoint.
+func (m *ValidatorEndpointMetrics) Latency(endpoint string, latency float64, err error) {
+	m.latency.Record(context.Background(), latency, otelmetric.WithAttributes(
+		attribute.String("endpoint", endpoint),
+		attribute.String("status", Status(err)),
+	))
+}
+
+// Healthy exports the health of endpoint.
+func (m *ValidatorEndpointMetrics) Healthy(endpoint string, healthy bool) {
+	value := 0.0
+	if healthy {
+		value = 1
+	}
+
+	m.healthy.WithLabelValues(endpoint).Set(value)
+}
+
+type ClaimGuardMetrics struct {
+	missing *prometheus.GaugeVec
+}
+
+func NewClaimGuardMetrics(reg prometheus.Registerer) *ClaimGuardMetrics {
+	m := &ClaimGuardMetrics{
+		missing: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   "platform",
+			Subsystem:   "soteria",
+			Name:        "claim_missing_ratio",
+			Help:        "Ratio of the sampled tokens without the configured issuer or subject claim in the window",
+			ConstLabels: prometheus.Labels{},
+		}, []string{"company", "claim"}),
+	}
+
+	m.register(reg)
+
+	return m
+}
+
+func (m *ClaimGuardMetrics) register(reg prometheus.Registerer) {
+	m.missing = register(reg, m.missing)
+}
+
+// Missing exports the ratio of sampled tokens without the configured claim, claim is iss or sub.
+func (m *ClaimGuardMetrics) Missing(company, claim string, ratio float64) {
+	m.missing.WithLabelValues(company, claim).Set(ratio)
 }
@@ -3,15 +3,18 @@ package metric_test
 import (
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	serrors "github.com/snapp-incubator/soteria/internal/errors"
 	"github.com/snapp-incubator/soteria/internal/metric"
+	"github.com/stretchr/testify/require"
 )
 
 func TestAuthIncrement(t *testing.T) {
 	t.Parallel()
 
-	m := metric.NewAPIMetrics()
+	m := metric.NewAPIMetrics(prometheus.DefaultRegisterer)
 
 	m.AuthSuccess("snapp", "-")
 	m.AuthFailed("snapp", "-", serrors.ErrInvalidSigningMethod)
@@ -32,8 +35,14 @@ func TestAuthIncrement(t *testing.T) {
 		TopicType:  "pub",
 	})
 	m.AuthFailed("snapp", "-", &serrors.KeyNotFoundError{Issuer: "iss"})
+	m.AuthFailed("snapp", "-", serrors.TemplateRenderError{TopicType: "chat", Field: "uid", Err: nil})
 	m.AuthFailed("snapp", "-", errors.ErrUnsupported)
 
+	m.ACLFailed("snapp", serrors.ErrUnexpectedMountpoint)
+	m.ACLFailed("snapp", serrors.ErrEmptyCredentials)
+	m.VendorResolved("snapp", "auth", "issuer")
+	m.TokenSource("snapp", "auth", "password")
+
 	m.ACLSuccess("snapp")
 	m.ACLFailed("snapp", serrors.ErrInvalidSigningMethod)
 	m.ACLFailed("snapp", serrors.ErrIssNotFound)
@@ -53,5 +62,264 @@ func TestAuthIncrement(t *testing.T) {
 		TopicType:  "pub",
 	})
 	m.ACLFailed("snapp", &serrors.KeyNotFoundError{Issuer: "iss"})
+	m.ACLFailed("snapp", serrors.PayloadTooLargeError{
+		Topic:     "topic",
+		TopicType: "driver_location",
+		Size:      2048,
+		MaxSize:   1024,
+	})
+	m.ACLFailed("snapp", serrors.ErrPostAuthorizeDenied)
+	m.ACLFailed("snapp", serrors.ErrPostAuthorizeFailed)
+	m.ACLFailed("snapp", serrors.ErrStateCheckDenied)
+	m.ACLFailed("snapp", serrors.ErrStateCheckFailed)
+	m.ACLFailed("snapp", serrors.ErrUnverifiedToken)
+	m.ACLFailed("snapp", serrors.ErrInvalidSubjectFormat)
+	m.ACLFailed("snapp", serrors.MalformedTopicError{Reason: "too_long", Length: 2048})
+	m.ACLFailed("snapp", serrors.PolicyDeniedError{TopicType: "chat", Issuer: "1", Sub: "sub", Cause: "night"})
+	m.ACLFailed("snapp", serrors.ErrPolicyFailed)
+	m.ACLFailed("snapp", serrors.MalformedCredentialError{Reason: "alphabet", Length: 64, Err: nil})
+	m.ACLFailed("snapp", serrors.SubscriptionLimitExceededError{TopicType: "chat", Issuer: "1", Sub: "sub", Max: 10})
+	m.ACLFailed("snapp", serrors.RideMismatchError{TopicType: "shared_location", Issuer: "1", Sub: "sub", Claim: "ride_id", Missing: false})
 	m.ACLFailed("snapp", errors.ErrUnsupported)
+
+	m.PayloadTooLarge("snapp", "driver_location", "-")
+	m.VendorDisabled("snapp", "acl", "deny")
+	m.Maintenance("snapp", "acl")
+	m.BudgetExceeded("snapp", "acl", "validator", "deny")
+	m.MalformedTopic("snapp", "acl", "too_long")
+	m.MalformedCredential("snapp", "auth", "alphabet")
+	m.TokenNormalized("snapp", "auth")
+	m.DeprecatedEndpoint("/v1/auth")
+	m.StaticAuth("snapp", "-", nil)
+	m.StaticAuth("snapp", "-", serrors.ErrIncorrectPassword)
+	m.StaticACL("snapp", nil)
+	m.AnonymousAuth("snapp", "-", nil)
+	m.AnonymousAuth("snapp", "-", serrors.ErrEmptyCredentials)
+	m.AnonymousACL("snapp", nil)
+	m.AuthFailed("snapp", "-", serrors.IATSkewError{Issuer: "0", IssuedAt: time.Now(), Err: errors.ErrUnsupported})
+}
+
+func TestAuthMethod(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	reg := prometheus.NewRegistry()
+
+	m := metric.NewAPIMetrics(reg)
+
+	m.AuthSuccess("snapp", "-")
+	m.StaticAuth("snapp", "-", serrors.ErrIncorrectPassword)
+	m.AnonymousACL("snapp", nil)
+
+	families, err := reg.Gather()
+	require.NoError(err)
+
+	for _, family := range families {
+		if family.GetName() != "platform_soteria_auth_method_total" {
+			continue
+		}
+
+		methods := make([]string, 0)
+
+		for _, m := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range m.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+
+			methods = append(methods, labels["endpoint"]+"/"+labels["auth_method"]+"/"+labels["status"])
+		}
+
+		require.ElementsMatch([]string{
+			"auth/jwt/success", "auth/static/err_incorrect_password", "acl/anonymous/success",
+		}, methods)
+
+		return
+	}
+
+	require.Fail("auth method is not counted")
+}
+
+func TestAutoAuthenticatorMetrics(t *testing.T) {
+	t.Parallel()
+
+	m := metric.NewAutoAuthenticatorMetrics(prometheus.DefaultRegisterer)
+
+	m.Latency(0.1, "snapp", nil)
+	m.IATSkew("snapp", "0", "recovered")
+	m.IATSkew("snapp", "0", "rejected")
+	m.Coalesced("snapp", "validator")
+	m.ValidationCache("snapp", "stale")
+	m.ACLVerification("snapp", "unverified")
+}
+
+func TestStateCheckMetrics(t *testing.T) {
+	t.Parallel()
+
+	m := metric.NewStateCheckMetrics(prometheus.DefaultRegisterer)
+
+	m.Result("snapp", "chat", "active")
+	m.Result("snapp", "chat", "error")
+}
+
+func TestRemoteAccessMetrics(t *testing.T) {
+	t.Parallel()
+
+	m := metric.NewRemoteAccessMetrics(prometheus.DefaultRegisterer)
+
+	m.Result("snapp", "chat", "remote")
+	m.Result("snapp", "chat", "stale")
+}
+
+func TestTopicServerMetrics(t *testing.T) {
+	t.Parallel()
+
+	m := metric.NewTopicServerMetrics(prometheus.DefaultRegisterer)
+
+	m.Match("snapp", "match", "matched")
+	m.Match("snapp", "bulk", "unmatched")
+}
+
+func TestSubscriptionLimitMetrics(t *testing.T) {
+	t.Parallel()
+
+	metric.NewSubscriptionLimitMetrics(prometheus.DefaultRegisterer).Exceeded("snapp", "chat", "1")
+}
+
+func TestTokenReuseMetrics(t *testing.T) {
+	t.Parallel()
+
+	metric.NewTokenReuseMetrics(prometheus.DefaultRegisterer).Suspected("snapp", "1")
+}
+
+func TestFailureRatioMetrics(t *testing.T) {
+	t.Parallel()
+
+	metric.NewFailureRatioMetrics(prometheus.DefaultRegisterer).Ratio("snapp", "0", 0.5)
+}
+
+func TestTopicMetrics(t *testing.T) {
+	t.Parallel()
+
+	metric.NewTopicMetrics(prometheus.DefaultRegisterer).Deprecated("snapp", "chat")
+	metric.NewTopicMetrics(prometheus.DefaultRegisterer).Normalized("snapp")
+	metric.NewTopicMetrics(prometheus.DefaultRegisterer).Matched("snapp", "static")
+}
+
+func TestLimiterMetrics(t *testing.T) {
+	t.Parallel()
+
+	m := metric.NewLimiterMetrics(prometheus.DefaultRegisterer)
+
+	m.InFlight("auth", 1)
+	m.QueueDepth("auth", 1)
+	m.Shed("auth", "timeout")
+}
+
+func TestConfigMetrics(t *testing.T) {
+	t.Parallel()
+
+	metric.NewConfigMetrics(prometheus.DefaultRegisterer).Swapped()
+}
+
+func TestKeyMetrics(t *testing.T) {
+	t.Parallel()
+
+	m := metric.NewKeyMetrics(prometheus.DefaultRegisterer)
+
+	m.Loaded("snapp", "0", "fingerprint")
+	m.Verified("snapp", "0", "")
+	m.Reloaded("snapp", "changed")
+	m.Unloaded("snapp", "0", "fingerprint")
+}
+
+func TestInvalidTopicMetrics(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	reg := prometheus.NewRegistry()
+
+	m := metric.NewInvalidTopicMetrics(reg, func(yield func(company, topicType string, rate float64)) {
+		yield("snapp", "unmatched", 0.5)
+	})
+	m.Denied("snapp", "unmatched")
+
+	// rates are collected when they are scraped.
+	families, err := reg.Gather()
+	require.NoError(err)
+
+	for _, family := range families {
+		if family.GetName() != "platform_soteria_invalid_topic_rate" {
+			continue
+		}
+
+		require.Len(family.GetMetric(), 1)
+		require.InDelta(0.5, family.GetMetric()[0].GetGauge().GetValue(), 0)
+
+		return
+	}
+
+	require.Fail("invalid topic rate is not collected")
+}
+
+func TestPolicyMetrics(t *testing.T) {
+	t.Parallel()
+
+	m := metric.NewPolicyMetrics(prometheus.DefaultRegisterer)
+
+	m.Decision("snapp", "deny")
+	m.Reloaded("snapp", "failed")
+}
+
+func TestSessionCacheMetrics(t *testing.T) {
+	t.Parallel()
+
+	m := metric.NewSessionCacheMetrics(prometheus.DefaultRegisterer)
+
+	m.Add("snapp", 1)
+	m.Lookup("snapp", "hit")
+}
+
+func TestBackgroundMetrics(t *testing.T) {
+	t.Parallel()
+
+	m := metric.NewBackgroundMetrics(prometheus.DefaultRegisterer)
+
+	m.State("revalidation", "queued", 1)
+	m.Result("revalidation", "done")
+}
+
+func TestNoExpiryMetrics(t *testing.T) {
+	t.Parallel()
+
+	m := metric.NewNoExpiryMetrics(prometheus.DefaultRegisterer)
+
+	m.Used("snapp", "dispatcher")
+}
+
+func TestBuildMetrics(t *testing.T) {
+	t.Parallel()
+
+	m := metric.NewBuildMetrics(prometheus.DefaultRegisterer)
+
+	m.Info("v1.0.0", "0123abc")
+}
+
+func TestValidatorEndpointMetrics(t *testing.T) {
+	t.Parallel()
+
+	m := metric.NewValidatorEndpointMetrics(prometheus.DefaultRegisterer)
+
+	m.Latency("http://validator-1", 0.1, nil)
+	m.Healthy("http://validator-1", false)
+}
+
+func TestClaimGuardMetrics(t *testing.T) {
+	t.Parallel()
+
+	m := metric.NewClaimGuardMetrics(prometheus.DefaultRegisterer)
+
+	m.Missing("snapp", "sub", 0.5)
 }
@@ -6,8 +6,10 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-func register[T prometheus.Collector](metric T) T {
-	if err := prometheus.Register(metric); err != nil {
+// register registers the metric on reg and returns the registered metric when it is already registered,
+// so components which are created more than once share their metrics.
+func register[T prometheus.Collector](reg prometheus.Registerer, metric T) T {
+	if err := reg.Register(metric); err != nil {
 		var are prometheus.AlreadyRegisteredError
 		if ok := errors.As(err, &are); ok {
 			metric, ok = are.ExistingCollector.(T)
@@ -21,3 +23,12 @@ func register[T prometheus.Collector](metric T) T {
 
 	return metric
 }
+
+// must panics on the errors of OpenTelemetry instrument creation like register does.
+func must[T any](instrument T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+
+	return instrument
+}
@@ -0,0 +1,347 @@
+// Package policy evaluates the accesses of a vendor by an OPA policy, so complex rules like time of day
+// or app version from claims don't need changes of Soteria. Rego modules are read from files and compiled
+// by the embedded OPA evaluator on startup and when they change, so decisions are evaluated in process.
+// Policy either overrides the static accesses of topics or is a second gate after them.
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/open-policy-agent/opa/v1/rego"
+	"github.com/prometheus/client_golang/prometheus"
+	serrors "github.com/snapp-incubator/soteria/internal/errors"
+	"github.com/snapp-incubator/soteria/internal/metric"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	// ModeGate evaluates the policy after the static accesses allow the access.
+	ModeGate = "gate"
+	// ModeOverride evaluates the policy instead of the static accesses, which are given in its input.
+	ModeOverride = "override"
+
+	DefaultTimeout = 50 * time.Millisecond
+
+	// maxReasonLength bounds the reasons of policy in responses.
+	maxReasonLength = 64
+)
+
+var (
+	ErrFailed         = serrors.ErrPolicyFailed
+	ErrInvalidModule  = errors.New("policy modules cannot be compiled")
+	ErrInvalidMode    = errors.New("policy mode should be gate or override")
+	ErrMissingPath    = errors.New("policy requires the path of its decision")
+	ErrMissingModules = errors.New("policy requires its rego modules")
+	ErrNotLoaded      = errors.New("policy modules are not loaded")
+	ErrInvalidResult  = errors.New("decision should be a boolean or an object")
+)
+
+type Config struct {
+	// Path is the path of decision document, e.g. soteria/authz is evaluated by data.soteria.authz query.
+	Path string `json:"path,omitempty" koanf:"path"`
+	// Modules are the files of rego modules which are compiled on startup and when they change.
+	Modules []string `json:"modules,omitempty" koanf:"modules"`
+	// Mode is gate or override, empty mode is gate.
+	Mode    string        `json:"mode,omitempty"    koanf:"mode"`
+	Timeout time.Duration `json:"timeout,omitempty" koanf:"timeout"`
+	// FailOpen uses the decision of static accesses when policy cannot be evaluated, otherwise access is denied.
+	FailOpen bool `json:"fail_open,omitempty" koanf:"fail_open"`
+}
+
+// Validate checks the configuration without compiling the modules.
+func (c Config) Validate() error {
+	if c.Mode != "" && c.Mode != ModeGate && c.Mode != ModeOverride {
+		return fmt.Errorf("%w: %q", ErrInvalidMode, c.Mode)
+	}
+
+	if strings.Trim(c.Path, "/") == "" {
+		return ErrMissingPath
+	}
+
+	if len(c.Modules) == 0 {
+		return ErrMissingModules
+	}
+
+	return nil
+}
+
+// query returns the rego query of decision path.
+func (c Config) query() string {
+	return "data." + strings.ReplaceAll(strings.Trim(c.Path, "/"), "/", ".")
+}
+
+// Input is the input document of policy.
+type Input struct {
+	Company   string         `json:"company"`
+	Claims    map[string]any `json:"claims"`
+	Issuer    string         `json:"issuer"`
+	Sub       string         `json:"sub"`
+	Entity    string         `json:"entity"`
+	Topic     string         `json:"topic"`
+	TopicType string         `json:"topic_type"`
+	Access    string         `json:"access"`
+	// ClientAttrs are the client attributes of token, like its hash-id.
+	ClientAttrs any `json:"client_attrs"`
+	// Static is the decision of the static accesses of topic.
+	Static bool `json:"static"`
+}
+
+// Decision is the decision document of policy, policies can also return a boolean.
+type Decision struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason"`
+}
+
+// Policy is safe for concurrent use.
+type Policy struct {
+	cfg     Config
+	company string
+	tracer  trace.Tracer
+	metrics *metric.PolicyMetrics
+	logger  *zap.Logger
+
+	// lock serializes the loads, prepared is the query of the last successful load which is replaced as a whole.
+	lock     sync.Mutex
+	prepared atomic.Pointer[rego.PreparedEvalQuery]
+}
+
+func New(cfg Config, company string, tracer trace.Tracer, reg prometheus.Registerer, logger *zap.Logger) *Policy {
+	if cfg.Mode == "" {
+		cfg.Mode = ModeGate
+	}
+
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+
+	return &Policy{
+		cfg:      cfg,
+		company:  company,
+		tracer:   tracer,
+		metrics:  metric.NewPolicyMetrics(reg),
+		logger:   logger,
+		lock:     sync.Mutex{},
+		prepared: atomic.Pointer[rego.PreparedEvalQuery]{},
+	}
+}
+
+// Modules returns the files of rego modules of policy.
+func (p *Policy) Modules() []string {
+	return p.cfg.Modules
+}
+
+// Allows evaluates the access by policy, static is the decision of the static accesses of topic.
+// It returns false without error when static accesses deny the access of a gate policy,
+// and PolicyDeniedError when policy denies it.
+func (p *Policy) Allows(ctx context.Context, static bool, input Input) (bool, error) {
+	if p.cfg.Mode == ModeGate && !static {
+		return false, nil
+	}
+
+	input.Company = p.company
+	input.Static = static
+
+	ctx, span := p.tracer.Start(ctx, "policy.evaluate")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("topic-type", input.TopicType),
+		attribute.String("mode", p.cfg.Mode),
+	)
+
+	decision, err := p.evaluate(ctx, input)
+	if err != nil {
+		span.RecordError(err)
+		p.metrics.Decision(p.company, "error")
+
+		p.logger.Error("policy evaluation failed",
+			zap.Error(err),
+			zap.String("topic-type", input.TopicType),
+			zap.Bool("fail-open", p.cfg.FailOpen),
+		)
+
+		if p.cfg.FailOpen {
+			return static, nil
+		}
+
+		return false, fmt.Errorf("%w: %w", ErrFailed, err)
+	}
+
+	if !decision.Allow {
+		p.metrics.Decision(p.company, "deny")
+
+		return false, serrors.PolicyDeniedError{
+			TopicType: input.TopicType,
+			Issuer:    input.Issuer,
+			Sub:       input.Sub,
+			Cause:     decision.Reason[:min(len(decision.Reason), maxReasonLength)],
+		}
+	}
+
+	p.metrics.Decision(p.company, "allow")
+
+	return true, nil
+}
+
+func (p *Policy) evaluate(ctx context.Context, input Input) (Decision, error) {
+	prepared := p.prepared.Load()
+	if prepared == nil {
+		return Decision{}, ErrNotLoaded // nolint: exhaustruct
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.Timeout)
+	defer cancel()
+
+	results, err := prepared.Eval(ctx, rego.EvalInput(input))
+	if err != nil {
+		return Decision{}, fmt.Errorf("evaluation failed %w", err) // nolint: exhaustruct
+	}
+
+	// undefined decisions have no result and they deny the access.
+	if len(results) == 0 || len(results[0].Expressions) == 0 {
+		return Decision{}, nil // nolint: exhaustruct
+	}
+
+	switch result := results[0].Expressions[0].Value.(type) {
+	case bool:
+		return Decision{Allow: result, Reason: ""}, nil
+	case map[string]any:
+		allow, _ := result["allow"].(bool)
+		reason, _ := result["reason"].(string)
+
+		return Decision{Allow: allow, Reason: reason}, nil
+	default:
+		return Decision{}, fmt.Errorf("%w: %T", ErrInvalidResult, result) // nolint: exhaustruct
+	}
+}
+
+// Load reads the rego modules and compiles them with the decision query. The compiled query replaces the
+// previous one only when every module is read and compiled, so a failed load keeps the previous modules
+// as a whole and decisions never see a part of a load.
+func (p *Policy) Load(ctx context.Context) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	prepared, err := p.prepare(ctx)
+	if err != nil {
+		p.metrics.Reloaded(p.company, "failed")
+
+		return err
+	}
+
+	p.prepared.Store(prepared)
+	p.metrics.Reloaded(p.company, "loaded")
+
+	return nil
+}
+
+func (p *Policy) prepare(ctx context.Context) (*rego.PreparedEvalQuery, error) {
+	options := []func(*rego.Rego){rego.Query(p.cfg.query())}
+	errs := make([]error, 0)
+
+	for _, path := range p.cfg.Modules {
+		module, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot read module %s %w", path, err))
+
+			continue
+		}
+
+		options = append(options, rego.Module(moduleID(path), string(module)))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	prepared, err := rego.New(options...).PrepareForEval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidModule, err)
+	}
+
+	return &prepared, nil
+}
+
+// moduleID returns the id of module in compiler which is its absolute path, so modules with the same name
+// in different directories don't replace each other.
+func moduleID(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	return filepath.ToSlash(path)
+}
+
+// Watch loads the rego modules again when their files change until context is done,
+// failed loads are logged and the previous modules are kept.
+func (p *Policy) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("cannot create policy watcher %w", err)
+	}
+
+	// directories are watched because editors and config maps replace the files.
+	dirs := make(map[string]struct{})
+
+	for _, path := range p.cfg.Modules {
+		dir := filepath.Dir(path)
+
+		if _, ok := dirs[dir]; ok {
+			continue
+		}
+
+		dirs[dir] = struct{}{}
+
+		if err := watcher.Add(dir); err != nil {
+			_ = watcher.Close()
+
+			return fmt.Errorf("cannot watch policy directory %s %w", dir, err)
+		}
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+					continue
+				}
+
+				if err := p.Load(ctx); err != nil {
+					p.logger.Error("policy reload failed, previous modules are kept", zap.Error(err))
+
+					continue
+				}
+
+				p.logger.Info("policy reloaded", zap.Strings("modules", p.cfg.Modules))
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				p.logger.Error("policy watcher failed", zap.Error(err))
+			}
+		}
+	}()
+
+	return nil
+}
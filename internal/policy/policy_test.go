@@ -0,0 +1,207 @@
+package policy_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	serrors "github.com/snapp-incubator/soteria/internal/errors"
+	"github.com/snapp-incubator/soteria/internal/policy"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+)
+
+// authz chooses the decisions by the app_version claim of input.
+const authz = `package soteria.authz
+
+allow if input.claims.app_version == "new"
+
+allow if {
+	input.claims.app_version == "static"
+	input.static
+}
+
+reason := "app_version_too_old" if input.claims.app_version == "old"
+`
+
+func input(version string) policy.Input {
+	// nolint: exhaustruct
+	return policy.Input{
+		Claims:    map[string]any{"app_version": version},
+		Issuer:    "1",
+		Sub:       "DXKgaNQa7N5Y7bo",
+		TopicType: "chat",
+		Topic:     "snapp/chat",
+		Access:    "subscribe",
+	}
+}
+
+func module(t *testing.T, name, text string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+
+	require.NoError(t, os.WriteFile(path, []byte(text), 0o600))
+
+	return path
+}
+
+func newPolicy(path, mode string, failOpen bool, modules ...string) *policy.Policy {
+	return policy.New(policy.Config{
+		Path:     path,
+		Modules:  modules,
+		Mode:     mode,
+		Timeout:  time.Second,
+		FailOpen: failOpen,
+	}, "snapp", noop.NewTracerProvider().Tracer(""), prometheus.NewRegistry(), zap.NewNop())
+}
+
+// nolint: funlen
+func TestAllows(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	ctx := context.Background()
+	path := module(t, "authz.rego", authz)
+
+	gate := newPolicy("soteria/authz", policy.ModeGate, false, path)
+	require.NoError(gate.Load(ctx))
+
+	allowed, err := gate.Allows(ctx, true, input("new"))
+	require.NoError(err)
+	require.True(allowed)
+
+	// static denials of gate policies are not evaluated.
+	allowed, err = gate.Allows(ctx, false, input("new"))
+	require.NoError(err)
+	require.False(allowed)
+
+	allowed, err = gate.Allows(ctx, true, input("old"))
+	require.False(allowed)
+
+	var pdErr serrors.PolicyDeniedError
+	require.ErrorAs(err, &pdErr)
+	require.Equal("app_version_too_old", pdErr.Reason())
+	require.Equal("chat", pdErr.TopicType)
+
+	// decisions without allow deny the access with the default reason.
+	_, err = gate.Allows(ctx, true, input("unknown"))
+	require.ErrorAs(err, &pdErr)
+	require.Equal(serrors.ReasonPolicyDenied, pdErr.Reason())
+
+	// boolean decisions are supported and undefined decisions deny the access.
+	boolean := newPolicy("soteria/authz/allow", policy.ModeGate, false, path)
+	require.NoError(boolean.Load(ctx))
+
+	allowed, err = boolean.Allows(ctx, true, input("new"))
+	require.NoError(err)
+	require.True(allowed)
+
+	_, err = boolean.Allows(ctx, true, input("unknown"))
+	require.ErrorAs(err, &pdErr)
+
+	invalid := newPolicy("soteria/authz/reason", policy.ModeGate, false, path)
+	require.NoError(invalid.Load(ctx))
+
+	_, err = invalid.Allows(ctx, true, input("old"))
+	require.ErrorIs(err, policy.ErrFailed)
+	require.ErrorIs(err, policy.ErrInvalidResult)
+
+	override := newPolicy("soteria/authz", policy.ModeOverride, false, path)
+	require.NoError(override.Load(ctx))
+
+	// override policies are evaluated on static denials and they are given the static decision.
+	allowed, err = override.Allows(ctx, false, input("new"))
+	require.NoError(err)
+	require.True(allowed)
+
+	_, err = override.Allows(ctx, false, input("static"))
+	require.ErrorAs(err, &pdErr)
+
+	allowed, err = override.Allows(ctx, true, input("static"))
+	require.NoError(err)
+	require.True(allowed)
+
+	// policies which are not loaded cannot be evaluated.
+	_, err = newPolicy("soteria/authz", policy.ModeOverride, false, path).Allows(ctx, true, input("new"))
+	require.ErrorIs(err, policy.ErrFailed)
+	require.ErrorIs(err, policy.ErrNotLoaded)
+
+	// fail open policies use the static decision when policy cannot be evaluated.
+	failOpen := newPolicy("soteria/authz", policy.ModeOverride, true, path)
+
+	allowed, err = failOpen.Allows(ctx, true, input("new"))
+	require.NoError(err)
+	require.True(allowed)
+
+	allowed, err = failOpen.Allows(ctx, false, input("new"))
+	require.NoError(err)
+	require.False(allowed)
+}
+
+// nolint: funlen
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "authz.rego")
+	// modules with the same name in different directories are different modules.
+	shared := filepath.Join(dir, "shared", "authz.rego")
+
+	require.NoError(os.Mkdir(filepath.Dir(shared), 0o700))
+	require.NoError(os.WriteFile(path, []byte("package soteria.authz\n\nallow if data.soteria.shared.version == 1\n"), 0o600))
+	require.NoError(os.WriteFile(shared, []byte("package soteria.shared\n\nversion := 1\n"), 0o600))
+
+	p := newPolicy("soteria/authz", policy.ModeGate, false, path, shared)
+
+	require.NoError(p.Load(ctx))
+
+	allowed, err := p.Allows(ctx, true, input("new"))
+	require.NoError(err)
+	require.True(allowed)
+
+	// rejected loads keep all of the previous modules.
+	require.NoError(os.WriteFile(path, []byte("package soteria.authz\n\ndefault allow := false\n"), 0o600))
+	require.NoError(os.WriteFile(shared, []byte("package soteria.shared\n\ninvalid version\n"), 0o600))
+	require.ErrorIs(p.Load(ctx), policy.ErrInvalidModule)
+
+	allowed, err = p.Allows(ctx, true, input("new"))
+	require.NoError(err)
+	require.True(allowed)
+
+	// unreadable modules fail the load before compiling.
+	require.NoError(os.WriteFile(shared, []byte("package soteria.shared\n\nversion := 2\n"), 0o600))
+	require.NoError(os.Remove(path))
+	require.Error(p.Load(ctx))
+
+	allowed, err = p.Allows(ctx, true, input("new"))
+	require.NoError(err)
+	require.True(allowed)
+}
+
+func TestConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	// nolint: exhaustruct
+	cfg := policy.Config{Path: "soteria/authz", Modules: []string{"authz.rego"}}
+	require.NoError(cfg.Validate())
+
+	cfg.Mode = "veto"
+	require.ErrorIs(cfg.Validate(), policy.ErrInvalidMode)
+
+	// nolint: exhaustruct
+	require.ErrorIs(policy.Config{Path: "/", Modules: []string{"authz.rego"}}.Validate(), policy.ErrMissingPath)
+	// nolint: exhaustruct
+	require.ErrorIs(policy.Config{Path: "soteria/authz"}.Validate(), policy.ErrMissingModules)
+}
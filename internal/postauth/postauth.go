@@ -0,0 +1,258 @@
+// Package postauth calls an external webhook after topic matching allows an access,
+// so other teams can veto accesses based on signals that soteria doesn't have.
+package postauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	serrors "github.com/snapp-incubator/soteria/internal/errors"
+	"github.com/snapp-incubator/soteria/internal/metric"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultTimeout  = 100 * time.Millisecond
+	DefaultCacheTTL = 5 * time.Second
+
+	// maxCacheEntries bounds the cache, expired entries are removed when cache reaches it.
+	maxCacheEntries = 10_000
+)
+
+var (
+	ErrDenied = serrors.ErrPostAuthorizeDenied
+	ErrFailed = serrors.ErrPostAuthorizeFailed
+)
+
+type Config struct {
+	URL      string        `json:"url,omitempty"       koanf:"url"`
+	Timeout  time.Duration `json:"timeout,omitempty"   koanf:"timeout"`
+	CacheTTL time.Duration `json:"cache_ttl,omitempty" koanf:"cache_ttl"`
+	// FailOpen allows the access when webhook cannot be called, otherwise access is denied.
+	FailOpen bool `json:"fail_open,omitempty" koanf:"fail_open"`
+}
+
+type Request struct {
+	Issuer string `json:"issuer"`
+	Sub    string `json:"sub"`
+	Topic  string `json:"topic"`
+	Access string `json:"access"`
+}
+
+type Response struct {
+	Allow *bool `json:"allow"`
+}
+
+type entry struct {
+	allow   bool
+	expires time.Time
+}
+
+type Client struct {
+	cfg       Config
+	company   string
+	topicType string
+	client    *http.Client
+	tracer    trace.Tracer
+	logger    *zap.Logger
+	metrics   *metric.PostAuthorizeMetrics
+
+	lock  sync.Mutex
+	cache map[string]entry
+}
+
+// New creates a webhook client for a topic type of vendor.
+func New(
+	cfg Config,
+	company, topicType string,
+	tracer trace.Tracer,
+	reg prometheus.Registerer,
+	logger *zap.Logger,
+) *Client {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = DefaultCacheTTL
+	}
+
+	return &Client{
+		cfg:       cfg,
+		company:   company,
+		topicType: topicType,
+		client:    new(http.Client),
+		tracer:    tracer,
+		logger:    logger,
+		metrics:   metric.NewPostAuthorizeMetrics(reg),
+		lock:      sync.Mutex{},
+		cache:     make(map[string]entry),
+	}
+}
+
+// Authorize asks the webhook about the access, it returns nil when access is allowed.
+func (c *Client) Authorize(ctx context.Context, iss, sub, topic string, access acl.AccessType) error {
+	key := fmt.Sprintf("%s|%s|%s|%s", iss, sub, topic, access)
+
+	if allow, ok := c.cached(key); ok {
+		c.metrics.Result(c.company, c.topicType, "cache")
+
+		return decision(allow)
+	}
+
+	ctx, span := c.tracer.Start(ctx, "postauth.authorize")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("topic-type", c.topicType),
+		attribute.String("url", c.cfg.URL),
+	)
+
+	allow, err := c.call(ctx, Request{
+		Issuer: iss,
+		Sub:    sub,
+		Topic:  topic,
+		Access: access.String(),
+	})
+	if err != nil {
+		span.RecordError(err)
+		c.metrics.Result(c.company, c.topicType, "error")
+
+		c.logger.Error("post authorize webhook failed",
+			zap.Error(err),
+			zap.String("topic-type", c.topicType),
+			zap.Bool("fail-open", c.cfg.FailOpen),
+		)
+
+		if c.cfg.FailOpen {
+			return nil
+		}
+
+		return fmt.Errorf("%w: %w", ErrFailed, err)
+	}
+
+	c.store(key, allow)
+
+	if allow {
+		c.metrics.Result(c.company, c.topicType, "allow")
+	} else {
+		c.metrics.Result(c.company, c.topicType, "deny")
+	}
+
+	return decision(allow)
+}
+
+func (c *Client) call(ctx context.Context, request Request) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return false, fmt.Errorf("cannot marshal request %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("cannot create request %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("sending request failed %w", err)
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, nil
+	}
+
+	var response Response
+
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		// an empty body with 200 status is considered as allow.
+		if errors.Is(err, io.EOF) {
+			return true, nil
+		}
+
+		// other bodies which cannot be decoded are failures, so fail_open decides the access.
+		return false, fmt.Errorf("cannot decode response %w", err)
+	}
+
+	if response.Allow != nil && !*response.Allow {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func (c *Client) cached(key string) (bool, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	e, ok := c.cache[key]
+	if !ok || time.Now().After(e.expires) {
+		return false, false
+	}
+
+	return e.allow, true
+}
+
+func (c *Client) store(key string, allow bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now()
+
+	if len(c.cache) >= maxCacheEntries {
+		for k, e := range c.cache {
+			if now.After(e.expires) {
+				delete(c.cache, k)
+			}
+		}
+
+		if len(c.cache) >= maxCacheEntries {
+			c.cache = make(map[string]entry)
+		}
+	}
+
+	c.cache[key] = entry{
+		allow:   allow,
+		expires: now.Add(c.cfg.CacheTTL),
+	}
+}
+
+func decision(allow bool) error {
+	if allow {
+		return nil
+	}
+
+	return ErrDenied
+}
+
+// Flush removes the cached decisions of client.
+func (c *Client) Flush() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.cache = make(map[string]entry)
+}
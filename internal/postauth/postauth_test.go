@@ -0,0 +1,97 @@
+package postauth_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/postauth"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+)
+
+// nolint: funlen
+func TestAuthorize(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	var calls atomic.Int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		calls.Add(1)
+
+		var request postauth.Request
+
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			res.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		switch request.Sub {
+		case "allowed":
+			_, _ = res.Write([]byte(`{"allow": true}`))
+		case "denied":
+			_, _ = res.Write([]byte(`{"allow": false}`))
+		case "slow":
+			time.Sleep(100 * time.Millisecond)
+		case "empty":
+		case "malformed":
+			_, _ = res.Write([]byte(`<html>allow</html>`))
+		default:
+			res.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	client := postauth.New(postauth.Config{
+		URL:      server.URL,
+		Timeout:  50 * time.Millisecond,
+		CacheTTL: time.Minute,
+		FailOpen: false,
+	}, "snapp", "chat", noop.NewTracerProvider().Tracer(""), prometheus.NewRegistry(), zap.NewNop())
+
+	ctx := context.Background()
+
+	require.NoError(client.Authorize(ctx, "1", "allowed", "snapp/chat", acl.Sub))
+	require.ErrorIs(client.Authorize(ctx, "1", "denied", "snapp/chat", acl.Sub), postauth.ErrDenied)
+	require.ErrorIs(client.Authorize(ctx, "1", "forbidden", "snapp/chat", acl.Sub), postauth.ErrDenied)
+	require.ErrorIs(client.Authorize(ctx, "1", "slow", "snapp/chat", acl.Sub), postauth.ErrFailed)
+	require.Equal(int64(4), calls.Load())
+
+	// decisions are cached but errors are not.
+	require.NoError(client.Authorize(ctx, "1", "allowed", "snapp/chat", acl.Sub))
+	require.ErrorIs(client.Authorize(ctx, "1", "denied", "snapp/chat", acl.Sub), postauth.ErrDenied)
+	require.Equal(int64(4), calls.Load())
+
+	require.NoError(client.Authorize(ctx, "1", "allowed", "snapp/another-chat", acl.Sub))
+	require.Equal(int64(5), calls.Load())
+
+	client.Flush()
+
+	require.NoError(client.Authorize(ctx, "1", "allowed", "snapp/chat", acl.Sub))
+	require.Equal(int64(6), calls.Load())
+
+	// empty bodies are allowed while bodies which cannot be decoded are failures.
+	require.NoError(client.Authorize(ctx, "1", "empty", "snapp/chat", acl.Sub))
+	require.ErrorIs(client.Authorize(ctx, "1", "malformed", "snapp/chat", acl.Sub), postauth.ErrFailed)
+
+	failOpen := postauth.New(postauth.Config{
+		URL:      server.URL,
+		Timeout:  50 * time.Millisecond,
+		CacheTTL: time.Minute,
+		FailOpen: true,
+	}, "snapp", "chat", noop.NewTracerProvider().Tracer(""), prometheus.NewRegistry(), zap.NewNop())
+
+	require.NoError(failOpen.Authorize(ctx, "1", "slow", "snapp/chat", acl.Sub))
+	require.NoError(failOpen.Authorize(ctx, "1", "malformed", "snapp/chat", acl.Sub))
+	require.ErrorIs(failOpen.Authorize(ctx, "1", "denied", "snapp/chat", acl.Sub), postauth.ErrDenied)
+}
@@ -1,13 +1,13 @@
 package profiler
 
 import (
-	"log"
 	"os"
 
 	"github.com/grafana/pyroscope-go"
+	"go.uber.org/zap"
 )
 
-func Start(cfg Config) {
+func Start(cfg Config, logger *zap.Logger) {
 	if cfg.Enabled {
 		// nolint: exhaustruct
 		if _, err := pyroscope.Start(pyroscope.Config{
@@ -31,7 +31,7 @@ func Start(cfg Config) {
 				pyroscope.ProfileBlockDuration,
 			},
 		}); err != nil {
-			log.Printf("failed to start the profiler")
+			logger.Error("failed to start the profiler", zap.Error(err))
 		}
 	}
 }
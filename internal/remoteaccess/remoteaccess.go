@@ -0,0 +1,286 @@
+// Package remoteaccess asks an external service about the access of entities on a topic type,
+// so vendors which manage their permissions in their own panels don't need configuration changes.
+package remoteaccess
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/metric"
+	"github.com/snapp-incubator/soteria/internal/singleflight"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultTimeout  = 100 * time.Millisecond
+	DefaultCacheTTL = 30 * time.Second
+
+	// FallbackDeny denies the access when the service cannot be called and there is no stale access.
+	FallbackDeny = "deny"
+	// FallbackStatic uses the accesses of topic configuration when the service cannot be called
+	// and there is no stale access.
+	FallbackStatic = "static"
+
+	// maxCacheEntries bounds the cache, expired entries are removed when cache reaches it.
+	maxCacheEntries = 10_000
+)
+
+var (
+	ErrMissingURL      = errors.New("remote accesses has no url")
+	ErrUnknownFallback = errors.New("unknown fallback of remote accesses")
+	ErrInvalidAccess   = errors.New("remote accesses returned an invalid access")
+)
+
+type Config struct {
+	URL      string        `json:"url,omitempty"       koanf:"url"`
+	Timeout  time.Duration `json:"timeout,omitempty"   koanf:"timeout"`
+	CacheTTL time.Duration `json:"cache_ttl,omitempty" koanf:"cache_ttl"`
+	// StaleTTL is how long an access is served after its cache ttl when the service cannot be called,
+	// zero never serves the stale accesses.
+	StaleTTL time.Duration `json:"stale_ttl,omitempty" koanf:"stale_ttl"`
+	// Fallback is FallbackDeny or FallbackStatic, it is FallbackDeny when it is empty.
+	Fallback string `json:"fallback,omitempty" koanf:"fallback"`
+}
+
+// Validate checks the configuration has an url and a known fallback.
+func (cfg Config) Validate() error {
+	if cfg.URL == "" {
+		return ErrMissingURL
+	}
+
+	switch cfg.Fallback {
+	case "", FallbackDeny, FallbackStatic:
+		return nil
+	}
+
+	return fmt.Errorf("%w %q, use %s or %s", ErrUnknownFallback, cfg.Fallback, FallbackDeny, FallbackStatic)
+}
+
+type Request struct {
+	Entity    string `json:"entity"`
+	TopicType string `json:"topic_type"`
+}
+
+type Response struct {
+	Access acl.AccessType `json:"access"`
+}
+
+type entry struct {
+	access  acl.AccessType
+	expires time.Time
+}
+
+type Client struct {
+	cfg       Config
+	company   string
+	topicType string
+	client    *http.Client
+	tracer    trace.Tracer
+	logger    *zap.Logger
+	metrics   *metric.RemoteAccessMetrics
+
+	lock  sync.Mutex
+	cache map[string]entry
+	// flight coalesces the concurrent calls of cache misses of an entity.
+	flight singleflight.Group[acl.AccessType]
+}
+
+// New creates a remote accesses client for a topic type of vendor.
+func New(
+	cfg Config,
+	company, topicType string,
+	tracer trace.Tracer,
+	reg prometheus.Registerer,
+	logger *zap.Logger,
+) *Client {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = DefaultCacheTTL
+	}
+
+	if cfg.Fallback == "" {
+		cfg.Fallback = FallbackDeny
+	}
+
+	return &Client{
+		cfg:       cfg,
+		company:   company,
+		topicType: topicType,
+		client:    new(http.Client),
+		tracer:    tracer,
+		logger:    logger,
+		metrics:   metric.NewRemoteAccessMetrics(reg),
+		lock:      sync.Mutex{},
+		cache:     make(map[string]entry),
+		flight:    singleflight.Group[acl.AccessType]{},
+	}
+}
+
+// Access returns the access of entity on the topic type. When the service cannot be called,
+// the stale access is returned if it is not older than stale ttl, otherwise the fallback is used
+// and static is the access of topic configuration.
+func (c *Client) Access(ctx context.Context, entity string, static acl.AccessType) acl.AccessType {
+	now := time.Now()
+
+	cached, ok := c.cached(entity)
+	if ok && now.Before(cached.expires) {
+		c.metrics.Result(c.company, c.topicType, "cache")
+
+		return cached.access
+	}
+
+	ctx, span := c.tracer.Start(ctx, "remoteaccess.access")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("topic-type", c.topicType),
+		attribute.String("entity", entity),
+		attribute.String("url", c.cfg.URL),
+	)
+
+	// the call is shared by the waiting requests, so it doesn't fail when the request which runs it
+	// is canceled, calls have their own timeout.
+	access, err, shared := c.flight.Do(entity, func() (acl.AccessType, error) {
+		access, err := c.call(context.WithoutCancel(ctx), Request{Entity: entity, TopicType: c.topicType})
+		if err == nil {
+			c.store(entity, access)
+		}
+
+		return access, err
+	})
+	if err == nil {
+		if shared {
+			c.metrics.Result(c.company, c.topicType, "coalesced")
+		} else {
+			c.metrics.Result(c.company, c.topicType, "remote")
+		}
+
+		return access
+	}
+
+	span.RecordError(err)
+
+	stale := ok && now.Before(cached.expires.Add(c.cfg.StaleTTL))
+
+	c.logger.Error("remote access failed",
+		zap.Error(err),
+		zap.String("topic-type", c.topicType),
+		zap.String("entity", entity),
+		zap.Bool("stale", stale),
+		zap.String("fallback", c.cfg.Fallback),
+	)
+
+	if stale {
+		c.metrics.Result(c.company, c.topicType, "stale")
+
+		return cached.access
+	}
+
+	c.metrics.Result(c.company, c.topicType, "error")
+
+	if c.cfg.Fallback == FallbackStatic {
+		return static
+	}
+
+	return acl.Deny
+}
+
+func (c *Client) call(ctx context.Context, request Request) (acl.AccessType, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("cannot marshal request %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("cannot create request %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("sending request failed %w", err)
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var response Response
+
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", fmt.Errorf("cannot decode response %w", err)
+	}
+
+	if !response.Access.IsValid() {
+		return "", fmt.Errorf("%w %q", ErrInvalidAccess, response.Access)
+	}
+
+	return response.Access, nil
+}
+
+func (c *Client) cached(entity string) (entry, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	e, ok := c.cache[entity]
+
+	return e, ok
+}
+
+func (c *Client) store(entity string, access acl.AccessType) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now()
+
+	if len(c.cache) >= maxCacheEntries {
+		for k, e := range c.cache {
+			if now.After(e.expires.Add(c.cfg.StaleTTL)) {
+				delete(c.cache, k)
+			}
+		}
+
+		if len(c.cache) >= maxCacheEntries {
+			c.cache = make(map[string]entry)
+		}
+	}
+
+	c.cache[entity] = entry{
+		access:  access,
+		expires: now.Add(c.cfg.CacheTTL),
+	}
+}
+
+// Flush removes the cached accesses of client.
+func (c *Client) Flush() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.cache = make(map[string]entry)
+}
@@ -0,0 +1,170 @@
+package remoteaccess_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/remoteaccess"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+)
+
+// accessServer is a mock remote accesses service which gives driver the publish access,
+// it fails all of its requests when down is set.
+func accessServer(calls *atomic.Int64, down *atomic.Bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		calls.Add(1)
+
+		var request remoteaccess.Request
+
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil || down.Load() {
+			res.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		switch request.Entity {
+		case "driver":
+			_, _ = res.Write([]byte(`{"access": "pub"}`))
+		case "passenger":
+			_, _ = res.Write([]byte(`{"access": "deny"}`))
+		default:
+			_, _ = res.Write([]byte(`{"access": "unknown"}`))
+		}
+	}))
+}
+
+// nolint: funlen
+func TestAccess(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	var (
+		calls atomic.Int64
+		down  atomic.Bool
+	)
+
+	server := accessServer(&calls, &down)
+	defer server.Close()
+
+	client := remoteaccess.New(remoteaccess.Config{
+		URL:      server.URL,
+		Timeout:  50 * time.Millisecond,
+		CacheTTL: 50 * time.Millisecond,
+		StaleTTL: time.Hour,
+		Fallback: remoteaccess.FallbackDeny,
+	}, "snapp", "driver_location", noop.NewTracerProvider().Tracer(""), prometheus.NewRegistry(), zap.NewNop())
+
+	ctx := context.Background()
+
+	require.Equal(acl.Pub, client.Access(ctx, "driver", acl.Sub))
+	require.Equal(acl.Deny, client.Access(ctx, "passenger", acl.Sub))
+	require.Equal(int64(2), calls.Load())
+
+	// accesses are cached per entity.
+	require.Equal(acl.Pub, client.Access(ctx, "driver", acl.Sub))
+	require.Equal(int64(2), calls.Load())
+
+	// invalid accesses use the fallback.
+	require.Equal(acl.Deny, client.Access(ctx, "unknown", acl.Sub))
+
+	time.Sleep(60 * time.Millisecond)
+
+	// stale accesses are served when service is down.
+	down.Store(true)
+	require.Equal(acl.Pub, client.Access(ctx, "driver", acl.Sub))
+	require.Equal(acl.Deny, client.Access(ctx, "box", acl.Sub))
+
+	client.Flush()
+	require.Equal(acl.Deny, client.Access(ctx, "driver", acl.Sub))
+
+	static := remoteaccess.New(remoteaccess.Config{
+		URL:      server.URL,
+		Timeout:  50 * time.Millisecond,
+		CacheTTL: time.Minute,
+		StaleTTL: 0,
+		Fallback: remoteaccess.FallbackStatic,
+	}, "snapp", "driver_location", noop.NewTracerProvider().Tracer(""), prometheus.NewRegistry(), zap.NewNop())
+
+	require.Equal(acl.Sub, static.Access(ctx, "driver", acl.Sub))
+}
+
+func TestAccessCoalesced(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	var calls atomic.Int64
+
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		<-release
+
+		_, _ = res.Write([]byte(`{"access": "pub"}`))
+	}))
+	defer server.Close()
+
+	client := remoteaccess.New(remoteaccess.Config{
+		URL:      server.URL,
+		Timeout:  time.Second,
+		CacheTTL: time.Minute,
+		StaleTTL: 0,
+		Fallback: remoteaccess.FallbackDeny,
+	}, "snapp", "driver_location", noop.NewTracerProvider().Tracer(""), prometheus.NewRegistry(), zap.NewNop())
+
+	const requests = 10
+
+	var wg sync.WaitGroup
+
+	accesses := make(chan acl.AccessType, requests)
+
+	for range requests {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			accesses <- client.Access(context.Background(), "driver", acl.Sub)
+		}()
+	}
+
+	// cache misses of the same entity wait for the running call.
+	require.Eventually(func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+	close(release)
+
+	wg.Wait()
+	close(accesses)
+
+	for access := range accesses {
+		require.Equal(acl.Pub, access)
+	}
+
+	require.Equal(int64(1), calls.Load())
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	// nolint: exhaustruct
+	require.NoError(remoteaccess.Config{URL: "http://localhost"}.Validate())
+	// nolint: exhaustruct
+	require.NoError(remoteaccess.Config{URL: "http://localhost", Fallback: remoteaccess.FallbackStatic}.Validate())
+	// nolint: exhaustruct
+	require.ErrorIs(remoteaccess.Config{}.Validate(), remoteaccess.ErrMissingURL)
+	// nolint: exhaustruct
+	require.ErrorIs(remoteaccess.Config{URL: "http://localhost", Fallback: "allow"}.Validate(), remoteaccess.ErrUnknownFallback)
+}
@@ -0,0 +1,278 @@
+// Package replay captures the topic decisions of ACL requests and replays them against another configuration,
+// so configuration changes can be checked with the real decisions before they are deployed. Tokens are not
+// captured, so decisions are replayed using the issuer and the subject hash of their tokens in the topic layer.
+package replay
+
+import (
+	"bufio"
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"sync"
+
+	serrors "github.com/snapp-incubator/soteria/internal/errors"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+)
+
+// ErrNotReplayable means the record cannot be replayed, e.g. its vendor is removed.
+var ErrNotReplayable = errors.New("record is not replayable")
+
+const (
+	DecisionAllow = "allow"
+	DecisionDeny  = "deny"
+
+	// ReasonNoMatch means none of the templates matches the topic.
+	ReasonNoMatch = "no_match"
+	// ReasonInvalidAccessType means the access type is not allowed by vendor or topic.
+	ReasonInvalidAccessType = "invalid_access_type"
+
+	// maxRecordSize is the longest line of the captured decisions.
+	maxRecordSize = 1 << 20
+	// subHashSize is the number of bytes of subject hashes, which are hex encoded.
+	subHashSize = 8
+)
+
+type Config struct {
+	// Path is the JSONL file which decisions are appended to, empty path disables the capture.
+	Path string `json:"path,omitempty" koanf:"path"`
+	// Ratio is the ratio of the captured decisions between 0 and 1.
+	Ratio float64 `json:"ratio,omitempty" koanf:"ratio"`
+}
+
+// Record is a captured decision without the secrets of its request.
+type Record struct {
+	Company string `json:"company"`
+	Issuer  string `json:"iss"`
+	// SubHash is the keyed hash of token subject, levels of topic which are the subject are replaced by it,
+	// so the decision is replayed with it as the subject.
+	SubHash string `json:"sub_hash"`
+	// Qualifier is the access qualifier claim of token when vendor has it.
+	Qualifier string         `json:"qualifier,omitempty"`
+	Topic     string         `json:"topic"`
+	Access    acl.AccessType `json:"access"`
+	Decision  string         `json:"decision"`
+	Reason    string         `json:"reason,omitempty"`
+}
+
+// Outcome returns the decision and reason of topic layer error, ok is false for the errors which don't
+// depend on topic configuration or are not replayed like token, state check and webhook failures.
+func Outcome(err error) (string, string, bool) {
+	if err == nil {
+		return DecisionAllow, "", true
+	}
+
+	var (
+		itErr  serrors.InvalidTopicError
+		iatErr serrors.InvalidAccessTypeError
+		rmErr  serrors.RideMismatchError
+		sleErr serrors.SubscriptionLimitExceededError
+		pmErr  serrors.PayloadMismatchError
+		pdErr  serrors.PolicyDeniedError
+		reason interface{ Reason() string }
+	)
+
+	switch {
+	case errors.As(err, &rmErr), errors.As(err, &sleErr), errors.As(err, &pmErr), errors.As(err, &pdErr):
+		// ride claims, subscriptions, payloads and policy decisions are not captured.
+		return "", "", false
+	case errors.As(err, &itErr):
+		return DecisionDeny, ReasonNoMatch, true
+	case errors.As(err, &iatErr):
+		return DecisionDeny, ReasonInvalidAccessType, true
+	case errors.As(err, &reason):
+		return DecisionDeny, reason.Reason(), true
+	default:
+		return "", "", false
+	}
+}
+
+// Recorder appends the sampled decisions to the capture file, it is safe for concurrent use
+// and nil recorder doesn't capture anything.
+type Recorder struct {
+	ratio float64
+	// key is the random key of subject hashes.
+	key []byte
+
+	lock    sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// New opens the capture file, recorder is nil when capture is disabled.
+func New(cfg Config) (*Recorder, error) {
+	if cfg.Path == "" || cfg.Ratio <= 0 {
+		return nil, nil //nolint: nilnil
+	}
+
+	file, err := os.OpenFile(cfg.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600) //nolint: mnd
+	if err != nil {
+		return nil, fmt.Errorf("cannot open capture file %w", err)
+	}
+
+	key := make([]byte, sha256.Size)
+
+	// rand.Read never returns an error.
+	_, _ = crand.Read(key)
+
+	return &Recorder{
+		ratio:   cfg.Ratio,
+		key:     key,
+		lock:    sync.Mutex{},
+		file:    file,
+		encoder: json.NewEncoder(file),
+	}, nil
+}
+
+// Sample decides the current decision is captured.
+func (r *Recorder) Sample() bool {
+	if r == nil {
+		return false
+	}
+
+	return r.ratio >= 1 || rand.Float64() < r.ratio //nolint: gosec
+}
+
+// Pseudonymize returns the hash of subject and the topic which has the hash instead of its levels which are
+// the subject, so the record of decision doesn't have the subject and it is replayed with the hash.
+func (r *Recorder) Pseudonymize(sub, topic string) (string, string) {
+	if r == nil {
+		return "", topic
+	}
+
+	mac := hmac.New(sha256.New, r.key)
+	_, _ = mac.Write([]byte(sub))
+
+	hash := hex.EncodeToString(mac.Sum(nil)[:subHashSize])
+
+	levels := strings.Split(topic, "/")
+	for i, level := range levels {
+		if level == sub {
+			levels[i] = hash
+		}
+	}
+
+	return hash, strings.Join(levels, "/")
+}
+
+// Write appends the record as a line of the capture file.
+func (r *Recorder) Write(record Record) error {
+	if r == nil {
+		return nil
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if err := r.encoder.Encode(record); err != nil {
+		return fmt.Errorf("cannot write the captured decision %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the capture file.
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("cannot close capture file %w", err)
+	}
+
+	return nil
+}
+
+// Mismatch is a record which has another decision with the candidate configuration.
+type Mismatch struct {
+	Record   Record
+	Decision string
+	Reason   string
+}
+
+// Summary is the result of replaying the records.
+type Summary struct {
+	Total   int
+	Matched int
+	// Skipped records cannot be replayed, e.g. their vendor is removed or the new decision is not a topic decision.
+	Skipped    int
+	Mismatched int
+	// Mismatches are the first mismatches in order of their records.
+	Mismatches []Mismatch
+}
+
+// Replay decides each record of input using decide and compares the outcome with the recorded decision,
+// records which decide returns ErrNotReplayable for are skipped and at most limit mismatches are kept.
+func Replay(input io.Reader, decide func(Record) error, limit int) (Summary, error) {
+	summary := Summary{
+		Total:      0,
+		Matched:    0,
+		Skipped:    0,
+		Mismatched: 0,
+		Mismatches: nil,
+	}
+
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(nil, maxRecordSize)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record Record
+
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return summary, fmt.Errorf("record %d is invalid %w", line, err)
+		}
+
+		summary.Total++
+
+		err := decide(record)
+		if errors.Is(err, ErrNotReplayable) {
+			summary.Skipped++
+
+			continue
+		}
+
+		decision, reason, ok := Outcome(err)
+		if !ok {
+			summary.Skipped++
+
+			continue
+		}
+
+		if decision == record.Decision && reason == record.Reason {
+			summary.Matched++
+
+			continue
+		}
+
+		summary.Mismatched++
+
+		if len(summary.Mismatches) < limit {
+			summary.Mismatches = append(summary.Mismatches, Mismatch{
+				Record:   record,
+				Decision: decision,
+				Reason:   reason,
+			})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return summary, fmt.Errorf("cannot read records %w", err)
+	}
+
+	return summary, nil
+}
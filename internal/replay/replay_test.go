@@ -0,0 +1,141 @@
+package replay_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	serrors "github.com/snapp-incubator/soteria/internal/errors"
+	"github.com/snapp-incubator/soteria/internal/replay"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/stretchr/testify/require"
+)
+
+// nolint: exhaustruct
+func TestOutcome(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		err      error
+		decision string
+		reason   string
+		ok       bool
+	}{
+		{name: "allow", err: nil, decision: replay.DecisionAllow, reason: "", ok: true},
+		{
+			name:     "topic not allowed",
+			err:      serrors.TopicNotAllowedError{AccessType: acl.Pub, Granted: acl.Sub},
+			decision: replay.DecisionDeny,
+			reason:   serrors.ReasonSubscribeOnly,
+			ok:       true,
+		},
+		{
+			name:     "no match",
+			err:      serrors.InvalidTopicError{Topic: "snapp/unknown"},
+			decision: replay.DecisionDeny,
+			reason:   replay.ReasonNoMatch,
+			ok:       true,
+		},
+		{name: "token", err: fmt.Errorf("token is invalid: %w", serrors.ErrInvalidClaims), ok: false},
+		{name: "ride", err: serrors.RideMismatchError{}, ok: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			decision, reason, ok := replay.Outcome(c.err)
+			require.Equal(t, c.ok, ok)
+			require.Equal(t, c.decision, decision)
+			require.Equal(t, c.reason, reason)
+		})
+	}
+}
+
+// nolint: exhaustruct
+func TestRecorderReplay(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "decisions.jsonl")
+
+	recorder, err := replay.New(replay.Config{Path: path, Ratio: 1})
+	require.NoError(err)
+	require.True(recorder.Sample())
+
+	records := []replay.Record{
+		{Company: "snapp", Issuer: "0", SubHash: "h", Topic: "snapp/driver/h/location", Access: acl.Pub, Decision: "allow"},
+		{
+			Company: "snapp", Issuer: "0", SubHash: "h", Topic: "snapp/driver/h/call/receive", Access: acl.Sub,
+			Decision: "deny", Reason: serrors.ReasonNoAccess,
+		},
+		{Company: "removed", Issuer: "0", SubHash: "h", Topic: "removed/driver/h/location", Access: acl.Pub, Decision: "allow"},
+	}
+
+	for _, record := range records {
+		require.NoError(recorder.Write(record))
+	}
+
+	require.NoError(recorder.Close())
+
+	input, err := os.Open(path)
+	require.NoError(err)
+
+	defer input.Close()
+
+	// candidate configuration allows every topic of snapp.
+	summary, err := replay.Replay(input, func(record replay.Record) error {
+		if record.Company != "snapp" {
+			return replay.ErrNotReplayable
+		}
+
+		return nil
+	}, 10)
+	require.NoError(err)
+
+	require.Equal(3, summary.Total)
+	require.Equal(1, summary.Matched)
+	require.Equal(1, summary.Mismatched)
+	require.Equal(1, summary.Skipped)
+	require.Equal([]replay.Mismatch{{Record: records[1], Decision: "allow", Reason: ""}}, summary.Mismatches)
+}
+
+func TestPseudonymize(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	recorder, err := replay.New(replay.Config{Path: filepath.Join(t.TempDir(), "decisions.jsonl"), Ratio: 1})
+	require.NoError(err)
+
+	hash, topic := recorder.Pseudonymize("DXKgaNQa7N5Y7bo", "snapp/driver/DXKgaNQa7N5Y7bo/location")
+	require.NotEmpty(hash)
+	require.NotContains(hash, "DXKgaNQa7N5Y7bo")
+	require.Equal("snapp/driver/"+hash+"/location", topic)
+
+	// subjects have the same hash and only the levels which are the subject are replaced.
+	again, topic := recorder.Pseudonymize("DXKgaNQa7N5Y7bo", "snapp/DXKgaNQa7N5Y7bo-chat/passenger")
+	require.Equal(hash, again)
+	require.Equal("snapp/DXKgaNQa7N5Y7bo-chat/passenger", topic)
+
+	other, _ := recorder.Pseudonymize("another", "snapp/driver/another/location")
+	require.NotEqual(hash, other)
+
+	require.NoError(recorder.Close())
+}
+
+// nolint: exhaustruct
+func TestDisabledRecorder(t *testing.T) {
+	t.Parallel()
+
+	recorder, err := replay.New(replay.Config{Path: "", Ratio: 1})
+	require.NoError(t, err)
+	require.Nil(t, recorder)
+
+	require.False(t, recorder.Sample())
+	require.NoError(t, recorder.Write(replay.Record{}))
+	require.NoError(t, recorder.Close())
+}
@@ -0,0 +1,146 @@
+// Package reuse detects the tokens which are used from many client addresses, e.g. a leaked token
+// which is shared between devices. Addresses are kept in memory by token id (jti), so each pod
+// only counts the authentications which it handles and the detection is a lower bound.
+package reuse
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/metric"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultWindow is the lifetime of the addresses of a token when it is not configured.
+	DefaultWindow = 10 * time.Minute
+	// DefaultMaxTokens is the number of tracked tokens when it is not configured.
+	DefaultMaxTokens = 100_000
+)
+
+type Config struct {
+	// MaxAddresses is the number of distinct client addresses which a token can have in Window,
+	// tokens with more addresses are suspected. zero disables the detection.
+	MaxAddresses int `json:"max_addresses,omitempty" koanf:"max_addresses"`
+	// Window is the lifetime of the addresses of a token from its first authentication.
+	Window time.Duration `json:"window,omitempty" koanf:"window"`
+	// MaxTokens caps the tracked tokens, new tokens are not tracked when it is full.
+	MaxTokens int `json:"max_tokens,omitempty" koanf:"max_tokens"`
+}
+
+type token struct {
+	addresses map[netip.Addr]struct{}
+	expires   time.Time
+	suspected bool
+}
+
+// Detector tracks the client addresses of tokens of a vendor, it is safe for concurrent use
+// and nil detector doesn't detect anything.
+type Detector struct {
+	cfg     Config
+	company string
+	metrics *metric.TokenReuseMetrics
+	logger  *zap.Logger
+
+	lock      sync.Mutex
+	tokens    map[string]*token
+	lastSweep time.Time
+}
+
+// New creates the detector of vendor, it returns nil when the detection is disabled.
+func New(cfg Config, company string, logger *zap.Logger) *Detector {
+	if cfg.MaxAddresses <= 0 {
+		return nil
+	}
+
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultWindow
+	}
+
+	if cfg.MaxTokens <= 0 {
+		cfg.MaxTokens = DefaultMaxTokens
+	}
+
+	return &Detector{
+		cfg:       cfg,
+		company:   company,
+		metrics:   metric.NewTokenReuseMetrics(prometheus.DefaultRegisterer),
+		logger:    logger,
+		lock:      sync.Mutex{},
+		tokens:    make(map[string]*token),
+		lastSweep: time.Now(),
+	}
+}
+
+// Observe records the client address of an authenticated token and reports whether the token has just
+// exceeded its maximum addresses. Each token is reported once in its window. Tokens without jti and
+// unknown addresses are not tracked.
+func (d *Detector) Observe(iss, sub, jti string, addr netip.Addr) bool {
+	if d == nil || jti == "" || !addr.IsValid() {
+		return false
+	}
+
+	now := time.Now()
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.sweep(now)
+
+	t, ok := d.tokens[jti]
+	if !ok || now.After(t.expires) {
+		if !ok && len(d.tokens) >= d.cfg.MaxTokens {
+			return false
+		}
+
+		t = &token{
+			addresses: make(map[netip.Addr]struct{}),
+			expires:   now.Add(d.cfg.Window),
+			suspected: false,
+		}
+		d.tokens[jti] = t
+	}
+
+	// addresses of suspected tokens are not kept, so a widely shared token doesn't grow without limit.
+	if t.suspected {
+		return false
+	}
+
+	t.addresses[addr] = struct{}{}
+
+	if len(t.addresses) <= d.cfg.MaxAddresses {
+		return false
+	}
+
+	t.suspected = true
+	t.addresses = nil
+
+	d.metrics.Suspected(d.company, iss)
+
+	d.logger.Warn("token reuse is suspected",
+		zap.String("iss", iss),
+		zap.String("sub", sub),
+		zap.String("jti", jti),
+		zap.Int("max-addresses", d.cfg.MaxAddresses),
+		zap.Duration("window", d.cfg.Window),
+	)
+
+	return true
+}
+
+// sweep removes the expired tokens once in each window, so tokens which are gone don't keep memory.
+func (d *Detector) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < d.cfg.Window {
+		return
+	}
+
+	d.lastSweep = now
+
+	for jti, t := range d.tokens {
+		if now.After(t.expires) {
+			delete(d.tokens, jti)
+		}
+	}
+}
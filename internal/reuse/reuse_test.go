@@ -0,0 +1,75 @@
+package reuse_test
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/snapp-incubator/soteria/internal/reuse"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestDisabled(t *testing.T) {
+	t.Parallel()
+
+	d := reuse.New(reuse.Config{MaxAddresses: 0, Window: 0, MaxTokens: 0}, "snapp", zap.NewNop())
+	require.Nil(t, d)
+	require.False(t, d.Observe("0", "sub", "jti", netip.MustParseAddr("10.0.0.1")))
+}
+
+func TestObserve(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	d := reuse.New(reuse.Config{
+		MaxAddresses: 2,
+		Window:       100 * time.Millisecond,
+		MaxTokens:    0,
+	}, "snapp", zap.NewNop())
+	require.NotNil(d)
+
+	first, second, third := netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("10.0.0.2"), netip.MustParseAddr("::1")
+
+	require.False(d.Observe("0", "sub", "jti", first))
+	require.False(d.Observe("0", "sub", "jti", second))
+
+	// addresses which are already seen are not counted again.
+	require.False(d.Observe("0", "sub", "jti", first))
+
+	require.True(d.Observe("0", "sub", "jti", third))
+
+	// tokens are reported once in their window.
+	require.False(d.Observe("0", "sub", "jti", netip.MustParseAddr("10.0.0.4")))
+
+	// other tokens have their own addresses and tokens without jti or address are not tracked.
+	require.False(d.Observe("0", "sub", "other", third))
+	require.False(d.Observe("0", "sub", "", third))
+	require.False(d.Observe("0", "sub", "unknown", netip.Addr{}))
+
+	// addresses decay, so the token is reported again in its next window.
+	time.Sleep(150 * time.Millisecond)
+
+	require.False(d.Observe("0", "sub", "jti", first))
+	require.False(d.Observe("0", "sub", "jti", second))
+	require.True(d.Observe("0", "sub", "jti", third))
+}
+
+func TestMaxTokens(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	d := reuse.New(reuse.Config{MaxAddresses: 1, Window: time.Hour, MaxTokens: 1}, "snapp", zap.NewNop())
+
+	first, second := netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("10.0.0.2")
+
+	require.False(d.Observe("0", "sub", "jti", first))
+
+	// new tokens are not tracked when the detector is full.
+	require.False(d.Observe("0", "sub", "other", first))
+	require.False(d.Observe("0", "sub", "other", second))
+
+	require.True(d.Observe("0", "sub", "jti", second))
+}
@@ -0,0 +1,51 @@
+// Package server tunes the REST server of Soteria, its zero configuration keeps the defaults of fiber.
+// It doesn't import fiber, so the packages which only read the configuration don't depend on the REST server.
+package server
+
+import "time"
+
+const (
+	// DefaultBodyLimit is the body limit of fiber.
+	DefaultBodyLimit = 4 * 1024 * 1024
+	// DefaultMaxHeaderBytes is the read buffer size of fiber which limits the request headers.
+	DefaultMaxHeaderBytes = 4096
+	// DefaultMinCompressSize is the minimum size of responses which are compressed.
+	DefaultMinCompressSize = 1024
+)
+
+type Config struct {
+	// ReadTimeout, WriteTimeout and IdleTimeout are the timeouts of connections, zero means no timeout
+	// and idle timeout uses the read timeout when it is zero.
+	ReadTimeout  time.Duration `json:"read_timeout,omitempty"  koanf:"read_timeout"`
+	WriteTimeout time.Duration `json:"write_timeout,omitempty" koanf:"write_timeout"`
+	IdleTimeout  time.Duration `json:"idle_timeout,omitempty"  koanf:"idle_timeout"`
+	// MaxHeaderBytes is the read buffer size of connections, requests with larger headers are rejected.
+	MaxHeaderBytes int `json:"max_header_bytes,omitempty" koanf:"max_header_bytes"`
+	// BodyLimits are the maximum request body size of route groups.
+	BodyLimits BodyLimits `json:"body_limits,omitempty" koanf:"body_limits"`
+	// Compression compresses the responses by gzip for the clients which accept it.
+	Compression Compression `json:"compression,omitempty" koanf:"compression"`
+}
+
+// BodyLimits are the maximum body size of route groups in bytes, zero uses the fiber default of 4MB.
+type BodyLimits struct {
+	// Broker is the limit of auth and acl routes which brokers call.
+	Broker int `json:"broker,omitempty" koanf:"broker"`
+	// Admin is the limit of admin and debug routes.
+	Admin int `json:"admin,omitempty" koanf:"admin"`
+}
+
+type Compression struct {
+	Enabled bool `json:"enabled,omitempty" koanf:"enabled"`
+	// MinSize is the minimum size of responses which are compressed, smaller responses are not worth it.
+	MinSize int `json:"min_size,omitempty" koanf:"min_size"`
+}
+
+// Limit returns the body limit of size, zero or negative sizes use the fiber default.
+func Limit(size int) int {
+	if size <= 0 {
+		return DefaultBodyLimit
+	}
+
+	return size
+}
@@ -0,0 +1,283 @@
+// Package session memoizes the ACL decisions of MQTT sessions by their client id, so the same
+// subscriptions which are checked again on takeover or bridge resync don't go through the authenticators.
+// Decisions are keyed by the digest of the credential which they are made for, so a decision is only
+// returned for the exact token which is verified when the decision is made, and it expires with the token.
+// Authentications of the client id invalidate its decisions. The cache is in memory, so invalidations only
+// reach the decisions of the pod which handles the authentication. Sessions are partitioned by vendor,
+// so vendors with the same client ids never share their decisions.
+package session
+
+import (
+	"context"
+	"crypto/sha256"
+	"hash/maphash"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/metric"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+)
+
+const (
+	ResultHit  = "hit"
+	ResultMiss = "miss"
+
+	// shards is the number of partitions of sessions, each partition has its own lock,
+	// so the decisions of different clients don't wait for each other.
+	shards = 32
+)
+
+type Config struct {
+	// TTL is the lifetime of decisions, zero disables the cache.
+	TTL time.Duration `json:"ttl,omitempty" koanf:"ttl"`
+	// MaxEntries caps the cached decisions, new decisions are not cached when the cache is full.
+	// Zero doesn't cap the cache and expired decisions are removed by Start in both cases.
+	MaxEntries int `json:"max_entries,omitempty" koanf:"max_entries"`
+}
+
+// client is a session of vendor, client ids are only unique in their vendor.
+type client struct {
+	company  string
+	clientID string
+}
+
+type key struct {
+	credential [sha256.Size]byte
+	topic      string
+	access     acl.AccessType
+}
+
+type entry[T any] struct {
+	value   T
+	expires time.Time
+}
+
+// Cache is safe for concurrent use and nil cache doesn't cache anything.
+type Cache[T any] struct {
+	shards  [shards]shard[T]
+	seed    maphash.Seed
+	size    atomic.Int64
+	ttl     time.Duration
+	max     int64
+	metrics *metric.SessionCacheMetrics
+}
+
+// shard has the sessions of a partition of clients.
+type shard[T any] struct {
+	lock     sync.Mutex
+	sessions map[client]map[key]entry[T]
+}
+
+// New returns the session cache, it is nil when the cache is disabled.
+func New[T any](cfg Config) *Cache[T] {
+	if cfg.TTL <= 0 {
+		return nil
+	}
+
+	// nolint: exhaustruct
+	c := &Cache[T]{
+		seed:    maphash.MakeSeed(),
+		ttl:     cfg.TTL,
+		max:     int64(cfg.MaxEntries),
+		metrics: metric.NewSessionCacheMetrics(prometheus.DefaultRegisterer),
+	}
+
+	for i := range c.shards {
+		c.shards[i].sessions = make(map[client]map[key]entry[T])
+	}
+
+	return c
+}
+
+// Start removes the expired decisions every ttl until the context is canceled, so the decisions of clients
+// which don't come back don't stay in memory.
+func (c *Cache[T]) Start(ctx context.Context) {
+	if c == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(c.ttl)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.expire(time.Now())
+			}
+		}
+	}()
+}
+
+// Get returns the decision of client of vendor on the topic for the credential, requests without client id
+// are never cached.
+func (c *Cache[T]) Get(company, clientID, credential, topic string, access acl.AccessType) (T, bool) {
+	var zero T
+
+	if c == nil || clientID == "" {
+		return zero, false
+	}
+
+	s := client{company: company, clientID: clientID}
+	k := key{credential: sha256.Sum256([]byte(credential)), topic: topic, access: access}
+
+	sh := c.shard(s)
+
+	sh.lock.Lock()
+	defer sh.lock.Unlock()
+
+	e, ok := sh.sessions[s][k]
+	if !ok || time.Now().After(e.expires) {
+		if ok {
+			c.remove(sh, s, k)
+		}
+
+		c.metrics.Lookup(company, ResultMiss)
+
+		return zero, false
+	}
+
+	c.metrics.Lookup(company, ResultHit)
+
+	return e.value, true
+}
+
+// Set stores the decision of client of vendor on the topic for the credential until the ttl of cache
+// or the given expiry, zero expiry is for the credentials which don't expire.
+func (c *Cache[T]) Set(
+	company, clientID, credential, topic string, access acl.AccessType, value T, expiry time.Time,
+) {
+	if c == nil || clientID == "" {
+		return
+	}
+
+	expires := time.Now().Add(c.ttl)
+	if !expiry.IsZero() && expiry.Before(expires) {
+		expires = expiry
+	}
+
+	s := client{company: company, clientID: clientID}
+	k := key{credential: sha256.Sum256([]byte(credential)), topic: topic, access: access}
+
+	sh := c.shard(s)
+
+	sh.lock.Lock()
+	defer sh.lock.Unlock()
+
+	if _, ok := sh.sessions[s][k]; !ok {
+		// the entry is reserved before it is stored, so concurrent sets on other shards cannot exceed the cap.
+		if size := c.size.Add(1); c.max > 0 && size > c.max {
+			c.size.Add(-1)
+
+			return
+		}
+
+		c.metrics.Add(company, 1)
+	}
+
+	if sh.sessions[s] == nil {
+		sh.sessions[s] = make(map[key]entry[T])
+	}
+
+	sh.sessions[s][k] = entry[T]{value: value, expires: expires}
+}
+
+// Invalidate removes the decisions of client of vendor, it is called on each authentication of client
+// because a new connection can have a new token. Only the decisions of this cache are removed.
+func (c *Cache[T]) Invalidate(company, clientID string) {
+	if c == nil || clientID == "" {
+		return
+	}
+
+	s := client{company: company, clientID: clientID}
+
+	sh := c.shard(s)
+
+	sh.lock.Lock()
+	defer sh.lock.Unlock()
+
+	c.drop(sh, s)
+}
+
+// Flush removes the decisions of every client of vendor and returns their number,
+// decisions of the other vendors are kept.
+func (c *Cache[T]) Flush(company string) int {
+	if c == nil {
+		return 0
+	}
+
+	flushed := 0
+
+	for i := range c.shards {
+		sh := &c.shards[i]
+
+		sh.lock.Lock()
+
+		for s := range sh.sessions {
+			if s.company == company {
+				flushed += c.drop(sh, s)
+			}
+		}
+
+		sh.lock.Unlock()
+	}
+
+	return flushed
+}
+
+// expire removes the expired decisions of every session, shards are locked one at a time.
+func (c *Cache[T]) expire(now time.Time) {
+	for i := range c.shards {
+		sh := &c.shards[i]
+
+		sh.lock.Lock()
+
+		for s, decisions := range sh.sessions {
+			for k, e := range decisions {
+				if now.After(e.expires) {
+					c.remove(sh, s, k)
+				}
+			}
+		}
+
+		sh.lock.Unlock()
+	}
+}
+
+func (c *Cache[T]) shard(s client) *shard[T] {
+	var h maphash.Hash
+
+	h.SetSeed(c.seed)
+	_, _ = h.WriteString(s.company)
+	_ = h.WriteByte(0)
+	_, _ = h.WriteString(s.clientID)
+
+	return &c.shards[h.Sum64()%shards]
+}
+
+func (c *Cache[T]) remove(sh *shard[T], s client, k key) {
+	delete(sh.sessions[s], k)
+
+	if len(sh.sessions[s]) == 0 {
+		delete(sh.sessions, s)
+	}
+
+	c.size.Add(-1)
+	c.metrics.Add(s.company, -1)
+}
+
+// drop removes every decision of session and returns their number.
+func (c *Cache[T]) drop(sh *shard[T], s client) int {
+	dropped := len(sh.sessions[s])
+
+	delete(sh.sessions, s)
+
+	c.size.Add(-int64(dropped))
+	c.metrics.Add(s.company, -dropped)
+
+	return dropped
+}
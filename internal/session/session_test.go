@@ -0,0 +1,161 @@
+package session_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/snapp-incubator/soteria/internal/session"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	c := session.New[string](session.Config{TTL: 50 * time.Millisecond, MaxEntries: 2})
+
+	c.Set("snapp", "client-1", "token", "a/b", acl.Sub, "allow", time.Time{})
+	c.Set("snapp", "client-1", "token", "a/c", acl.Sub, "deny", time.Time{})
+
+	value, ok := c.Get("snapp", "client-1", "token", "a/b", acl.Sub)
+	require.True(ok)
+	require.Equal("allow", value)
+
+	_, ok = c.Get("snapp", "client-1", "token", "a/b", acl.Pub)
+	require.False(ok)
+
+	// the cache is full.
+	c.Set("snapp", "client-2", "token", "a/b", acl.Sub, "allow", time.Time{})
+
+	_, ok = c.Get("snapp", "client-2", "token", "a/b", acl.Sub)
+	require.False(ok)
+
+	c.Invalidate("snapp", "client-1")
+
+	_, ok = c.Get("snapp", "client-1", "token", "a/b", acl.Sub)
+	require.False(ok)
+
+	c.Set("snapp", "client-2", "token", "a/b", acl.Sub, "allow", time.Time{})
+
+	time.Sleep(60 * time.Millisecond)
+
+	_, ok = c.Get("snapp", "client-2", "token", "a/b", acl.Sub)
+	require.False(ok)
+}
+
+func TestCacheStart(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	c := session.New[string](session.Config{TTL: 20 * time.Millisecond, MaxEntries: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	c.Start(ctx)
+
+	c.Set("snapp", "client-1", "token", "a/b", acl.Sub, "allow", time.Time{})
+
+	// expired decisions are removed in the background, so they don't keep the cache full.
+	require.Eventually(func() bool {
+		c.Set("snapp", "client-2", "token", "a/b", acl.Sub, "allow", time.Time{})
+
+		_, ok := c.Get("snapp", "client-2", "token", "a/b", acl.Sub)
+
+		return ok
+	}, time.Second, 10*time.Millisecond)
+
+	// nil caches have nothing to remove.
+	var disabled *session.Cache[string]
+
+	disabled.Start(ctx)
+}
+
+func TestCacheDisabled(t *testing.T) {
+	t.Parallel()
+
+	c := session.New[string](session.Config{TTL: 0, MaxEntries: 0})
+	require.Nil(t, c)
+
+	c.Set("snapp", "client", "token", "a/b", acl.Sub, "allow", time.Time{})
+	c.Invalidate("snapp", "client")
+
+	_, ok := c.Get("snapp", "client", "token", "a/b", acl.Sub)
+	require.False(t, ok)
+}
+
+func TestCacheVendors(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	c := session.New[string](session.Config{TTL: time.Minute, MaxEntries: 0})
+
+	// vendors have the same client ids and topics.
+	c.Set("snapp", "client", "token", "a/b", acl.Sub, "allow", time.Time{})
+	c.Set("tapsi", "client", "token", "a/b", acl.Sub, "deny", time.Time{})
+
+	value, ok := c.Get("snapp", "client", "token", "a/b", acl.Sub)
+	require.True(ok)
+	require.Equal("allow", value)
+
+	value, ok = c.Get("tapsi", "client", "token", "a/b", acl.Sub)
+	require.True(ok)
+	require.Equal("deny", value)
+
+	_, ok = c.Get("gojek", "client", "token", "a/b", acl.Sub)
+	require.False(ok)
+
+	c.Invalidate("tapsi", "client")
+
+	_, ok = c.Get("tapsi", "client", "token", "a/b", acl.Sub)
+	require.False(ok)
+
+	_, ok = c.Get("snapp", "client", "token", "a/b", acl.Sub)
+	require.True(ok)
+
+	c.Set("tapsi", "client", "token", "a/b", acl.Sub, "deny", time.Time{})
+	c.Set("tapsi", "other", "token", "a/b", acl.Sub, "deny", time.Time{})
+
+	require.Equal(2, c.Flush("tapsi"))
+	require.Equal(0, c.Flush("tapsi"))
+
+	_, ok = c.Get("tapsi", "other", "token", "a/b", acl.Sub)
+	require.False(ok)
+
+	_, ok = c.Get("snapp", "client", "token", "a/b", acl.Sub)
+	require.True(ok)
+}
+
+func TestCacheCredentials(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	c := session.New[string](session.Config{TTL: time.Minute, MaxEntries: 0})
+
+	c.Set("snapp", "client", "token", "a/b", acl.Sub, "allow", time.Time{})
+
+	// decisions are only returned for the credential which they are made for.
+	_, ok := c.Get("snapp", "client", "forged", "a/b", acl.Sub)
+	require.False(ok)
+
+	value, ok := c.Get("snapp", "client", "token", "a/b", acl.Sub)
+	require.True(ok)
+	require.Equal("allow", value)
+
+	// decisions expire with their credential before the ttl.
+	c.Set("snapp", "client", "expiring", "a/b", acl.Sub, "allow", time.Now().Add(20*time.Millisecond))
+
+	_, ok = c.Get("snapp", "client", "expiring", "a/b", acl.Sub)
+	require.True(ok)
+
+	time.Sleep(30 * time.Millisecond)
+
+	_, ok = c.Get("snapp", "client", "expiring", "a/b", acl.Sub)
+	require.False(ok)
+}
@@ -0,0 +1,76 @@
+// Package singleflight coalesces the concurrent calls with the same key, so a cache miss
+// under load calls the upstream once instead of once per waiting request.
+package singleflight
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrPanicked is the error of callers which wait for a call that panics, the panic is only
+// propagated to the caller which runs the call.
+var ErrPanicked = errors.New("singleflight call panicked")
+
+type call[T any] struct {
+	wg    sync.WaitGroup
+	value T
+	err   error
+}
+
+// Group runs one call per key at a time, its zero value is ready to use.
+type Group[T any] struct {
+	lock  sync.Mutex
+	calls map[string]*call[T]
+}
+
+// Do calls fn and returns its result, callers which call it with the same key while fn is running
+// wait for it and receive its result. shared is true for these waiting callers.
+func (g *Group[T]) Do(key string, fn func() (T, error)) (T, error, bool) { //nolint: revive
+	g.lock.Lock()
+
+	if g.calls == nil {
+		g.calls = make(map[string]*call[T])
+	}
+
+	if c, ok := g.calls[key]; ok {
+		g.lock.Unlock()
+		c.wg.Wait()
+
+		return c.value, c.err, true
+	}
+
+	c := new(call[T])
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.lock.Unlock()
+
+	recovered := g.call(c, fn)
+
+	g.lock.Lock()
+	delete(g.calls, key)
+	g.lock.Unlock()
+
+	c.wg.Done()
+
+	if recovered != nil {
+		panic(recovered)
+	}
+
+	return c.value, c.err, false
+}
+
+// call runs fn and returns its recovered panic, so the waiting callers are released with ErrPanicked
+// before the panic is propagated.
+func (g *Group[T]) call(c *call[T], fn func() (T, error)) (recovered any) {
+	defer func() {
+		if recovered = recover(); recovered != nil {
+			var zero T
+
+			c.value, c.err = zero, ErrPanicked
+		}
+	}()
+
+	c.value, c.err = fn()
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package singleflight_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/snapp-incubator/soteria/internal/singleflight"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDo(t *testing.T) {
+	t.Parallel()
+
+	var (
+		group  singleflight.Group[int]
+		calls  atomic.Int32
+		shared atomic.Int32
+		wg     sync.WaitGroup
+	)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	errFailed := errors.New("failed")
+
+	const callers = 10
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		value, err, ok := group.Do("key", func() (int, error) {
+			close(started)
+			<-release
+			calls.Add(1)
+
+			return 1, errFailed
+		})
+		require.Equal(t, 1, value)
+		require.ErrorIs(t, err, errFailed)
+		require.False(t, ok)
+	}()
+
+	<-started
+
+	for range callers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			value, err, ok := group.Do("key", func() (int, error) {
+				calls.Add(1)
+
+				return 2, nil
+			})
+
+			// callers which start after the first call is finished run their own call.
+			if ok {
+				shared.Add(1)
+				require.Equal(t, 1, value)
+				require.ErrorIs(t, err, errFailed)
+			}
+		}()
+	}
+
+	close(release)
+	wg.Wait()
+
+	require.Equal(t, int32(callers+1), calls.Load()+shared.Load())
+
+	value, err, ok := group.Do("key", func() (int, error) {
+		return 3, nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, value)
+	require.False(t, ok)
+}
+
+func TestDoPanic(t *testing.T) {
+	t.Parallel()
+
+	var group singleflight.Group[int]
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	waited := make(chan error)
+
+	go func() {
+		defer func() {
+			require.Equal(t, "boom", recover())
+		}()
+
+		_, _, _ = group.Do("key", func() (int, error) {
+			close(started)
+			<-release
+
+			panic("boom")
+		})
+	}()
+
+	<-started
+
+	go func() {
+		_, err, _ := group.Do("key", func() (int, error) {
+			return 1, nil
+		})
+
+		waited <- err
+	}()
+
+	close(release)
+
+	// the waiting caller may start after the panic, then it runs its own call.
+	err := <-waited
+	if err != nil {
+		require.ErrorIs(t, err, singleflight.ErrPanicked)
+	}
+
+	// the key is released after the panic.
+	value, err, ok := group.Do("key", func() (int, error) {
+		return 2, nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, value)
+	require.False(t, ok)
+}
@@ -0,0 +1,250 @@
+// Package statecheck asks an external state service about the state of a driver and passenger pair,
+// so accesses like chat subscriptions are only allowed while their ride is active.
+package statecheck
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	serrors "github.com/snapp-incubator/soteria/internal/errors"
+	"github.com/snapp-incubator/soteria/internal/metric"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultTimeout  = 100 * time.Millisecond
+	DefaultCacheTTL = 30 * time.Second
+
+	// DefaultDriverID decodes the driver id from the sub of driver token.
+	DefaultDriverID = "{{ DecodeHashID .sub .iss }}"
+	// DefaultPassengerHash is the third level of topic like snapp/passenger/<hash>/chat.
+	DefaultPassengerHash = "{{ .segment2 }}"
+
+	// maxCacheEntries bounds the cache, expired entries are removed when cache reaches it.
+	maxCacheEntries = 100_000
+)
+
+var (
+	ErrDenied = serrors.ErrStateCheckDenied
+	ErrFailed = serrors.ErrStateCheckFailed
+)
+
+type Config struct {
+	URL      string        `json:"url,omitempty"       koanf:"url"`
+	Timeout  time.Duration `json:"timeout,omitempty"   koanf:"timeout"`
+	CacheTTL time.Duration `json:"cache_ttl,omitempty" koanf:"cache_ttl"`
+	// FailOpen allows the access when state service cannot be called, otherwise access is denied.
+	FailOpen bool `json:"fail_open,omitempty" koanf:"fail_open"`
+	// DriverID and PassengerHash are templates which are rendered with the same fields as topic templates.
+	DriverID      string `json:"driver_id,omitempty"      koanf:"driver_id"`
+	PassengerHash string `json:"passenger_hash,omitempty" koanf:"passenger_hash"`
+}
+
+type Request struct {
+	DriverID      string `json:"driver_id"`
+	PassengerHash string `json:"passenger_hash"`
+}
+
+type Response struct {
+	Active bool `json:"active"`
+}
+
+type entry struct {
+	active  bool
+	expires time.Time
+}
+
+type Client struct {
+	cfg       Config
+	company   string
+	topicType string
+	client    *http.Client
+	tracer    trace.Tracer
+	logger    *zap.Logger
+	metrics   *metric.StateCheckMetrics
+
+	lock  sync.Mutex
+	cache map[Request]entry
+}
+
+// New creates a state service client for a topic type of vendor.
+func New(
+	cfg Config,
+	company, topicType string,
+	tracer trace.Tracer,
+	reg prometheus.Registerer,
+	logger *zap.Logger,
+) *Client {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = DefaultCacheTTL
+	}
+
+	return &Client{
+		cfg:       cfg,
+		company:   company,
+		topicType: topicType,
+		client:    new(http.Client),
+		tracer:    tracer,
+		logger:    logger,
+		metrics:   metric.NewStateCheckMetrics(reg),
+		lock:      sync.Mutex{},
+		cache:     make(map[Request]entry),
+	}
+}
+
+// Check asks the state service about the pair, it returns nil when their state is active.
+func (c *Client) Check(ctx context.Context, driverID, passengerHash string) error {
+	request := Request{
+		DriverID:      driverID,
+		PassengerHash: passengerHash,
+	}
+
+	if active, ok := c.cached(request); ok {
+		c.metrics.Result(c.company, c.topicType, "cache")
+
+		return decision(active)
+	}
+
+	ctx, span := c.tracer.Start(ctx, "statecheck.check")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("topic-type", c.topicType),
+		attribute.String("url", c.cfg.URL),
+	)
+
+	active, err := c.call(ctx, request)
+	if err != nil {
+		span.RecordError(err)
+		c.metrics.Result(c.company, c.topicType, "error")
+
+		c.logger.Error("state check failed",
+			zap.Error(err),
+			zap.String("topic-type", c.topicType),
+			zap.Bool("fail-open", c.cfg.FailOpen),
+		)
+
+		if c.cfg.FailOpen {
+			return nil
+		}
+
+		return fmt.Errorf("%w: %w", ErrFailed, err)
+	}
+
+	c.store(request, active)
+
+	if active {
+		c.metrics.Result(c.company, c.topicType, "active")
+	} else {
+		c.metrics.Result(c.company, c.topicType, "inactive")
+	}
+
+	return decision(active)
+}
+
+func (c *Client) call(ctx context.Context, request Request) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return false, fmt.Errorf("cannot marshal request %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("cannot create request %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("sending request failed %w", err)
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	// services which respond by another status are failing, so fail_open decides the access.
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected status code %d", resp.StatusCode) // nolint: err113
+	}
+
+	var response Response
+
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return false, fmt.Errorf("cannot decode response %w", err)
+	}
+
+	return response.Active, nil
+}
+
+func (c *Client) cached(request Request) (bool, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	e, ok := c.cache[request]
+	if !ok || time.Now().After(e.expires) {
+		return false, false
+	}
+
+	return e.active, true
+}
+
+func (c *Client) store(request Request, active bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now()
+
+	if len(c.cache) >= maxCacheEntries {
+		for k, e := range c.cache {
+			if now.After(e.expires) {
+				delete(c.cache, k)
+			}
+		}
+
+		if len(c.cache) >= maxCacheEntries {
+			c.cache = make(map[Request]entry)
+		}
+	}
+
+	c.cache[request] = entry{
+		active:  active,
+		expires: now.Add(c.cfg.CacheTTL),
+	}
+}
+
+func decision(active bool) error {
+	if active {
+		return nil
+	}
+
+	return ErrDenied
+}
+
+// Flush removes the cached decisions of client.
+func (c *Client) Flush() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.cache = make(map[Request]entry)
+}
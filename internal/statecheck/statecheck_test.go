@@ -0,0 +1,93 @@
+package statecheck_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/statecheck"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+)
+
+// stateServer is a mock state service which has an active ride for the active passenger.
+func stateServer(calls *atomic.Int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		calls.Add(1)
+
+		var request statecheck.Request
+
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			res.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		switch request.PassengerHash {
+		case "active":
+			_, _ = res.Write([]byte(`{"active": true}`))
+		case "inactive":
+			_, _ = res.Write([]byte(`{"active": false}`))
+		case "slow":
+			time.Sleep(100 * time.Millisecond)
+		default:
+			res.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+// nolint: funlen
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	var calls atomic.Int64
+
+	server := stateServer(&calls)
+	defer server.Close()
+
+	// nolint: exhaustruct
+	client := statecheck.New(statecheck.Config{
+		URL:      server.URL,
+		Timeout:  50 * time.Millisecond,
+		CacheTTL: time.Minute,
+		FailOpen: false,
+	}, "snapp", "passenger_chat", noop.NewTracerProvider().Tracer(""), prometheus.NewRegistry(), zap.NewNop())
+
+	ctx := context.Background()
+
+	require.NoError(client.Check(ctx, "1", "active"))
+	require.ErrorIs(client.Check(ctx, "1", "inactive"), statecheck.ErrDenied)
+	// responses with another status than 200 are failures.
+	require.ErrorIs(client.Check(ctx, "1", "unknown"), statecheck.ErrFailed)
+	require.ErrorIs(client.Check(ctx, "1", "slow"), statecheck.ErrFailed)
+	require.Equal(int64(4), calls.Load())
+
+	// results are cached per pair but errors are not.
+	require.NoError(client.Check(ctx, "1", "active"))
+	require.ErrorIs(client.Check(ctx, "1", "inactive"), statecheck.ErrDenied)
+	require.ErrorIs(client.Check(ctx, "1", "unknown"), statecheck.ErrFailed)
+	require.Equal(int64(5), calls.Load())
+
+	require.NoError(client.Check(ctx, "2", "active"))
+	require.Equal(int64(6), calls.Load())
+
+	// nolint: exhaustruct
+	failOpen := statecheck.New(statecheck.Config{
+		URL:      server.URL,
+		Timeout:  50 * time.Millisecond,
+		CacheTTL: time.Minute,
+		FailOpen: true,
+	}, "snapp", "passenger_chat", noop.NewTracerProvider().Tracer(""), prometheus.NewRegistry(), zap.NewNop())
+
+	require.NoError(failOpen.Check(ctx, "1", "slow"))
+	require.NoError(failOpen.Check(ctx, "1", "unknown"))
+	require.ErrorIs(failOpen.Check(ctx, "1", "inactive"), statecheck.ErrDenied)
+}
@@ -0,0 +1,163 @@
+// Package sublimit limits the number of distinct topics of a topic type which each client subscribes,
+// so a single compromised account cannot subscribe to an unbounded number of topics.
+// counters are kept in memory, so each pod counts only the subscriptions which it authorizes.
+package sublimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	serrors "github.com/snapp-incubator/soteria/internal/errors"
+	"github.com/snapp-incubator/soteria/internal/metric"
+	"go.uber.org/zap"
+)
+
+// DefaultTTL is the lifetime of counters when it is not configured.
+const DefaultTTL = time.Hour
+
+type Config struct {
+	// Max is the maximum number of distinct topics which a client can subscribe in TTL, zero means no limit.
+	Max int `json:"max,omitempty" koanf:"max"`
+	// Issuers override the maximum of issuers, zero means no limit for the issuer.
+	Issuers map[string]int `json:"issuers,omitempty" koanf:"issuers"`
+	// TTL is the lifetime of counters from their first subscription, so clients are not locked out forever.
+	TTL time.Duration `json:"ttl,omitempty" koanf:"ttl"`
+}
+
+// Enabled reports whether any limit is configured.
+func (cfg Config) Enabled() bool {
+	if cfg.Max > 0 {
+		return true
+	}
+
+	for _, limit := range cfg.Issuers {
+		if limit > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+type key struct {
+	issuer string
+	sub    string
+}
+
+type counter struct {
+	topics  map[string]struct{}
+	expires time.Time
+}
+
+// Limiter counts the subscribed topics of clients for a topic type, it is safe for concurrent use.
+type Limiter struct {
+	cfg       Config
+	company   string
+	topicType string
+	metrics   *metric.SubscriptionLimitMetrics
+	logger    *zap.Logger
+
+	lock      sync.Mutex
+	counters  map[key]*counter
+	lastSweep time.Time
+}
+
+// New creates a limiter for a topic type, it returns nil when no limit is configured.
+func New(cfg Config, company, topicType string, reg prometheus.Registerer, logger *zap.Logger) *Limiter {
+	if !cfg.Enabled() {
+		return nil
+	}
+
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultTTL
+	}
+
+	return &Limiter{
+		cfg:       cfg,
+		company:   company,
+		topicType: topicType,
+		metrics:   metric.NewSubscriptionLimitMetrics(reg),
+		logger:    logger,
+		lock:      sync.Mutex{},
+		counters:  make(map[key]*counter),
+		lastSweep: time.Now(),
+	}
+}
+
+// Subscribe counts the topic for the client and returns SubscriptionLimitExceededError when client
+// has subscribed to its maximum number of topics. topics which are already counted are always allowed,
+// so clients can resubscribe them, e.g. after reconnecting.
+func (l *Limiter) Subscribe(iss, sub, topic string) error {
+	limit := l.limit(iss)
+	if limit <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.sweep(now)
+
+	id := key{issuer: iss, sub: sub}
+
+	c, ok := l.counters[id]
+	if !ok || now.After(c.expires) {
+		c = &counter{
+			topics:  make(map[string]struct{}),
+			expires: now.Add(l.cfg.TTL),
+		}
+		l.counters[id] = c
+	}
+
+	if _, ok := c.topics[topic]; ok {
+		return nil
+	}
+
+	if len(c.topics) >= limit {
+		l.metrics.Exceeded(l.company, l.topicType, iss)
+
+		l.logger.Warn("subscription limit exceeded",
+			zap.String("topic-type", l.topicType),
+			zap.String("iss", iss),
+			zap.String("sub", sub),
+			zap.Int("max", limit),
+		)
+
+		return serrors.SubscriptionLimitExceededError{
+			TopicType: l.topicType,
+			Issuer:    iss,
+			Sub:       sub,
+			Max:       limit,
+		}
+	}
+
+	c.topics[topic] = struct{}{}
+
+	return nil
+}
+
+func (l *Limiter) limit(iss string) int {
+	if limit, ok := l.cfg.Issuers[iss]; ok {
+		return limit
+	}
+
+	return l.cfg.Max
+}
+
+// sweep removes the expired counters once in each TTL, so clients which are gone don't keep memory.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.cfg.TTL {
+		return
+	}
+
+	l.lastSweep = now
+
+	for id, c := range l.counters {
+		if now.After(c.expires) {
+			delete(l.counters, id)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package sublimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	serrors "github.com/snapp-incubator/soteria/internal/errors"
+	"github.com/snapp-incubator/soteria/internal/sublimit"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestDisabled(t *testing.T) {
+	t.Parallel()
+
+	reg := prometheus.NewRegistry()
+
+	require.Nil(t, sublimit.New(sublimit.Config{Max: 0, Issuers: nil, TTL: 0}, "snapp", "chat", reg, zap.NewNop()))
+	require.Nil(t, sublimit.New(sublimit.Config{Max: 0, Issuers: map[string]int{"0": 0}, TTL: 0}, "snapp", "chat", reg,
+		zap.NewNop()))
+}
+
+func TestSubscribe(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	l := sublimit.New(sublimit.Config{
+		Max:     2,
+		Issuers: map[string]int{"0": 0},
+		TTL:     100 * time.Millisecond,
+	}, "snapp", "chat", prometheus.NewRegistry(), zap.NewNop())
+	require.NotNil(l)
+
+	require.NoError(l.Subscribe("1", "sub", "chat/1"))
+	require.NoError(l.Subscribe("1", "sub", "chat/2"))
+
+	// topics which are already subscribed are allowed.
+	require.NoError(l.Subscribe("1", "sub", "chat/1"))
+
+	var slErr serrors.SubscriptionLimitExceededError
+
+	require.ErrorAs(l.Subscribe("1", "sub", "chat/3"), &slErr)
+	require.Equal(serrors.ReasonSubscriptionLimitExceeded, slErr.Reason())
+	require.Equal(2, slErr.Max)
+
+	// other clients have their own counters.
+	require.NoError(l.Subscribe("1", "other", "chat/3"))
+
+	// issuers can have no limit.
+	for _, topic := range []string{"chat/1", "chat/2", "chat/3"} {
+		require.NoError(l.Subscribe("0", "sub", topic))
+	}
+
+	// counters decay, so clients are not locked out forever.
+	time.Sleep(150 * time.Millisecond)
+
+	require.NoError(l.Subscribe("1", "sub", "chat/3"))
+}
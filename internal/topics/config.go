@@ -1,7 +1,29 @@
 package topics
 
+import "fmt"
+
+const (
+	// HashTypeHashID means the subjects of issuer are hash-ids, it is the default hash type.
+	HashTypeHashID = "hashid"
+	// HashTypeNone means the subjects of issuer are not hashed, so they are used verbatim
+	// instead of their decoded or encoded hash-id.
+	HashTypeNone = "none"
+)
+
 type HashData struct {
 	Length   int    `json:"length,omitempty"   koanf:"length"`
 	Salt     string `json:"salt,omitempty"     koanf:"salt"`
 	Alphabet string `json:"alphabet,omitempty" koanf:"alphabet"`
+	// Type is HashTypeHashID or HashTypeNone, it is HashTypeHashID when it is empty.
+	Type string `json:"type,omitempty" koanf:"type"`
+}
+
+// Validate checks the hash type is known.
+func (d HashData) Validate() error {
+	switch d.Type {
+	case "", HashTypeHashID, HashTypeNone:
+		return nil
+	default:
+		return fmt.Errorf("%w %q", ErrUnknownHashType, d.Type)
+	}
 }
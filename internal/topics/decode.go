@@ -0,0 +1,197 @@
+package topics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"text/template/parse"
+
+	"github.com/speps/go-hashids/v2"
+)
+
+const (
+	// decodeFunction is the function which the DecodeHashID calls of templates are rewritten to.
+	decodeFunction = "decodeSubject"
+	// decodedField is the field of the decoded subject of request, claims cannot set it because fields
+	// of claims are replaced by it.
+	decodedField = "\x00decoded"
+)
+
+var (
+	// ErrUnknownHashIDIssuer is returned for the subjects of issuers which have no hash-id.
+	ErrUnknownHashIDIssuer = errors.New("issuer has no hash-id")
+	ErrEmptyHashID         = errors.New("sub decodes to no id")
+	ErrUnknownHashType     = errors.New("unknown hash type")
+)
+
+type decodedKey struct{}
+
+// decodedSubject identifies a subject by the hash-id which decodes it, so vendors which share the context
+// of a request don't use the subjects of each other.
+type decodedSubject struct {
+	hid *hashids.HashID
+	sub string
+}
+
+// decodedSubjects memoizes the decoded hash-ids of the subjects of a request, so the subject format check
+// of the request and its template rendering decode the subject once. Parallel resolution of vendors shares
+// the request, so it has a lock.
+type decodedSubjects struct {
+	lock sync.Mutex
+	ids  map[decodedSubject]string
+}
+
+// WithDecodedSubjects attaches a memo of the decoded subjects to the context of request.
+func WithDecodedSubjects(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(decodedKey{}).(*decodedSubjects); ok {
+		return ctx
+	}
+
+	return context.WithValue(ctx, decodedKey{}, &decodedSubjects{
+		lock: sync.Mutex{},
+		ids:  make(map[decodedSubject]string),
+	})
+}
+
+func decodedFrom(ctx context.Context) *decodedSubjects {
+	d, _ := ctx.Value(decodedKey{}).(*decodedSubjects)
+
+	return d
+}
+
+func (d *decodedSubjects) get(key decodedSubject) (string, bool) {
+	if d == nil {
+		return "", false
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	id, ok := d.ids[key]
+
+	return id, ok
+}
+
+func (d *decodedSubjects) set(key decodedSubject, id string) {
+	if d == nil {
+		return
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.ids[key] = id
+}
+
+// DecodeSubject decodes the hash-id subject of issuer, subjects are memoized on the request when its context
+// has WithDecodedSubjects. Subjects of issuers with HashTypeNone are returned verbatim.
+func (t *Manager) DecodeSubject(ctx context.Context, sub, iss string) (string, error) {
+	hid, ok := t.HashIDSManager[iss]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownHashIDIssuer, iss)
+	}
+
+	if hid == nil {
+		if sub == "" {
+			return "", ErrEmptyHashID
+		}
+
+		return sub, nil
+	}
+
+	memo := decodedFrom(ctx)
+	key := decodedSubject{hid: hid, sub: sub}
+
+	if id, ok := memo.get(key); ok {
+		return id, nil
+	}
+
+	ids, err := hid.DecodeWithError(sub)
+	if err != nil {
+		return "", fmt.Errorf("cannot decode sub %w", err)
+	}
+
+	if len(ids) == 0 {
+		return "", ErrEmptyHashID
+	}
+
+	id := strconv.Itoa(ids[0])
+	memo.set(key, id)
+
+	return id, nil
+}
+
+// withDecoded adds the memoized subject of request to the fields, which is used by the DecodeHashID
+// calls of templates instead of decoding the subject again.
+func (t *Manager) withDecoded(ctx context.Context, fields map[string]string) map[string]string {
+	hid := t.HashIDSManager[fields["iss"]]
+	if hid == nil {
+		return fields
+	}
+
+	if id, ok := decodedFrom(ctx).get(decodedSubject{hid: hid, sub: fields["sub"]}); ok {
+		fields[decodedField] = id
+	}
+
+	return fields
+}
+
+// decodeSubject is DecodeHashID of the rewritten templates, which have their fields as the first argument.
+func (t *Manager) decodeSubject(fields map[string]string, sub, iss string) string {
+	if id, ok := fields[decodedField]; ok && sub == fields["sub"] && iss == fields["iss"] {
+		return id
+	}
+
+	return t.DecodeHashID(sub, iss)
+}
+
+// memoizeDecode rewrites the DecodeHashID calls of template to decodeSubject calls with the fields of
+// template ($) as their first argument, so they can use the decoded subject of request.
+func memoizeDecode(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+
+		for _, child := range n.Nodes {
+			memoizeDecode(child)
+		}
+	case *parse.ActionNode:
+		memoizeDecode(n.Pipe)
+	case *parse.TemplateNode:
+		memoizeDecode(n.Pipe)
+	case *parse.IfNode:
+		memoizeBranch(&n.BranchNode)
+	case *parse.RangeNode:
+		memoizeBranch(&n.BranchNode)
+	case *parse.WithNode:
+		memoizeBranch(&n.BranchNode)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+
+		for _, cmd := range n.Cmds {
+			memoizeDecode(cmd)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			memoizeDecode(arg)
+		}
+
+		if ident, ok := n.Args[0].(*parse.IdentifierNode); ok && ident.Ident == "DecodeHashID" {
+			ident.Ident = decodeFunction
+			fields := &parse.VariableNode{NodeType: parse.NodeVariable, Pos: ident.Pos, Ident: []string{"$"}}
+			n.Args = append([]parse.Node{ident, fields}, n.Args[1:]...)
+		}
+	}
+}
+
+func memoizeBranch(n *parse.BranchNode) {
+	memoizeDecode(n.Pipe)
+	memoizeDecode(n.List)
+	memoizeDecode(n.ElseList)
+}
@@ -0,0 +1,228 @@
+package topics
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	jwtstrconv "github.com/snapp-incubator/soteria/pkg/strconv"
+	"go.uber.org/zap"
+)
+
+// noRule is the rule of tokens which no entity rule matches, their entity is mapped by iss_entity_map.
+const noRule = -1
+
+var (
+	ErrInvalidEntityRule     = errors.New("entity rule should have iss and entity, and its claim and value together")
+	ErrUnreachableEntityRule = errors.New("entity rule is unreachable, an earlier rule matches all of its tokens")
+	ErrMissingDefaultEntity  = errors.New("issuer of entity rules has no rule without claim or iss_entity_map entry")
+)
+
+// EntityRule resolves the entity of the tokens of an issuer by one of their claims, e.g. guests and passengers
+// which share an issuer. Rules without claim match every token of their issuer.
+// Entity of the matched rule is the key of iss_peer_map and hashid_map before the issuer, so the entity only
+// needs the keys which differ from its issuer. Accesses of rules with claim are only their own accesses.
+type EntityRule struct {
+	Iss    string `json:"iss,omitempty"    koanf:"iss"`
+	Claim  string `json:"claim,omitempty"  koanf:"claim"`
+	Value  string `json:"value,omitempty"  koanf:"value"`
+	Entity string `json:"entity,omitempty" koanf:"entity"`
+}
+
+// EntityRuleError is an error of the entity rule with the given index.
+type EntityRuleError struct {
+	Index int
+	Err   error
+}
+
+func (err EntityRuleError) Error() string {
+	return fmt.Sprintf("iss_entity_rules[%d]: %s", err.Index, err.Err)
+}
+
+func (err EntityRuleError) Unwrap() error {
+	return err.Err
+}
+
+func (r EntityRule) matches(iss string, claims map[string]any) bool {
+	if r.Iss != iss {
+		return false
+	}
+
+	if r.Claim == "" {
+		return true
+	}
+
+	value, ok := claims[r.Claim]
+
+	return ok && jwtstrconv.ToString(value) == r.Value
+}
+
+// ValidateEntityRules checks the rules have their fields, every rule is reachable and every issuer of rules has
+// a default entry, which is a rule without claim or its iss_entity_map entry. Errors are EntityRuleError.
+func ValidateEntityRules(rules []EntityRule, issEntityMap map[string]string) []error {
+	errs := make([]error, 0)
+
+	// last is the index of the last rule of each issuer, which the missing default entry is reported on.
+	last := make(map[string]int)
+	defaults := make(map[string]bool)
+
+	for i, rule := range rules {
+		if rule.Iss == "" || rule.Entity == "" || (rule.Claim == "") != (rule.Value == "") {
+			errs = append(errs, EntityRuleError{Index: i, Err: ErrInvalidEntityRule})
+
+			continue
+		}
+
+		for j, earlier := range rules[:i] {
+			if earlier.Iss == rule.Iss && (earlier.Claim == "" || (earlier.Claim == rule.Claim && earlier.Value == rule.Value)) {
+				errs = append(errs, EntityRuleError{Index: i, Err: fmt.Errorf("%w: rules[%d]", ErrUnreachableEntityRule, j)})
+
+				break
+			}
+		}
+
+		last[rule.Iss] = i
+
+		if rule.Claim == "" {
+			defaults[rule.Iss] = true
+		}
+	}
+
+	for i, rule := range rules {
+		if _, ok := issEntityMap[rule.Iss]; ok || defaults[rule.Iss] || last[rule.Iss] != i {
+			continue
+		}
+
+		errs = append(errs, EntityRuleError{Index: i, Err: fmt.Errorf("%w: %s", ErrMissingDefaultEntity, rule.Iss)})
+	}
+
+	return errs
+}
+
+// WithEntityRules resolves the entity of tokens by the given rules before iss_entity_map.
+func (t *Manager) WithEntityRules(rules []EntityRule) *Manager {
+	t.EntityRules = rules
+
+	// functions which render alternations can make the anchor of templates optional.
+	for _, rule := range rules {
+		if strings.Contains(rule.Entity, "|") {
+			t.prefixes = nil
+		}
+	}
+
+	return t
+}
+
+// rule returns the index of the first entity rule which matches the token of issuer.
+func (t *Manager) rule(iss string, claims map[string]any) int {
+	for i, rule := range t.EntityRules {
+		if rule.matches(iss, claims) {
+			return i
+		}
+	}
+
+	return noRule
+}
+
+// Entity returns the entity of the token of issuer, it is the entity of the first matching rule
+// and the mapped entity of issuer when no rule matches.
+func (t *Manager) Entity(iss string, claims map[string]any) string {
+	if i := t.rule(iss, claims); i != noRule {
+		return t.EntityRules[i].Entity
+	}
+
+	return t.IssEntityMapper(iss)
+}
+
+// staticAccess returns the static access of token on the template. Entities of rules with claim are other users
+// than the rest of tokens of their issuer, e.g. guests of passengers, so they only have their own access and the
+// default access. Entities of rules without claim are the entity of their issuer, so their own access has
+// precedence over the accesses of issuer.
+func (t *Manager) staticAccess(topicTemplate Template, iss, qualifier string, claims map[string]any) acl.AccessType {
+	i := t.rule(iss, claims)
+	if i == noRule {
+		return topicTemplate.Access(iss, qualifier)
+	}
+
+	if rule := t.EntityRules[i]; rule.Claim != "" {
+		return topicTemplate.claimEntityAccess(rule.Entity)
+	}
+
+	return topicTemplate.EntityAccess(t.EntityRules[i].Entity, iss, qualifier)
+}
+
+// boundKey identifies a template which is bound to the functions of an entity rule.
+type boundKey struct {
+	template *template.Template
+	rule     int
+}
+
+// bind returns the template with the functions of the given rule, so IssToEntity, IssToPeer and the hash-id
+// functions resolve the entity of rule for its issuer. Bound templates are cloned once and cached.
+func (t *Manager) bind(tmpl *template.Template, rule int) *template.Template {
+	if rule == noRule || tmpl == nil {
+		return tmpl
+	}
+
+	key := boundKey{template: tmpl, rule: rule}
+
+	if bound, ok := t.bound.Load(key); ok {
+		return bound.(*template.Template) //nolint: forcetypeassert
+	}
+
+	bound, err := tmpl.Clone()
+	if err != nil {
+		t.Logger.Error("cannot bind template to entity rule", zap.Error(err), zap.Int("rule", rule))
+
+		return tmpl
+	}
+
+	actual, _ := t.bound.LoadOrStore(key, bound.Funcs(t.ruleFunctions(t.EntityRules[rule])))
+
+	return actual.(*template.Template) //nolint: forcetypeassert
+}
+
+// ruleFunctions returns the manager functions which use the entity of rule for its issuer.
+func (t *Manager) ruleFunctions(rule EntityRule) template.FuncMap {
+	// hashKey is the hash-id of entity when it has one and the hash-id of issuer otherwise.
+	hashKey := func(iss string) string {
+		if _, ok := t.HashIDSManager[rule.Entity]; ok && iss == rule.Iss {
+			return rule.Entity
+		}
+
+		return iss
+	}
+
+	return template.FuncMap{
+		"IssToEntity": func(iss string) string {
+			if iss == rule.Iss {
+				return rule.Entity
+			}
+
+			return t.IssEntityMapper(iss)
+		},
+		"IssToPeer": func(iss string) string {
+			if peer, ok := t.IssPeerMap[rule.Entity]; ok && iss == rule.Iss {
+				return peer
+			}
+
+			return t.IssPeerMapper(iss)
+		},
+		"DecodeHashID": func(sub, iss string) string {
+			return t.DecodeHashID(sub, hashKey(iss))
+		},
+		"EncodeHashID": func(sub, iss string) string {
+			return t.EncodeHashID(sub, hashKey(iss))
+		},
+		// the decoded subject of request is decoded by the hash-id of issuer.
+		decodeFunction: func(fields map[string]string, sub, iss string) string {
+			if key := hashKey(iss); key != iss {
+				return t.DecodeHashID(sub, key)
+			}
+
+			return t.decodeSubject(fields, sub, iss)
+		},
+	}
+}
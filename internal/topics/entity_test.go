@@ -0,0 +1,182 @@
+package topics_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// nolint: funlen
+func TestEntityRules(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	hid, err := topics.NewHashIDManager(map[string]topics.HashData{
+		topics.PassengerIss: {Length: 15, Salt: "secret", Alphabet: "", Type: ""},
+		"guest":             {Length: 0, Salt: "", Alphabet: "", Type: topics.HashTypeNone},
+	})
+	require.NoError(err)
+
+	// nolint: exhaustruct
+	topicList := []topics.Topic{
+		{
+			Type:     topics.PassengerLocation,
+			Template: "^{{.company}}/{{IssToEntity .iss}}/{{.sub}}/{{IssToPeer .iss}}-location$",
+			Accesses: map[string]acl.AccessType{topics.PassengerIss: acl.Sub, "guest": acl.Sub},
+		},
+		{
+			Type:     topics.CabEvent,
+			Template: "^{{IssToEntity .iss}}-event-{{ EncodeMD5 (DecodeHashID .sub .iss) }}$",
+			Accesses: map[string]acl.AccessType{topics.PassengerIss: acl.Sub},
+		},
+	}
+
+	manager := topics.NewTopicManager(
+		topicList,
+		hid,
+		"snapp",
+		map[string]string{topics.DriverIss: topics.Driver, topics.Default: ""},
+		map[string]string{topics.PassengerIss: topics.Driver, "guest": "support", topics.Default: ""},
+		zap.NewNop(),
+	).WithEntityRules([]topics.EntityRule{
+		{Iss: topics.PassengerIss, Claim: "user_kind", Value: "guest", Entity: "guest"},
+		{Iss: topics.PassengerIss, Claim: "", Value: "", Entity: topics.Passenger},
+	})
+
+	guest := map[string]any{"user_kind": "guest"}
+	passenger := map[string]any{"user_kind": "rider"}
+
+	require.Equal("guest", manager.Entity(topics.PassengerIss, guest))
+	require.Equal(topics.Passenger, manager.Entity(topics.PassengerIss, passenger))
+	require.Equal(topics.Passenger, manager.Entity(topics.PassengerIss, nil))
+	require.Equal(topics.Driver, manager.Entity(topics.DriverIss, guest))
+
+	// entity and peer of templates are resolved by the rules.
+	ctx := context.Background()
+
+	location, err := manager.ParseTopic(ctx, "snapp/guest/g-42/support-location", topics.PassengerIss, "g-42", guest)
+	require.NoError(err)
+	require.NotNil(location)
+	require.Equal(acl.Sub, manager.Access(ctx, location, topics.PassengerIss, "", guest))
+
+	location, err = manager.ParseTopic(ctx, "snapp/passenger/DXKgaNQa7N5Y7bo/driver-location",
+		topics.PassengerIss, "DXKgaNQa7N5Y7bo", passenger)
+	require.NoError(err)
+	require.NotNil(location)
+
+	location, err = manager.ParseTopic(ctx, "snapp/passenger/g-42/driver-location", topics.PassengerIss, "g-42", guest)
+	require.NoError(err)
+	require.Nil(location)
+
+	// subjects of guests are decoded by their own hash-id and they don't have the access of passengers.
+	event, err := manager.ParseTopic(ctx, "guest-event-"+manager.EncodeMD5("g-42"), topics.PassengerIss, "g-42",
+		guest)
+	require.NoError(err)
+	require.NotNil(event)
+	require.False(manager.Access(ctx, event, topics.PassengerIss, "", guest).Allows(acl.Sub))
+
+	permissions := manager.AllowedTopics(ctx, topics.PassengerIss, "g-42", "", guest)
+	require.Len(permissions, 1)
+	require.Equal("snapp/guest/g-42/support-location", permissions[0].Topic)
+
+	require.Len(manager.AllowedTopics(ctx, topics.PassengerIss, "DXKgaNQa7N5Y7bo", "", passenger), 2)
+}
+
+// nolint: funlen
+func TestValidateEntityRules(t *testing.T) {
+	t.Parallel()
+
+	issEntityMap := map[string]string{topics.DriverIss: topics.Driver, topics.Default: ""}
+
+	cases := []struct {
+		name  string
+		rules []topics.EntityRule
+		err   error
+		index int
+	}{
+		{
+			name: "valid",
+			rules: []topics.EntityRule{
+				{Iss: topics.PassengerIss, Claim: "user_kind", Value: "guest", Entity: "guest"},
+				{Iss: topics.PassengerIss, Claim: "user_kind", Value: "staff", Entity: "staff"},
+				{Iss: topics.PassengerIss, Claim: "", Value: "", Entity: topics.Passenger},
+				// issuers of iss_entity_map use it as their default entry.
+				{Iss: topics.DriverIss, Claim: "fleet", Value: "box", Entity: "box"},
+			},
+			err:   nil,
+			index: 0,
+		},
+		{
+			name: "missing entity",
+			rules: []topics.EntityRule{
+				{Iss: topics.DriverIss, Claim: "fleet", Value: "box", Entity: ""},
+			},
+			err:   topics.ErrInvalidEntityRule,
+			index: 0,
+		},
+		{
+			name: "claim without value",
+			rules: []topics.EntityRule{
+				{Iss: topics.DriverIss, Claim: "fleet", Value: "", Entity: "box"},
+			},
+			err:   topics.ErrInvalidEntityRule,
+			index: 0,
+		},
+		{
+			name: "after rule without claim",
+			rules: []topics.EntityRule{
+				{Iss: topics.PassengerIss, Claim: "", Value: "", Entity: topics.Passenger},
+				{Iss: topics.PassengerIss, Claim: "user_kind", Value: "guest", Entity: "guest"},
+			},
+			err:   topics.ErrUnreachableEntityRule,
+			index: 1,
+		},
+		{
+			name: "duplicate condition",
+			rules: []topics.EntityRule{
+				{Iss: topics.DriverIss, Claim: "fleet", Value: "box", Entity: "box"},
+				{Iss: topics.DriverIss, Claim: "fleet", Value: "box", Entity: "courier"},
+			},
+			err:   topics.ErrUnreachableEntityRule,
+			index: 1,
+		},
+		{
+			name: "missing default entry",
+			rules: []topics.EntityRule{
+				{Iss: topics.PassengerIss, Claim: "user_kind", Value: "guest", Entity: "guest"},
+				{Iss: topics.PassengerIss, Claim: "user_kind", Value: "staff", Entity: "staff"},
+			},
+			err:   topics.ErrMissingDefaultEntity,
+			index: 1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			require := require.New(t)
+
+			errs := topics.ValidateEntityRules(c.rules, issEntityMap)
+
+			if c.err == nil {
+				require.Empty(errs)
+
+				return
+			}
+
+			require.Len(errs, 1)
+			require.ErrorIs(errs[0], c.err)
+
+			var ruleErr topics.EntityRuleError
+
+			require.ErrorAs(errs[0], &ruleErr)
+			require.Equal(c.index, ruleErr.Index)
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package topics
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	serrors "github.com/snapp-incubator/soteria/internal/errors"
+	regexp "github.com/wasilibs/go-re2"
+)
+
+var ErrInvalidFieldValues = errors.New("allowed values of topic field are invalid")
+
+// FieldValues restricts a level of topic to the known values, e.g. the nodes of call system
+// in the node_call_entry topics.
+type FieldValues struct {
+	// Segment is the position of field in topic, e.g. 4 in snapp/driver/<hash>/call/<node>/send.
+	Segment int `json:"segment,omitempty" koanf:"segment"`
+	// Values are the allowed values of field.
+	Values []string `json:"values,omitempty" koanf:"values"`
+	// Pattern is a regular expression which matches the whole of the other allowed values.
+	Pattern string `json:"pattern,omitempty" koanf:"pattern"`
+}
+
+// FieldMatcher checks the values of a topic field.
+type FieldMatcher struct {
+	Field   string
+	Segment int
+	values  map[string]struct{}
+	pattern *regexp.Regexp
+}
+
+// Matcher validates the allowed values of field and compiles them.
+func (v FieldValues) Matcher(field string) (*FieldMatcher, error) {
+	if v.Segment < 0 || v.Segment >= MaxSegments {
+		return nil, fmt.Errorf("%w: %s has segment %d", ErrInvalidFieldValues, field, v.Segment)
+	}
+
+	if len(v.Values) == 0 && v.Pattern == "" {
+		return nil, fmt.Errorf("%w: %s has neither values nor pattern", ErrInvalidFieldValues, field)
+	}
+
+	m := &FieldMatcher{
+		Field:   field,
+		Segment: v.Segment,
+		values:  make(map[string]struct{}, len(v.Values)),
+		pattern: nil,
+	}
+
+	for _, value := range v.Values {
+		m.values[value] = struct{}{}
+	}
+
+	if v.Pattern != "" {
+		pattern, err := regexp.Compile("^(?:" + v.Pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s has invalid pattern %w", ErrInvalidFieldValues, field, err)
+		}
+
+		m.pattern = pattern
+	}
+
+	return m, nil
+}
+
+// Allows checks the value is one of the allowed values or matches their pattern.
+func (m *FieldMatcher) Allows(value string) bool {
+	if _, ok := m.values[value]; ok {
+		return true
+	}
+
+	return m.pattern != nil && m.pattern.MatchString(value)
+}
+
+// fieldMatchers compiles the allowed values of topic fields in the order of their names,
+// they are validated by the authenticator builder.
+func fieldMatchers(fields map[string]FieldValues) []*FieldMatcher {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	matchers := make([]*FieldMatcher, 0, len(fields))
+
+	for _, field := range slices.Sorted(maps.Keys(fields)) {
+		m, err := fields[field].Matcher(field)
+		if err != nil {
+			panic(err)
+		}
+
+		matchers = append(matchers, m)
+	}
+
+	return matchers
+}
+
+// CheckFields checks the fields of topic have their allowed values, templates without
+// allowed values are not checked.
+func (t Template) CheckFields(topic, iss, sub string) error {
+	if len(t.AllowedValues) == 0 {
+		return nil
+	}
+
+	levels := strings.Split(topic, Separator)
+
+	for _, m := range t.AllowedValues {
+		value := ""
+		if m.Segment < len(levels) {
+			value = levels[m.Segment]
+		}
+
+		if !m.Allows(value) {
+			return serrors.InvalidTopicFieldError{
+				TopicType: t.Type,
+				Issuer:    iss,
+				Sub:       sub,
+				Field:     m.Field,
+				Value:     value,
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package topics_test
+
+import (
+	"testing"
+
+	serrors "github.com/snapp-incubator/soteria/internal/errors"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestFieldValuesMatcher(t *testing.T) {
+	t.Parallel()
+
+	m, err := topics.FieldValues{Segment: 4, Values: []string{"sfu1", "sfu2"}, Pattern: "heliograph-[0-9]+"}.Matcher("node")
+	require.NoError(t, err)
+
+	require.True(t, m.Allows("sfu1"))
+	require.True(t, m.Allows("heliograph-0"))
+	require.False(t, m.Allows("sfu3"))
+	// patterns match the whole value.
+	require.False(t, m.Allows("x-heliograph-0"))
+
+	_, err = topics.FieldValues{Segment: 4, Values: nil, Pattern: ""}.Matcher("node")
+	require.ErrorIs(t, err, topics.ErrInvalidFieldValues)
+
+	_, err = topics.FieldValues{Segment: topics.MaxSegments, Values: []string{"sfu1"}, Pattern: ""}.Matcher("node")
+	require.ErrorIs(t, err, topics.ErrInvalidFieldValues)
+}
+
+func TestTopicCheckFields(t *testing.T) {
+	t.Parallel()
+
+	// nolint: exhaustruct
+	manager := topics.NewTopicManager([]topics.Topic{
+		{
+			Type:     topics.NodeCallEntry,
+			Template: "^{{.company}}/{{IssToEntity .iss}}/{{.sub}}/call/[a-zA-Z0-9-_]+/send$",
+			AllowedValues: map[string]topics.FieldValues{
+				"node": {Segment: 4, Values: []string{"sfu1", "sfu2"}},
+			},
+		},
+	}, nil, "snapp", map[string]string{topics.Default: "driver"}, map[string]string{topics.Default: "passenger"}, zap.NewNop())
+
+	temp := manager.TopicTemplates[0]
+
+	require.NoError(t, temp.CheckFields("snapp/driver/sub/call/sfu1/send", topics.DriverIss, "sub"))
+
+	var itfErr serrors.InvalidTopicFieldError
+
+	err := temp.CheckFields("snapp/driver/sub/call/made-up/send", topics.DriverIss, "sub")
+	require.ErrorAs(t, err, &itfErr)
+	require.Equal(t, "node", itfErr.Field)
+	require.Equal(t, "made-up", itfErr.Value)
+	require.Equal(t, serrors.ReasonInvalidTopicField, itfErr.Reason())
+
+	// nolint: exhaustruct
+	without := topics.Template{Type: topics.NodeCallEntry}
+	require.NoError(t, without.CheckFields("snapp/driver/sub/call/made-up/send", topics.DriverIss, "sub"))
+}
@@ -4,17 +4,32 @@
 package topics
 
 import (
+	"context"
 	"crypto/md5" //nolint: gosec
 	"encoding/hex"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"text/template"
-
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/budget"
+	"github.com/snapp-incubator/soteria/internal/flags"
+	"github.com/snapp-incubator/soteria/internal/metric"
+	"github.com/snapp-incubator/soteria/internal/postauth"
+	"github.com/snapp-incubator/soteria/internal/remoteaccess"
+	"github.com/snapp-incubator/soteria/internal/statecheck"
+	"github.com/snapp-incubator/soteria/internal/sublimit"
+	"github.com/snapp-incubator/soteria/pkg/acl"
 	jwtstrconv "github.com/snapp-incubator/soteria/pkg/strconv"
 	"github.com/speps/go-hashids/v2"
 	regexp "github.com/wasilibs/go-re2"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 const (
@@ -45,6 +60,21 @@ const (
 	EmqCabHashPrefix = "emqch"
 
 	Default = "default"
+
+	// SegmentPrefix is the prefix of positional fields, segment0 is the first part of topic
+	// before the first separator.
+	SegmentPrefix = "segment"
+	// MaxSegments is the maximum number of positional fields extracted from a topic.
+	MaxSegments = 16
+	// Separator is the MQTT topic levels separator.
+	Separator = "/"
+	// QualifierSeparator separates the issuer and its qualifier in the accesses keys.
+	QualifierSeparator = ":"
+	// DeprecatedWarnInterval is the interval of sampling the deprecated topic warnings.
+	DeprecatedWarnInterval = time.Second
+	// MissingKeyOption fails the rendering of templates which reference a missing field,
+	// instead of rendering them as empty string.
+	MissingKeyOption = "missingkey=error"
 )
 
 type Manager struct {
@@ -53,11 +83,32 @@ type Manager struct {
 	TopicTemplates []Template
 	IssEntityMap   map[string]string
 	IssPeerMap     map[string]string
-	Functions      template.FuncMap
-	Logger         *zap.Logger
+	// EntityRules resolve the entity of tokens by their claims before IssEntityMap.
+	EntityRules []EntityRule
+	Functions   template.FuncMap
+	Logger      *zap.Logger
+	// Metrics counts the matches of deprecated templates and the normalized topics, they are not counted
+	// when it is nil.
+	Metrics *metric.TopicMetrics
+	// Flags are the runtime feature flags which enable the normalization of topics, nil flags use the default values.
+	Flags *flags.Flags
+	// AllowedAccessTypes are the allowed access types of vendor, which static topics and templates without
+	// their own allowed access types use. Nil allows every access type.
+	AllowedAccessTypes []acl.AccessType
+
+	// sampled logs the first deprecated match of each interval.
+	sampled *zap.Logger
+	// prefixes narrows the templates which can match a topic, all templates are tried when it is nil.
+	prefixes *trie
+	// statics are the static topics which are matched before the templates.
+	statics []staticTemplate
+	// bound caches the templates which are bound to the functions of entity rules.
+	bound sync.Map
+	// registerer registers the metrics of topic clients, e.g. post authorizers and state checkers.
+	registerer prometheus.Registerer
 }
 
-// NewTopicManager returns a topic manager to validate topics.
+// NewTopicManager returns a topic manager to validate topics, templates are matched in the order of Ordered.
 func NewTopicManager(
 	topicList []Topic,
 	hashIDManager map[string]*hashids.HashID,
@@ -73,65 +124,463 @@ func NewTopicManager(
 		Logger: logger.With(
 			zap.String("company", company),
 		),
+		Metrics:    nil,
+		registerer: prometheus.DefaultRegisterer,
 	}
 
-	manager.Functions = template.FuncMap{
-		"IssToEntity":  manager.IssEntityMapper,
-		"DecodeHashID": manager.DecodeHashID,
-		"EncodeHashID": manager.EncodeHashID,
-		"EncodeMD5":    manager.EncodeMD5,
-		"IssToPeer":    manager.IssPeerMapper,
-	}
+	manager.sampled = manager.Logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewSamplerWithOptions(core, DeprecatedWarnInterval, 1, 0)
+	}))
+
+	manager.Functions = manager.functions()
 
 	templates := make([]Template, 0)
+	prefixes := newTrie()
 
-	for _, topic := range topicList {
+	for i, topic := range Ordered(topicList) {
 		each := Template{
-			Type:     topic.Type,
-			Template: template.Must(template.New(topic.Type).Funcs(manager.Functions).Parse(topic.Template)),
-			Accesses: topic.Accesses,
+			Type:            topic.Type,
+			Template:        manager.parsePattern(topic.Type, topic.Template),
+			Accesses:        topic.Accesses,
+			MaxPayloadBytes: topic.MaxPayloadBytes,
+			PostAuthorizer:  nil,
+			StateCheck:      nil,
+			Deprecated:      topic.Deprecated,
+			// allowed access types are parsed by the authenticator builder.
+			AllowedAccessTypes:  nil,
+			SubscriptionLimiter: nil,
+			RideMembership:      rideMembership(topic.RideMembership),
+			AllowedValues:       fieldMatchers(topic.AllowedValues),
+			PayloadChecks:       topic.PayloadChecks,
+			RemoteAccesses:      nil,
 		}
 		templates = append(templates, each)
+
+		prefixes.insert(LiteralPrefix(each.Template, company), i)
 	}
 
 	manager.TopicTemplates = templates
 
+	// functions which render alternations can make the anchor of templates optional.
+	if !alternation(issEntityMap, nil) && !alternation(issPeerMap, nil) {
+		manager.prefixes = prefixes
+	}
+
 	return manager
 }
 
+// functions returns the functions of templates, its decodeSubject is only called by the rewritten
+// DecodeHashID calls.
+func (t *Manager) functions() template.FuncMap {
+	return template.FuncMap{
+		"IssToEntity":  t.IssEntityMapper,
+		"DecodeHashID": t.DecodeHashID,
+		"EncodeHashID": t.EncodeHashID,
+		"EncodeMD5":    t.EncodeMD5,
+		"IssToPeer":    t.IssPeerMapper,
+		decodeFunction: t.decodeSubject,
+		quoteFunction:  regexp.QuoteMeta,
+	}
+}
+
+// rideMembership returns the ride membership of topic with its default claim.
+func rideMembership(membership *RideMembership) *RideMembership {
+	if membership == nil {
+		return nil
+	}
+
+	result := *membership
+	if result.Claim == "" {
+		result.Claim = DefaultRideClaim
+	}
+
+	return &result
+}
+
+// parse parses the template with the manager functions, its DecodeHashID calls use the decoded subject of request.
+func (t *Manager) parse(name, text string) *template.Template {
+	tmpl := template.Must(template.New(name).Funcs(t.Functions).Option(MissingKeyOption).Parse(text))
+
+	if tmpl.Tree != nil {
+		memoizeDecode(tmpl.Tree.Root)
+	}
+
+	return tmpl
+}
+
+// parsePattern parses the topic template same as parse, its segment actions are quoted because topic templates
+// are regular expressions.
+func (t *Manager) parsePattern(name, text string) *template.Template {
+	tmpl := t.parse(name, text)
+
+	if tmpl.Tree != nil {
+		quoteSegments(tmpl.Tree.Root)
+	}
+
+	return tmpl
+}
+
+// WithRegisterer counts the metrics of manager and registers them and the metrics of topic clients on reg,
+// so it is called before the topic clients are created.
+func (t *Manager) WithRegisterer(reg prometheus.Registerer) *Manager {
+	t.registerer = reg
+	t.Metrics = metric.NewTopicMetrics(reg)
+
+	return t
+}
+
+// WithAllowedAccessTypes sets the allowed access types of vendor.
+func (t *Manager) WithAllowedAccessTypes(allowed []acl.AccessType) *Manager {
+	t.AllowedAccessTypes = allowed
+
+	return t
+}
+
+// WithFlags sets the feature flags, topics are normalized before matching them when the normalize_topics flag of
+// vendor is enabled.
+func (t *Manager) WithFlags(f *flags.Flags) *Manager {
+	t.Flags = f
+
+	return t
+}
+
+// WithPostAuthorizers creates webhook clients for topics which have post authorize webhook.
+func (t *Manager) WithPostAuthorizers(topicList []Topic, tracer trace.Tracer) *Manager {
+	for i, topic := range Ordered(topicList) {
+		if topic.PostAuthorizeWebhook == nil || i >= len(t.TopicTemplates) {
+			continue
+		}
+
+		t.TopicTemplates[i].PostAuthorizer = postauth.New(
+			*topic.PostAuthorizeWebhook,
+			t.Company,
+			topic.Type,
+			tracer,
+			t.registerer,
+			t.Logger.Named("postauth"),
+		)
+	}
+
+	return t
+}
+
+// WithStateCheckers creates state service clients for topics which have state check.
+func (t *Manager) WithStateCheckers(topicList []Topic, tracer trace.Tracer) *Manager {
+	for i, topic := range Ordered(topicList) {
+		if topic.StateCheck == nil || i >= len(t.TopicTemplates) {
+			continue
+		}
+
+		driverID := topic.StateCheck.DriverID
+		if driverID == "" {
+			driverID = statecheck.DefaultDriverID
+		}
+
+		passengerHash := topic.StateCheck.PassengerHash
+		if passengerHash == "" {
+			passengerHash = statecheck.DefaultPassengerHash
+		}
+
+		t.TopicTemplates[i].StateCheck = &StateCheck{
+			Client: statecheck.New(
+				*topic.StateCheck,
+				t.Company,
+				topic.Type,
+				tracer,
+				t.registerer,
+				t.Logger.Named("statecheck"),
+			),
+			DriverID:      t.parse(topic.Type, driverID),
+			PassengerHash: t.parse(topic.Type, passengerHash),
+		}
+	}
+
+	return t
+}
+
+// WithRemoteAccesses creates remote accesses clients for topics which have remote accesses source.
+func (t *Manager) WithRemoteAccesses(topicList []Topic, tracer trace.Tracer) *Manager {
+	for i, topic := range Ordered(topicList) {
+		if topic.AccessesSource != AccessesSourceRemote || topic.RemoteAccesses == nil || i >= len(t.TopicTemplates) {
+			continue
+		}
+
+		t.TopicTemplates[i].RemoteAccesses = remoteaccess.New(
+			*topic.RemoteAccesses,
+			t.Company,
+			topic.Type,
+			tracer,
+			t.registerer,
+			t.Logger.Named("remoteaccess"),
+		)
+	}
+
+	return t
+}
+
+// WithSubscriptionLimits creates subscription limiters for topics which have subscription limit,
+// templates which share their type share the limiter of the first one.
+func (t *Manager) WithSubscriptionLimits(topicList []Topic) *Manager {
+	limiters := make(map[string]*sublimit.Limiter)
+
+	for i, topic := range Ordered(topicList) {
+		if topic.SubscriptionLimit == nil || i >= len(t.TopicTemplates) {
+			continue
+		}
+
+		limiter, ok := limiters[topic.Type]
+		if !ok {
+			limiter = sublimit.New(*topic.SubscriptionLimit, t.Company, topic.Type, t.registerer, t.Logger.Named("sublimit"))
+			limiters[topic.Type] = limiter
+		}
+
+		t.TopicTemplates[i].SubscriptionLimiter = limiter
+	}
+
+	return t
+}
+
+// Flush removes the cached webhook decisions, states and remote accesses of topics,
+// nil manager has nothing to flush.
+func (t *Manager) Flush() {
+	if t == nil {
+		return
+	}
+
+	for _, topicTemplate := range t.TopicTemplates {
+		if topicTemplate.PostAuthorizer != nil {
+			topicTemplate.PostAuthorizer.Flush()
+		}
+
+		if topicTemplate.StateCheck != nil {
+			topicTemplate.StateCheck.Client.Flush()
+		}
+
+		if topicTemplate.RemoteAccesses != nil {
+			topicTemplate.RemoteAccesses.Flush()
+		}
+	}
+}
+
+// Access returns the effective access of user on the topic which is matched by the given template.
+// Templates with remote accesses ask the remote service about the entity of token and their
+// static access is the fallback, the other templates only use their static access.
+func (t *Manager) Access(
+	ctx context.Context,
+	topicTemplate *Template,
+	iss, qualifier string,
+	claims map[string]any,
+) acl.AccessType {
+	static := t.staticAccess(*topicTemplate, iss, qualifier, claims)
+
+	if topicTemplate.RemoteAccesses == nil {
+		return static
+	}
+
+	budget.SetStage(ctx, budget.StageRemoteAccess)
+	defer budget.SetStage(ctx, budget.StageParseTopic)
+
+	return topicTemplate.RemoteAccesses.Access(ctx, t.Entity(iss, claims), static)
+}
+
+// CheckState checks the state of the topic which is matched by the given template, it is skipped
+// for topics without state check. the request fields are rendered using the same fields as topic.
+func (t *Manager) CheckState(ctx context.Context, topicTemplate *Template, topic, iss, sub string, claims map[string]any) error {
+	if topicTemplate.StateCheck == nil {
+		return nil
+	}
+
+	fields := t.withDecoded(ctx, t.fields(iss, sub, claims, Segments(topic)))
+	rule := t.rule(iss, claims)
+
+	driverID, err := Template{ //nolint: exhaustruct
+		Type:     topicTemplate.Type,
+		Template: t.bind(topicTemplate.StateCheck.DriverID, rule),
+	}.Parse(fields)
+	if err != nil {
+		return fmt.Errorf("%w: cannot render driver id %w", statecheck.ErrFailed, err)
+	}
+
+	passengerHash, err := Template{ //nolint: exhaustruct
+		Type:     topicTemplate.Type,
+		Template: t.bind(topicTemplate.StateCheck.PassengerHash, rule),
+	}.Parse(fields)
+	if err != nil {
+		return fmt.Errorf("%w: cannot render passenger hash %w", statecheck.ErrFailed, err)
+	}
+
+	return topicTemplate.StateCheck.Client.Check(ctx, driverID, passengerHash) //nolint: wrapcheck
+}
+
 // ParseTopic checks if a topic is valid based on the given parameters.
-func (t *Manager) ParseTopic(topic, iss, sub string, claims map[string]any) *Template {
+// templates are tried in their matching order, so templates which share a type are all matched.
+// It returns nil template without error when no template matches the topic, and the first
+// TemplateRenderError when no template matches and some of them cannot be rendered.
+func (t *Manager) ParseTopic(
+	ctx context.Context, topic, iss, sub string, claims map[string]any,
+) (*Template, error) {
+	topic = t.Normalize(topic)
+
+	if topicTemplate, ok := t.matchStatic(topic); ok {
+		t.matched(MatchStatic)
+
+		return topicTemplate, nil
+	}
+
+	segments := Segments(topic)
+	fields := t.withDecoded(ctx, t.fields(iss, sub, claims, segments))
+	rule := t.rule(iss, claims)
+
+	var buf [candidatesSize]int
+
+	for _, i := range t.candidates(topic, fields, segments, buf[:0]) {
+		topicTemplate := t.TopicTemplates[i]
+
+		regex, err := t.render(topicTemplate, fields, rule)
+		if err != nil {
+			continue
+		}
+
+		if regexp.MustCompile(regex).MatchString(topic) {
+			if topicTemplate.Deprecated {
+				t.deprecated(topicTemplate, topic, iss)
+			}
+
+			t.matched(MatchTemplate)
+
+			return &topicTemplate, nil
+		}
+	}
+
+	// templates which cannot match the topic are not rendered, so they are rendered here
+	// to return the same error as trying all templates in order.
+	for _, topicTemplate := range t.TopicTemplates {
+		if _, err := topicTemplate.Parse(fields); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+// candidates returns the index of templates which their literal prefix matches the topic in order.
+// all templates are candidates when fields have alternation, because it makes their anchor optional.
+func (t *Manager) candidates(topic string, fields, segments map[string]string, dst []int) []int {
+	if t.prefixes == nil || t.prefixes.size != len(t.TopicTemplates) || alternation(fields, segments) {
+		for i := range t.TopicTemplates {
+			dst = append(dst, i)
+		}
+
+		return dst
+	}
+
+	dst = t.prefixes.match(topic, dst)
+	slices.Sort(dst)
+
+	return dst
+}
+
+// alternation reports whether any of values has an alternation, segments are skipped because
+// they are quoted.
+func alternation(values, segments map[string]string) bool {
+	for k, v := range values {
+		if _, ok := segments[k]; ok {
+			continue
+		}
+
+		if strings.Contains(v, "|") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matched counts the matched topics by their match, static or template.
+func (t *Manager) matched(match string) {
+	if t.Metrics != nil {
+		t.Metrics.Matched(t.Company, match)
+	}
+}
+
+// deprecated counts the match of a deprecated template and logs a sampled warning.
+func (t *Manager) deprecated(topicTemplate Template, topic, iss string) {
+	if t.Metrics != nil {
+		t.Metrics.Deprecated(t.Company, topicTemplate.Type)
+	}
+
+	if t.sampled != nil {
+		t.sampled.Warn("topic matched a deprecated template",
+			zap.String("type", topicTemplate.Type),
+			zap.String("topic", topic),
+			zap.String("iss", iss),
+		)
+	}
+}
+
+// fields returns the template fields of a client, segments are the positional fields of topic.
+func (t *Manager) fields(iss, sub string, claims map[string]any, segments map[string]string) map[string]string {
 	fields := make(map[string]string)
 
 	for k, v := range claims {
 		fields[k] = jwtstrconv.ToString(v)
 	}
 
+	// positional fields are always defined, so templates can reference the missing segments of short topics.
+	for i := range MaxSegments {
+		fields[SegmentPrefix+strconv.Itoa(i)] = ""
+	}
+
+	for k, v := range segments {
+		fields[k] = v
+	}
+
 	fields["iss"] = iss
 	fields["company"] = t.Company
 	fields["sub"] = sub
 
-	for _, topicTemplate := range t.TopicTemplates {
-		regex := new(strings.Builder)
+	delete(fields, decodedField)
 
-		if err := topicTemplate.Template.Execute(regex, fields); err != nil {
-			t.Logger.Error("template execution failed", zap.Error(err), zap.String("template", topicTemplate.Type))
+	return fields
+}
 
-			return nil
-		}
+// render executes the topic template with the functions of the matched entity rule,
+// which results in the topic regular expression.
+func (t *Manager) render(topicTemplate Template, fields map[string]string, rule int) (string, error) {
+	topicTemplate.Template = t.bind(topicTemplate.Template, rule)
 
-		t.Logger.Debug("topic template generated",
-			zap.String("topic", regex.String()),
-			zap.String("iss", iss),
-			zap.String("sub", sub),
-		)
+	regex, err := topicTemplate.Parse(fields)
+	if err != nil {
+		t.Logger.Error("template execution failed", zap.Error(err), zap.String("template", topicTemplate.Type))
 
-		if regexp.MustCompile(regex.String()).MatchString(topic) {
-			return &topicTemplate
-		}
+		return "", err
 	}
 
-	return nil
+	t.Logger.Debug("topic template generated",
+		zap.String("topic", regex),
+		zap.String("iss", fields["iss"]),
+		zap.String("sub", fields["sub"]),
+	)
+
+	return regex, nil
+}
+
+// Segments extracts positional fields of topic (segment0, segment1, ...) which can be used in templates.
+// the missing segments of short topics are not present and render as empty string. The values are verbatim,
+// topic templates quote the output of their segment actions.
+func Segments(topic string) map[string]string {
+	segments := strings.SplitN(topic, Separator, MaxSegments+1)
+	if len(segments) > MaxSegments {
+		segments = segments[:MaxSegments]
+	}
+
+	fields := make(map[string]string, len(segments))
+
+	for i, segment := range segments {
+		fields[SegmentPrefix+strconv.Itoa(i)] = segment
+	}
+
+	return fields
 }
 
 func (t *Manager) EncodeMD5(iss string) string {
@@ -141,17 +590,29 @@ func (t *Manager) EncodeMD5(iss string) string {
 }
 
 func (t *Manager) DecodeHashID(sub, iss string) string {
-	id, err := t.HashIDSManager[iss].DecodeWithError(sub)
+	id, err := t.DecodeSubject(context.Background(), sub, iss)
 	if err != nil {
 		t.Logger.Error("decoding sub failed", zap.Error(err), zap.String("sub", sub))
 
 		return ""
 	}
 
-	return strconv.Itoa(id[0])
+	return id
 }
 
 func (t *Manager) EncodeHashID(sub, iss string) string {
+	hid, ok := t.HashIDSManager[iss]
+	if !ok {
+		t.Logger.Error("encoding sub failed", zap.Error(ErrUnknownHashIDIssuer), zap.String("iss", iss))
+
+		return ""
+	}
+
+	// subjects of issuers without hashing are used verbatim.
+	if hid == nil {
+		return sub
+	}
+
 	subInt, err := strconv.Atoi(sub)
 	if err != nil {
 		t.Logger.Error("encoding sub failed", zap.Error(err), zap.String("sub", sub))
@@ -159,7 +620,7 @@ func (t *Manager) EncodeHashID(sub, iss string) string {
 		return ""
 	}
 
-	id, err := t.HashIDSManager[iss].Encode([]int{subInt})
+	id, err := hid.Encode([]int{subInt})
 	if err != nil {
 		t.Logger.Error("encoding sub failed", zap.Error(err), zap.String("sub", sub))
 
@@ -187,11 +648,21 @@ func (t *Manager) IssPeerMapper(iss string) string {
 	return t.IssPeerMap[Default]
 }
 
+// NewHashIDManager creates the hash-ids of issuers, issuers with HashTypeNone have nil hash-id
+// which means their subjects are used verbatim.
 func NewHashIDManager(hidmap map[string]HashData) (map[string]*hashids.HashID, error) {
 	hid := make(map[string]*hashids.HashID)
 
 	for iss, data := range hidmap {
-		var err error
+		if err := data.Validate(); err != nil {
+			return nil, fmt.Errorf("%w of issuer %s", err, iss)
+		}
+
+		if data.Type == HashTypeNone {
+			hid[iss] = nil
+
+			continue
+		}
 
 		hd := hashids.NewData()
 		hd.Salt = data.Salt
@@ -201,6 +672,8 @@ func NewHashIDManager(hidmap map[string]HashData) (map[string]*hashids.HashID, e
 			hd.Alphabet = data.Alphabet
 		}
 
+		var err error
+
 		hid[iss], err = hashids.NewWithData(hd)
 		if err != nil {
 			return nil, fmt.Errorf("cannot create hashid enc/dec %w", err)
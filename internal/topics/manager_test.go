@@ -1,11 +1,23 @@
 package topics_test
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/snapp-incubator/soteria/internal/config"
+	serrors "github.com/snapp-incubator/soteria/internal/errors"
+	"github.com/snapp-incubator/soteria/internal/remoteaccess"
 	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace/noop"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 // nolint: funlen
@@ -120,6 +132,18 @@ func TestTopicManager(t *testing.T) {
 			issuer: topics.DriverIss,
 			want:   topics.CallOutgoing,
 		},
+		{
+			name:   "testing truncated node call entry",
+			arg:    "snapp/driver/DXKgaNQa7N5Y7bo/call",
+			issuer: topics.DriverIss,
+			want:   "",
+		},
+		{
+			name:   "testing over-long node call entry",
+			arg:    "snapp/driver/DXKgaNQa7N5Y7bo/call/heliograph-1/send/more/levels",
+			issuer: topics.DriverIss,
+			want:   "",
+		},
 		{
 			name:   "testing box event",
 			arg:    "bucks",
@@ -145,7 +169,13 @@ func TestTopicManager(t *testing.T) {
 
 			topic := tc.arg
 
-			topicTemplate := topicManager.ParseTopic(topic, tc.issuer, sub, nil)
+			// issuers without hash id cannot render the templates which decode their sub.
+			topicTemplate, err := topicManager.ParseTopic(context.Background(), topic, tc.issuer, sub, nil)
+			if err != nil {
+				require.ErrorAs(t, err, new(serrors.TemplateRenderError))
+				require.Empty(t, tc.want)
+			}
+
 			if topicTemplate != nil {
 				if len(tc.want) == 0 {
 					t.Errorf("topic %s is invalid, must throw error.", tc.arg)
@@ -160,3 +190,378 @@ func TestTopicManager(t *testing.T) {
 		})
 	}
 }
+
+// nolint: funlen
+func TestTopicManagerSegments(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.SnappVendor()
+
+	hid, err := topics.NewHashIDManager(cfg.HashIDMap)
+	require.NoError(t, err)
+
+	topicManager := topics.NewTopicManager([]topics.Topic{
+		{
+			Type:     topics.NodeCallEntry,
+			Template: "^{{.company}}/{{IssToEntity .iss}}/{{.sub}}/call/{{.segment4}}/send$",
+			Accesses: map[string]acl.AccessType{
+				topics.DriverIss: acl.Pub,
+			},
+			MaxPayloadBytes: 0,
+		},
+	}, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop())
+
+	sub := "DXKgaNQa7N5Y7bo"
+
+	tests := []struct {
+		name  string
+		topic string
+		valid bool
+	}{
+		{name: "valid node", topic: "snapp/driver/DXKgaNQa7N5Y7bo/call/heliograph-0/send", valid: true},
+		{name: "empty", topic: "", valid: false},
+		{name: "single segment", topic: "snapp", valid: false},
+		{name: "truncated", topic: "snapp/driver/DXKgaNQa7N5Y7bo/call", valid: false},
+		{name: "truncated with separator", topic: "snapp/driver/DXKgaNQa7N5Y7bo/call/", valid: false},
+		{name: "over-long", topic: "snapp/driver/DXKgaNQa7N5Y7bo/call/heliograph-0/send/extra", valid: false},
+		{name: "many levels", topic: strings.Repeat("snapp/", 100), valid: false},
+		{name: "regex in segment", topic: "snapp/driver/DXKgaNQa7N5Y7bo/call/.*/send", valid: true},
+		{name: "regex does not match", topic: "snapp/driver/DXKgaNQa7N5Y7bo/call/(a|b)/send/x", valid: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			topicTemplate, err := topicManager.ParseTopic(context.Background(), tc.topic, topics.DriverIss, sub, nil)
+			require.NoError(t, err)
+
+			if tc.valid {
+				require.NotNil(t, topicTemplate)
+				require.Equal(t, topics.NodeCallEntry, topicTemplate.Type)
+			} else {
+				require.Nil(t, topicTemplate)
+			}
+		})
+	}
+}
+
+// nolint: funlen
+func TestTopicManagerRemoteAccess(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	cfg := config.SnappVendor()
+
+	hid, err := topics.NewHashIDManager(cfg.HashIDMap)
+	require.NoError(err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		var request remoteaccess.Request
+
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil || request.Entity != "driver" {
+			res.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		_, _ = res.Write([]byte(`{"access": "pubsub"}`))
+	}))
+	defer server.Close()
+
+	// nolint: exhaustruct
+	topicList := []topics.Topic{
+		{
+			Type:     topics.Chat,
+			Template: "^{{.company}}/chat/{{.sub}}$",
+			Accesses: map[string]acl.AccessType{
+				topics.DriverIss:    acl.Sub,
+				topics.PassengerIss: acl.Sub,
+			},
+			AccessesSource: topics.AccessesSourceRemote,
+			RemoteAccesses: &remoteaccess.Config{URL: server.URL, Fallback: remoteaccess.FallbackStatic},
+		},
+		{
+			Type:     topics.CabEvent,
+			Template: "^{{.company}}/event/{{.sub}}$",
+			Accesses: map[string]acl.AccessType{
+				topics.DriverIss: acl.Sub,
+			},
+		},
+	}
+
+	topicManager := topics.NewTopicManager(
+		topicList, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop(),
+	).WithRemoteAccesses(topicList, noop.NewTracerProvider().Tracer(""))
+
+	sub := "DXKgaNQa7N5Y7bo"
+	ctx := context.Background()
+
+	chat, err := topicManager.ParseTopic(context.Background(), "snapp/chat/"+sub, topics.DriverIss, sub, nil)
+	require.NoError(err)
+	require.NotNil(chat)
+	require.Equal(acl.PubSub, topicManager.Access(ctx, chat, topics.DriverIss, "", nil))
+
+	// failures of remote service use the static accesses.
+	require.Equal(acl.Sub, topicManager.Access(ctx, chat, topics.PassengerIss, "", nil))
+
+	// topics with static source are mixed with the remote ones.
+	event, err := topicManager.ParseTopic(context.Background(), "snapp/event/"+sub, topics.DriverIss, sub, nil)
+	require.NoError(err)
+	require.NotNil(event)
+	require.Equal(acl.Sub, topicManager.Access(ctx, event, topics.DriverIss, "", nil))
+
+	// allowed topics use the remote accesses like acl.
+	permissions := topicManager.AllowedTopics(ctx, topics.DriverIss, sub, "", nil)
+	require.Len(permissions, 2)
+	require.Equal(topics.Chat, permissions[0].Type)
+	require.Equal([]string{"publish", "subscribe"}, permissions[0].Accesses)
+}
+
+// nolint: funlen
+func TestTopicManagerSharedType(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	cfg := config.SnappVendor()
+
+	hid, err := topics.NewHashIDManager(cfg.HashIDMap)
+	require.NoError(err)
+
+	core, logs := observer.New(zapcore.WarnLevel)
+
+	// nolint: exhaustruct
+	topicManager := topics.NewTopicManager([]topics.Topic{
+		{
+			Type:     topics.Chat,
+			Template: "^{{.company}}/chat/{{.sub}}$",
+			Accesses: map[string]acl.AccessType{
+				topics.DriverIss: acl.Sub,
+			},
+			Deprecated: true,
+		},
+		{
+			Type:     topics.Chat,
+			Template: "^{{.company}}/v2/chat/[a-zA-Z0-9]+/{{.sub}}$",
+			Accesses: map[string]acl.AccessType{
+				topics.DriverIss: acl.PubSub,
+			},
+		},
+	}, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.New(core))
+
+	sub := "DXKgaNQa7N5Y7bo"
+
+	old, err := topicManager.ParseTopic(context.Background(), "snapp/chat/"+sub, topics.DriverIss, sub, nil)
+	require.NoError(err)
+	require.NotNil(old)
+	require.Equal(topics.Chat, old.Type)
+	require.True(old.Deprecated)
+	require.False(old.HasAccess(topics.DriverIss, "", acl.Pub))
+
+	current, err := topicManager.ParseTopic(context.Background(), "snapp/v2/chat/1234/"+sub, topics.DriverIss, sub, nil)
+	require.NoError(err)
+	require.NotNil(current)
+	require.Equal(topics.Chat, current.Type)
+	require.False(current.Deprecated)
+	require.True(current.HasAccess(topics.DriverIss, "", acl.Pub))
+
+	missing, err := topicManager.ParseTopic(context.Background(), "snapp/v2/chat/"+sub, topics.DriverIss, sub, nil)
+	require.NoError(err)
+	require.Nil(missing)
+
+	// deprecated warnings are sampled.
+	old, err = topicManager.ParseTopic(context.Background(), "snapp/chat/"+sub, topics.DriverIss, sub, nil)
+	require.NoError(err)
+	require.NotNil(old)
+	require.Equal(1, logs.FilterMessage("topic matched a deprecated template").Len())
+}
+
+func TestTopicManagerMissingField(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	cfg := config.SnappVendor()
+
+	hid, err := topics.NewHashIDManager(cfg.HashIDMap)
+	require.NoError(err)
+
+	topicManager := topics.NewTopicManager([]topics.Topic{
+		{
+			Type:     topics.SharedLocation,
+			Template: "^{{.company}}/{{IssToEntity .iss}}/{{.sub}}/{{.uid}}/location$",
+			Accesses: map[string]acl.AccessType{topics.DriverIss: acl.Sub},
+		},
+		{
+			Type:     topics.Chat,
+			Template: "^{{.company}}/chat/{{.sub}}$",
+			Accesses: map[string]acl.AccessType{topics.DriverIss: acl.Sub},
+		},
+	}, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop())
+
+	sub := "DXKgaNQa7N5Y7bo"
+
+	_, err = topicManager.ParseTopic(context.Background(), "snapp/driver/"+sub+"/456/location", topics.DriverIss, sub, nil)
+
+	var renderErr serrors.TemplateRenderError
+
+	require.ErrorAs(err, &renderErr)
+	require.Equal(topics.SharedLocation, renderErr.TopicType)
+	require.Equal("uid", renderErr.Field)
+
+	shared, err := topicManager.ParseTopic(
+		context.Background(), "snapp/driver/"+sub+"/456/location", topics.DriverIss, sub, map[string]any{"uid": "456"},
+	)
+	require.NoError(err)
+	require.NotNil(shared)
+	require.Equal(topics.SharedLocation, shared.Type)
+
+	// templates after the one which cannot be rendered are still matched.
+	chat, err := topicManager.ParseTopic(context.Background(), "snapp/chat/"+sub, topics.DriverIss, sub, nil)
+	require.NoError(err)
+	require.NotNil(chat)
+	require.Equal(topics.Chat, chat.Type)
+}
+
+func TestTopicManagerHashTypeNone(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	cfg := config.SnappVendor()
+
+	hid, err := topics.NewHashIDManager(map[string]topics.HashData{
+		topics.DriverIss: {Length: 0, Salt: "", Alphabet: "", Type: topics.HashTypeNone},
+	})
+	require.NoError(err)
+
+	topicManager := topics.NewTopicManager(nil, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop())
+
+	// subjects of issuers without hashing are used verbatim.
+	id, err := topicManager.DecodeSubject(context.Background(), "driver-42", topics.DriverIss)
+	require.NoError(err)
+	require.Equal("driver-42", id)
+	require.Equal("driver-42", topicManager.EncodeHashID("driver-42", topics.DriverIss))
+
+	_, err = topicManager.DecodeSubject(context.Background(), "driver-42", topics.PassengerIss)
+	require.ErrorIs(err, topics.ErrUnknownHashIDIssuer)
+	require.Empty(topicManager.EncodeHashID("42", topics.PassengerIss))
+
+	_, err = topics.NewHashIDManager(map[string]topics.HashData{
+		topics.DriverIss: {Length: 0, Salt: "", Alphabet: "", Type: "sha1"},
+	})
+	require.ErrorIs(err, topics.ErrUnknownHashType)
+}
+
+func TestTopicManagerDecodedSegment(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.SnappVendor()
+
+	hid, err := topics.NewHashIDManager(map[string]topics.HashData{
+		topics.DriverIss: {Length: 0, Salt: "", Alphabet: "", Type: topics.HashTypeNone},
+	})
+	require.NoError(t, err)
+
+	topicManager := topics.NewTopicManager([]topics.Topic{
+		{
+			Type:     topics.DriverLocation,
+			Template: "^{{.company}}/driver/{{.segment2}}/{{EncodeMD5 (DecodeHashID .segment2 .iss)}}$",
+			Accesses: map[string]acl.AccessType{
+				topics.DriverIss: acl.Sub,
+			},
+			MaxPayloadBytes: 0,
+		},
+	}, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop())
+
+	hash := topicManager.EncodeMD5("a+b")
+
+	tests := []struct {
+		name  string
+		topic string
+		valid bool
+	}{
+		// functions have the segments verbatim, so the subject of segment decodes as it is.
+		{name: "decoded", topic: "snapp/driver/a+b/" + hash, valid: true},
+		{name: "quoted segment", topic: "snapp/driver/a+b/" + topicManager.EncodeMD5(`a\+b`), valid: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			topicTemplate, err := topicManager.ParseTopic(context.Background(), tc.topic, topics.DriverIss, "a+b", nil)
+			require.NoError(t, err)
+
+			if tc.valid {
+				require.NotNil(t, topicTemplate)
+			} else {
+				require.Nil(t, topicTemplate)
+			}
+		})
+	}
+}
+
+func TestDecodedSubjects(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	cfg := config.SnappVendor()
+
+	hid, err := topics.NewHashIDManager(map[string]topics.HashData{
+		topics.DriverIss: {Length: 15, Salt: "secret", Alphabet: "", Type: ""},
+	})
+	require.NoError(err)
+
+	// nolint: exhaustruct
+	topicManager := topics.NewTopicManager([]topics.Topic{{
+		Type:     topics.Chat,
+		Template: "^{{.company}}/{{ DecodeHashID .sub .iss }}/{{ if .sub }}{{ EncodeMD5 (DecodeHashID .sub .iss) }}{{ end }}$",
+		Accesses: map[string]acl.AccessType{topics.DriverIss: acl.Sub},
+	}}, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop())
+
+	sub := topicManager.EncodeHashID("42", topics.DriverIss)
+	topic := "snapp/42/" + topicManager.EncodeMD5("42")
+
+	// the decoded subject of request is reused by the DecodeHashID calls of templates.
+	ctx := topics.WithDecodedSubjects(context.Background())
+
+	id, err := topicManager.DecodeSubject(ctx, sub, topics.DriverIss)
+	require.NoError(err)
+	require.Equal("42", id)
+
+	chat, err := topicManager.ParseTopic(ctx, topic, topics.DriverIss, sub, nil)
+	require.NoError(err)
+	require.NotNil(chat)
+
+	// claims cannot set the decoded subject of request.
+	forged := map[string]any{"\x00decoded": "7"}
+
+	chat, err = topicManager.ParseTopic(context.Background(), "snapp/7/"+topicManager.EncodeMD5("7"),
+		topics.DriverIss, sub, forged)
+	require.NoError(err)
+	require.Nil(chat)
+
+	chat, err = topicManager.ParseTopic(context.Background(), topic, topics.DriverIss, sub, forged)
+	require.NoError(err)
+	require.NotNil(chat)
+}
+
+func TestSegments(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	require.Equal(map[string]string{
+		"segment0": "snapp",
+		"segment1": "driver",
+		"segment2": "",
+		"segment3": "a+b",
+	}, topics.Segments("snapp/driver//a+b"))
+
+	require.Len(topics.Segments(strings.Repeat("a/", 100)), topics.MaxSegments)
+	require.Equal(map[string]string{"segment0": ""}, topics.Segments(""))
+}
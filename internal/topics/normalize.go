@@ -0,0 +1,53 @@
+package topics
+
+import "strings"
+
+// SharePrefix is the prefix of MQTT shared subscriptions, $share/<group>/<topic>.
+const SharePrefix = "$share/"
+
+// Normalize collapses the duplicate separators and strips a single trailing separator of topic,
+// e.g. snapp//driver/1/location/ becomes snapp/driver/1/location. The group of shared subscriptions
+// is kept as it is and only their topic is normalized. It returns false when topic is not changed.
+func Normalize(topic string) (string, bool) {
+	prefix, rest := "", topic
+
+	if strings.HasPrefix(topic, SharePrefix) {
+		group, filter, ok := strings.Cut(topic[len(SharePrefix):], Separator)
+		if !ok {
+			return topic, false
+		}
+
+		prefix, rest = SharePrefix+group+Separator, filter
+	}
+
+	normalized := rest
+
+	for strings.Contains(normalized, Separator+Separator) {
+		normalized = strings.ReplaceAll(normalized, Separator+Separator, Separator)
+	}
+
+	if len(normalized) > len(Separator) {
+		normalized = strings.TrimSuffix(normalized, Separator)
+	}
+
+	if normalized == rest {
+		return topic, false
+	}
+
+	return prefix + normalized, true
+}
+
+// Normalize normalizes topic when the normalize_topics flag of vendor is enabled and counts the changed topics.
+// Normalized topics are not changed again, so topics can be normalized before each use.
+func (t *Manager) Normalize(topic string) string {
+	if t == nil || !t.Flags.NormalizeTopics(t.Company) {
+		return topic
+	}
+
+	normalized, changed := Normalize(topic)
+	if changed && t.Metrics != nil {
+		t.Metrics.Normalized(t.Company)
+	}
+
+	return normalized
+}
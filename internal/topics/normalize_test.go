@@ -0,0 +1,94 @@
+package topics_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/flags"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNormalize(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		topic      string
+		normalized string
+	}{
+		{name: "valid", topic: "snapp/driver/1/location", normalized: "snapp/driver/1/location"},
+		{name: "duplicate slashes", topic: "snapp//driver///1/location", normalized: "snapp/driver/1/location"},
+		{name: "trailing slash", topic: "snapp/driver/1/location/", normalized: "snapp/driver/1/location"},
+		{name: "both", topic: "snapp//driver/1/location//", normalized: "snapp/driver/1/location"},
+		{name: "leading slashes", topic: "//snapp/driver", normalized: "/snapp/driver"},
+		{name: "root", topic: "/", normalized: "/"},
+		{name: "empty", topic: "", normalized: ""},
+		{name: "share", topic: "$share/group/snapp//driver/", normalized: "$share/group/snapp/driver"},
+		{name: "share without topic", topic: "$share/group", normalized: "$share/group"},
+		{name: "empty share group", topic: "$share//snapp//driver", normalized: "$share//snapp/driver"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			normalized, changed := topics.Normalize(tc.topic)
+			require.Equal(t, tc.normalized, normalized)
+			require.Equal(t, tc.normalized != tc.topic, changed)
+
+			// normalized topics are not changed again.
+			again, changed := topics.Normalize(normalized)
+			require.Equal(t, normalized, again)
+			require.False(t, changed)
+		})
+	}
+}
+
+func TestTopicManagerNormalization(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	cfg := config.SnappVendor()
+
+	hid, err := topics.NewHashIDManager(cfg.HashIDMap)
+	require.NoError(err)
+
+	// nolint: exhaustruct
+	topicList := []topics.Topic{{
+		Type:     topics.DriverLocation,
+		Template: "^{{.company}}/driver/{{.sub}}/location$",
+		Accesses: map[string]acl.AccessType{topics.DriverIss: acl.Pub},
+	}}
+
+	sub := "DXKgaNQa7N5Y7bo"
+	topic := "snapp//driver/" + sub + "/location/"
+
+	strict := topics.NewTopicManager(topicList, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop())
+
+	template, err := strict.ParseTopic(context.Background(), topic, topics.DriverIss, sub, nil)
+	require.NoError(err)
+	require.Nil(template)
+	require.Equal(topic, strict.Normalize(topic))
+
+	features := flags.New(zap.NewNop())
+	features.Load(map[string]bool{}, map[string]map[string]bool{"snapp": {flags.NormalizeTopics: true}})
+
+	normalizing := topics.NewTopicManager(topicList, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop()).
+		WithFlags(features)
+
+	template, err = normalizing.ParseTopic(context.Background(), topic, topics.DriverIss, sub, nil)
+	require.NoError(err)
+	require.NotNil(template)
+	require.Equal(topics.DriverLocation, template.Type)
+	require.Equal("snapp/driver/"+sub+"/location", normalizing.Normalize(topic))
+
+	// the flag is read on each match, so reloads of flags take effect without rebuilding the manager.
+	features.Load(map[string]bool{}, map[string]map[string]bool{})
+
+	require.Equal(topic, normalizing.Normalize(topic))
+}
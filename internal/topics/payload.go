@@ -0,0 +1,184 @@
+package topics
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	serrors "github.com/snapp-incubator/soteria/internal/errors"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	jwtstrconv "github.com/snapp-incubator/soteria/pkg/strconv"
+)
+
+const (
+	// MaxPayloadCheckBytes is the maximum size of decoded payloads which are checked, bigger payloads are denied.
+	MaxPayloadCheckBytes = 16 * 1024
+	// MaxPayloadCheckDepth is the maximum nesting of objects and arrays of checked payloads.
+	MaxPayloadCheckDepth = 8
+
+	// PayloadPathSeparator separates the keys of nested fields in payload check paths.
+	PayloadPathSeparator = "."
+)
+
+var ErrInvalidPayloadCheck = errors.New("payload check is invalid")
+
+// PayloadCheck binds a field of the JSON payload of publishes to a claim of token or a level of topic,
+// e.g. the sender of chat messages to the sub claim of their publisher.
+type PayloadCheck struct {
+	// Path is the dot separated path of field in payload, e.g. sender.id.
+	Path string `json:"path,omitempty" koanf:"path"`
+	// Claim is the claim which field should be equal to.
+	Claim string `json:"claim,omitempty" koanf:"claim"`
+	// Segment is the position of level in topic which field should be equal to, it is used when claim is empty.
+	Segment int `json:"segment,omitempty" koanf:"segment"`
+}
+
+// Validate checks the path of payload check is within the checked depth and its segment is in topic.
+func (c PayloadCheck) Validate() error {
+	if c.Path == "" || strings.Count(c.Path, PayloadPathSeparator) >= MaxPayloadCheckDepth {
+		return fmt.Errorf("%w: path %q should have 1 to %d keys", ErrInvalidPayloadCheck, c.Path, MaxPayloadCheckDepth)
+	}
+
+	if c.Claim == "" && (c.Segment < 0 || c.Segment >= MaxSegments) {
+		return fmt.Errorf("%w: %s has segment %d", ErrInvalidPayloadCheck, c.Path, c.Segment)
+	}
+
+	return nil
+}
+
+// CheckPayload compares the fields of JSON payload of publish with their claims or topic levels.
+// Payloads are base64 encoded by broker. Publishes without payload are denied as malformed, because
+// their fields cannot be checked, e.g. when broker is not configured to forward the payload of topic.
+func (t Template) CheckPayload(
+	topic, iss, sub string,
+	claims map[string]any,
+	accessType acl.AccessType,
+	payload string,
+) error {
+	if len(t.PayloadChecks) == 0 || accessType != acl.Pub {
+		return nil
+	}
+
+	err := serrors.PayloadMismatchError{
+		TopicType: t.Type,
+		Issuer:    iss,
+		Sub:       sub,
+		Path:      "",
+		Malformed: true,
+	}
+
+	fields, ok := decodePayload(payload)
+	if !ok {
+		return err
+	}
+
+	levels := strings.Split(topic, Separator)
+
+	err.Malformed = false
+
+	for _, check := range t.PayloadChecks {
+		var expected string
+
+		if check.Claim != "" {
+			expected = jwtstrconv.ToString(claims[check.Claim])
+		} else if check.Segment < len(levels) {
+			expected = levels[check.Segment]
+		}
+
+		if value, ok := payloadField(fields, check.Path); !ok || expected == "" || value != expected {
+			err.Path = check.Path
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodePayload decodes the JSON object of base64 payload. Size and depth of payload are checked before
+// it is decoded, so large or deeply nested payloads don't cost more than their scan.
+func decodePayload(payload string) (map[string]any, bool) {
+	if len(payload) > base64.StdEncoding.EncodedLen(MaxPayloadCheckBytes) {
+		return nil, false
+	}
+
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil || jsonDepth(data) > MaxPayloadCheckDepth {
+		return nil, false
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// numbers are kept as they are, so large ids are compared without losing their precision.
+	decoder.UseNumber()
+
+	var fields map[string]any
+
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, false
+	}
+
+	return fields, true
+}
+
+// jsonDepth returns the maximum nesting of objects and arrays of JSON, it stops after the maximum depth
+// of payload checks is exceeded.
+func jsonDepth(data []byte) int {
+	var (
+		depth, maxDepth  int
+		inString, escape bool
+	)
+
+	for _, b := range data {
+		switch {
+		case escape:
+			escape = false
+		case inString && b == '\\':
+			escape = true
+		case b == '"':
+			inString = !inString
+		case inString:
+		case b == '{' || b == '[':
+			depth++
+
+			if depth > maxDepth {
+				maxDepth = depth
+			}
+
+			if maxDepth > MaxPayloadCheckDepth {
+				return maxDepth
+			}
+		case b == '}' || b == ']':
+			depth--
+		}
+	}
+
+	return maxDepth
+}
+
+// payloadField returns the string or number field of payload at the path.
+func payloadField(fields map[string]any, path string) (string, bool) {
+	var value any = fields
+
+	for _, key := range strings.Split(path, PayloadPathSeparator) {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return "", false
+		}
+
+		if value, ok = object[key]; !ok {
+			return "", false
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	}
+
+	return "", false
+}
@@ -0,0 +1,92 @@
+package topics_test
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	serrors "github.com/snapp-incubator/soteria/internal/errors"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayloadCheckValidate(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, topics.PayloadCheck{Path: "sender.id", Claim: "sub", Segment: 0}.Validate())
+	require.NoError(t, topics.PayloadCheck{Path: "ride_id", Claim: "", Segment: 3}.Validate())
+
+	require.ErrorIs(t, topics.PayloadCheck{Path: "", Claim: "sub", Segment: 0}.Validate(), topics.ErrInvalidPayloadCheck)
+	require.ErrorIs(t, topics.PayloadCheck{
+		Path:    strings.Repeat("a.", topics.MaxPayloadCheckDepth) + "a",
+		Claim:   "sub",
+		Segment: 0,
+	}.Validate(), topics.ErrInvalidPayloadCheck)
+	require.ErrorIs(t, topics.PayloadCheck{
+		Path:    "ride_id",
+		Claim:   "",
+		Segment: topics.MaxSegments,
+	}.Validate(), topics.ErrInvalidPayloadCheck)
+}
+
+// nolint: funlen
+func TestTopicCheckPayload(t *testing.T) {
+	t.Parallel()
+
+	// nolint: exhaustruct
+	temp := topics.Template{
+		Type: topics.Chat,
+		PayloadChecks: []topics.PayloadCheck{
+			{Path: "sender.id", Claim: "sub"},
+			{Path: "ride_id", Segment: 3},
+		},
+	}
+
+	topic := "snapp/driver/sub/1234/chat"
+	claims := map[string]any{"sub": "sub"}
+
+	encode := func(payload string) string {
+		return base64.StdEncoding.EncodeToString([]byte(payload))
+	}
+
+	require.NoError(t, temp.CheckPayload(
+		topic, topics.DriverIss, "sub", claims, acl.Pub, encode(`{"sender": {"id": "sub"}, "ride_id": 1234, "text": "hi"}`),
+	))
+
+	// subscriptions and publishes without payload are not checked.
+	require.NoError(t, temp.CheckPayload(topic, topics.DriverIss, "sub", claims, acl.Sub, encode(`{}`)))
+
+	var pmErr serrors.PayloadMismatchError
+
+	err := temp.CheckPayload(
+		topic, topics.DriverIss, "sub", claims, acl.Pub, encode(`{"sender": {"id": "other"}, "ride_id": "1234"}`),
+	)
+	require.ErrorAs(t, err, &pmErr)
+	require.Equal(t, "sender.id", pmErr.Path)
+	require.False(t, pmErr.Malformed)
+	require.Equal(t, serrors.ReasonPayloadMismatch, pmErr.Reason())
+
+	err = temp.CheckPayload(topic, topics.DriverIss, "sub", claims, acl.Pub, encode(`{"sender": {"id": "sub"}}`))
+	require.ErrorAs(t, err, &pmErr)
+	require.Equal(t, "ride_id", pmErr.Path)
+
+	for name, payload := range map[string]string{
+		// brokers which don't forward the payload of topic send publishes without payload.
+		"empty":          "",
+		"invalid base64": "not base64!",
+		"invalid json":   encode(`{"sender": `),
+		"not an object":  encode(`["sub"]`),
+		"too deep":       encode(strings.Repeat(`{"a":`, topics.MaxPayloadCheckDepth+1) + `1` + strings.Repeat(`}`, topics.MaxPayloadCheckDepth+1)),
+		"too large":      encode(`{"text": "` + strings.Repeat("a", topics.MaxPayloadCheckBytes) + `"}`),
+	} {
+		err := temp.CheckPayload(topic, topics.DriverIss, "sub", claims, acl.Pub, payload)
+		require.ErrorAs(t, err, &pmErr, name)
+		require.True(t, pmErr.Malformed, name)
+	}
+
+	// brackets in strings are not nesting.
+	require.NoError(t, temp.CheckPayload(topic, topics.DriverIss, "sub", claims, acl.Pub, encode(
+		`{"sender": {"id": "sub"}, "ride_id": "1234", "text": "`+strings.Repeat(`{[\"`, topics.MaxPayloadCheckDepth+1)+`"}`,
+	)))
+}
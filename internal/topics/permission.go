@@ -0,0 +1,133 @@
+package topics
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+
+	regexp "github.com/wasilibs/go-re2"
+)
+
+// UnresolvedPrefix marks the fields which cannot be resolved without a topic, like segments.
+const UnresolvedPrefix = "<"
+
+// Permission is an allowed topic of a client.
+type Permission struct {
+	Type string `json:"type"`
+	// Topic is the concrete topic when all fields of template are resolved.
+	Topic string `json:"topic,omitempty"`
+	// Pattern is the topic regular expression when topic is not concrete,
+	// unresolved fields are marked like <segment2>. Static topics have their MQTT pattern.
+	Pattern  string   `json:"pattern,omitempty"`
+	Accesses []string `json:"accesses"`
+}
+
+// AllowedTopics returns the topics which the client has access to. They are rendered using
+// the same fields as ACL but without a topic, so positional fields remain unresolved.
+// Templates with remote accesses ask the service about their access, like ACL.
+func (t *Manager) AllowedTopics(ctx context.Context, iss, sub, qualifier string, claims map[string]any) []Permission {
+	segments := make(map[string]string, MaxSegments)
+
+	for i := range MaxSegments {
+		segments[SegmentPrefix+strconv.Itoa(i)] = UnresolvedPrefix + SegmentPrefix + strconv.Itoa(i) + ">"
+	}
+
+	fields := t.fields(iss, sub, claims, segments)
+	rule := t.rule(iss, claims)
+
+	permissions := make([]Permission, 0)
+
+	// static topics are listed first, because they are matched before templates.
+	for _, static := range t.statics {
+		accesses := t.grants(ctx, &static.template, iss, qualifier, claims)
+		if len(accesses) == 0 {
+			continue
+		}
+
+		permission := Permission{
+			Type:     StaticTopicType,
+			Topic:    static.topic.Pattern,
+			Pattern:  "",
+			Accesses: accesses,
+		}
+
+		if !static.topic.Concrete() {
+			permission.Topic = ""
+			permission.Pattern = static.topic.Pattern
+		}
+
+		permissions = append(permissions, permission)
+	}
+
+	for _, topicTemplate := range t.TopicTemplates {
+		accesses := t.grants(ctx, &topicTemplate, iss, qualifier, claims)
+		if len(accesses) == 0 {
+			continue
+		}
+
+		regex, err := t.render(topicTemplate, fields, rule)
+		if err != nil {
+			continue
+		}
+
+		permission := Permission{
+			Type:     topicTemplate.Type,
+			Topic:    "",
+			Pattern:  regex,
+			Accesses: accesses,
+		}
+
+		if topic, ok := concrete(regex); ok {
+			permission.Topic = topic
+			permission.Pattern = ""
+		}
+
+		permissions = append(permissions, permission)
+	}
+
+	return permissions
+}
+
+// grants returns the names of accesses which the client has on the template and its allowed access types
+// allow, templates without their own allowed access types use the allowed access types of vendor like ACL.
+func (t *Manager) grants(
+	ctx context.Context,
+	topicTemplate *Template,
+	iss, qualifier string,
+	claims map[string]any,
+) []string {
+	granted := t.Access(ctx, topicTemplate, iss, qualifier, claims).Grants()
+
+	allowed := topicTemplate.AllowedAccessTypes
+	if allowed == nil {
+		allowed = t.AllowedAccessTypes
+	}
+
+	accesses := make([]string, 0, len(granted))
+
+	for _, grant := range granted {
+		if allowed != nil && !slices.Contains(allowed, grant) {
+			continue
+		}
+
+		accesses = append(accesses, grant.String())
+	}
+
+	return accesses
+}
+
+// concrete returns the topic of an anchored regular expression which has no meta characters.
+func concrete(regex string) (string, bool) {
+	if !strings.HasPrefix(regex, "^") || !strings.HasSuffix(regex, "$") || len(regex) < 2 { //nolint: mnd
+		return "", false
+	}
+
+	topic := regex[1 : len(regex)-1]
+
+	if regexp.QuoteMeta(topic) != topic || strings.Contains(topic, UnresolvedPrefix) {
+		return "", false
+	}
+
+	return topic, true
+}
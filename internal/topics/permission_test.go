@@ -0,0 +1,78 @@
+package topics_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestAllowedTopics(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	cfg := config.SnappVendor()
+
+	cfg.Topics = append(cfg.Topics, topics.Topic{
+		Type:     "segment_call",
+		Template: "^{{.company}}/{{IssToEntity .iss}}/{{.sub}}/segment/{{.segment4}}/send$",
+		Accesses: map[string]acl.AccessType{
+			topics.DriverIss: acl.PubSub,
+		},
+		MaxPayloadBytes:      0,
+		PostAuthorizeWebhook: nil,
+		StateCheck:           nil,
+		Deprecated:           false,
+	})
+
+	hid, err := topics.NewHashIDManager(cfg.HashIDMap)
+	require.NoError(err)
+
+	manager := topics.NewTopicManager(cfg.Topics, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop())
+
+	permissions := make(map[string]topics.Permission)
+
+	for _, permission := range manager.AllowedTopics(context.Background(), topics.DriverIss, "DXKgaNQa7N5Y7bo", "", nil) {
+		permissions[permission.Type] = permission
+	}
+
+	require.NotContains(permissions, topics.BoxEvent)
+
+	require.Equal(topics.Permission{
+		Type:     topics.DriverLocation,
+		Topic:    "snapp/driver/DXKgaNQa7N5Y7bo/location",
+		Pattern:  "",
+		Accesses: []string{"publish"},
+	}, permissions[topics.DriverLocation])
+
+	require.Equal(topics.Permission{
+		Type:     topics.NodeCallEntry,
+		Topic:    "",
+		Pattern:  "^snapp/driver/DXKgaNQa7N5Y7bo/call/[a-zA-Z0-9-_]+/send$",
+		Accesses: []string{"publish"},
+	}, permissions[topics.NodeCallEntry])
+
+	require.Equal(topics.Permission{
+		Type:     "segment_call",
+		Topic:    "",
+		Pattern:  "^snapp/driver/DXKgaNQa7N5Y7bo/segment/<segment4>/send$",
+		Accesses: []string{"publish", "subscribe"},
+	}, permissions["segment_call"])
+
+	// every concrete topic is allowed by the ACL rendering too.
+	for _, permission := range permissions {
+		if permission.Topic == "" {
+			continue
+		}
+
+		template, err := manager.ParseTopic(context.Background(), permission.Topic, topics.DriverIss, "DXKgaNQa7N5Y7bo", nil)
+		require.NoError(err)
+		require.NotNil(template, permission.Topic)
+		require.Equal(permission.Type, template.Type)
+	}
+}
@@ -0,0 +1,71 @@
+package topics
+
+import (
+	"strings"
+	"text/template/parse"
+)
+
+// quoteFunction is the function which quotes the output of the segment actions of templates.
+const quoteFunction = "quoteSegment"
+
+// quoteSegments appends a quoteFunction call to the actions of template which use a segment, so the functions
+// of action (e.g. DecodeHashID) have the segments verbatim and only its output is quoted, because segments are
+// coming from user and templates are regular expressions.
+func quoteSegments(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+
+		for _, child := range n.Nodes {
+			quoteSegments(child)
+		}
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) == 0 && hasSegment(n.Pipe) {
+			quote := &parse.IdentifierNode{NodeType: parse.NodeIdentifier, Pos: n.Pos, Ident: quoteFunction}
+			n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+				NodeType: parse.NodeCommand,
+				Pos:      n.Pos,
+				Args:     []parse.Node{quote},
+			})
+		}
+	case *parse.IfNode:
+		quoteBranch(&n.BranchNode)
+	case *parse.RangeNode:
+		quoteBranch(&n.BranchNode)
+	case *parse.WithNode:
+		quoteBranch(&n.BranchNode)
+	}
+}
+
+func quoteBranch(n *parse.BranchNode) {
+	quoteSegments(n.List)
+	quoteSegments(n.ElseList)
+}
+
+// hasSegment reports whether any of the commands of pipe uses a segment field.
+func hasSegment(node parse.Node) bool {
+	switch n := node.(type) {
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+
+		for _, cmd := range n.Cmds {
+			if hasSegment(cmd) {
+				return true
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if hasSegment(arg) {
+				return true
+			}
+		}
+	case *parse.FieldNode:
+		return strings.HasPrefix(n.Ident[0], SegmentPrefix)
+	}
+
+	return false
+}
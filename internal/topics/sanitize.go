@@ -0,0 +1,51 @@
+package topics
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	serrors "github.com/snapp-incubator/soteria/internal/errors"
+)
+
+// DefaultMaxTopicLength is the default maximum length of topics in bytes.
+const DefaultMaxTopicLength = 1024
+
+// Reasons of malformed topics which are used in errors and metrics.
+const (
+	MalformedEmpty       = "empty"
+	MalformedTooLong     = "too_long"
+	MalformedInvalidUTF8 = "invalid_utf8"
+	MalformedControl     = "control_character"
+	MalformedBidi        = "bidi_character"
+)
+
+type MalformedTopicError = serrors.MalformedTopicError
+
+// Sanitize checks the topic before any regular expression or template work, so malformed topics are cheap
+// to drop. topics must be valid UTF-8 without control characters as MQTT specification says, and without
+// bidirectional formatting characters which only confuse the logs. zero max length disables the length check.
+func Sanitize(topic string, maxLength int) error {
+	if topic == "" {
+		return MalformedTopicError{Reason: MalformedEmpty, Length: 0}
+	}
+
+	if maxLength > 0 && len(topic) > maxLength {
+		return MalformedTopicError{Reason: MalformedTooLong, Length: len(topic)}
+	}
+
+	if !utf8.ValidString(topic) {
+		return MalformedTopicError{Reason: MalformedInvalidUTF8, Length: len(topic)}
+	}
+
+	for _, r := range topic {
+		if unicode.IsControl(r) {
+			return MalformedTopicError{Reason: MalformedControl, Length: len(topic)}
+		}
+
+		if unicode.Is(unicode.Bidi_Control, r) {
+			return MalformedTopicError{Reason: MalformedBidi, Length: len(topic)}
+		}
+	}
+
+	return nil
+}
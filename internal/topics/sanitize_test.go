@@ -0,0 +1,52 @@
+package topics_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitize(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		topic  string
+		reason string
+	}{
+		{name: "valid", topic: "snapp/driver/DXKgaNQa7N5Y7bo/location", reason: ""},
+		{name: "valid unicode", topic: "snapp/مسافر/chat", reason: ""},
+		{name: "empty", topic: "", reason: topics.MalformedEmpty},
+		{name: "too long", topic: strings.Repeat("a", topics.DefaultMaxTopicLength+1), reason: topics.MalformedTooLong},
+		{name: "invalid utf-8", topic: "snapp/\xff/chat", reason: topics.MalformedInvalidUTF8},
+		{name: "null byte", topic: "snapp/\x00/chat", reason: topics.MalformedControl},
+		{name: "c1 control", topic: "snapp/\u0085/chat", reason: topics.MalformedControl},
+		{name: "rtl override", topic: "snapp/\u202e/chat", reason: topics.MalformedBidi},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := topics.Sanitize(tc.topic, topics.DefaultMaxTopicLength)
+			if tc.reason == "" {
+				require.NoError(t, err)
+
+				return
+			}
+
+			var mErr topics.MalformedTopicError
+
+			require.ErrorAs(t, err, &mErr)
+			require.Equal(t, tc.reason, mErr.Reason)
+		})
+	}
+}
+
+func TestSanitizeWithoutMaxLength(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, topics.Sanitize(strings.Repeat("a", 64*1024), 0))
+}
@@ -0,0 +1,132 @@
+package topics
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	serrors "github.com/snapp-incubator/soteria/internal/errors"
+	regexp "github.com/wasilibs/go-re2"
+)
+
+// noValue is rendered by templates for the values which they cannot find.
+const noValue = "<no value>"
+
+// selfCheckIDs are the known ids which are encoded and decoded back by the hash-id of each issuer.
+var selfCheckIDs = []int{1, 42, 1_000_000_007}
+
+var (
+	ErrHashIDRoundTrip = errors.New("hash-id doesn't decode back to its id")
+	ErrEmptyLevel      = errors.New("rendered topic has an empty level")
+	ErrNoValue         = errors.New("rendered topic has a missing value")
+)
+
+// SelfCheckError is a failure of the self-check of an issuer, topic type is empty for the failures
+// of its hash-id.
+type SelfCheckError struct {
+	Issuer    string
+	TopicType string
+	Err       error
+}
+
+func (err SelfCheckError) Error() string {
+	if err.TopicType == "" {
+		return fmt.Sprintf("issuer %s: %s", err.Issuer, err.Err)
+	}
+
+	return fmt.Sprintf("issuer %s template %s: %s", err.Issuer, err.TopicType, err.Err)
+}
+
+func (err SelfCheckError) Unwrap() error {
+	return err.Err
+}
+
+// SelfCheck encodes known ids by the hash-id of each issuer and decodes them back, then renders each
+// template for the issuers of its accesses with their sample subject and checks the result is a valid topic.
+// Templates which need claims or topic levels to be rendered are not checked.
+func (t *Manager) SelfCheck() []error {
+	errs := make([]error, 0)
+
+	for _, iss := range slices.Sorted(maps.Keys(t.HashIDSManager)) {
+		// subjects of issuers without hashing are used verbatim.
+		if t.HashIDSManager[iss] == nil {
+			continue
+		}
+
+		if err := t.roundTrip(iss); err != nil {
+			errs = append(errs, SelfCheckError{Issuer: iss, TopicType: "", Err: err})
+		}
+	}
+
+	for _, topicTemplate := range t.TopicTemplates {
+		if strings.Contains(topicTemplate.Template.Root.String(), "."+SegmentPrefix) {
+			continue
+		}
+
+		for _, iss := range issuers(topicTemplate) {
+			if iss == Default {
+				continue
+			}
+
+			if err := t.checkTemplate(topicTemplate, iss); err != nil {
+				errs = append(errs, SelfCheckError{Issuer: iss, TopicType: topicTemplate.Type, Err: err})
+			}
+		}
+	}
+
+	return errs
+}
+
+func (t *Manager) roundTrip(iss string) error {
+	for _, id := range selfCheckIDs {
+		encoded, err := t.HashIDSManager[iss].Encode([]int{id})
+		if err != nil {
+			return fmt.Errorf("cannot encode %d %w", id, err)
+		}
+
+		ids, err := t.HashIDSManager[iss].DecodeWithError(encoded)
+		if err != nil {
+			return fmt.Errorf("cannot decode %s %w", encoded, err)
+		}
+
+		if len(ids) != 1 || ids[0] != id {
+			return fmt.Errorf("%w: %d is encoded as %s", ErrHashIDRoundTrip, id, encoded)
+		}
+	}
+
+	return nil
+}
+
+func (t *Manager) checkTemplate(topicTemplate Template, iss string) error {
+	regex, err := topicTemplate.Parse(t.fields(iss, t.sampleSub(iss), nil, nil))
+	if err != nil {
+		var renderErr serrors.TemplateRenderError
+
+		// templates which use claims cannot be rendered without a token.
+		if errors.As(err, &renderErr) && renderErr.Field != "" {
+			return nil
+		}
+
+		return err
+	}
+
+	if strings.Contains(regex, noValue) {
+		return fmt.Errorf("%w: %s", ErrNoValue, strconv.Quote(regex))
+	}
+
+	if _, err := regexp.Compile(regex); err != nil {
+		return fmt.Errorf("cannot compile %s %w", strconv.Quote(regex), err)
+	}
+
+	topic := strings.TrimSuffix(strings.TrimPrefix(regex, "^"), "$")
+
+	if strings.Contains(topic, Separator+Separator) ||
+		strings.HasPrefix(topic, Separator) || strings.HasSuffix(topic, Separator) {
+		return fmt.Errorf("%w: %s", ErrEmptyLevel, strconv.Quote(regex))
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package topics_test
+
+import (
+	"testing"
+
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSelfCheck(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	cfg := config.SnappVendor()
+
+	hid, err := topics.NewHashIDManager(cfg.HashIDMap)
+	require.NoError(err)
+
+	manager := topics.NewTopicManager(cfg.Topics, hid, cfg.Company, cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop())
+	require.Empty(manager.SelfCheck())
+
+	topicList := []topics.Topic{
+		{ // nolint: exhaustruct
+			// issuer 2 has no entity, so its topics have an empty level.
+			Type:     topics.Chat,
+			Template: "^{{.company}}/{{IssToEntity .iss}}/{{.sub}}/chat$",
+			Accesses: map[string]acl.AccessType{topics.DriverIss: acl.Sub, "2": acl.Sub},
+		},
+		{ // nolint: exhaustruct
+			// templates which need claims or topic levels are not checked.
+			Type:     topics.SharedLocation,
+			Template: "^{{.company}}/{{.uid}}/location$",
+			Accesses: map[string]acl.AccessType{topics.DriverIss: acl.Sub},
+		},
+		{ // nolint: exhaustruct
+			Type:     topics.NodeCallEntry,
+			Template: "^{{.company}}/{{.segment1}}/call$",
+			Accesses: map[string]acl.AccessType{topics.DriverIss: acl.Pub},
+		},
+	}
+
+	manager = topics.NewTopicManager(topicList, hid, cfg.Company, cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop())
+
+	errs := manager.SelfCheck()
+	require.Len(errs, 1)
+	require.ErrorIs(errs[0], topics.ErrEmptyLevel)
+
+	var checkErr topics.SelfCheckError
+
+	require.ErrorAs(errs[0], &checkErr)
+	require.Equal("2", checkErr.Issuer)
+	require.Equal(topics.Chat, checkErr.TopicType)
+}
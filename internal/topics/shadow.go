@@ -0,0 +1,206 @@
+package topics
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"slices"
+	"strings"
+	"unicode"
+
+	regexp "github.com/wasilibs/go-re2"
+)
+
+// sampleID is the id which is encoded as the sub of sample topics.
+const sampleID = "1"
+
+// Shadow is a template which cannot match a sample of its own topics, because a static topic
+// or a template before it in the matching order matches the sample first.
+type Shadow struct {
+	Type       string
+	Index      int
+	ShadowedBy string
+	// ShadowedByIndex is the index of the matched template in the matching order,
+	// or the index of the matched static topic.
+	ShadowedByIndex int
+	// ShadowedByPattern is the pattern of the matched static topic, it is empty for templates.
+	ShadowedByPattern string
+	Topic             string
+	Issuer            string
+}
+
+func (s Shadow) String() string {
+	if s.ShadowedByPattern != "" {
+		return fmt.Sprintf("template %d (%s) is shadowed by static topic %d (%s) on topic %q of issuer %s",
+			s.Index, s.Type, s.ShadowedByIndex, s.ShadowedByPattern, s.Topic, s.Issuer,
+		)
+	}
+
+	return fmt.Sprintf("template %d (%s) is shadowed by template %d (%s) on topic %q of issuer %s",
+		s.Index, s.Type, s.ShadowedByIndex, s.ShadowedBy, s.Topic, s.Issuer,
+	)
+}
+
+// Shadows generates a sample topic from each template for the issuers of its accesses and reports
+// the templates which their sample is matched by a static topic or a template before them. templates
+// which cannot be rendered without claims are not analyzed.
+func (t *Manager) Shadows() []Shadow {
+	shadows := make([]Shadow, 0)
+
+	for j, topicTemplate := range t.TopicTemplates {
+		for _, iss := range issuers(topicTemplate) {
+			sample, ok := t.sample(topicTemplate, iss)
+			if !ok {
+				continue
+			}
+
+			// static topics are matched before all templates.
+			if k := t.firstStatic(sample); k >= 0 {
+				shadows = append(shadows, Shadow{
+					Type:              topicTemplate.Type,
+					Index:             j,
+					ShadowedBy:        StaticTopicType,
+					ShadowedByIndex:   k,
+					ShadowedByPattern: t.statics[k].topic.Pattern,
+					Topic:             sample,
+					Issuer:            iss,
+				})
+
+				break
+			}
+
+			i := t.firstMatch(sample, iss, j+1)
+			if i < 0 || i == j {
+				continue
+			}
+
+			shadows = append(shadows, Shadow{
+				Type:              topicTemplate.Type,
+				Index:             j,
+				ShadowedBy:        t.TopicTemplates[i].Type,
+				ShadowedByIndex:   i,
+				ShadowedByPattern: "",
+				Topic:             sample,
+				Issuer:            iss,
+			})
+
+			// each template is reported once.
+			break
+		}
+	}
+
+	return shadows
+}
+
+// issuers returns the sorted issuers of template accesses without their qualifiers.
+func issuers(topicTemplate Template) []string {
+	list := make([]string, 0, len(topicTemplate.Accesses))
+
+	for key := range topicTemplate.Accesses {
+		iss, _, _ := strings.Cut(key, QualifierSeparator)
+		if !slices.Contains(list, iss) {
+			list = append(list, iss)
+		}
+	}
+
+	slices.Sort(list)
+
+	return list
+}
+
+// sample renders the template for a sample client of issuer and generates a topic which matches it.
+func (t *Manager) sample(topicTemplate Template, iss string) (string, bool) {
+	regex, err := topicTemplate.Parse(t.fields(iss, t.sampleSub(iss), nil, nil))
+	if err != nil {
+		return "", false
+	}
+
+	re, err := syntax.Parse(regex, syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+
+	var sample strings.Builder
+
+	generate(&sample, re.Simplify())
+
+	return sample.String(), true
+}
+
+// sampleSub returns the sub of sample client, it is a hash id for issuers which have hash id.
+func (t *Manager) sampleSub(iss string) string {
+	if _, ok := t.HashIDSManager[iss]; ok {
+		return t.EncodeHashID(sampleID, iss)
+	}
+
+	return sampleID
+}
+
+// firstMatch returns the index of the first template in the first n templates which matches the topic
+// same as ParseTopic, it returns -1 when none of them matches.
+func (t *Manager) firstMatch(topic, iss string, n int) int {
+	fields := t.fields(iss, t.sampleSub(iss), nil, Segments(topic))
+
+	for i, topicTemplate := range t.TopicTemplates[:n] {
+		regex, err := topicTemplate.Parse(fields)
+		if err != nil {
+			continue
+		}
+
+		re, err := regexp.Compile(regex)
+		if err != nil {
+			continue
+		}
+
+		if re.MatchString(topic) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// generate writes the shortest string which matches the regular expression, alternations use
+// their first branch and character classes prefer letters and digits.
+// nolint: cyclop
+func generate(sample *strings.Builder, re *syntax.Regexp) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		sample.WriteString(string(re.Rune))
+	case syntax.OpCharClass:
+		sample.WriteRune(classRune(re.Rune))
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		sample.WriteRune('x')
+	case syntax.OpCapture, syntax.OpPlus:
+		generate(sample, re.Sub[0])
+	case syntax.OpRepeat:
+		for range re.Min {
+			generate(sample, re.Sub[0])
+		}
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			generate(sample, sub)
+		}
+	case syntax.OpAlternate:
+		generate(sample, re.Sub[0])
+	case syntax.OpNoMatch, syntax.OpEmptyMatch, syntax.OpStar, syntax.OpQuest,
+		syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText,
+		syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+	}
+}
+
+// classRune returns a rune of the character class ranges, ASCII letters and digits are preferred.
+func classRune(ranges []rune) rune {
+	if len(ranges) == 0 {
+		return 'x'
+	}
+
+	for i := 0; i+1 < len(ranges); i += 2 {
+		for r := ranges[i]; r <= min(ranges[i+1], unicode.MaxASCII); r++ {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return r
+			}
+		}
+	}
+
+	return ranges[0]
+}
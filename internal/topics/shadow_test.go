@@ -0,0 +1,112 @@
+package topics_test
+
+import (
+	"testing"
+
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// nolint: funlen
+func TestShadows(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.SnappVendor()
+
+	hid, err := topics.NewHashIDManager(cfg.HashIDMap)
+	require.NoError(t, err)
+
+	// nolint: exhaustruct
+	broad := topics.Topic{
+		Type:     "broad",
+		Template: "^{{.company}}/{{IssToEntity .iss}}/.+$",
+		Accesses: map[string]acl.AccessType{topics.DriverIss: acl.Sub},
+	}
+
+	// nolint: exhaustruct
+	chat := topics.Topic{
+		Type:     topics.Chat,
+		Template: "^{{.company}}/{{IssToEntity .iss}}/{{.sub}}/chat$",
+		Accesses: map[string]acl.AccessType{topics.DriverIss: acl.PubSub},
+	}
+
+	cases := []struct {
+		name    string
+		topics  func() []topics.Topic
+		shadows []string
+	}{
+		{
+			name: "default configuration",
+			topics: func() []topics.Topic {
+				return cfg.Topics
+			},
+			shadows: nil,
+		},
+		{
+			name: "broad template before specific one",
+			topics: func() []topics.Topic {
+				return []topics.Topic{broad, chat}
+			},
+			shadows: []string{topics.Chat},
+		},
+		{
+			name: "specific template before broad one",
+			topics: func() []topics.Topic {
+				return []topics.Topic{chat, broad}
+			},
+			shadows: nil,
+		},
+		{
+			name: "specific template with higher priority",
+			topics: func() []topics.Topic {
+				prioritized := chat
+				prioritized.Priority = 1
+
+				return []topics.Topic{broad, prioritized}
+			},
+			shadows: nil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			manager := topics.NewTopicManager(c.topics(), hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop())
+
+			var shadowed []string
+
+			for _, shadow := range manager.Shadows() {
+				require.Equal(t, "broad", shadow.ShadowedBy)
+				require.Equal(t, topics.DriverIss, shadow.Issuer)
+
+				shadowed = append(shadowed, shadow.Type)
+			}
+
+			require.Equal(t, c.shadows, shadowed)
+		})
+	}
+}
+
+func TestOrdered(t *testing.T) {
+	t.Parallel()
+
+	// nolint: exhaustruct
+	ordered := topics.Ordered([]topics.Topic{
+		{Type: "a"},
+		{Type: "b", Priority: 1},
+		{Type: "c"},
+		{Type: "d", Priority: -1},
+		{Type: "e", Priority: 1},
+	})
+
+	types := make([]string, 0, len(ordered))
+	for _, topic := range ordered {
+		types = append(types, topic.Type)
+	}
+
+	require.Equal(t, []string{"b", "e", "a", "c", "d"}, types)
+}
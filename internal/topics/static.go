@@ -0,0 +1,144 @@
+package topics
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/snapp-incubator/soteria/pkg/acl"
+)
+
+const (
+	// StaticTopicType is the topic type of static topics.
+	StaticTopicType = "static"
+	// SingleLevelWildcard matches exactly one level of topic.
+	SingleLevelWildcard = "+"
+	// MultiLevelWildcard matches any number of levels at the end of topic.
+	MultiLevelWildcard = "#"
+
+	// MatchStatic and MatchTemplate are the matches of topics, static topics are matched before templates.
+	MatchStatic   = "static"
+	MatchTemplate = "template"
+)
+
+var ErrInvalidStaticTopic = errors.New("invalid static topic")
+
+// StaticTopic is a topic which doesn't need the template fields, like the topics of operations.
+// Its pattern is an exact topic or an MQTT pattern and its accesses are keyed by issuer same as templates.
+type StaticTopic struct {
+	Pattern  string                    `json:"pattern,omitempty"  koanf:"pattern"`
+	Accesses map[string]acl.AccessType `json:"accesses,omitempty" koanf:"accesses"`
+}
+
+// Validate checks the pattern has wildcards only as whole levels and the multi-level wildcard only at its end.
+func (s StaticTopic) Validate() error {
+	if s.Pattern == "" {
+		return fmt.Errorf("%w: pattern is empty", ErrInvalidStaticTopic)
+	}
+
+	levels := strings.Split(s.Pattern, Separator)
+
+	for i, level := range levels {
+		if level == MultiLevelWildcard && i != len(levels)-1 {
+			return fmt.Errorf("%w: %s has %s before its last level", ErrInvalidStaticTopic, s.Pattern, MultiLevelWildcard)
+		}
+
+		if level != MultiLevelWildcard && level != SingleLevelWildcard &&
+			strings.ContainsAny(level, MultiLevelWildcard+SingleLevelWildcard) {
+			return fmt.Errorf("%w: %s has a wildcard inside a level", ErrInvalidStaticTopic, s.Pattern)
+		}
+	}
+
+	return nil
+}
+
+// Concrete reports whether the pattern is an exact topic.
+func (s StaticTopic) Concrete() bool {
+	return !strings.ContainsAny(s.Pattern, MultiLevelWildcard+SingleLevelWildcard)
+}
+
+// staticTemplate is the template of a static topic, it is never rendered.
+type staticTemplate struct {
+	topic    StaticTopic
+	template Template
+}
+
+// WithStaticTopics adds the static topics which are matched in their order before the templates.
+func (t *Manager) WithStaticTopics(staticTopics []StaticTopic) *Manager {
+	t.statics = make([]staticTemplate, 0, len(staticTopics))
+
+	for _, static := range staticTopics {
+		t.statics = append(t.statics, staticTemplate{
+			topic: static,
+			template: Template{
+				Type:                StaticTopicType,
+				Template:            nil,
+				Accesses:            static.Accesses,
+				MaxPayloadBytes:     0,
+				PostAuthorizer:      nil,
+				StateCheck:          nil,
+				Deprecated:          false,
+				AllowedAccessTypes:  nil,
+				SubscriptionLimiter: nil,
+				RideMembership:      nil,
+				AllowedValues:       nil,
+				PayloadChecks:       nil,
+				RemoteAccesses:      nil,
+			},
+		})
+	}
+
+	return t
+}
+
+// matchStatic returns the template of the first static topic which matches the topic.
+func (t *Manager) matchStatic(topic string) (*Template, bool) {
+	i := t.firstStatic(topic)
+	if i < 0 {
+		return nil, false
+	}
+
+	topicTemplate := t.statics[i].template
+
+	return &topicTemplate, true
+}
+
+// firstStatic returns the index of the first static topic which matches the topic, it returns -1
+// when none of them matches.
+func (t *Manager) firstStatic(topic string) int {
+	for i, static := range t.statics {
+		if MatchPattern(static.topic.Pattern, topic) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// MatchPattern checks the topic matches the pattern which can have MQTT wildcards.
+// wildcards in topic are matched only by the same or wider wildcards in pattern.
+func MatchPattern(pattern, topic string) bool {
+	patternLevels := strings.Split(pattern, Separator)
+	topicLevels := strings.Split(topic, Separator)
+
+	for i, level := range patternLevels {
+		if level == MultiLevelWildcard {
+			return i == len(patternLevels)-1
+		}
+
+		if i >= len(topicLevels) {
+			return false
+		}
+
+		switch {
+		case topicLevels[i] == MultiLevelWildcard:
+			return false
+		case level == SingleLevelWildcard:
+			continue
+		case level != topicLevels[i]:
+			return false
+		}
+	}
+
+	return len(patternLevels) == len(topicLevels)
+}
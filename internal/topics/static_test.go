@@ -0,0 +1,146 @@
+package topics_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestStaticTopics(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	cfg := config.SnappVendor()
+
+	hid, err := topics.NewHashIDManager(cfg.HashIDMap)
+	require.NoError(err)
+
+	manager := topics.NewTopicManager(cfg.Topics, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop()).
+		WithStaticTopics([]topics.StaticTopic{
+			{
+				Pattern: "snapp/ops/broadcast",
+				Accesses: map[string]acl.AccessType{
+					topics.DriverIss:    acl.Sub,
+					topics.PassengerIss: acl.Sub,
+				},
+			},
+			{
+				Pattern: "snapp/ops/health/#",
+				Accesses: map[string]acl.AccessType{
+					topics.DriverIss: acl.Pub,
+				},
+			},
+		})
+
+	sub := "DXKgaNQa7N5Y7bo"
+
+	broadcast, err := manager.ParseTopic(context.Background(), "snapp/ops/broadcast", topics.PassengerIss, sub, nil)
+	require.NoError(err)
+	require.NotNil(broadcast)
+	require.Equal(topics.StaticTopicType, broadcast.Type)
+	require.True(broadcast.HasAccess(topics.PassengerIss, "", acl.Sub))
+	require.False(broadcast.HasAccess(topics.PassengerIss, "", acl.Pub))
+
+	health, err := manager.ParseTopic(context.Background(), "snapp/ops/health/eu/1", topics.DriverIss, sub, nil)
+	require.NoError(err)
+	require.NotNil(health)
+	require.True(health.HasAccess(topics.DriverIss, "", acl.Pub))
+
+	// topics which are not static are matched by templates.
+	location, err := manager.ParseTopic(context.Background(), "snapp/driver/"+sub+"/location", topics.DriverIss, sub, nil)
+	require.NoError(err)
+	require.NotNil(location)
+	require.Equal(topics.DriverLocation, location.Type)
+
+	missing, err := manager.ParseTopic(context.Background(), "snapp/ops/broadcast/1", topics.DriverIss, sub, nil)
+	require.NoError(err)
+	require.Nil(missing)
+
+	permissions := manager.AllowedTopics(context.Background(), topics.DriverIss, sub, "", nil)
+	require.Equal([]topics.Permission{
+		{
+			Type:     topics.StaticTopicType,
+			Topic:    "snapp/ops/broadcast",
+			Pattern:  "",
+			Accesses: []string{"subscribe"},
+		},
+		{
+			Type:     topics.StaticTopicType,
+			Topic:    "",
+			Pattern:  "snapp/ops/health/#",
+			Accesses: []string{"publish"},
+		},
+	}, permissions[:2])
+
+	// permissions only have the access types which vendor allows, same as acl.
+	permissions = manager.WithAllowedAccessTypes([]acl.AccessType{acl.Sub}).
+		AllowedTopics(context.Background(), topics.DriverIss, sub, "", nil)
+	require.Equal(topics.Permission{
+		Type:     topics.StaticTopicType,
+		Topic:    "snapp/ops/broadcast",
+		Pattern:  "",
+		Accesses: []string{"subscribe"},
+	}, permissions[0])
+	require.NotEqual("snapp/ops/health/#", permissions[1].Pattern)
+}
+
+func TestStaticTopicShadows(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	cfg := config.SnappVendor()
+
+	hid, err := topics.NewHashIDManager(cfg.HashIDMap)
+	require.NoError(err)
+
+	// static topics are matched before templates, so a wide pattern shadows the templates of its levels.
+	manager := topics.NewTopicManager(cfg.Topics, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop()).
+		WithStaticTopics([]topics.StaticTopic{
+			{Pattern: "snapp/ops/broadcast", Accesses: map[string]acl.AccessType{topics.DriverIss: acl.Sub}},
+			{Pattern: "snapp/driver/+/location", Accesses: map[string]acl.AccessType{topics.DriverIss: acl.Sub}},
+		})
+
+	shadows := manager.Shadows()
+	require.Len(shadows, 1)
+	require.Equal(topics.DriverLocation, shadows[0].Type)
+	require.Equal(topics.StaticTopicType, shadows[0].ShadowedBy)
+	require.Equal(1, shadows[0].ShadowedByIndex)
+	require.Equal("snapp/driver/+/location", shadows[0].ShadowedByPattern)
+	require.Contains(shadows[0].String(), "is shadowed by static topic 1 (snapp/driver/+/location)")
+}
+
+func TestStaticTopicValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		pattern string
+		valid   bool
+	}{
+		{name: "exact", pattern: "snapp/ops/broadcast", valid: true},
+		{name: "wildcards", pattern: "snapp/+/health/#", valid: true},
+		{name: "empty", pattern: "", valid: false},
+		{name: "multi-level in the middle", pattern: "snapp/#/health", valid: false},
+		{name: "wildcard inside level", pattern: "snapp/ops+/health", valid: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := topics.StaticTopic{Pattern: tc.pattern, Accesses: nil}.Validate()
+			if tc.valid {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, topics.ErrInvalidStaticTopic)
+			}
+		})
+	}
+}
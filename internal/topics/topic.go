@@ -1,37 +1,251 @@
 package topics
 
 import (
+	"cmp"
+	"context"
+	"slices"
 	"strings"
 	"text/template"
 
+	serrors "github.com/snapp-incubator/soteria/internal/errors"
+	"github.com/snapp-incubator/soteria/internal/postauth"
+	"github.com/snapp-incubator/soteria/internal/remoteaccess"
+	"github.com/snapp-incubator/soteria/internal/statecheck"
+	"github.com/snapp-incubator/soteria/internal/sublimit"
 	"github.com/snapp-incubator/soteria/pkg/acl"
+	jwtstrconv "github.com/snapp-incubator/soteria/pkg/strconv"
 )
 
+// DefaultRideClaim is the claim which has the ride of client.
+const DefaultRideClaim = "ride_id"
+
+const (
+	// AccessesSourceStatic uses the accesses of topic configuration, it is the default source.
+	AccessesSourceStatic = "static"
+	// AccessesSourceRemote asks the remote accesses service about the access of entities.
+	AccessesSourceRemote = "remote"
+)
+
+// missingKeyError is the text of template execution errors which reference a missing field.
+const missingKeyError = `map has no entry for key "`
+
 type Topic struct {
 	Type     string                    `json:"type,omitempty"     koanf:"type"`
 	Template string                    `json:"template,omitempty" koanf:"template"`
 	Accesses map[string]acl.AccessType `json:"accesses,omitempty" koanf:"accesses"`
+	// MaxPayloadBytes limits the payload size of publishes on the topic, zero means no limit.
+	MaxPayloadBytes int `json:"max_payload_bytes,omitempty" koanf:"max_payload_bytes"`
+	// PostAuthorizeWebhook is called after the access is allowed and it can deny the access.
+	PostAuthorizeWebhook *postauth.Config `json:"post_authorize_webhook,omitempty" koanf:"post_authorize_webhook"`
+	// StateCheck is called after the topic is matched and denies the access when state is not active.
+	StateCheck *statecheck.Config `json:"state_check,omitempty" koanf:"state_check"`
+	// Deprecated counts and warns the matches of template, so old topic shapes which share their type
+	// with the new ones can be removed when they are not used anymore.
+	Deprecated bool `json:"deprecated,omitempty" koanf:"deprecated"`
+	// AllowedAccessTypes overrides the allowed access types of vendor for the topic when it is set.
+	AllowedAccessTypes []string `json:"allowed_access_types,omitempty" koanf:"allowed_access_types"`
+	// SubscriptionLimit limits the distinct topics of the type which each client subscribes.
+	SubscriptionLimit *sublimit.Config `json:"subscription_limit,omitempty" koanf:"subscription_limit"`
+	// Priority orders the matching of templates, templates with higher priority are tried first
+	// and templates with the same priority are tried in the configuration order.
+	Priority int `json:"priority,omitempty" koanf:"priority"`
+	// RideMembership only allows the topics which belong to the ride of client.
+	RideMembership *RideMembership `json:"ride_membership,omitempty" koanf:"ride_membership"`
+	// AllowedValues restricts the fields of topic, which are its levels, to their known values by field name.
+	AllowedValues map[string]FieldValues `json:"allowed_values,omitempty" koanf:"allowed_values"`
+	// PayloadChecks compare the fields of JSON payload of publishes with their claims or topic levels.
+	PayloadChecks []PayloadCheck `json:"payload_checks,omitempty" koanf:"payload_checks"`
+	// AccessesSource is AccessesSourceStatic or AccessesSourceRemote, it is static when it is empty.
+	AccessesSource string `json:"accesses_source,omitempty" koanf:"accesses_source"`
+	// RemoteAccesses is the service which is asked about the accesses of remote source.
+	RemoteAccesses *remoteaccess.Config `json:"remote_accesses,omitempty" koanf:"remote_accesses"`
+}
+
+// RideMembership binds a level of topic to the ride claim of token, e.g. passengers can only subscribe
+// to the shared location of their own ride.
+type RideMembership struct {
+	// Claim is the ride claim of token, it is DefaultRideClaim when it is empty.
+	Claim string `json:"claim,omitempty" koanf:"claim"`
+	// Segment is the position of ride in topic, e.g. 3 in snapp/driver/<hash>/<ride>/shared_location.
+	Segment int `json:"segment,omitempty" koanf:"segment"`
+}
+
+// Ordered returns the topics in their matching order, which is the descending order of their priority
+// and the configuration order of topics with the same priority.
+func Ordered(topicList []Topic) []Topic {
+	ordered := slices.Clone(topicList)
+
+	slices.SortStableFunc(ordered, func(a, b Topic) int {
+		return cmp.Compare(b.Priority, a.Priority)
+	})
+
+	return ordered
 }
 
 type Template struct {
-	Type     string
-	Template *template.Template
-	Accesses map[string]acl.AccessType
+	Type            string
+	Template        *template.Template
+	Accesses        map[string]acl.AccessType
+	MaxPayloadBytes int
+	PostAuthorizer  *postauth.Client
+	StateCheck      *StateCheck
+	Deprecated      bool
+	// AllowedAccessTypes are used instead of the vendor allowed access types when they are not nil.
+	AllowedAccessTypes []acl.AccessType
+	// SubscriptionLimiter is shared between the templates of a type, it is nil when there is no limit.
+	SubscriptionLimiter *sublimit.Limiter
+	// RideMembership has the default ride claim when it is set.
+	RideMembership *RideMembership
+	// AllowedValues check the fields of topic in the order of their names.
+	AllowedValues []*FieldMatcher
+	// PayloadChecks are validated by the authenticator builder.
+	PayloadChecks []PayloadCheck
+	// RemoteAccesses replaces the accesses when it is set, the accesses are its static fallback.
+	RemoteAccesses *remoteaccess.Client
 }
 
-func (t Template) Parse(fields map[string]string) string {
+// StateCheck has the state service client and the templates of its request fields.
+type StateCheck struct {
+	Client        *statecheck.Client
+	DriverID      *template.Template
+	PassengerHash *template.Template
+}
+
+// Parse renders the template using the given fields, it returns TemplateRenderError when the template
+// cannot be rendered, e.g. it references a field which is not supplied.
+func (t Template) Parse(fields map[string]string) (string, error) {
 	writer := new(strings.Builder)
 
 	if err := t.Template.Execute(writer, fields); err != nil {
+		return "", serrors.TemplateRenderError{
+			TopicType: t.Type,
+			Field:     missingField(err),
+			Err:       err,
+		}
+	}
+
+	return writer.String(), nil
+}
+
+// missingField returns the missing field of template execution error, templates are parsed
+// with missingkey=error so referencing a missing field fails with the key name.
+func missingField(err error) string {
+	_, key, ok := strings.Cut(err.Error(), missingKeyError)
+	if !ok {
 		return ""
 	}
 
-	return writer.String()
+	field, _, _ := strings.Cut(key, `"`)
+
+	return field
 }
 
 // HasAccess check if user has access on topic.
-func (t Template) HasAccess(iss string, accessType acl.AccessType) bool {
-	access := t.Accesses[iss]
+func (t Template) HasAccess(iss, qualifier string, accessType acl.AccessType) bool {
+	return t.Access(iss, qualifier).Allows(accessType)
+}
+
+// Access returns the effective access of user on topic.
+// The precedence is the qualified access of the issuer (e.g. `0:caller`) when qualifier is not empty,
+// then explicit access of the issuer and at the end the default access which is defined using the default key.
+// explicit deny never falls back.
+func (t Template) Access(iss, qualifier string) acl.AccessType {
+	return t.EntityAccess("", iss, qualifier)
+}
+
+// EntityAccess returns the effective access of user whose entity is resolved by an entity rule,
+// the access of entity has precedence over the accesses of its issuer when topic has it.
+func (t Template) EntityAccess(entity, iss, qualifier string) acl.AccessType {
+	key := iss
+
+	if _, ok := t.Accesses[entity]; ok && entity != "" {
+		key = entity
+	} else if qualifier != "" {
+		if _, ok := t.Accesses[QualifiedKey(iss, qualifier)]; ok {
+			key = QualifiedKey(iss, qualifier)
+		}
+	}
+
+	access, ok := t.Accesses[key]
+	if !ok || access == acl.None {
+		access = t.Accesses[Default]
+	}
+
+	return access
+}
+
+// claimEntityAccess returns the access of an entity which is resolved by a claim of its token, it is the access
+// of entity or the default access, so the entity never has the accesses of its issuer.
+func (t Template) claimEntityAccess(entity string) acl.AccessType {
+	access, ok := t.Accesses[entity]
+	if !ok || access == acl.None {
+		access = t.Accesses[Default]
+	}
+
+	return access
+}
+
+// QualifiedKey returns the accesses key of an issuer with the given qualifier.
+func QualifiedKey(iss, qualifier string) string {
+	return iss + QualifierSeparator + qualifier
+}
+
+// AllowsPayload check if a publish with the given payload size is allowed on topic.
+// subscriptions and requests without payload size are always allowed.
+func (t Template) AllowsPayload(accessType acl.AccessType, size int) bool {
+	if accessType != acl.Pub || t.MaxPayloadBytes <= 0 {
+		return true
+	}
+
+	return size <= t.MaxPayloadBytes
+}
+
+// PostAuthorize calls the post authorize webhook of topic, topics without webhook are always allowed.
+func (t Template) PostAuthorize(ctx context.Context, iss, sub, topic string, accessType acl.AccessType) error {
+	if t.PostAuthorizer == nil {
+		return nil
+	}
+
+	return t.PostAuthorizer.Authorize(ctx, iss, sub, topic, accessType) //nolint: wrapcheck
+}
+
+// LimitSubscription counts the subscribed topic for the client, it is called after the access is allowed
+// and publishes and topics without limit are always allowed.
+func (t Template) LimitSubscription(iss, sub, topic string, accessType acl.AccessType) error {
+	if accessType != acl.Sub || t.SubscriptionLimiter == nil {
+		return nil
+	}
+
+	return t.SubscriptionLimiter.Subscribe(iss, sub, topic) //nolint: wrapcheck
+}
+
+// CheckRide checks the ride level of topic is the ride claim of client, templates without
+// ride membership are not checked.
+func (t Template) CheckRide(topic, iss, sub string, claims map[string]any) error {
+	if t.RideMembership == nil {
+		return nil
+	}
+
+	err := serrors.RideMismatchError{
+		TopicType: t.Type,
+		Issuer:    iss,
+		Sub:       sub,
+		Claim:     t.RideMembership.Claim,
+		Missing:   false,
+	}
+
+	ride, ok := claims[t.RideMembership.Claim]
+	if !ok || ride == nil || jwtstrconv.ToString(ride) == "" {
+		err.Missing = true
+
+		return err
+	}
+
+	levels := strings.Split(topic, Separator)
+
+	if t.RideMembership.Segment >= len(levels) || levels[t.RideMembership.Segment] != jwtstrconv.ToString(ride) {
+		return err
+	}
 
-	return access == acl.PubSub || access == accessType
+	return nil
 }
@@ -1,14 +1,19 @@
 package topics_test
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"text/template"
 
+	serrors "github.com/snapp-incubator/soteria/internal/errors"
 	"github.com/snapp-incubator/soteria/internal/topics"
 	"github.com/snapp-incubator/soteria/pkg/acl"
 	"github.com/stretchr/testify/require"
 )
 
+var errSample = errors.New("sample error")
+
 func TestTopic(t *testing.T) {
 	t.Parallel()
 
@@ -29,9 +34,239 @@ func TestTopic(t *testing.T) {
 		Accesses: topic.Accesses,
 	}
 
-	s := temp.Parse(map[string]string{
+	s, err := temp.Parse(map[string]string{
 		"iss": "passenger",
 	})
+	require.NoError(err)
 
 	require.Equal("^passenger-event-$", s)
 }
+
+func TestTopicParseErrors(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	// nolint: exhaustruct
+	missing := topics.Template{
+		Type:     topics.SharedLocation,
+		Template: template.Must(template.New("").Option(topics.MissingKeyOption).Parse("^{{.sub}}/{{.uid}}$")),
+	}
+
+	_, err := missing.Parse(map[string]string{
+		"sub": "DXKgaNQa7N5Y7bo",
+	})
+
+	var renderErr serrors.TemplateRenderError
+
+	require.ErrorAs(err, &renderErr)
+	require.Equal(topics.SharedLocation, renderErr.TopicType)
+	require.Equal("uid", renderErr.Field)
+	require.Equal(serrors.ReasonMissingField, renderErr.Reason())
+
+	// nolint: exhaustruct
+	failed := topics.Template{
+		Type: topics.Chat,
+		Template: template.Must(template.New("").Funcs(template.FuncMap{
+			"Fail": func() (string, error) {
+				return "", errSample
+			},
+		}).Parse("^{{Fail}}$")),
+	}
+
+	_, err = failed.Parse(map[string]string{})
+
+	require.ErrorAs(err, &renderErr)
+	require.ErrorIs(err, errSample)
+	require.Equal(topics.Chat, renderErr.TopicType)
+	require.Empty(renderErr.Field)
+	require.Equal(serrors.ReasonTemplateFailed, renderErr.Reason())
+}
+
+func TestTopicAllowsPayload(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	// nolint: exhaustruct
+	temp := topics.Template{
+		Type:            topics.DriverLocation,
+		MaxPayloadBytes: 1024,
+	}
+
+	require.True(temp.AllowsPayload(acl.Pub, 0))
+	require.True(temp.AllowsPayload(acl.Pub, 1024))
+	require.False(temp.AllowsPayload(acl.Pub, 1025))
+	require.True(temp.AllowsPayload(acl.Sub, 200*1024))
+
+	temp.MaxPayloadBytes = 0
+
+	require.True(temp.AllowsPayload(acl.Pub, 200*1024))
+}
+
+// nolint: funlen
+func TestTopicHasAccess(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		accesses map[string]acl.AccessType
+		iss      string
+		access   acl.AccessType
+		want     bool
+	}{
+		{
+			name:     "explicit allow",
+			accesses: map[string]acl.AccessType{topics.PassengerIss: acl.Sub},
+			iss:      topics.PassengerIss,
+			access:   acl.Sub,
+			want:     true,
+		},
+		{
+			name:     "explicit pubsub allows publish",
+			accesses: map[string]acl.AccessType{topics.PassengerIss: acl.PubSub},
+			iss:      topics.PassengerIss,
+			access:   acl.Pub,
+			want:     true,
+		},
+		{
+			name:     "missing is denied by default",
+			accesses: map[string]acl.AccessType{topics.DriverIss: acl.Sub},
+			iss:      topics.PassengerIss,
+			access:   acl.Sub,
+			want:     false,
+		},
+		{
+			name:     "missing uses the default",
+			accesses: map[string]acl.AccessType{topics.Default: acl.Sub},
+			iss:      topics.PassengerIss,
+			access:   acl.Sub,
+			want:     true,
+		},
+		{
+			name:     "none uses the default",
+			accesses: map[string]acl.AccessType{topics.PassengerIss: acl.None, topics.Default: acl.Sub},
+			iss:      topics.PassengerIss,
+			access:   acl.Sub,
+			want:     true,
+		},
+		{
+			name:     "explicit deny overrides the default",
+			accesses: map[string]acl.AccessType{topics.PassengerIss: acl.Deny, topics.Default: acl.PubSub},
+			iss:      topics.PassengerIss,
+			access:   acl.Sub,
+			want:     false,
+		},
+		{
+			name:     "explicit allow overrides the default",
+			accesses: map[string]acl.AccessType{topics.PassengerIss: acl.Pub, topics.Default: acl.Sub},
+			iss:      topics.PassengerIss,
+			access:   acl.Sub,
+			want:     false,
+		},
+		{
+			name:     "default deny",
+			accesses: map[string]acl.AccessType{topics.Default: acl.Deny},
+			iss:      topics.PassengerIss,
+			access:   acl.Sub,
+			want:     false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// nolint: exhaustruct
+			temp := topics.Template{
+				Type:     topics.Chat,
+				Accesses: tc.accesses,
+			}
+
+			require.Equal(t, tc.want, temp.HasAccess(tc.iss, "", tc.access))
+		})
+	}
+}
+
+// nolint: funlen
+func TestTopicHasQualifiedAccess(t *testing.T) {
+	t.Parallel()
+
+	// nolint: exhaustruct
+	temp := topics.Template{
+		Type: topics.CallOutgoing,
+		Accesses: map[string]acl.AccessType{
+			topics.QualifiedKey(topics.DriverIss, "caller"): acl.Sub,
+			topics.QualifiedKey(topics.DriverIss, "callee"): acl.Deny,
+			topics.DriverIss:    acl.Sub,
+			topics.PassengerIss: acl.Sub,
+			topics.Default:      acl.Sub,
+		},
+	}
+
+	tests := []struct {
+		name      string
+		iss       string
+		qualifier string
+		want      bool
+	}{
+		{name: "caller is allowed", iss: topics.DriverIss, qualifier: "caller", want: true},
+		{name: "callee is denied", iss: topics.DriverIss, qualifier: "callee", want: false},
+		{name: "without qualifier uses the entity", iss: topics.DriverIss, qualifier: "", want: true},
+		{name: "unknown qualifier uses the entity", iss: topics.PassengerIss, qualifier: "callee", want: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.want, temp.HasAccess(tc.iss, tc.qualifier, acl.Sub))
+		})
+	}
+}
+
+func TestTopicPostAuthorizeWithoutWebhook(t *testing.T) {
+	t.Parallel()
+
+	// nolint: exhaustruct
+	temp := topics.Template{
+		Type: topics.Chat,
+	}
+
+	require.NoError(t, temp.PostAuthorize(context.Background(), "1", "sub", "snapp/chat", acl.Sub))
+}
+
+func TestTopicCheckRide(t *testing.T) {
+	t.Parallel()
+
+	// nolint: exhaustruct
+	temp := topics.Template{
+		Type: topics.SharedLocation,
+		RideMembership: &topics.RideMembership{
+			Claim:   topics.DefaultRideClaim,
+			Segment: 3,
+		},
+	}
+
+	topic := "snapp/driver/DXKgaNQa7N5Y7bo/ride-1/shared_location"
+
+	require.NoError(t, temp.CheckRide(topic, topics.PassengerIss, "sub", map[string]any{"ride_id": "ride-1"}))
+
+	var rmErr serrors.RideMismatchError
+
+	err := temp.CheckRide(topic, topics.PassengerIss, "sub", map[string]any{"ride_id": "ride-2"})
+	require.ErrorAs(t, err, &rmErr)
+	require.False(t, rmErr.Missing)
+	require.Equal(t, serrors.ReasonRideMismatch, rmErr.Reason())
+
+	err = temp.CheckRide(topic, topics.PassengerIss, "sub", map[string]any{})
+	require.ErrorAs(t, err, &rmErr)
+	require.True(t, rmErr.Missing)
+
+	err = temp.CheckRide("snapp/driver", topics.PassengerIss, "sub", map[string]any{"ride_id": "ride-1"})
+	require.ErrorAs(t, err, &rmErr)
+
+	// nolint: exhaustruct
+	without := topics.Template{Type: topics.SharedLocation}
+	require.NoError(t, without.CheckRide(topic, topics.PassengerIss, "sub", map[string]any{}))
+}
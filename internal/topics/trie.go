@@ -0,0 +1,228 @@
+package topics
+
+import (
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"unicode/utf8"
+)
+
+// candidatesSize is the number of candidates which are collected without allocation.
+const candidatesSize = 16
+
+// regexMeta are the characters which end the literal prefix of a regular expression.
+const regexMeta = `\.+*?()|[]{}^$`
+
+// trie is a radix trie over the literal prefixes of templates, it narrows the templates which can
+// match a topic before rendering them.
+type trie struct {
+	root *trieNode
+	// size is the number of templates which are inserted in the trie.
+	size int
+}
+
+type trieNode struct {
+	prefix    string
+	children  []*trieNode
+	templates []int
+}
+
+func newTrie() *trie {
+	return &trie{
+		root: new(trieNode),
+		size: 0,
+	}
+}
+
+// insert adds the index of a template with the given literal prefix.
+func (t *trie) insert(prefix string, index int) {
+	t.size++
+
+	node := t.root
+
+	for prefix != "" {
+		child := node.child(prefix[0])
+		if child == nil {
+			child = &trieNode{
+				prefix:    prefix,
+				children:  nil,
+				templates: nil,
+			}
+			node.children = append(node.children, child)
+
+			node = child
+
+			break
+		}
+
+		common := commonPrefix(prefix, child.prefix)
+		if common < len(child.prefix) {
+			// split the child at the common prefix.
+			split := &trieNode{
+				prefix:    child.prefix[:common],
+				children:  []*trieNode{child},
+				templates: nil,
+			}
+			node.replace(split)
+
+			child.prefix = child.prefix[common:]
+
+			child = split
+		}
+
+		node = child
+		prefix = prefix[common:]
+	}
+
+	node.templates = append(node.templates, index)
+}
+
+// match appends the index of templates which their literal prefix is a prefix of topic to dst.
+// the indices of each node are sorted but they are not sorted across nodes.
+func (t *trie) match(topic string, dst []int) []int {
+	node := t.root
+
+	for {
+		dst = append(dst, node.templates...)
+
+		if topic == "" {
+			return dst
+		}
+
+		child := node.child(topic[0])
+		if child == nil || !strings.HasPrefix(topic, child.prefix) {
+			return dst
+		}
+
+		topic = topic[len(child.prefix):]
+		node = child
+	}
+}
+
+func (n *trieNode) child(b byte) *trieNode {
+	for _, child := range n.children {
+		if child.prefix[0] == b {
+			return child
+		}
+	}
+
+	return nil
+}
+
+// replace replaces the child which has the same first byte with the given node.
+func (n *trieNode) replace(node *trieNode) {
+	for i, child := range n.children {
+		if child.prefix[0] == node.prefix[0] {
+			n.children[i] = node
+
+			return
+		}
+	}
+}
+
+func commonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+
+	return i
+}
+
+// LiteralPrefix returns the literal prefix of topics which are matched by the template, it is
+// the anchored part of the template before its first field. the company field is part of the prefix
+// because it is the same for all topics of a manager. it returns an empty string when the template
+// is not anchored or it uses alternation, so any topic can be matched by it.
+func LiteralPrefix(tmpl *template.Template, company string) string {
+	if tmpl == nil || tmpl.Tree == nil || tmpl.Tree.Root == nil {
+		return ""
+	}
+
+	var text strings.Builder
+
+	literal := true
+
+	for _, node := range tmpl.Tree.Root.Nodes {
+		// alternation makes the anchor optional, e.g. ^a|b matches b anywhere. actions are checked
+		// conservatively because their constants and pipelines can render it too.
+		if strings.Contains(node.String(), "|") {
+			return ""
+		}
+
+		switch n := node.(type) {
+		case *parse.TextNode:
+			if literal {
+				text.Write(n.Text)
+			}
+		case *parse.ActionNode:
+			if literal && isCompany(n) {
+				text.WriteString(company)
+
+				continue
+			}
+
+			literal = false
+		default:
+			literal = false
+		}
+	}
+
+	return regexPrefix(text.String())
+}
+
+// isCompany reports whether the action only prints the company field.
+func isCompany(n *parse.ActionNode) bool {
+	if len(n.Pipe.Decl) != 0 || len(n.Pipe.Cmds) != 1 || len(n.Pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+
+	field, ok := n.Pipe.Cmds[0].Args[0].(*parse.FieldNode)
+
+	return ok && len(field.Ident) == 1 && field.Ident[0] == "company"
+}
+
+// regexPrefix returns the literal prefix of an anchored regular expression.
+func regexPrefix(regex string) string {
+	regex, ok := strings.CutPrefix(regex, "^")
+	if !ok {
+		return ""
+	}
+
+	var prefix strings.Builder
+
+	for i := 0; i < len(regex); i++ {
+		c := regex[i]
+
+		switch {
+		case strings.ContainsRune("?*{", rune(c)):
+			// the last literal is optional.
+			return dropLast(prefix.String())
+		case c == '\\':
+			// only escaped punctuations are literals, e.g. \d is a character class.
+			if i+1 >= len(regex) || !strings.ContainsRune(regexMeta+"/-", rune(regex[i+1])) {
+				return prefix.String()
+			}
+
+			i++
+
+			if i+1 < len(regex) && strings.ContainsRune("?*{", rune(regex[i+1])) {
+				return prefix.String()
+			}
+
+			prefix.WriteByte(regex[i])
+		case strings.ContainsRune(regexMeta, rune(c)):
+			return prefix.String()
+		default:
+			prefix.WriteByte(c)
+		}
+	}
+
+	return prefix.String()
+}
+
+// dropLast removes the last rune of s.
+func dropLast(s string) string {
+	_, size := utf8.DecodeLastRuneInString(s)
+
+	return s[:len(s)-size]
+}
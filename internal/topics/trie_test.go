@@ -0,0 +1,166 @@
+package topics_test
+
+import (
+	"context"
+	"math/rand/v2"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestLiteralPrefix(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{
+			name:     "company",
+			template: "^{{.company}}/driver/{{.sub}}/location$",
+			want:     "snapp/driver/",
+		},
+		{
+			name:     "literal",
+			template: "^bucks$",
+			want:     "bucks",
+		},
+		{
+			name:     "function",
+			template: "^{{IssToEntity .iss}}-event-{{.sub}}$",
+			want:     "",
+		},
+		{
+			name:     "not anchored",
+			template: "{{.company}}/chat/{{.sub}}$",
+			want:     "",
+		},
+		{
+			name:     "alternation",
+			template: "^{{.company}}/chat/{{.sub}}|^bucks$",
+			want:     "",
+		},
+		{
+			name:     "character class",
+			template: "^shared/[a-z]+/{{.sub}}$",
+			want:     "shared/",
+		},
+		{
+			name:     "optional",
+			template: "^{{.company}}/chats?/{{.sub}}$",
+			want:     "snapp/chat",
+		},
+		{
+			name:     "escaped",
+			template: `^{{.company}}\.ir/chat/{{.sub}}$`,
+			want:     "snapp.ir/chat/",
+		},
+		{
+			name:     "class escape",
+			template: `^{{.company}}/\d+/{{.sub}}$`,
+			want:     "snapp/",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpl := template.Must(template.New(tc.name).Funcs(template.FuncMap{
+				"IssToEntity": func(string) string { return "" },
+			}).Parse(tc.template))
+
+			require.Equal(t, tc.want, topics.LiteralPrefix(tmpl, "snapp"))
+		})
+	}
+}
+
+// TestParseTopicDifferential checks the templates which are narrowed by their literal prefixes
+// match the same as trying all templates in order.
+func TestParseTopicDifferential(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	cfg := config.SnappVendor()
+
+	hid, err := topics.NewHashIDManager(cfg.HashIDMap)
+	require.NoError(err)
+
+	topicManager := topics.NewTopicManager(cfg.Topics, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop())
+
+	// managers which are not created by NewTopicManager try all templates.
+	// nolint: exhaustruct
+	reference := &topics.Manager{
+		HashIDSManager: hid,
+		Company:        "snapp",
+		TopicTemplates: topicManager.TopicTemplates,
+		IssEntityMap:   cfg.IssEntityMap,
+		IssPeerMap:     cfg.IssPeerMap,
+		Functions:      topicManager.Functions,
+		Logger:         zap.NewNop(),
+	}
+
+	sub := "DXKgaNQa7N5Y7bo"
+	issuers := []string{topics.DriverIss, topics.PassengerIss}
+
+	levels := []string{
+		"snapp", "driver", "passenger", "shared", "bucks", "chat", "call", "send", "receive",
+		"location", "superapp", "driver-location", "passenger-location", "1234", sub, "", "snapp|bucks",
+		"driver-event-" + topicManager.EncodeMD5(topicManager.DecodeHashID(sub, topics.DriverIss)),
+		"passenger-event-" + topicManager.EncodeMD5(topicManager.DecodeHashID(sub, topics.PassengerIss)),
+	}
+
+	r := rand.New(rand.NewPCG(1, 2)) // nolint: gosec
+
+	for range 2000 {
+		segments := make([]string, 1+r.IntN(6))
+		for i := range segments {
+			segments[i] = levels[r.IntN(len(levels))]
+		}
+
+		topic := strings.Join(segments, topics.Separator)
+		iss := issuers[r.IntN(len(issuers))]
+
+		want, wantErr := reference.ParseTopic(context.Background(), topic, iss, sub, nil)
+		got, gotErr := topicManager.ParseTopic(context.Background(), topic, iss, sub, nil)
+
+		require.Equal(wantErr, gotErr, topic)
+
+		if want == nil {
+			require.Nil(got, topic)
+
+			continue
+		}
+
+		require.NotNil(got, topic)
+		require.Equal(want.Type, got.Type, topic)
+		require.Equal(want.Template, got.Template, topic)
+	}
+}
+
+func BenchmarkParseTopic(b *testing.B) {
+	cfg := config.SnappVendor()
+
+	hid, err := topics.NewHashIDManager(cfg.HashIDMap)
+	require.NoError(b, err)
+
+	topicManager := topics.NewTopicManager(cfg.Topics, hid, "snapp", cfg.IssEntityMap, cfg.IssPeerMap, zap.NewNop())
+
+	sub := "DXKgaNQa7N5Y7bo"
+	topic := "snapp/passenger/" + sub + "/location"
+
+	b.ResetTimer()
+
+	for range b.N {
+		if _, err := topicManager.ParseTopic(context.Background(), topic, topics.PassengerIss, sub, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
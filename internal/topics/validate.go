@@ -0,0 +1,63 @@
+package topics
+
+import (
+	"errors"
+	"fmt"
+	"text/template"
+)
+
+var (
+	ErrMissingTopicType = errors.New("topic has no type")
+	ErrMissingTemplate  = errors.New("topic has no template")
+	ErrInvalidTemplate  = errors.New("topic template cannot be parsed")
+	ErrMissingAccesses  = errors.New("topic has no accesses, so it denies everyone")
+	ErrReservedType     = errors.New("topic type is reserved for static topics")
+)
+
+// placeholder stands for the functions of manager, which depend on its vendor, while templates are parsed
+// without a manager. Parsing only checks the names of functions.
+func placeholder(...any) string {
+	return ""
+}
+
+// placeholders returns the placeholders of the functions of manager which templates can call,
+// decodeSubject and quoteSegment are not one of them because only the rewritten templates use them.
+func placeholders() template.FuncMap {
+	funcs := make(template.FuncMap)
+
+	for name := range new(Manager).functions() {
+		if name != decodeFunction && name != quoteFunction {
+			funcs[name] = placeholder
+		}
+	}
+
+	return funcs
+}
+
+// Validate checks the topic has a type other than the static topic type and a template which parses,
+// and topics of static accesses have accesses.
+// Validation runs after configuration is unmarshalled, so broken topics fail the startup instead of panicking
+// while their manager is created. Its errors are joined.
+func (t Topic) Validate() error {
+	errs := make([]error, 0)
+
+	switch t.Type {
+	case "":
+		errs = append(errs, ErrMissingTopicType)
+	case StaticTopicType:
+		errs = append(errs, ErrReservedType)
+	}
+
+	if t.Template == "" {
+		errs = append(errs, ErrMissingTemplate)
+	} else if _, err := template.New(t.Type).Funcs(placeholders()).Option(MissingKeyOption).Parse(t.Template); err != nil {
+		errs = append(errs, fmt.Errorf("%w: %w", ErrInvalidTemplate, err))
+	}
+
+	// remote topics can have no accesses, their accesses are only the fallback of remote accesses.
+	if len(t.Accesses) == 0 && t.AccessesSource != AccessesSourceRemote {
+		errs = append(errs, ErrMissingAccesses)
+	}
+
+	return errors.Join(errs...)
+}
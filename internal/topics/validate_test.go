@@ -0,0 +1,60 @@
+package topics_test
+
+import (
+	"testing"
+
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopicValidate(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	for _, topic := range config.SnappVendor().Topics {
+		require.NoError(topic.Validate(), topic.Type)
+	}
+
+	// nolint: exhaustruct
+	require.ErrorIs(topics.Topic{Type: "chat", Template: "^chat/{{.sub}$"}.Validate(), topics.ErrInvalidTemplate)
+
+	// templates can call the functions of manager, except decodeSubject which only the rewritten
+	// DecodeHashID calls use.
+	// nolint: exhaustruct
+	require.NoError(topics.Topic{
+		Type:     "location",
+		Template: "^{{IssToEntity .iss}}/{{EncodeMD5 (DecodeHashID .sub .iss)}}/{{IssToPeer .iss}}$",
+		Accesses: map[string]acl.AccessType{topics.DriverIss: acl.Sub},
+	}.Validate())
+	// nolint: exhaustruct
+	require.ErrorIs(topics.Topic{
+		Type:     "location",
+		Template: "^{{decodeSubject .fields .sub .iss}}$",
+		Accesses: map[string]acl.AccessType{topics.DriverIss: acl.Sub},
+	}.Validate(), topics.ErrInvalidTemplate)
+
+	// all of the errors are joined.
+	err := topics.Topic{}.Validate() // nolint: exhaustruct
+	require.ErrorIs(err, topics.ErrMissingTopicType)
+	require.ErrorIs(err, topics.ErrMissingTemplate)
+	require.ErrorIs(err, topics.ErrMissingAccesses)
+
+	// static topic type is reserved for static topics.
+	// nolint: exhaustruct
+	require.ErrorIs(topics.Topic{
+		Type:     topics.StaticTopicType,
+		Template: "^chat$",
+		Accesses: map[string]acl.AccessType{topics.DriverIss: acl.Sub},
+	}.Validate(), topics.ErrReservedType)
+
+	// remote topics don't need the static accesses.
+	// nolint: exhaustruct
+	require.NoError(topics.Topic{
+		Type:           "chat",
+		Template:       "^chat$",
+		AccessesSource: topics.AccessesSourceRemote,
+	}.Validate())
+}
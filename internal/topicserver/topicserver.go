@@ -0,0 +1,280 @@
+// Package topicserver serves the topic matching of vendors without the auth machinery, so topics like
+// the historical topics of analytics can be validated offline at high volume. Tokens are never verified
+// and the topics are decided in the topic layer same as the replay.
+package topicserver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/ansrivas/fiberprometheus/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/metric"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultMaxBulks is the number of concurrent bulk requests, bulk requests over it are rejected.
+	DefaultMaxBulks = 4
+	// DefaultMaxLineBytes is the maximum size of each line of bulk requests.
+	DefaultMaxLineBytes = 64 << 10
+
+	// flushEvery is the number of bulk responses which are flushed together.
+	flushEvery = 64
+
+	EndpointMatch = "match"
+	EndpointBulk  = "bulk"
+
+	ResultMatched   = "matched"
+	ResultUnmatched = "unmatched"
+	ResultError     = "error"
+)
+
+var (
+	ErrUnknownVendor = errors.New("vendor has no topics")
+	ErrTooManyBulks  = errors.New("too many concurrent bulk requests")
+)
+
+type Config struct {
+	// MaxBulks is the number of concurrent bulk requests, it is DefaultMaxBulks when it is zero.
+	MaxBulks int
+	// MaxLineBytes is the maximum size of each line of bulk requests, it is DefaultMaxLineBytes when it is zero.
+	MaxLineBytes int
+}
+
+// MatchRequest is a topic of subject, vendor is the first vendor of resolution when it is empty.
+type MatchRequest struct {
+	Vendor string         `json:"vendor,omitempty"`
+	Topic  string         `json:"topic"`
+	Issuer string         `json:"issuer"`
+	Sub    string         `json:"sub"`
+	Claims map[string]any `json:"claims,omitempty"`
+}
+
+// MatchResponse has the matched topic type and the decision of each access by its name.
+type MatchResponse struct {
+	Vendor   string          `json:"vendor"`
+	Topic    string          `json:"topic"`
+	Matched  bool            `json:"matched"`
+	Type     string          `json:"type,omitempty"`
+	Accesses map[string]bool `json:"accesses,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+type Server struct {
+	Deciders map[string]authenticator.Decider
+	// Default is the vendor of requests without vendor.
+	Default string
+	Logger  *zap.Logger
+	Metrics *metric.TopicServerMetrics
+
+	maxLineBytes int
+	bulks        chan struct{}
+}
+
+// New creates the topics server of deciders.
+func New(cfg Config, deciders map[string]authenticator.Decider, defaultVendor string, logger *zap.Logger) *Server {
+	if cfg.MaxBulks <= 0 {
+		cfg.MaxBulks = DefaultMaxBulks
+	}
+
+	if cfg.MaxLineBytes <= 0 {
+		cfg.MaxLineBytes = DefaultMaxLineBytes
+	}
+
+	return &Server{
+		Deciders:     deciders,
+		Default:      defaultVendor,
+		Logger:       logger,
+		Metrics:      metric.NewTopicServerMetrics(prometheus.DefaultRegisterer),
+		maxLineBytes: cfg.MaxLineBytes,
+		bulks:        make(chan struct{}, cfg.MaxBulks),
+	}
+}
+
+// App returns the fiber app of server, request bodies are streamed so bulk requests are read
+// while they are matched.
+func (s *Server) App() *fiber.App {
+	// nolint: exhaustruct
+	app := fiber.New(fiber.Config{
+		StreamRequestBody:     true,
+		DisableStartupMessage: true,
+	})
+
+	prometheus := fiberprometheus.NewWithRegistry(prometheus.DefaultRegisterer, "http", "platform", "soteria", nil)
+	prometheus.RegisterAt(app, "/metrics")
+
+	app.Post("/match", s.Match)
+	app.Post("/bulk", s.Bulk)
+
+	return app
+}
+
+// Match matches a topic.
+func (s *Server) Match(c *fiber.Ctx) error {
+	var request MatchRequest
+
+	if err := json.Unmarshal(c.Body(), &request); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	response := s.match(EndpointMatch, request)
+	if _, ok := s.Deciders[response.Vendor]; !ok {
+		return c.Status(http.StatusNotFound).JSON(response)
+	}
+
+	return c.Status(http.StatusOK).JSON(response)
+}
+
+// Bulk matches the topics of NDJSON body and streams their responses as NDJSON in the same order.
+// Lines are read one by one while their responses are written, so slow clients are backpressured
+// instead of buffering the whole body. Invalid lines have their error in their response.
+func (s *Server) Bulk(c *fiber.Ctx) error {
+	select {
+	case s.bulks <- struct{}{}:
+	default:
+		return c.Status(http.StatusTooManyRequests).JSON(fiber.Map{"error": ErrTooManyBulks.Error()})
+	}
+
+	body := c.Context().RequestBodyStream()
+	if body == nil {
+		body = bytes.NewReader(c.Body())
+	}
+
+	c.Set(fiber.HeaderContentType, "application/x-ndjson")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer func() {
+			<-s.bulks
+		}()
+
+		if err := s.bulk(body, w); err != nil {
+			s.Logger.Error("bulk request failed", zap.Error(err))
+		}
+	})
+
+	return nil
+}
+
+func (s *Server) bulk(body io.Reader, w *bufio.Writer) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), s.maxLineBytes)
+
+	encoder := json.NewEncoder(w)
+	written := 0
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var (
+			request  MatchRequest
+			response MatchResponse
+		)
+
+		if err := json.Unmarshal(line, &request); err != nil {
+			s.Metrics.Match(s.Default, EndpointBulk, ResultError)
+
+			response = MatchResponse{
+				Vendor:   request.Vendor,
+				Topic:    request.Topic,
+				Matched:  false,
+				Type:     "",
+				Accesses: nil,
+				Error:    err.Error(),
+			}
+		} else {
+			response = s.match(EndpointBulk, request)
+		}
+
+		if err := encoder.Encode(response); err != nil {
+			return fmt.Errorf("cannot write response %w", err)
+		}
+
+		written++
+
+		// responses are flushed in batches, so clients receive them while they are still sending.
+		if written%flushEvery == 0 {
+			if err := w.Flush(); err != nil {
+				return fmt.Errorf("cannot flush responses %w", err)
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		_ = encoder.Encode(fiber.Map{"error": err.Error()})
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("cannot flush responses %w", err)
+	}
+
+	return scanner.Err() //nolint: wrapcheck
+}
+
+// match matches the topic of request by the decider of its vendor and counts it by its result.
+func (s *Server) match(endpoint string, request MatchRequest) MatchResponse {
+	vendor := request.Vendor
+	if vendor == "" {
+		vendor = s.Default
+	}
+
+	response := MatchResponse{
+		Vendor:   vendor,
+		Topic:    request.Topic,
+		Matched:  false,
+		Type:     "",
+		Accesses: nil,
+		Error:    "",
+	}
+
+	decider, ok := s.Deciders[vendor]
+	if !ok {
+		s.Metrics.Match(vendor, endpoint, ResultError)
+
+		response.Error = fmt.Sprintf("%s: %s", ErrUnknownVendor, vendor)
+
+		return response
+	}
+
+	decision, err := decider.Match(request.Topic, authenticator.Subject{
+		Issuer:    request.Issuer,
+		Sub:       request.Sub,
+		Qualifier: "",
+		ID:        "",
+	}, request.Claims)
+	if err != nil {
+		var invalid authenticator.InvalidTopicError
+
+		if errors.As(err, &invalid) {
+			s.Metrics.Match(vendor, endpoint, ResultUnmatched)
+		} else {
+			s.Metrics.Match(vendor, endpoint, ResultError)
+
+			response.Error = err.Error()
+		}
+
+		return response
+	}
+
+	s.Metrics.Match(vendor, endpoint, ResultMatched)
+
+	response.Matched = true
+	response.Type = decision.Type
+	response.Accesses = make(map[string]bool, len(decision.Accesses))
+
+	for access, err := range decision.Accesses {
+		response.Accesses[access.String()] = err == nil
+	}
+
+	return response
+}
@@ -0,0 +1,169 @@
+package topicserver_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/internal/topicserver"
+	"github.com/snapp-incubator/soteria/pkg/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+)
+
+// app has the handlers of server without its metrics endpoint which can be registered once.
+func app(t *testing.T) *fiber.App {
+	t.Helper()
+
+	deciders, err := authenticator.Builder{
+		Vendors:              []config.Vendor{config.SnappVendor()},
+		Logger:               zap.NewNop(),
+		ValidatorConfig:      config.Default().Validator,
+		Tracer:               noop.NewTracerProvider().Tracer(""),
+		KeyRegistry:          nil,
+		Flags:                nil,
+		FailureRatio:         nil,
+		StrictTopicShadowing: false,
+		Background:           nil,
+	}.Deciders()
+	require.NoError(t, err)
+
+	// nolint: exhaustruct
+	server := topicserver.New(topicserver.Config{}, deciders, "snapp", zap.NewNop())
+
+	app := fiber.New()
+	app.Post("/match", server.Match)
+	app.Post("/bulk", server.Bulk)
+
+	return app
+}
+
+func TestMatch(t *testing.T) {
+	t.Parallel()
+
+	app := app(t)
+
+	tests := []struct {
+		name     string
+		body     string
+		status   int
+		response topicserver.MatchResponse
+	}{
+		{
+			name:   "matched",
+			body:   `{"topic": "snapp/driver/` + testutil.DefaultSubject + `/location", "issuer": "0", "sub": "` + testutil.DefaultSubject + `"}`,
+			status: http.StatusOK,
+			response: topicserver.MatchResponse{
+				Vendor:   "snapp",
+				Topic:    "snapp/driver/" + testutil.DefaultSubject + "/location",
+				Matched:  true,
+				Type:     topics.DriverLocation,
+				Accesses: map[string]bool{"publish": true, "subscribe": false},
+				Error:    "",
+			},
+		},
+		{
+			name:   "unmatched",
+			body:   `{"topic": "snapp/driver/` + testutil.DefaultSubject + `/unknown", "issuer": "0", "sub": "` + testutil.DefaultSubject + `"}`,
+			status: http.StatusOK,
+			response: topicserver.MatchResponse{
+				Vendor:   "snapp",
+				Topic:    "snapp/driver/" + testutil.DefaultSubject + "/unknown",
+				Matched:  false,
+				Type:     "",
+				Accesses: nil,
+				Error:    "",
+			},
+		},
+		{
+			name:   "unknown vendor",
+			body:   `{"vendor": "unknown", "topic": "unknown/location", "issuer": "0", "sub": "1"}`,
+			status: http.StatusNotFound,
+			response: topicserver.MatchResponse{
+				Vendor:   "unknown",
+				Topic:    "unknown/location",
+				Matched:  false,
+				Type:     "",
+				Accesses: nil,
+				Error:    topicserver.ErrUnknownVendor.Error() + ": unknown",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require := require.New(t)
+
+			req := httptest.NewRequest(http.MethodPost, "/match", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req, -1)
+			require.NoError(err)
+
+			defer resp.Body.Close()
+
+			require.Equal(tc.status, resp.StatusCode)
+
+			var response topicserver.MatchResponse
+
+			require.NoError(json.NewDecoder(resp.Body).Decode(&response))
+			require.Equal(tc.response, response)
+		})
+	}
+}
+
+func TestBulk(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	app := app(t)
+
+	lines := []string{
+		`{"topic": "snapp/driver/` + testutil.DefaultSubject + `/location", "issuer": "0", "sub": "` + testutil.DefaultSubject + `"}`,
+		`not json`,
+		``,
+		`{"vendor": "unknown", "topic": "unknown/location", "issuer": "0", "sub": "1"}`,
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/bulk", strings.NewReader(strings.Join(lines, "\n")))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	resp, err := app.Test(req, -1)
+	require.NoError(err)
+
+	defer resp.Body.Close()
+
+	require.Equal(http.StatusOK, resp.StatusCode)
+
+	responses := make([]topicserver.MatchResponse, 0)
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var response topicserver.MatchResponse
+
+		require.NoError(json.Unmarshal(scanner.Bytes(), &response))
+
+		responses = append(responses, response)
+	}
+
+	require.NoError(scanner.Err())
+
+	// empty lines are skipped and the others are answered in order.
+	require.Len(responses, 3)
+	require.True(responses[0].Matched)
+	require.Equal(topics.DriverLocation, responses[0].Type)
+	require.NotEmpty(responses[1].Error)
+	require.False(responses[2].Matched)
+	require.NotEmpty(responses[2].Error)
+}
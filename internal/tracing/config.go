@@ -1,7 +1,14 @@
 package tracing
 
+import "time"
+
 type Config struct {
 	Enabled  bool    `json:"enabled,omitempty"  koanf:"enabled"`
 	Endpoint string  `json:"endpoint,omitempty" koanf:"endpoint"`
 	Ratio    float64 `json:"ratio,omitempty"    koanf:"ratio"`
+	// Metrics exports metrics to the same endpoint, metrics are always exposed on /metrics for prometheus.
+	Metrics         bool          `json:"metrics,omitempty"          koanf:"metrics"`
+	MetricsInterval time.Duration `json:"metrics_interval,omitempty" koanf:"metrics_interval"`
+	// Attributes are added to the resource of both traces and metrics.
+	Attributes map[string]string `json:"attributes,omitempty" koanf:"attributes"`
 }
@@ -0,0 +1,66 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.uber.org/zap"
+)
+
+// HighCardinalityAttributes are the attributes which are dropped from all metrics
+// because they are unique per client or topic.
+// nolint: gochecknoglobals
+var HighCardinalityAttributes = []attribute.Key{
+	"sub",
+	"topic",
+	"token",
+	"username",
+	"password",
+	"client-id",
+	"client-ip",
+}
+
+// newMeterProvider creates the meter provider which is always exported using the prometheus default registry,
+// so the existing metrics names are kept, and it is exported to the endpoint when metrics are enabled.
+func newMeterProvider(cfg Config, res *resource.Resource, logger *zap.Logger) *sdkmetric.MeterProvider {
+	exporter, err := otelprometheus.New(
+		otelprometheus.WithRegisterer(prometheus.DefaultRegisterer),
+		otelprometheus.WithoutScopeInfo(),
+		otelprometheus.WithoutTargetInfo(),
+	)
+	if err != nil {
+		logger.Fatal("failed to initialize prometheus exporter for metrics", zap.Error(err))
+	}
+
+	opts := []sdkmetric.Option{
+		sdkmetric.WithResource(res),
+		sdkmetric.WithReader(exporter),
+		sdkmetric.WithView(sdkmetric.NewView(
+			sdkmetric.Instrument{Name: "*"}, // nolint: exhaustruct
+			sdkmetric.Stream{ // nolint: exhaustruct
+				AttributeFilter: attribute.NewDenyKeysFilter(HighCardinalityAttributes...),
+			},
+		)),
+	}
+
+	if cfg.Metrics {
+		otlp, err := otlpmetricgrpc.New(
+			context.Background(),
+			otlpmetricgrpc.WithEndpoint(cfg.Endpoint), otlpmetricgrpc.WithInsecure(),
+		)
+		if err != nil {
+			logger.Fatal("failed to initialize export pipeline for metrics (otlp with grpc)", zap.Error(err))
+		}
+
+		opts = append(opts, sdkmetric.WithReader(
+			sdkmetric.NewPeriodicReader(otlp, sdkmetric.WithInterval(cfg.MetricsInterval)),
+		))
+	}
+
+	return sdkmetric.NewMeterProvider(opts...)
+}
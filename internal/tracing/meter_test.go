@@ -0,0 +1,53 @@
+package tracing_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/tracing"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+func TestHighCardinalityAttributes(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	// nolint: exhaustruct
+	telemetry := tracing.New(tracing.Config{}, zap.NewNop())
+
+	counter, err := otel.Meter("test").Int64Counter("platform_soteria_test_total")
+	require.NoError(err)
+
+	counter.Add(context.Background(), 1, metric.WithAttributes(
+		attribute.String("company", "snapp"),
+		attribute.String("topic", "snapp/driver/1/location"),
+		attribute.String("client-id", "driver-1"),
+	))
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(err)
+
+	var labels []string
+
+	for _, family := range families {
+		if family.GetName() != "platform_soteria_test_total" {
+			continue
+		}
+
+		require.Len(family.GetMetric(), 1)
+
+		for _, label := range family.GetMetric()[0].GetLabel() {
+			labels = append(labels, label.GetName())
+		}
+	}
+
+	require.Equal([]string{"company"}, labels)
+
+	require.NoError(telemetry.Shutdown(context.Background()))
+}
@@ -2,8 +2,10 @@ package tracing
 
 import (
 	"context"
+	"errors"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
@@ -14,9 +16,34 @@ import (
 	"go.uber.org/zap"
 )
 
-func New(cfg Config, logger *zap.Logger) trace.Tracer {
+// Telemetry has the tracer and the providers which are flushed on shutdown.
+type Telemetry struct {
+	Tracer trace.Tracer
+
+	shutdown []func(context.Context) error
+}
+
+// New sets up the global meter provider and, when tracing is enabled, the global tracer provider.
+func New(cfg Config, logger *zap.Logger) Telemetry {
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewSchemaless(attributes(cfg)...),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	mp := newMeterProvider(cfg, res, logger)
+
+	otel.SetMeterProvider(mp)
+
+	telemetry := Telemetry{
+		Tracer:   noop.NewTracerProvider().Tracer("snapp.dispatching"),
+		shutdown: []func(context.Context) error{mp.Shutdown},
+	}
+
 	if !cfg.Enabled {
-		return noop.NewTracerProvider().Tracer("snapp.dispatching")
+		return telemetry
 	}
 
 	exporter, err := otlptracegrpc.New(
@@ -27,17 +54,6 @@ func New(cfg Config, logger *zap.Logger) trace.Tracer {
 		logger.Fatal("failed to initialize export pipeline for traces (otlp with grpc)", zap.Error(err))
 	}
 
-	res, err := resource.Merge(
-		resource.Default(),
-		resource.NewSchemaless(
-			semconv.ServiceNamespaceKey.String("snapp.dispatching"),
-			semconv.ServiceNameKey.String("soteria"),
-		),
-	)
-	if err != nil {
-		panic(err)
-	}
-
 	bsp := sdktrace.NewBatchSpanProcessor(exporter)
 	tp := sdktrace.NewTracerProvider(
 		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.Ratio))),
@@ -52,7 +68,34 @@ func New(cfg Config, logger *zap.Logger) trace.Tracer {
 
 	otel.SetTextMapPropagator(tc)
 
-	tracer := otel.Tracer("dispatching/soteria")
+	telemetry.Tracer = otel.Tracer("dispatching/soteria")
+	// spans are flushed before metrics, so their export metrics are included.
+	telemetry.shutdown = append([]func(context.Context) error{tp.Shutdown}, telemetry.shutdown...)
+
+	return telemetry
+}
+
+// Shutdown flushes and stops the providers.
+func (t Telemetry) Shutdown(ctx context.Context) error {
+	errs := make([]error, 0, len(t.shutdown))
+
+	for _, shutdown := range t.shutdown {
+		errs = append(errs, shutdown(ctx))
+	}
+
+	return errors.Join(errs...)
+}
+
+// attributes returns the resource attributes, the configured attributes override the service ones.
+func attributes(cfg Config) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.ServiceNamespaceKey.String("snapp.dispatching"),
+		semconv.ServiceNameKey.String("soteria"),
+	}
+
+	for k, v := range cfg.Attributes {
+		attrs = append(attrs, attribute.String(k, v))
+	}
 
-	return tracer
+	return attrs
 }
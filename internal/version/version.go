@@ -0,0 +1,56 @@
+// Package version has the build information of binary, which is injected at build time:
+//
+//	go build -ldflags "-X github.com/snapp-incubator/soteria/internal/version.Version=v1.0.0 \
+//	  -X github.com/snapp-incubator/soteria/internal/version.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/snapp-incubator/soteria/internal/version.BuildDate=$(date -u +%FT%TZ)"
+//
+// Binaries which are built without them use the VCS information which Go embeds, if any.
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+const unknown = "unknown"
+
+// nolint: gochecknoglobals
+var (
+	Version   = "dev"
+	Commit    = unknown
+	BuildDate = unknown
+)
+
+// Info is the build information of binary.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build information, the injected values take precedence over the embedded VCS information.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	for _, setting := range build.Settings {
+		switch {
+		case setting.Key == "vcs.revision" && info.Commit == unknown:
+			info.Commit = setting.Value
+		case setting.Key == "vcs.time" && info.BuildDate == unknown:
+			info.BuildDate = setting.Value
+		}
+	}
+
+	return info
+}
@@ -0,0 +1,216 @@
+// Package worker runs the background tasks of components, like revalidation of stale tokens, on a bounded
+// pool of goroutines with a shared lifecycle. Panics of tasks are recovered, and queued tasks are drained
+// on shutdown until its deadline.
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/metric"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultWorkers         = 4
+	DefaultQueueSize       = 1000
+	DefaultShutdownTimeout = 5 * time.Second
+
+	StateQueued  = "queued"
+	StateRunning = "running"
+
+	ResultDone    = "done"
+	ResultPanic   = "panic"
+	ResultDropped = "dropped"
+)
+
+var ErrDrainTimeout = errors.New("background tasks are not drained before shutdown deadline")
+
+type Config struct {
+	// Workers is the number of goroutines which run the tasks.
+	Workers int `json:"workers,omitempty" koanf:"workers"`
+	// QueueSize is the number of tasks which wait for a worker, tasks are dropped when the queue is full.
+	QueueSize int `json:"queue_size,omitempty" koanf:"queue_size"`
+	// ShutdownTimeout is the deadline of draining the queued tasks on shutdown.
+	ShutdownTimeout time.Duration `json:"shutdown_timeout,omitempty" koanf:"shutdown_timeout"`
+}
+
+type task struct {
+	component string
+	ctx       context.Context //nolint: containedctx
+	run       func(context.Context)
+}
+
+// Pool is safe for concurrent use, nil pool runs every task in its own goroutine.
+type Pool struct {
+	cfg     Config
+	tasks   chan task
+	logger  *zap.Logger
+	metrics *metric.BackgroundMetrics
+
+	// ctx is canceled when the pool is stopped, so the running tasks are abandoned after the deadline.
+	ctx    context.Context //nolint: containedctx
+	cancel context.CancelFunc
+
+	lock    sync.RWMutex
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+// New creates the pool and starts its workers.
+func New(cfg Config, logger *zap.Logger) *Pool {
+	if cfg.Workers <= 0 {
+		cfg.Workers = DefaultWorkers
+	}
+
+	if cfg.QueueSize < 0 {
+		cfg.QueueSize = 0
+	}
+
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = DefaultShutdownTimeout
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	p := &Pool{
+		cfg:     cfg,
+		tasks:   make(chan task, cfg.QueueSize),
+		logger:  logger,
+		metrics: metric.NewBackgroundMetrics(prometheus.DefaultRegisterer),
+		ctx:     ctx,
+		cancel:  cancel,
+		lock:    sync.RWMutex{},
+		stopped: false,
+		wg:      sync.WaitGroup{},
+	}
+
+	for range cfg.Workers {
+		p.wg.Add(1)
+
+		go p.work()
+	}
+
+	return p
+}
+
+// Submit queues the task of component, it returns false when the task is dropped because
+// the queue is full or the pool is stopped. Task context has the values of ctx but it is not
+// canceled with it, because tasks outlive the requests which submit them.
+func (p *Pool) Submit(ctx context.Context, component string, run func(context.Context)) bool {
+	if p == nil {
+		go run(context.WithoutCancel(ctx))
+
+		return true
+	}
+
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if p.stopped {
+		p.metrics.Result(component, ResultDropped)
+
+		return false
+	}
+
+	select {
+	case p.tasks <- task{component: component, ctx: context.WithoutCancel(ctx), run: run}:
+		p.metrics.State(component, StateQueued, 1)
+
+		return true
+	default:
+		p.metrics.Result(component, ResultDropped)
+
+		return false
+	}
+}
+
+// Stop stops accepting tasks and waits for the queued and running tasks until the shutdown timeout,
+// tasks which are not finished by then are canceled.
+func (p *Pool) Stop() error {
+	if p == nil {
+		return nil
+	}
+
+	p.lock.Lock()
+
+	if p.stopped {
+		p.lock.Unlock()
+
+		return nil
+	}
+
+	p.stopped = true
+	close(p.tasks)
+	p.lock.Unlock()
+
+	done := make(chan struct{})
+
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(p.cfg.ShutdownTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		p.cancel()
+
+		return nil
+	case <-timer.C:
+		p.cancel()
+
+		return fmt.Errorf("%w: %d tasks are queued", ErrDrainTimeout, len(p.tasks))
+	}
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+
+	for t := range p.tasks {
+		p.metrics.State(t.component, StateQueued, -1)
+
+		// tasks which are queued after the deadline are not run.
+		if p.ctx.Err() != nil {
+			p.metrics.Result(t.component, ResultDropped)
+
+			continue
+		}
+
+		p.run(t)
+	}
+}
+
+func (p *Pool) run(t task) {
+	p.metrics.State(t.component, StateRunning, 1)
+	defer p.metrics.State(t.component, StateRunning, -1)
+
+	ctx, cancel := context.WithCancel(t.ctx)
+	defer cancel()
+
+	stop := context.AfterFunc(p.ctx, cancel)
+	defer stop()
+
+	defer func() {
+		if r := recover(); r != nil {
+			p.metrics.Result(t.component, ResultPanic)
+			p.logger.Error("background task panicked",
+				zap.String("component", t.component),
+				zap.Any("panic", r),
+				zap.Stack("stack"),
+			)
+
+			return
+		}
+
+		p.metrics.Result(t.component, ResultDone)
+	}()
+
+	t.run(ctx)
+}
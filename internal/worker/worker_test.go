@@ -0,0 +1,90 @@
+package worker_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/snapp-incubator/soteria/internal/worker"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestPoolPanic(t *testing.T) {
+	t.Parallel()
+
+	p := worker.New(worker.Config{Workers: 1, QueueSize: 10, ShutdownTimeout: time.Second}, zap.NewNop())
+
+	var done atomic.Bool
+
+	require.True(t, p.Submit(context.Background(), "test", func(context.Context) { panic("boom") }))
+	require.True(t, p.Submit(context.Background(), "test", func(context.Context) { done.Store(true) }))
+
+	// worker survives the panic of the first task.
+	require.NoError(t, p.Stop())
+	require.True(t, done.Load())
+}
+
+func TestPoolDrop(t *testing.T) {
+	t.Parallel()
+
+	p := worker.New(worker.Config{Workers: 1, QueueSize: 1, ShutdownTimeout: time.Second}, zap.NewNop())
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	require.True(t, p.Submit(context.Background(), "test", func(context.Context) {
+		close(started)
+		<-release
+	}))
+
+	<-started
+
+	require.True(t, p.Submit(context.Background(), "test", func(context.Context) {}))
+	require.False(t, p.Submit(context.Background(), "test", func(context.Context) {}), "queue is full")
+
+	close(release)
+	require.NoError(t, p.Stop())
+
+	require.False(t, p.Submit(context.Background(), "test", func(context.Context) {}), "pool is stopped")
+}
+
+func TestPoolStopDeadline(t *testing.T) {
+	t.Parallel()
+
+	p := worker.New(worker.Config{Workers: 1, QueueSize: 1, ShutdownTimeout: 50 * time.Millisecond}, zap.NewNop())
+
+	canceled := make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	require.True(t, p.Submit(ctx, "test", func(ctx context.Context) {
+		<-ctx.Done()
+		close(canceled)
+	}))
+
+	// task is not canceled with the context of its submitter.
+	cancel()
+
+	require.ErrorIs(t, p.Stop(), worker.ErrDrainTimeout)
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		require.FailNow(t, "task is not canceled after the shutdown deadline")
+	}
+}
+
+func TestNilPool(t *testing.T) {
+	t.Parallel()
+
+	var p *worker.Pool
+
+	done := make(chan struct{})
+
+	require.True(t, p.Submit(context.Background(), "test", func(context.Context) { close(done) }))
+	<-done
+
+	require.NoError(t, p.Stop())
+}
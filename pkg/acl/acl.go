@@ -1,5 +1,13 @@
 package acl
 
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownAccessType is returned for access types which are not one of the known names or values.
+var ErrUnknownAccessType = errors.New("unknown access type")
+
 // AccessType Types for EMQ contains subscribe, publish and publish-subscribe.
 type AccessType string
 
@@ -7,7 +15,10 @@ const (
 	Sub    AccessType = "1"
 	Pub    AccessType = "2"
 	PubSub AccessType = "3"
-	None   AccessType = "-1"
+	// None means there is no access and the default access (if any) applies.
+	None AccessType = "-1"
+	// Deny is an explicit deny which takes precedence over the default access.
+	Deny AccessType = "0"
 
 	ClientCredentials = "client_credentials"
 )
@@ -20,7 +31,92 @@ func (a AccessType) String() string {
 		return "publish"
 	case PubSub:
 		return "publish-subscribe"
+	case Deny:
+		return "deny"
 	}
 
 	return ""
 }
+
+// IsValid checks the access type is one of the known access types.
+func (a AccessType) IsValid() bool {
+	switch a {
+	case Sub, Pub, PubSub, None, Deny:
+		return true
+	}
+
+	return false
+}
+
+// Allows checks the access type grants the requested access type.
+func (a AccessType) Allows(accessType AccessType) bool {
+	switch a { //nolint:exhaustive
+	case PubSub:
+		return accessType == Sub || accessType == Pub || accessType == PubSub
+	case Sub, Pub:
+		return a == accessType
+	}
+
+	return false
+}
+
+// Grants returns the publish and subscribe accesses which are granted by the access type.
+func (a AccessType) Grants() []AccessType {
+	switch a { //nolint:exhaustive
+	case PubSub:
+		return []AccessType{Pub, Sub}
+	case Sub, Pub:
+		return []AccessType{a}
+	}
+
+	return nil
+}
+
+// ParseAccessType parses the name or value of access type, e.g. pub, publish or 2 for publish.
+// Values are the same as EMQ access types, so numeric accesses of the older configurations still work.
+func ParseAccessType(access string) (AccessType, error) {
+	switch access {
+	case "sub", "subscribe", string(Sub):
+		return Sub, nil
+	case "pub", "publish", string(Pub):
+		return Pub, nil
+	case "pubsub", "subpub", "publish-subscribe", string(PubSub):
+		return PubSub, nil
+	case "deny", string(Deny):
+		return Deny, nil
+	case "none", string(None):
+		return None, nil
+	}
+
+	return "", fmt.Errorf("%w %q, use sub, pub, pubsub, deny or none", ErrUnknownAccessType, access)
+}
+
+// UnmarshalText parses the name or value of access type, so configurations can use the names.
+func (a *AccessType) UnmarshalText(text []byte) error {
+	access, err := ParseAccessType(string(text))
+	if err != nil {
+		return err
+	}
+
+	*a = access
+
+	return nil
+}
+
+// MarshalText returns the name of access type, unknown access types are returned as they are.
+func (a AccessType) MarshalText() ([]byte, error) {
+	switch a {
+	case Sub:
+		return []byte("sub"), nil
+	case Pub:
+		return []byte("pub"), nil
+	case PubSub:
+		return []byte("pubsub"), nil
+	case Deny:
+		return []byte("deny"), nil
+	case None:
+		return []byte("none"), nil
+	}
+
+	return []byte(a), nil
+}
@@ -0,0 +1,60 @@
+package acl_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAccessType(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]acl.AccessType{
+		"sub":       acl.Sub,
+		"subscribe": acl.Sub,
+		"1":         acl.Sub,
+		"pub":       acl.Pub,
+		"publish":   acl.Pub,
+		"2":         acl.Pub,
+		"pubsub":    acl.PubSub,
+		"subpub":    acl.PubSub,
+		"3":         acl.PubSub,
+		"deny":      acl.Deny,
+		"0":         acl.Deny,
+		"none":      acl.None,
+		"-1":        acl.None,
+	}
+
+	for access, expected := range tests {
+		t.Run(access, func(t *testing.T) {
+			t.Parallel()
+
+			at, err := acl.ParseAccessType(access)
+			require.NoError(t, err)
+			require.Equal(t, expected, at)
+		})
+	}
+
+	_, err := acl.ParseAccessType("subs")
+	require.ErrorIs(t, err, acl.ErrUnknownAccessType)
+	require.ErrorContains(t, err, `"subs"`)
+}
+
+func TestAccessTypeText(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	var accesses map[string]acl.AccessType
+
+	require.NoError(json.Unmarshal([]byte(`{"0": "pub", "1": "2", "2": "deny"}`), &accesses))
+	require.Equal(map[string]acl.AccessType{"0": acl.Pub, "1": acl.Pub, "2": acl.Deny}, accesses)
+
+	encoded, err := json.Marshal(accesses)
+	require.NoError(err)
+	require.JSONEq(`{"0": "pub", "1": "pub", "2": "deny"}`, string(encoded))
+
+	require.ErrorIs(json.Unmarshal([]byte(`{"0": "subs"}`), &accesses), acl.ErrUnknownAccessType)
+}
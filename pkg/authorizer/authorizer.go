@@ -0,0 +1,209 @@
+// Package authorizer embeds the token authentication and topic authorization of soteria vendors
+// in other services, so they can authorize topics in-process instead of calling soteria over HTTP.
+// It has no dependency on the soteria HTTP server or its commands, it only uses the vendor types of configuration.
+package authorizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/internal/authenticator"
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/snapp-incubator/soteria/pkg/strconv"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+)
+
+var ErrPermissionsNotSupported = errors.New("vendor cannot list the allowed topics")
+
+// Permission is an allowed topic of a client with its accesses.
+type Permission struct {
+	Type string `json:"type"`
+	// Topic is the concrete topic when all fields of template are resolved.
+	Topic string `json:"topic,omitempty"`
+	// Pattern is the topic regular expression when topic is not concrete.
+	Pattern  string   `json:"pattern,omitempty"`
+	Accesses []string `json:"accesses"`
+}
+
+// Option configures the optional dependencies of authorizer.
+type Option func(*options)
+
+type options struct {
+	logger     *zap.Logger
+	tracer     trace.Tracer
+	registerer prometheus.Registerer
+}
+
+// WithLogger sets the logger of authorizer, logs are discarded by default.
+func WithLogger(logger *zap.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithTracer sets the tracer of authorizer, spans are not recorded by default.
+func WithTracer(tracer trace.Tracer) Option {
+	return func(o *options) {
+		o.tracer = tracer
+	}
+}
+
+// WithRegisterer sets the registerer of authorizer metrics, metrics are not registered on
+// the default registerer of the service by default.
+func WithRegisterer(reg prometheus.Registerer) Option {
+	return func(o *options) {
+		o.registerer = reg
+	}
+}
+
+// Claims are the claims of a verified token.
+type Claims struct {
+	Company string
+	// Issuer and Subject are the claims which are named by the JWT configuration of vendor.
+	Issuer  string
+	Subject string
+	// Raw has all claims of the token.
+	Raw map[string]any
+}
+
+// Authorizer authenticates the tokens of a vendor and authorizes their topics, it is safe for concurrent use.
+type Authorizer struct {
+	auth   authenticator.Authenticator
+	jwt    config.JWT
+	parser *jwt.Parser
+}
+
+// New builds the authorizer of vendor same as soteria builds its vendors.
+func New(vendor Vendor, opts ...Option) (*Authorizer, error) {
+	o := options{
+		logger:     zap.NewNop(),
+		tracer:     noop.NewTracerProvider().Tracer("authorizer"),
+		registerer: prometheus.NewRegistry(),
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	cfg := vendor.config()
+
+	// nolint: exhaustruct
+	auth, err := authenticator.Builder{
+		Vendors: nil,
+		Logger:  o.logger,
+		ValidatorConfig: config.Validator{
+			URL:               vendor.Validator.URL,
+			Timeout:           vendor.Validator.Timeout,
+			IATSkewRetryDelay: 0,
+			CacheTTL:          0,
+			StaleTTL:          0,
+		},
+		Tracer:     o.tracer,
+		Registerer: o.registerer,
+	}.Authenticator(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("cannot build vendor %s %w", vendor.Company, err)
+	}
+
+	return newAuthorizer(auth, cfg), nil
+}
+
+// Vendors builds the authorizers of all vendors of builder, soteria builds its vendors using it because
+// its vendor configuration has more fields than Vendor. Other services use New.
+func Vendors(builder authenticator.Builder) (map[string]*Authorizer, error) {
+	auths, err := builder.Authenticators()
+	if err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	authorizers := make(map[string]*Authorizer, len(auths))
+
+	for _, vendor := range builder.Vendors {
+		if auth, ok := auths[vendor.Company]; ok {
+			authorizers[vendor.Company] = newAuthorizer(auth, vendor)
+		}
+	}
+
+	return authorizers, nil
+}
+
+func newAuthorizer(auth authenticator.Authenticator, vendor config.Vendor) *Authorizer {
+	return &Authorizer{
+		auth:   auth,
+		jwt:    vendor.Jwt,
+		parser: jwt.NewParser(),
+	}
+}
+
+// Authenticator returns the authenticator of vendor which soteria serves over HTTP.
+func (a *Authorizer) Authenticator() authenticator.Authenticator {
+	return a.auth
+}
+
+// Company returns the company of vendor.
+func (a *Authorizer) Company() string {
+	return a.auth.GetCompany()
+}
+
+// Auth checks the token is valid.
+func (a *Authorizer) Auth(ctx context.Context, token string) error {
+	return a.auth.Auth(ctx, token) //nolint: wrapcheck
+}
+
+// Verify checks the token is valid and returns its claims.
+func (a *Authorizer) Verify(ctx context.Context, token string) (Claims, error) {
+	if err := a.auth.Auth(ctx, token); err != nil {
+		return Claims{}, err //nolint: wrapcheck
+	}
+
+	// claims are read from the unverified token because it is already verified.
+	claims := make(jwt.MapClaims)
+
+	if _, _, err := a.parser.ParseUnverified(token, claims); err != nil {
+		return Claims{}, fmt.Errorf("cannot parse the verified token %w", err)
+	}
+
+	return Claims{
+		Company: a.auth.GetCompany(),
+		Issuer:  strconv.ToString(claims[a.jwt.IssName]),
+		Subject: strconv.ToString(claims[a.jwt.SubName]),
+		Raw:     claims,
+	}, nil
+}
+
+// ACL checks the token has the given access on topic.
+func (a *Authorizer) ACL(ctx context.Context, access acl.AccessType, token, topic string) (bool, error) {
+	return a.auth.ACL(ctx, access, token, topic, 0) //nolint: wrapcheck
+}
+
+// AllowedTopics returns the topics which the valid token has access to.
+func (a *Authorizer) AllowedTopics(ctx context.Context, token string) ([]Permission, error) {
+	permAuth, ok := a.auth.(authenticator.PermissionsAuthenticator)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPermissionsNotSupported, a.auth.GetCompany())
+	}
+
+	// permissions are listed using the unverified claims, so token is verified first.
+	if err := a.auth.Auth(ctx, token); err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	permissions, err := permAuth.Permissions(ctx, token)
+	if err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	result := make([]Permission, 0, len(permissions))
+
+	for _, permission := range permissions {
+		result = append(result, Permission(permission))
+	}
+
+	return result, nil
+}
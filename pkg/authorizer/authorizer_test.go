@@ -0,0 +1,138 @@
+package authorizer_test
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/snapp-incubator/soteria/pkg/authorizer"
+	"github.com/snapp-incubator/soteria/pkg/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func vendor(key []byte) authorizer.Vendor {
+	// nolint: exhaustruct
+	return authorizer.Vendor{
+		Company:            "snapp",
+		Type:               "manual",
+		AllowedAccessTypes: []string{"pub", "sub"},
+		Topics: []authorizer.Topic{
+			{
+				Type:     "driver_location",
+				Template: "^{{.company}}/driver/{{.sub}}/location$",
+				Accesses: map[string]acl.AccessType{
+					testutil.DriverIss:    acl.Pub,
+					testutil.PassengerIss: acl.None,
+				},
+			},
+		},
+		Keys: map[string]string{
+			testutil.DriverIss: base64.StdEncoding.EncodeToString(key),
+		},
+		IssEntityMap: map[string]string{"default": ""},
+		IssPeerMap:   map[string]string{"default": ""},
+		JWT: authorizer.JWT{
+			IssName:       "iss",
+			SubName:       "sub",
+			SigningMethod: "HS512",
+		},
+	}
+}
+
+func TestAuthorizer(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	key := []byte("secret")
+
+	a, err := authorizer.New(vendor(key))
+	require.NoError(err)
+	require.Equal("snapp", a.Company())
+
+	token, err := testutil.DriverToken(jwt.SigningMethodHS512, key)
+	require.NoError(err)
+
+	require.NoError(a.Auth(context.Background(), token))
+
+	claims, err := a.Verify(context.Background(), token)
+	require.NoError(err)
+	require.Equal("snapp", claims.Company)
+	require.Equal(testutil.DriverIss, claims.Issuer)
+	require.Equal(testutil.DefaultSubject, claims.Subject)
+
+	topic := "snapp/driver/" + testutil.DefaultSubject + "/location"
+
+	ok, err := a.ACL(context.Background(), acl.Pub, token, topic)
+	require.NoError(err)
+	require.True(ok)
+
+	ok, err = a.ACL(context.Background(), acl.Sub, token, topic)
+	require.Error(err)
+	require.False(ok)
+
+	permissions, err := a.AllowedTopics(context.Background(), token)
+	require.NoError(err)
+	require.Equal([]authorizer.Permission{
+		{
+			Type:     "driver_location",
+			Topic:    topic,
+			Pattern:  "",
+			Accesses: []string{"publish"},
+		},
+	}, permissions)
+
+	invalid, err := testutil.DriverToken(jwt.SigningMethodHS512, []byte("invalid"))
+	require.NoError(err)
+
+	require.Error(a.Auth(context.Background(), invalid))
+
+	_, err = a.Verify(context.Background(), invalid)
+	require.Error(err)
+
+	_, err = a.AllowedTopics(context.Background(), invalid)
+	require.Error(err)
+}
+
+func TestAuthorizerInvalidVendor(t *testing.T) {
+	t.Parallel()
+
+	v := vendor([]byte("secret"))
+	v.Type = "unknown"
+
+	_, err := authorizer.New(v)
+	require.Error(t, err)
+}
+
+func TestAuthorizerRegisterer(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	key := []byte("secret")
+	reg := prometheus.NewRegistry()
+
+	a, err := authorizer.New(vendor(key), authorizer.WithRegisterer(reg))
+	require.NoError(err)
+
+	token, err := testutil.DriverToken(jwt.SigningMethodHS512, key)
+	require.NoError(err)
+
+	ok, err := a.ACL(context.Background(), acl.Pub, token, "snapp/driver/"+testutil.DefaultSubject+"/location")
+	require.NoError(err)
+	require.True(ok)
+
+	// metrics of authorizer are registered on the given registerer.
+	families, err := reg.Gather()
+	require.NoError(err)
+
+	names := make([]string, 0, len(families))
+	for _, family := range families {
+		names = append(names, family.GetName())
+	}
+
+	require.Contains(names, "platform_soteria_topic_match_total")
+}
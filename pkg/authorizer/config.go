@@ -0,0 +1,135 @@
+package authorizer
+
+import (
+	"time"
+
+	"github.com/snapp-incubator/soteria/internal/config"
+	"github.com/snapp-incubator/soteria/internal/topics"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+)
+
+// Vendor is the configuration of a vendor which mirrors the vendors of soteria configuration,
+// fields are only added to it, so it can be used by the other services.
+type Vendor struct {
+	Company string `json:"company,omitempty" koanf:"company"`
+	// Type is the authenticator type of vendor, e.g. manual or auto.
+	Type                 string                       `json:"type,omitempty"                   koanf:"type"`
+	AllowedAccessTypes   []string                     `json:"allowed_access_types,omitempty"   koanf:"allowed_access_types"`
+	Topics               []Topic                      `json:"topics,omitempty"                 koanf:"topics"`
+	Keys                 map[string]string            `json:"keys,omitempty"                   koanf:"keys"`
+	IssEntityMap         map[string]string            `json:"iss_entity_map,omitempty"         koanf:"iss_entity_map"`
+	IssPeerMap           map[string]string            `json:"iss_peer_map,omitempty"           koanf:"iss_peer_map"`
+	JWT                  JWT                          `json:"jwt,omitempty"                    koanf:"jwt"`
+	HashIDMap            map[string]HashData          `json:"hash_id_map,omitempty"            koanf:"hashid_map"`
+	AccessQualifierClaim string                       `json:"access_qualifier_claim,omitempty" koanf:"access_qualifier_claim"`
+	VerificationKeys     map[string][]VerificationKey `json:"verification_keys,omitempty"      koanf:"verification_keys"`
+	// Validator is used by the auto vendors which validate tokens using the validator service.
+	Validator Validator `json:"validator,omitempty" koanf:"validator"`
+	// IssEntityRules resolve the entity of tokens by a claim in their order before IssEntityMap.
+	IssEntityRules []EntityRule `json:"iss_entity_rules,omitempty" koanf:"iss_entity_rules"`
+}
+
+type Topic struct {
+	Type               string                    `json:"type,omitempty"                 koanf:"type"`
+	Template           string                    `json:"template,omitempty"             koanf:"template"`
+	Accesses           map[string]acl.AccessType `json:"accesses,omitempty"             koanf:"accesses"`
+	MaxPayloadBytes    int                       `json:"max_payload_bytes,omitempty"    koanf:"max_payload_bytes"`
+	AllowedAccessTypes []string                  `json:"allowed_access_types,omitempty" koanf:"allowed_access_types"`
+	// Priority orders the templates, the templates with higher priority are tried first.
+	Priority int `json:"priority,omitempty" koanf:"priority"`
+}
+
+type JWT struct {
+	IssName       string `json:"iss_name,omitempty"       koanf:"iss_name"`
+	SubName       string `json:"sub_name,omitempty"       koanf:"sub_name"`
+	SigningMethod string `json:"signing_method,omitempty" koanf:"signing_method"`
+	// IssuerSigningMethods override the signing method of issuers.
+	IssuerSigningMethods map[string]string `json:"issuer_signing_methods,omitempty" koanf:"issuer_signing_methods"`
+}
+
+type HashData struct {
+	Length   int    `json:"length,omitempty"   koanf:"length"`
+	Salt     string `json:"salt,omitempty"     koanf:"salt"`
+	Alphabet string `json:"alphabet,omitempty" koanf:"alphabet"`
+	// Type is hashid or none, subjects of issuers with none type are raw ids. It is hashid when it is empty.
+	Type string `json:"type,omitempty" koanf:"type"`
+}
+
+// EntityRule resolves the entity of the tokens of an issuer which have the value in their claim,
+// rules without claim match every token of their issuer.
+type EntityRule struct {
+	Iss    string `json:"iss,omitempty"    koanf:"iss"`
+	Claim  string `json:"claim,omitempty"  koanf:"claim"`
+	Value  string `json:"value,omitempty"  koanf:"value"`
+	Entity string `json:"entity,omitempty" koanf:"entity"`
+}
+
+type VerificationKey struct {
+	Kid string `json:"kid,omitempty" koanf:"kid"`
+	Key string `json:"key,omitempty" koanf:"key"`
+}
+
+type Validator struct {
+	URL     string        `json:"url,omitempty"     koanf:"url"`
+	Timeout time.Duration `json:"timeout,omitempty" koanf:"timeout"`
+}
+
+// config converts the vendor to the soteria vendor configuration.
+func (v Vendor) config() config.Vendor {
+	topicList := make([]topics.Topic, 0, len(v.Topics))
+
+	for _, topic := range v.Topics {
+		// nolint: exhaustruct
+		topicList = append(topicList, topics.Topic{
+			Type:               topic.Type,
+			Template:           topic.Template,
+			Accesses:           topic.Accesses,
+			MaxPayloadBytes:    topic.MaxPayloadBytes,
+			AllowedAccessTypes: topic.AllowedAccessTypes,
+		})
+	}
+
+	var hashIDMap map[string]topics.HashData
+
+	if v.HashIDMap != nil {
+		hashIDMap = make(map[string]topics.HashData, len(v.HashIDMap))
+
+		for iss, data := range v.HashIDMap {
+			hashIDMap[iss] = topics.HashData(data)
+		}
+	}
+
+	var entityRules []topics.EntityRule
+
+	for _, rule := range v.IssEntityRules {
+		entityRules = append(entityRules, topics.EntityRule(rule))
+	}
+
+	var verificationKeys map[string][]config.VerificationKey
+
+	if v.VerificationKeys != nil {
+		verificationKeys = make(map[string][]config.VerificationKey, len(v.VerificationKeys))
+
+		for iss, keys := range v.VerificationKeys {
+			for _, key := range keys {
+				verificationKeys[iss] = append(verificationKeys[iss], config.VerificationKey(key))
+			}
+		}
+	}
+
+	// nolint: exhaustruct
+	return config.Vendor{
+		AllowedAccessTypes:   v.AllowedAccessTypes,
+		Company:              v.Company,
+		Topics:               topicList,
+		Keys:                 v.Keys,
+		IssEntityMap:         v.IssEntityMap,
+		IssPeerMap:           v.IssPeerMap,
+		IssEntityRules:       entityRules,
+		Jwt:                  config.JWT(v.JWT),
+		Type:                 v.Type,
+		HashIDMap:            hashIDMap,
+		AccessQualifierClaim: v.AccessQualifierClaim,
+		VerificationKeys:     verificationKeys,
+	}
+}
@@ -0,0 +1,59 @@
+// Package correlation carries the request id of a request across the components which handle it, e.g. the logs
+// of Soteria and the calls to the validator, so a single EMQ request can be found in the logs of every service.
+// It only depends on the standard library.
+package correlation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+const (
+	// Header has the request id of requests and their responses.
+	Header = "X-Request-ID"
+	// MaxRequestIDLength is the longest incoming request id which is honored, longer ids are replaced.
+	MaxRequestIDLength = 128
+
+	requestIDBytes = 16
+)
+
+type requestIDKey struct{}
+
+// NewRequestID returns a random request id.
+func NewRequestID() string {
+	id := make([]byte, requestIDBytes)
+
+	// rand.Read never returns an error.
+	_, _ = rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
+
+// ValidRequestID checks the incoming request id can be honored, it must be printable ASCII without spaces
+// because it is logged and sent to other services as a header.
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > MaxRequestIDLength {
+		return false
+	}
+
+	for i := range len(id) {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// WithRequestID returns a copy of ctx which carries the request id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request id of ctx, it is empty when ctx doesn't carry one.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+
+	return id
+}
@@ -0,0 +1,30 @@
+package correlation_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/snapp-incubator/soteria/pkg/correlation"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestID(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	require.Empty(correlation.RequestIDFromContext(context.Background()))
+
+	id := correlation.NewRequestID()
+	require.True(correlation.ValidRequestID(id))
+	require.NotEqual(id, correlation.NewRequestID())
+
+	ctx := correlation.WithRequestID(context.Background(), id)
+	require.Equal(id, correlation.RequestIDFromContext(ctx))
+
+	require.False(correlation.ValidRequestID(""))
+	require.False(correlation.ValidRequestID("a b"))
+	require.False(correlation.ValidRequestID("a\nb"))
+	require.False(correlation.ValidRequestID(strings.Repeat("a", correlation.MaxRequestIDLength+1)))
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/snapp-incubator/soteria/pkg/authorizer"
+)
+
+// Fiber returns a middleware which only calls the next handlers for the requests which have a valid
+// bearer token, claims of the token are injected into the user context of request.
+func Fiber(a *authorizer.Authorizer, opts ...Option) fiber.Handler {
+	o := newOptions(opts)
+
+	return func(c *fiber.Ctx) error {
+		token, err := bearer(c.Get(fiber.HeaderAuthorization))
+		if err == nil {
+			var claims authorizer.Claims
+
+			if claims, err = authorize(c.UserContext(), a, o, token); err == nil {
+				c.SetUserContext(withClaims(c.UserContext(), claims))
+
+				return c.Next()
+			}
+		}
+
+		code, message := status(err)
+		if code == http.StatusUnauthorized {
+			c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+		}
+
+		return c.Status(code).SendString(message)
+	}
+}
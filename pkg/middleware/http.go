@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/snapp-incubator/soteria/pkg/authorizer"
+)
+
+// Handler only calls next for the requests which have a valid bearer token, claims of the token
+// are injected into the request context. It can be used by the routers which accept http.Handler
+// middlewares, e.g. echo.WrapMiddleware.
+func Handler(a *authorizer.Authorizer, next http.Handler, opts ...Option) http.Handler {
+	o := newOptions(opts)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := bearer(r.Header.Get("Authorization"))
+		if err == nil {
+			var claims authorizer.Claims
+
+			if claims, err = authorize(r.Context(), a, o, token); err == nil {
+				next.ServeHTTP(w, r.WithContext(withClaims(r.Context(), claims)))
+
+				return
+			}
+		}
+
+		code, message := status(err)
+		if code == http.StatusUnauthorized {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+		}
+
+		http.Error(w, message, code)
+	})
+}
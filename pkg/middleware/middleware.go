@@ -0,0 +1,138 @@
+// Package middleware protects HTTP APIs with the tokens of a soteria vendor. It has an http.Handler
+// wrapper and a fiber middleware which verify the bearer token using the soteria authorizer,
+// so keys and their rotation are configured the same way as soteria vendors.
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/snapp-incubator/soteria/pkg/authorizer"
+)
+
+const (
+	bearerPrefix = "bearer "
+
+	// SubjectPlaceholder is replaced by the subject of token in permission topics.
+	SubjectPlaceholder = "{sub}"
+)
+
+var (
+	ErrTokenRequired    = errors.New("bearer token is required")
+	ErrTokenInvalid     = errors.New("token is invalid")
+	ErrPermissionDenied = errors.New("token doesn't have the permission")
+)
+
+type claimsKey struct{}
+
+// Permission is an access on a topic which the token must have, the SubjectPlaceholder
+// of topic is replaced by the subject of token, e.g. snapp/driver/{sub}/location.
+type Permission struct {
+	Access acl.AccessType
+	Topic  string
+}
+
+// Option configures the middleware of a route.
+type Option func(*options)
+
+type options struct {
+	permission *Permission
+}
+
+// WithPermission only allows the tokens which have the access on topic.
+func WithPermission(access acl.AccessType, topic string) Option {
+	return func(o *options) {
+		o.permission = &Permission{
+			Access: access,
+			Topic:  topic,
+		}
+	}
+}
+
+// ClaimsFromContext returns the claims of the verified token which are injected by the middleware.
+func ClaimsFromContext(ctx context.Context) (authorizer.Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(authorizer.Claims)
+
+	return claims, ok
+}
+
+// SubjectFromContext returns the subject of the verified token.
+func SubjectFromContext(ctx context.Context) string {
+	claims, _ := ClaimsFromContext(ctx)
+
+	return claims.Subject
+}
+
+// IssuerFromContext returns the issuer of the verified token.
+func IssuerFromContext(ctx context.Context) string {
+	claims, _ := ClaimsFromContext(ctx)
+
+	return claims.Issuer
+}
+
+func withClaims(ctx context.Context, claims authorizer.Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		permission: nil,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// bearer returns the token of authorization header.
+func bearer(header string) (string, error) {
+	if len(header) <= len(bearerPrefix) || !strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+		return "", ErrTokenRequired
+	}
+
+	return header[len(bearerPrefix):], nil
+}
+
+// authorize verifies the token and checks its permission when there is one, errors wrap
+// ErrTokenInvalid or ErrPermissionDenied and their messages are not sent to clients.
+func authorize(ctx context.Context, a *authorizer.Authorizer, o options, token string) (authorizer.Claims, error) {
+	claims, err := a.Verify(ctx, token)
+	if err != nil {
+		return authorizer.Claims{}, fmt.Errorf("%w: %w", ErrTokenInvalid, err)
+	}
+
+	if o.permission == nil {
+		return claims, nil
+	}
+
+	topic := strings.ReplaceAll(o.permission.Topic, SubjectPlaceholder, claims.Subject)
+
+	ok, err := a.ACL(ctx, o.permission.Access, token, topic)
+	if err != nil {
+		return authorizer.Claims{}, fmt.Errorf("%w: %s on %s: %w", ErrPermissionDenied, o.permission.Access, topic, err)
+	}
+
+	if !ok {
+		return authorizer.Claims{}, fmt.Errorf("%w: %s on %s", ErrPermissionDenied, o.permission.Access, topic)
+	}
+
+	return claims, nil
+}
+
+// status returns the HTTP status code and the client message of error.
+func status(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrPermissionDenied):
+		return http.StatusForbidden, ErrPermissionDenied.Error()
+	case errors.Is(err, ErrTokenRequired):
+		return http.StatusUnauthorized, ErrTokenRequired.Error()
+	default:
+		return http.StatusUnauthorized, ErrTokenInvalid.Error()
+	}
+}
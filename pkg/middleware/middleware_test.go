@@ -0,0 +1,160 @@
+package middleware_test
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/snapp-incubator/soteria/pkg/acl"
+	"github.com/snapp-incubator/soteria/pkg/authorizer"
+	"github.com/snapp-incubator/soteria/pkg/middleware"
+	"github.com/snapp-incubator/soteria/pkg/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+const location = "snapp/driver/" + middleware.SubjectPlaceholder + "/location"
+
+func newAuthorizer(t *testing.T, key []byte) *authorizer.Authorizer {
+	t.Helper()
+
+	// nolint: exhaustruct
+	a, err := authorizer.New(authorizer.Vendor{
+		Company:            "snapp",
+		Type:               "manual",
+		AllowedAccessTypes: []string{"pub", "sub"},
+		Topics: []authorizer.Topic{
+			{
+				Type:     "driver_location",
+				Template: "^{{.company}}/driver/{{.sub}}/location$",
+				Accesses: map[string]acl.AccessType{testutil.DriverIss: acl.Pub},
+			},
+		},
+		Keys: map[string]string{
+			testutil.DriverIss: base64.StdEncoding.EncodeToString(key),
+		},
+		IssEntityMap: map[string]string{"default": ""},
+		IssPeerMap:   map[string]string{"default": ""},
+		JWT: authorizer.JWT{
+			IssName:       "iss",
+			SubName:       "sub",
+			SigningMethod: "HS512",
+		},
+	})
+	require.NoError(t, err)
+
+	return a
+}
+
+type testCase struct {
+	name    string
+	header  string
+	options []middleware.Option
+	status  int
+	body    string
+}
+
+func cases(t *testing.T, key []byte) []testCase {
+	t.Helper()
+
+	token, err := testutil.DriverToken(jwt.SigningMethodHS512, key)
+	require.NoError(t, err)
+
+	invalid, err := testutil.DriverToken(jwt.SigningMethodHS512, []byte("invalid"))
+	require.NoError(t, err)
+
+	return []testCase{
+		{
+			name: "without token", header: "", options: nil,
+			status: http.StatusUnauthorized, body: middleware.ErrTokenRequired.Error(),
+		},
+		{
+			name: "invalid token", header: "Bearer " + invalid, options: nil,
+			status: http.StatusUnauthorized, body: middleware.ErrTokenInvalid.Error(),
+		},
+		{
+			name: "valid token", header: "Bearer " + token, options: nil,
+			status: http.StatusOK, body: testutil.DriverIss + "/" + testutil.DefaultSubject,
+		},
+		{
+			name: "token with permission", header: "bearer " + token,
+			options: []middleware.Option{middleware.WithPermission(acl.Pub, location)},
+			status:  http.StatusOK, body: testutil.DriverIss + "/" + testutil.DefaultSubject,
+		},
+		{
+			name: "token without permission", header: "Bearer " + token,
+			options: []middleware.Option{middleware.WithPermission(acl.Sub, location)},
+			status:  http.StatusForbidden, body: middleware.ErrPermissionDenied.Error(),
+		},
+	}
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	key := []byte("secret")
+	a := newAuthorizer(t, key)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, middleware.IssuerFromContext(r.Context())+"/"+middleware.SubjectFromContext(r.Context()))
+	})
+
+	for _, tc := range cases(t, key) {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+
+			resp := httptest.NewRecorder()
+
+			middleware.Handler(a, next, tc.options...).ServeHTTP(resp, req)
+
+			require.Equal(t, tc.status, resp.Code)
+			require.Contains(t, resp.Body.String(), tc.body)
+		})
+	}
+}
+
+func TestFiber(t *testing.T) {
+	t.Parallel()
+
+	key := []byte("secret")
+	a := newAuthorizer(t, key)
+
+	for _, tc := range cases(t, key) {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			app := fiber.New()
+			app.Get("/", middleware.Fiber(a, tc.options...), func(c *fiber.Ctx) error {
+				claims, ok := middleware.ClaimsFromContext(c.UserContext())
+				require.True(t, ok)
+				require.Equal(t, "snapp", claims.Company)
+
+				return c.SendString(claims.Issuer + "/" + claims.Subject)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.header != "" {
+				req.Header.Set(fiber.HeaderAuthorization, tc.header)
+			}
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.status, resp.StatusCode)
+			require.Contains(t, string(body), tc.body)
+		})
+	}
+}
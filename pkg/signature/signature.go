@@ -0,0 +1,88 @@
+// Package signature signs and verifies the decisions of Soteria, so brokers can check
+// the responses are coming from Soteria. It only depends on the standard library
+// and can be vendored by the broker plugins.
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// Header has the hex encoded HMAC-SHA256 of the canonical string.
+	Header = "X-Soteria-Signature"
+	// TimestampHeader has the unix time in seconds when the response is signed.
+	TimestampHeader = "X-Soteria-Timestamp"
+
+	// DefaultMaxAge is the maximum age of signatures which are accepted by Verify.
+	DefaultMaxAge = 30 * time.Second
+)
+
+var (
+	ErrInvalidSignature = errors.New("signature is not valid")
+	ErrInvalidTimestamp = errors.New("signature timestamp is not valid")
+	ErrExpiredSignature = errors.New("signature is expired")
+)
+
+// Canonical returns the signed string which has the timestamp, path, decision and topic
+// separated by new lines. topic is empty for the auth responses.
+func Canonical(timestamp int64, path, decision, topic string) string {
+	return strconv.FormatInt(timestamp, 10) + "\n" + path + "\n" + decision + "\n" + topic
+}
+
+// Signer signs the decisions using a shared secret, it is safe for concurrent use
+// and reuses its hash states.
+type Signer struct {
+	pool sync.Pool
+}
+
+func NewSigner(secret []byte) *Signer {
+	key := make([]byte, len(secret))
+	copy(key, secret)
+
+	return &Signer{
+		pool: sync.Pool{
+			New: func() any {
+				return hmac.New(sha256.New, key)
+			},
+		},
+	}
+}
+
+// Sign returns the hex encoded signature of the decision.
+func (s *Signer) Sign(timestamp int64, path, decision, topic string) string {
+	mac, _ := s.pool.Get().(hash.Hash)
+	defer s.pool.Put(mac)
+
+	mac.Reset()
+	_, _ = mac.Write([]byte(Canonical(timestamp, path, decision, topic)))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature and timestamp headers of a response. Signatures older than maxAge
+// or from more than maxAge in the future are rejected to prevent their replay.
+func (s *Signer) Verify(signature, timestamp string, path, decision, topic string, now time.Time, maxAge time.Duration) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+
+	if age := now.Sub(time.Unix(ts, 0)); age > maxAge || age < -maxAge {
+		return ErrExpiredSignature
+	}
+
+	expected := s.Sign(ts, path, decision, topic)
+
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package signature_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/snapp-incubator/soteria/pkg/signature"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonical(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "1700000000\n/v2/acl\nallow\nsnapp/driver/1/location",
+		signature.Canonical(1700000000, "/v2/acl", "allow", "snapp/driver/1/location"))
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	signer := signature.NewSigner([]byte("secret"))
+
+	now := time.Now()
+	ts := strconv.FormatInt(now.Unix(), 10)
+
+	sig := signer.Sign(now.Unix(), "/v2/acl", "allow", "topic")
+	require.Len(sig, 64)
+	require.Equal(sig, signer.Sign(now.Unix(), "/v2/acl", "allow", "topic"))
+
+	require.NoError(signer.Verify(sig, ts, "/v2/acl", "allow", "topic", now, signature.DefaultMaxAge))
+
+	require.ErrorIs(
+		signer.Verify(sig, ts, "/v2/acl", "deny", "topic", now, signature.DefaultMaxAge),
+		signature.ErrInvalidSignature,
+	)
+	require.ErrorIs(
+		signer.Verify(sig, ts, "/v2/acl", "allow", "other", now, signature.DefaultMaxAge),
+		signature.ErrInvalidSignature,
+	)
+	require.ErrorIs(
+		signature.NewSigner([]byte("other")).Verify(sig, ts, "/v2/acl", "allow", "topic", now, signature.DefaultMaxAge),
+		signature.ErrInvalidSignature,
+	)
+	require.ErrorIs(
+		signer.Verify(sig, ts, "/v2/acl", "allow", "topic", now.Add(time.Minute), signature.DefaultMaxAge),
+		signature.ErrExpiredSignature,
+	)
+	require.ErrorIs(
+		signer.Verify(sig, "now", "/v2/acl", "allow", "topic", now, signature.DefaultMaxAge),
+		signature.ErrInvalidTimestamp,
+	)
+}
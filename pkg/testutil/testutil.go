@@ -0,0 +1,137 @@
+// Package testutil generates keys and tokens in memory so tests don't
+// depend on the committed fixtures that can expire or get lost.
+package testutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// RSAKeySize is the size of the generated RSA keys, it is the minimum size of keygen rather than
+	// its 4096 bits default to keep tests fast.
+	RSAKeySize = 2048
+
+	// DefaultSubject is the hash-id of 1 with the default snapp vendor salt.
+	DefaultSubject = "DXKgaNQa7N5Y7bo"
+
+	// DefaultExpiration is used when claims have no expiration, it is long enough to never expire in tests.
+	DefaultExpiration = 24 * time.Hour
+
+	DriverIss     = "0"
+	PassengerIss  = "1"
+	ThirdPartyIss = "100"
+)
+
+// Claims are the options for generating a token.
+type Claims struct {
+	Issuer  string
+	Subject string
+	// ExpiresIn is relative to now, so negative values generate expired tokens.
+	ExpiresIn time.Duration
+	// NoExpiration omits the exp claim.
+	NoExpiration bool
+	// Extra claims are added to the token as they are.
+	Extra map[string]any
+	// Kid is set as the kid header of token when it is not empty.
+	Kid string
+}
+
+// RSAKey generates a new RSA private key.
+func RSAKey() (*rsa.PrivateKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, RSAKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate rsa key %w", err)
+	}
+
+	return key, nil
+}
+
+// ECDSAKey generates a new ECDSA private key on P-256 curve.
+func ECDSAKey() (*ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate ecdsa key %w", err)
+	}
+
+	return key, nil
+}
+
+// PublicKeyPEM encodes the public key in PEM format as it is used in vendor keys configuration.
+func PublicKeyPEM(key any) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", fmt.Errorf("cannot marshal public key %w", err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{
+		Type:    "PUBLIC KEY",
+		Headers: nil,
+		Bytes:   der,
+	})), nil
+}
+
+// Token generates a signed token with the given claims.
+func Token(method jwt.SigningMethod, key any, c Claims) (string, error) {
+	claims := jwt.MapClaims{}
+
+	for k, v := range c.Extra {
+		claims[k] = v
+	}
+
+	if c.Issuer != "" {
+		claims["iss"] = c.Issuer
+	}
+
+	if c.Subject != "" {
+		claims["sub"] = c.Subject
+	}
+
+	if !c.NoExpiration {
+		exp := c.ExpiresIn
+		if exp == 0 {
+			exp = DefaultExpiration
+		}
+
+		claims["exp"] = jwt.NewNumericDate(time.Now().Add(exp))
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+
+	if c.Kid != "" {
+		token.Header["kid"] = c.Kid
+	}
+
+	tokenString, err := token.SignedString(key)
+	if err != nil {
+		return "", fmt.Errorf("cannot generate a signed string %w", err)
+	}
+
+	return tokenString, nil
+}
+
+// DriverToken generates a driver token with the default subject.
+func DriverToken(method jwt.SigningMethod, key any) (string, error) {
+	// nolint: exhaustruct
+	return Token(method, key, Claims{Issuer: DriverIss, Subject: DefaultSubject})
+}
+
+// PassengerToken generates a passenger token with the default subject.
+func PassengerToken(method jwt.SigningMethod, key any) (string, error) {
+	// nolint: exhaustruct
+	return Token(method, key, Claims{Issuer: PassengerIss, Subject: DefaultSubject})
+}
+
+// ThirdPartyToken generates a third-party token with the default subject.
+func ThirdPartyToken(method jwt.SigningMethod, key any) (string, error) {
+	// nolint: exhaustruct
+	return Token(method, key, Claims{Issuer: ThirdPartyIss, Subject: DefaultSubject})
+}
@@ -0,0 +1,101 @@
+package testutil_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/snapp-incubator/soteria/pkg/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRSAToken(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	key, err := testutil.RSAKey()
+	require.NoError(err)
+
+	public, err := testutil.PublicKeyPEM(&key.PublicKey)
+	require.NoError(err)
+
+	publicKey, err := jwt.ParseRSAPublicKeyFromPEM([]byte(public))
+	require.NoError(err)
+
+	token, err := testutil.DriverToken(jwt.SigningMethodRS512, key)
+	require.NoError(err)
+
+	var claims jwt.MapClaims
+
+	_, err = jwt.NewParser().ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) {
+		return publicKey, nil
+	})
+	require.NoError(err)
+	require.Equal(testutil.DriverIss, claims["iss"])
+	require.Equal(testutil.DefaultSubject, claims["sub"])
+}
+
+func TestECDSAToken(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	key, err := testutil.ECDSAKey()
+	require.NoError(err)
+
+	public, err := testutil.PublicKeyPEM(&key.PublicKey)
+	require.NoError(err)
+
+	publicKey, err := jwt.ParseECPublicKeyFromPEM([]byte(public))
+	require.NoError(err)
+
+	token, err := testutil.PassengerToken(jwt.SigningMethodES256, key)
+	require.NoError(err)
+
+	_, err = jwt.NewParser().Parse(token, func(*jwt.Token) (any, error) {
+		return publicKey, nil
+	})
+	require.NoError(err)
+}
+
+func TestTokenExpiration(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	key := []byte("secret")
+
+	token, err := testutil.Token(jwt.SigningMethodHS256, key, testutil.Claims{
+		Issuer:       testutil.ThirdPartyIss,
+		Subject:      testutil.DefaultSubject,
+		ExpiresIn:    -time.Minute,
+		NoExpiration: false,
+		Extra:        map[string]any{"uid": "1"},
+		Kid:          "",
+	})
+	require.NoError(err)
+
+	_, err = jwt.NewParser().Parse(token, func(*jwt.Token) (any, error) {
+		return key, nil
+	})
+	require.ErrorIs(err, jwt.ErrTokenExpired)
+
+	token, err = testutil.Token(jwt.SigningMethodHS256, key, testutil.Claims{
+		Issuer:       testutil.ThirdPartyIss,
+		Subject:      testutil.DefaultSubject,
+		ExpiresIn:    0,
+		NoExpiration: true,
+		Extra:        nil,
+		Kid:          "",
+	})
+	require.NoError(err)
+
+	var claims jwt.MapClaims
+
+	_, err = jwt.NewParser().ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) {
+		return key, nil
+	})
+	require.NoError(err)
+	require.NotContains(claims, "exp")
+}
@@ -1,9 +1,12 @@
 package validator
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"time"
@@ -23,8 +26,81 @@ var (
 	ErrInvalidJWT            = errors.New("invalid jwt")
 	ErrInvalidUserDataHeader = errors.New("invalid X-User-Data header")
 	ErrRequestFailed         = errors.New("validator request failed")
+	// ErrTokenExpired is returned with ErrRequestFailed when validator rejects the token because it is expired.
+	ErrTokenExpired = errors.New("token is expired")
+	// ErrTokenSkewed is returned with ErrRequestFailed when validator rejects the token because its iat or nbf
+	// is in the future of the validator clock.
+	ErrTokenSkewed = errors.New("token is not valid yet")
 )
 
+// Codes are reported by validator in the code field of its JSON responses for the rejections
+// which its clients handle differently.
+const (
+	CodeTokenExpired          = "token_expired"
+	CodeTokenNotValidYet      = "token_not_valid_yet"
+	CodeTokenUsedBeforeIssued = "token_used_before_issued"
+)
+
+// maxReasonLength bounds the response body of validator which is read for the reason of rejection.
+const maxReasonLength = 1024
+
+// RequestFailedError is the rejection of validator with its status code and the code and reason of its response,
+// it is ErrRequestFailed and also ErrTokenExpired or ErrTokenSkewed when the token is rejected because of its time.
+type RequestFailedError struct {
+	StatusCode int
+	Code       string
+	Reason     string
+}
+
+func (err RequestFailedError) Error() string {
+	if err.Reason == "" {
+		return fmt.Sprintf("%s: status %d", ErrRequestFailed, err.StatusCode)
+	}
+
+	return fmt.Sprintf("%s: status %d: %s", ErrRequestFailed, err.StatusCode, err.Reason)
+}
+
+// Expired reports whether validator rejected the token because it is expired, the reason is not checked
+// because its message is free text.
+func (err RequestFailedError) Expired() bool {
+	return err.Code == CodeTokenExpired
+}
+
+// Skewed reports whether validator rejected the token because its iat or nbf is in the future.
+func (err RequestFailedError) Skewed() bool {
+	return err.Code == CodeTokenNotValidYet || err.Code == CodeTokenUsedBeforeIssued
+}
+
+func (err RequestFailedError) Unwrap() []error {
+	if err.Expired() {
+		return []error{ErrRequestFailed, ErrTokenExpired}
+	}
+
+	if err.Skewed() {
+		return []error{ErrRequestFailed, ErrTokenSkewed}
+	}
+
+	return []error{ErrRequestFailed}
+}
+
+// rejection returns the code and reason of validator response, the code is the code field of JSON responses
+// and the reason is their message or error field or the body of the other responses.
+func rejection(body io.Reader) (string, string) {
+	raw, _ := io.ReadAll(io.LimitReader(body, maxReasonLength))
+
+	var message struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Error   string `json:"error"`
+	}
+
+	if err := json.Unmarshal(raw, &message); err == nil {
+		return message.Code, cmp.Or(message.Message, message.Error)
+	}
+
+	return "", strings.TrimSpace(string(raw))
+}
+
 type Client struct {
 	baseURL    string
 	client     *http.Client
@@ -101,7 +177,9 @@ func (c *Client) Validate(parentCtx context.Context, headers http.Header, bearer
 	}()
 
 	if response.StatusCode != http.StatusOK {
-		return ErrRequestFailed
+		code, reason := rejection(response.Body)
+
+		return RequestFailedError{StatusCode: response.StatusCode, Code: code, Reason: reason}
 	}
 
 	userDataHeader := response.Header.Get(userDataHeader)